@@ -411,8 +411,24 @@ type SolveRequest struct {
 	SourcePolicySession     string                    `protobuf:"bytes,15,opt,name=SourcePolicySession,proto3" json:"SourcePolicySession,omitempty"`
 	CompatibilityVersion    int64                     `protobuf:"varint,16,opt,name=CompatibilityVersion,proto3" json:"CompatibilityVersion,omitempty"`
 	ProxyNetwork            bool                      `protobuf:"varint,17,opt,name=ProxyNetwork,proto3" json:"ProxyNetwork,omitempty"`
-	unknownFields           protoimpl.UnknownFields
-	sizeCache               protoimpl.SizeCache
+	// Priority is the priority class of the build: "low", "normal" or "high".
+	// Queued operations of builds with a higher priority are started first
+	// when the worker limits the number of parallel operations.
+	Priority string `protobuf:"bytes,18,opt,name=Priority,proto3" json:"Priority,omitempty"`
+	// Tenant identifies the owner of the build. Workers share their
+	// parallelism limit fairly between tenants of the same priority.
+	Tenant string `protobuf:"bytes,19,opt,name=Tenant,proto3" json:"Tenant,omitempty"`
+	// ProxyRecord stores the response bodies of successful GET requests made
+	// through the proxy network in the content store.
+	ProxyRecord bool `protobuf:"varint,20,opt,name=ProxyRecord,proto3" json:"ProxyRecord,omitempty"`
+	// ProxyReplay serves requests made through the proxy network only from
+	// recorded responses, without network access.
+	ProxyReplay bool `protobuf:"varint,21,opt,name=ProxyReplay,proto3" json:"ProxyReplay,omitempty"`
+	// ProxyReplayMaterials maps the URLs that can be requested in replay mode
+	// to the digests of their recorded responses.
+	ProxyReplayMaterials map[string]string `protobuf:"bytes,22,rep,name=ProxyReplayMaterials,proto3" json:"ProxyReplayMaterials,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
 }
 
 func (x *SolveRequest) Reset() {
@@ -564,6 +580,41 @@ func (x *SolveRequest) GetProxyNetwork() bool {
 	return false
 }
 
+func (x *SolveRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *SolveRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *SolveRequest) GetProxyRecord() bool {
+	if x != nil {
+		return x.ProxyRecord
+	}
+	return false
+}
+
+func (x *SolveRequest) GetProxyReplay() bool {
+	if x != nil {
+		return x.ProxyReplay
+	}
+	return false
+}
+
+func (x *SolveRequest) GetProxyReplayMaterials() map[string]string {
+	if x != nil {
+		return x.ProxyReplayMaterials
+	}
+	return nil
+}
+
 type CacheOptions struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// ExportRefDeprecated is deprecated in favor or the new Exports since BuildKit v0.4.0.
@@ -1584,6 +1635,8 @@ type BuildHistoryRecord struct {
 	NumCompletedSteps int32                       `protobuf:"varint,17,opt,name=numCompletedSteps,proto3" json:"numCompletedSteps,omitempty"`
 	ExternalError     *Descriptor                 `protobuf:"bytes,18,opt,name=externalError,proto3" json:"externalError,omitempty"`
 	NumWarnings       int32                       `protobuf:"varint,19,opt,name=numWarnings,proto3" json:"numWarnings,omitempty"`
+	CacheInfo         *Descriptor                 `protobuf:"bytes,20,opt,name=cacheInfo,proto3" json:"cacheInfo,omitempty"`
+	ResourceUsage     *Descriptor                 `protobuf:"bytes,21,opt,name=resourceUsage,proto3" json:"resourceUsage,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -1751,6 +1804,20 @@ func (x *BuildHistoryRecord) GetNumWarnings() int32 {
 	return 0
 }
 
+func (x *BuildHistoryRecord) GetCacheInfo() *Descriptor {
+	if x != nil {
+		return x.CacheInfo
+	}
+	return nil
+}
+
+func (x *BuildHistoryRecord) GetResourceUsage() *Descriptor {
+	if x != nil {
+		return x.ResourceUsage
+	}
+	return nil
+}
+
 type UpdateBuildHistoryRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ref           string                 `protobuf:"bytes,1,opt,name=Ref,proto3" json:"Ref,omitempty"`
@@ -1855,30 +1922,30 @@ func (*UpdateBuildHistoryResponse) Descriptor() ([]byte, []int) {
 	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{23}
 }
 
-type Descriptor struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	MediaType     string                 `protobuf:"bytes,1,opt,name=media_type,json=mediaType,proto3" json:"media_type,omitempty"`
-	Digest        string                 `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
-	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
-	Annotations   map[string]string      `protobuf:"bytes,5,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+type ExplainCacheMissRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ref is the build record containing the vertex
+	Ref string `protobuf:"bytes,1,opt,name=Ref,proto3" json:"Ref,omitempty"`
+	// Vertex is the digest of the vertex that did not match the cache
+	Vertex        string `protobuf:"bytes,2,opt,name=Vertex,proto3" json:"Vertex,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Descriptor) Reset() {
-	*x = Descriptor{}
+func (x *ExplainCacheMissRequest) Reset() {
+	*x = ExplainCacheMissRequest{}
 	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Descriptor) String() string {
+func (x *ExplainCacheMissRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Descriptor) ProtoMessage() {}
+func (*ExplainCacheMissRequest) ProtoMessage() {}
 
-func (x *Descriptor) ProtoReflect() protoreflect.Message {
+func (x *ExplainCacheMissRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1890,62 +1957,53 @@ func (x *Descriptor) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Descriptor.ProtoReflect.Descriptor instead.
-func (*Descriptor) Descriptor() ([]byte, []int) {
+// Deprecated: Use ExplainCacheMissRequest.ProtoReflect.Descriptor instead.
+func (*ExplainCacheMissRequest) Descriptor() ([]byte, []int) {
 	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *Descriptor) GetMediaType() string {
+func (x *ExplainCacheMissRequest) GetRef() string {
 	if x != nil {
-		return x.MediaType
+		return x.Ref
 	}
 	return ""
 }
 
-func (x *Descriptor) GetDigest() string {
+func (x *ExplainCacheMissRequest) GetVertex() string {
 	if x != nil {
-		return x.Digest
+		return x.Vertex
 	}
 	return ""
 }
 
-func (x *Descriptor) GetSize() int64 {
-	if x != nil {
-		return x.Size
-	}
-	return 0
-}
-
-func (x *Descriptor) GetAnnotations() map[string]string {
-	if x != nil {
-		return x.Annotations
-	}
-	return nil
-}
-
-type BuildResultInfo struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	ResultDeprecated *Descriptor            `protobuf:"bytes,1,opt,name=ResultDeprecated,proto3" json:"ResultDeprecated,omitempty"`
-	Attestations     []*Descriptor          `protobuf:"bytes,2,rep,name=Attestations,proto3" json:"Attestations,omitempty"`
-	Results          map[int64]*Descriptor  `protobuf:"bytes,3,rep,name=Results,proto3" json:"Results,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+type ExplainCacheMissResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Vertex string                 `protobuf:"bytes,1,opt,name=Vertex,proto3" json:"Vertex,omitempty"`
+	Name   string                 `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	// Cached is set if the vertex matched the cache
+	Cached bool `protobuf:"varint,3,opt,name=Cached,proto3" json:"Cached,omitempty"`
+	// PreviousRef and PreviousVertex are the closest vertex in a previous build
+	PreviousRef    string             `protobuf:"bytes,4,opt,name=PreviousRef,proto3" json:"PreviousRef,omitempty"`
+	PreviousVertex string             `protobuf:"bytes,5,opt,name=PreviousVertex,proto3" json:"PreviousVertex,omitempty"`
+	Reasons        []*CacheMissReason `protobuf:"bytes,6,rep,name=Reasons,proto3" json:"Reasons,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
-func (x *BuildResultInfo) Reset() {
-	*x = BuildResultInfo{}
+func (x *ExplainCacheMissResponse) Reset() {
+	*x = ExplainCacheMissResponse{}
 	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BuildResultInfo) String() string {
+func (x *ExplainCacheMissResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BuildResultInfo) ProtoMessage() {}
+func (*ExplainCacheMissResponse) ProtoMessage() {}
 
-func (x *BuildResultInfo) ProtoReflect() protoreflect.Message {
+func (x *ExplainCacheMissResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1957,57 +2015,82 @@ func (x *BuildResultInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BuildResultInfo.ProtoReflect.Descriptor instead.
-func (*BuildResultInfo) Descriptor() ([]byte, []int) {
+// Deprecated: Use ExplainCacheMissResponse.ProtoReflect.Descriptor instead.
+func (*ExplainCacheMissResponse) Descriptor() ([]byte, []int) {
 	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{25}
 }
 
-func (x *BuildResultInfo) GetResultDeprecated() *Descriptor {
+func (x *ExplainCacheMissResponse) GetVertex() string {
 	if x != nil {
-		return x.ResultDeprecated
+		return x.Vertex
 	}
-	return nil
+	return ""
 }
 
-func (x *BuildResultInfo) GetAttestations() []*Descriptor {
+func (x *ExplainCacheMissResponse) GetName() string {
 	if x != nil {
-		return x.Attestations
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-func (x *BuildResultInfo) GetResults() map[int64]*Descriptor {
+func (x *ExplainCacheMissResponse) GetCached() bool {
 	if x != nil {
-		return x.Results
+		return x.Cached
+	}
+	return false
+}
+
+func (x *ExplainCacheMissResponse) GetPreviousRef() string {
+	if x != nil {
+		return x.PreviousRef
+	}
+	return ""
+}
+
+func (x *ExplainCacheMissResponse) GetPreviousVertex() string {
+	if x != nil {
+		return x.PreviousVertex
+	}
+	return ""
+}
+
+func (x *ExplainCacheMissResponse) GetReasons() []*CacheMissReason {
+	if x != nil {
+		return x.Reasons
 	}
 	return nil
 }
 
-// Exporter describes the output exporter
-type Exporter struct {
+type CacheMissReason struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
-	// Type identifies the exporter
+	// Type is one of op, input, content, ignore-cache, new or unchanged
 	Type string `protobuf:"bytes,1,opt,name=Type,proto3" json:"Type,omitempty"`
-	// Attrs specifies exporter configuration
-	Attrs         map[string]string `protobuf:"bytes,2,rep,name=Attrs,proto3" json:"Attrs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Vertex is the explained vertex or the ancestor the change was found in
+	Vertex string `protobuf:"bytes,2,opt,name=Vertex,proto3" json:"Vertex,omitempty"`
+	Name   string `protobuf:"bytes,3,opt,name=Name,proto3" json:"Name,omitempty"`
+	// Field is the changed op field, input or content path
+	Field         string `protobuf:"bytes,4,opt,name=Field,proto3" json:"Field,omitempty"`
+	Old           string `protobuf:"bytes,5,opt,name=Old,proto3" json:"Old,omitempty"`
+	New           string `protobuf:"bytes,6,opt,name=New,proto3" json:"New,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Exporter) Reset() {
-	*x = Exporter{}
+func (x *CacheMissReason) Reset() {
+	*x = CacheMissReason{}
 	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Exporter) String() string {
+func (x *CacheMissReason) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Exporter) ProtoMessage() {}
+func (*CacheMissReason) ProtoMessage() {}
 
-func (x *Exporter) ProtoReflect() protoreflect.Message {
+func (x *CacheMissReason) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -2019,69 +2102,1028 @@ func (x *Exporter) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Exporter.ProtoReflect.Descriptor instead.
-func (*Exporter) Descriptor() ([]byte, []int) {
+// Deprecated: Use CacheMissReason.ProtoReflect.Descriptor instead.
+func (*CacheMissReason) Descriptor() ([]byte, []int) {
 	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *Exporter) GetType() string {
+func (x *CacheMissReason) GetType() string {
 	if x != nil {
 		return x.Type
 	}
 	return ""
 }
 
-func (x *Exporter) GetAttrs() map[string]string {
+func (x *CacheMissReason) GetVertex() string {
 	if x != nil {
-		return x.Attrs
+		return x.Vertex
+	}
+	return ""
+}
+
+func (x *CacheMissReason) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CacheMissReason) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *CacheMissReason) GetOld() string {
+	if x != nil {
+		return x.Old
+	}
+	return ""
+}
+
+func (x *CacheMissReason) GetNew() string {
+	if x != nil {
+		return x.New
+	}
+	return ""
+}
+
+type ResourceUsageRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ref is the build record to return the resource usage of
+	Ref           string `protobuf:"bytes,1,opt,name=Ref,proto3" json:"Ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResourceUsageRequest) Reset() {
+	*x = ResourceUsageRequest{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceUsageRequest) ProtoMessage() {}
+
+func (x *ResourceUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceUsageRequest.ProtoReflect.Descriptor instead.
+func (*ResourceUsageRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ResourceUsageRequest) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+type ResourceUsageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vertexes      []*VertexResourceUsage `protobuf:"bytes,1,rep,name=Vertexes,proto3" json:"Vertexes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResourceUsageResponse) Reset() {
+	*x = ResourceUsageResponse{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceUsageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceUsageResponse) ProtoMessage() {}
+
+func (x *ResourceUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceUsageResponse.ProtoReflect.Descriptor instead.
+func (*ResourceUsageResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ResourceUsageResponse) GetVertexes() []*VertexResourceUsage {
+	if x != nil {
+		return x.Vertexes
 	}
 	return nil
 }
 
-var File_github_com_moby_buildkit_api_services_control_control_proto protoreflect.FileDescriptor
+// VertexResourceUsage is the resource usage of the container of an executed
+// vertex. Durations are in nanoseconds.
+type VertexResourceUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vertex        string                 `protobuf:"bytes,1,opt,name=Vertex,proto3" json:"Vertex,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=Started,proto3" json:"Started,omitempty"`
+	Duration      int64                  `protobuf:"varint,4,opt,name=Duration,proto3" json:"Duration,omitempty"`
+	CPU           int64                  `protobuf:"varint,5,opt,name=CPU,proto3" json:"CPU,omitempty"`
+	PeakMemory    uint64                 `protobuf:"varint,6,opt,name=PeakMemory,proto3" json:"PeakMemory,omitempty"`
+	OOMKills      uint64                 `protobuf:"varint,7,opt,name=OOMKills,proto3" json:"OOMKills,omitempty"`
+	PeakPIDs      uint64                 `protobuf:"varint,8,opt,name=PeakPIDs,proto3" json:"PeakPIDs,omitempty"`
+	IOReadBytes   uint64                 `protobuf:"varint,9,opt,name=IOReadBytes,proto3" json:"IOReadBytes,omitempty"`
+	IOWriteBytes  uint64                 `protobuf:"varint,10,opt,name=IOWriteBytes,proto3" json:"IOWriteBytes,omitempty"`
+	NetRxBytes    int64                  `protobuf:"varint,11,opt,name=NetRxBytes,proto3" json:"NetRxBytes,omitempty"`
+	NetTxBytes    int64                  `protobuf:"varint,12,opt,name=NetTxBytes,proto3" json:"NetTxBytes,omitempty"`
+	CPUStall      int64                  `protobuf:"varint,13,opt,name=CPUStall,proto3" json:"CPUStall,omitempty"`
+	MemoryStall   int64                  `protobuf:"varint,14,opt,name=MemoryStall,proto3" json:"MemoryStall,omitempty"`
+	IOStall       int64                  `protobuf:"varint,15,opt,name=IOStall,proto3" json:"IOStall,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc = "" +
-	"\n" +
-	";github.com/moby/buildkit/api/services/control/control.proto\x12\x10moby.buildkit.v1\x1a/github.com/moby/buildkit/api/types/worker.proto\x1a,github.com/moby/buildkit/solver/pb/ops.proto\x1a5github.com/moby/buildkit/sourcepolicy/pb/policy.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17google/rpc/status.proto\"\xca\x01\n" +
-	"\fPruneRequest\x12\x16\n" +
-	"\x06filter\x18\x01 \x03(\tR\x06filter\x12\x10\n" +
-	"\x03all\x18\x02 \x01(\bR\x03all\x12\"\n" +
-	"\fkeepDuration\x18\x03 \x01(\x03R\fkeepDuration\x12$\n" +
-	"\rreservedSpace\x18\x04 \x01(\x03R\rreservedSpace\x12\"\n" +
-	"\fmaxUsedSpace\x18\x05 \x01(\x03R\fmaxUsedSpace\x12\"\n" +
-	"\fminFreeSpace\x18\x06 \x01(\x03R\fminFreeSpace\"F\n" +
-	"\x10DiskUsageRequest\x12\x16\n" +
-	"\x06filter\x18\x01 \x03(\tR\x06filter\x12\x1a\n" +
-	"\bageLimit\x18\x02 \x01(\x03R\bageLimit\"J\n" +
-	"\x11DiskUsageResponse\x125\n" +
-	"\x06record\x18\x01 \x03(\v2\x1d.moby.buildkit.v1.UsageRecordR\x06record\"\x87\x03\n" +
-	"\vUsageRecord\x12\x0e\n" +
-	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x18\n" +
-	"\aMutable\x18\x02 \x01(\bR\aMutable\x12\x14\n" +
-	"\x05InUse\x18\x03 \x01(\bR\x05InUse\x12\x12\n" +
-	"\x04Size\x18\x04 \x01(\x03R\x04Size\x12\x1a\n" +
-	"\x06Parent\x18\x05 \x01(\tB\x02\x18\x01R\x06Parent\x128\n" +
-	"\tCreatedAt\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tCreatedAt\x12:\n" +
-	"\n" +
-	"LastUsedAt\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
-	"LastUsedAt\x12\x1e\n" +
-	"\n" +
-	"UsageCount\x18\b \x01(\x03R\n" +
-	"UsageCount\x12 \n" +
-	"\vDescription\x18\t \x01(\tR\vDescription\x12\x1e\n" +
-	"\n" +
-	"RecordType\x18\n" +
-	" \x01(\tR\n" +
-	"RecordType\x12\x16\n" +
-	"\x06Shared\x18\v \x01(\bR\x06Shared\x12\x18\n" +
-	"\aParents\x18\f \x03(\tR\aParents\"\xfe\b\n" +
-	"\fSolveRequest\x12\x10\n" +
-	"\x03Ref\x18\x01 \x01(\tR\x03Ref\x12.\n" +
-	"\n" +
-	"Definition\x18\x02 \x01(\v2\x0e.pb.DefinitionR\n" +
-	"Definition\x12.\n" +
-	"\x12ExporterDeprecated\x18\x03 \x01(\tR\x12ExporterDeprecated\x12u\n" +
-	"\x17ExporterAttrsDeprecated\x18\x04 \x03(\v2;.moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecatedEntryR\x17ExporterAttrsDeprecated\x12\x18\n" +
+func (x *VertexResourceUsage) Reset() {
+	*x = VertexResourceUsage{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VertexResourceUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VertexResourceUsage) ProtoMessage() {}
+
+func (x *VertexResourceUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VertexResourceUsage.ProtoReflect.Descriptor instead.
+func (*VertexResourceUsage) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *VertexResourceUsage) GetVertex() string {
+	if x != nil {
+		return x.Vertex
+	}
+	return ""
+}
+
+func (x *VertexResourceUsage) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *VertexResourceUsage) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *VertexResourceUsage) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetCPU() int64 {
+	if x != nil {
+		return x.CPU
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetPeakMemory() uint64 {
+	if x != nil {
+		return x.PeakMemory
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetOOMKills() uint64 {
+	if x != nil {
+		return x.OOMKills
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetPeakPIDs() uint64 {
+	if x != nil {
+		return x.PeakPIDs
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetIOReadBytes() uint64 {
+	if x != nil {
+		return x.IOReadBytes
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetIOWriteBytes() uint64 {
+	if x != nil {
+		return x.IOWriteBytes
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetNetRxBytes() int64 {
+	if x != nil {
+		return x.NetRxBytes
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetNetTxBytes() int64 {
+	if x != nil {
+		return x.NetTxBytes
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetCPUStall() int64 {
+	if x != nil {
+		return x.CPUStall
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetMemoryStall() int64 {
+	if x != nil {
+		return x.MemoryStall
+	}
+	return 0
+}
+
+func (x *VertexResourceUsage) GetIOStall() int64 {
+	if x != nil {
+		return x.IOStall
+	}
+	return 0
+}
+
+type ListCacheKeysRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Filter only returns the keys with the substring in their ID, summary
+	// or result IDs
+	Filter        string `protobuf:"bytes,1,opt,name=Filter,proto3" json:"Filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCacheKeysRequest) Reset() {
+	*x = ListCacheKeysRequest{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCacheKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCacheKeysRequest) ProtoMessage() {}
+
+func (x *ListCacheKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCacheKeysRequest.ProtoReflect.Descriptor instead.
+func (*ListCacheKeysRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *ListCacheKeysRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+type ListCacheKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []*CacheKeyRecord      `protobuf:"bytes,1,rep,name=Keys,proto3" json:"Keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCacheKeysResponse) Reset() {
+	*x = ListCacheKeysResponse{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCacheKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCacheKeysResponse) ProtoMessage() {}
+
+func (x *ListCacheKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCacheKeysResponse.ProtoReflect.Descriptor instead.
+func (*ListCacheKeysResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *ListCacheKeysResponse) GetKeys() []*CacheKeyRecord {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type InspectCacheKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ID            string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InspectCacheKeyRequest) Reset() {
+	*x = InspectCacheKeyRequest{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectCacheKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectCacheKeyRequest) ProtoMessage() {}
+
+func (x *InspectCacheKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectCacheKeyRequest.ProtoReflect.Descriptor instead.
+func (*InspectCacheKeyRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *InspectCacheKeyRequest) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+type InspectCacheKeyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           *CacheKeyRecord        `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InspectCacheKeyResponse) Reset() {
+	*x = InspectCacheKeyResponse{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectCacheKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectCacheKeyResponse) ProtoMessage() {}
+
+func (x *InspectCacheKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectCacheKeyResponse.ProtoReflect.Descriptor instead.
+func (*InspectCacheKeyResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *InspectCacheKeyResponse) GetKey() *CacheKeyRecord {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+type InvalidateCacheKeyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	ID    string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	// Dependents also invalidates the keys depending on the key
+	Dependents    bool `protobuf:"varint,2,opt,name=Dependents,proto3" json:"Dependents,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvalidateCacheKeyRequest) Reset() {
+	*x = InvalidateCacheKeyRequest{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvalidateCacheKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvalidateCacheKeyRequest) ProtoMessage() {}
+
+func (x *InvalidateCacheKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvalidateCacheKeyRequest.ProtoReflect.Descriptor instead.
+func (*InvalidateCacheKeyRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *InvalidateCacheKeyRequest) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *InvalidateCacheKeyRequest) GetDependents() bool {
+	if x != nil {
+		return x.Dependents
+	}
+	return false
+}
+
+type InvalidateCacheKeyResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Results are the IDs of the released results
+	Results       []string `protobuf:"bytes,1,rep,name=Results,proto3" json:"Results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvalidateCacheKeyResponse) Reset() {
+	*x = InvalidateCacheKeyResponse{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvalidateCacheKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvalidateCacheKeyResponse) ProtoMessage() {}
+
+func (x *InvalidateCacheKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvalidateCacheKeyResponse.ProtoReflect.Descriptor instead.
+func (*InvalidateCacheKeyResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *InvalidateCacheKeyResponse) GetResults() []string {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type CacheKeyRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	ID    string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	// Summary describes the operation of the key. It is only set if the
+	// daemon saves cache debug information.
+	Summary string            `protobuf:"bytes,2,opt,name=Summary,proto3" json:"Summary,omitempty"`
+	Results []*CacheKeyResult `protobuf:"bytes,3,rep,name=Results,proto3" json:"Results,omitempty"`
+	// Links are the keys depending on this key
+	Links []*CacheKeyLink `protobuf:"bytes,4,rep,name=Links,proto3" json:"Links,omitempty"`
+	// Backlinks are the keys this key depends on
+	Backlinks     []*CacheKeyLink `protobuf:"bytes,5,rep,name=Backlinks,proto3" json:"Backlinks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CacheKeyRecord) Reset() {
+	*x = CacheKeyRecord{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CacheKeyRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheKeyRecord) ProtoMessage() {}
+
+func (x *CacheKeyRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheKeyRecord.ProtoReflect.Descriptor instead.
+func (*CacheKeyRecord) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *CacheKeyRecord) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *CacheKeyRecord) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *CacheKeyRecord) GetResults() []*CacheKeyResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *CacheKeyRecord) GetLinks() []*CacheKeyLink {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *CacheKeyRecord) GetBacklinks() []*CacheKeyLink {
+	if x != nil {
+		return x.Backlinks
+	}
+	return nil
+}
+
+type CacheKeyResult struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ID        string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	// Size and LastUsedAt are set if the record of the result exists
+	Size          int64                  `protobuf:"varint,3,opt,name=Size,proto3" json:"Size,omitempty"`
+	LastUsedAt    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=LastUsedAt,proto3" json:"LastUsedAt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CacheKeyResult) Reset() {
+	*x = CacheKeyResult{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CacheKeyResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheKeyResult) ProtoMessage() {}
+
+func (x *CacheKeyResult) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheKeyResult.ProtoReflect.Descriptor instead.
+func (*CacheKeyResult) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *CacheKeyResult) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *CacheKeyResult) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *CacheKeyResult) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *CacheKeyResult) GetLastUsedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUsedAt
+	}
+	return nil
+}
+
+type CacheKeyLink struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ID            string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Input         int64                  `protobuf:"varint,2,opt,name=Input,proto3" json:"Input,omitempty"`
+	Output        int64                  `protobuf:"varint,3,opt,name=Output,proto3" json:"Output,omitempty"`
+	Digest        string                 `protobuf:"bytes,4,opt,name=Digest,proto3" json:"Digest,omitempty"`
+	Selector      string                 `protobuf:"bytes,5,opt,name=Selector,proto3" json:"Selector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CacheKeyLink) Reset() {
+	*x = CacheKeyLink{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CacheKeyLink) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheKeyLink) ProtoMessage() {}
+
+func (x *CacheKeyLink) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheKeyLink.ProtoReflect.Descriptor instead.
+func (*CacheKeyLink) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *CacheKeyLink) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *CacheKeyLink) GetInput() int64 {
+	if x != nil {
+		return x.Input
+	}
+	return 0
+}
+
+func (x *CacheKeyLink) GetOutput() int64 {
+	if x != nil {
+		return x.Output
+	}
+	return 0
+}
+
+func (x *CacheKeyLink) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *CacheKeyLink) GetSelector() string {
+	if x != nil {
+		return x.Selector
+	}
+	return ""
+}
+
+type Descriptor struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MediaType     string                 `protobuf:"bytes,1,opt,name=media_type,json=mediaType,proto3" json:"media_type,omitempty"`
+	Digest        string                 `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Annotations   map[string]string      `protobuf:"bytes,5,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Descriptor) Reset() {
+	*x = Descriptor{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Descriptor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Descriptor) ProtoMessage() {}
+
+func (x *Descriptor) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Descriptor.ProtoReflect.Descriptor instead.
+func (*Descriptor) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *Descriptor) GetMediaType() string {
+	if x != nil {
+		return x.MediaType
+	}
+	return ""
+}
+
+func (x *Descriptor) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *Descriptor) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Descriptor) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+type BuildResultInfo struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	ResultDeprecated *Descriptor            `protobuf:"bytes,1,opt,name=ResultDeprecated,proto3" json:"ResultDeprecated,omitempty"`
+	Attestations     []*Descriptor          `protobuf:"bytes,2,rep,name=Attestations,proto3" json:"Attestations,omitempty"`
+	Results          map[int64]*Descriptor  `protobuf:"bytes,3,rep,name=Results,proto3" json:"Results,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *BuildResultInfo) Reset() {
+	*x = BuildResultInfo{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BuildResultInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildResultInfo) ProtoMessage() {}
+
+func (x *BuildResultInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildResultInfo.ProtoReflect.Descriptor instead.
+func (*BuildResultInfo) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *BuildResultInfo) GetResultDeprecated() *Descriptor {
+	if x != nil {
+		return x.ResultDeprecated
+	}
+	return nil
+}
+
+func (x *BuildResultInfo) GetAttestations() []*Descriptor {
+	if x != nil {
+		return x.Attestations
+	}
+	return nil
+}
+
+func (x *BuildResultInfo) GetResults() map[int64]*Descriptor {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// Exporter describes the output exporter
+type Exporter struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Type identifies the exporter
+	Type string `protobuf:"bytes,1,opt,name=Type,proto3" json:"Type,omitempty"`
+	// Attrs specifies exporter configuration
+	Attrs         map[string]string `protobuf:"bytes,2,rep,name=Attrs,proto3" json:"Attrs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Exporter) Reset() {
+	*x = Exporter{}
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Exporter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Exporter) ProtoMessage() {}
+
+func (x *Exporter) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Exporter.ProtoReflect.Descriptor instead.
+func (*Exporter) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *Exporter) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Exporter) GetAttrs() map[string]string {
+	if x != nil {
+		return x.Attrs
+	}
+	return nil
+}
+
+var File_github_com_moby_buildkit_api_services_control_control_proto protoreflect.FileDescriptor
+
+const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc = "" +
+	"\n" +
+	";github.com/moby/buildkit/api/services/control/control.proto\x12\x10moby.buildkit.v1\x1a/github.com/moby/buildkit/api/types/worker.proto\x1a,github.com/moby/buildkit/solver/pb/ops.proto\x1a5github.com/moby/buildkit/sourcepolicy/pb/policy.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17google/rpc/status.proto\"\xca\x01\n" +
+	"\fPruneRequest\x12\x16\n" +
+	"\x06filter\x18\x01 \x03(\tR\x06filter\x12\x10\n" +
+	"\x03all\x18\x02 \x01(\bR\x03all\x12\"\n" +
+	"\fkeepDuration\x18\x03 \x01(\x03R\fkeepDuration\x12$\n" +
+	"\rreservedSpace\x18\x04 \x01(\x03R\rreservedSpace\x12\"\n" +
+	"\fmaxUsedSpace\x18\x05 \x01(\x03R\fmaxUsedSpace\x12\"\n" +
+	"\fminFreeSpace\x18\x06 \x01(\x03R\fminFreeSpace\"F\n" +
+	"\x10DiskUsageRequest\x12\x16\n" +
+	"\x06filter\x18\x01 \x03(\tR\x06filter\x12\x1a\n" +
+	"\bageLimit\x18\x02 \x01(\x03R\bageLimit\"J\n" +
+	"\x11DiskUsageResponse\x125\n" +
+	"\x06record\x18\x01 \x03(\v2\x1d.moby.buildkit.v1.UsageRecordR\x06record\"\x87\x03\n" +
+	"\vUsageRecord\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x18\n" +
+	"\aMutable\x18\x02 \x01(\bR\aMutable\x12\x14\n" +
+	"\x05InUse\x18\x03 \x01(\bR\x05InUse\x12\x12\n" +
+	"\x04Size\x18\x04 \x01(\x03R\x04Size\x12\x1a\n" +
+	"\x06Parent\x18\x05 \x01(\tB\x02\x18\x01R\x06Parent\x128\n" +
+	"\tCreatedAt\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tCreatedAt\x12:\n" +
+	"\n" +
+	"LastUsedAt\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"LastUsedAt\x12\x1e\n" +
+	"\n" +
+	"UsageCount\x18\b \x01(\x03R\n" +
+	"UsageCount\x12 \n" +
+	"\vDescription\x18\t \x01(\tR\vDescription\x12\x1e\n" +
+	"\n" +
+	"RecordType\x18\n" +
+	" \x01(\tR\n" +
+	"RecordType\x12\x16\n" +
+	"\x06Shared\x18\v \x01(\bR\x06Shared\x12\x18\n" +
+	"\aParents\x18\f \x03(\tR\aParents\"\xad\v\n" +
+	"\fSolveRequest\x12\x10\n" +
+	"\x03Ref\x18\x01 \x01(\tR\x03Ref\x12.\n" +
+	"\n" +
+	"Definition\x18\x02 \x01(\v2\x0e.pb.DefinitionR\n" +
+	"Definition\x12.\n" +
+	"\x12ExporterDeprecated\x18\x03 \x01(\tR\x12ExporterDeprecated\x12u\n" +
+	"\x17ExporterAttrsDeprecated\x18\x04 \x03(\v2;.moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecatedEntryR\x17ExporterAttrsDeprecated\x12\x18\n" +
 	"\aSession\x18\x05 \x01(\tR\aSession\x12\x1a\n" +
 	"\bFrontend\x18\x06 \x01(\tR\bFrontend\x12W\n" +
 	"\rFrontendAttrs\x18\a \x03(\v21.moby.buildkit.v1.SolveRequest.FrontendAttrsEntryR\rFrontendAttrs\x124\n" +
@@ -2095,7 +3137,12 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\x15EnableSessionExporter\x18\x0e \x01(\bR\x15EnableSessionExporter\x120\n" +
 	"\x13SourcePolicySession\x18\x0f \x01(\tR\x13SourcePolicySession\x122\n" +
 	"\x14CompatibilityVersion\x18\x10 \x01(\x03R\x14CompatibilityVersion\x12\"\n" +
-	"\fProxyNetwork\x18\x11 \x01(\bR\fProxyNetwork\x1aJ\n" +
+	"\fProxyNetwork\x18\x11 \x01(\bR\fProxyNetwork\x12\x1a\n" +
+	"\bPriority\x18\x12 \x01(\tR\bPriority\x12\x16\n" +
+	"\x06Tenant\x18\x13 \x01(\tR\x06Tenant\x12 \n" +
+	"\vProxyRecord\x18\x14 \x01(\bR\vProxyRecord\x12 \n" +
+	"\vProxyReplay\x18\x15 \x01(\bR\vProxyReplay\x12l\n" +
+	"\x14ProxyReplayMaterials\x18\x16 \x03(\v28.moby.buildkit.v1.SolveRequest.ProxyReplayMaterialsEntryR\x14ProxyReplayMaterials\x1aJ\n" +
 	"\x1cExporterAttrsDeprecatedEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a@\n" +
@@ -2104,7 +3151,10 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aQ\n" +
 	"\x13FrontendInputsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12$\n" +
-	"\x05value\x18\x02 \x01(\v2\x0e.pb.DefinitionR\x05value:\x028\x01\"\xad\x03\n" +
+	"\x05value\x18\x02 \x01(\v2\x0e.pb.DefinitionR\x05value:\x028\x01\x1aG\n" +
+	"\x19ProxyReplayMaterialsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xad\x03\n" +
 	"\fCacheOptions\x120\n" +
 	"\x13ExportRefDeprecated\x18\x01 \x01(\tR\x13ExportRefDeprecated\x122\n" +
 	"\x14ImportRefsDeprecated\x18\x02 \x03(\tR\x14ImportRefsDeprecated\x12o\n" +
@@ -2183,7 +3233,8 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\x05Limit\x18\x05 \x01(\x05R\x05Limit\"\x8e\x01\n" +
 	"\x11BuildHistoryEvent\x12;\n" +
 	"\x04type\x18\x01 \x01(\x0e2'.moby.buildkit.v1.BuildHistoryEventTypeR\x04type\x12<\n" +
-	"\x06record\x18\x02 \x01(\v2$.moby.buildkit.v1.BuildHistoryRecordR\x06record\"\xd3\t\n" +
+	"\x06record\x18\x02 \x01(\v2$.moby.buildkit.v1.BuildHistoryRecordR\x06record\"\xd3\n" +
+	"\n" +
 	"\x12BuildHistoryRecord\x12\x10\n" +
 	"\x03Ref\x18\x01 \x01(\tR\x03Ref\x12\x1a\n" +
 	"\bFrontend\x18\x02 \x01(\tR\bFrontend\x12]\n" +
@@ -2206,7 +3257,9 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\rnumTotalSteps\x18\x10 \x01(\x05R\rnumTotalSteps\x12,\n" +
 	"\x11numCompletedSteps\x18\x11 \x01(\x05R\x11numCompletedSteps\x12B\n" +
 	"\rexternalError\x18\x12 \x01(\v2\x1c.moby.buildkit.v1.DescriptorR\rexternalError\x12 \n" +
-	"\vnumWarnings\x18\x13 \x01(\x05R\vnumWarnings\x1a@\n" +
+	"\vnumWarnings\x18\x13 \x01(\x05R\vnumWarnings\x12:\n" +
+	"\tcacheInfo\x18\x14 \x01(\v2\x1c.moby.buildkit.v1.DescriptorR\tcacheInfo\x12B\n" +
+	"\rresourceUsage\x18\x15 \x01(\v2\x1c.moby.buildkit.v1.DescriptorR\rresourceUsage\x1a@\n" +
 	"\x12FrontendAttrsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aC\n" +
@@ -2221,7 +3274,85 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\x06Pinned\x18\x02 \x01(\bR\x06Pinned\x12\x16\n" +
 	"\x06Delete\x18\x03 \x01(\bR\x06Delete\x12\x1a\n" +
 	"\bFinalize\x18\x04 \x01(\bR\bFinalize\"\x1c\n" +
-	"\x1aUpdateBuildHistoryResponse\"\xe8\x01\n" +
+	"\x1aUpdateBuildHistoryResponse\"C\n" +
+	"\x17ExplainCacheMissRequest\x12\x10\n" +
+	"\x03Ref\x18\x01 \x01(\tR\x03Ref\x12\x16\n" +
+	"\x06Vertex\x18\x02 \x01(\tR\x06Vertex\"\xe5\x01\n" +
+	"\x18ExplainCacheMissResponse\x12\x16\n" +
+	"\x06Vertex\x18\x01 \x01(\tR\x06Vertex\x12\x12\n" +
+	"\x04Name\x18\x02 \x01(\tR\x04Name\x12\x16\n" +
+	"\x06Cached\x18\x03 \x01(\bR\x06Cached\x12 \n" +
+	"\vPreviousRef\x18\x04 \x01(\tR\vPreviousRef\x12&\n" +
+	"\x0ePreviousVertex\x18\x05 \x01(\tR\x0ePreviousVertex\x12;\n" +
+	"\aReasons\x18\x06 \x03(\v2!.moby.buildkit.v1.CacheMissReasonR\aReasons\"\x8b\x01\n" +
+	"\x0fCacheMissReason\x12\x12\n" +
+	"\x04Type\x18\x01 \x01(\tR\x04Type\x12\x16\n" +
+	"\x06Vertex\x18\x02 \x01(\tR\x06Vertex\x12\x12\n" +
+	"\x04Name\x18\x03 \x01(\tR\x04Name\x12\x14\n" +
+	"\x05Field\x18\x04 \x01(\tR\x05Field\x12\x10\n" +
+	"\x03Old\x18\x05 \x01(\tR\x03Old\x12\x10\n" +
+	"\x03New\x18\x06 \x01(\tR\x03New\"(\n" +
+	"\x14ResourceUsageRequest\x12\x10\n" +
+	"\x03Ref\x18\x01 \x01(\tR\x03Ref\"Z\n" +
+	"\x15ResourceUsageResponse\x12A\n" +
+	"\bVertexes\x18\x01 \x03(\v2%.moby.buildkit.v1.VertexResourceUsageR\bVertexes\"\xdb\x03\n" +
+	"\x13VertexResourceUsage\x12\x16\n" +
+	"\x06Vertex\x18\x01 \x01(\tR\x06Vertex\x12\x12\n" +
+	"\x04Name\x18\x02 \x01(\tR\x04Name\x124\n" +
+	"\aStarted\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\aStarted\x12\x1a\n" +
+	"\bDuration\x18\x04 \x01(\x03R\bDuration\x12\x10\n" +
+	"\x03CPU\x18\x05 \x01(\x03R\x03CPU\x12\x1e\n" +
+	"\n" +
+	"PeakMemory\x18\x06 \x01(\x04R\n" +
+	"PeakMemory\x12\x1a\n" +
+	"\bOOMKills\x18\a \x01(\x04R\bOOMKills\x12\x1a\n" +
+	"\bPeakPIDs\x18\b \x01(\x04R\bPeakPIDs\x12 \n" +
+	"\vIOReadBytes\x18\t \x01(\x04R\vIOReadBytes\x12\"\n" +
+	"\fIOWriteBytes\x18\n" +
+	" \x01(\x04R\fIOWriteBytes\x12\x1e\n" +
+	"\n" +
+	"NetRxBytes\x18\v \x01(\x03R\n" +
+	"NetRxBytes\x12\x1e\n" +
+	"\n" +
+	"NetTxBytes\x18\f \x01(\x03R\n" +
+	"NetTxBytes\x12\x1a\n" +
+	"\bCPUStall\x18\r \x01(\x03R\bCPUStall\x12 \n" +
+	"\vMemoryStall\x18\x0e \x01(\x03R\vMemoryStall\x12\x18\n" +
+	"\aIOStall\x18\x0f \x01(\x03R\aIOStall\".\n" +
+	"\x14ListCacheKeysRequest\x12\x16\n" +
+	"\x06Filter\x18\x01 \x01(\tR\x06Filter\"M\n" +
+	"\x15ListCacheKeysResponse\x124\n" +
+	"\x04Keys\x18\x01 \x03(\v2 .moby.buildkit.v1.CacheKeyRecordR\x04Keys\"(\n" +
+	"\x16InspectCacheKeyRequest\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\"M\n" +
+	"\x17InspectCacheKeyResponse\x122\n" +
+	"\x03Key\x18\x01 \x01(\v2 .moby.buildkit.v1.CacheKeyRecordR\x03Key\"K\n" +
+	"\x19InvalidateCacheKeyRequest\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x1e\n" +
+	"\n" +
+	"Dependents\x18\x02 \x01(\bR\n" +
+	"Dependents\"6\n" +
+	"\x1aInvalidateCacheKeyResponse\x12\x18\n" +
+	"\aResults\x18\x01 \x03(\tR\aResults\"\xea\x01\n" +
+	"\x0eCacheKeyRecord\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x18\n" +
+	"\aSummary\x18\x02 \x01(\tR\aSummary\x12:\n" +
+	"\aResults\x18\x03 \x03(\v2 .moby.buildkit.v1.CacheKeyResultR\aResults\x124\n" +
+	"\x05Links\x18\x04 \x03(\v2\x1e.moby.buildkit.v1.CacheKeyLinkR\x05Links\x12<\n" +
+	"\tBacklinks\x18\x05 \x03(\v2\x1e.moby.buildkit.v1.CacheKeyLinkR\tBacklinks\"\xaa\x01\n" +
+	"\x0eCacheKeyResult\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\x128\n" +
+	"\tCreatedAt\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tCreatedAt\x12\x12\n" +
+	"\x04Size\x18\x03 \x01(\x03R\x04Size\x12:\n" +
+	"\n" +
+	"LastUsedAt\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"LastUsedAt\"\x80\x01\n" +
+	"\fCacheKeyLink\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x14\n" +
+	"\x05Input\x18\x02 \x01(\x03R\x05Input\x12\x16\n" +
+	"\x06Output\x18\x03 \x01(\x03R\x06Output\x12\x16\n" +
+	"\x06Digest\x18\x04 \x01(\tR\x06Digest\x12\x1a\n" +
+	"\bSelector\x18\x05 \x01(\tR\bSelector\"\xe8\x01\n" +
 	"\n" +
 	"Descriptor\x12\x1d\n" +
 	"\n" +
@@ -2249,7 +3380,8 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\x15BuildHistoryEventType\x12\v\n" +
 	"\aSTARTED\x10\x00\x12\f\n" +
 	"\bCOMPLETE\x10\x01\x12\v\n" +
-	"\aDELETED\x10\x022\x89\x06\n" +
+	"\aDELETED\x10\x022\x91\n" +
+	"\n" +
 	"\aControl\x12T\n" +
 	"\tDiskUsage\x12\".moby.buildkit.v1.DiskUsageRequest\x1a#.moby.buildkit.v1.DiskUsageResponse\x12H\n" +
 	"\x05Prune\x12\x1e.moby.buildkit.v1.PruneRequest\x1a\x1d.moby.buildkit.v1.UsageRecord0\x01\x12H\n" +
@@ -2259,7 +3391,12 @@ const file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc =
 	"\vListWorkers\x12$.moby.buildkit.v1.ListWorkersRequest\x1a%.moby.buildkit.v1.ListWorkersResponse\x12E\n" +
 	"\x04Info\x12\x1d.moby.buildkit.v1.InfoRequest\x1a\x1e.moby.buildkit.v1.InfoResponse\x12b\n" +
 	"\x12ListenBuildHistory\x12%.moby.buildkit.v1.BuildHistoryRequest\x1a#.moby.buildkit.v1.BuildHistoryEvent0\x01\x12o\n" +
-	"\x12UpdateBuildHistory\x12+.moby.buildkit.v1.UpdateBuildHistoryRequest\x1a,.moby.buildkit.v1.UpdateBuildHistoryResponseB@Z>github.com/moby/buildkit/api/services/control;moby_buildkit_v1b\x06proto3"
+	"\x12UpdateBuildHistory\x12+.moby.buildkit.v1.UpdateBuildHistoryRequest\x1a,.moby.buildkit.v1.UpdateBuildHistoryResponse\x12i\n" +
+	"\x10ExplainCacheMiss\x12).moby.buildkit.v1.ExplainCacheMissRequest\x1a*.moby.buildkit.v1.ExplainCacheMissResponse\x12`\n" +
+	"\rResourceUsage\x12&.moby.buildkit.v1.ResourceUsageRequest\x1a'.moby.buildkit.v1.ResourceUsageResponse\x12`\n" +
+	"\rListCacheKeys\x12&.moby.buildkit.v1.ListCacheKeysRequest\x1a'.moby.buildkit.v1.ListCacheKeysResponse\x12f\n" +
+	"\x0fInspectCacheKey\x12(.moby.buildkit.v1.InspectCacheKeyRequest\x1a).moby.buildkit.v1.InspectCacheKeyResponse\x12o\n" +
+	"\x12InvalidateCacheKey\x12+.moby.buildkit.v1.InvalidateCacheKeyRequest\x1a,.moby.buildkit.v1.InvalidateCacheKeyResponseB@Z>github.com/moby/buildkit/api/services/control;moby_buildkit_v1b\x06proto3"
 
 var (
 	file_github_com_moby_buildkit_api_services_control_control_proto_rawDescOnce sync.Once
@@ -2274,7 +3411,7 @@ func file_github_com_moby_buildkit_api_services_control_control_proto_rawDescGZI
 }
 
 var file_github_com_moby_buildkit_api_services_control_control_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes = make([]protoimpl.MessageInfo, 39)
+var file_github_com_moby_buildkit_api_services_control_control_proto_msgTypes = make([]protoimpl.MessageInfo, 55)
 var file_github_com_moby_buildkit_api_services_control_control_proto_goTypes = []any{
 	(BuildHistoryEventType)(0),         // 0: moby.buildkit.v1.BuildHistoryEventType
 	(*PruneRequest)(nil),               // 1: moby.buildkit.v1.PruneRequest
@@ -2301,106 +3438,145 @@ var file_github_com_moby_buildkit_api_services_control_control_proto_goTypes = [
 	(*BuildHistoryRecord)(nil),         // 22: moby.buildkit.v1.BuildHistoryRecord
 	(*UpdateBuildHistoryRequest)(nil),  // 23: moby.buildkit.v1.UpdateBuildHistoryRequest
 	(*UpdateBuildHistoryResponse)(nil), // 24: moby.buildkit.v1.UpdateBuildHistoryResponse
-	(*Descriptor)(nil),                 // 25: moby.buildkit.v1.Descriptor
-	(*BuildResultInfo)(nil),            // 26: moby.buildkit.v1.BuildResultInfo
-	(*Exporter)(nil),                   // 27: moby.buildkit.v1.Exporter
-	nil,                                // 28: moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecatedEntry
-	nil,                                // 29: moby.buildkit.v1.SolveRequest.FrontendAttrsEntry
-	nil,                                // 30: moby.buildkit.v1.SolveRequest.FrontendInputsEntry
-	nil,                                // 31: moby.buildkit.v1.CacheOptions.ExportAttrsDeprecatedEntry
-	nil,                                // 32: moby.buildkit.v1.CacheOptionsEntry.AttrsEntry
-	nil,                                // 33: moby.buildkit.v1.SolveResponse.ExporterResponseEntry
-	nil,                                // 34: moby.buildkit.v1.BuildHistoryRecord.FrontendAttrsEntry
-	nil,                                // 35: moby.buildkit.v1.BuildHistoryRecord.ExporterResponseEntry
-	nil,                                // 36: moby.buildkit.v1.BuildHistoryRecord.ResultsEntry
-	nil,                                // 37: moby.buildkit.v1.Descriptor.AnnotationsEntry
-	nil,                                // 38: moby.buildkit.v1.BuildResultInfo.ResultsEntry
-	nil,                                // 39: moby.buildkit.v1.Exporter.AttrsEntry
-	(*timestamppb.Timestamp)(nil),      // 40: google.protobuf.Timestamp
-	(*pb.Definition)(nil),              // 41: pb.Definition
-	(*pb1.Policy)(nil),                 // 42: moby.buildkit.v1.sourcepolicy.Policy
-	(*pb.ProgressGroup)(nil),           // 43: pb.ProgressGroup
-	(*pb.SourceInfo)(nil),              // 44: pb.SourceInfo
-	(*pb.Range)(nil),                   // 45: pb.Range
-	(*types.WorkerRecord)(nil),         // 46: moby.buildkit.v1.types.WorkerRecord
-	(*types.BuildkitVersion)(nil),      // 47: moby.buildkit.v1.types.BuildkitVersion
-	(*status.Status)(nil),              // 48: google.rpc.Status
+	(*ExplainCacheMissRequest)(nil),    // 25: moby.buildkit.v1.ExplainCacheMissRequest
+	(*ExplainCacheMissResponse)(nil),   // 26: moby.buildkit.v1.ExplainCacheMissResponse
+	(*CacheMissReason)(nil),            // 27: moby.buildkit.v1.CacheMissReason
+	(*ResourceUsageRequest)(nil),       // 28: moby.buildkit.v1.ResourceUsageRequest
+	(*ResourceUsageResponse)(nil),      // 29: moby.buildkit.v1.ResourceUsageResponse
+	(*VertexResourceUsage)(nil),        // 30: moby.buildkit.v1.VertexResourceUsage
+	(*ListCacheKeysRequest)(nil),       // 31: moby.buildkit.v1.ListCacheKeysRequest
+	(*ListCacheKeysResponse)(nil),      // 32: moby.buildkit.v1.ListCacheKeysResponse
+	(*InspectCacheKeyRequest)(nil),     // 33: moby.buildkit.v1.InspectCacheKeyRequest
+	(*InspectCacheKeyResponse)(nil),    // 34: moby.buildkit.v1.InspectCacheKeyResponse
+	(*InvalidateCacheKeyRequest)(nil),  // 35: moby.buildkit.v1.InvalidateCacheKeyRequest
+	(*InvalidateCacheKeyResponse)(nil), // 36: moby.buildkit.v1.InvalidateCacheKeyResponse
+	(*CacheKeyRecord)(nil),             // 37: moby.buildkit.v1.CacheKeyRecord
+	(*CacheKeyResult)(nil),             // 38: moby.buildkit.v1.CacheKeyResult
+	(*CacheKeyLink)(nil),               // 39: moby.buildkit.v1.CacheKeyLink
+	(*Descriptor)(nil),                 // 40: moby.buildkit.v1.Descriptor
+	(*BuildResultInfo)(nil),            // 41: moby.buildkit.v1.BuildResultInfo
+	(*Exporter)(nil),                   // 42: moby.buildkit.v1.Exporter
+	nil,                                // 43: moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecatedEntry
+	nil,                                // 44: moby.buildkit.v1.SolveRequest.FrontendAttrsEntry
+	nil,                                // 45: moby.buildkit.v1.SolveRequest.FrontendInputsEntry
+	nil,                                // 46: moby.buildkit.v1.SolveRequest.ProxyReplayMaterialsEntry
+	nil,                                // 47: moby.buildkit.v1.CacheOptions.ExportAttrsDeprecatedEntry
+	nil,                                // 48: moby.buildkit.v1.CacheOptionsEntry.AttrsEntry
+	nil,                                // 49: moby.buildkit.v1.SolveResponse.ExporterResponseEntry
+	nil,                                // 50: moby.buildkit.v1.BuildHistoryRecord.FrontendAttrsEntry
+	nil,                                // 51: moby.buildkit.v1.BuildHistoryRecord.ExporterResponseEntry
+	nil,                                // 52: moby.buildkit.v1.BuildHistoryRecord.ResultsEntry
+	nil,                                // 53: moby.buildkit.v1.Descriptor.AnnotationsEntry
+	nil,                                // 54: moby.buildkit.v1.BuildResultInfo.ResultsEntry
+	nil,                                // 55: moby.buildkit.v1.Exporter.AttrsEntry
+	(*timestamppb.Timestamp)(nil),      // 56: google.protobuf.Timestamp
+	(*pb.Definition)(nil),              // 57: pb.Definition
+	(*pb1.Policy)(nil),                 // 58: moby.buildkit.v1.sourcepolicy.Policy
+	(*pb.ProgressGroup)(nil),           // 59: pb.ProgressGroup
+	(*pb.SourceInfo)(nil),              // 60: pb.SourceInfo
+	(*pb.Range)(nil),                   // 61: pb.Range
+	(*types.WorkerRecord)(nil),         // 62: moby.buildkit.v1.types.WorkerRecord
+	(*types.BuildkitVersion)(nil),      // 63: moby.buildkit.v1.types.BuildkitVersion
+	(*status.Status)(nil),              // 64: google.rpc.Status
 }
 var file_github_com_moby_buildkit_api_services_control_control_proto_depIdxs = []int32{
 	4,  // 0: moby.buildkit.v1.DiskUsageResponse.record:type_name -> moby.buildkit.v1.UsageRecord
-	40, // 1: moby.buildkit.v1.UsageRecord.CreatedAt:type_name -> google.protobuf.Timestamp
-	40, // 2: moby.buildkit.v1.UsageRecord.LastUsedAt:type_name -> google.protobuf.Timestamp
-	41, // 3: moby.buildkit.v1.SolveRequest.Definition:type_name -> pb.Definition
-	28, // 4: moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecated:type_name -> moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecatedEntry
-	29, // 5: moby.buildkit.v1.SolveRequest.FrontendAttrs:type_name -> moby.buildkit.v1.SolveRequest.FrontendAttrsEntry
+	56, // 1: moby.buildkit.v1.UsageRecord.CreatedAt:type_name -> google.protobuf.Timestamp
+	56, // 2: moby.buildkit.v1.UsageRecord.LastUsedAt:type_name -> google.protobuf.Timestamp
+	57, // 3: moby.buildkit.v1.SolveRequest.Definition:type_name -> pb.Definition
+	43, // 4: moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecated:type_name -> moby.buildkit.v1.SolveRequest.ExporterAttrsDeprecatedEntry
+	44, // 5: moby.buildkit.v1.SolveRequest.FrontendAttrs:type_name -> moby.buildkit.v1.SolveRequest.FrontendAttrsEntry
 	6,  // 6: moby.buildkit.v1.SolveRequest.Cache:type_name -> moby.buildkit.v1.CacheOptions
-	30, // 7: moby.buildkit.v1.SolveRequest.FrontendInputs:type_name -> moby.buildkit.v1.SolveRequest.FrontendInputsEntry
-	42, // 8: moby.buildkit.v1.SolveRequest.SourcePolicy:type_name -> moby.buildkit.v1.sourcepolicy.Policy
-	27, // 9: moby.buildkit.v1.SolveRequest.Exporters:type_name -> moby.buildkit.v1.Exporter
-	31, // 10: moby.buildkit.v1.CacheOptions.ExportAttrsDeprecated:type_name -> moby.buildkit.v1.CacheOptions.ExportAttrsDeprecatedEntry
-	7,  // 11: moby.buildkit.v1.CacheOptions.Exports:type_name -> moby.buildkit.v1.CacheOptionsEntry
-	7,  // 12: moby.buildkit.v1.CacheOptions.Imports:type_name -> moby.buildkit.v1.CacheOptionsEntry
-	32, // 13: moby.buildkit.v1.CacheOptionsEntry.Attrs:type_name -> moby.buildkit.v1.CacheOptionsEntry.AttrsEntry
-	33, // 14: moby.buildkit.v1.SolveResponse.ExporterResponse:type_name -> moby.buildkit.v1.SolveResponse.ExporterResponseEntry
-	11, // 15: moby.buildkit.v1.StatusResponse.vertexes:type_name -> moby.buildkit.v1.Vertex
-	12, // 16: moby.buildkit.v1.StatusResponse.statuses:type_name -> moby.buildkit.v1.VertexStatus
-	13, // 17: moby.buildkit.v1.StatusResponse.logs:type_name -> moby.buildkit.v1.VertexLog
-	14, // 18: moby.buildkit.v1.StatusResponse.warnings:type_name -> moby.buildkit.v1.VertexWarning
-	40, // 19: moby.buildkit.v1.Vertex.started:type_name -> google.protobuf.Timestamp
-	40, // 20: moby.buildkit.v1.Vertex.completed:type_name -> google.protobuf.Timestamp
-	43, // 21: moby.buildkit.v1.Vertex.progressGroup:type_name -> pb.ProgressGroup
-	40, // 22: moby.buildkit.v1.VertexStatus.timestamp:type_name -> google.protobuf.Timestamp
-	40, // 23: moby.buildkit.v1.VertexStatus.started:type_name -> google.protobuf.Timestamp
-	40, // 24: moby.buildkit.v1.VertexStatus.completed:type_name -> google.protobuf.Timestamp
-	40, // 25: moby.buildkit.v1.VertexLog.timestamp:type_name -> google.protobuf.Timestamp
-	44, // 26: moby.buildkit.v1.VertexWarning.info:type_name -> pb.SourceInfo
-	45, // 27: moby.buildkit.v1.VertexWarning.ranges:type_name -> pb.Range
-	46, // 28: moby.buildkit.v1.ListWorkersResponse.record:type_name -> moby.buildkit.v1.types.WorkerRecord
-	47, // 29: moby.buildkit.v1.InfoResponse.buildkitVersion:type_name -> moby.buildkit.v1.types.BuildkitVersion
-	0,  // 30: moby.buildkit.v1.BuildHistoryEvent.type:type_name -> moby.buildkit.v1.BuildHistoryEventType
-	22, // 31: moby.buildkit.v1.BuildHistoryEvent.record:type_name -> moby.buildkit.v1.BuildHistoryRecord
-	34, // 32: moby.buildkit.v1.BuildHistoryRecord.FrontendAttrs:type_name -> moby.buildkit.v1.BuildHistoryRecord.FrontendAttrsEntry
-	27, // 33: moby.buildkit.v1.BuildHistoryRecord.Exporters:type_name -> moby.buildkit.v1.Exporter
-	48, // 34: moby.buildkit.v1.BuildHistoryRecord.error:type_name -> google.rpc.Status
-	40, // 35: moby.buildkit.v1.BuildHistoryRecord.CreatedAt:type_name -> google.protobuf.Timestamp
-	40, // 36: moby.buildkit.v1.BuildHistoryRecord.CompletedAt:type_name -> google.protobuf.Timestamp
-	25, // 37: moby.buildkit.v1.BuildHistoryRecord.logs:type_name -> moby.buildkit.v1.Descriptor
-	35, // 38: moby.buildkit.v1.BuildHistoryRecord.ExporterResponse:type_name -> moby.buildkit.v1.BuildHistoryRecord.ExporterResponseEntry
-	26, // 39: moby.buildkit.v1.BuildHistoryRecord.Result:type_name -> moby.buildkit.v1.BuildResultInfo
-	36, // 40: moby.buildkit.v1.BuildHistoryRecord.Results:type_name -> moby.buildkit.v1.BuildHistoryRecord.ResultsEntry
-	25, // 41: moby.buildkit.v1.BuildHistoryRecord.trace:type_name -> moby.buildkit.v1.Descriptor
-	25, // 42: moby.buildkit.v1.BuildHistoryRecord.externalError:type_name -> moby.buildkit.v1.Descriptor
-	37, // 43: moby.buildkit.v1.Descriptor.annotations:type_name -> moby.buildkit.v1.Descriptor.AnnotationsEntry
-	25, // 44: moby.buildkit.v1.BuildResultInfo.ResultDeprecated:type_name -> moby.buildkit.v1.Descriptor
-	25, // 45: moby.buildkit.v1.BuildResultInfo.Attestations:type_name -> moby.buildkit.v1.Descriptor
-	38, // 46: moby.buildkit.v1.BuildResultInfo.Results:type_name -> moby.buildkit.v1.BuildResultInfo.ResultsEntry
-	39, // 47: moby.buildkit.v1.Exporter.Attrs:type_name -> moby.buildkit.v1.Exporter.AttrsEntry
-	41, // 48: moby.buildkit.v1.SolveRequest.FrontendInputsEntry.value:type_name -> pb.Definition
-	26, // 49: moby.buildkit.v1.BuildHistoryRecord.ResultsEntry.value:type_name -> moby.buildkit.v1.BuildResultInfo
-	25, // 50: moby.buildkit.v1.BuildResultInfo.ResultsEntry.value:type_name -> moby.buildkit.v1.Descriptor
-	2,  // 51: moby.buildkit.v1.Control.DiskUsage:input_type -> moby.buildkit.v1.DiskUsageRequest
-	1,  // 52: moby.buildkit.v1.Control.Prune:input_type -> moby.buildkit.v1.PruneRequest
-	5,  // 53: moby.buildkit.v1.Control.Solve:input_type -> moby.buildkit.v1.SolveRequest
-	9,  // 54: moby.buildkit.v1.Control.Status:input_type -> moby.buildkit.v1.StatusRequest
-	15, // 55: moby.buildkit.v1.Control.Session:input_type -> moby.buildkit.v1.BytesMessage
-	16, // 56: moby.buildkit.v1.Control.ListWorkers:input_type -> moby.buildkit.v1.ListWorkersRequest
-	18, // 57: moby.buildkit.v1.Control.Info:input_type -> moby.buildkit.v1.InfoRequest
-	20, // 58: moby.buildkit.v1.Control.ListenBuildHistory:input_type -> moby.buildkit.v1.BuildHistoryRequest
-	23, // 59: moby.buildkit.v1.Control.UpdateBuildHistory:input_type -> moby.buildkit.v1.UpdateBuildHistoryRequest
-	3,  // 60: moby.buildkit.v1.Control.DiskUsage:output_type -> moby.buildkit.v1.DiskUsageResponse
-	4,  // 61: moby.buildkit.v1.Control.Prune:output_type -> moby.buildkit.v1.UsageRecord
-	8,  // 62: moby.buildkit.v1.Control.Solve:output_type -> moby.buildkit.v1.SolveResponse
-	10, // 63: moby.buildkit.v1.Control.Status:output_type -> moby.buildkit.v1.StatusResponse
-	15, // 64: moby.buildkit.v1.Control.Session:output_type -> moby.buildkit.v1.BytesMessage
-	17, // 65: moby.buildkit.v1.Control.ListWorkers:output_type -> moby.buildkit.v1.ListWorkersResponse
-	19, // 66: moby.buildkit.v1.Control.Info:output_type -> moby.buildkit.v1.InfoResponse
-	21, // 67: moby.buildkit.v1.Control.ListenBuildHistory:output_type -> moby.buildkit.v1.BuildHistoryEvent
-	24, // 68: moby.buildkit.v1.Control.UpdateBuildHistory:output_type -> moby.buildkit.v1.UpdateBuildHistoryResponse
-	60, // [60:69] is the sub-list for method output_type
-	51, // [51:60] is the sub-list for method input_type
-	51, // [51:51] is the sub-list for extension type_name
-	51, // [51:51] is the sub-list for extension extendee
-	0,  // [0:51] is the sub-list for field type_name
+	45, // 7: moby.buildkit.v1.SolveRequest.FrontendInputs:type_name -> moby.buildkit.v1.SolveRequest.FrontendInputsEntry
+	58, // 8: moby.buildkit.v1.SolveRequest.SourcePolicy:type_name -> moby.buildkit.v1.sourcepolicy.Policy
+	42, // 9: moby.buildkit.v1.SolveRequest.Exporters:type_name -> moby.buildkit.v1.Exporter
+	46, // 10: moby.buildkit.v1.SolveRequest.ProxyReplayMaterials:type_name -> moby.buildkit.v1.SolveRequest.ProxyReplayMaterialsEntry
+	47, // 11: moby.buildkit.v1.CacheOptions.ExportAttrsDeprecated:type_name -> moby.buildkit.v1.CacheOptions.ExportAttrsDeprecatedEntry
+	7,  // 12: moby.buildkit.v1.CacheOptions.Exports:type_name -> moby.buildkit.v1.CacheOptionsEntry
+	7,  // 13: moby.buildkit.v1.CacheOptions.Imports:type_name -> moby.buildkit.v1.CacheOptionsEntry
+	48, // 14: moby.buildkit.v1.CacheOptionsEntry.Attrs:type_name -> moby.buildkit.v1.CacheOptionsEntry.AttrsEntry
+	49, // 15: moby.buildkit.v1.SolveResponse.ExporterResponse:type_name -> moby.buildkit.v1.SolveResponse.ExporterResponseEntry
+	11, // 16: moby.buildkit.v1.StatusResponse.vertexes:type_name -> moby.buildkit.v1.Vertex
+	12, // 17: moby.buildkit.v1.StatusResponse.statuses:type_name -> moby.buildkit.v1.VertexStatus
+	13, // 18: moby.buildkit.v1.StatusResponse.logs:type_name -> moby.buildkit.v1.VertexLog
+	14, // 19: moby.buildkit.v1.StatusResponse.warnings:type_name -> moby.buildkit.v1.VertexWarning
+	56, // 20: moby.buildkit.v1.Vertex.started:type_name -> google.protobuf.Timestamp
+	56, // 21: moby.buildkit.v1.Vertex.completed:type_name -> google.protobuf.Timestamp
+	59, // 22: moby.buildkit.v1.Vertex.progressGroup:type_name -> pb.ProgressGroup
+	56, // 23: moby.buildkit.v1.VertexStatus.timestamp:type_name -> google.protobuf.Timestamp
+	56, // 24: moby.buildkit.v1.VertexStatus.started:type_name -> google.protobuf.Timestamp
+	56, // 25: moby.buildkit.v1.VertexStatus.completed:type_name -> google.protobuf.Timestamp
+	56, // 26: moby.buildkit.v1.VertexLog.timestamp:type_name -> google.protobuf.Timestamp
+	60, // 27: moby.buildkit.v1.VertexWarning.info:type_name -> pb.SourceInfo
+	61, // 28: moby.buildkit.v1.VertexWarning.ranges:type_name -> pb.Range
+	62, // 29: moby.buildkit.v1.ListWorkersResponse.record:type_name -> moby.buildkit.v1.types.WorkerRecord
+	63, // 30: moby.buildkit.v1.InfoResponse.buildkitVersion:type_name -> moby.buildkit.v1.types.BuildkitVersion
+	0,  // 31: moby.buildkit.v1.BuildHistoryEvent.type:type_name -> moby.buildkit.v1.BuildHistoryEventType
+	22, // 32: moby.buildkit.v1.BuildHistoryEvent.record:type_name -> moby.buildkit.v1.BuildHistoryRecord
+	50, // 33: moby.buildkit.v1.BuildHistoryRecord.FrontendAttrs:type_name -> moby.buildkit.v1.BuildHistoryRecord.FrontendAttrsEntry
+	42, // 34: moby.buildkit.v1.BuildHistoryRecord.Exporters:type_name -> moby.buildkit.v1.Exporter
+	64, // 35: moby.buildkit.v1.BuildHistoryRecord.error:type_name -> google.rpc.Status
+	56, // 36: moby.buildkit.v1.BuildHistoryRecord.CreatedAt:type_name -> google.protobuf.Timestamp
+	56, // 37: moby.buildkit.v1.BuildHistoryRecord.CompletedAt:type_name -> google.protobuf.Timestamp
+	40, // 38: moby.buildkit.v1.BuildHistoryRecord.logs:type_name -> moby.buildkit.v1.Descriptor
+	51, // 39: moby.buildkit.v1.BuildHistoryRecord.ExporterResponse:type_name -> moby.buildkit.v1.BuildHistoryRecord.ExporterResponseEntry
+	41, // 40: moby.buildkit.v1.BuildHistoryRecord.Result:type_name -> moby.buildkit.v1.BuildResultInfo
+	52, // 41: moby.buildkit.v1.BuildHistoryRecord.Results:type_name -> moby.buildkit.v1.BuildHistoryRecord.ResultsEntry
+	40, // 42: moby.buildkit.v1.BuildHistoryRecord.trace:type_name -> moby.buildkit.v1.Descriptor
+	40, // 43: moby.buildkit.v1.BuildHistoryRecord.externalError:type_name -> moby.buildkit.v1.Descriptor
+	40, // 44: moby.buildkit.v1.BuildHistoryRecord.cacheInfo:type_name -> moby.buildkit.v1.Descriptor
+	40, // 45: moby.buildkit.v1.BuildHistoryRecord.resourceUsage:type_name -> moby.buildkit.v1.Descriptor
+	27, // 46: moby.buildkit.v1.ExplainCacheMissResponse.Reasons:type_name -> moby.buildkit.v1.CacheMissReason
+	30, // 47: moby.buildkit.v1.ResourceUsageResponse.Vertexes:type_name -> moby.buildkit.v1.VertexResourceUsage
+	56, // 48: moby.buildkit.v1.VertexResourceUsage.Started:type_name -> google.protobuf.Timestamp
+	37, // 49: moby.buildkit.v1.ListCacheKeysResponse.Keys:type_name -> moby.buildkit.v1.CacheKeyRecord
+	37, // 50: moby.buildkit.v1.InspectCacheKeyResponse.Key:type_name -> moby.buildkit.v1.CacheKeyRecord
+	38, // 51: moby.buildkit.v1.CacheKeyRecord.Results:type_name -> moby.buildkit.v1.CacheKeyResult
+	39, // 52: moby.buildkit.v1.CacheKeyRecord.Links:type_name -> moby.buildkit.v1.CacheKeyLink
+	39, // 53: moby.buildkit.v1.CacheKeyRecord.Backlinks:type_name -> moby.buildkit.v1.CacheKeyLink
+	56, // 54: moby.buildkit.v1.CacheKeyResult.CreatedAt:type_name -> google.protobuf.Timestamp
+	56, // 55: moby.buildkit.v1.CacheKeyResult.LastUsedAt:type_name -> google.protobuf.Timestamp
+	53, // 56: moby.buildkit.v1.Descriptor.annotations:type_name -> moby.buildkit.v1.Descriptor.AnnotationsEntry
+	40, // 57: moby.buildkit.v1.BuildResultInfo.ResultDeprecated:type_name -> moby.buildkit.v1.Descriptor
+	40, // 58: moby.buildkit.v1.BuildResultInfo.Attestations:type_name -> moby.buildkit.v1.Descriptor
+	54, // 59: moby.buildkit.v1.BuildResultInfo.Results:type_name -> moby.buildkit.v1.BuildResultInfo.ResultsEntry
+	55, // 60: moby.buildkit.v1.Exporter.Attrs:type_name -> moby.buildkit.v1.Exporter.AttrsEntry
+	57, // 61: moby.buildkit.v1.SolveRequest.FrontendInputsEntry.value:type_name -> pb.Definition
+	41, // 62: moby.buildkit.v1.BuildHistoryRecord.ResultsEntry.value:type_name -> moby.buildkit.v1.BuildResultInfo
+	40, // 63: moby.buildkit.v1.BuildResultInfo.ResultsEntry.value:type_name -> moby.buildkit.v1.Descriptor
+	2,  // 64: moby.buildkit.v1.Control.DiskUsage:input_type -> moby.buildkit.v1.DiskUsageRequest
+	1,  // 65: moby.buildkit.v1.Control.Prune:input_type -> moby.buildkit.v1.PruneRequest
+	5,  // 66: moby.buildkit.v1.Control.Solve:input_type -> moby.buildkit.v1.SolveRequest
+	9,  // 67: moby.buildkit.v1.Control.Status:input_type -> moby.buildkit.v1.StatusRequest
+	15, // 68: moby.buildkit.v1.Control.Session:input_type -> moby.buildkit.v1.BytesMessage
+	16, // 69: moby.buildkit.v1.Control.ListWorkers:input_type -> moby.buildkit.v1.ListWorkersRequest
+	18, // 70: moby.buildkit.v1.Control.Info:input_type -> moby.buildkit.v1.InfoRequest
+	20, // 71: moby.buildkit.v1.Control.ListenBuildHistory:input_type -> moby.buildkit.v1.BuildHistoryRequest
+	23, // 72: moby.buildkit.v1.Control.UpdateBuildHistory:input_type -> moby.buildkit.v1.UpdateBuildHistoryRequest
+	25, // 73: moby.buildkit.v1.Control.ExplainCacheMiss:input_type -> moby.buildkit.v1.ExplainCacheMissRequest
+	28, // 74: moby.buildkit.v1.Control.ResourceUsage:input_type -> moby.buildkit.v1.ResourceUsageRequest
+	31, // 75: moby.buildkit.v1.Control.ListCacheKeys:input_type -> moby.buildkit.v1.ListCacheKeysRequest
+	33, // 76: moby.buildkit.v1.Control.InspectCacheKey:input_type -> moby.buildkit.v1.InspectCacheKeyRequest
+	35, // 77: moby.buildkit.v1.Control.InvalidateCacheKey:input_type -> moby.buildkit.v1.InvalidateCacheKeyRequest
+	3,  // 78: moby.buildkit.v1.Control.DiskUsage:output_type -> moby.buildkit.v1.DiskUsageResponse
+	4,  // 79: moby.buildkit.v1.Control.Prune:output_type -> moby.buildkit.v1.UsageRecord
+	8,  // 80: moby.buildkit.v1.Control.Solve:output_type -> moby.buildkit.v1.SolveResponse
+	10, // 81: moby.buildkit.v1.Control.Status:output_type -> moby.buildkit.v1.StatusResponse
+	15, // 82: moby.buildkit.v1.Control.Session:output_type -> moby.buildkit.v1.BytesMessage
+	17, // 83: moby.buildkit.v1.Control.ListWorkers:output_type -> moby.buildkit.v1.ListWorkersResponse
+	19, // 84: moby.buildkit.v1.Control.Info:output_type -> moby.buildkit.v1.InfoResponse
+	21, // 85: moby.buildkit.v1.Control.ListenBuildHistory:output_type -> moby.buildkit.v1.BuildHistoryEvent
+	24, // 86: moby.buildkit.v1.Control.UpdateBuildHistory:output_type -> moby.buildkit.v1.UpdateBuildHistoryResponse
+	26, // 87: moby.buildkit.v1.Control.ExplainCacheMiss:output_type -> moby.buildkit.v1.ExplainCacheMissResponse
+	29, // 88: moby.buildkit.v1.Control.ResourceUsage:output_type -> moby.buildkit.v1.ResourceUsageResponse
+	32, // 89: moby.buildkit.v1.Control.ListCacheKeys:output_type -> moby.buildkit.v1.ListCacheKeysResponse
+	34, // 90: moby.buildkit.v1.Control.InspectCacheKey:output_type -> moby.buildkit.v1.InspectCacheKeyResponse
+	36, // 91: moby.buildkit.v1.Control.InvalidateCacheKey:output_type -> moby.buildkit.v1.InvalidateCacheKeyResponse
+	78, // [78:92] is the sub-list for method output_type
+	64, // [64:78] is the sub-list for method input_type
+	64, // [64:64] is the sub-list for extension type_name
+	64, // [64:64] is the sub-list for extension extendee
+	0,  // [0:64] is the sub-list for field type_name
 }
 
 func init() { file_github_com_moby_buildkit_api_services_control_control_proto_init() }
@@ -2414,7 +3590,7 @@ func file_github_com_moby_buildkit_api_services_control_control_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc), len(file_github_com_moby_buildkit_api_services_control_control_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   39,
+			NumMessages:   55,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
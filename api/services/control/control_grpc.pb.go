@@ -28,6 +28,11 @@ const (
 	Control_Info_FullMethodName               = "/moby.buildkit.v1.Control/Info"
 	Control_ListenBuildHistory_FullMethodName = "/moby.buildkit.v1.Control/ListenBuildHistory"
 	Control_UpdateBuildHistory_FullMethodName = "/moby.buildkit.v1.Control/UpdateBuildHistory"
+	Control_ExplainCacheMiss_FullMethodName   = "/moby.buildkit.v1.Control/ExplainCacheMiss"
+	Control_ResourceUsage_FullMethodName      = "/moby.buildkit.v1.Control/ResourceUsage"
+	Control_ListCacheKeys_FullMethodName      = "/moby.buildkit.v1.Control/ListCacheKeys"
+	Control_InspectCacheKey_FullMethodName    = "/moby.buildkit.v1.Control/InspectCacheKey"
+	Control_InvalidateCacheKey_FullMethodName = "/moby.buildkit.v1.Control/InvalidateCacheKey"
 )
 
 // ControlClient is the client API for Control service.
@@ -43,6 +48,11 @@ type ControlClient interface {
 	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
 	ListenBuildHistory(ctx context.Context, in *BuildHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BuildHistoryEvent], error)
 	UpdateBuildHistory(ctx context.Context, in *UpdateBuildHistoryRequest, opts ...grpc.CallOption) (*UpdateBuildHistoryResponse, error)
+	ExplainCacheMiss(ctx context.Context, in *ExplainCacheMissRequest, opts ...grpc.CallOption) (*ExplainCacheMissResponse, error)
+	ResourceUsage(ctx context.Context, in *ResourceUsageRequest, opts ...grpc.CallOption) (*ResourceUsageResponse, error)
+	ListCacheKeys(ctx context.Context, in *ListCacheKeysRequest, opts ...grpc.CallOption) (*ListCacheKeysResponse, error)
+	InspectCacheKey(ctx context.Context, in *InspectCacheKeyRequest, opts ...grpc.CallOption) (*InspectCacheKeyResponse, error)
+	InvalidateCacheKey(ctx context.Context, in *InvalidateCacheKeyRequest, opts ...grpc.CallOption) (*InvalidateCacheKeyResponse, error)
 }
 
 type controlClient struct {
@@ -173,6 +183,56 @@ func (c *controlClient) UpdateBuildHistory(ctx context.Context, in *UpdateBuildH
 	return out, nil
 }
 
+func (c *controlClient) ExplainCacheMiss(ctx context.Context, in *ExplainCacheMissRequest, opts ...grpc.CallOption) (*ExplainCacheMissResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExplainCacheMissResponse)
+	err := c.cc.Invoke(ctx, Control_ExplainCacheMiss_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ResourceUsage(ctx context.Context, in *ResourceUsageRequest, opts ...grpc.CallOption) (*ResourceUsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResourceUsageResponse)
+	err := c.cc.Invoke(ctx, Control_ResourceUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ListCacheKeys(ctx context.Context, in *ListCacheKeysRequest, opts ...grpc.CallOption) (*ListCacheKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCacheKeysResponse)
+	err := c.cc.Invoke(ctx, Control_ListCacheKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) InspectCacheKey(ctx context.Context, in *InspectCacheKeyRequest, opts ...grpc.CallOption) (*InspectCacheKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InspectCacheKeyResponse)
+	err := c.cc.Invoke(ctx, Control_InspectCacheKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) InvalidateCacheKey(ctx context.Context, in *InvalidateCacheKeyRequest, opts ...grpc.CallOption) (*InvalidateCacheKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InvalidateCacheKeyResponse)
+	err := c.cc.Invoke(ctx, Control_InvalidateCacheKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ControlServer is the server API for Control service.
 // All implementations should embed UnimplementedControlServer
 // for forward compatibility.
@@ -186,6 +246,11 @@ type ControlServer interface {
 	Info(context.Context, *InfoRequest) (*InfoResponse, error)
 	ListenBuildHistory(*BuildHistoryRequest, grpc.ServerStreamingServer[BuildHistoryEvent]) error
 	UpdateBuildHistory(context.Context, *UpdateBuildHistoryRequest) (*UpdateBuildHistoryResponse, error)
+	ExplainCacheMiss(context.Context, *ExplainCacheMissRequest) (*ExplainCacheMissResponse, error)
+	ResourceUsage(context.Context, *ResourceUsageRequest) (*ResourceUsageResponse, error)
+	ListCacheKeys(context.Context, *ListCacheKeysRequest) (*ListCacheKeysResponse, error)
+	InspectCacheKey(context.Context, *InspectCacheKeyRequest) (*InspectCacheKeyResponse, error)
+	InvalidateCacheKey(context.Context, *InvalidateCacheKeyRequest) (*InvalidateCacheKeyResponse, error)
 }
 
 // UnimplementedControlServer should be embedded to have
@@ -222,6 +287,21 @@ func (UnimplementedControlServer) ListenBuildHistory(*BuildHistoryRequest, grpc.
 func (UnimplementedControlServer) UpdateBuildHistory(context.Context, *UpdateBuildHistoryRequest) (*UpdateBuildHistoryResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method UpdateBuildHistory not implemented")
 }
+func (UnimplementedControlServer) ExplainCacheMiss(context.Context, *ExplainCacheMissRequest) (*ExplainCacheMissResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExplainCacheMiss not implemented")
+}
+func (UnimplementedControlServer) ResourceUsage(context.Context, *ResourceUsageRequest) (*ResourceUsageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ResourceUsage not implemented")
+}
+func (UnimplementedControlServer) ListCacheKeys(context.Context, *ListCacheKeysRequest) (*ListCacheKeysResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListCacheKeys not implemented")
+}
+func (UnimplementedControlServer) InspectCacheKey(context.Context, *InspectCacheKeyRequest) (*InspectCacheKeyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InspectCacheKey not implemented")
+}
+func (UnimplementedControlServer) InvalidateCacheKey(context.Context, *InvalidateCacheKeyRequest) (*InvalidateCacheKeyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InvalidateCacheKey not implemented")
+}
 func (UnimplementedControlServer) testEmbeddedByValue() {}
 
 // UnsafeControlServer may be embedded to opt out of forward compatibility for this service.
@@ -372,6 +452,96 @@ func _Control_UpdateBuildHistory_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Control_ExplainCacheMiss_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainCacheMissRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ExplainCacheMiss(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ExplainCacheMiss_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ExplainCacheMiss(ctx, req.(*ExplainCacheMissRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ResourceUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResourceUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ResourceUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ResourceUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ResourceUsage(ctx, req.(*ResourceUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ListCacheKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCacheKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ListCacheKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ListCacheKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ListCacheKeys(ctx, req.(*ListCacheKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_InspectCacheKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectCacheKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).InspectCacheKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_InspectCacheKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).InspectCacheKey(ctx, req.(*InspectCacheKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_InvalidateCacheKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvalidateCacheKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).InvalidateCacheKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_InvalidateCacheKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).InvalidateCacheKey(ctx, req.(*InvalidateCacheKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Control_ServiceDesc is the grpc.ServiceDesc for Control service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -399,6 +569,26 @@ var Control_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateBuildHistory",
 			Handler:    _Control_UpdateBuildHistory_Handler,
 		},
+		{
+			MethodName: "ExplainCacheMiss",
+			Handler:    _Control_ExplainCacheMiss_Handler,
+		},
+		{
+			MethodName: "ResourceUsage",
+			Handler:    _Control_ResourceUsage_Handler,
+		},
+		{
+			MethodName: "ListCacheKeys",
+			Handler:    _Control_ListCacheKeys_Handler,
+		},
+		{
+			MethodName: "InspectCacheKey",
+			Handler:    _Control_InspectCacheKey_Handler,
+		},
+		{
+			MethodName: "InvalidateCacheKey",
+			Handler:    _Control_InvalidateCacheKey_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -839,9 +839,18 @@ func (cm *cacheManager) createMergeRef(ctx context.Context, parents parentRefs,
 }
 
 func (cm *cacheManager) Diff(ctx context.Context, lower, upper ImmutableRef, pg progress.Controller, opts ...RefOption) (ir ImmutableRef, rerr error) {
-	if lower == nil {
+	var squash bool
+	for _, o := range opts {
+		if o == SquashDiff {
+			squash = true
+		}
+	}
+	if lower == nil && !squash {
 		return nil, errors.New("lower ref for diff cannot be nil")
 	}
+	if lower == nil && upper == nil {
+		return nil, errors.New("upper ref for diff from scratch cannot be nil")
+	}
 
 	var dps diffParents
 	parents := parentRefs{diffParents: &dps}
@@ -882,7 +891,7 @@ func (cm *cacheManager) Diff(ctx context.Context, lower, upper ImmutableRef, pg
 	// running the differ directly on lower and upper, but this is chosen as a default
 	// behavior in order to maximize layer re-use in the default case. We may add an
 	// option for controlling this behavior in the future if it's needed.
-	if dps.upper != nil {
+	if dps.upper != nil && !squash {
 		lowerLayers := dps.lower.layerChain()
 		upperLayers := dps.upper.layerChain()
 		var lowerIsAncestor bool
@@ -941,8 +950,10 @@ func (cm *cacheManager) Diff(ctx context.Context, lower, upper ImmutableRef, pg
 
 func (cm *cacheManager) createDiffRef(ctx context.Context, parents parentRefs, dhs DescHandlers, pg progress.Controller, opts ...RefOption) (ir *immutableRef, rerr error) {
 	dps := parents.diffParents
-	if err := dps.lower.Finalize(ctx); err != nil {
-		return nil, errors.Wrapf(err, "failed to finalize lower parent during diff")
+	if dps.lower != nil {
+		if err := dps.lower.Finalize(ctx); err != nil {
+			return nil, errors.Wrapf(err, "failed to finalize lower parent during diff")
+		}
 	}
 	if dps.upper != nil {
 		if err := dps.upper.Finalize(ctx); err != nil {
@@ -1529,6 +1540,13 @@ type noUpdateLastUsed struct{}
 
 var NoUpdateLastUsed noUpdateLastUsed
 
+type squashDiff struct{}
+
+// SquashDiff makes Diff return a single layer with all the changes between
+// lower and upper, even if lower is an ancestor of upper. With SquashDiff,
+// lower can be nil to squash all the layers of upper.
+var SquashDiff squashDiff
+
 func CachePolicyRetain(m *cacheMetadata) error {
 	return m.SetCachePolicyRetain()
 }
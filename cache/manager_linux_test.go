@@ -0,0 +1,124 @@
+//go:build linux
+
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/containerd/v2/plugins/snapshots/native"
+	"github.com/moby/buildkit/cache/config"
+	"github.com/moby/buildkit/snapshot"
+	"github.com/moby/buildkit/util/compression"
+	"github.com/moby/buildkit/util/leaseutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefOwnershipWithoutIdentityMapping(t *testing.T) {
+	t.Parallel()
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root")
+	}
+
+	ctx := namespaces.WithNamespace(context.Background(), "buildkit-test")
+
+	tmpdir := t.TempDir()
+
+	snapshotter, err := native.NewSnapshotter(filepath.Join(tmpdir, "snapshots"))
+	require.NoError(t, err)
+
+	co, cleanup, err := newCacheManager(ctx, t, cmOpt{
+		snapshotter:     snapshotter,
+		snapshotterName: "native",
+	})
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	cm := co.manager
+
+	ctx, done, err := leaseutil.WithLease(ctx, co.lm, leaseutil.MakeTemporary)
+	require.NoError(t, err)
+	defer done(context.WithoutCancel(ctx))
+
+	// workers that remap execs to distinct ID ranges with idmapped mounts
+	// store the files of refs with the IDs they have inside the containers
+	require.Nil(t, cm.IdentityMapping())
+
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0o644, Uid: 1000, Gid: 1001, Size: 3}))
+	_, err = tw.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	dgst := digest.FromBytes(buf.Bytes())
+	desc := ocispecs.Descriptor{
+		Digest:    dgst,
+		MediaType: ocispecs.MediaTypeImageLayer,
+		Size:      int64(buf.Len()),
+		Annotations: map[string]string{
+			labels.LabelUncompressed: dgst.String(),
+		},
+	}
+	require.NoError(t, content.WriteBlob(ctx, co.cs, "test-ownership", bytes.NewReader(buf.Bytes()), desc))
+
+	ref, err := cm.GetByBlob(ctx, desc, nil)
+	require.NoError(t, err)
+	defer ref.Release(context.WithoutCancel(ctx))
+	require.Nil(t, ref.IdentityMapping())
+
+	mutRef, err := cm.New(ctx, ref, nil)
+	require.NoError(t, err)
+	mntable, err := mutRef.Mount(ctx, false, nil)
+	require.NoError(t, err)
+	require.Nil(t, mntable.IdentityMapping())
+	lm := snapshot.LocalMounter(mntable)
+	dir, err := lm.Mount()
+	require.NoError(t, err)
+
+	fi, err := os.Lstat(filepath.Join(dir, "foo"))
+	require.NoError(t, err)
+	st := fi.Sys().(*syscall.Stat_t)
+	require.Equal(t, [2]uint32{1000, 1001}, [2]uint32{st.Uid, st.Gid})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar"), []byte("bar"), 0o644))
+	require.NoError(t, os.Lchown(filepath.Join(dir, "bar"), 2000, 2001))
+	require.NoError(t, lm.Unmount())
+
+	child, err := mutRef.Commit(ctx)
+	require.NoError(t, err)
+	defer child.Release(context.WithoutCancel(ctx))
+
+	remotes, err := child.GetRemotes(ctx, true, config.RefConfig{Compression: compression.New(compression.Uncompressed).SetForce(true)}, false, nil)
+	require.NoError(t, err)
+	require.Len(t, remotes, 1)
+	require.Len(t, remotes[0].Descriptors, 2)
+
+	owners := map[string][2]int{}
+	for _, desc := range remotes[0].Descriptors {
+		ra, err := co.cs.ReaderAt(ctx, desc)
+		require.NoError(t, err)
+		tr := tar.NewReader(content.NewReader(ra))
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			owners[hdr.Name] = [2]int{hdr.Uid, hdr.Gid}
+		}
+		require.NoError(t, ra.Close())
+	}
+	require.Equal(t, [2]int{1000, 1001}, owners["foo"])
+	require.Equal(t, [2]int{2000, 2001}, owners["bar"])
+}
@@ -1549,7 +1549,7 @@ func TestConversion(t *testing.T) {
 
 	// Tests all combination of the conversions from type i to type j preserve
 	// the uncompressed digest.
-	allCompression := []compression.Type{compression.Uncompressed, compression.Gzip, compression.EStargz, compression.Zstd}
+	allCompression := []compression.Type{compression.Uncompressed, compression.Gzip, compression.EStargz, compression.Zstd, compression.ZstdChunked}
 	eg, egctx := errgroup.WithContext(ctx)
 	for _, orgDesc := range []ocispecs.Descriptor{orgDescGo, orgDescSys} {
 		for _, i := range allCompression {
@@ -2250,6 +2250,186 @@ func TestDiffOp(t *testing.T) {
 	checkDiskUsage(ctx, t, cm, 0, 0)
 }
 
+func TestSquashDiff(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "freebsd" {
+		t.Skipf("Depends on unimplemented diff-op support on %s", runtime.GOOS)
+	}
+	t.Parallel()
+
+	ctx := namespaces.WithNamespace(context.Background(), "buildkit-test")
+
+	tmpdir := t.TempDir()
+
+	snapshotter, err := native.NewSnapshotter(filepath.Join(tmpdir, "snapshots"))
+	require.NoError(t, err)
+
+	co, cleanup, err := newCacheManager(ctx, t, cmOpt{
+		snapshotter:     snapshotter,
+		snapshotterName: "native",
+	})
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	cm := co.manager
+
+	var chain []ImmutableRef
+	var parent ImmutableRef
+	for i := range 4 {
+		active, err := cm.New(ctx, parent, nil)
+		require.NoError(t, err)
+		m, err := active.Mount(ctx, false, nil)
+		require.NoError(t, err)
+		lm := snapshot.LocalMounter(m)
+		target, err := lm.Mount()
+		require.NoError(t, err)
+		require.NoError(t, fstest.Apply(
+			fstest.CreateFile(strconv.Itoa(i), []byte(strconv.Itoa(i)), 0644),
+		).Apply(target))
+		require.NoError(t, lm.Unmount())
+		parent, err = active.Commit(ctx)
+		require.NoError(t, err)
+		chain = append(chain, parent)
+	}
+
+	// without SquashDiff, the diff re-uses the layers between lower and upper
+	diff, err := cm.Diff(ctx, chain[0], chain[3], nil)
+	require.NoError(t, err)
+	layers := diff.LayerChain()
+	require.Len(t, layers, 3)
+	require.NoError(t, layers.Release(ctx))
+	require.NoError(t, diff.Release(ctx))
+
+	squashed, err := cm.Diff(ctx, chain[0], chain[3], nil, SquashDiff)
+	require.NoError(t, err)
+	layers = squashed.LayerChain()
+	require.Len(t, layers, 1)
+	require.Equal(t, squashed.ID(), layers[0].ID())
+	require.NoError(t, layers.Release(ctx))
+	checkMount := func(ref ImmutableRef, files ...int) {
+		m, err := ref.Mount(ctx, true, nil)
+		require.NoError(t, err)
+		lm := snapshot.LocalMounter(m)
+		target, err := lm.Mount()
+		require.NoError(t, err)
+		defer lm.Unmount()
+		var appliers []fstest.Applier
+		for _, i := range files {
+			appliers = append(appliers, fstest.CreateFile(strconv.Itoa(i), []byte(strconv.Itoa(i)), 0644))
+		}
+		require.NoError(t, fstest.CheckDirectoryEqualWithApplier(target, fstest.Apply(appliers...)))
+	}
+	checkMount(squashed, 1, 2, 3)
+	require.NoError(t, squashed.Release(ctx))
+
+	// with SquashDiff, lower can be nil to squash all layers
+	_, err = cm.Diff(ctx, nil, chain[3], nil)
+	require.Error(t, err)
+	squashed, err = cm.Diff(ctx, nil, chain[3], nil, SquashDiff)
+	require.NoError(t, err)
+	layers = squashed.LayerChain()
+	require.Len(t, layers, 1)
+	require.NoError(t, layers.Release(ctx))
+	checkMount(squashed, 0, 1, 2, 3)
+	require.NoError(t, squashed.Release(ctx))
+
+	for _, ref := range chain {
+		require.NoError(t, ref.Release(ctx))
+	}
+}
+
+func TestSquashLayers(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "freebsd" {
+		t.Skipf("Depends on unimplemented diff-op support on %s", runtime.GOOS)
+	}
+	t.Parallel()
+
+	ctx := namespaces.WithNamespace(context.Background(), "buildkit-test")
+
+	tmpdir := t.TempDir()
+
+	snapshotter, err := native.NewSnapshotter(filepath.Join(tmpdir, "snapshots"))
+	require.NoError(t, err)
+
+	co, cleanup, err := newCacheManager(ctx, t, cmOpt{
+		snapshotter:     snapshotter,
+		snapshotterName: "native",
+	})
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	cm := co.manager
+
+	newLayer := func(parent ImmutableRef, i int) ImmutableRef {
+		active, err := cm.New(ctx, parent, nil)
+		require.NoError(t, err)
+		m, err := active.Mount(ctx, false, nil)
+		require.NoError(t, err)
+		lm := snapshot.LocalMounter(m)
+		target, err := lm.Mount()
+		require.NoError(t, err)
+		require.NoError(t, fstest.Apply(
+			fstest.CreateFile(strconv.Itoa(i), []byte(strconv.Itoa(i)), 0644),
+		).Apply(target))
+		require.NoError(t, lm.Unmount())
+		ref, err := active.Commit(ctx)
+		require.NoError(t, err)
+		return ref
+	}
+	checkSquash := func(ref ImmutableRef, boundaries []int, numLayers int, files ...int) {
+		squashed, err := SquashLayers(ctx, ref, boundaries, nil)
+		require.NoError(t, err)
+		defer squashed.Release(ctx)
+
+		layers := squashed.LayerChain()
+		require.Len(t, layers, numLayers)
+		require.NoError(t, layers.Release(ctx))
+
+		m, err := squashed.Mount(ctx, true, nil)
+		require.NoError(t, err)
+		lm := snapshot.LocalMounter(m)
+		target, err := lm.Mount()
+		require.NoError(t, err)
+		defer lm.Unmount()
+		var appliers []fstest.Applier
+		for _, i := range files {
+			appliers = append(appliers, fstest.CreateFile(strconv.Itoa(i), []byte(strconv.Itoa(i)), 0644))
+		}
+		require.NoError(t, fstest.CheckDirectoryEqualWithApplier(target, fstest.Apply(appliers...)))
+	}
+
+	var refs []ImmutableRef
+	var parent ImmutableRef
+	for i := range 5 {
+		parent = newLayer(parent, i)
+		refs = append(refs, parent)
+	}
+	top := refs[4]
+
+	checkSquash(top, nil, 1, 0, 1, 2, 3, 4)
+	checkSquash(top, []int{2}, 2, 0, 1, 2, 3, 4)
+	checkSquash(top, []int{1, 2, 3, 4, 5, 10}, 5, 0, 1, 2, 3, 4)
+	checkSquash(top, []int{3, 1}, 3, 0, 1, 2, 3, 4)
+
+	// layers of merged refs don't have the layers below them as parents
+	other := newLayer(nil, 5)
+	refs = append(refs, other)
+	merged, err := cm.Merge(ctx, []ImmutableRef{other, top}, nil)
+	require.NoError(t, err)
+	refs = append(refs, merged)
+	layers := merged.LayerChain()
+	require.Len(t, layers, 6)
+	require.NoError(t, layers.Release(ctx))
+
+	checkSquash(merged, []int{1}, 2, 0, 1, 2, 3, 4, 5)
+	checkSquash(merged, []int{3}, 2, 0, 1, 2, 3, 4, 5)
+	checkSquash(merged, []int{2, 4}, 3, 0, 1, 2, 3, 4, 5)
+
+	for _, ref := range refs {
+		require.NoError(t, ref.Release(ctx))
+	}
+
+	require.Equal(t, []int{0, 2, 4}, SquashGroups(6, []int{4, 2, 2, 0, -1, 6, 8}))
+	require.Nil(t, SquashGroups(0, []int{1}))
+}
+
 func TestLoadHalfFinalizedRef(t *testing.T) {
 	// This test simulates the situation where a ref w/ an equalMutable has its
 	// snapshot committed but there is a crash before the metadata is updated to
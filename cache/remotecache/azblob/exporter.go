@@ -132,7 +132,14 @@ func (ce *exporter) Finalize(ctx context.Context) (map[string]string, error) {
 	}
 
 	for _, name := range ce.config.Names {
-		if innerError := ce.uploadManifest(ctx, manifestKey(ce.config, name), bytesToReadSeekCloser(dt)); innerError != nil {
+		key := manifestKey(ce.config, name)
+		var innerError error
+		if ce.config.Merge.Enabled {
+			innerError = remotecache.WriteMerged(ctx, &mergeTarget{containerClient: ce.containerClient, key: key}, config, ce.config.Merge.MergeOpt)
+		} else {
+			innerError = ce.uploadManifest(ctx, key, bytesToReadSeekCloser(dt))
+		}
+		if innerError != nil {
 			return nil, errors.Wrapf(innerError, "error writing manifest %s", name)
 		}
 	}
@@ -165,6 +172,56 @@ func (ce *exporter) uploadManifest(ctx context.Context, manifestKey string, read
 	return nil
 }
 
+// mergeTarget updates a manifest with conditional uploads based on its ETag.
+type mergeTarget struct {
+	containerClient *container.Client
+	key             string
+}
+
+func (t *mergeTarget) Read(ctx context.Context) (*cacheimporttypes.CacheConfig, string, error) {
+	res, err := t.containerClient.NewBlockBlobClient(t.key).DownloadStream(ctx, &blob.DownloadStreamOptions{})
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", errors.Wrapf(err, "failed to download manifest %s", t.key)
+	}
+	defer res.Body.Close()
+
+	var config cacheimporttypes.CacheConfig
+	if err := json.NewDecoder(res.Body).Decode(&config); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	var etag string
+	if res.ETag != nil {
+		etag = string(*res.ETag)
+	}
+	return &config, etag, nil
+}
+
+func (t *mergeTarget) Write(ctx context.Context, dt []byte, etag string) error {
+	ctx, cnclFn := context.WithCancelCause(ctx)
+	ctx, _ = context.WithTimeoutCause(ctx, time.Minute*5, errors.WithStack(context.DeadlineExceeded)) //nolint:govet
+	defer cnclFn(errors.WithStack(context.Canceled))
+
+	cond := &blob.ModifiedAccessConditions{}
+	if etag == "" {
+		cond.IfNoneMatch = to.Ptr(azcore.ETagAny)
+	} else {
+		cond.IfMatch = to.Ptr(azcore.ETag(etag))
+	}
+	_, err := t.containerClient.NewBlockBlobClient(t.key).Upload(ctx, bytesToReadSeekCloser(dt), &blockblob.UploadOptions{
+		AccessConditions: &blob.AccessConditions{ModifiedAccessConditions: cond},
+	})
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+			return errors.WithStack(remotecache.ErrMergeConflict)
+		}
+		return errors.Wrapf(err, "failed to upload manifest %s", t.key)
+	}
+	return nil
+}
+
 // For uploading blobs, use the UploadStream with access conditions which state that only upload if the blob
 // does not already exist. Since blobs are content addressable, this is the right thing to do for blobs and it gives
 // a performance improvement over the Upload API used for uploading manifests.
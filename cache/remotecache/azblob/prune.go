@@ -0,0 +1,109 @@
+package azblob
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/moby/buildkit/cache/remotecache"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// NewPruneTarget returns a remotecache.PruneTarget for the azblob cache
+// described by attrs, which are the same attributes as for the cache exporter.
+func NewPruneTarget(ctx context.Context, attrs map[string]string) (remotecache.PruneTarget, error) {
+	config, err := getConfig(attrs)
+	if err != nil {
+		return nil, err
+	}
+	containerClient, err := createContainerClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &pruneTarget{containerClient: containerClient, config: config}, nil
+}
+
+type pruneTarget struct {
+	containerClient *container.Client
+	config          *Config
+}
+
+func (t *pruneTarget) list(ctx context.Context, prefix string, filter func(key string) bool) ([]remotecache.PruneObject, error) {
+	var objects []remotecache.PruneObject
+	pager := t.containerClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list blobs with prefix %s", prefix)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil || !filter(*item.Name) {
+				continue
+			}
+			obj := remotecache.PruneObject{Key: *item.Name}
+			if props := item.Properties; props != nil {
+				if props.ContentLength != nil {
+					obj.Size = *props.ContentLength
+				}
+				if props.LastModified != nil {
+					obj.LastModified = *props.LastModified
+				}
+			}
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+func (t *pruneTarget) Manifests(ctx context.Context) ([]remotecache.PruneObject, error) {
+	prefix := filepath.Join(t.config.Prefix, t.config.ManifestsPrefix) + "/"
+	return t.list(ctx, prefix, func(key string) bool {
+		return !strings.Contains(strings.TrimPrefix(key, prefix), "/")
+	})
+}
+
+func (t *pruneTarget) Blobs(ctx context.Context) ([]remotecache.PruneObject, error) {
+	prefix := filepath.Join(t.config.Prefix, t.config.BlobsPrefix) + "/"
+	return t.list(ctx, prefix, func(key string) bool {
+		_, err := digest.Parse(strings.TrimPrefix(key, prefix))
+		return err == nil
+	})
+}
+
+func (t *pruneTarget) References(ctx context.Context, manifest remotecache.PruneObject) ([]string, error) {
+	res, err := t.containerClient.NewBlobClient(manifest.Key).DownloadStream(ctx, &blob.DownloadStreamOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download manifest %s", manifest.Key)
+	}
+	defer res.Body.Close()
+
+	var config cacheimporttypes.CacheConfig
+	if err := json.NewDecoder(res.Body).Decode(&config); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	refs := make([]string, 0, len(config.Layers))
+	for _, l := range config.Layers {
+		refs = append(refs, blobKey(t.config, l.Blob))
+	}
+	return refs, nil
+}
+
+func (t *pruneTarget) DeleteManifest(ctx context.Context, manifest remotecache.PruneObject) error {
+	return t.delete(ctx, manifest.Key)
+}
+
+func (t *pruneTarget) DeleteBlob(ctx context.Context, b remotecache.PruneObject) error {
+	return t.delete(ctx, b.Key)
+}
+
+func (t *pruneTarget) delete(ctx context.Context, key string) error {
+	_, err := t.containerClient.NewBlobClient(key).Delete(ctx, &blob.DeleteOptions{})
+	return errors.Wrapf(err, "failed to delete blob %s", key)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/moby/buildkit/cache/remotecache"
 	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 )
@@ -38,6 +39,7 @@ type Config struct {
 	BlobsPrefix     string
 	Names           []string
 	AccountName     string
+	Merge           remotecache.MergeConfig
 	secretAccessKey string
 }
 
@@ -100,6 +102,11 @@ func getConfig(attrs map[string]string) (*Config, error) {
 
 	secretAccessKey := attrs[attrSecretAccessKey]
 
+	merge, err := remotecache.ParseMergeConfig(attrs)
+	if err != nil {
+		return &Config{}, err
+	}
+
 	config := Config{
 		AccountURL:      accountURLString,
 		AccountName:     accountName,
@@ -108,6 +115,7 @@ func getConfig(attrs map[string]string) (*Config, error) {
 		Names:           names,
 		ManifestsPrefix: manifestsPrefix,
 		BlobsPrefix:     blobsPrefix,
+		Merge:           merge,
 		secretAccessKey: secretAccessKey,
 	}
 
@@ -9,6 +9,7 @@ import (
 	"github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/core/images"
 	remoteserrors "github.com/containerd/containerd/v2/core/remotes/errors"
+	cerrdefs "github.com/containerd/errdefs"
 	v1 "github.com/moby/buildkit/cache/remotecache/v1"
 	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
 	"github.com/moby/buildkit/session"
@@ -73,6 +74,13 @@ func NewExporter(ingester content.Ingester, ref string, oci bool, imageManifest
 	return &contentCacheExporter{CacheExporterTarget: cc, chains: cc, ingester: ingester, oci: oci, imageManifest: imageManifest, ref: ref, comp: compressionConfig}
 }
 
+// NewMergingExporter returns an exporter that merges the exported cache into
+// the cache manifest resolved by src instead of replacing it.
+func NewMergingExporter(ingester content.Ingester, ref string, oci bool, imageManifest bool, compressionConfig compression.Config, src MergeSource, opt v1.MergeOpt) Exporter {
+	cc := v1.NewCacheChains()
+	return &contentCacheExporter{CacheExporterTarget: cc, chains: cc, ingester: ingester, oci: oci, imageManifest: imageManifest, ref: ref, comp: compressionConfig, mergeSrc: src, mergeOpt: opt}
+}
+
 type ExportableCache struct {
 	// This cache describes two distinct styles of exportable cache, one is an Index (or Manifest List) of blobs,
 	// or as an artifact using the OCI image manifest format.
@@ -172,6 +180,8 @@ type contentCacheExporter struct {
 	imageManifest bool
 	ref           string
 	comp          compression.Config
+	mergeSrc      MergeSource
+	mergeOpt      v1.MergeOpt
 }
 
 func (ce *contentCacheExporter) Name() string {
@@ -185,7 +195,6 @@ func (ce *contentCacheExporter) Config() Config {
 }
 
 func (ce *contentCacheExporter) Finalize(ctx context.Context) (map[string]string, error) {
-	res := make(map[string]string)
 	config, descs, err := ce.chains.Marshal(ctx)
 	if err != nil {
 		return nil, err
@@ -196,11 +205,6 @@ func (ce *contentCacheExporter) Finalize(ctx context.Context) (map[string]string
 		return nil, progress.OneOff(ctx, "skipping cache export for empty result")(nil)
 	}
 
-	cache, err := NewExportableCache(ce.oci, ce.imageManifest)
-	if err != nil {
-		return nil, err
-	}
-
 	// Collect layer descriptors for parallel pushing.
 	layerDescs := make([]ocispecs.Descriptor, len(config.Layers))
 	for i, l := range config.Layers {
@@ -226,6 +230,61 @@ func (ce *contentCacheExporter) Finalize(ctx context.Context) (map[string]string
 		return nil, err
 	}
 
+	if ce.mergeSrc != nil {
+		var res map[string]string
+		err := RetryMerge(ctx, func(ctx context.Context) error {
+			var err error
+			res, err = ce.writeMerged(ctx, config, descs)
+			return err
+		})
+		return res, err
+	}
+	return ce.writeManifest(ctx, config, layerDescs)
+}
+
+// writeMerged merges config with the cache manifest of the merge source and
+// writes the result. Registries do not support conditional writes, so the
+// manifest is resolved again right before it is written and the merge is
+// retried if it changed in between. This narrows but does not close the
+// window for concurrent writers to override each other.
+func (ce *contentCacheExporter) writeMerged(ctx context.Context, config *cacheimporttypes.CacheConfig, descs v1.DescriptorProvider) (map[string]string, error) {
+	prev, existing, existingLayers, err := readMergeSource(ctx, ce.mergeSrc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cache manifest for merging")
+	}
+	merged, err := v1.MergeConfig(ce.mergeOpt, existing, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge cache manifest")
+	}
+
+	layerDescs := make([]ocispecs.Descriptor, len(merged.Layers))
+	for i, l := range merged.Layers {
+		if dgstPair, ok := descs[l.Blob]; ok {
+			layerDescs[i] = dgstPair.Descriptor
+		} else if desc, ok := existingLayers[l.Blob]; ok {
+			layerDescs[i] = desc
+		} else {
+			return nil, errors.Errorf("missing blob %s", l.Blob)
+		}
+	}
+
+	cur, _, err := ce.mergeSrc.Resolve(ctx)
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return nil, err
+	}
+	if cur.Digest != prev.Digest {
+		return nil, errors.WithStack(ErrMergeConflict)
+	}
+	return ce.writeManifest(ctx, merged, layerDescs)
+}
+
+func (ce *contentCacheExporter) writeManifest(ctx context.Context, config *cacheimporttypes.CacheConfig, layerDescs []ocispecs.Descriptor) (map[string]string, error) {
+	res := make(map[string]string)
+	cache, err := NewExportableCache(ce.oci, ce.imageManifest)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add blobs to cache manifest in order.
 	for _, desc := range layerDescs {
 		cache.AddCacheBlob(desc)
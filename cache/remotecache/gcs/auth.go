@@ -0,0 +1,327 @@
+package gcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/pkg/errors"
+)
+
+const (
+	storageScope          = "https://www.googleapis.com/auth/devstorage.read_write"
+	defaultTokenURL       = "https://oauth2.googleapis.com/token"
+	defaultMetadataHost   = "metadata.google.internal"
+	tokenExpiryDelta      = time.Minute
+	credentialsTypeSA     = "service_account"
+	credentialsTypeUser   = "authorized_user"
+	jwtBearerGrantType    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	refreshTokenGrantType = "refresh_token"
+)
+
+type token struct {
+	value  string
+	expiry time.Time
+}
+
+func (t *token) valid() bool {
+	return t != nil && t.value != "" && (t.expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.expiry))
+}
+
+// tokenSource returns OAuth2 access tokens for the GCS API.
+type tokenSource interface {
+	token(ctx context.Context) (*token, error)
+}
+
+type staticTokenSource struct {
+	t *token
+}
+
+func (s *staticTokenSource) token(context.Context) (*token, error) {
+	return s.t, nil
+}
+
+// cachingTokenSource reuses a token until it is about to expire.
+type cachingTokenSource struct {
+	mu  sync.Mutex
+	t   *token
+	src tokenSource
+}
+
+func (s *cachingTokenSource) token(ctx context.Context) (*token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.valid() {
+		return s.t, nil
+	}
+	t, err := s.src.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.t = t
+	return t, nil
+}
+
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// getCredentials reads the credentials file from the session secret named in
+// config, if any. Credentials are never read from a client supplied path so
+// that a client can't make the daemon read arbitrary files.
+func getCredentials(ctx context.Context, sm *session.Manager, g session.Group, config Config) ([]byte, error) {
+	if config.CredentialsSecret == "" || config.Anonymous {
+		return nil, nil
+	}
+	var dt []byte
+	err := sm.Any(ctx, g, func(ctx context.Context, _ string, caller session.Caller) error {
+		var err error
+		dt, err = secrets.GetSecret(ctx, caller, config.CredentialsSecret)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve gcs cache credentials secret %s", config.CredentialsSecret)
+	}
+	return dt, nil
+}
+
+// newTokenSource resolves credentials from a static access token or the
+// credentials passed in by the client. Otherwise the daemon side credentials
+// are used in the same order as the Google client libraries:
+// $GOOGLE_APPLICATION_CREDENTIALS, the gcloud application default credentials
+// and finally the metadata server, which also serves workload identity tokens
+// on GKE.
+func newTokenSource(client *http.Client, config Config, credentials []byte) (tokenSource, error) {
+	if config.AccessToken != "" {
+		return &staticTokenSource{t: &token{value: config.AccessToken}}, nil
+	}
+	if credentials != nil {
+		src, err := tokenSourceFromJSON(client, credentials)
+		if err != nil {
+			// don't wrap the parse error as it may contain parts of the secret
+			return nil, errors.Errorf("invalid credentials in secret %s", config.CredentialsSecret)
+		}
+		return &cachingTokenSource{src: src}, nil
+	}
+	fn := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if fn == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			p := filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(p); err == nil {
+				fn = p
+			}
+		}
+	}
+	if fn != "" {
+		dt, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read credentials file %s", fn)
+		}
+		src, err := tokenSourceFromJSON(client, dt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid credentials file %s", fn)
+		}
+		return &cachingTokenSource{src: src}, nil
+	}
+	return &cachingTokenSource{src: &metadataTokenSource{client: client}}, nil
+}
+
+func tokenSourceFromJSON(client *http.Client, dt []byte) (tokenSource, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(dt, &f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch f.Type {
+	case credentialsTypeSA:
+		key, err := parseKey([]byte(f.PrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		tokenURL := f.TokenURI
+		if tokenURL == "" {
+			tokenURL = defaultTokenURL
+		}
+		return &jwtTokenSource{
+			client:   client,
+			email:    f.ClientEmail,
+			keyID:    f.PrivateKeyID,
+			key:      key,
+			tokenURL: tokenURL,
+		}, nil
+	case credentialsTypeUser:
+		return &refreshTokenSource{
+			client:       client,
+			clientID:     f.ClientID,
+			clientSecret: f.ClientSecret,
+			refreshToken: f.RefreshToken,
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported credentials type %q", f.Type)
+	}
+}
+
+func parseKey(dt []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(dt)
+	if block != nil {
+		dt = block.Bytes
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(dt)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(dt)
+		if err != nil {
+			return nil, errors.Wrap(err, "private key should be a PEM or plain PKCS1 or PKCS8")
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is invalid")
+	}
+	return key, nil
+}
+
+// jwtTokenSource exchanges a self-signed JWT for an access token as described
+// in https://developers.google.com/identity/protocols/oauth2/service-account
+type jwtTokenSource struct {
+	client   *http.Client
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURL string
+}
+
+func (s *jwtTokenSource) token(ctx context.Context) (*token, error) {
+	now := time.Now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if s.keyID != "" {
+		header["kid"] = s.keyID
+	}
+	claims := map[string]any{
+		"iss":   s.email,
+		"scope": storageScope,
+		"aud":   s.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	hdt, err := json.Marshal(header)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cdt, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(hdt) + "." + base64.RawURLEncoding.EncodeToString(cdt)
+	h := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, h[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign JWT")
+	}
+	return retrieveToken(ctx, s.client, s.tokenURL, url.Values{
+		"grant_type": {jwtBearerGrantType},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	})
+}
+
+type refreshTokenSource struct {
+	client       *http.Client
+	clientID     string
+	clientSecret string
+	refreshToken string
+}
+
+func (s *refreshTokenSource) token(ctx context.Context) (*token, error) {
+	return retrieveToken(ctx, s.client, defaultTokenURL, url.Values{
+		"grant_type":    {refreshTokenGrantType},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"refresh_token": {s.refreshToken},
+	})
+}
+
+type metadataTokenSource struct {
+	client *http.Client
+}
+
+func (s *metadataTokenSource) token(ctx context.Context) (*token, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultMetadataHost
+	}
+	u := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=" + url.QueryEscape(storageScope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get token from metadata server, no credentials configured")
+	}
+	return parseTokenResponse(resp)
+}
+
+func retrieveToken(ctx context.Context, client *http.Client, tokenURL string, v url.Values) (*token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve access token")
+	}
+	return parseTokenResponse(resp)
+}
+
+func parseTokenResponse(resp *http.Response) (*token, error) {
+	defer resp.Body.Close()
+	dt, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to retrieve access token: %s: %s", resp.Status, strings.TrimSpace(string(dt)))
+	}
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(dt, &tr); err != nil {
+		return nil, errors.Wrap(err, "failed to parse access token response")
+	}
+	if tr.AccessToken == "" {
+		return nil, errors.New("empty access token in response")
+	}
+	t := &token{value: tr.AccessToken}
+	if tr.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
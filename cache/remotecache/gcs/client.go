@@ -0,0 +1,376 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/buildkit/cache/remotecache"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/tracing"
+	"github.com/moby/buildkit/version"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// statusResumeIncomplete is returned by resumable uploads for every chunk
+	// except the final one.
+	statusResumeIncomplete = 308
+	maxChunkRetries        = 5
+)
+
+type objectAttrs struct {
+	Size    int64
+	Updated time.Time
+}
+
+// gcsClient is a minimal client for the GCS JSON API that implements the
+// operations needed by the cache exporter and importer.
+type gcsClient struct {
+	client          *http.Client
+	tokens          tokenSource
+	endpoint        string
+	bucket          string
+	prefix          string
+	blobsPrefix     string
+	manifestsPrefix string
+	chunkSize       int64
+}
+
+func newGCSClient(config Config, credentials []byte) (*gcsClient, error) {
+	client := tracing.DefaultClient
+	var tokens tokenSource
+	if !config.Anonymous {
+		var err error
+		tokens, err = newTokenSource(client, config, credentials)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &gcsClient{
+		client:          client,
+		tokens:          tokens,
+		endpoint:        strings.TrimSuffix(config.EndpointURL, "/"),
+		bucket:          config.Bucket,
+		prefix:          config.Prefix,
+		blobsPrefix:     config.BlobsPrefix,
+		manifestsPrefix: config.ManifestsPrefix,
+		chunkSize:       config.UploadChunkSize,
+	}, nil
+}
+
+func (c *gcsClient) manifestKey(name string) string {
+	return c.prefix + c.manifestsPrefix + name
+}
+
+func (c *gcsClient) blobKey(dgst digest.Digest) string {
+	return c.prefix + c.blobsPrefix + dgst.String()
+}
+
+func (c *gcsClient) objectURL(key string) string {
+	return c.endpoint + "/storage/v1/b/" + url.PathEscape(c.bucket) + "/o/" + url.PathEscape(key)
+}
+
+func (c *gcsClient) uploadURL(key, uploadType string) string {
+	return c.endpoint + "/upload/storage/v1/b/" + url.PathEscape(c.bucket) + "/o?" + url.Values{
+		"uploadType": {uploadType},
+		"name":       {key},
+	}.Encode()
+}
+
+func (c *gcsClient) do(ctx context.Context, method, u string, body io.Reader, fn func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	if c.tokens != nil {
+		t, err := c.tokens.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+t.value)
+	}
+	if fn != nil {
+		fn(req)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resp, nil
+}
+
+type statusError struct {
+	StatusCode int
+	msg        string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+func (e *statusError) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return cerrdefs.ErrNotFound
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	dt, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return errors.WithStack(&statusError{
+		StatusCode: resp.StatusCode,
+		msg:        fmt.Sprintf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Redacted(), resp.Status, strings.TrimSpace(string(dt))),
+	})
+}
+
+// isRetryable reports whether a failed request may succeed when repeated.
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return true
+}
+
+func (c *gcsClient) stat(ctx context.Context, key string) (*objectAttrs, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var obj struct {
+		Size    string    `json:"size"`
+		Updated time.Time `json:"updated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode metadata of %s", key)
+	}
+	size, err := strconv.ParseInt(obj.Size, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid size for %s", key)
+	}
+	return &objectAttrs{Size: size, Updated: obj.Updated}, nil
+}
+
+// touch updates the custom metadata of an object, which refreshes its
+// update time without rewriting the object data.
+func (c *gcsClient) touch(ctx context.Context, key string) error {
+	dt, err := json.Marshal(map[string]any{
+		"metadata": map[string]string{"updated-at": time.Now().UTC().Format(time.RFC3339)},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := c.do(ctx, http.MethodPatch, c.objectURL(key), bytes.NewReader(dt), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (c *gcsClient) getReader(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(key)+"?alt=media", nil, func(req *http.Request) {
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+func (c *gcsClient) getManifest(ctx context.Context, key string, config *cacheimporttypes.CacheConfig) (bool, error) {
+	generation, err := c.getManifestWithGeneration(ctx, key, config)
+	return generation != "", err
+}
+
+// getManifestWithGeneration reads a manifest and returns the generation of
+// the object. An empty generation is returned if the manifest does not exist.
+func (c *gcsClient) getManifestWithGeneration(ctx context.Context, key string, config *cacheimporttypes.CacheConfig) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(key)+"?alt=media", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	if err := decoder.Decode(config); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return "", errors.Errorf("unexpected data after JSON object")
+	}
+	generation := resp.Header.Get("X-Goog-Generation")
+	if generation == "" {
+		return "", errors.Errorf("missing generation for %s", key)
+	}
+	return generation, nil
+}
+
+// save uploads an object in a single request.
+func (c *gcsClient) save(ctx context.Context, key string, body io.Reader, size int64) error {
+	return c.saveIfGenerationMatch(ctx, key, body, size, "")
+}
+
+// saveIfGenerationMatch uploads an object only if its current generation
+// matches generation. Generation "0" requires that the object does not exist
+// and an empty generation disables the precondition. A failed precondition
+// returns remotecache.ErrMergeConflict.
+func (c *gcsClient) saveIfGenerationMatch(ctx context.Context, key string, body io.Reader, size int64, generation string) error {
+	u := c.uploadURL(key, "media")
+	if generation != "" {
+		u += "&" + url.Values{"ifGenerationMatch": {generation}}.Encode()
+	}
+	resp, err := c.do(ctx, http.MethodPost, u, body, func(req *http.Request) {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return errors.WithStack(remotecache.ErrMergeConflict)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// saveResumable uploads an object with the resumable upload protocol. Data is
+// sent in chunks and a failed chunk is retried from the last offset persisted
+// by the server, so a transient error does not restart a large upload.
+func (c *gcsClient) saveResumable(ctx context.Context, key string, ra io.ReaderAt, size int64) error {
+	if size <= c.chunkSize {
+		return c.save(ctx, key, io.NewSectionReader(ra, 0, size), size)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, c.uploadURL(key, "resumable"), nil, func(req *http.Request) {
+		req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+		req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return errors.Errorf("missing resumable upload session for %s", key)
+	}
+
+	var offset int64
+	var retries int
+	for {
+		end := min(offset+c.chunkSize, size)
+		next, done, err := c.putChunk(ctx, session, io.NewSectionReader(ra, offset, end-offset), offset, end, size)
+		if err == nil {
+			if done {
+				return nil
+			}
+			offset = next
+			retries = 0
+			continue
+		}
+		if retries >= maxChunkRetries || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+		retries++
+		bklog.G(ctx).Debugf("retrying upload of %s at offset %d: %v", key, offset, err)
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(time.Duration(retries) * time.Second):
+		}
+		next, done, qerr := c.putChunk(ctx, session, nil, 0, 0, size)
+		if qerr != nil {
+			continue
+		}
+		if done {
+			return nil
+		}
+		offset = next
+	}
+}
+
+// putChunk sends the bytes [start, end) of an upload. A nil body queries the
+// upload status instead. It returns the offset the server expects next.
+func (c *gcsClient) putChunk(ctx context.Context, session string, body io.Reader, start, end, size int64) (int64, bool, error) {
+	contentRange := fmt.Sprintf("bytes %d-%d/%d", start, end-1, size)
+	if body == nil {
+		contentRange = fmt.Sprintf("bytes */%d", size)
+		body = http.NoBody
+	}
+	resp, err := c.do(ctx, http.MethodPut, session, body, func(req *http.Request) {
+		req.ContentLength = end - start
+		req.Header.Set("Content-Range", contentRange)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return size, true, nil
+	case statusResumeIncomplete:
+		r := resp.Header.Get("Range")
+		if r == "" {
+			return 0, false, nil
+		}
+		_, last, ok := strings.Cut(strings.TrimPrefix(r, "bytes="), "-")
+		if !ok {
+			return 0, false, errors.Errorf("invalid range %q in upload response", r)
+		}
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "invalid range %q in upload response", r)
+		}
+		return n + 1, false, nil
+	default:
+		return 0, false, responseError(resp)
+	}
+}
+
+// Fetch implements remotes.Fetcher for cache blobs.
+func (c *gcsClient) Fetch(ctx context.Context, desc ocispecs.Descriptor) (io.ReadCloser, error) {
+	return c.getReader(ctx, c.blobKey(desc.Digest), 0)
+}
@@ -0,0 +1,410 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/docker/go-units"
+	"github.com/moby/buildkit/cache/remotecache"
+	v1 "github.com/moby/buildkit/cache/remotecache/v1"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/compression"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/moby/buildkit/worker"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	attrBucket            = "bucket"
+	attrPrefix            = "prefix"
+	attrManifestsPrefix   = "manifests_prefix"
+	attrBlobsPrefix       = "blobs_prefix"
+	attrName              = "name"
+	attrTouchRefresh      = "touch_refresh"
+	attrEndpointURL       = "endpoint_url"
+	attrCredentialsSecret = "credentials_secret"
+	attrAccessToken       = "access_token"
+	attrAnonymous         = "anonymous"
+	attrUploadParallelism = "upload_parallelism"
+	attrUploadChunkSize   = "upload_chunk_size"
+
+	defaultEndpointURL = "https://storage.googleapis.com"
+	// resumable upload chunks must be a multiple of 256 KiB
+	chunkSizeAlignment     = 256 * 1024
+	defaultUploadChunkSize = 16 * 1024 * 1024
+)
+
+type Config struct {
+	Bucket          string
+	Prefix          string
+	ManifestsPrefix string
+	BlobsPrefix     string
+	Names           []string
+	TouchRefresh    time.Duration
+	EndpointURL     string
+	// CredentialsSecret is the ID of a session secret holding a service
+	// account key or authorized user credentials file.
+	CredentialsSecret string
+	AccessToken       string
+	Anonymous         bool
+	UploadParallelism int
+	UploadChunkSize   int64
+	Merge             remotecache.MergeConfig
+}
+
+func getConfig(attrs map[string]string) (Config, error) {
+	bucket, ok := attrs[attrBucket]
+	if !ok {
+		bucket, ok = os.LookupEnv("BUILDKIT_GCS_BUCKET")
+		if !ok {
+			return Config{}, errors.Errorf("bucket ($BUILDKIT_GCS_BUCKET) not set for gcs cache")
+		}
+	}
+
+	prefix := attrs[attrPrefix]
+
+	manifestsPrefix, ok := attrs[attrManifestsPrefix]
+	if !ok {
+		manifestsPrefix = "manifests/"
+	}
+
+	blobsPrefix, ok := attrs[attrBlobsPrefix]
+	if !ok {
+		blobsPrefix = "blobs/"
+	}
+
+	names := []string{"buildkit"}
+	name, ok := attrs[attrName]
+	if ok {
+		splittedNames := strings.Split(name, ";")
+		if len(splittedNames) > 0 {
+			names = splittedNames
+		}
+	}
+
+	touchRefresh := 24 * time.Hour
+	if v, ok := attrs[attrTouchRefresh]; ok {
+		touchRefreshFromUser, err := time.ParseDuration(v)
+		if err == nil {
+			touchRefresh = touchRefreshFromUser
+		}
+	}
+
+	endpointURL, ok := attrs[attrEndpointURL]
+	if !ok {
+		endpointURL, ok = os.LookupEnv("STORAGE_EMULATOR_HOST")
+		if ok && !strings.Contains(endpointURL, "://") {
+			endpointURL = "http://" + endpointURL
+		}
+		if !ok {
+			endpointURL = defaultEndpointURL
+		}
+	}
+
+	var anonymous bool
+	if v, ok := attrs[attrAnonymous]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "failed to parse %s", attrAnonymous)
+		}
+		anonymous = b
+	}
+
+	uploadParallelism := 4
+	if v, ok := attrs[attrUploadParallelism]; ok {
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			return Config{}, errors.Errorf("upload_parallelism must be a positive integer")
+		}
+		uploadParallelism = i
+	}
+
+	var uploadChunkSize int64 = defaultUploadChunkSize
+	if v, ok := attrs[attrUploadChunkSize]; ok {
+		n, err := units.RAMInBytes(v)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "failed to parse %s", attrUploadChunkSize)
+		}
+		if n <= 0 || n%chunkSizeAlignment != 0 {
+			return Config{}, errors.Errorf("upload_chunk_size must be a positive multiple of 256KiB")
+		}
+		uploadChunkSize = n
+	}
+
+	merge, err := remotecache.ParseMergeConfig(attrs)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Config{
+		Bucket:            bucket,
+		Prefix:            prefix,
+		ManifestsPrefix:   manifestsPrefix,
+		BlobsPrefix:       blobsPrefix,
+		Names:             names,
+		TouchRefresh:      touchRefresh,
+		EndpointURL:       endpointURL,
+		CredentialsSecret: attrs[attrCredentialsSecret],
+		AccessToken:       attrs[attrAccessToken],
+		Anonymous:         anonymous,
+		UploadParallelism: uploadParallelism,
+		UploadChunkSize:   uploadChunkSize,
+		Merge:             merge,
+	}, nil
+}
+
+// ResolveCacheExporterFunc for gcs cache exporter.
+func ResolveCacheExporterFunc(sm *session.Manager) remotecache.ResolveCacheExporterFunc {
+	return func(ctx context.Context, g session.Group, attrs map[string]string) (remotecache.Exporter, error) {
+		config, err := getConfig(attrs)
+		if err != nil {
+			return nil, err
+		}
+		creds, err := getCredentials(ctx, sm, g, config)
+		if err != nil {
+			return nil, err
+		}
+		gcsClient, err := newGCSClient(config, creds)
+		if err != nil {
+			return nil, err
+		}
+		cc := v1.NewCacheChains()
+		return &exporter{CacheExporterTarget: cc, chains: cc, gcsClient: gcsClient, config: config}, nil
+	}
+}
+
+type exporter struct {
+	solver.CacheExporterTarget
+	chains    *v1.CacheChains
+	gcsClient *gcsClient
+	config    Config
+}
+
+func (*exporter) Name() string {
+	return "exporting cache to Google Cloud Storage"
+}
+
+func (e *exporter) Config() remotecache.Config {
+	return remotecache.Config{
+		Compression: compression.New(compression.Default),
+	}
+}
+
+func (e *exporter) Finalize(ctx context.Context) (map[string]string, error) {
+	cacheConfig, descs, err := e.chains.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	eg, groupCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(e.config.UploadParallelism)
+
+	for i, l := range cacheConfig.Layers {
+		eg.Go(func() error {
+			dgstPair, ok := descs[l.Blob]
+			if !ok {
+				return errors.Errorf("missing blob %s", l.Blob)
+			}
+			if dgstPair.Descriptor.Annotations == nil {
+				return errors.Errorf("invalid descriptor without annotations")
+			}
+			v, ok := dgstPair.Descriptor.Annotations[labels.LabelUncompressed]
+			if !ok {
+				return errors.Errorf("invalid descriptor without uncompressed annotation")
+			}
+			diffID, err := digest.Parse(v)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse uncompressed annotation")
+			}
+
+			key := e.gcsClient.blobKey(dgstPair.Descriptor.Digest)
+			attrs, err := e.gcsClient.stat(groupCtx, key)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check file presence in cache")
+			}
+			if attrs != nil {
+				if time.Since(attrs.Updated) > e.config.TouchRefresh {
+					if err := e.gcsClient.touch(groupCtx, key); err != nil {
+						return errors.Wrapf(err, "failed to touch file")
+					}
+				}
+			} else {
+				layerDone := progress.OneOff(groupCtx, fmt.Sprintf("writing layer %s", l.Blob))
+				ra, err := dgstPair.Provider.ReaderAt(groupCtx, dgstPair.Descriptor)
+				if err != nil {
+					return layerDone(errors.Wrap(err, "error reading layer blob from provider"))
+				}
+				defer ra.Close()
+				if err := e.gcsClient.saveResumable(groupCtx, key, ra, ra.Size()); err != nil {
+					return layerDone(errors.Wrap(err, "error writing layer blob"))
+				}
+				layerDone(nil)
+			}
+
+			la := &cacheimporttypes.LayerAnnotations{
+				DiffID:    diffID,
+				Size:      dgstPair.Descriptor.Size,
+				MediaType: dgstPair.Descriptor.MediaType,
+			}
+			if v, ok := dgstPair.Descriptor.Annotations["buildkit/createdat"]; ok {
+				var t time.Time
+				if err := (&t).UnmarshalText([]byte(v)); err != nil {
+					return err
+				}
+				la.CreatedAt = t.UTC()
+			}
+			cacheConfig.Layers[i].Annotations = la
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	dt, err := json.Marshal(cacheConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range e.config.Names {
+		key := e.gcsClient.manifestKey(name)
+		if e.config.Merge.Enabled {
+			err = remotecache.WriteMerged(ctx, &mergeTarget{gcsClient: e.gcsClient, key: key}, cacheConfig, e.config.Merge.MergeOpt)
+		} else {
+			err = e.gcsClient.save(ctx, key, bytes.NewReader(dt), int64(len(dt)))
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error writing manifest: %s", name)
+		}
+	}
+	return nil, nil
+}
+
+// mergeTarget updates a manifest with uploads conditional on the generation
+// of the object.
+type mergeTarget struct {
+	gcsClient *gcsClient
+	key       string
+}
+
+func (t *mergeTarget) Read(ctx context.Context) (*cacheimporttypes.CacheConfig, string, error) {
+	var config cacheimporttypes.CacheConfig
+	generation, err := t.gcsClient.getManifestWithGeneration(ctx, t.key, &config)
+	if err != nil || generation == "" {
+		return nil, "", err
+	}
+	return &config, generation, nil
+}
+
+func (t *mergeTarget) Write(ctx context.Context, dt []byte, generation string) error {
+	if generation == "" {
+		generation = "0"
+	}
+	return t.gcsClient.saveIfGenerationMatch(ctx, t.key, bytes.NewReader(dt), int64(len(dt)), generation)
+}
+
+// ResolveCacheImporterFunc for gcs cache importer.
+func ResolveCacheImporterFunc(sm *session.Manager) remotecache.ResolveCacheImporterFunc {
+	return func(ctx context.Context, g session.Group, attrs map[string]string) (remotecache.Importer, ocispecs.Descriptor, error) {
+		config, err := getConfig(attrs)
+		if err != nil {
+			return nil, ocispecs.Descriptor{}, err
+		}
+		creds, err := getCredentials(ctx, sm, g, config)
+		if err != nil {
+			return nil, ocispecs.Descriptor{}, err
+		}
+		gcsClient, err := newGCSClient(config, creds)
+		if err != nil {
+			return nil, ocispecs.Descriptor{}, err
+		}
+		return &importer{gcsClient, config}, ocispecs.Descriptor{}, nil
+	}
+}
+
+type importer struct {
+	gcsClient *gcsClient
+	config    Config
+}
+
+func (i *importer) makeDescriptorProviderPair(l cacheimporttypes.CacheLayer) (*v1.DescriptorProviderPair, error) {
+	if l.Annotations == nil {
+		return nil, errors.Errorf("cache layer with missing annotations")
+	}
+	if l.Annotations.DiffID == "" {
+		return nil, errors.Errorf("cache layer with missing diffid")
+	}
+	annotations := map[string]string{}
+	annotations[labels.LabelUncompressed] = l.Annotations.DiffID.String()
+	if !l.Annotations.CreatedAt.IsZero() {
+		txt, err := l.Annotations.CreatedAt.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		annotations["buildkit/createdat"] = string(txt)
+	}
+	return &v1.DescriptorProviderPair{
+		Provider: contentutil.FromFetcher(i.gcsClient),
+		Descriptor: ocispecs.Descriptor{
+			MediaType:   l.Annotations.MediaType,
+			Digest:      l.Blob,
+			Size:        l.Annotations.Size,
+			Annotations: annotations,
+		},
+	}, nil
+}
+
+func (i *importer) load(ctx context.Context) (*v1.CacheChains, error) {
+	var config cacheimporttypes.CacheConfig
+	found, err := i.gcsClient.getManifest(ctx, i.gcsClient.manifestKey(i.config.Names[0]), &config)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return v1.NewCacheChains(), nil
+	}
+
+	allLayers := v1.DescriptorProvider{}
+
+	for _, l := range config.Layers {
+		dpp, err := i.makeDescriptorProviderPair(l)
+		if err != nil {
+			return nil, err
+		}
+		allLayers[l.Blob] = *dpp
+	}
+
+	cc := v1.NewCacheChains()
+	if err := v1.ParseConfig(config, allLayers, cc); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+func (i *importer) Resolve(ctx context.Context, _ ocispecs.Descriptor, id string, w worker.Worker) (solver.CacheManager, error) {
+	cc, err := i.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keysStorage, resultStorage, err := v1.NewCacheKeyStorage(cc, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return solver.NewCacheManager(ctx, id, keysStorage, resultStorage), nil
+}
@@ -0,0 +1,328 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/cache/remotecache"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeObject struct {
+	data       []byte
+	updated    time.Time
+	generation int64
+}
+
+// fakeServer implements the subset of the GCS JSON API used by gcsClient.
+type fakeServer struct {
+	t          *testing.T
+	mu         sync.Mutex
+	objects    map[string]*fakeObject
+	uploads    map[string][]byte
+	failChunks int
+	chunkPuts  int
+	token      string
+	generation int64
+}
+
+func (s *fakeServer) put(key string, dt []byte) {
+	s.generation++
+	s.objects[key] = &fakeObject{data: dt, updated: time.Now(), generation: s.generation}
+}
+
+func newFakeServer(t *testing.T, token string) *fakeServer {
+	return &fakeServer{
+		t:       t,
+		objects: map[string]*fakeObject{},
+		uploads: map[string][]byte{},
+		token:   token,
+	}
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const objPrefix = "/storage/v1/b/bucket/o/"
+	switch {
+	case strings.HasPrefix(r.URL.Path, objPrefix):
+		key := strings.TrimPrefix(r.URL.Path, objPrefix)
+		obj, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("alt") == "media" {
+				w.Header().Set("X-Goog-Generation", strconv.FormatInt(obj.generation, 10))
+				dt := obj.data
+				if rng := r.Header.Get("Range"); rng != "" {
+					off, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+					require.NoError(s.t, err)
+					dt = dt[off:]
+					w.WriteHeader(http.StatusPartialContent)
+				}
+				w.Write(dt)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"size":    strconv.Itoa(len(obj.data)),
+				"updated": obj.updated,
+			})
+		case http.MethodPatch:
+			obj.updated = time.Now()
+			w.Write([]byte("{}"))
+		}
+	case r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		key := r.URL.Query().Get("name")
+		switch r.URL.Query().Get("uploadType") {
+		case "media":
+			if v := r.URL.Query().Get("ifGenerationMatch"); v != "" {
+				var current int64
+				if obj, ok := s.objects[key]; ok {
+					current = obj.generation
+				}
+				if v != strconv.FormatInt(current, 10) {
+					w.WriteHeader(http.StatusPreconditionFailed)
+					return
+				}
+			}
+			dt, err := io.ReadAll(r.Body)
+			require.NoError(s.t, err)
+			s.put(key, dt)
+			w.Write([]byte("{}"))
+		case "resumable":
+			w.Header().Set("Location", "http://"+r.Host+"/session?name="+url.QueryEscape(key))
+			w.WriteHeader(http.StatusOK)
+		}
+	case r.URL.Path == "/session" && r.Method == http.MethodPut:
+		key := r.URL.Query().Get("name")
+		cr := r.Header.Get("Content-Range")
+		rng, total, _ := strings.Cut(strings.TrimPrefix(cr, "bytes "), "/")
+		size, err := strconv.Atoi(total)
+		require.NoError(s.t, err)
+		if rng != "*" {
+			s.chunkPuts++
+			if s.failChunks > 0 {
+				s.failChunks--
+				// persist half of the chunk to simulate a partial write
+				dt, _ := io.ReadAll(r.Body)
+				s.uploads[key] = append(s.uploads[key], dt[:len(dt)/2]...)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			start, _, _ := strings.Cut(rng, "-")
+			off, err := strconv.Atoi(start)
+			require.NoError(s.t, err)
+			require.Equal(s.t, len(s.uploads[key]), off)
+			dt, err := io.ReadAll(r.Body)
+			require.NoError(s.t, err)
+			s.uploads[key] = append(s.uploads[key], dt...)
+		}
+		if len(s.uploads[key]) == size {
+			s.put(key, s.uploads[key])
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if n := len(s.uploads[key]); n > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+		}
+		w.WriteHeader(statusResumeIncomplete)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server, attrs map[string]string) *gcsClient {
+	all := map[string]string{
+		"bucket":       "bucket",
+		"endpoint_url": srv.URL,
+		"access_token": "token",
+	}
+	for k, v := range attrs {
+		all[k] = v
+	}
+	cfg, err := getConfig(all)
+	require.NoError(t, err)
+	c, err := newGCSClient(cfg, nil)
+	require.NoError(t, err)
+	return c
+}
+
+func TestGetConfig(t *testing.T) {
+	t.Setenv("BUILDKIT_GCS_BUCKET", "")
+	t.Setenv("STORAGE_EMULATOR_HOST", "localhost:4443")
+
+	cfg, err := getConfig(map[string]string{"bucket": "b", "prefix": "p/"})
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:4443", cfg.EndpointURL)
+	require.Equal(t, int64(defaultUploadChunkSize), cfg.UploadChunkSize)
+	require.Equal(t, 24*time.Hour, cfg.TouchRefresh)
+
+	_, err = getConfig(map[string]string{"bucket": "b", "upload_chunk_size": "100k"})
+	require.ErrorContains(t, err, "multiple of 256KiB")
+
+	cfg, err = getConfig(map[string]string{"bucket": "b", "upload_chunk_size": "512k"})
+	require.NoError(t, err)
+	require.Equal(t, int64(512*1024), cfg.UploadChunkSize)
+}
+
+func TestSaveAndRead(t *testing.T) {
+	ctx := context.TODO()
+	fs := newFakeServer(t, "token")
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	c := newTestClient(t, srv, map[string]string{"prefix": "cache/"})
+
+	attrs, err := c.stat(ctx, c.manifestKey("buildkit"))
+	require.NoError(t, err)
+	require.Nil(t, attrs)
+
+	require.NoError(t, c.save(ctx, c.manifestKey("buildkit"), strings.NewReader("{}"), 2))
+	require.Contains(t, fs.objects, "cache/manifests/buildkit")
+
+	attrs, err = c.stat(ctx, c.manifestKey("buildkit"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), attrs.Size)
+
+	fs.objects["cache/manifests/buildkit"].updated = time.Now().Add(-48 * time.Hour)
+	require.NoError(t, c.touch(ctx, c.manifestKey("buildkit")))
+	attrs, err = c.stat(ctx, c.manifestKey("buildkit"))
+	require.NoError(t, err)
+	require.Less(t, time.Since(attrs.Updated), time.Hour)
+
+	rc, err := c.getReader(ctx, c.manifestKey("buildkit"), 1)
+	require.NoError(t, err)
+	dt, err := io.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	require.Equal(t, "}", string(dt))
+
+	_, err = c.getReader(ctx, c.manifestKey("missing"), 0)
+	require.Error(t, err)
+}
+
+func TestResumableUpload(t *testing.T) {
+	ctx := context.TODO()
+	fs := newFakeServer(t, "")
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	c := newTestClient(t, srv, map[string]string{"upload_chunk_size": "256k"})
+
+	data := make([]byte, 3*chunkSizeAlignment+100)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	fs.failChunks = 1
+	require.NoError(t, c.saveResumable(ctx, "blob", bytes.NewReader(data), int64(len(data))))
+	require.Equal(t, data, fs.objects["blob"].data)
+	// the failed first chunk persisted half of its data, so the upload
+	// resumes from there and needs three more chunks
+	require.Equal(t, 4, fs.chunkPuts)
+}
+
+func TestMergeTarget(t *testing.T) {
+	ctx := context.TODO()
+	fs := newFakeServer(t, "")
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	c := newTestClient(t, srv, nil)
+	target := &mergeTarget{gcsClient: c, key: c.manifestKey("buildkit")}
+
+	cfg, generation, err := target.Read(ctx)
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+	require.Empty(t, generation)
+
+	require.NoError(t, target.Write(ctx, []byte(`{"layers":[]}`), generation))
+	// the manifest exists now, so writing it as new again conflicts
+	require.ErrorIs(t, target.Write(ctx, []byte(`{}`), ""), remotecache.ErrMergeConflict)
+
+	cfg, generation, err = target.Read(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	require.NotEmpty(t, generation)
+
+	fs.mu.Lock()
+	fs.put("manifests/buildkit", []byte(`{}`))
+	fs.mu.Unlock()
+	require.ErrorIs(t, target.Write(ctx, []byte(`{}`), generation), remotecache.ErrMergeConflict)
+
+	_, generation, err = target.Read(ctx)
+	require.NoError(t, err)
+	require.NoError(t, target.Write(ctx, []byte(`{}`), generation))
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	ctx := context.TODO()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	kdt, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var calls int
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, jwtBearerGrantType, r.Form.Get("grant_type"))
+		require.Len(t, strings.Split(r.Form.Get("assertion"), "."), 3)
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "sa-token", "expires_in": 3600})
+	}))
+	defer tokenSrv.Close()
+
+	creds, err := json.Marshal(credentialsFile{
+		Type:        credentialsTypeSA,
+		ClientEmail: "test@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kdt})),
+		TokenURI:    tokenSrv.URL,
+	})
+	require.NoError(t, err)
+
+	src, err := tokenSourceFromJSON(http.DefaultClient, creds)
+	require.NoError(t, err)
+	cached := &cachingTokenSource{src: src}
+
+	for range 2 {
+		tok, err := cached.token(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "sa-token", tok.value)
+	}
+	require.Equal(t, 1, calls)
+}
+
+func TestCredentialsSecret(t *testing.T) {
+	cfg := Config{CredentialsSecret: "gcs"}
+	_, err := newTokenSource(http.DefaultClient, cfg, []byte(`{"type":"service_account","private_key":"very-secret"}`))
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "very-secret")
+
+	src, err := newTokenSource(http.DefaultClient, cfg, []byte(`{"type":"authorized_user","refresh_token":"r"}`))
+	require.NoError(t, err)
+	require.IsType(t, &cachingTokenSource{}, src)
+}
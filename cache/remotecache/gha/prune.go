@@ -0,0 +1,167 @@
+package gha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/cache/remotecache"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	"github.com/moby/buildkit/util/tracing"
+	bkversion "github.com/moby/buildkit/version"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	actionscache "github.com/tonistiigi/go-actions-cache"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+// NewPruneTarget returns a remotecache.PruneTarget for the GitHub Actions
+// cache described by attrs, which are the same attributes as for the cache
+// exporter. The repository and ghtoken attributes are required to list and
+// delete cache entries. Only the entries of the writable scope of the token,
+// i.e. the current ref, are pruned.
+func NewPruneTarget(attrs map[string]string) (remotecache.PruneTarget, error) {
+	config, err := getConfig(nil, nil, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if config.Repository == "" || config.GHToken == "" {
+		return nil, errors.Errorf("%s and %s are required to prune github actions cache", attrRepository, attrGHToken)
+	}
+	opt := actionscache.Opt{
+		Client:    tracing.DefaultClient,
+		Timeout:   config.Timeout,
+		UserAgent: bkversion.UserAgent(),
+	}
+	cache, err := actionscache.New(config.Token, config.URL, config.Version > 1, opt)
+	if err != nil {
+		return nil, err
+	}
+	api, err := actionscache.NewRestAPI(config.Repository, config.GHToken, opt)
+	if err != nil {
+		return nil, err
+	}
+	var ref string
+	for _, s := range cache.Scopes() {
+		if s.Permission&actionscache.PermissionWrite != 0 {
+			ref = s.Scope
+		}
+	}
+	if ref == "" {
+		return nil, errors.Errorf("github actions cache token has no writable scope")
+	}
+	return &pruneTarget{
+		cache:  cache,
+		api:    api,
+		config: config,
+		ref:    ref,
+		ids:    map[string]int{},
+	}, nil
+}
+
+type pruneTarget struct {
+	cache  *actionscache.Cache
+	api    *actionscache.RestAPI
+	config *Config
+	ref    string
+	// ids maps the keys of the listed entries to their cache IDs
+	ids map[string]int
+}
+
+func (t *pruneTarget) list(ctx context.Context, prefix string) ([]remotecache.PruneObject, error) {
+	keys, err := t.api.ListKeys(ctx, prefix, t.ref)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]remotecache.PruneObject, 0, len(keys))
+	for _, k := range keys {
+		// the key parameter of the REST API matches by prefix, but the ref
+		// is checked again to be safe
+		if !strings.HasPrefix(k.Key, prefix) || k.Ref != t.ref {
+			continue
+		}
+		lastAccessed, err := time.Parse(time.RFC3339, k.LastAccessed)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid last access time for %s", k.Key)
+		}
+		t.ids[k.Key] = k.ID
+		objects = append(objects, remotecache.PruneObject{
+			Key:          k.Key,
+			Size:         int64(k.SizeInBytes),
+			LastModified: lastAccessed,
+		})
+	}
+	return objects, nil
+}
+
+func (t *pruneTarget) Manifests(ctx context.Context) ([]remotecache.PruneObject, error) {
+	return t.list(ctx, "index-")
+}
+
+func (t *pruneTarget) Blobs(ctx context.Context) ([]remotecache.PruneObject, error) {
+	return t.list(ctx, blobKeyPrefix())
+}
+
+func (t *pruneTarget) References(ctx context.Context, manifest remotecache.PruneObject) ([]string, error) {
+	entry, err := t.cache.Load(ctx, manifest.Key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.Errorf("cache index %s not found", manifest.Key)
+	}
+	buf := &bytes.Buffer{}
+	if err := entry.WriteTo(ctx, buf); err != nil {
+		return nil, err
+	}
+	var config cacheimporttypes.CacheConfig
+	if err := json.Unmarshal(buf.Bytes(), &config); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	refs := make([]string, 0, len(config.Layers)+1)
+	for _, l := range config.Layers {
+		refs = append(refs, blobKey(l.Blob))
+	}
+	// the signature of a signed index is stored as a blob
+	refs = append(refs, blobKey(digest.FromBytes(buf.Bytes()))+"-sig")
+	return refs, nil
+}
+
+func (t *pruneTarget) DeleteManifest(ctx context.Context, manifest remotecache.PruneObject) error {
+	return t.delete(ctx, manifest.Key)
+}
+
+func (t *pruneTarget) DeleteBlob(ctx context.Context, blob remotecache.PruneObject) error {
+	return t.delete(ctx, blob.Key)
+}
+
+func (t *pruneTarget) delete(ctx context.Context, key string) error {
+	id, ok := t.ids[key]
+	if !ok {
+		return errors.Errorf("unknown cache entry %s", key)
+	}
+	u := githubAPIURL + "/repos/" + t.config.Repository + "/actions/caches/" + strconv.Itoa(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+t.config.GHToken)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", bkversion.UserAgent())
+	resp, err := tracing.DefaultClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	// the entry may have been evicted in the meantime
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return errors.Errorf("unexpected status code deleting cache entry %s: %s", key, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,556 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/pkg/labels"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/buildkit/cache/remotecache"
+	v1 "github.com/moby/buildkit/cache/remotecache/v1"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/compression"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/moby/buildkit/util/tracing"
+	"github.com/moby/buildkit/version"
+	"github.com/moby/buildkit/worker"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	attrURL               = "url"
+	attrManifestsPrefix   = "manifests_prefix"
+	attrBlobsPrefix       = "blobs_prefix"
+	attrName              = "name"
+	attrUploadParallelism = "upload_parallelism"
+	attrAuthHeaderSecret  = "auth_header_secret"
+	attrAuthTokenSecret   = "auth_token_secret"
+)
+
+type Config struct {
+	URL               *url.URL
+	ManifestsPrefix   string
+	BlobsPrefix       string
+	Names             []string
+	UploadParallelism int
+	// AuthHeaderSecret is the ID of a session secret whose value is sent as
+	// the Authorization header.
+	AuthHeaderSecret string
+	// AuthTokenSecret is the ID of a session secret whose value is sent as a
+	// bearer token in the Authorization header.
+	AuthTokenSecret string
+	Merge           remotecache.MergeConfig
+}
+
+func getConfig(attrs map[string]string) (Config, error) {
+	urlStr, ok := attrs[attrURL]
+	if !ok || urlStr == "" {
+		return Config{}, errors.Errorf("url not set for http cache")
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return Config{}, errors.Wrapf(err, "invalid url %q for http cache", urlStr)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Config{}, errors.Errorf("unsupported url scheme %q for http cache", u.Scheme)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	manifestsPrefix, ok := attrs[attrManifestsPrefix]
+	if !ok {
+		manifestsPrefix = "manifests/"
+	}
+
+	blobsPrefix, ok := attrs[attrBlobsPrefix]
+	if !ok {
+		blobsPrefix = "blobs/"
+	}
+
+	names := []string{"buildkit"}
+	if name, ok := attrs[attrName]; ok {
+		splittedNames := strings.Split(name, ";")
+		if len(splittedNames) > 0 {
+			names = splittedNames
+		}
+	}
+
+	uploadParallelism := 4
+	if v, ok := attrs[attrUploadParallelism]; ok {
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			return Config{}, errors.Errorf("upload_parallelism must be a positive integer")
+		}
+		uploadParallelism = i
+	}
+
+	authHeaderSecret := attrs[attrAuthHeaderSecret]
+	authTokenSecret := attrs[attrAuthTokenSecret]
+	if authHeaderSecret != "" && authTokenSecret != "" {
+		return Config{}, errors.Errorf("only one of %s and %s can be set for http cache", attrAuthHeaderSecret, attrAuthTokenSecret)
+	}
+
+	merge, err := remotecache.ParseMergeConfig(attrs)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Config{
+		URL:               u,
+		ManifestsPrefix:   manifestsPrefix,
+		BlobsPrefix:       blobsPrefix,
+		Names:             names,
+		UploadParallelism: uploadParallelism,
+		AuthHeaderSecret:  authHeaderSecret,
+		AuthTokenSecret:   authTokenSecret,
+		Merge:             merge,
+	}, nil
+}
+
+// ResolveCacheExporterFunc for "http" cache exporter.
+func ResolveCacheExporterFunc(sm *session.Manager) remotecache.ResolveCacheExporterFunc {
+	return func(ctx context.Context, g session.Group, attrs map[string]string) (remotecache.Exporter, error) {
+		config, err := getConfig(attrs)
+		if err != nil {
+			return nil, err
+		}
+		client, err := newClient(ctx, sm, g, config)
+		if err != nil {
+			return nil, err
+		}
+		return NewExporter(client, config), nil
+	}
+}
+
+// ResolveCacheImporterFunc for "http" cache importer.
+func ResolveCacheImporterFunc(sm *session.Manager) remotecache.ResolveCacheImporterFunc {
+	return func(ctx context.Context, g session.Group, attrs map[string]string) (remotecache.Importer, ocispecs.Descriptor, error) {
+		config, err := getConfig(attrs)
+		if err != nil {
+			return nil, ocispecs.Descriptor{}, err
+		}
+		client, err := newClient(ctx, sm, g, config)
+		if err != nil {
+			return nil, ocispecs.Descriptor{}, err
+		}
+		return NewImporter(client, config), ocispecs.Descriptor{}, nil
+	}
+}
+
+var _ remotecache.Exporter = &exporter{}
+
+type exporter struct {
+	solver.CacheExporterTarget
+	chains *v1.CacheChains
+	client *Client
+	config Config
+}
+
+func NewExporter(client *Client, config Config) remotecache.Exporter {
+	cc := v1.NewCacheChains()
+	return &exporter{CacheExporterTarget: cc, chains: cc, client: client, config: config}
+}
+
+func (*exporter) Name() string {
+	return "exporting cache to HTTP server"
+}
+
+func (*exporter) Config() remotecache.Config {
+	return remotecache.Config{
+		Compression: compression.New(compression.Default),
+	}
+}
+
+func (e *exporter) Finalize(ctx context.Context) (map[string]string, error) {
+	cacheConfig, descs, err := e.chains.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	eg, groupCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(e.config.UploadParallelism)
+
+	for i, l := range cacheConfig.Layers {
+		eg.Go(func() error {
+			dgstPair, ok := descs[l.Blob]
+			if !ok {
+				return errors.Errorf("missing blob %s", l.Blob)
+			}
+			if dgstPair.Descriptor.Annotations == nil {
+				return errors.Errorf("invalid descriptor without annotations")
+			}
+			v, ok := dgstPair.Descriptor.Annotations[labels.LabelUncompressed]
+			if !ok {
+				return errors.Errorf("invalid descriptor without uncompressed annotation")
+			}
+			diffID, err := digest.Parse(v)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse uncompressed annotation")
+			}
+
+			blobURL := e.client.blobURL(dgstPair.Descriptor.Digest)
+			exists, err := e.client.exists(groupCtx, blobURL)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check blob presence in cache")
+			}
+			bklog.G(groupCtx).Debugf("layer %s exists = %t", blobURL, exists)
+			if !exists {
+				layerDone := progress.OneOff(groupCtx, fmt.Sprintf("writing layer %s", l.Blob))
+				ra, err := dgstPair.Provider.ReaderAt(groupCtx, dgstPair.Descriptor)
+				if err != nil {
+					return layerDone(errors.Wrap(err, "error reading layer blob from provider"))
+				}
+				defer ra.Close()
+				if err := e.client.put(groupCtx, blobURL, io.NewSectionReader(ra, 0, ra.Size()), ra.Size()); err != nil {
+					return layerDone(errors.Wrap(err, "error writing layer blob"))
+				}
+				layerDone(nil)
+			}
+
+			la := &cacheimporttypes.LayerAnnotations{
+				DiffID:    diffID,
+				Size:      dgstPair.Descriptor.Size,
+				MediaType: dgstPair.Descriptor.MediaType,
+			}
+			if v, ok := dgstPair.Descriptor.Annotations["buildkit/createdat"]; ok {
+				var t time.Time
+				if err := (&t).UnmarshalText([]byte(v)); err != nil {
+					return err
+				}
+				la.CreatedAt = t.UTC()
+			}
+			cacheConfig.Layers[i].Annotations = la
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	dt, err := json.Marshal(cacheConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range e.config.Names {
+		u := e.client.manifestURL(name)
+		if e.config.Merge.Enabled {
+			err = remotecache.WriteMerged(ctx, &mergeTarget{client: e.client, url: u}, cacheConfig, e.config.Merge.MergeOpt)
+		} else {
+			err = e.client.put(ctx, u, bytes.NewReader(dt), int64(len(dt)))
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error writing manifest: %s", name)
+		}
+	}
+	return nil, nil
+}
+
+// mergeTarget updates a manifest with conditional PUT requests. The server
+// must return an ETag for manifests and support If-Match and If-None-Match.
+type mergeTarget struct {
+	client *Client
+	url    string
+}
+
+func (t *mergeTarget) Read(ctx context.Context) (*cacheimporttypes.CacheConfig, string, error) {
+	var config cacheimporttypes.CacheConfig
+	found, etag, err := t.client.getManifestWithETag(ctx, t.url, &config)
+	if err != nil || !found {
+		return nil, "", err
+	}
+	if etag == "" {
+		return nil, "", errors.Errorf("server did not return an ETag for %s, merging is not supported", t.url)
+	}
+	return &config, etag, nil
+}
+
+func (t *mergeTarget) Write(ctx context.Context, dt []byte, etag string) error {
+	return t.client.putIfMatch(ctx, t.url, bytes.NewReader(dt), int64(len(dt)), etag, true)
+}
+
+type importer struct {
+	client *Client
+	config Config
+}
+
+func NewImporter(client *Client, config Config) remotecache.Importer {
+	return &importer{client: client, config: config}
+}
+
+func (i *importer) makeDescriptorProviderPair(l cacheimporttypes.CacheLayer) (*v1.DescriptorProviderPair, error) {
+	if l.Annotations == nil {
+		return nil, errors.Errorf("cache layer with missing annotations")
+	}
+	if l.Annotations.DiffID == "" {
+		return nil, errors.Errorf("cache layer with missing diffid")
+	}
+	annotations := map[string]string{}
+	annotations[labels.LabelUncompressed] = l.Annotations.DiffID.String()
+	if !l.Annotations.CreatedAt.IsZero() {
+		txt, err := l.Annotations.CreatedAt.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		annotations["buildkit/createdat"] = string(txt)
+	}
+	return &v1.DescriptorProviderPair{
+		Provider: contentutil.FromFetcher(i.client),
+		Descriptor: ocispecs.Descriptor{
+			MediaType:   l.Annotations.MediaType,
+			Digest:      l.Blob,
+			Size:        l.Annotations.Size,
+			Annotations: annotations,
+		},
+	}, nil
+}
+
+func (i *importer) load(ctx context.Context) (*v1.CacheChains, error) {
+	var config cacheimporttypes.CacheConfig
+	found, err := i.client.getManifest(ctx, i.client.manifestURL(i.config.Names[0]), &config)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return v1.NewCacheChains(), nil
+	}
+
+	allLayers := v1.DescriptorProvider{}
+	for _, l := range config.Layers {
+		dpp, err := i.makeDescriptorProviderPair(l)
+		if err != nil {
+			return nil, err
+		}
+		allLayers[l.Blob] = *dpp
+	}
+
+	cc := v1.NewCacheChains()
+	if err := v1.ParseConfig(config, allLayers, cc); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+func (i *importer) Resolve(ctx context.Context, _ ocispecs.Descriptor, id string, w worker.Worker) (solver.CacheManager, error) {
+	cc, err := i.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keysStorage, resultStorage, err := v1.NewCacheKeyStorage(cc, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return solver.NewCacheManager(ctx, id, keysStorage, resultStorage), nil
+}
+
+// Client talks to a plain HTTP server storing cache manifests and blobs as
+// individual resources addressed by their path.
+type Client struct {
+	client          *http.Client
+	baseURL         *url.URL
+	manifestsPrefix string
+	blobsPrefix     string
+	authorization   string
+}
+
+// NewClient returns a client for the HTTP server described by config. The
+// authorization value, if not empty, is sent with every request.
+func NewClient(config Config, authorization string) *Client {
+	return &Client{
+		client:          tracing.DefaultClient,
+		baseURL:         config.URL,
+		manifestsPrefix: config.ManifestsPrefix,
+		blobsPrefix:     config.BlobsPrefix,
+		authorization:   authorization,
+	}
+}
+
+func newClient(ctx context.Context, sm *session.Manager, g session.Group, config Config) (*Client, error) {
+	secretID := config.AuthHeaderSecret
+	if secretID == "" {
+		secretID = config.AuthTokenSecret
+	}
+	if secretID == "" {
+		return NewClient(config, ""), nil
+	}
+	var authorization string
+	err := sm.Any(ctx, g, func(ctx context.Context, _ string, caller session.Caller) error {
+		dt, err := secrets.GetSecret(ctx, caller, secretID)
+		if err != nil {
+			return err
+		}
+		authorization = string(dt)
+		if config.AuthTokenSecret != "" {
+			authorization = "Bearer " + authorization
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve http cache auth secret %s", secretID)
+	}
+	return NewClient(config, authorization), nil
+}
+
+func (c *Client) manifestURL(name string) string {
+	return c.baseURL.JoinPath(c.manifestsPrefix + name).String()
+}
+
+func (c *Client) blobURL(dgst digest.Digest) string {
+	return c.baseURL.JoinPath(c.blobsPrefix + dgst.String()).String()
+}
+
+func (c *Client) newRequest(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	return req, nil
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resp, nil
+}
+
+func (c *Client) exists(ctx context.Context, u string) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	default:
+		return false, errors.Errorf("unexpected status code for HEAD %s: %s", u, resp.Status)
+	}
+}
+
+func (c *Client) put(ctx context.Context, u string, body io.Reader, size int64) error {
+	return c.putIfMatch(ctx, u, body, size, "", false)
+}
+
+// putIfMatch uploads an object only if the current ETag of the object matches
+// etag, or if the object does not exist yet for an empty etag. Without
+// conditional the object is always written. A failed precondition returns
+// remotecache.ErrMergeConflict.
+func (c *Client) putIfMatch(ctx context.Context, u string, body io.Reader, size int64, etag string, conditional bool) error {
+	req, err := c.newRequest(ctx, http.MethodPut, u, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if conditional {
+		if etag == "" {
+			req.Header.Set("If-None-Match", "*")
+		} else {
+			req.Header.Set("If-Match", etag)
+		}
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return errors.WithStack(remotecache.ErrMergeConflict)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code for PUT %s: %s", u, resp.Status)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, u string) (io.ReadCloser, error) {
+	resp, err := c.getResponse(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) getResponse(ctx context.Context, u string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errors.Wrapf(cerrdefs.ErrNotFound, "GET %s", u)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, errors.Errorf("unexpected status code for GET %s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *Client) getManifest(ctx context.Context, u string, config *cacheimporttypes.CacheConfig) (bool, error) {
+	found, _, err := c.getManifestWithETag(ctx, u, config)
+	return found, err
+}
+
+func (c *Client) getManifestWithETag(ctx context.Context, u string, config *cacheimporttypes.CacheConfig) (bool, string, error) {
+	resp, err := c.getResponse(ctx, u)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return false, "", nil
+		}
+		return false, "", err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	if err := decoder.Decode(config); err != nil {
+		return false, "", errors.WithStack(err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return false, "", errors.Errorf("unexpected data after JSON object")
+	}
+	return true, resp.Header.Get("ETag"), nil
+}
+
+// Fetch implements remotes.Fetcher for cache blobs.
+func (c *Client) Fetch(ctx context.Context, desc ocispecs.Descriptor) (io.ReadCloser, error) {
+	u := c.blobURL(desc.Digest)
+	bklog.G(ctx).Debugf("reading layer from cache: %s", u)
+	return c.get(ctx, u)
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/moby/buildkit/cache/remotecache"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/contentutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	requests map[string]int
+	auth     string
+}
+
+func newTestServer(auth string) *testServer {
+	return &testServer{
+		objects:  map[string][]byte{},
+		requests: map[string]int{},
+		auth:     auth,
+	}
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[r.Method+" "+r.URL.Path]++
+	if s.auth != "" && r.Header.Get("Authorization") != s.auth {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		dt, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag(dt))
+		if r.Method == http.MethodGet {
+			w.Write(dt)
+		}
+	case http.MethodPut:
+		current, exists := s.objects[r.URL.Path]
+		if (r.Header.Get("If-None-Match") == "*" && exists) ||
+			(r.Header.Get("If-Match") != "" && (!exists || r.Header.Get("If-Match") != etag(current))) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		dt, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[r.URL.Path] = dt
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func etag(dt []byte) string {
+	return `"` + digest.FromBytes(dt).Encoded() + `"`
+}
+
+func TestGetConfig(t *testing.T) {
+	_, err := getConfig(map[string]string{})
+	require.ErrorContains(t, err, "url not set")
+
+	_, err = getConfig(map[string]string{"url": "ftp://example.com"})
+	require.ErrorContains(t, err, "unsupported url scheme")
+
+	_, err = getConfig(map[string]string{"url": "http://example.com", "upload_parallelism": "0"})
+	require.ErrorContains(t, err, "upload_parallelism")
+
+	_, err = getConfig(map[string]string{"url": "http://example.com", "auth_header_secret": "a", "auth_token_secret": "b"})
+	require.ErrorContains(t, err, "only one of")
+
+	cfg, err := getConfig(map[string]string{"url": "https://example.com/cache", "name": "foo;bar"})
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/cache/", cfg.URL.String())
+	require.Equal(t, []string{"foo", "bar"}, cfg.Names)
+	require.Equal(t, "manifests/", cfg.ManifestsPrefix)
+	require.Equal(t, "blobs/", cfg.BlobsPrefix)
+	require.Equal(t, 4, cfg.UploadParallelism)
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.TODO()
+
+	srv := newTestServer("Bearer secret")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	cfg, err := getConfig(map[string]string{"url": ts.URL + "/cache", "name": "foo;bar"})
+	require.NoError(t, err)
+	client := NewClient(cfg, "Bearer secret")
+
+	buf := contentutil.NewBuffer()
+	desc := writeBlob(ctx, t, buf, []byte("layer0"))
+
+	exp := NewExporter(client, cfg).(*exporter)
+	rec, ok, err := exp.Add(digest.FromString("foo"), nil, []solver.CacheExportResult{{
+		CreatedAt: time.Now(),
+		Result: &solver.Remote{
+			Descriptors: []ocispecs.Descriptor{desc},
+			Provider:    buf,
+		},
+	}})
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = exp.Add(digest.FromString("bar"), [][]solver.CacheLink{{{Src: rec}}}, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = exp.Finalize(ctx)
+	require.NoError(t, err)
+
+	require.Contains(t, srv.objects, "/cache/blobs/"+desc.Digest.String())
+	require.Contains(t, srv.objects, "/cache/manifests/foo")
+	require.Contains(t, srv.objects, "/cache/manifests/bar")
+	require.Equal(t, 1, srv.requests["PUT /cache/blobs/"+desc.Digest.String()])
+
+	// exporting again does not upload existing blobs
+	_, err = exp.Finalize(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, srv.requests["PUT /cache/blobs/"+desc.Digest.String()])
+	require.Equal(t, 2, srv.requests["HEAD /cache/blobs/"+desc.Digest.String()])
+
+	imp := NewImporter(client, cfg).(*importer)
+	cc, err := imp.load(ctx)
+	require.NoError(t, err)
+
+	ccfg, descs, err := cc.Marshal(ctx)
+	require.NoError(t, err)
+	require.Len(t, ccfg.Layers, 1)
+	require.Len(t, ccfg.Records, 2)
+	require.Equal(t, desc.Digest, ccfg.Layers[0].Blob)
+
+	ra, err := descs[desc.Digest].ReaderAt(ctx, desc)
+	require.NoError(t, err)
+	defer ra.Close()
+	dt, err := io.ReadAll(content.NewReader(ra))
+	require.NoError(t, err)
+	require.Equal(t, "layer0", string(dt))
+
+	// missing manifest results in empty cache
+	imp = NewImporter(client, Config{
+		URL:             cfg.URL,
+		ManifestsPrefix: cfg.ManifestsPrefix,
+		BlobsPrefix:     cfg.BlobsPrefix,
+		Names:           []string{"missing"},
+	}).(*importer)
+	cc, err = imp.load(ctx)
+	require.NoError(t, err)
+	ccfg, _, err = cc.Marshal(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ccfg.Records)
+}
+
+func TestExportMerge(t *testing.T) {
+	ctx := context.TODO()
+
+	srv := newTestServer("")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	cfg, err := getConfig(map[string]string{"url": ts.URL, "merge": "true"})
+	require.NoError(t, err)
+	client := NewClient(cfg, "")
+	buf := contentutil.NewBuffer()
+
+	for _, name := range []string{"foo", "bar"} {
+		desc := writeBlob(ctx, t, buf, []byte(name))
+		exp := NewExporter(client, cfg).(*exporter)
+		_, _, err := exp.Add(digest.FromString(name), nil, []solver.CacheExportResult{{
+			CreatedAt: time.Now(),
+			Result: &solver.Remote{
+				Descriptors: []ocispecs.Descriptor{desc},
+				Provider:    buf,
+			},
+		}})
+		require.NoError(t, err)
+		_, err = exp.Finalize(ctx)
+		require.NoError(t, err)
+	}
+
+	cc, err := NewImporter(client, cfg).(*importer).load(ctx)
+	require.NoError(t, err)
+	ccfg, _, err := cc.Marshal(ctx)
+	require.NoError(t, err)
+	require.Len(t, ccfg.Layers, 2)
+	require.Len(t, ccfg.Records, 2)
+
+	// a concurrent update between read and write is detected
+	target := &mergeTarget{client: client, url: client.manifestURL("buildkit")}
+	_, version, err := target.Read(ctx)
+	require.NoError(t, err)
+	srv.objects["/manifests/buildkit"] = []byte("{}")
+	require.ErrorIs(t, target.Write(ctx, []byte("{}"), version), remotecache.ErrMergeConflict)
+}
+
+func TestUnauthorized(t *testing.T) {
+	ctx := context.TODO()
+
+	ts := httptest.NewServer(newTestServer("Bearer secret"))
+	defer ts.Close()
+
+	cfg, err := getConfig(map[string]string{"url": ts.URL})
+	require.NoError(t, err)
+
+	imp := NewImporter(NewClient(cfg, "Bearer wrong"), cfg).(*importer)
+	_, err = imp.load(ctx)
+	require.ErrorContains(t, err, "401")
+}
+
+func writeBlob(ctx context.Context, t *testing.T, cs content.Ingester, dt []byte) ocispecs.Descriptor {
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(len(dt)),
+		Annotations: map[string]string{
+			labels.LabelUncompressed: digest.FromString(strings.ToUpper(string(dt))).String(),
+		},
+	}
+	err := content.WriteBlob(ctx, cs, desc.Digest.String(), strings.NewReader(string(dt)), desc)
+	require.NoError(t, err)
+	return desc
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/containerd/containerd/v2/core/content"
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/moby/buildkit/cache/remotecache"
 	"github.com/moby/buildkit/session"
 	sessioncontent "github.com/moby/buildkit/session/content"
@@ -21,6 +22,7 @@ const (
 	attrDest             = "dest"
 	attrImageManifest    = "image-manifest"
 	attrOCIMediatypes    = "oci-mediatypes"
+	attrMergeDigest      = "merge_digest"
 	contentStoreIDPrefix = "local:"
 )
 
@@ -43,6 +45,10 @@ func ResolveCacheExporterFunc(sm *session.Manager) remotecache.ResolveCacheExpor
 		if err != nil {
 			return nil, err
 		}
+		merge, err := remotecache.ParseMergeConfig(attrs)
+		if err != nil {
+			return nil, err
+		}
 		ociMediatypes := true
 		if v, ok := attrs[attrOCIMediatypes]; ok {
 			b, err := strconv.ParseBool(v)
@@ -67,10 +73,33 @@ func ResolveCacheExporterFunc(sm *session.Manager) remotecache.ResolveCacheExpor
 		if err != nil {
 			return nil, err
 		}
+		if merge.Enabled {
+			src := &mergeSource{store: cs, dgst: digest.Digest(attrs[attrMergeDigest])}
+			return &exporter{remotecache.NewMergingExporter(cs, "", ociMediatypes, imageManifest, compressionConfig, src, merge.MergeOpt)}, nil
+		}
 		return &exporter{remotecache.NewExporter(cs, "", ociMediatypes, imageManifest, compressionConfig)}, nil
 	}
 }
 
+// mergeSource resolves the current cache manifest of a local cache directory.
+// The index.json of the directory is read and updated by the client, which
+// passes the digest of the tagged manifest when the build starts.
+type mergeSource struct {
+	store content.Store
+	dgst  digest.Digest
+}
+
+func (s *mergeSource) Resolve(ctx context.Context) (ocispecs.Descriptor, content.Provider, error) {
+	if s.dgst == "" {
+		return ocispecs.Descriptor{}, nil, errors.Wrap(cerrdefs.ErrNotFound, "no existing cache manifest")
+	}
+	info, err := s.store.Info(ctx, s.dgst)
+	if err != nil {
+		return ocispecs.Descriptor{}, nil, err
+	}
+	return ocispecs.Descriptor{Digest: s.dgst, Size: info.Size}, s.store, nil
+}
+
 // ResolveCacheImporterFunc for "local" cache importer.
 func ResolveCacheImporterFunc(sm *session.Manager) remotecache.ResolveCacheImporterFunc {
 	return func(ctx context.Context, g session.Group, attrs map[string]string) (remotecache.Importer, ocispecs.Descriptor, error) {
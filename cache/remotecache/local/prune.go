@@ -0,0 +1,135 @@
+package local
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	contentlocal "github.com/containerd/containerd/v2/plugins/content/local"
+	"github.com/moby/buildkit/cache/remotecache"
+	"github.com/moby/buildkit/client/ociindex"
+	"github.com/moby/buildkit/util/imageutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// NewPruneTarget returns a remotecache.PruneTarget for the cache directory
+// set by the dest (or src) attribute. Every manifest in index.json is a cache
+// manifest for pruning and removing it also removes all its tags.
+func NewPruneTarget(attrs map[string]string) (remotecache.PruneTarget, error) {
+	dir := attrs[attrDest]
+	if dir == "" {
+		dir = attrs[attrSrc]
+	}
+	if dir == "" {
+		return nil, errors.New("local cache prune requires dest")
+	}
+	cs, err := contentlocal.NewStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &pruneTarget{cs: cs, idx: ociindex.NewStoreIndex(dir)}, nil
+}
+
+type pruneTarget struct {
+	cs  content.Store
+	idx ociindex.StoreIndex
+}
+
+func (t *pruneTarget) Manifests(ctx context.Context) ([]remotecache.PruneObject, error) {
+	idx, err := t.idx.Read()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var objects []remotecache.PruneObject
+	seen := map[digest.Digest]struct{}{}
+	for _, m := range idx.Manifests {
+		if _, ok := seen[m.Digest]; ok {
+			continue
+		}
+		seen[m.Digest] = struct{}{}
+		obj := remotecache.PruneObject{
+			Key:  m.Digest.String(),
+			Size: m.Size,
+		}
+		// the modification time of the manifest blob is when it was last
+		// exported, a missing blob leaves the manifest expired
+		if info, err := t.cs.Info(ctx, m.Digest); err == nil {
+			obj.LastModified = info.UpdatedAt
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func (t *pruneTarget) Blobs(ctx context.Context) ([]remotecache.PruneObject, error) {
+	var objects []remotecache.PruneObject
+	err := t.cs.Walk(ctx, func(info content.Info) error {
+		objects = append(objects, remotecache.PruneObject{
+			Key:          info.Digest.String(),
+			Size:         info.Size,
+			LastModified: info.UpdatedAt,
+		})
+		return nil
+	})
+	return objects, err
+}
+
+func (t *pruneTarget) References(ctx context.Context, manifest remotecache.PruneObject) ([]string, error) {
+	dgst, err := digest.Parse(manifest.Key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := t.cs.Info(ctx, dgst)
+	if err != nil {
+		return nil, err
+	}
+	desc := ocispecs.Descriptor{
+		Digest: dgst,
+		Size:   info.Size,
+	}
+	dt, err := content.ReadBlob(ctx, t.cs, desc)
+	if err != nil {
+		return nil, err
+	}
+	desc.MediaType, err = imageutil.DetectManifestBlobMediaType(dt)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var refs []string
+	childrenHandler := images.ChildrenHandler(t.cs)
+	handler := images.HandlerFunc(func(ctx context.Context, desc ocispecs.Descriptor) ([]ocispecs.Descriptor, error) {
+		mu.Lock()
+		refs = append(refs, desc.Digest.String())
+		mu.Unlock()
+		return childrenHandler(ctx, desc)
+	})
+	if err := images.Dispatch(ctx, handler, nil, desc); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+func (t *pruneTarget) DeleteManifest(ctx context.Context, manifest remotecache.PruneObject) error {
+	dgst, err := digest.Parse(manifest.Key)
+	if err != nil {
+		return err
+	}
+	return t.idx.Remove(dgst)
+}
+
+func (t *pruneTarget) DeleteBlob(ctx context.Context, blob remotecache.PruneObject) error {
+	dgst, err := digest.Parse(blob.Key)
+	if err != nil {
+		return err
+	}
+	return t.cs.Delete(ctx, dgst)
+}
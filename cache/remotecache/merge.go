@@ -0,0 +1,184 @@
+package remotecache
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	cerrdefs "github.com/containerd/errdefs"
+	v1 "github.com/moby/buildkit/cache/remotecache/v1"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/progress"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	attrMerge           = "merge"
+	attrMergeMaxRecords = "merge_max_records"
+	attrMergeMaxAge     = "merge_max_age"
+
+	maxMergeAttempts = 10
+)
+
+// ErrMergeConflict is returned by MergeTarget.Write when the cache manifest
+// was modified after it was read.
+var ErrMergeConflict = errors.New("cache manifest was modified concurrently")
+
+// MergeConfig configures merging the exported cache into an existing cache
+// manifest instead of replacing it.
+type MergeConfig struct {
+	Enabled bool
+	v1.MergeOpt
+}
+
+// ParseMergeConfig parses the merge attributes of a cache exporter.
+func ParseMergeConfig(attrs map[string]string) (MergeConfig, error) {
+	var mc MergeConfig
+	if v, ok := attrs[attrMerge]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return mc, errors.Wrapf(err, "failed to parse %s", attrMerge)
+		}
+		mc.Enabled = b
+	}
+	if v, ok := attrs[attrMergeMaxRecords]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return mc, errors.Errorf("%s must be a non-negative integer", attrMergeMaxRecords)
+		}
+		mc.MaxRecords = n
+	}
+	if v, ok := attrs[attrMergeMaxAge]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return mc, errors.Wrapf(err, "failed to parse %s", attrMergeMaxAge)
+		}
+		mc.MaxAge = d
+	}
+	if !mc.Enabled && (mc.MaxRecords != 0 || mc.MaxAge != 0) {
+		return mc, errors.Errorf("%s and %s require %s=true", attrMergeMaxRecords, attrMergeMaxAge, attrMerge)
+	}
+	return mc, nil
+}
+
+// MergeTarget is a cache manifest that supports conditional writes.
+type MergeTarget interface {
+	// Read returns the current cache config and an opaque version of it. A
+	// nil config is returned if the manifest does not exist.
+	Read(ctx context.Context) (*cacheimporttypes.CacheConfig, string, error)
+	// Write stores the manifest if its version still matches the one
+	// returned by Read, or if it still does not exist for an empty version.
+	// It returns ErrMergeConflict otherwise.
+	Write(ctx context.Context, dt []byte, version string) error
+}
+
+// MergeSource resolves the cache manifest that an exporter created with
+// NewMergingExporter merges into.
+type MergeSource interface {
+	// Resolve returns the descriptor of the current cache manifest and a
+	// provider for its blobs. An error matching cerrdefs.ErrNotFound is
+	// returned if the manifest does not exist.
+	Resolve(ctx context.Context) (ocispecs.Descriptor, content.Provider, error)
+}
+
+// readMergeSource reads the cache config and the layer descriptors of the
+// cache manifest of src. A nil config is returned if the manifest does not
+// exist.
+func readMergeSource(ctx context.Context, src MergeSource) (ocispecs.Descriptor, *cacheimporttypes.CacheConfig, map[digest.Digest]ocispecs.Descriptor, error) {
+	desc, provider, err := src.Resolve(ctx)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return ocispecs.Descriptor{}, nil, nil, nil
+		}
+		return ocispecs.Descriptor{}, nil, nil, err
+	}
+	dt, err := readBlob(ctx, provider, desc)
+	if err != nil {
+		return ocispecs.Descriptor{}, nil, nil, err
+	}
+
+	var mfst struct {
+		MediaType string                `json:"mediaType,omitempty"`
+		Config    ocispecs.Descriptor   `json:"config"`
+		Layers    []ocispecs.Descriptor `json:"layers"`
+		Manifests []ocispecs.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return ocispecs.Descriptor{}, nil, nil, errors.WithStack(err)
+	}
+
+	layers := map[digest.Digest]ocispecs.Descriptor{}
+	var configDesc ocispecs.Descriptor
+	if mfst.Config.MediaType == cacheimporttypes.CacheConfigMediaTypeV0 {
+		configDesc = mfst.Config
+	}
+	for _, m := range append(mfst.Manifests, mfst.Layers...) {
+		if m.MediaType == cacheimporttypes.CacheConfigMediaTypeV0 {
+			configDesc = m
+			continue
+		}
+		layers[m.Digest] = m
+	}
+	if configDesc.Digest == "" {
+		return ocispecs.Descriptor{}, nil, nil, errors.Errorf("%s is not a cache manifest and cannot be merged", desc.Digest)
+	}
+
+	dt, err = readBlob(ctx, provider, configDesc)
+	if err != nil {
+		return ocispecs.Descriptor{}, nil, nil, err
+	}
+	var config cacheimporttypes.CacheConfig
+	if err := json.Unmarshal(dt, &config); err != nil {
+		return ocispecs.Descriptor{}, nil, nil, errors.WithStack(err)
+	}
+	return desc, &config, layers, nil
+}
+
+// WriteMerged merges config with the manifest of target and writes the
+// result back. If another writer modified the manifest in between, the merge
+// is retried with the new manifest.
+func WriteMerged(ctx context.Context, target MergeTarget, config *cacheimporttypes.CacheConfig, opt v1.MergeOpt) error {
+	return RetryMerge(ctx, func(ctx context.Context) error {
+		existing, version, err := target.Read(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to read cache manifest for merging")
+		}
+		merged, err := v1.MergeConfig(opt, existing, config)
+		if err != nil {
+			return errors.Wrap(err, "failed to merge cache manifest")
+		}
+		dt, err := json.Marshal(merged)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return target.Write(ctx, dt, version)
+	})
+}
+
+// RetryMerge calls fn until it does not return ErrMergeConflict, with a
+// growing and jittered delay between the attempts so that concurrent writers
+// do not keep conflicting with each other.
+func RetryMerge(ctx context.Context, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if !errors.Is(err, ErrMergeConflict) {
+			return err
+		}
+		if attempt >= maxMergeAttempts {
+			return errors.Wrapf(err, "giving up after %d attempts", attempt)
+		}
+		bklog.G(ctx).Debugf("retrying cache manifest merge after conflict (attempt %d)", attempt)
+		progress.OneOff(ctx, "cache manifest was modified concurrently, merging again")(nil)
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(time.Duration(attempt)*100*time.Millisecond + rand.N(100*time.Millisecond)):
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package remotecache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+const pruneParallelism = 8
+
+// PruneBlobGracePeriod is the minimum age of an unreferenced blob before it
+// is deleted. Exports upload their blobs before the manifest that references
+// them, so younger blobs may belong to an export that is still running.
+const PruneBlobGracePeriod = time.Hour
+
+// PruneObject is a manifest or blob stored in a remote cache.
+type PruneObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// PruneTarget provides access to the manifests and blobs of a remote cache
+// for PruneRemote.
+type PruneTarget interface {
+	// Manifests lists the cache manifests.
+	Manifests(ctx context.Context) ([]PruneObject, error)
+	// Blobs lists the cache blobs.
+	Blobs(ctx context.Context) ([]PruneObject, error)
+	// References returns the keys of the blobs referenced by a manifest.
+	References(ctx context.Context, manifest PruneObject) ([]string, error)
+	DeleteManifest(ctx context.Context, manifest PruneObject) error
+	DeleteBlob(ctx context.Context, blob PruneObject) error
+}
+
+type PruneOpt struct {
+	// KeepDuration keeps manifests and blobs modified more recently than this
+	// duration. Manifests are only deleted if it is set. Blobs are kept for at
+	// least PruneBlobGracePeriod.
+	KeepDuration time.Duration
+	// DryRun only reports the objects that would be deleted.
+	DryRun bool
+}
+
+type PruneResult struct {
+	// Manifests and Blobs are the deleted objects, or the objects that would
+	// be deleted for a dry run.
+	Manifests     []PruneObject
+	Blobs         []PruneObject
+	KeptManifests int
+	KeptBlobs     int
+}
+
+// Size returns the total size of the deleted objects.
+func (r *PruneResult) Size() int64 {
+	var size int64
+	for _, o := range r.Manifests {
+		size += o.Size
+	}
+	for _, o := range r.Blobs {
+		size += o.Size
+	}
+	return size
+}
+
+// PruneRemote deletes the manifests of a remote cache that are older than
+// opt.KeepDuration and the blobs that are not referenced by any remaining
+// manifest. Manifests are deleted before blobs so that an interrupted prune
+// never leaves a manifest with missing blobs behind.
+func PruneRemote(ctx context.Context, target PruneTarget, opt PruneOpt) (*PruneResult, error) {
+	now := time.Now()
+	cutoff := now.Add(-opt.KeepDuration)
+	blobCutoff := now.Add(-max(opt.KeepDuration, PruneBlobGracePeriod))
+
+	manifests, err := target.Manifests(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list cache manifests")
+	}
+
+	res := &PruneResult{}
+	var keep []PruneObject
+	for _, m := range manifests {
+		if opt.KeepDuration > 0 && m.LastModified.Before(cutoff) {
+			res.Manifests = append(res.Manifests, m)
+		} else {
+			keep = append(keep, m)
+		}
+	}
+	res.KeptManifests = len(keep)
+
+	var mu sync.Mutex
+	referenced := map[string]struct{}{}
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(pruneParallelism)
+	for _, m := range keep {
+		eg.Go(func() error {
+			refs, err := target.References(egCtx, m)
+			if err != nil {
+				// never delete blobs that an unreadable manifest may reference
+				return errors.Wrapf(err, "failed to read cache manifest %s", m.Key)
+			}
+			mu.Lock()
+			for _, r := range refs {
+				referenced[r] = struct{}{}
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	// blobs are listed after the manifests were read, so blobs of manifests
+	// written in the meantime are new enough to be kept by the grace period
+	blobs, err := target.Blobs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list cache blobs")
+	}
+	for _, b := range blobs {
+		if _, ok := referenced[b.Key]; !ok && b.LastModified.Before(blobCutoff) {
+			res.Blobs = append(res.Blobs, b)
+		} else {
+			res.KeptBlobs++
+		}
+	}
+
+	if opt.DryRun {
+		return res, nil
+	}
+
+	for _, m := range res.Manifests {
+		if err := target.DeleteManifest(ctx, m); err != nil {
+			return nil, errors.Wrapf(err, "failed to delete cache manifest %s", m.Key)
+		}
+	}
+	eg, egCtx = errgroup.WithContext(ctx)
+	eg.SetLimit(pruneParallelism)
+	for _, b := range res.Blobs {
+		eg.Go(func() error {
+			return errors.Wrapf(target.DeleteBlob(egCtx, b), "failed to delete cache blob %s", b.Key)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
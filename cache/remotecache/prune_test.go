@@ -0,0 +1,163 @@
+package remotecache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testPruneTarget struct {
+	mu        sync.Mutex
+	manifests map[string]PruneObject
+	blobs     map[string]PruneObject
+	refs      map[string][]string
+	// onReferences is called when a manifest is read
+	onReferences func()
+}
+
+func (t *testPruneTarget) Manifests(ctx context.Context) ([]PruneObject, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var objects []PruneObject
+	for _, o := range t.manifests {
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func (t *testPruneTarget) Blobs(ctx context.Context) ([]PruneObject, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var objects []PruneObject
+	for _, o := range t.blobs {
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func (t *testPruneTarget) References(ctx context.Context, manifest PruneObject) ([]string, error) {
+	if t.onReferences != nil {
+		t.onReferences()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.refs[manifest.Key], nil
+}
+
+func (t *testPruneTarget) DeleteManifest(ctx context.Context, manifest PruneObject) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.manifests, manifest.Key)
+	return nil
+}
+
+func (t *testPruneTarget) DeleteBlob(ctx context.Context, blob PruneObject) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.blobs, blob.Key)
+	return nil
+}
+
+func newTestPruneTarget() *testPruneTarget {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	obj := func(key string, t time.Time) PruneObject {
+		return PruneObject{Key: key, Size: 10, LastModified: t}
+	}
+	return &testPruneTarget{
+		manifests: map[string]PruneObject{
+			"old": obj("old", old),
+			"new": obj("new", now),
+		},
+		blobs: map[string]PruneObject{
+			"a":        obj("a", old),
+			"shared":   obj("shared", old),
+			"b":        obj("b", old),
+			"orphan":   obj("orphan", old),
+			"inflight": obj("inflight", now),
+		},
+		refs: map[string][]string{
+			"old": {"a", "shared"},
+			"new": {"b", "shared"},
+		},
+	}
+}
+
+func keys(objects []PruneObject) []string {
+	var out []string
+	for _, o := range objects {
+		out = append(out, o.Key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestPruneRemote(t *testing.T) {
+	ctx := context.TODO()
+
+	target := newTestPruneTarget()
+	res, err := PruneRemote(ctx, target, PruneOpt{})
+	require.NoError(t, err)
+	require.Empty(t, res.Manifests)
+	require.Equal(t, 2, res.KeptManifests)
+	require.Equal(t, []string{"orphan"}, keys(res.Blobs))
+	require.Equal(t, 4, res.KeptBlobs)
+	require.Len(t, target.blobs, 4)
+	require.Contains(t, target.blobs, "inflight")
+
+	target = newTestPruneTarget()
+	res, err = PruneRemote(ctx, target, PruneOpt{KeepDuration: 24 * time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, []string{"old"}, keys(res.Manifests))
+	require.Equal(t, []string{"a", "orphan"}, keys(res.Blobs))
+	require.Equal(t, int64(30), res.Size())
+	require.Len(t, target.manifests, 1)
+	require.Contains(t, target.manifests, "new")
+	require.Len(t, target.blobs, 3)
+	require.Contains(t, target.blobs, "inflight")
+
+	target = newTestPruneTarget()
+	res, err = PruneRemote(ctx, target, PruneOpt{KeepDuration: 24 * time.Hour, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"old"}, keys(res.Manifests))
+	require.Equal(t, []string{"a", "orphan"}, keys(res.Blobs))
+	require.Len(t, target.manifests, 2)
+	require.Len(t, target.blobs, 5)
+
+	// unreferenced blobs younger than the grace period are always kept
+	target = newTestPruneTarget()
+	target.blobs["recent"] = PruneObject{Key: "recent", Size: 10, LastModified: time.Now().Add(-PruneBlobGracePeriod / 2)}
+	res, err = PruneRemote(ctx, target, PruneOpt{KeepDuration: time.Minute})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "orphan"}, keys(res.Blobs))
+	require.Contains(t, target.blobs, "inflight")
+	require.Contains(t, target.blobs, "recent")
+}
+
+func TestPruneRemoteConcurrentExport(t *testing.T) {
+	ctx := context.TODO()
+
+	// an export uploads its blob and writes its manifest after the manifests
+	// were listed, the blob must not be deleted
+	target := newTestPruneTarget()
+	var once sync.Once
+	target.onReferences = func() {
+		once.Do(func() {
+			target.mu.Lock()
+			defer target.mu.Unlock()
+			now := time.Now()
+			target.blobs["exported"] = PruneObject{Key: "exported", Size: 10, LastModified: now}
+			target.manifests["exporting"] = PruneObject{Key: "exporting", Size: 10, LastModified: now}
+			target.refs["exporting"] = []string{"exported"}
+		})
+	}
+	res, err := PruneRemote(ctx, target, PruneOpt{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"orphan"}, keys(res.Blobs))
+	require.Contains(t, target.blobs, "exported")
+	require.Contains(t, target.blobs, "inflight")
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/remotes"
 	"github.com/containerd/containerd/v2/core/remotes/docker"
 	"github.com/containerd/containerd/v2/core/snapshots"
 	"github.com/containerd/containerd/v2/pkg/snapshotters"
@@ -96,10 +97,36 @@ func ResolveCacheExporterFunc(sm *session.Manager, hosts docker.RegistryHosts) r
 		if err != nil {
 			return nil, err
 		}
+		merge, err := remotecache.ParseMergeConfig(attrs)
+		if err != nil {
+			return nil, err
+		}
+		if merge.Enabled {
+			src := &mergeSource{resolver: remote, ref: refString}
+			return &exporter{remotecache.NewMergingExporter(contentutil.FromPusher(pusher), refString, ociMediatypes, imageManifest, compressionConfig, src, merge.MergeOpt)}, nil
+		}
 		return &exporter{remotecache.NewExporter(contentutil.FromPusher(pusher), refString, ociMediatypes, imageManifest, compressionConfig)}, nil
 	}
 }
 
+// mergeSource resolves the current cache manifest of a registry ref.
+type mergeSource struct {
+	resolver remotes.Resolver
+	ref      string
+}
+
+func (s *mergeSource) Resolve(ctx context.Context) (ocispecs.Descriptor, content.Provider, error) {
+	name, desc, err := s.resolver.Resolve(ctx, s.ref)
+	if err != nil {
+		return ocispecs.Descriptor{}, nil, err
+	}
+	fetcher, err := s.resolver.Fetcher(ctx, name)
+	if err != nil {
+		return ocispecs.Descriptor{}, nil, err
+	}
+	return desc, contentutil.FromFetcher(limited.Default.WrapFetcher(fetcher, s.ref)), nil
+}
+
 func ResolveCacheImporterFunc(sm *session.Manager, cs content.Store, hosts docker.RegistryHosts) remotecache.ResolveCacheImporterFunc {
 	return func(ctx context.Context, g session.Group, attrs map[string]string) (remotecache.Importer, ocispecs.Descriptor, error) {
 		ref, err := canonicalizeRef(attrs[attrRef])
@@ -0,0 +1,100 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/moby/buildkit/cache/remotecache"
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// NewPruneTarget returns a remotecache.PruneTarget for the s3 cache described
+// by attrs, which are the same attributes as for the cache exporter.
+func NewPruneTarget(ctx context.Context, attrs map[string]string) (remotecache.PruneTarget, error) {
+	config, err := getConfig(attrs)
+	if err != nil {
+		return nil, err
+	}
+	s3Client, err := newS3Client(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &pruneTarget{s3Client: s3Client}, nil
+}
+
+type pruneTarget struct {
+	s3Client *s3Client
+}
+
+func (t *pruneTarget) list(ctx context.Context, prefix string, filter func(key string) bool) ([]remotecache.PruneObject, error) {
+	var objects []remotecache.PruneObject
+	paginator := s3.NewListObjectsV2Paginator(t.s3Client, &s3.ListObjectsV2Input{
+		Bucket: &t.s3Client.bucket,
+		Prefix: &prefix,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if !filter(key) {
+				continue
+			}
+			objects = append(objects, remotecache.PruneObject{
+				Key:          key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (t *pruneTarget) Manifests(ctx context.Context) ([]remotecache.PruneObject, error) {
+	prefix := t.s3Client.prefix + t.s3Client.manifestsPrefix
+	return t.list(ctx, prefix, func(key string) bool {
+		// skip nested keys, e.g. blobs stored below the manifests prefix
+		return !strings.Contains(strings.TrimPrefix(key, prefix), "/")
+	})
+}
+
+func (t *pruneTarget) Blobs(ctx context.Context) ([]remotecache.PruneObject, error) {
+	prefix := t.s3Client.prefix + t.s3Client.blobsPrefix
+	return t.list(ctx, prefix, func(key string) bool {
+		_, err := digest.Parse(strings.TrimPrefix(key, prefix))
+		return err == nil
+	})
+}
+
+func (t *pruneTarget) References(ctx context.Context, manifest remotecache.PruneObject) ([]string, error) {
+	var config cacheimporttypes.CacheConfig
+	if _, err := t.s3Client.getManifest(ctx, manifest.Key, &config); err != nil {
+		return nil, err
+	}
+	refs := make([]string, 0, len(config.Layers))
+	for _, l := range config.Layers {
+		refs = append(refs, t.s3Client.blobKey(l.Blob))
+	}
+	return refs, nil
+}
+
+func (t *pruneTarget) DeleteManifest(ctx context.Context, manifest remotecache.PruneObject) error {
+	return t.delete(ctx, manifest.Key)
+}
+
+func (t *pruneTarget) DeleteBlob(ctx context.Context, blob remotecache.PruneObject) error {
+	return t.delete(ctx, blob.Key)
+}
+
+func (t *pruneTarget) delete(ctx context.Context, key string) error {
+	_, err := t.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &t.s3Client.bucket,
+		Key:    &key,
+	})
+	return err
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/middleware"
 	"github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/pkg/labels"
@@ -71,6 +72,7 @@ type Config struct {
 	DisableAcceptEncoding bool
 	RetryMode             aws.RetryMode
 	RetryMaxAttempts      int
+	Merge                 remotecache.MergeConfig
 }
 
 func getConfig(attrs map[string]string) (Config, error) {
@@ -180,6 +182,11 @@ func getConfig(attrs map[string]string) (Config, error) {
 		}
 	}
 
+	merge, err := remotecache.ParseMergeConfig(attrs)
+	if err != nil {
+		return Config{}, err
+	}
+
 	return Config{
 		Bucket:                bucket,
 		Region:                region,
@@ -197,6 +204,7 @@ func getConfig(attrs map[string]string) (Config, error) {
 		DisableAcceptEncoding: disableAcceptEncoding,
 		RetryMode:             retryMode,
 		RetryMaxAttempts:      retryMaxAttempts,
+		Merge:                 merge,
 	}, nil
 }
 
@@ -332,13 +340,54 @@ func (e *exporter) Finalize(ctx context.Context) (map[string]string, error) {
 	}
 
 	for _, name := range e.config.Names {
-		if err := e.s3Client.saveMutableAt(ctx, e.s3Client.manifestKey(name), bytes.NewReader(dt)); err != nil {
+		key := e.s3Client.manifestKey(name)
+		if e.config.Merge.Enabled {
+			err = remotecache.WriteMerged(ctx, &mergeTarget{s3Client: e.s3Client, key: key}, cacheConfig, e.config.Merge.MergeOpt)
+		} else {
+			err = e.s3Client.saveMutableAt(ctx, key, bytes.NewReader(dt))
+		}
+		if err != nil {
 			return nil, errors.Wrapf(err, "error writing manifest: %s", name)
 		}
 	}
 	return nil, nil
 }
 
+// mergeTarget updates a manifest with S3 conditional writes.
+type mergeTarget struct {
+	s3Client *s3Client
+	key      string
+}
+
+func (t *mergeTarget) Read(ctx context.Context) (*cacheimporttypes.CacheConfig, string, error) {
+	var config cacheimporttypes.CacheConfig
+	found, etag, err := t.s3Client.getManifestWithETag(ctx, t.key, &config)
+	if err != nil || !found {
+		return nil, "", err
+	}
+	return &config, etag, nil
+}
+
+func (t *mergeTarget) Write(ctx context.Context, dt []byte, etag string) error {
+	input := &s3.PutObjectInput{
+		Bucket: &t.s3Client.bucket,
+		Key:    &t.key,
+		Body:   bytes.NewReader(dt),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	if _, err := t.s3Client.PutObject(ctx, input); err != nil {
+		if isPreconditionFailed(err) {
+			return errors.WithStack(remotecache.ErrMergeConflict)
+		}
+		return err
+	}
+	return nil
+}
+
 // ResolveCacheImporterFunc for s3 cache importer.
 func ResolveCacheImporterFunc() remotecache.ResolveCacheImporterFunc {
 	return func(ctx context.Context, _ session.Group, attrs map[string]string) (remotecache.Importer, ocispecs.Descriptor, error) {
@@ -491,6 +540,11 @@ func newS3Client(ctx context.Context, config Config) (*s3Client, error) {
 }
 
 func (s3Client *s3Client) getManifest(ctx context.Context, key string, config *cacheimporttypes.CacheConfig) (bool, error) {
+	found, _, err := s3Client.getManifestWithETag(ctx, key, config)
+	return found, err
+}
+
+func (s3Client *s3Client) getManifestWithETag(ctx context.Context, key string, config *cacheimporttypes.CacheConfig) (bool, string, error) {
 	input := &s3.GetObjectInput{
 		Bucket: &s3Client.bucket,
 		Key:    &key,
@@ -499,21 +553,21 @@ func (s3Client *s3Client) getManifest(ctx context.Context, key string, config *c
 	output, err := s3Client.GetObject(ctx, input)
 	if err != nil {
 		if isNotFound(err) {
-			return false, nil
+			return false, "", nil
 		}
-		return false, err
+		return false, "", err
 	}
 	defer output.Body.Close()
 
 	decoder := json.NewDecoder(output.Body)
 	if err := decoder.Decode(config); err != nil {
-		return false, errors.WithStack(err)
+		return false, "", errors.WithStack(err)
 	}
 	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
-		return false, errors.Errorf("unexpected data after JSON object")
+		return false, "", errors.Errorf("unexpected data after JSON object")
 	}
 
-	return true, nil
+	return true, aws.ToString(output.ETag), nil
 }
 
 func (s3Client *s3Client) getReader(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
@@ -666,6 +720,17 @@ func (s3Client *s3Client) blobKey(dgst digest.Digest) string {
 	return s3Client.prefix + s3Client.blobsPrefix + dgst.String()
 }
 
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return true
+		}
+	}
+	return false
+}
+
 func isNotFound(err error) bool {
 	var nf *s3types.NotFound
 	var nsk *s3types.NoSuchKey
@@ -0,0 +1,386 @@
+package cacheimport
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// MergeOpt controls how the merged cache config is trimmed.
+type MergeOpt struct {
+	// MaxRecords is the maximum number of records with results to keep.
+	// Records with the oldest results are dropped first. Zero means no limit.
+	MaxRecords int
+	// MaxAge drops results that were created longer ago than this duration.
+	// Zero means no limit.
+	MaxAge time.Duration
+}
+
+// MergeConfig merges the layers and records of multiple cache configs into a
+// single config. Layers are deduplicated by their blob and parent chain and
+// records by their digest and inputs, so that merging a config with itself is
+// a no-op. Records present in several configs keep all their distinct
+// results, e.g. for different platforms, and the newest creation time of
+// results that point to the same layers. The result is trimmed according to opt and normalized in the same way
+// as CacheChains.Marshal.
+func MergeConfig(opt MergeOpt, configs ...*cacheimporttypes.CacheConfig) (*cacheimporttypes.CacheConfig, error) {
+	m := &configMerger{
+		layersByKey:  map[digest.Digest]int{},
+		recordsByKey: map[digest.Digest]int{},
+	}
+	for _, cfg := range configs {
+		if cfg == nil {
+			continue
+		}
+		if err := m.add(cfg); err != nil {
+			return nil, err
+		}
+	}
+	cc := &cacheimporttypes.CacheConfig{
+		Layers:  m.layers,
+		Records: m.records,
+	}
+	trimConfig(cc, opt, time.Now())
+	sortConfig(cc)
+	sortResults(cc)
+	return cc, nil
+}
+
+type configMerger struct {
+	layers       []cacheimporttypes.CacheLayer
+	layersByKey  map[digest.Digest]int
+	records      []cacheimporttypes.CacheRecord
+	recordsByKey map[digest.Digest]int
+}
+
+type mergeState struct {
+	cfg       *cacheimporttypes.CacheConfig
+	layerKeys map[int]digest.Digest
+	layerIdx  map[int]int
+	recordIdx map[int]int
+	recordKey map[int]digest.Digest
+}
+
+func (m *configMerger) add(cfg *cacheimporttypes.CacheConfig) error {
+	st := &mergeState{
+		cfg:       cfg,
+		layerKeys: map[int]digest.Digest{},
+		layerIdx:  map[int]int{},
+		recordIdx: map[int]int{},
+		recordKey: map[int]digest.Digest{},
+	}
+	for i := range cfg.Records {
+		if _, err := m.addRecord(st, i, map[int]struct{}{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLayer adds the layer with index idx in the source config, together with
+// its parents, and returns its index in the merged config.
+func (m *configMerger) addLayer(st *mergeState, idx int, visited map[int]struct{}) (int, error) {
+	if i, ok := st.layerIdx[idx]; ok {
+		return i, nil
+	}
+	if idx < 0 || idx >= len(st.cfg.Layers) {
+		return 0, errors.Errorf("invalid layer index %d", idx)
+	}
+	if _, ok := visited[idx]; ok {
+		return 0, errors.Errorf("invalid looping layer")
+	}
+	visited[idx] = struct{}{}
+
+	l := st.cfg.Layers[idx]
+	parent := -1
+	key := l.Blob
+	if l.ParentIndex != -1 {
+		var err error
+		parent, err = m.addLayer(st, l.ParentIndex, visited)
+		if err != nil {
+			return 0, err
+		}
+		key = digest.FromString(st.layerKeys[l.ParentIndex].String() + "/" + l.Blob.String())
+	}
+	st.layerKeys[idx] = key
+
+	i, ok := m.layersByKey[key]
+	if !ok {
+		i = len(m.layers)
+		m.layers = append(m.layers, cacheimporttypes.CacheLayer{
+			Blob:        l.Blob,
+			ParentIndex: parent,
+			Annotations: l.Annotations,
+		})
+		m.layersByKey[key] = i
+	} else if m.layers[i].Annotations == nil {
+		m.layers[i].Annotations = l.Annotations
+	}
+	st.layerIdx[idx] = i
+	return i, nil
+}
+
+// addRecord adds the record with index idx in the source config, together
+// with the records it links to, and returns its index in the merged config.
+func (m *configMerger) addRecord(st *mergeState, idx int, visited map[int]struct{}) (int, error) {
+	if i, ok := st.recordIdx[idx]; ok {
+		return i, nil
+	}
+	if idx < 0 || idx >= len(st.cfg.Records) {
+		return 0, errors.Errorf("invalid record ID: %d", idx)
+	}
+	if _, ok := visited[idx]; ok {
+		return 0, errors.Errorf("invalid looping record")
+	}
+	visited[idx] = struct{}{}
+
+	rec := st.cfg.Records[idx]
+	inputs := make([][]cacheimporttypes.CacheInput, len(rec.Inputs))
+	var sb strings.Builder
+	sb.WriteString(rec.Digest.String())
+	for i, ins := range rec.Inputs {
+		keys := make([]string, 0, len(ins))
+		for _, in := range ins {
+			link, err := m.addRecord(st, in.LinkIndex, visited)
+			if err != nil {
+				return 0, err
+			}
+			inputs[i] = append(inputs[i], cacheimporttypes.CacheInput{
+				Selector:  in.Selector,
+				LinkIndex: link,
+			})
+			keys = append(keys, in.Selector+"@"+st.recordKey[in.LinkIndex].String())
+		}
+		slices.Sort(keys)
+		sb.WriteString("|" + strconv.Itoa(i) + ":" + strings.Join(keys, ","))
+	}
+	key := digest.FromString(sb.String())
+	st.recordKey[idx] = key
+
+	var results []cacheimporttypes.CacheResult
+	for _, res := range rec.Results {
+		l, err := m.addLayer(st, res.LayerIndex, map[int]struct{}{})
+		if err != nil {
+			return 0, err
+		}
+		results = append(results, cacheimporttypes.CacheResult{LayerIndex: l, CreatedAt: res.CreatedAt})
+	}
+	var chainedResults []cacheimporttypes.ChainedResult
+	for _, res := range rec.ChainedResults {
+		indexes := make([]int, len(res.LayerIndexes))
+		for j, li := range res.LayerIndexes {
+			l, err := m.addLayer(st, li, map[int]struct{}{})
+			if err != nil {
+				return 0, err
+			}
+			indexes[j] = l
+		}
+		chainedResults = append(chainedResults, cacheimporttypes.ChainedResult{LayerIndexes: indexes, CreatedAt: res.CreatedAt})
+	}
+
+	i, ok := m.recordsByKey[key]
+	if !ok {
+		i = len(m.records)
+		m.records = append(m.records, cacheimporttypes.CacheRecord{
+			Digest: rec.Digest,
+			Inputs: inputs,
+		})
+		m.recordsByKey[key] = i
+	}
+	r := &m.records[i]
+	r.Results = uniqueResults(append(r.Results, results...), func(r cacheimporttypes.CacheResult) (string, time.Time) {
+		return strconv.Itoa(r.LayerIndex), r.CreatedAt
+	})
+	r.ChainedResults = uniqueResults(append(r.ChainedResults, chainedResults...), func(r cacheimporttypes.ChainedResult) (string, time.Time) {
+		keys := make([]string, len(r.LayerIndexes))
+		for i, l := range r.LayerIndexes {
+			keys[i] = strconv.Itoa(l)
+		}
+		return strings.Join(keys, ","), r.CreatedAt
+	})
+	st.recordIdx[idx] = i
+	return i, nil
+}
+
+// uniqueResults deduplicates results that point to the same layers, keeping
+// the newest one of each.
+func uniqueResults[T any](results []T, keyFn func(T) (string, time.Time)) []T {
+	if len(results) <= 1 {
+		return results
+	}
+	out := make([]T, 0, len(results))
+	byKey := map[string]int{}
+	for _, res := range results {
+		key, createdAt := keyFn(res)
+		i, ok := byKey[key]
+		if !ok {
+			byKey[key] = len(out)
+			out = append(out, res)
+			continue
+		}
+		if _, t := keyFn(out[i]); createdAt.After(t) {
+			out[i] = res
+		}
+	}
+	return out
+}
+
+// sortResults orders the results of every record newest first so that the
+// merged config does not depend on the order of the input configs.
+func sortResults(cc *cacheimporttypes.CacheConfig) {
+	for i := range cc.Records {
+		r := &cc.Records[i]
+		slices.SortFunc(r.Results, func(a, b cacheimporttypes.CacheResult) int {
+			return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.LayerIndex, b.LayerIndex))
+		})
+		slices.SortFunc(r.ChainedResults, func(a, b cacheimporttypes.ChainedResult) int {
+			return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), slices.Compare(a.LayerIndexes, b.LayerIndexes))
+		})
+	}
+}
+
+// trimConfig drops results according to opt and removes the records and
+// layers that are no longer needed by any remaining result.
+func trimConfig(cc *cacheimporttypes.CacheConfig, opt MergeOpt, now time.Time) {
+	lastUsed := func(r cacheimporttypes.CacheRecord) time.Time {
+		var t time.Time
+		for _, res := range r.Results {
+			t = maxTime(t, res.CreatedAt)
+		}
+		for _, res := range r.ChainedResults {
+			t = maxTime(t, res.CreatedAt)
+		}
+		return t
+	}
+
+	if opt.MaxAge > 0 {
+		cutoff := now.Add(-opt.MaxAge)
+		for i := range cc.Records {
+			r := &cc.Records[i]
+			r.Results = slices.DeleteFunc(r.Results, func(res cacheimporttypes.CacheResult) bool {
+				return res.CreatedAt.Before(cutoff)
+			})
+			r.ChainedResults = slices.DeleteFunc(r.ChainedResults, func(res cacheimporttypes.ChainedResult) bool {
+				return res.CreatedAt.Before(cutoff)
+			})
+		}
+	}
+
+	if opt.MaxRecords > 0 {
+		var withResults []int
+		for i, r := range cc.Records {
+			if len(r.Results) > 0 || len(r.ChainedResults) > 0 {
+				withResults = append(withResults, i)
+			}
+		}
+		if len(withResults) > opt.MaxRecords {
+			slices.SortStableFunc(withResults, func(a, b int) int {
+				return cmp.Or(lastUsed(cc.Records[b]).Compare(lastUsed(cc.Records[a])), cmp.Compare(a, b))
+			})
+			for _, i := range withResults[opt.MaxRecords:] {
+				cc.Records[i].Results = nil
+				cc.Records[i].ChainedResults = nil
+			}
+		}
+	}
+
+	// keep records with results and everything they link to
+	keep := make([]bool, len(cc.Records))
+	var mark func(int)
+	mark = func(i int) {
+		if keep[i] {
+			return
+		}
+		keep[i] = true
+		for _, ins := range cc.Records[i].Inputs {
+			for _, in := range ins {
+				mark(in.LinkIndex)
+			}
+		}
+	}
+	for i, r := range cc.Records {
+		if len(r.Results) > 0 || len(r.ChainedResults) > 0 {
+			mark(i)
+		}
+	}
+
+	keepLayers := make([]bool, len(cc.Layers))
+	var markLayer func(int)
+	markLayer = func(i int) {
+		for i != -1 && !keepLayers[i] {
+			keepLayers[i] = true
+			i = cc.Layers[i].ParentIndex
+		}
+	}
+	for i, r := range cc.Records {
+		if !keep[i] {
+			continue
+		}
+		for _, res := range r.Results {
+			markLayer(res.LayerIndex)
+		}
+		for _, res := range r.ChainedResults {
+			for _, l := range res.LayerIndexes {
+				markLayer(l)
+			}
+		}
+	}
+
+	layerIdx := make([]int, len(cc.Layers))
+	layers := make([]cacheimporttypes.CacheLayer, 0, len(cc.Layers))
+	for i, l := range cc.Layers {
+		if !keepLayers[i] {
+			continue
+		}
+		layerIdx[i] = len(layers)
+		if l.ParentIndex != -1 {
+			l.ParentIndex = layerIdx[l.ParentIndex]
+		}
+		layers = append(layers, l)
+	}
+
+	recordIdx := make([]int, len(cc.Records))
+	n := 0
+	for i := range cc.Records {
+		if keep[i] {
+			recordIdx[i] = n
+			n++
+		}
+	}
+	records := make([]cacheimporttypes.CacheRecord, 0, n)
+	for i, r := range cc.Records {
+		if !keep[i] {
+			continue
+		}
+		for j, ins := range r.Inputs {
+			for k := range ins {
+				r.Inputs[j][k].LinkIndex = recordIdx[ins[k].LinkIndex]
+			}
+		}
+		for j := range r.Results {
+			r.Results[j].LayerIndex = layerIdx[r.Results[j].LayerIndex]
+		}
+		for j := range r.ChainedResults {
+			for k, l := range r.ChainedResults[j].LayerIndexes {
+				r.ChainedResults[j].LayerIndexes[k] = layerIdx[l]
+			}
+		}
+		records = append(records, r)
+	}
+
+	cc.Layers = layers
+	cc.Records = records
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
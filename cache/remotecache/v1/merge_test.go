@@ -0,0 +1,138 @@
+package cacheimport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cacheimporttypes "github.com/moby/buildkit/cache/remotecache/v1/types"
+	"github.com/moby/buildkit/solver"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func marshalChain(t *testing.T, leaf string, layers []string, createdAt time.Time) *cacheimporttypes.CacheConfig {
+	cc := NewCacheChains()
+
+	base, ok, err := cc.Add(outputKey(dgst("base"), 0), nil, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	var descs []ocispecs.Descriptor
+	for _, l := range layers {
+		descs = append(descs, ocispecs.Descriptor{Digest: dgst(l)})
+	}
+	_, ok, err = cc.Add(outputKey(dgst(leaf), 0), [][]solver.CacheLink{
+		{{Src: base, Selector: "sel"}},
+	}, []solver.CacheExportResult{{
+		CreatedAt: createdAt,
+		Result:    &solver.Remote{Descriptors: descs},
+	}})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	cfg, _, err := cc.Marshal(context.TODO())
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestMergeConfig(t *testing.T) {
+	now := time.Now()
+	cfgA := marshalChain(t, "a", []string{"d0", "d1"}, now.Add(-2*time.Hour))
+	cfgB := marshalChain(t, "b", []string{"d0", "d2"}, now.Add(-time.Hour))
+
+	// merging a config with itself does not change it
+	merged, err := MergeConfig(MergeOpt{}, cfgA, cfgA)
+	require.NoError(t, err)
+	require.Equal(t, cfgA, merged)
+
+	merged, err = MergeConfig(MergeOpt{}, cfgA, cfgB)
+	require.NoError(t, err)
+	require.Len(t, merged.Layers, 3)
+	require.Len(t, merged.Records, 3)
+
+	var withResults int
+	for _, r := range merged.Records {
+		if r.Digest == outputKey(dgst("base"), 0) {
+			require.Empty(t, r.Results)
+			continue
+		}
+		require.Len(t, r.Inputs, 1)
+		require.Len(t, r.Inputs[0], 1)
+		require.Equal(t, "sel", r.Inputs[0][0].Selector)
+		require.Equal(t, outputKey(dgst("base"), 0), merged.Records[r.Inputs[0][0].LinkIndex].Digest)
+		require.Len(t, r.Results, 1)
+		l := merged.Layers[r.Results[0].LayerIndex]
+		require.Equal(t, dgst("d0"), merged.Layers[l.ParentIndex].Blob)
+		withResults++
+	}
+	require.Equal(t, 2, withResults)
+
+	// the order of the configs does not matter
+	merged2, err := MergeConfig(MergeOpt{}, cfgB, cfgA)
+	require.NoError(t, err)
+	require.Equal(t, merged, merged2)
+
+	// distinct results of the same record are all kept, newest first
+	cfgA2 := marshalChain(t, "a", []string{"d3"}, now)
+	merged, err = MergeConfig(MergeOpt{}, cfgA, cfgA2)
+	require.NoError(t, err)
+	require.Len(t, merged.Records, 2)
+	require.Len(t, merged.Layers, 3)
+	var rec cacheimporttypes.CacheRecord
+	for _, r := range merged.Records {
+		if r.Digest == outputKey(dgst("a"), 0) {
+			rec = r
+		}
+	}
+	require.Len(t, rec.Results, 2)
+	require.Equal(t, dgst("d3"), merged.Layers[rec.Results[0].LayerIndex].Blob)
+	require.Equal(t, dgst("d1"), merged.Layers[rec.Results[1].LayerIndex].Blob)
+
+	// the same result is kept once with its newest creation time
+	cfgA3 := marshalChain(t, "a", []string{"d0", "d1"}, now)
+	merged, err = MergeConfig(MergeOpt{}, cfgA, cfgA3)
+	require.NoError(t, err)
+	require.Equal(t, cfgA3, merged)
+}
+
+func TestMergeConfigTrim(t *testing.T) {
+	now := time.Now()
+	cfgA := marshalChain(t, "a", []string{"d0", "d1"}, now.Add(-2*time.Hour))
+	cfgB := marshalChain(t, "b", []string{"d2"}, now.Add(-time.Hour))
+
+	merged, err := MergeConfig(MergeOpt{MaxRecords: 1}, cfgA, cfgB)
+	require.NoError(t, err)
+	require.Len(t, merged.Records, 2)
+	require.Len(t, merged.Layers, 1)
+	require.Equal(t, dgst("d2"), merged.Layers[0].Blob)
+
+	merged, err = MergeConfig(MergeOpt{MaxAge: 90 * time.Minute}, cfgA, cfgB)
+	require.NoError(t, err)
+	require.Len(t, merged.Records, 2)
+	require.Len(t, merged.Layers, 1)
+	require.Equal(t, dgst("d2"), merged.Layers[0].Blob)
+
+	merged, err = MergeConfig(MergeOpt{MaxAge: 30 * time.Minute}, cfgA, cfgB)
+	require.NoError(t, err)
+	require.Empty(t, merged.Records)
+	require.Empty(t, merged.Layers)
+}
+
+func TestMergeConfigInvalid(t *testing.T) {
+	_, err := MergeConfig(MergeOpt{}, &cacheimporttypes.CacheConfig{
+		Records: []cacheimporttypes.CacheRecord{{
+			Digest: dgst("foo"),
+			Inputs: [][]cacheimporttypes.CacheInput{{{LinkIndex: 0}}},
+		}},
+	})
+	require.ErrorContains(t, err, "looping")
+
+	_, err = MergeConfig(MergeOpt{}, &cacheimporttypes.CacheConfig{
+		Records: []cacheimporttypes.CacheRecord{{
+			Digest:  dgst("foo"),
+			Results: []cacheimporttypes.CacheResult{{LayerIndex: 3}},
+		}},
+	})
+	require.ErrorContains(t, err, "invalid layer index")
+}
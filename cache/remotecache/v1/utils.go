@@ -94,6 +94,11 @@ func sortConfig(cc *cacheimporttypes.CacheConfig) {
 		for j := range r.r.Results {
 			r.r.Results[j].LayerIndex = unsortedLayers[r.r.Results[j].LayerIndex].newIndex
 		}
+		for j := range r.r.ChainedResults {
+			for k, l := range r.r.ChainedResults[j].LayerIndexes {
+				r.r.ChainedResults[j].LayerIndexes[k] = unsortedLayers[l].newIndex
+			}
+		}
 		for j, inputs := range r.r.Inputs {
 			for k := range inputs {
 				r.r.Inputs[j][k].LinkIndex = unsortedRecords[r.r.Inputs[j][k].LinkIndex].newIndex
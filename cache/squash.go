@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/moby/buildkit/util/progress"
+	"github.com/pkg/errors"
+)
+
+// SquashLayers returns a ref with the same contents as ref whose layers are
+// the layers of ref squashed into groups. Each index of boundaries is the index
+// of a layer of ref that starts a new group. The first group always starts at
+// the first layer, and indexes beyond the last layer are ignored. Groups with a
+// single layer re-use the layer of ref.
+func SquashLayers(ctx context.Context, ref ImmutableRef, boundaries []int, pg progress.Controller) (ImmutableRef, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	sr, ok := ref.(*immutableRef)
+	if !ok {
+		return nil, errors.Errorf("invalid ref for squashing %T", ref)
+	}
+	cm := sr.cm
+
+	layers := sr.layerChain()
+	starts := SquashGroups(len(layers), boundaries)
+	if len(starts) == len(layers) {
+		return sr.Clone(), nil
+	}
+
+	var refs []ImmutableRef
+	defer func() {
+		for _, r := range refs {
+			r.Release(context.WithoutCancel(ctx))
+		}
+	}()
+
+	// state returns a ref with the contents of the first n layers
+	states := map[int]ImmutableRef{}
+	state := func(n int) (ImmutableRef, error) {
+		if n == 0 {
+			return nil, nil
+		}
+		if r, ok := states[n]; ok {
+			return r, nil
+		}
+		top := layers[n-1]
+		if slices.EqualFunc(top.layerChain(), layers[:n], func(a, b *immutableRef) bool { return a.ID() == b.ID() }) {
+			states[n] = top
+			return top, nil
+		}
+		singles := make([]ImmutableRef, n)
+		for i, l := range layers[:n] {
+			r, err := singleLayer(ctx, l, pg)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, r)
+			singles[i] = r
+		}
+		r, err := cm.Merge(ctx, singles, pg)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+		states[n] = r
+		return r, nil
+	}
+
+	parents := make([]ImmutableRef, 0, len(starts))
+	for i, start := range starts {
+		end := len(layers)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if end-start == 1 {
+			r, err := singleLayer(ctx, layers[start], pg)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, r)
+			parents = append(parents, r)
+			continue
+		}
+		lower, err := state(start)
+		if err != nil {
+			return nil, err
+		}
+		upper, err := state(end)
+		if err != nil {
+			return nil, err
+		}
+		r, err := cm.Diff(ctx, lower, upper, pg, SquashDiff,
+			WithDescription(fmt.Sprintf("squashed layers %d-%d of %s", start+1, end, sr.ID())))
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+		parents = append(parents, r)
+	}
+	if len(parents) == 1 {
+		return parents[0].Clone(), nil
+	}
+	return cm.Merge(ctx, parents, pg)
+}
+
+// SquashGroups returns the indexes of the first layers of the groups that n
+// layers are squashed into for the given boundaries.
+func SquashGroups(n int, boundaries []int) []int {
+	if n == 0 {
+		return nil
+	}
+	starts := []int{0}
+	for _, b := range boundaries {
+		if b > 0 && b < n {
+			starts = append(starts, b)
+		}
+	}
+	slices.Sort(starts)
+	return slices.Compact(starts)
+}
+
+// singleLayer returns a ref whose only layer is l.
+func singleLayer(ctx context.Context, l *immutableRef, pg progress.Controller) (ImmutableRef, error) {
+	if l.kind() == Layer {
+		return l.cm.Diff(ctx, l.layerParent, l, pg)
+	}
+	return l.Clone(), nil
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/util/grpcerrors"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+var checkpointTests = []func(t *testing.T, sb integration.Sandbox){
+	testExecCheckpointRestore,
+}
+
+type checkpointEnabled struct{}
+
+func (*checkpointEnabled) UpdateConfigFile(in string) (string, func() error) {
+	return in + `
+
+[worker.oci]
+checkpoint = true
+`, nil
+}
+
+var checkpointWorker integration.ConfigUpdater = &checkpointEnabled{}
+
+// testExecCheckpointRestore checkpoints a step after its timeout and resumes
+// it with the next build.
+func testExecCheckpointRestore(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	if sb.Name() != "oci" {
+		t.Skip("checkpointing is only supported by the OCI worker running as root")
+	}
+	if _, err := exec.LookPath("criu"); err != nil {
+		t.Skip("criu is not installed")
+	}
+
+	c, err := New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// the second half of the sleep runs after the process is restored
+	def, err := llb.Image("busybox:latest").Run(
+		llb.Shlex(`sh -c 'echo start >> /log; sleep 10; echo done >> /log'`),
+		llb.WithCheckpoint(5*time.Second),
+	).Root().Marshal(sb.Context())
+	require.NoError(t, err)
+
+	_, err = c.Solve(sb.Context(), def, SolveOpt{}, nil)
+	require.Error(t, err)
+	require.True(t, errdefs.IsCheckpointed(err), "%+v", err)
+	require.Equal(t, codes.Aborted, grpcerrors.Code(err))
+	require.ErrorContains(t, err, "will be resumed by the next build")
+
+	var ce *errdefs.CheckpointedError
+	require.ErrorAs(t, err, &ce)
+	du, err := c.DiskUsage(sb.Context(), WithFilter([]string{"type==" + string(UsageRecordTypeCheckpoint)}))
+	require.NoError(t, err)
+	require.Len(t, du, 1)
+	require.Equal(t, ce.Id, du[0].ID)
+
+	destDir := t.TempDir()
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type:      ExporterLocal,
+				OutputDir: destDir,
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	// the process was resumed instead of being started again
+	dt, err := os.ReadFile(filepath.Join(destDir, "log"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"start", "done"}, strings.Fields(string(dt)))
+
+	// the checkpoint is consumed by the build that resumed it
+	du, err = c.DiskUsage(sb.Context(), WithFilter([]string{"type==" + string(UsageRecordTypeCheckpoint)}))
+	require.NoError(t, err)
+	require.Empty(t, du)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/moby/buildkit/util/testutil/workers"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+	"golang.org/x/sys/unix"
+)
+
+func testFSImageExt4Reproducible(t *testing.T, sb integration.Sandbox) {
+	requiresLinux(t)
+	workers.CheckFeatureCompat(t, sb, workers.FeatureSourceDateEpoch)
+	for _, bin := range []string{"mkfs.ext4", "debugfs"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("test requires %s", bin)
+		}
+	}
+
+	c, err := New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	def, err := llb.Local("src").Marshal(sb.Context())
+	require.NoError(t, err)
+
+	export := func() []byte {
+		// the files are extracted again on every export, with new change times
+		dir := integration.Tmpdir(
+			t,
+			fstest.CreateDir("etc", 0755),
+			fstest.CreateFile("etc/foo", []byte("foo"), 0644),
+			fstest.CreateFile("bar", []byte("bar"), 0600),
+			fstest.Symlink("etc/foo", "baz"),
+		)
+		err := unix.Lsetxattr(filepath.Join(dir.Name, "bar"), "user.foo", []byte("xattr-value"), 0)
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skip("test requires user xattrs")
+		}
+		require.NoError(t, err)
+
+		out := filepath.Join(t.TempDir(), "rootfs.ext4")
+		_, err = c.Solve(sb.Context(), def, SolveOpt{
+			Exports: []ExportEntry{
+				{
+					Type: ExporterFSImage,
+					Attrs: map[string]string{
+						"format":            "ext4",
+						"size":              "16m",
+						"source-date-epoch": "1700000000",
+					},
+					Output: func(map[string]string) (io.WriteCloser, error) {
+						return os.Create(out)
+					},
+				},
+			},
+			LocalMounts: map[string]fsutil.FS{
+				"src": dir,
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		dt, err := os.ReadFile(out)
+		require.NoError(t, err)
+		return dt
+	}
+
+	img1 := export()
+	img2 := export()
+	require.True(t, bytes.Equal(img1, img2), "ext4 images are not reproducible")
+
+	img := filepath.Join(t.TempDir(), "rootfs.ext4")
+	require.NoError(t, os.WriteFile(img, img1, 0600))
+	out, err := exec.Command("debugfs", "-R", "ea_get /bar user.foo", img).Output()
+	require.NoError(t, err)
+	require.Contains(t, string(out), "xattr-value")
+
+	out, err = exec.Command("debugfs", "-R", "cat /etc/foo", img).Output()
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(out))
+}
@@ -3,6 +3,10 @@ package client
 import (
 	"archive/tar"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -29,16 +33,23 @@ import (
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
 	gateway "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
 	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/compression"
 	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/sigstoresign"
 	"github.com/moby/buildkit/util/testutil"
 	"github.com/moby/buildkit/util/testutil/integration"
 	"github.com/moby/buildkit/util/testutil/workers"
 	digest "github.com/opencontainers/go-digest"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 func testBuildExportScratch(t *testing.T, sb integration.Sandbox) {
@@ -584,6 +595,303 @@ func testBuildExportZstd(t *testing.T, sb integration.Sandbox) {
 	require.Equal(t, lastLayer.Digest.Hex(), zstdLayerDigest)
 }
 
+func testBuildExportZstdChunked(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	workers.CheckFeatureCompat(t, sb, workers.FeatureOCIExporter)
+	c, err := New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	busybox := llb.Image("busybox:latest")
+	st := busybox.Run(llb.Shlex(`sh -e -c "echo -n chunked > /data"`)).Root()
+
+	def, err := st.Marshal(sb.Context())
+	require.NoError(t, err)
+
+	out := filepath.Join(t.TempDir(), "out.tar")
+	outW, err := os.Create(out)
+	require.NoError(t, err)
+
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type:   ExporterOCI,
+				Output: fixedWriteCloser(outW),
+				Attrs: map[string]string{
+					"compression":       "zstd:chunked",
+					"force-compression": "true",
+					"oci-mediatypes":    "true",
+				},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	dt, err := os.ReadFile(out)
+	require.NoError(t, err)
+	m, err := testutil.ReadTarToMap(dt, false)
+	require.NoError(t, err)
+
+	var index ocispecs.Index
+	require.NoError(t, json.Unmarshal(m[ocispecs.ImageIndexFile].Data, &index))
+	var mfst ocispecs.Manifest
+	require.NoError(t, json.Unmarshal(m[ocispecs.ImageBlobsDir+"/sha256/"+index.Manifests[0].Digest.Hex()].Data, &mfst))
+	require.Greater(t, len(mfst.Layers), 1)
+
+	// the layers of the base image are converted as well
+	for _, l := range mfst.Layers {
+		require.Equal(t, ocispecs.MediaTypeImageLayerZstd, l.MediaType)
+		for _, k := range compression.ZstdChunkedAnnotations {
+			require.NotEmpty(t, l.Annotations[k], "missing annotation %s", k)
+		}
+		blob := m[ocispecs.ImageBlobsDir+"/sha256/"+l.Digest.Hex()].Data
+		require.Equal(t, []byte{0x28, 0xb5, 0x2f, 0xfd}, blob[:4])
+		// zstd:chunked footer magic
+		require.Equal(t, []byte("GNUlInUx"), blob[len(blob)-8:])
+	}
+
+	outW, err = os.Create(out)
+	require.NoError(t, err)
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type:   ExporterOCI,
+				Output: fixedWriteCloser(outW),
+				Attrs: map[string]string{
+					"compression":    "zstd:chunked",
+					"oci-mediatypes": "false",
+				},
+			},
+		},
+	}, nil)
+	require.ErrorContains(t, err, "conflicts with \"oci-mediatypes=false\"")
+}
+
+func testBuildExportSquash(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	workers.CheckFeatureCompat(t, sb, workers.FeatureOCIExporter)
+	c, err := New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	busybox := llb.Image("busybox:latest")
+	st := busybox.
+		Run(llb.Shlex(`sh -c "echo -n secret > /secret"`)).Root().
+		Run(llb.Shlex(`sh -c "rm /secret && echo -n b > /b"`)).Root().
+		Run(llb.Shlex(`sh -c "echo -n c > /c"`)).Root()
+
+	def, err := st.Marshal(sb.Context())
+	require.NoError(t, err)
+
+	export := func(attrs map[string]string) (ocispecs.Image, []map[string]*testutil.TarItem) {
+		out := filepath.Join(t.TempDir(), "out.tar")
+		outW, err := os.Create(out)
+		require.NoError(t, err)
+
+		_, err = c.Solve(sb.Context(), def, SolveOpt{
+			Exports: []ExportEntry{
+				{
+					Type:   ExporterOCI,
+					Output: fixedWriteCloser(outW),
+					Attrs:  attrs,
+				},
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		dt, err := os.ReadFile(out)
+		require.NoError(t, err)
+		m, err := testutil.ReadTarToMap(dt, false)
+		require.NoError(t, err)
+
+		var index ocispecs.Index
+		require.NoError(t, json.Unmarshal(m[ocispecs.ImageIndexFile].Data, &index))
+		var mfst ocispecs.Manifest
+		require.NoError(t, json.Unmarshal(m[ocispecs.ImageBlobsDir+"/sha256/"+index.Manifests[0].Digest.Hex()].Data, &mfst))
+		var img ocispecs.Image
+		require.NoError(t, json.Unmarshal(m[ocispecs.ImageBlobsDir+"/sha256/"+mfst.Config.Digest.Hex()].Data, &img))
+		require.Len(t, img.RootFS.DiffIDs, len(mfst.Layers))
+
+		var layers []map[string]*testutil.TarItem
+		for _, l := range mfst.Layers {
+			lm, err := testutil.ReadTarToMap(m[ocispecs.ImageBlobsDir+"/sha256/"+l.Digest.Hex()].Data, true)
+			require.NoError(t, err)
+			layers = append(layers, lm)
+		}
+		return img, layers
+	}
+	nonEmpty := func(img ocispecs.Image) (n int) {
+		for _, h := range img.History {
+			if !h.EmptyLayer {
+				n++
+			}
+		}
+		return n
+	}
+
+	img, layers := export(nil)
+	numLayers := len(layers)
+	require.Greater(t, numLayers, 3)
+	require.Contains(t, layers[numLayers-3], "secret")
+	baseDiffIDs := img.RootFS.DiffIDs[:numLayers-3]
+
+	img, layers = export(map[string]string{"squash": "true"})
+	require.Len(t, layers, 1)
+	require.Len(t, img.History, numLayers)
+	require.Equal(t, 1, nonEmpty(img))
+	require.NotContains(t, layers[0], "secret")
+	require.NotContains(t, layers[0], ".wh.secret")
+	require.Equal(t, []byte("b"), layers[0]["b"].Data)
+	require.Equal(t, []byte("c"), layers[0]["c"].Data)
+	require.Contains(t, layers[0], "bin/busybox")
+
+	// keep the busybox layers and squash the layers of the build steps
+	img, layers = export(map[string]string{"squash-from": strconv.Itoa(len(baseDiffIDs))})
+	require.Len(t, layers, len(baseDiffIDs)+1)
+	require.Equal(t, baseDiffIDs, img.RootFS.DiffIDs[:len(baseDiffIDs)])
+	require.Len(t, img.History, numLayers)
+	require.Equal(t, len(layers), nonEmpty(img))
+	top := layers[len(layers)-1]
+	require.NotContains(t, top, "secret")
+	require.Contains(t, top, "b")
+	require.Contains(t, top, "c")
+	require.NotContains(t, top, "bin/busybox")
+
+	// keep the layer of the last build step
+	img, layers = export(map[string]string{"layer-boundaries": strconv.Itoa(numLayers - 1)})
+	require.Len(t, layers, 2)
+	require.Len(t, img.History, numLayers)
+	require.NotContains(t, layers[0], "secret")
+	require.Contains(t, layers[0], "b")
+	require.Contains(t, layers[1], "c")
+	require.NotContains(t, layers[1], "b")
+	require.NotContains(t, layers[1], "bin/busybox")
+
+	// inline cache references the layers of the result
+	outW, err := os.Create(filepath.Join(t.TempDir(), "out.tar"))
+	require.NoError(t, err)
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type:   ExporterOCI,
+				Output: fixedWriteCloser(outW),
+				Attrs:  map[string]string{"squash": "true"},
+			},
+		},
+		CacheExports: []CacheOptionsEntry{
+			{
+				Type: "inline",
+			},
+		},
+	}, nil)
+	require.ErrorContains(t, err, "inline cache can't be exported with squashed or rebalanced layers")
+}
+
+func testBuildExportRebalance(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	workers.CheckFeatureCompat(t, sb, workers.FeatureOCIExporter)
+	c, err := New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	busybox := llb.Image("busybox:latest")
+	st := busybox.
+		Run(llb.Shlex(`sh -c "mkdir /app /cache && head -c 262144 /dev/urandom > /app/big && head -c 1024 /dev/urandom > /cache/data"`)).Root().
+		Run(llb.Shlex(`sh -c "echo -n b > /b"`)).Root().
+		Run(llb.Shlex(`sh -c "echo -n c > /c"`)).Root()
+
+	def, err := st.Marshal(sb.Context())
+	require.NoError(t, err)
+
+	export := func(attrs map[string]string) (ocispecs.Image, []map[string]*testutil.TarItem) {
+		out := filepath.Join(t.TempDir(), "out.tar")
+		outW, err := os.Create(out)
+		require.NoError(t, err)
+
+		_, err = c.Solve(sb.Context(), def, SolveOpt{
+			Exports: []ExportEntry{
+				{
+					Type:   ExporterOCI,
+					Output: fixedWriteCloser(outW),
+					Attrs:  attrs,
+				},
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		dt, err := os.ReadFile(out)
+		require.NoError(t, err)
+		m, err := testutil.ReadTarToMap(dt, false)
+		require.NoError(t, err)
+
+		var index ocispecs.Index
+		require.NoError(t, json.Unmarshal(m[ocispecs.ImageIndexFile].Data, &index))
+		var mfst ocispecs.Manifest
+		require.NoError(t, json.Unmarshal(m[ocispecs.ImageBlobsDir+"/sha256/"+index.Manifests[0].Digest.Hex()].Data, &mfst))
+		var img ocispecs.Image
+		require.NoError(t, json.Unmarshal(m[ocispecs.ImageBlobsDir+"/sha256/"+mfst.Config.Digest.Hex()].Data, &img))
+		require.Len(t, img.RootFS.DiffIDs, len(mfst.Layers))
+
+		var nonEmpty int
+		for _, h := range img.History {
+			if !h.EmptyLayer {
+				nonEmpty++
+			}
+		}
+		require.Equal(t, len(mfst.Layers), nonEmpty)
+
+		var layers []map[string]*testutil.TarItem
+		for _, l := range mfst.Layers {
+			lm, err := testutil.ReadTarToMap(m[ocispecs.ImageBlobsDir+"/sha256/"+l.Digest.Hex()].Data, true)
+			require.NoError(t, err)
+			layers = append(layers, lm)
+		}
+		return img, layers
+	}
+
+	img, layers := export(nil)
+	numLayers := len(layers)
+	require.Greater(t, numLayers, 3)
+	baseDiffIDs := img.RootFS.DiffIDs[:numLayers-3]
+
+	// the small layers of the build steps are merged into one
+	img, layers = export(map[string]string{"layer-size-min": "16MB"})
+	require.Len(t, layers, len(baseDiffIDs)+1)
+	require.Equal(t, baseDiffIDs, img.RootFS.DiffIDs[:len(baseDiffIDs)])
+	top := layers[len(layers)-1]
+	require.Contains(t, top, "app/big")
+	require.Contains(t, top, "b")
+	require.Contains(t, top, "c")
+
+	// the hot path is moved to a layer above the rest of its layer
+	img, layers = export(map[string]string{"layer-hot-paths": "/cache"})
+	require.Len(t, layers, numLayers+1)
+	require.Equal(t, baseDiffIDs, img.RootFS.DiffIDs[:len(baseDiffIDs)])
+	require.Contains(t, layers[len(baseDiffIDs)], "app/big")
+	require.NotContains(t, layers[len(baseDiffIDs)], "cache/data")
+	require.Contains(t, layers[len(baseDiffIDs)+1], "cache/data")
+	require.NotContains(t, layers[len(baseDiffIDs)+1], "app/big")
+
+	// the large layer is split
+	img, layers = export(map[string]string{"layer-size-max": "64KB", "compression": "uncompressed"})
+	require.Greater(t, len(layers), numLayers)
+	require.Equal(t, baseDiffIDs, img.RootFS.DiffIDs[:len(baseDiffIDs)])
+	require.Contains(t, layers[len(layers)-1], "c")
+
+	outW, err := os.Create(filepath.Join(t.TempDir(), "out.tar"))
+	require.NoError(t, err)
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type:   ExporterOCI,
+				Output: fixedWriteCloser(outW),
+				Attrs:  map[string]string{"layer-size-min": "1MB", "layer-size-max": "512KB"},
+			},
+		},
+	}, nil)
+	require.ErrorContains(t, err, "minimum layer size")
+}
+
 func testBuildPushAndValidate(t *testing.T, sb integration.Sandbox) {
 	workers.CheckFeatureCompat(t, sb, workers.FeatureDirectPush)
 	requiresLinux(t)
@@ -1755,6 +2063,105 @@ func testPushByDigest(t *testing.T, sb integration.Sandbox) {
 	require.Greater(t, desc.Size, int64(0))
 }
 
+func testPushSigned(t *testing.T, sb integration.Sandbox) {
+	workers.CheckFeatureCompat(t, sb, workers.FeatureDirectPush)
+	requiresLinux(t)
+	c, err := New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	registry, err := sb.NewRegistry()
+	if errors.Is(err, integration.ErrRequirements) {
+		t.Skip(err.Error())
+	}
+	require.NoError(t, err)
+
+	st := llb.Scratch().File(llb.Mkfile("foo", 0600, []byte("data")))
+
+	def, err := st.Marshal(sb.Context())
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyPEM, err := cryptoutils.MarshalPrivateKeyToPEM(key)
+	require.NoError(t, err)
+
+	name := registry + "/foo/signed:latest"
+	resp, err := c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type: ExporterImage,
+				Attrs: map[string]string{
+					"name":            name,
+					"push":            "true",
+					"oci-mediatypes":  "true",
+					"sign-key-secret": "signing-key",
+				},
+			},
+		},
+		Session: []session.Attachable{secretsprovider.FromMap(map[string][]byte{
+			"signing-key": keyPEM,
+		})},
+	}, nil)
+	require.NoError(t, err)
+
+	desc, _, err := contentutil.ProviderFromRef(name)
+	require.NoError(t, err)
+	require.Equal(t, resp.ExporterResponse[exptypes.ExporterImageDigestKey], desc.Digest.String())
+
+	sigDigest := resp.ExporterResponse[exptypes.ExporterImageSignatureDigestKey]
+	require.NotEmpty(t, sigDigest)
+	sigDesc, provider, err := contentutil.ProviderFromRef(registry + "/foo/signed@" + sigDigest)
+	require.NoError(t, err)
+	dt, err := content.ReadBlob(sb.Context(), provider, sigDesc)
+	require.NoError(t, err)
+
+	var mfst ocispecs.Manifest
+	require.NoError(t, json.Unmarshal(dt, &mfst))
+	require.Equal(t, sigstoresign.BundleMediaType, mfst.ArtifactType)
+	require.NotNil(t, mfst.Subject)
+	require.Equal(t, desc.Digest, mfst.Subject.Digest)
+	require.Len(t, mfst.Layers, 1)
+
+	dt, err = content.ReadBlob(sb.Context(), provider, mfst.Layers[0])
+	require.NoError(t, err)
+	var bundle protobundle.Bundle
+	require.NoError(t, protojson.Unmarshal(dt, &bundle))
+	ms := bundle.GetMessageSignature()
+	require.NotNil(t, ms)
+	sum, err := hex.DecodeString(desc.Digest.Hex())
+	require.NoError(t, err)
+	require.Equal(t, sum, ms.MessageDigest.Digest)
+	require.True(t, ecdsa.VerifyASN1(&key.PublicKey, sum, ms.Signature))
+
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type: ExporterImage,
+				Attrs: map[string]string{
+					"name":            name,
+					"sign-key-secret": "signing-key",
+				},
+			},
+		},
+	}, nil)
+	require.ErrorContains(t, err, "requires \"push=true\"")
+
+	_, err = c.Solve(sb.Context(), def, SolveOpt{
+		Exports: []ExportEntry{
+			{
+				Type: ExporterImage,
+				Attrs: map[string]string{
+					"name":            name,
+					"push":            "true",
+					"sign-fulcio-url": "https://fulcio.example.com",
+				},
+			},
+		},
+	}, nil)
+	require.ErrorContains(t, err, "requires \"sign-rekor-url\"")
+}
+
 func testPushProgressSameVertex(t *testing.T, sb integration.Sandbox) {
 	workers.CheckFeatureCompat(t, sb, workers.FeatureDirectPush)
 	requiresLinux(t)
@@ -66,6 +66,9 @@ var allTests = []func(t *testing.T, sb integration.Sandbox){
 	testBuildExportWithForeignLayer,
 	testBuildExportWithUncompressed,
 	testBuildExportZstd,
+	testBuildExportZstdChunked,
+	testBuildExportSquash,
+	testBuildExportRebalance,
 	testBuildPushAndValidate,
 	testExportBusyboxLocal,
 	testExportedImageLabels,
@@ -77,6 +80,7 @@ var allTests = []func(t *testing.T, sb integration.Sandbox){
 	testPullWithDigestCheck,
 	testPullZstdImage,
 	testPushByDigest,
+	testPushSigned,
 	testPushProgressSameVertex,
 	testStargzLazyPull,
 
@@ -96,6 +100,9 @@ var allTests = []func(t *testing.T, sb integration.Sandbox){
 	testTarExporterWithSocket,
 	testTarExporterWithSocketCopy,
 
+	// client_export_fsimage_test.go
+	testFSImageExt4Reproducible,
+
 	// client_export_metadata_test.go
 	testAttestationBundle,
 	testAttestationDefaultSubject,
@@ -375,4 +382,12 @@ func testIntegration(t *testing.T, funcs ...func(t *testing.T, sb integration.Sa
 
 	// client_cdi_test.go
 	integration.Run(t, integration.TestFuncs(cdiTests...), mirrors)
+
+	// client_checkpoint_test.go
+	integration.Run(t, integration.TestFuncs(checkpointTests...),
+		mirrors,
+		integration.WithMatrix("checkpoint", map[string]any{
+			"enabled": checkpointWorker,
+		}),
+	)
 }
@@ -84,6 +84,7 @@ const (
 	UsageRecordTypeLocalSource UsageRecordType = "source.local"
 	UsageRecordTypeGitCheckout UsageRecordType = "source.git.checkout"
 	UsageRecordTypeCacheMount  UsageRecordType = "exec.cachemount"
+	UsageRecordTypeCheckpoint  UsageRecordType = "exec.checkpoint"
 	UsageRecordTypeRegular     UsageRecordType = "regular"
 )
 
@@ -7,11 +7,12 @@ import (
 )
 
 const (
-	ExporterImage  = "image"
-	ExporterLocal  = "local"
-	ExporterTar    = "tar"
-	ExporterOCI    = "oci"
-	ExporterDocker = "docker"
+	ExporterImage   = "image"
+	ExporterLocal   = "local"
+	ExporterTar     = "tar"
+	ExporterOCI     = "oci"
+	ExporterDocker  = "docker"
+	ExporterFSImage = "fsimage"
 )
 
 type LocalExporterMode string
@@ -7,6 +7,7 @@ import (
 	"net"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/system"
@@ -46,6 +47,7 @@ type mount struct {
 	tmpfs        bool
 	tmpfsOpt     TmpfsInfo
 	cacheSharing CacheMountSharingMode
+	cacheSize    int64
 	noOutput     bool
 	contentCache MountContentCache
 }
@@ -61,6 +63,11 @@ type ExecOp struct {
 	secrets     []SecretInfo
 	ssh         []SSHInfo
 	cdiDevices  []CDIDeviceInfo
+	secProfile  string
+	networkName string
+	services    []ServiceInfo
+	checkpoint  *CheckpointInfo
+	runtime     string
 }
 
 func (e *ExecOp) AddMount(target string, source Output, opt ...MountOption) Output {
@@ -127,6 +134,18 @@ func (e *ExecOp) Validate(ctx context.Context, c *Constraints) error {
 			}
 		}
 	}
+	for _, s := range e.services {
+		if s.Name == "" {
+			return errors.Errorf("service name is required")
+		}
+		out := s.State.Output()
+		if out == nil {
+			return errors.Errorf("service %s requires a root filesystem", s.Name)
+		}
+		if err := out.Vertex(ctx, c).Validate(ctx, c); err != nil {
+			return err
+		}
+	}
 	e.isValidated = true
 	return nil
 }
@@ -255,8 +274,15 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 		meta.Ulimit = ul
 	}
 
-	if e.constraints.Metadata.LinuxResources != nil {
+	if res := e.constraints.Metadata.LinuxResources; res != nil {
 		addCap(&e.constraints, pb.CapExecMetaLinuxResources)
+		if res.PidsLimit != 0 || res.BlkioWeight != 0 || len(res.BlkioDeviceReadBps) > 0 ||
+			len(res.BlkioDeviceWriteBps) > 0 || len(res.BlkioDeviceReadIOps) > 0 || len(res.BlkioDeviceWriteIOps) > 0 {
+			addCap(&e.constraints, pb.CapExecMetaLinuxResourcesPidsBlkio)
+		}
+		if res.DiskQuota != 0 {
+			addCap(&e.constraints, pb.CapExecMetaLinuxResourcesDiskQuota)
+		}
 	}
 
 	network, err := getNetwork(e.base)(ctx, c)
@@ -307,6 +333,9 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 		if m.cacheID != "" {
 			addCap(&e.constraints, pb.CapExecMountCache)
 			addCap(&e.constraints, pb.CapExecMountCacheSharing)
+			if m.cacheSize > 0 {
+				addCap(&e.constraints, pb.CapExecMountCacheSizeLimit)
+			}
 		} else if m.tmpfs {
 			addCap(&e.constraints, pb.CapExecMountTmpfs)
 			if m.tmpfsOpt.Size > 0 {
@@ -346,6 +375,32 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 		peo.CdiDevices = cd
 	}
 
+	if e.secProfile != "" {
+		addCap(&e.constraints, pb.CapExecMetaSecurityProfile)
+		peo.SecurityProfile = e.secProfile
+	}
+
+	if e.networkName != "" {
+		addCap(&e.constraints, pb.CapExecMetaNetworkName)
+		peo.NetworkName = e.networkName
+	}
+
+	if len(e.services) > 0 {
+		addCap(&e.constraints, pb.CapExecMetaServices)
+	}
+
+	if e.runtime != "" {
+		addCap(&e.constraints, pb.CapExecMetaRuntime)
+		peo.Runtime = e.runtime
+	}
+
+	if e.checkpoint != nil {
+		addCap(&e.constraints, pb.CapExecMetaCheckpoint)
+		peo.Checkpoint = &pb.CheckpointOpt{
+			Timeout: int64(e.checkpoint.Timeout / time.Second),
+		}
+	}
+
 	if e.constraints.Platform == nil {
 		p, err := getPlatform(e.base)(ctx, c)
 		if err != nil {
@@ -361,7 +416,7 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 
 	outIndex := 0
 	for _, m := range e.mounts {
-		inputIndex := pb.InputIndex(len(pop.Inputs))
+		var inputIndex pb.InputIndex
 		if m.source != nil {
 			if m.tmpfs {
 				return "", nil, nil, nil, errors.Errorf("tmpfs mounts must use scratch")
@@ -370,20 +425,7 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 			if err != nil {
 				return "", nil, nil, nil, err
 			}
-
-			newInput := true
-
-			for i, inp2 := range pop.Inputs {
-				if inp.EqualVT(inp2) {
-					inputIndex = pb.InputIndex(i)
-					newInput = false
-					break
-				}
-			}
-
-			if newInput {
-				pop.Inputs = append(pop.Inputs, inp)
-			}
+			inputIndex = addInput(pop, inp)
 		} else {
 			inputIndex = pb.Empty
 		}
@@ -404,7 +446,8 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 		if m.cacheID != "" {
 			pm.MountType = pb.MountType_CACHE
 			pm.CacheOpt = &pb.CacheOpt{
-				ID: m.cacheID,
+				ID:        m.cacheID,
+				SizeLimit: m.cacheSize,
 			}
 			switch m.cacheSharing {
 			case CacheMountShared:
@@ -473,6 +516,19 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 		peo.Mounts = append(peo.Mounts, pm)
 	}
 
+	for _, s := range e.services {
+		inp, err := s.State.Output().ToInput(ctx, c)
+		if err != nil {
+			return "", nil, nil, nil, err
+		}
+		sc, err := s.marshal(ctx, c)
+		if err != nil {
+			return "", nil, nil, nil, err
+		}
+		sc.Input = int64(addInput(pop, inp))
+		peo.Services = append(peo.Services, sc)
+	}
+
 	dt, err := deterministicMarshal(pop)
 	if err != nil {
 		return "", nil, nil, nil, err
@@ -480,6 +536,18 @@ func (e *ExecOp) Marshal(ctx context.Context, c *Constraints) (digest.Digest, []
 	return cache.Store(dt, md, e.constraints.SourceLocations, c)
 }
 
+// addInput adds inp to the inputs of pop unless it is already one of them
+// and returns its index.
+func addInput(pop *pb.Op, inp *pb.Input) pb.InputIndex {
+	for i, inp2 := range pop.Inputs {
+		if inp.EqualVT(inp2) {
+			return pb.InputIndex(i)
+		}
+	}
+	pop.Inputs = append(pop.Inputs, inp)
+	return pb.InputIndex(len(pop.Inputs) - 1)
+}
+
 func (e *ExecOp) Output() Output {
 	return e.root
 }
@@ -500,6 +568,14 @@ func (e *ExecOp) Inputs() (inputs []Output) {
 			}
 		}
 	}
+	for _, s := range e.services {
+		if out := s.State.Output(); out != nil {
+			if _, ok := seen[out]; !ok {
+				inputs = append(inputs, out)
+				seen[out] = struct{}{}
+			}
+		}
+	}
 
 	return
 }
@@ -571,6 +647,14 @@ func AsPersistentCacheDir(id string, sharing CacheMountSharingMode) MountOption
 	}
 }
 
+// CacheMountSizeLimit limits the size of a cache mount created with
+// [AsPersistentCacheDir]. Writes that exceed the limit fail.
+func CacheMountSizeLimit(b int64) MountOption {
+	return func(m *mount) {
+		m.cacheSize = b
+	}
+}
+
 func Tmpfs(opts ...TmpfsOption) MountOption {
 	return func(m *mount) {
 		t := &TmpfsInfo{}
@@ -683,6 +767,158 @@ type CDIDeviceInfo struct {
 	Optional bool
 }
 
+// WithSecurityProfile is a RunOption that runs the exec with the seccomp and
+// AppArmor profile of the named security profile configured in the daemon
+// instead of the default profile.
+func WithSecurityProfile(name string) RunOption {
+	return runOptionFunc(func(ei *ExecInfo) {
+		ei.SecurityProfile = name
+	})
+}
+
+// WithNetworkName is a RunOption that attaches the exec to the named CNI
+// network configured in the daemon instead of the default network.
+func WithNetworkName(name string) RunOption {
+	return runOptionFunc(func(ei *ExecInfo) {
+		ei.NetworkName = name
+	})
+}
+
+// WithRuntime is a RunOption that runs the exec with the named OCI runtime
+// configured in the daemon, e.g. runsc, instead of the default runtime of the
+// worker.
+func WithRuntime(name string) RunOption {
+	return runOptionFunc(func(ei *ExecInfo) {
+		ei.Runtime = name
+	})
+}
+
+// WithCheckpoint is a RunOption that checkpoints the process of the exec with
+// CRIU when the build is cancelled or, if timeout is not zero, when the
+// process has run for longer than timeout. The next build of the exec with
+// the same inputs resumes the process from the checkpoint instead of starting
+// it again. Workers without checkpoint support run the exec normally.
+func WithCheckpoint(timeout time.Duration) RunOption {
+	return runOptionFunc(func(ei *ExecInfo) {
+		ei.Checkpoint = &CheckpointInfo{Timeout: timeout}
+	})
+}
+
+type CheckpointInfo struct {
+	Timeout time.Duration
+}
+
+// AddService is a RunOption that starts a service container from the root
+// filesystem of st before the command of the exec runs. The service shares
+// the network namespace of the command, can be reached by the command with
+// name as the host name and is stopped when the command exits. The
+// environment, working directory and user of the service are taken from st.
+// Services can't be added to an exec with the host network mode.
+func AddService(name string, st State, opts ...ServiceOption) RunOption {
+	return runOptionFunc(func(ei *ExecInfo) {
+		si := ServiceInfo{Name: name, State: st}
+		for _, opt := range opts {
+			opt.SetServiceOption(&si)
+		}
+		ei.Services = append(ei.Services, si)
+	})
+}
+
+type ServiceOption interface {
+	SetServiceOption(*ServiceInfo)
+}
+
+type serviceOptionFunc func(*ServiceInfo)
+
+func (fn serviceOptionFunc) SetServiceOption(si *ServiceInfo) {
+	fn(si)
+}
+
+// ServiceArgs sets the command of the service. By default the args of the
+// state of the service are used.
+func ServiceArgs(args ...string) ServiceOption {
+	return serviceOptionFunc(func(si *ServiceInfo) {
+		si.Args = args
+	})
+}
+
+// ServiceReadyTCP delays the command of the exec until the service accepts
+// connections on port.
+func ServiceReadyTCP(port int) ServiceOption {
+	return serviceOptionFunc(func(si *ServiceInfo) {
+		si.ReadyTCPPort = port
+	})
+}
+
+// ServiceReadyExec delays the command of the exec until args run in the
+// service container exit with code 0.
+func ServiceReadyExec(args ...string) ServiceOption {
+	return serviceOptionFunc(func(si *ServiceInfo) {
+		si.ReadyExec = args
+	})
+}
+
+// ServiceReadyTimeout sets the maximum time to wait for the readiness checks
+// of the service to succeed.
+func ServiceReadyTimeout(d time.Duration) ServiceOption {
+	return serviceOptionFunc(func(si *ServiceInfo) {
+		si.ReadyTimeout = d
+	})
+}
+
+type ServiceInfo struct {
+	Name  string
+	State State
+	Args  []string
+
+	ReadyTCPPort int
+	ReadyExec    []string
+	ReadyTimeout time.Duration
+}
+
+func (si ServiceInfo) marshal(ctx context.Context, c *Constraints) (*pb.ServiceContainer, error) {
+	args := si.Args
+	if len(args) == 0 {
+		var err error
+		args, err = getArgs(si.State)(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			return nil, errors.Errorf("arguments are required for service %s", si.Name)
+		}
+	}
+	env, err := getEnv(si.State)(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := getDir(si.State)(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := getUser(si.State)(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	sc := &pb.ServiceContainer{
+		Name: si.Name,
+		Meta: &pb.Meta{
+			Args: args,
+			Env:  env.ToArray(),
+			Cwd:  cwd,
+			User: user,
+		},
+	}
+	if si.ReadyTCPPort != 0 || len(si.ReadyExec) > 0 {
+		sc.Readiness = &pb.ReadinessCheck{
+			TcpPort: int32(si.ReadyTCPPort),
+			Exec:    si.ReadyExec,
+			Timeout: int64(si.ReadyTimeout / time.Second),
+		}
+	}
+	return sc, nil
+}
+
 func ValidExitCodes(codes ...int) RunOption {
 	return runOptionFunc(func(ei *ExecInfo) {
 		ei.State = validExitCodes(codes...)(ei.State)
@@ -875,6 +1111,16 @@ type ExecInfo struct {
 	Secrets        []SecretInfo
 	SSH            []SSHInfo
 	CDIDevices     []CDIDeviceInfo
+	// SecurityProfile is the name of the security profile of the exec.
+	SecurityProfile string
+	// NetworkName is the name of the CNI network of the exec.
+	NetworkName string
+	// Services are started before the exec and stopped after it.
+	Services []ServiceInfo
+	// Checkpoint enables checkpointing the process of the exec.
+	Checkpoint *CheckpointInfo
+	// Runtime is the name of the OCI runtime of the exec.
+	Runtime string
 }
 
 type MountInfo struct {
@@ -3,8 +3,10 @@ package llb
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -172,3 +174,132 @@ func TestExecOpMarshalConsistency(t *testing.T) {
 		prevDef = def.Def
 	}
 }
+
+func TestLinuxResourcesLimitsMarshal(t *testing.T) {
+	t.Parallel()
+
+	st := Image("busybox:latest").
+		Run(
+			Shlex("true"),
+			PidsLimit(100),
+			BlkioWeight(300),
+			BlkioDeviceWriteBps("/dev/sda", 1024*1024),
+			DiskQuota(1<<30),
+			AddMount("/cache", Scratch(), AsPersistentCacheDir("id", CacheMountShared), CacheMountSizeLimit(1<<20)),
+		).Root()
+
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	var found bool
+	for _, md := range def.Metadata {
+		if md.LinuxResources == nil {
+			continue
+		}
+		found = true
+		res := md.LinuxResources
+		require.Equal(t, int64(100), res.PidsLimit)
+		require.Equal(t, uint32(300), res.BlkioWeight)
+		require.Len(t, res.BlkioDeviceWriteBps, 1)
+		require.Equal(t, "/dev/sda", res.BlkioDeviceWriteBps[0].Path)
+		require.Equal(t, uint64(1024*1024), res.BlkioDeviceWriteBps[0].Rate)
+		require.Equal(t, int64(1<<30), res.DiskQuota)
+		require.True(t, md.Caps[pb.CapExecMetaLinuxResourcesPidsBlkio])
+		require.True(t, md.Caps[pb.CapExecMetaLinuxResourcesDiskQuota])
+		require.True(t, md.Caps[pb.CapExecMountCacheSizeLimit])
+	}
+	require.True(t, found, "LinuxResources not found in OpMetadata")
+
+	var cacheMount *pb.Mount
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		for _, m := range op.GetExec().GetMounts() {
+			if m.MountType == pb.MountType_CACHE {
+				cacheMount = m
+			}
+		}
+	}
+	require.NotNil(t, cacheMount)
+	require.Equal(t, int64(1<<20), cacheMount.CacheOpt.SizeLimit)
+}
+
+func TestExecServicesMarshal(t *testing.T) {
+	t.Parallel()
+
+	redis := Image("redis:alpine").AddEnv("REDIS_ARGS", "--save ''")
+	st := Image("golang:alpine").
+		Run(
+			Shlex("go test ./..."),
+			AddService("redis", redis,
+				ServiceArgs("redis-server"),
+				ServiceReadyTCP(6379),
+				ServiceReadyTimeout(30*time.Second),
+			),
+			AddService("cache", redis, ServiceArgs("redis-server", "--port", "6380"), ServiceReadyExec("redis-cli", "-p", "6380", "ping")),
+		).Root()
+
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	m, arr := parseDef(t, def.Def)
+	exec := arr[len(arr)-2]
+	require.NotNil(t, exec.GetExec())
+	require.Len(t, exec.Inputs, 2)
+
+	services := exec.GetExec().Services
+	require.Len(t, services, 2)
+
+	require.Equal(t, "redis", services[0].Name)
+	require.Equal(t, []string{"redis-server"}, services[0].Meta.Args)
+	require.Contains(t, services[0].Meta.Env, "REDIS_ARGS=--save ''")
+	require.Equal(t, int32(6379), services[0].Readiness.TcpPort)
+	require.Equal(t, int64(30), services[0].Readiness.Timeout)
+
+	require.Equal(t, "cache", services[1].Name)
+	require.Equal(t, []string{"redis-cli", "-p", "6380", "ping"}, services[1].Readiness.Exec)
+	require.Equal(t, services[0].Input, services[1].Input)
+
+	redisOp := m[exec.Inputs[services[0].Input].Digest]
+	require.Equal(t, "docker-image://docker.io/library/redis:alpine", redisOp.GetSource().Identifier)
+
+	require.True(t, def.Metadata[digest.FromBytes(def.Def[len(def.Def)-2])].Caps[pb.CapExecMetaServices])
+}
+
+func TestExecServiceRequiresRootfs(t *testing.T) {
+	t.Parallel()
+
+	st := Image("busybox").Run(Shlex("true"), AddService("db", Scratch(), ServiceArgs("db"))).Root()
+	_, err := st.Marshal(context.TODO())
+	require.ErrorContains(t, err, "service db requires a root filesystem")
+}
+
+func TestExecCheckpointMarshal(t *testing.T) {
+	t.Parallel()
+
+	st := Image("gcc").Run(Shlex("make -j8"), WithCheckpoint(90*time.Minute)).Root()
+
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	_, arr := parseDef(t, def.Def)
+	exec := arr[len(arr)-2]
+	require.NotNil(t, exec.GetExec())
+	require.Equal(t, int64(5400), exec.GetExec().Checkpoint.Timeout)
+	require.True(t, def.Metadata[digest.FromBytes(def.Def[len(def.Def)-2])].Caps[pb.CapExecMetaCheckpoint])
+}
+
+func TestExecRuntimeMarshal(t *testing.T) {
+	t.Parallel()
+
+	st := Image("alpine").Run(Shlex("make test"), WithRuntime("runsc")).Root()
+
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	_, arr := parseDef(t, def.Def)
+	exec := arr[len(arr)-2]
+	require.NotNil(t, exec.GetExec())
+	require.Equal(t, "runsc", exec.GetExec().Runtime)
+	require.True(t, def.Metadata[digest.FromBytes(def.Def[len(def.Def)-2])].Caps[pb.CapExecMetaRuntime])
+}
@@ -324,7 +324,8 @@ func getCgroupParent(s State) func(context.Context, *Constraints) (string, error
 	}
 }
 
-// LinuxResources holds CPU and memory resource limits for containers.
+// LinuxResources holds CPU, memory, process, IO and disk resource limits
+// for containers.
 type LinuxResources struct {
 	Memory     int64
 	MemorySwap int64
@@ -333,6 +334,22 @@ type LinuxResources struct {
 	CPUQuota   int64
 	CpusetCpus string
 	CpusetMems string
+	PidsLimit  int64
+	// BlkioWeight is the relative block IO weight in the range 10-1000.
+	BlkioWeight          uint16
+	BlkioDeviceReadBps   []ThrottleDevice
+	BlkioDeviceWriteBps  []ThrottleDevice
+	BlkioDeviceReadIOps  []ThrottleDevice
+	BlkioDeviceWriteIOps []ThrottleDevice
+	// DiskQuota limits the size of the writable root layer in bytes.
+	DiskQuota int64
+}
+
+// ThrottleDevice limits the IO rate of the block device at Path on the host
+// of the worker.
+type ThrottleDevice struct {
+	Path string
+	Rate uint64
 }
 
 // Network returns a [StateOption] which sets the network mode used for containers created by [State.Run].
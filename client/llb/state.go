@@ -8,6 +8,7 @@ import (
 	"net"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/containerd/platforms"
 	"github.com/moby/buildkit/identity"
@@ -181,6 +182,9 @@ func (s State) Marshal(ctx context.Context, co ...ConstraintsOpt) (*Definition,
 		if m.ExportCache != nil {
 			md.Caps[pb.CapMetaExportCache] = true
 		}
+		if m.ExecutionPolicy != nil {
+			md.Caps[pb.CapMetaExecutionPolicy] = true
+		}
 	}
 
 	def.Metadata[dgst] = md
@@ -297,6 +301,11 @@ func (s State) Run(ro ...RunOption) ExecState {
 	exec.secrets = ei.Secrets
 	exec.ssh = ei.SSH
 	exec.cdiDevices = ei.CDIDevices
+	exec.secProfile = ei.SecurityProfile
+	exec.networkName = ei.NetworkName
+	exec.services = ei.Services
+	exec.checkpoint = ei.Checkpoint
+	exec.runtime = ei.Runtime
 
 	return ExecState{
 		State: s.WithOutput(exec.Output()),
@@ -607,6 +616,21 @@ func mergeMetadata(m1, m2 OpMetadata) OpMetadata {
 		m1.LinuxResources = m2.LinuxResources
 	}
 
+	if m2.ExecutionPolicy != nil {
+		if m1.ExecutionPolicy == nil {
+			m1.ExecutionPolicy = m2.ExecutionPolicy
+		} else {
+			ep := m1.ExecutionPolicy.CloneVT()
+			if m2.ExecutionPolicy.Timeout != 0 {
+				ep.Timeout = m2.ExecutionPolicy.Timeout
+			}
+			if m2.ExecutionPolicy.Retry != nil {
+				ep.Retry = m2.ExecutionPolicy.Retry
+			}
+			m1.ExecutionPolicy = ep
+		}
+	}
+
 	return m1
 }
 
@@ -690,6 +714,8 @@ type OpMetadata struct {
 	Caps           map[apicaps.CapID]bool `json:"caps,omitempty"`
 	ProgressGroup  *pb.ProgressGroup      `json:"progress_group,omitempty"`
 	LinuxResources *pb.LinuxResources     `json:"linux_resources,omitempty"`
+	// ExecutionPolicy is not part of the cache key.
+	ExecutionPolicy *pb.ExecutionPolicy `json:"execution_policy,omitempty"`
 }
 
 func NewOpMetadata(mpb *pb.OpMetadata) OpMetadata {
@@ -704,12 +730,13 @@ func (m OpMetadata) ToPB() *pb.OpMetadata {
 		caps[string(k)] = v
 	}
 	return &pb.OpMetadata{
-		IgnoreCache:    m.IgnoreCache,
-		Description:    m.Description,
-		ExportCache:    m.ExportCache,
-		Caps:           caps,
-		ProgressGroup:  m.ProgressGroup,
-		LinuxResources: m.LinuxResources,
+		IgnoreCache:     m.IgnoreCache,
+		Description:     m.Description,
+		ExportCache:     m.ExportCache,
+		Caps:            caps,
+		ProgressGroup:   m.ProgressGroup,
+		LinuxResources:  m.LinuxResources,
+		ExecutionPolicy: m.ExecutionPolicy,
 	}
 }
 
@@ -731,6 +758,7 @@ func (m *OpMetadata) FromPB(mpb *pb.OpMetadata) {
 	}
 	m.ProgressGroup = mpb.ProgressGroup
 	m.LinuxResources = mpb.LinuxResources
+	m.ExecutionPolicy = mpb.ExecutionPolicy
 }
 
 func Platform(p ocispecs.Platform) ConstraintsOpt {
@@ -751,22 +779,101 @@ func ProgressGroup(id, name string, weak bool) ConstraintsOpt {
 	})
 }
 
-// WithLinuxResources sets all CPU/memory resource limits at once.
+// WithLinuxResources sets all resource limits at once.
 // Resource limits are applied via OpMetadata and do not affect the cache key.
 func WithLinuxResources(res LinuxResources) ConstraintsOpt {
 	return constraintsOptFunc(func(c *Constraints) {
 		c.Metadata.LinuxResources = &pb.LinuxResources{
-			Memory:     res.Memory,
-			MemorySwap: res.MemorySwap,
-			CpuShares:  res.CPUShares,
-			CpuPeriod:  res.CPUPeriod,
-			CpuQuota:   res.CPUQuota,
-			CpusetCpus: res.CpusetCpus,
-			CpusetMems: res.CpusetMems,
+			Memory:               res.Memory,
+			MemorySwap:           res.MemorySwap,
+			CpuShares:            res.CPUShares,
+			CpuPeriod:            res.CPUPeriod,
+			CpuQuota:             res.CPUQuota,
+			CpusetCpus:           res.CpusetCpus,
+			CpusetMems:           res.CpusetMems,
+			PidsLimit:            res.PidsLimit,
+			BlkioWeight:          uint32(res.BlkioWeight),
+			BlkioDeviceReadBps:   throttleDevices(res.BlkioDeviceReadBps),
+			BlkioDeviceWriteBps:  throttleDevices(res.BlkioDeviceWriteBps),
+			BlkioDeviceReadIOps:  throttleDevices(res.BlkioDeviceReadIOps),
+			BlkioDeviceWriteIOps: throttleDevices(res.BlkioDeviceWriteIOps),
+			DiskQuota:            res.DiskQuota,
 		}
 	})
 }
 
+func throttleDevices(devs []ThrottleDevice) []*pb.LinuxThrottleDevice {
+	if len(devs) == 0 {
+		return nil
+	}
+	out := make([]*pb.LinuxThrottleDevice, len(devs))
+	for i, d := range devs {
+		out[i] = &pb.LinuxThrottleDevice{Path: d.Path, Rate: d.Rate}
+	}
+	return out
+}
+
+// Error classes that can be retried with [WithRetry].
+const (
+	// RetryOnNetworkError retries errors from failed network connections,
+	// e.g. while fetching git or http sources.
+	RetryOnNetworkError = "network"
+	// RetryOnTimeout retries attempts that exceeded the timeout set with
+	// [WithTimeout].
+	RetryOnTimeout = "timeout"
+)
+
+// RetryPolicy defines how an operation that failed is retried by the solver.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It is doubled on every
+	// following retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// ExitCodes of the process of an exec operation that are retried.
+	// Processes stopped by the timeout are only retried with
+	// [RetryOnTimeout].
+	ExitCodes []int
+	// ErrorClasses that are retried, e.g. [RetryOnNetworkError].
+	// If neither ExitCodes nor ErrorClasses are set, all errors are retried.
+	ErrorClasses []string
+}
+
+func ensureExecutionPolicy(c *Constraints) *pb.ExecutionPolicy {
+	if c.Metadata.ExecutionPolicy == nil {
+		c.Metadata.ExecutionPolicy = &pb.ExecutionPolicy{}
+	} else {
+		c.Metadata.ExecutionPolicy = c.Metadata.ExecutionPolicy.CloneVT()
+	}
+	return c.Metadata.ExecutionPolicy
+}
+
+// WithTimeout limits the duration of a single attempt to run an operation.
+// Like other metadata, the timeout does not affect the cache key.
+func WithTimeout(d time.Duration) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		ensureExecutionPolicy(c).Timeout = int64(d)
+	})
+}
+
+// WithRetry retries an operation that failed according to the policy.
+// Like other metadata, the policy does not affect the cache key.
+func WithRetry(p RetryPolicy) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		rp := &pb.RetryPolicy{
+			MaxAttempts:  int64(p.MaxAttempts),
+			Backoff:      int64(p.Backoff),
+			MaxBackoff:   int64(p.MaxBackoff),
+			ErrorClasses: slices.Clone(p.ErrorClasses),
+		}
+		for _, code := range p.ExitCodes {
+			rp.ExitCodes = append(rp.ExitCodes, int32(code))
+		}
+		ensureExecutionPolicy(c).Retry = rp
+	})
+}
+
 func ensureLinuxResources(c *Constraints) *pb.LinuxResources {
 	if c.Metadata.LinuxResources == nil {
 		c.Metadata.LinuxResources = &pb.LinuxResources{}
@@ -816,6 +923,54 @@ func CpusetMems(mems string) ConstraintsOpt {
 	})
 }
 
+func PidsLimit(limit int64) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		ensureLinuxResources(c).PidsLimit = limit
+	})
+}
+
+func BlkioWeight(weight uint16) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		ensureLinuxResources(c).BlkioWeight = uint32(weight)
+	})
+}
+
+func BlkioDeviceReadBps(path string, rate uint64) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		res := ensureLinuxResources(c)
+		res.BlkioDeviceReadBps = append(slices.Clone(res.BlkioDeviceReadBps), &pb.LinuxThrottleDevice{Path: path, Rate: rate})
+	})
+}
+
+func BlkioDeviceWriteBps(path string, rate uint64) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		res := ensureLinuxResources(c)
+		res.BlkioDeviceWriteBps = append(slices.Clone(res.BlkioDeviceWriteBps), &pb.LinuxThrottleDevice{Path: path, Rate: rate})
+	})
+}
+
+func BlkioDeviceReadIOps(path string, rate uint64) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		res := ensureLinuxResources(c)
+		res.BlkioDeviceReadIOps = append(slices.Clone(res.BlkioDeviceReadIOps), &pb.LinuxThrottleDevice{Path: path, Rate: rate})
+	})
+}
+
+func BlkioDeviceWriteIOps(path string, rate uint64) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		res := ensureLinuxResources(c)
+		res.BlkioDeviceWriteIOps = append(slices.Clone(res.BlkioDeviceWriteIOps), &pb.LinuxThrottleDevice{Path: path, Rate: rate})
+	})
+}
+
+// DiskQuota limits the size of the writable root layer of an exec.
+// Writes that exceed the limit fail.
+func DiskQuota(limit int64) ConstraintsOpt {
+	return constraintsOptFunc(func(c *Constraints) {
+		ensureLinuxResources(c).DiskQuota = limit
+	})
+}
+
 var (
 	LinuxAmd64   = Platform(ocispecs.Platform{OS: "linux", Architecture: "amd64"})
 	LinuxArmhf   = Platform(ocispecs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"})
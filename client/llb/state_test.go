@@ -3,6 +3,7 @@ package llb
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/moby/buildkit/solver/pb"
 	digest "github.com/opencontainers/go-digest"
@@ -351,3 +352,36 @@ func getEnvHelper(t *testing.T, s State, k string) (string, bool) {
 	require.NoError(t, err)
 	return v, ok
 }
+
+func TestExecutionPolicy(t *testing.T) {
+	t.Parallel()
+
+	st1 := Git("https://github.com/moby/buildkit.git", "master")
+	st2 := Git("https://github.com/moby/buildkit.git", "master",
+		WithTimeout(time.Minute),
+		WithRetry(RetryPolicy{
+			MaxAttempts:  3,
+			Backoff:      time.Second,
+			ErrorClasses: []string{RetryOnNetworkError},
+		}),
+	)
+
+	def1, err := st1.Marshal(context.TODO())
+	require.NoError(t, err)
+	def2, err := st2.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	// the execution policy must not change the cache key
+	require.Equal(t, def1.Def, def2.Def)
+
+	dgst := digest.FromBytes(def2.Def[0])
+	md := def2.Metadata[dgst]
+	require.NotNil(t, md.ExecutionPolicy)
+	require.Equal(t, int64(time.Minute), md.ExecutionPolicy.Timeout)
+	require.Equal(t, int64(3), md.ExecutionPolicy.Retry.MaxAttempts)
+	require.Equal(t, int64(time.Second), md.ExecutionPolicy.Retry.Backoff)
+	require.Equal(t, []string{RetryOnNetworkError}, md.ExecutionPolicy.Retry.ErrorClasses)
+
+	rootDgst := digest.FromBytes(def2.Def[len(def2.Def)-1])
+	require.True(t, def2.Metadata[rootDgst].Caps[pb.CapMetaExecutionPolicy])
+}
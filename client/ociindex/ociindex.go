@@ -6,10 +6,12 @@ import (
 	"maps"
 	"os"
 	"path"
+	"slices"
 	"syscall"
 
 	"github.com/containerd/containerd/v2/pkg/reference"
 	"github.com/gofrs/flock"
+	digest "github.com/opencontainers/go-digest"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 )
@@ -105,7 +107,49 @@ func (s StoreIndex) Put(desc ocispecs.Descriptor, names ...NameOrTag) error {
 		return err
 	}
 
-	// modify the index file
+	return s.modify(func(idx *ocispecs.Index) error {
+		namesp := make([]*NameOrTag, 0, len(names))
+		for _, n := range names {
+			namesp = append(namesp, &n)
+		}
+		if len(names) == 0 {
+			namesp = append(namesp, nil)
+		}
+
+		for _, name := range namesp {
+			if err := insertDesc(idx, desc, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove removes all descriptors with digest dgst from the index.
+func (s StoreIndex) Remove(dgst digest.Digest) error {
+	lock := flock.New(s.lockPath)
+	locked, err := lock.TryLock()
+	if err != nil {
+		return errors.Wrapf(err, "could not lock %s", s.lockPath)
+	}
+	if !locked {
+		return errors.Errorf("could not lock %s", s.lockPath)
+	}
+	defer func() {
+		lock.Unlock()
+		os.RemoveAll(s.lockPath)
+	}()
+
+	return s.modify(func(idx *ocispecs.Index) error {
+		idx.Manifests = slices.DeleteFunc(idx.Manifests, func(m ocispecs.Descriptor) bool {
+			return m.Digest == dgst
+		})
+		return nil
+	})
+}
+
+// modify updates the index file with fn. The caller must hold the lock.
+func (s StoreIndex) modify(fn func(*ocispecs.Index) error) error {
 	idxFile, err := os.OpenFile(s.indexPath, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return errors.Wrapf(err, "could not open %s", s.indexPath)
@@ -125,18 +169,8 @@ func (s StoreIndex) Put(desc ocispecs.Descriptor, names ...NameOrTag) error {
 
 	setOCIIndexDefaults(&idx)
 
-	namesp := make([]*NameOrTag, 0, len(names))
-	for _, n := range names {
-		namesp = append(namesp, &n)
-	}
-	if len(names) == 0 {
-		namesp = append(namesp, nil)
-	}
-
-	for _, name := range namesp {
-		if err = insertDesc(&idx, desc, name); err != nil {
-			return err
-		}
+	if err := fn(&idx); err != nil {
+		return err
 	}
 
 	idxData, err = json.Marshal(idx)
@@ -129,6 +129,25 @@ func TestAddDescriptor(t *testing.T) {
 	assert.Equal(t, 2, readIdx.SchemaVersion)
 }
 
+func TestRemoveDescriptor(t *testing.T) {
+	dir := t.TempDir()
+
+	one := randDescriptor("foo")
+	two := randDescriptor("bar")
+
+	store := NewStoreIndex(dir)
+	require.NoError(t, store.Put(one, Tag("a")))
+	require.NoError(t, store.Put(one, Tag("b")))
+	require.NoError(t, store.Put(two, Tag("c")))
+
+	require.NoError(t, store.Remove(one.Digest))
+
+	readIdx, err := store.Read()
+	require.NoError(t, err)
+	require.Len(t, readIdx.Manifests, 1)
+	assert.Equal(t, two.Digest, readIdx.Manifests[0].Digest)
+}
+
 func TestAddDescriptorWithTag(t *testing.T) {
 	dir := t.TempDir()
 
@@ -57,7 +57,20 @@ type SolveOpt struct {
 	SourcePolicy          *spb.Policy
 	SourcePolicyProvider  session.Attachable
 	ProxyNetwork          bool
-	Ref                   string
+	// ProxyRecord stores the response bodies of successful GET requests made
+	// through the proxy network so that they can be replayed later.
+	ProxyRecord bool
+	// ProxyReplay serves requests made through the proxy network only from
+	// recorded responses if it is not nil. It maps the URLs that can be
+	// requested to the digests of the recorded responses.
+	ProxyReplay map[string]digest.Digest
+	Ref         string
+	// Priority is the priority class of the build: "low", "normal" or
+	// "high". Defaults to "normal".
+	Priority string
+	// Tenant identifies the owner of the build for sharing the parallelism
+	// limit of the workers fairly between builds.
+	Tenant string
 }
 
 type ExportEntry struct {
@@ -161,7 +174,7 @@ func (c *Client) solve(ctx context.Context, def *llb.Definition, runGateway runG
 			switch ex.Type {
 			case ExporterLocal:
 				supportDir = true
-			case ExporterTar:
+			case ExporterTar, ExporterFSImage:
 				supportFile = true
 			case ExporterOCI, ExporterDocker:
 				supportFile = ex.Output != nil
@@ -329,10 +342,20 @@ func (c *Client) solve(ctx context.Context, def *llb.Definition, runGateway runG
 			CompatibilityVersion:    int64(opt.CompatibilityVersion),
 			SourcePolicy:            opt.SourcePolicy,
 			ProxyNetwork:            opt.ProxyNetwork,
+			ProxyRecord:             opt.ProxyRecord,
+			Priority:                opt.Priority,
+			Tenant:                  opt.Tenant,
 		}
 		if opt.SourcePolicyProvider != nil {
 			sopt.SourcePolicySession = s.ID()
 		}
+		if opt.ProxyReplay != nil {
+			sopt.ProxyReplay = true
+			sopt.ProxyReplayMaterials = make(map[string]string, len(opt.ProxyReplay))
+			for u, dgst := range opt.ProxyReplay {
+				sopt.ProxyReplayMaterials[u] = dgst.String()
+			}
+		}
 
 		resp, err := c.ControlClient().Solve(ctx, sopt)
 		if err != nil {
@@ -574,6 +597,24 @@ func parseCacheOptions(ctx context.Context, isGateway bool, opt SolveOpt) (*cach
 			// TODO(AkihiroSuda): support custom index JSON path and tag
 			storesToUpdate[csDir] = tag
 
+			if v, ok := ex.Attrs["merge"]; ok {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to parse merge attribute")
+				}
+				if b {
+					// the daemon can't read index.json, so pass the manifest
+					// to merge into explicitly
+					desc, err := ociindex.NewStoreIndex(csDir).Get(tag)
+					if err != nil {
+						return nil, errors.Wrap(err, "failed to read cache manifest for merging")
+					}
+					if desc != nil {
+						ex.Attrs["merge_digest"] = desc.Digest.String()
+					}
+				}
+			}
+
 			if v, ok := ex.Attrs["reset"]; ok {
 				b, err := strconv.ParseBool(v)
 				if err != nil {
@@ -76,6 +76,10 @@ var buildCommand = &cli.Command{
 			Name:  "no-cache",
 			Usage: "Disable cache for all the vertices",
 		},
+		&cli.BoolFlag{
+			Name:  "check-reproducible",
+			Usage: "Run exec and file vertices twice and report differences in their results as warnings",
+		},
 		&cli.StringSliceFlag{
 			Name:  "export-cache",
 			Usage: "Export build cache, e.g. --export-cache type=registry,ref=example.com/foo/bar, or --export-cache type=local,dest=path/to/dir",
@@ -90,7 +94,7 @@ var buildCommand = &cli.Command{
 		},
 		&cli.StringSliceFlag{
 			Name:  "allow",
-			Usage: "Allow extra privileged entitlement, e.g. network.host, security.insecure, device",
+			Usage: "Allow extra privileged entitlement, e.g. network.host, network.custom, security.insecure, security.profile, security.runtime, device",
 		},
 		&cli.StringSliceFlag{
 			Name:  "ssh",
@@ -108,6 +112,22 @@ var buildCommand = &cli.Command{
 			Name:  "proxy-network",
 			Usage: "Run build with proxy network enforcement",
 		},
+		&cli.BoolFlag{
+			Name:  "proxy-record",
+			Usage: "Record the responses of proxy network requests for offline replay",
+		},
+		&cli.StringFlag{
+			Name:  "proxy-replay",
+			Usage: "Serve proxy network requests only from responses recorded for the materials of a provenance file",
+		},
+		&cli.StringFlag{
+			Name:  "priority",
+			Usage: "Priority class of the build when workers are saturated: low, normal or high (high requires a daemon that trusts the client)",
+		},
+		&cli.StringFlag{
+			Name:  "tenant",
+			Usage: "Tenant of the build, workers share their parallelism limit fairly between tenants (requires a daemon that trusts the client)",
+		},
 		&cli.StringFlag{
 			Name:  "ref-file",
 			Usage: "Write build ref to a file",
@@ -248,6 +268,13 @@ func buildAction(clicontext *cli.Command) error {
 		}
 		srcPol = &srcPolStruct
 	}
+	var proxyReplay map[string]digest.Digest
+	if fn := clicontext.String("proxy-replay"); fn != "" {
+		proxyReplay, err = build.ParseProxyReplay(fn)
+		if err != nil {
+			return err
+		}
+	}
 	eg, ctx := errgroup.WithContext(bccommon.CommandContext(clicontext))
 
 	ref := identity.NewID()
@@ -264,13 +291,22 @@ func buildAction(clicontext *cli.Command) error {
 		AllowedEntitlements: clicontext.StringSlice("allow"),
 		SourcePolicy:        srcPol,
 		ProxyNetwork:        clicontext.Bool("proxy-network"),
+		ProxyRecord:         clicontext.Bool("proxy-record"),
+		ProxyReplay:         proxyReplay,
 		Ref:                 ref,
+		Priority:            clicontext.String("priority"),
+		Tenant:              clicontext.String("tenant"),
 	}
 
 	solveOpt.FrontendAttrs, err = build.ParseOpt(clicontext.StringSlice("opt"))
 	if err != nil {
 		return errors.Wrap(err, "invalid opt")
 	}
+	if clicontext.Bool("check-reproducible") {
+		if _, ok := solveOpt.FrontendAttrs["reproducible-check"]; !ok {
+			solveOpt.FrontendAttrs["reproducible-check"] = "true"
+		}
+	}
 
 	solveOpt.LocalMounts, err = build.ParseLocal(clicontext.StringSlice("local"))
 	if err != nil {
@@ -78,7 +78,7 @@ func resolveExporterDest(exporter, dest string, attrs map[string]string) (filesy
 	switch exporter {
 	case client.ExporterLocal:
 		supportDir = true
-	case client.ExporterTar:
+	case client.ExporterTar, client.ExporterFSImage:
 		supportFile = true
 	case client.ExporterOCI, client.ExporterDocker:
 		tar, err := strconv.ParseBool(attrs["tar"])
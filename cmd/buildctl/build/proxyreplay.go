@@ -0,0 +1,63 @@
+package build
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+type provenanceMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenancePredicate struct {
+	// SLSA v0.2
+	Materials []provenanceMaterial `json:"materials"`
+	// SLSA v1
+	BuildDefinition struct {
+		ResolvedDependencies []provenanceMaterial `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+}
+
+// ParseProxyReplay parses --proxy-replay. The file is a SLSA provenance
+// statement or predicate of an earlier build. Its HTTP materials are the
+// responses that can be replayed.
+func ParseProxyReplay(fn string) (map[string]digest.Digest, error) {
+	dt, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var stmt struct {
+		Predicate *provenancePredicate `json:"predicate"`
+	}
+	if err := json.Unmarshal(dt, &stmt); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse provenance %q", fn)
+	}
+	pred := stmt.Predicate
+	if pred == nil {
+		pred = &provenancePredicate{}
+		if err := json.Unmarshal(dt, pred); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse provenance %q", fn)
+		}
+	}
+	out := map[string]digest.Digest{}
+	for _, m := range append(pred.Materials, pred.BuildDefinition.ResolvedDependencies...) {
+		if !strings.HasPrefix(m.URI, "http://") && !strings.HasPrefix(m.URI, "https://") {
+			continue
+		}
+		v, ok := m.Digest[digest.SHA256.String()]
+		if !ok {
+			continue
+		}
+		dgst := digest.NewDigestFromEncoded(digest.SHA256, v)
+		if err := dgst.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid digest for material %s", m.URI)
+		}
+		out[m.URI] = dgst
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProxyReplay(t *testing.T) {
+	const (
+		sha  = "6e4b94fc270e708e1068be28bd3551dc6917a4fc5a61293d51bb36e6b75c4b53"
+		sha2 = "1e4b94fc270e708e1068be28bd3551dc6917a4fc5a61293d51bb36e6b75c4b53"
+	)
+	for _, tc := range []struct {
+		name string
+		dt   string
+	}{
+		{
+			name: "v1 statement",
+			dt: `{"predicateType":"https://slsa.dev/provenance/v1","predicate":{"buildDefinition":{"resolvedDependencies":[
+				{"uri":"pkg:docker/alpine@3.20","digest":{"sha256":"` + sha2 + `"}},
+				{"uri":"https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz","digest":{"sha256":"` + sha + `"}}
+			]}}}`,
+		},
+		{
+			name: "v0.2 predicate",
+			dt: `{"materials":[
+				{"uri":"pkg:docker/alpine@3.20","digest":{"sha256":"` + sha2 + `"}},
+				{"uri":"https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz","digest":{"sha256":"` + sha + `"}}
+			]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "provenance.json")
+			require.NoError(t, os.WriteFile(fn, []byte(tc.dt), 0600))
+			replay, err := ParseProxyReplay(fn)
+			require.NoError(t, err)
+			require.Equal(t, map[string]digest.Digest{
+				"https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz": digest.NewDigestFromEncoded(digest.SHA256, sha),
+			}, replay)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package build
+
+import (
+	"os"
+
+	"github.com/moby/buildkit/client"
+	"github.com/pkg/errors"
+)
+
+// ParsePruneRemoteCache parses --type and --opt of prune-remote
+func ParsePruneRemoteCache(typ string, opts []string) (client.CacheOptionsEntry, error) {
+	if typ == "" {
+		return client.CacheOptionsEntry{}, errors.New("prune-remote requires --type")
+	}
+	attrs, err := attrMap(opts)
+	if err != nil {
+		return client.CacheOptionsEntry{}, err
+	}
+	entry := client.CacheOptionsEntry{
+		Type:  typ,
+		Attrs: attrs,
+	}
+	if entry.Type == "gha" {
+		// listing and deleting entries needs the REST API in addition to
+		// the cache runtime API
+		if _, ok := entry.Attrs["repository"]; !ok {
+			if v, ok := os.LookupEnv("GITHUB_REPOSITORY"); ok {
+				entry.Attrs["repository"] = v
+			}
+		}
+		if _, ok := entry.Attrs["ghtoken"]; !ok {
+			if v, ok := os.LookupEnv("GITHUB_TOKEN"); ok {
+				entry.Attrs["ghtoken"] = v
+			}
+		}
+		return loadGithubEnv(entry)
+	}
+	return entry, nil
+}
@@ -0,0 +1,34 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePruneRemoteCache(t *testing.T) {
+	_, err := ParsePruneRemoteCache("", nil)
+	require.ErrorContains(t, err, "requires --type")
+
+	_, err = ParsePruneRemoteCache("s3", []string{"bucket"})
+	require.ErrorContains(t, err, "invalid value")
+
+	entry, err := ParsePruneRemoteCache("s3", []string{"bucket=foo", "region=eu-west-1"})
+	require.NoError(t, err)
+	require.Equal(t, "s3", entry.Type)
+	require.Equal(t, map[string]string{"bucket": "foo", "region": "eu-west-1"}, entry.Attrs)
+
+	t.Setenv("ACTIONS_CACHE_SERVICE_V2", "")
+	t.Setenv("ACTIONS_CACHE_URL", "https://github.com/test")
+	t.Setenv("ACTIONS_RUNTIME_TOKEN", "bar")
+	t.Setenv("GITHUB_REPOSITORY", "moby/buildkit")
+	t.Setenv("GITHUB_TOKEN", "ghtoken")
+	entry, err = ParsePruneRemoteCache("gha", nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"url":        "https://github.com/test",
+		"token":      "bar",
+		"repository": "moby/buildkit",
+		"ghtoken":    "ghtoken",
+	}, entry.Attrs)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moby/buildkit/cache/remotecache"
+	azblob "github.com/moby/buildkit/cache/remotecache/azblob"
+	"github.com/moby/buildkit/cache/remotecache/gha"
+	localremotecache "github.com/moby/buildkit/cache/remotecache/local"
+	s3remotecache "github.com/moby/buildkit/cache/remotecache/s3"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/cmd/buildctl/build"
+	bccommon "github.com/moby/buildkit/cmd/buildctl/common"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/units"
+	"github.com/urfave/cli/v3"
+)
+
+var cacheCommand = &cli.Command{
+	Name:  "cache",
+	Usage: "manage build cache",
+	Commands: []*cli.Command{
+		pruneRemoteCommand,
+	},
+}
+
+var pruneRemoteCommand = &cli.Command{
+	Name:      "prune-remote",
+	Usage:     "clean up unreferenced blobs and old manifests of a remote cache",
+	UsageText: "buildctl cache prune-remote --type=s3 --opt bucket=my_bucket --opt region=eu-west-1 --keep-duration=168h",
+	Action:    commandAction(pruneRemote),
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "Remote cache type (s3, azblob, local, gha)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "opt",
+			Usage: "Remote cache attribute, e.g. bucket=my_bucket. Accepts the same attributes as --export-cache",
+		},
+		&cli.DurationFlag{
+			Name:  "keep-duration",
+			Usage: "Keep manifests and blobs newer than this limit. Manifests are only deleted if set. Blobs are kept for at least 1h",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Report what would be deleted without deleting anything",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "Verbose output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template, e.g, '{{json .}}'",
+		},
+	},
+}
+
+func pruneRemote(clicontext *cli.Command) error {
+	entry, err := build.ParsePruneRemoteCache(clicontext.String("type"), clicontext.StringSlice("opt"))
+	if err != nil {
+		return err
+	}
+
+	ctx := bccommon.CommandContext(clicontext)
+	target, err := newPruneTarget(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	res, err := remotecache.PruneRemote(ctx, target, remotecache.PruneOpt{
+		KeepDuration: clicontext.Duration("keep-duration"),
+		DryRun:       clicontext.Bool("dry-run"),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := clicontext.Root().Writer
+	if format := clicontext.String("format"); format != "" {
+		tmpl, err := bccommon.ParseTemplate(format)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(w, res); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	if clicontext.Bool("verbose") {
+		fmt.Fprintln(tw, "KEY\tSIZE\tLAST MODIFIED")
+		for _, objs := range [][]remotecache.PruneObject{res.Manifests, res.Blobs} {
+			for _, o := range objs {
+				fmt.Fprintf(tw, "%s\t%.2f\t%s\n", o.Key, units.Bytes(o.Size), o.LastModified.Format(time.RFC3339))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "Manifests:\t%d deleted, %d kept\n", len(res.Manifests), res.KeptManifests)
+	fmt.Fprintf(tw, "Blobs:\t%d deleted, %d kept\n", len(res.Blobs), res.KeptBlobs)
+	if clicontext.Bool("dry-run") {
+		fmt.Fprintf(tw, "Reclaimable:\t%.2f\n", units.Bytes(res.Size()))
+	} else {
+		fmt.Fprintf(tw, "Reclaimed:\t%.2f\n", units.Bytes(res.Size()))
+	}
+	return tw.Flush()
+}
+
+func newPruneTarget(ctx context.Context, entry client.CacheOptionsEntry) (remotecache.PruneTarget, error) {
+	switch entry.Type {
+	case "s3":
+		return s3remotecache.NewPruneTarget(ctx, entry.Attrs)
+	case "azblob":
+		return azblob.NewPruneTarget(ctx, entry.Attrs)
+	case "local":
+		return localremotecache.NewPruneTarget(entry.Attrs)
+	case "gha":
+		return gha.NewPruneTarget(entry.Attrs)
+	default:
+		return nil, errors.Errorf("unsupported cache type %q for prune-remote", entry.Type)
+	}
+}
@@ -18,5 +18,9 @@ var debugCommand = &cli.Command{
 		debug.CtlCommand,
 		debug.GetCommand,
 		debug.HistoriesCommand,
+		debug.ExplainMissCommand,
+		debug.CacheCommand,
+		debug.ResourcesCommand,
+		debug.CheckpointsCommand,
 	},
 }
@@ -0,0 +1,224 @@
+package debug
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	bccommon "github.com/moby/buildkit/cmd/buildctl/common"
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/units"
+	"github.com/urfave/cli/v3"
+)
+
+var CacheCommand = &cli.Command{
+	Name:  "cache",
+	Usage: "inspect and invalidate keys of the local cache",
+	Commands: []*cli.Command{
+		cacheLsCommand,
+		cacheInspectCommand,
+		cacheInvalidateCommand,
+	},
+}
+
+var cacheLsCommand = &cli.Command{
+	Name:   "ls",
+	Usage:  "list cache keys",
+	Action: commandAction(cacheLs),
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "filter",
+			Usage: "Only list keys with the substring in their ID, operation summary or result IDs",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template, e.g, '{{json .}}'",
+		},
+	},
+}
+
+var cacheInspectCommand = &cli.Command{
+	Name:      "inspect",
+	Usage:     "show the results and links of a cache key",
+	ArgsUsage: "<key>",
+	Action:    commandAction(cacheInspect),
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template, e.g, '{{json .}}'",
+		},
+	},
+}
+
+var cacheInvalidateCommand = &cli.Command{
+	Name:      "invalidate",
+	Usage:     "release the results of a cache key so that the next build runs the operation again",
+	ArgsUsage: "<key>",
+	Action:    commandAction(cacheInvalidate),
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dependents",
+			Usage: "Also invalidate the keys depending on the key",
+		},
+	},
+}
+
+func cacheLs(clicontext *cli.Command) error {
+	c, err := bccommon.ResolveClient(clicontext)
+	if err != nil {
+		return err
+	}
+
+	ctx := appcontext.Context()
+	resp, err := c.ControlClient().ListCacheKeys(ctx, &controlapi.ListCacheKeysRequest{
+		Filter: clicontext.String("filter"),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := clicontext.Root().Writer
+	if format := clicontext.String("format"); format != "" {
+		tmpl, err := bccommon.ParseTemplate(format)
+		if err != nil {
+			return err
+		}
+		for _, k := range resp.Keys {
+			if err := tmpl.Execute(w, k); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "ID\tRESULTS\tSIZE\tLAST USED\tLINKS\tBACKLINKS\tSUMMARY")
+	for _, k := range resp.Keys {
+		var size int64
+		var lastUsed time.Time
+		for _, r := range k.Results {
+			size += r.Size
+			if r.LastUsedAt != nil && r.LastUsedAt.AsTime().After(lastUsed) {
+				lastUsed = r.LastUsedAt.AsTime()
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%s\t%d\t%d\t%s\n", k.ID, len(k.Results), units.Bytes(size), formatLastUsed(lastUsed), len(k.Links), len(k.Backlinks), truncate(k.Summary, 80))
+	}
+	return tw.Flush()
+}
+
+func cacheInspect(clicontext *cli.Command) error {
+	if clicontext.Args().Len() != 1 {
+		return errors.Errorf("cache key must be specified")
+	}
+
+	c, err := bccommon.ResolveClient(clicontext)
+	if err != nil {
+		return err
+	}
+
+	ctx := appcontext.Context()
+	resp, err := c.ControlClient().InspectCacheKey(ctx, &controlapi.InspectCacheKeyRequest{
+		ID: clicontext.Args().First(),
+	})
+	if err != nil {
+		return err
+	}
+	k := resp.Key
+
+	w := clicontext.Root().Writer
+	if format := clicontext.String("format"); format != "" {
+		tmpl, err := bccommon.ParseTemplate(format)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(w, k); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", k.ID)
+	if k.Summary != "" {
+		fmt.Fprintf(tw, "Summary:\t%s\n", k.Summary)
+	}
+	tw.Flush()
+
+	if len(k.Results) > 0 {
+		fmt.Fprintln(w, "\nResults:")
+		tw = tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+		fmt.Fprintln(tw, "ID\tCREATED\tSIZE\tLAST USED")
+		for _, r := range k.Results {
+			var lastUsed time.Time
+			if r.LastUsedAt != nil {
+				lastUsed = r.LastUsedAt.AsTime()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.2f\t%s\n", r.ID, r.CreatedAt.AsTime().Local().Format(time.RFC3339), units.Bytes(r.Size), formatLastUsed(lastUsed))
+		}
+		tw.Flush()
+	}
+	printCacheKeyLinks(w, "Links", k.Links)
+	printCacheKeyLinks(w, "Backlinks", k.Backlinks)
+	return nil
+}
+
+func printCacheKeyLinks(w io.Writer, title string, links []*controlapi.CacheKeyLink) {
+	if len(links) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "ID\tINPUT\tOUTPUT\tDIGEST\tSELECTOR")
+	for _, l := range links {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", l.ID, l.Input, l.Output, l.Digest, l.Selector)
+	}
+	tw.Flush()
+}
+
+func cacheInvalidate(clicontext *cli.Command) error {
+	if clicontext.Args().Len() != 1 {
+		return errors.Errorf("cache key must be specified")
+	}
+
+	c, err := bccommon.ResolveClient(clicontext)
+	if err != nil {
+		return err
+	}
+
+	ctx := appcontext.Context()
+	resp, err := c.ControlClient().InvalidateCacheKey(ctx, &controlapi.InvalidateCacheKeyRequest{
+		ID:         clicontext.Args().First(),
+		Dependents: clicontext.Bool("dependents"),
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range resp.Results {
+		fmt.Fprintln(clicontext.Root().Writer, id)
+	}
+	return nil
+}
+
+func formatLastUsed(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func truncate(s string, l int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= l {
+		return s
+	}
+	return s[:l-3] + "..."
+}
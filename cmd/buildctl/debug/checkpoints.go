@@ -0,0 +1,72 @@
+package debug
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	bccommon "github.com/moby/buildkit/cmd/buildctl/common"
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/tonistiigi/units"
+	"github.com/urfave/cli/v3"
+)
+
+var CheckpointsCommand = &cli.Command{
+	Name:   "checkpoints",
+	Usage:  "list the checkpoints of exec steps that will be resumed by the next build",
+	Action: commandAction(checkpoints),
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template, e.g, '{{json .}}'",
+		},
+	},
+}
+
+func checkpoints(clicontext *cli.Command) error {
+	c, err := bccommon.ResolveClient(clicontext)
+	if err != nil {
+		return err
+	}
+
+	ctx := appcontext.Context()
+	du, err := c.DiskUsage(ctx, client.WithFilter([]string{"type==" + string(client.UsageRecordTypeCheckpoint)}))
+	if err != nil {
+		return err
+	}
+
+	w := clicontext.Root().Writer
+	if format := clicontext.String("format"); format != "" {
+		tmpl, err := bccommon.ParseTemplate(format)
+		if err != nil {
+			return err
+		}
+		for _, di := range du {
+			if err := tmpl.Execute(w, di); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(du) == 0 {
+		_, err := fmt.Fprintln(w, "no checkpoints")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "ID\tSIZE\tCREATED\tDESCRIPTION")
+	for _, di := range du {
+		fmt.Fprintf(tw, "%s\t%.2f\t%s\t%s\n",
+			di.ID,
+			units.Bytes(di.Size),
+			di.CreatedAt.Format(time.RFC3339),
+			truncate(di.Description, 60),
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package debug
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	bccommon "github.com/moby/buildkit/cmd/buildctl/common"
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+)
+
+var ExplainMissCommand = &cli.Command{
+	Name:      "explain-miss",
+	Usage:     "explain why a vertex of a build did not match the cache",
+	ArgsUsage: "<ref> <vertex-digest>",
+	Action:    commandAction(explainMiss),
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template, e.g, '{{json .}}'",
+		},
+	},
+}
+
+func explainMiss(clicontext *cli.Command) error {
+	args := clicontext.Args()
+	if args.Len() != 2 {
+		return errors.Errorf("build ref and vertex digest must be specified")
+	}
+
+	c, err := bccommon.ResolveClient(clicontext)
+	if err != nil {
+		return err
+	}
+
+	ctx := appcontext.Context()
+	resp, err := c.ControlClient().ExplainCacheMiss(ctx, &controlapi.ExplainCacheMissRequest{
+		Ref:    args.Get(0),
+		Vertex: args.Get(1),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := clicontext.Root().Writer
+	if format := clicontext.String("format"); format != "" {
+		tmpl, err := bccommon.ParseTemplate(format)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(w, resp); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "Vertex:\t%s\t%s\n", resp.Vertex, resp.Name)
+	if resp.Cached {
+		fmt.Fprintln(tw, "Result:\tvertex was not executed, it was cached or not needed by the build")
+		return tw.Flush()
+	}
+	if resp.PreviousRef != "" {
+		fmt.Fprintf(tw, "Compared with:\t%s\tbuild %s\n", resp.PreviousVertex, resp.PreviousRef)
+	}
+	fmt.Fprintln(tw)
+	tw.Flush()
+
+	tw = tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "REASON\tVERTEX\tFIELD\tOLD\tNEW")
+	for _, r := range resp.Reasons {
+		switch r.Type {
+		case "new":
+			fmt.Fprintf(tw, "%s\t%s\t%s\t\t\n", r.Type, r.Name, "no similar vertex in previous builds")
+		case "unchanged":
+			fmt.Fprintf(tw, "%s\t%s\t%s\t\t\n", r.Type, r.Name, "no changed cache key inputs, the cache record was probably pruned")
+		default:
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Type, r.Name, r.Field, r.Old, r.New)
+		}
+	}
+	return tw.Flush()
+}
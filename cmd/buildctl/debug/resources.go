@@ -0,0 +1,139 @@
+package debug
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	bccommon "github.com/moby/buildkit/cmd/buildctl/common"
+	"github.com/moby/buildkit/solver/llbsolver/resourceusage"
+	"github.com/moby/buildkit/util/appcontext"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/units"
+	"github.com/urfave/cli/v3"
+)
+
+var ResourcesCommand = &cli.Command{
+	Name:      "resources",
+	Usage:     "show the resources used by the executed steps of a build",
+	ArgsUsage: "<ref>",
+	Action:    commandAction(resources),
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "Sort the steps in descending order by " + strings.Join(resourceusage.SortKeys, ", "),
+			Value: "memory",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output using the given Go template, e.g, '{{json .}}'",
+		},
+	},
+}
+
+func resources(clicontext *cli.Command) error {
+	if clicontext.Args().Len() != 1 {
+		return errors.Errorf("build ref must be specified")
+	}
+	sortKey := clicontext.String("sort")
+	if !slices.Contains(resourceusage.SortKeys, sortKey) {
+		return errors.Errorf("invalid sort key %q, expected one of %s", sortKey, strings.Join(resourceusage.SortKeys, ", "))
+	}
+
+	c, err := bccommon.ResolveClient(clicontext)
+	if err != nil {
+		return err
+	}
+
+	ctx := appcontext.Context()
+	resp, err := c.ControlClient().ResourceUsage(ctx, &controlapi.ResourceUsageRequest{
+		Ref: clicontext.Args().First(),
+	})
+	if err != nil {
+		return err
+	}
+
+	vertexes := make([]resourceusage.Vertex, 0, len(resp.Vertexes))
+	for _, v := range resp.Vertexes {
+		vertexes = append(vertexes, resourceusage.Vertex{
+			Digest:       digest.Digest(v.Vertex),
+			Name:         v.Name,
+			Started:      v.Started.AsTime(),
+			Duration:     time.Duration(v.Duration),
+			CPU:          time.Duration(v.CPU),
+			PeakMemory:   v.PeakMemory,
+			OOMKills:     v.OOMKills,
+			PeakPIDs:     v.PeakPIDs,
+			IOReadBytes:  v.IOReadBytes,
+			IOWriteBytes: v.IOWriteBytes,
+			NetRxBytes:   v.NetRxBytes,
+			NetTxBytes:   v.NetTxBytes,
+			CPUStall:     time.Duration(v.CPUStall),
+			MemoryStall:  time.Duration(v.MemoryStall),
+			IOStall:      time.Duration(v.IOStall),
+		})
+	}
+	resourceusage.Sort(vertexes, sortKey)
+
+	w := clicontext.Root().Writer
+	if format := clicontext.String("format"); format != "" {
+		tmpl, err := bccommon.ParseTemplate(format)
+		if err != nil {
+			return err
+		}
+		for _, v := range vertexes {
+			if err := tmpl.Execute(w, v); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(vertexes) == 0 {
+		_, err := fmt.Fprintln(w, "no resource usage was recorded for the build")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "VERTEX\tDURATION\tCPU\tPEAK MEMORY\tOOM KILLS\tPIDS\tIO READ\tIO WRITE\tNET RX\tNET TX\tSTALL CPU/MEM/IO\tNAME")
+	for _, v := range vertexes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%s/%s/%s\t%s\n",
+			shortDigest(v.Digest),
+			formatDuration(v.Duration),
+			formatDuration(v.CPU),
+			units.Bytes(v.PeakMemory),
+			v.OOMKills,
+			v.PeakPIDs,
+			units.Bytes(v.IOReadBytes),
+			units.Bytes(v.IOWriteBytes),
+			units.Bytes(v.NetRxBytes),
+			units.Bytes(v.NetTxBytes),
+			formatDuration(v.CPUStall),
+			formatDuration(v.MemoryStall),
+			formatDuration(v.IOStall),
+			truncate(v.Name, 60),
+		)
+	}
+	return tw.Flush()
+}
+
+func shortDigest(dgst digest.Digest) string {
+	if err := dgst.Validate(); err != nil {
+		return dgst.String()
+	}
+	return dgst.Encoded()[:12]
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
@@ -108,6 +108,7 @@ func main() {
 		diskUsageCommand,
 		pruneCommand,
 		pruneHistoriesCommand,
+		cacheCommand,
 		buildCommand,
 		debugCommand,
 		dialStdioCommand,
@@ -16,12 +16,16 @@ type Config struct {
 	// Root is the path to a directory where buildkit will store persistent data
 	Root string `toml:"root"`
 
-	// Entitlements e.g. security.insecure, network.host, device
+	// Entitlements e.g. security.insecure, security.profile, network.host, device
 	Entitlements []string `toml:"insecure-entitlements"`
 
 	// ProxyNetwork enables proxy network enforcement for all builds.
 	ProxyNetwork bool `toml:"proxyNetwork"`
 
+	// ProxyEgress restricts the requests that build steps can make through
+	// the proxy network. If set, a request must match at least one rule.
+	ProxyEgress []EgressRuleConfig `toml:"proxyEgress"`
+
 	// LogFormat is the format of the logs. It can be "json" or "text".
 	Log LogConfig `toml:"log"`
 
@@ -32,6 +36,12 @@ type Config struct {
 
 	CDI CDIConfig `toml:"cdi"`
 
+	Scheduling SchedulingConfig `toml:"scheduling"`
+
+	// SecurityProfiles are the seccomp and AppArmor profiles that build
+	// steps can select by name.
+	SecurityProfiles map[string]SecurityProfileConfig `toml:"securityProfile"`
+
 	Workers struct {
 		OCI        OCIConfig        `toml:"oci"`
 		Containerd ContainerdConfig `toml:"containerd"`
@@ -59,6 +69,17 @@ type Config struct {
 
 type CacheConfig struct {
 	GHA *ghatypes.CacheConfig `toml:"gha"`
+
+	KeyStorage KeyStorageConfig `toml:"keyStorage"`
+}
+
+type KeyStorageConfig struct {
+	// Backend is the storage engine of the local cache key index: "bolt"
+	// (default), "kvbolt" or "lsm".
+	Backend string `toml:"backend"`
+	// Seed is the path of an index snapshot that is imported when the index
+	// is empty. Only supported by the "kvbolt" and "lsm" backends.
+	Seed string `toml:"seed"`
 }
 
 type SystemConfig struct {
@@ -104,6 +125,33 @@ type CDIConfig struct {
 	AutoAllowed []string `toml:"autoAllowed"`
 }
 
+type SchedulingConfig struct {
+	// TrustClients accepts the tenant and the "high" priority class that
+	// clients request for their builds. Otherwise the tenant of a build is
+	// the common name of the TLS client certificate.
+	TrustClients bool `toml:"trustClients"`
+}
+
+type SecurityProfileConfig struct {
+	// Seccomp is the path to a seccomp profile in JSON format.
+	Seccomp string `toml:"seccomp"`
+	// AppArmor is the name of an AppArmor profile loaded on the host.
+	AppArmor string `toml:"apparmor"`
+	// Restrictive marks profiles that don't loosen the default profile.
+	// They can be selected without the security.profile entitlement.
+	Restrictive bool `toml:"restrictive"`
+}
+
+// EgressRuleConfig matches requests made through the proxy network. Empty
+// fields match any value.
+type EgressRuleConfig struct {
+	// Hosts are host names that may contain wildcards, e.g. "*.example.com".
+	Hosts        []string `toml:"hosts"`
+	Ports        []int    `toml:"ports"`
+	PathPrefixes []string `toml:"pathPrefixes"`
+	Methods      []string `toml:"methods"`
+}
+
 type GCConfig struct {
 	GC *bool `toml:"gc"`
 	// Deprecated: use GCReservedSpace instead
@@ -121,6 +169,14 @@ type NetworkConfig struct {
 	CNIPoolSize   int    `toml:"cniPoolSize"`
 	BridgeName    string `toml:"bridgeName"`
 	BridgeSubnet  string `toml:"bridgeSubnet"`
+	// CNINetworks are additional CNI networks that build steps can select by
+	// name.
+	CNINetworks map[string]CNINetworkConfig `toml:"cniNetworks"`
+}
+
+type CNINetworkConfig struct {
+	ConfigPath string `toml:"cniConfigPath"`
+	PoolSize   int    `toml:"cniPoolSize"`
 }
 
 type OCIConfig struct {
@@ -132,13 +188,23 @@ type OCIConfig struct {
 	NoProcessSandbox bool              `toml:"noProcessSandbox"`
 	GCConfig
 	NetworkConfig
-	// UserRemapUnsupported is unsupported key for testing. The feature is
-	// incomplete and the intention is to make it default without config.
+	// UserRemap is the name of the user whose subordinate UID and GID ranges
+	// are used for the user namespaces of build steps. Each build step runs
+	// with a distinct range of 65536 IDs.
+	UserRemap string `toml:"userRemap"`
+	// Deprecated: use UserRemap instead
 	UserRemapUnsupported string `toml:"userRemapUnsupported"`
 	// For use in storing the OCI worker binary name that will replace buildkit-runc
 	Binary               string `toml:"binary"`
 	ProxySnapshotterPath string `toml:"proxySnapshotterPath"`
 	DefaultCgroupParent  string `toml:"defaultCgroupParent"`
+	// Runtimes are additional OCI runtimes that build steps can select by
+	// name instead of Binary.
+	Runtimes map[string]OCIRuntimeConfig `toml:"runtimes"`
+	// DefaultRuntime is the name of the runtime of Runtimes that runs the
+	// build steps that don't select a runtime. Selecting another runtime
+	// requires the security.runtime entitlement.
+	DefaultRuntime string `toml:"defaultRuntime"`
 
 	// StargzSnapshotterConfig is configuration for stargz snapshotter.
 	// We use a generic map[string]interface{} in order to remove the dependency
@@ -154,6 +220,13 @@ type OCIConfig struct {
 
 	// MaxParallelism is the maximum number of parallel build steps that can be run at the same time.
 	MaxParallelism int `toml:"max-parallelism"`
+
+	// Checkpoint enables checkpointing exec steps with CRIU. Experimental.
+	Checkpoint bool `toml:"checkpoint"`
+}
+
+type OCIRuntimeConfig struct {
+	Binary string `toml:"binary"`
 }
 
 type ContainerdConfig struct {
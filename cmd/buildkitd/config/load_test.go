@@ -35,9 +35,17 @@ snapshotter="overlay"
 rootless=true
 gc=false
 gckeepstorage=123456789
+userRemap="buildkit"
+defaultRuntime="runsc"
 [worker.oci.labels]
 foo="bar"
 "aa.bb.cc"="baz"
+[worker.oci.cniNetworks.isolated]
+cniConfigPath="/etc/buildkit/cni-isolated.json"
+cniPoolSize=4
+[worker.oci.runtimes.runsc]
+binary="/usr/local/bin/runsc"
+[worker.oci.runtimes.crun]
 
 [worker.containerd]
 namespace="non-default"
@@ -77,6 +85,28 @@ cert="cert.pem"
 nameservers=["1.1.1.1","8.8.8.8"]
 options=["edns0"]
 searchDomains=["example.com"]
+
+[cache.keyStorage]
+backend="lsm"
+seed="/var/lib/buildkit/seed"
+
+[[proxyEgress]]
+hosts=["npm.internal.example.com"]
+ports=[443]
+methods=["GET", "HEAD"]
+[[proxyEgress]]
+hosts=["*.example.org"]
+pathPrefixes=["/mirror/"]
+
+[scheduling]
+trustClients=true
+
+[securityProfile."perf"]
+seccomp="/etc/buildkit/seccomp-perf.json"
+apparmor="buildkit-perf"
+[securityProfile."confined"]
+apparmor="buildkit-confined"
+restrictive=true
 `
 
 	cfg, err := Load(bytes.NewBuffer([]byte(testConfig)))
@@ -103,9 +133,18 @@ searchDomains=["example.com"]
 	require.Equal(t, "overlay", cfg.Workers.OCI.Snapshotter)
 	require.Equal(t, true, cfg.Workers.OCI.Rootless)
 	require.Equal(t, false, *cfg.Workers.OCI.GC)
+	require.Equal(t, "buildkit", cfg.Workers.OCI.UserRemap)
 
 	require.Equal(t, "bar", cfg.Workers.OCI.Labels["foo"])
 	require.Equal(t, "baz", cfg.Workers.OCI.Labels["aa.bb.cc"])
+	require.Equal(t, map[string]CNINetworkConfig{
+		"isolated": {ConfigPath: "/etc/buildkit/cni-isolated.json", PoolSize: 4},
+	}, cfg.Workers.OCI.CNINetworks)
+	require.Equal(t, map[string]OCIRuntimeConfig{
+		"runsc": {Binary: "/usr/local/bin/runsc"},
+		"crun":  {},
+	}, cfg.Workers.OCI.Runtimes)
+	require.Equal(t, "runsc", cfg.Workers.OCI.DefaultRuntime)
 
 	require.Nil(t, cfg.Workers.Containerd.Enabled)
 	require.Equal(t, 1, len(cfg.Workers.Containerd.Platforms))
@@ -150,4 +189,31 @@ searchDomains=["example.com"]
 	require.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, cfg.DNS.Nameservers)
 	require.Equal(t, []string{"example.com"}, cfg.DNS.SearchDomains)
 	require.Equal(t, []string{"edns0"}, cfg.DNS.Options)
+
+	require.Equal(t, "lsm", cfg.Cache.KeyStorage.Backend)
+	require.Equal(t, "/var/lib/buildkit/seed", cfg.Cache.KeyStorage.Seed)
+
+	require.Len(t, cfg.ProxyEgress, 2)
+	require.Equal(t, []string{"npm.internal.example.com"}, cfg.ProxyEgress[0].Hosts)
+	require.Equal(t, []int{443}, cfg.ProxyEgress[0].Ports)
+	require.Equal(t, []string{"GET", "HEAD"}, cfg.ProxyEgress[0].Methods)
+	require.Equal(t, []string{"/mirror/"}, cfg.ProxyEgress[1].PathPrefixes)
+
+	require.True(t, cfg.Scheduling.TrustClients)
+
+	require.Len(t, cfg.SecurityProfiles, 2)
+	require.Equal(t, "/etc/buildkit/seccomp-perf.json", cfg.SecurityProfiles["perf"].Seccomp)
+	require.Equal(t, "buildkit-perf", cfg.SecurityProfiles["perf"].AppArmor)
+	require.False(t, cfg.SecurityProfiles["perf"].Restrictive)
+	require.True(t, cfg.SecurityProfiles["confined"].Restrictive)
+}
+
+func TestLoadDeprecatedUserRemap(t *testing.T) {
+	cfg, err := Load(bytes.NewBuffer([]byte(`
+[worker.oci]
+userRemapUnsupported="buildkit"
+`)))
+	require.NoError(t, err)
+	require.Empty(t, cfg.Workers.OCI.UserRemap)
+	require.Equal(t, "buildkit", cfg.Workers.OCI.UserRemapUnsupported) //nolint:staticcheck
 }
@@ -44,6 +44,10 @@ func setupDebugHandlers(addr string) error {
 	m.Handle("/debug/cache/lookup", http.HandlerFunc(handleCacheLookup))
 	m.Handle("/debug/cache/store", http.HandlerFunc(handleDebugCacheStore))
 	m.Handle("POST /debug/cache/load", http.HandlerFunc(handleCacheLoad))
+	m.Handle("GET /debug/cache/index/size", http.HandlerFunc(handleCacheIndexSize))
+	m.Handle("POST /debug/cache/index/compact", http.HandlerFunc(handleCacheIndexCompact))
+	m.Handle("GET /debug/cache/index/export", http.HandlerFunc(handleCacheIndexExport))
+	m.Handle("POST /debug/cache/index/import", http.HandlerFunc(handleCacheIndexImport))
 
 	m.Handle("/debug/gc", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		runtime.GC()
@@ -268,6 +272,79 @@ func loadCacheFromReader(ctx context.Context, rdr io.Reader) ([]*recordWithDebug
 	return recs, nil
 }
 
+// cacheIndex is implemented by cache key storages that support maintenance
+// of their index.
+type cacheIndex interface {
+	Size() (int64, error)
+	Compact(context.Context) error
+	Export(context.Context, io.Writer) error
+	Import(context.Context, io.Reader) error
+}
+
+func cacheIndexForDebug(w http.ResponseWriter) (cacheIndex, bool) {
+	idx, ok := cacheStoreForDebug.(cacheIndex)
+	if !ok {
+		http.Error(w, "cache key storage does not support index operations", http.StatusNotImplemented)
+		return nil, false
+	}
+	return idx, true
+}
+
+func handleCacheIndexSize(w http.ResponseWriter, r *http.Request) {
+	idx, ok := cacheIndexForDebug(w)
+	if !ok {
+		return
+	}
+	size, err := idx.Size()
+	if err != nil {
+		http.Error(w, "failed to get cache index size: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"size": size})
+}
+
+func handleCacheIndexCompact(w http.ResponseWriter, r *http.Request) {
+	idx, ok := cacheIndexForDebug(w)
+	if !ok {
+		return
+	}
+	before, _ := idx.Size()
+	if err := idx.Compact(r.Context()); err != nil {
+		http.Error(w, "failed to compact cache index: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	after, _ := idx.Size()
+	bklog.G(r.Context()).Debugf("compacted cache index from %d to %d bytes", before, after)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"before": before, "after": after})
+}
+
+func handleCacheIndexExport(w http.ResponseWriter, r *http.Request) {
+	idx, ok := cacheIndexForDebug(w)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := idx.Export(r.Context(), w); err != nil {
+		// the response may already be partially written, so the error can
+		// only be logged
+		bklog.G(r.Context()).Errorf("failed to export cache index: %v", err)
+	}
+}
+
+func handleCacheIndexImport(w http.ResponseWriter, r *http.Request) {
+	idx, ok := cacheIndexForDebug(w)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+	if err := idx.Import(r.Context(), r.Body); err != nil {
+		http.Error(w, "failed to import cache index: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func handleDebugCacheStore(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
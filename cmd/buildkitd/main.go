@@ -25,7 +25,9 @@ import (
 	"github.com/gofrs/flock"
 	"github.com/moby/buildkit/cache/remotecache"
 	"github.com/moby/buildkit/cache/remotecache/azblob"
+	gcsremotecache "github.com/moby/buildkit/cache/remotecache/gcs"
 	"github.com/moby/buildkit/cache/remotecache/gha"
+	httpremotecache "github.com/moby/buildkit/cache/remotecache/http"
 	inlineremotecache "github.com/moby/buildkit/cache/remotecache/inline"
 	localremotecache "github.com/moby/buildkit/cache/remotecache/local"
 	registryremotecache "github.com/moby/buildkit/cache/remotecache/registry"
@@ -42,7 +44,9 @@ import (
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/solver"
 	"github.com/moby/buildkit/solver/bboltcachestorage"
+	"github.com/moby/buildkit/solver/kvcachestorage"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/util/apicaps"
 	"github.com/moby/buildkit/util/appcontext"
 	"github.com/moby/buildkit/util/appdefaults"
@@ -53,6 +57,11 @@ import (
 	"github.com/moby/buildkit/util/disk"
 	"github.com/moby/buildkit/util/grpcerrors"
 	_ "github.com/moby/buildkit/util/grpcutil/encoding/proto"
+	"github.com/moby/buildkit/util/kv"
+	"github.com/moby/buildkit/util/kv/boltkv"
+	"github.com/moby/buildkit/util/kv/lsm"
+	"github.com/moby/buildkit/util/network"
+	"github.com/moby/buildkit/util/network/cniprovider"
 	"github.com/moby/buildkit/util/profiler"
 	"github.com/moby/buildkit/util/resolver"
 	"github.com/moby/buildkit/util/resolver/limited"
@@ -231,7 +240,7 @@ func main() {
 		},
 		&cli.StringSliceFlag{
 			Name:  "allow-insecure-entitlement",
-			Usage: "allows insecure entitlements e.g. network.host, security.insecure, device",
+			Usage: "allows insecure entitlements e.g. network.host, network.custom, security.insecure, security.profile, security.runtime, device",
 		},
 		&cli.BoolFlag{
 			Name:  "proxy-network",
@@ -424,18 +433,9 @@ func main() {
 
 		ents := c.StringSlice("allow-insecure-entitlement")
 		if len(ents) > 0 {
-			cfg.Entitlements = []string{}
-			for _, e := range ents {
-				switch e {
-				case "security.insecure":
-					cfg.Entitlements = append(cfg.Entitlements, e)
-				case "network.host":
-					cfg.Entitlements = append(cfg.Entitlements, e)
-				case "device":
-					cfg.Entitlements = append(cfg.Entitlements, e)
-				default:
-					return errors.Errorf("invalid entitlement : %s", e)
-				}
+			cfg.Entitlements, err = parseInsecureEntitlements(ents)
+			if err != nil {
+				return err
 			}
 		}
 
@@ -642,6 +642,29 @@ func isRootlessConfig() bool {
 	return u != "" && u != "root"
 }
 
+func parseInsecureEntitlements(ents []string) ([]string, error) {
+	out := []string{}
+	for _, e := range ents {
+		switch e {
+		case "security.insecure":
+			out = append(out, e)
+		case "network.host":
+			out = append(out, e)
+		case "network.custom":
+			out = append(out, e)
+		case "device":
+			out = append(out, e)
+		case "security.profile":
+			out = append(out, e)
+		case "security.runtime":
+			out = append(out, e)
+		default:
+			return nil, errors.Errorf("invalid entitlement : %s", e)
+		}
+	}
+	return out, nil
+}
+
 func applyMainFlags(c *cli.Command, cfg *config.Config, warnings *[]string) error {
 	if c.IsSet("debug") && c.Bool("debug") {
 		cfg.Log.Level = "debug"
@@ -894,7 +917,7 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		frontends["gateway.v0"] = gwfe
 	}
 
-	cacheStorage, err := bboltcachestorage.NewStore(filepath.Join(cfg.Root, "cache.db"))
+	cacheStorage, err := newCacheKeyStorage(ctx, cfg.Cache.KeyStorage, cfg.Root)
 	if err != nil {
 		return nil, err
 	}
@@ -926,6 +949,8 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		"gha":      gha.ResolveCacheExporterFunc(cfg.Cache.GHA, verifierProvider),
 		"s3":       s3remotecache.ResolveCacheExporterFunc(),
 		"azblob":   azblob.ResolveCacheExporterFunc(),
+		"http":     httpremotecache.ResolveCacheExporterFunc(sessionManager),
+		"gcs":      gcsremotecache.ResolveCacheExporterFunc(sessionManager),
 	}
 	remoteCacheImporterFuncs := map[string]remotecache.ResolveCacheImporterFunc{
 		"registry": registryremotecache.ResolveCacheImporterFunc(sessionManager, w.ContentStore(), resolverFn),
@@ -933,6 +958,8 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		"gha":      gha.ResolveCacheImporterFunc(cfg.Cache.GHA, verifierProvider),
 		"s3":       s3remotecache.ResolveCacheImporterFunc(),
 		"azblob":   azblob.ResolveCacheImporterFunc(),
+		"http":     httpremotecache.ResolveCacheImporterFunc(sessionManager),
+		"gcs":      gcsremotecache.ResolveCacheImporterFunc(sessionManager),
 	}
 
 	if cfg.CDI.Disabled == nil || !*cfg.CDI.Disabled {
@@ -944,6 +971,11 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		return nil, err
 	}
 
+	proxyEgress, err := getProxyEgress(cfg.ProxyEgress)
+	if err != nil {
+		return nil, err
+	}
+
 	return control.NewController(control.Opt{
 		SessionManager:            sessionManager,
 		WorkerController:          wc,
@@ -960,12 +992,67 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		ContentStore:              w.ContentStore(),
 		HistoryConfig:             cfg.History,
 		ProxyNetwork:              cfg.ProxyNetwork,
+		ProxyEgress:               proxyEgress,
 		GarbageCollect:            w.GarbageCollect,
 		GracefulStop:              ctx.Done(),
 		ProvenanceEnv:             provenanceEnv,
+		TrustClientScheduling:     cfg.Scheduling.TrustClients,
 	})
 }
 
+func newCacheKeyStorage(ctx context.Context, cfg config.KeyStorageConfig, root string) (control.CacheKeyStore, error) {
+	var kvs kv.Store
+	switch cfg.Backend {
+	case "", "bolt":
+		if cfg.Seed != "" {
+			return nil, errors.Errorf("cache key storage seed is not supported by the bolt backend")
+		}
+		return bboltcachestorage.NewStore(filepath.Join(root, "cache.db"))
+	case "kvbolt":
+		s, err := boltkv.Open(filepath.Join(root, "cache-kv.db"))
+		if err != nil {
+			return nil, err
+		}
+		kvs = s
+	case "lsm":
+		s, err := lsm.Open(filepath.Join(root, "cache-lsm"), lsm.Opt{})
+		if err != nil {
+			return nil, err
+		}
+		kvs = s
+	default:
+		return nil, errors.Errorf("invalid cache key storage backend %q", cfg.Backend)
+	}
+	st := kvcachestorage.NewStore(kvs)
+	if cfg.Seed == "" {
+		return st, nil
+	}
+	empty := true
+	errNotEmpty := errors.New("not empty")
+	if err := st.Walk(func(string) error {
+		empty = false
+		return errNotEmpty
+	}); err != nil && !errors.Is(err, errNotEmpty) {
+		st.Close()
+		return nil, err
+	}
+	if !empty {
+		return st, nil
+	}
+	f, err := os.Open(cfg.Seed)
+	if err != nil {
+		st.Close()
+		return nil, errors.Wrap(err, "failed to open cache key storage seed")
+	}
+	defer f.Close()
+	if err := st.Import(ctx, f); err != nil {
+		st.Close()
+		return nil, errors.Wrapf(err, "failed to import cache key storage seed %s", cfg.Seed)
+	}
+	bklog.G(ctx).Infof("seeded cache key storage from %s", cfg.Seed)
+	return st, nil
+}
+
 func resolverFunc(cfg *config.Config) docker.RegistryHosts {
 	return resolver.NewRegistryConfig(cfg.Registries)
 }
@@ -1208,6 +1295,59 @@ func getCDIManager(cfg config.CDIConfig) (*cdidevices.Manager, error) {
 	return cdidevices.NewManager(cdiCache, cfg.AutoAllowed), nil
 }
 
+func getProxyEgress(cfg []config.EgressRuleConfig) (*network.EgressPolicy, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	p := &network.EgressPolicy{}
+	for _, r := range cfg {
+		p.Rules = append(p.Rules, network.EgressRule{
+			Hosts:        r.Hosts,
+			Ports:        r.Ports,
+			PathPrefixes: r.PathPrefixes,
+			Methods:      r.Methods,
+		})
+	}
+	if err := p.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid proxyEgress config")
+	}
+	return p, nil
+}
+
+func getSecurityProfiles(cfg map[string]config.SecurityProfileConfig) (*securityprofiles.Manager, error) {
+	profiles := make([]securityprofiles.Profile, 0, len(cfg))
+	for name, pcfg := range cfg {
+		p := securityprofiles.Profile{
+			Name:        name,
+			AppArmor:    pcfg.AppArmor,
+			Restrictive: pcfg.Restrictive,
+		}
+		if pcfg.Seccomp != "" {
+			dt, err := os.ReadFile(pcfg.Seccomp)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read seccomp profile for security profile %q", name)
+			}
+			p.Seccomp = string(dt)
+		}
+		profiles = append(profiles, p)
+	}
+	return securityprofiles.NewManager(profiles)
+}
+
+func cniNetworks(cfg map[string]config.CNINetworkConfig) map[string]cniprovider.Opt {
+	if len(cfg) == 0 {
+		return nil
+	}
+	networks := make(map[string]cniprovider.Opt, len(cfg))
+	for name, ncfg := range cfg {
+		networks[name] = cniprovider.Opt{
+			ConfigPath: ncfg.ConfigPath,
+			PoolSize:   ncfg.PoolSize,
+		}
+	}
+	return networks
+}
+
 func newVerifierProvider(root string) func() (*policy.Verifier, error) {
 	var mu sync.Mutex
 	var verifier *policy.Verifier
@@ -14,6 +14,7 @@ import (
 	"github.com/moby/buildkit/cmd/buildkitd/config"
 	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/util/disk"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/network/cniprovider"
 	"github.com/moby/buildkit/util/network/netproviders"
 	"github.com/moby/buildkit/worker"
@@ -23,7 +24,6 @@ import (
 	"github.com/pelletier/go-toml/v2"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v3"
-	"golang.org/x/sync/semaphore"
 )
 
 const (
@@ -297,6 +297,11 @@ func containerdWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([
 		return nil, err
 	}
 
+	secProfiles, err := getSecurityProfiles(common.config.SecurityProfiles)
+	if err != nil {
+		return nil, err
+	}
+
 	nc := netproviders.Opt{
 		Mode: common.config.Workers.Containerd.Mode,
 		CNI: cniprovider.Opt{
@@ -307,11 +312,12 @@ func containerdWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([
 			BridgeName:   common.config.Workers.Containerd.BridgeName,
 			BridgeSubnet: common.config.Workers.Containerd.BridgeSubnet,
 		},
+		Networks: cniNetworks(common.config.Workers.Containerd.CNINetworks),
 	}
 
-	var parallelismSem *semaphore.Weighted
+	var scheduler *fairshare.Scheduler
 	if cfg.MaxParallelism > 0 {
-		parallelismSem = semaphore.NewWeighted(int64(cfg.MaxParallelism))
+		scheduler = fairshare.New(cfg.MaxParallelism)
 	}
 
 	snapshotter := defaults.DefaultSnapshotter
@@ -351,7 +357,7 @@ func containerdWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([
 		NetworkOpt:      nc,
 		ApparmorProfile: common.config.Workers.Containerd.ApparmorProfile,
 		Selinux:         common.config.Workers.Containerd.SELinux,
-		ParallelismSem:  parallelismSem,
+		Scheduler:       scheduler,
 		TraceSocket:     common.traceSocket,
 		Runtime:         runtime,
 		CDIManager:      cdiManager,
@@ -364,6 +370,7 @@ func containerdWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([
 	opt.GCPolicy = getGCPolicy(cfg.GCConfig, common.config.Root)
 	opt.BuildkitVersion = getBuildkitVersion()
 	opt.RegistryHosts = resolverFunc(common.config)
+	opt.SecurityProfiles = secProfiles
 
 	if platformsStr := cfg.Platforms; len(platformsStr) != 0 {
 		platforms, err := parsePlatforms(platformsStr)
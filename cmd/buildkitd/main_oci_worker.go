@@ -34,6 +34,7 @@ import (
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/util/disk"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/network/cniprovider"
 	"github.com/moby/buildkit/util/network/netproviders"
 	"github.com/moby/buildkit/util/resolver"
@@ -45,7 +46,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
-	"golang.org/x/sync/semaphore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials/insecure"
@@ -277,10 +277,34 @@ func ociWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([]worker
 		return nil, nil
 	}
 
-	// TODO: this should never change the existing state dir
-	idmapping, err := parseIdentityMapping(cfg.UserRemapUnsupported)
-	if err != nil {
-		return nil, err
+	//nolint:staticcheck // used for backward compatibility
+	if cfg.UserRemap == "" && cfg.UserRemapUnsupported != "" {
+		bklog.L.Warn("userRemapUnsupported is deprecated, use userRemap instead")
+		cfg.UserRemap = cfg.UserRemapUnsupported //nolint:staticcheck
+	}
+
+	var userRemap *oci.IDMapPool
+	if cfg.UserRemap != "" {
+		if cfg.Rootless {
+			return nil, errors.New("can't enable userRemap with rootless")
+		}
+		idmapping, err := parseIdentityMapping(cfg.UserRemap)
+		if err != nil {
+			return nil, err
+		}
+		userRemap, err = oci.NewIDMapPool(*idmapping, oci.IDMapRangeSize)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid subordinate ID ranges of %s", cfg.UserRemap)
+		}
+		// build steps fail instead of waiting if no range is free, so there
+		// needs to be a range for every build step that can run at a time
+		switch {
+		case cfg.MaxParallelism > 0 && userRemap.Len() < cfg.MaxParallelism:
+			return nil, errors.Errorf("subordinate ID ranges of %s only have room for %d build steps, max-parallelism is %d: add %d IDs to /etc/subuid and /etc/subgid for each build step", cfg.UserRemap, userRemap.Len(), cfg.MaxParallelism, oci.IDMapRangeSize)
+		case cfg.MaxParallelism <= 0:
+			bklog.L.Warnf("user namespaces: subordinate ID ranges of %s only have room for %d build steps and max-parallelism is not set, build steps that run when all ranges are in use fail", cfg.UserRemap, userRemap.Len())
+		}
+		bklog.L.Debugf("user namespaces: running build steps with %d ID ranges of %s", userRemap.Len(), cfg.UserRemap)
 	}
 
 	hosts := resolverFunc(common.config)
@@ -312,6 +336,11 @@ func ociWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([]worker
 		return nil, err
 	}
 
+	secProfiles, err := getSecurityProfiles(common.config.SecurityProfiles)
+	if err != nil {
+		return nil, err
+	}
+
 	nc := netproviders.Opt{
 		Mode: common.config.Workers.OCI.Mode,
 		CNI: cniprovider.Opt{
@@ -322,20 +351,43 @@ func ociWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([]worker
 			BridgeName:   common.config.Workers.OCI.BridgeName,
 			BridgeSubnet: common.config.Workers.OCI.BridgeSubnet,
 		},
+		Networks: cniNetworks(common.config.Workers.OCI.CNINetworks),
 	}
 
-	var parallelismSem *semaphore.Weighted
+	var scheduler *fairshare.Scheduler
 	if cfg.MaxParallelism > 0 {
-		parallelismSem = semaphore.NewWeighted(int64(cfg.MaxParallelism))
-	}
-
-	opt, err := runc.NewWorkerOpt(common.config.Root, snFactory, cfg.Rootless, processMode, cfg.Labels, idmapping, nc, dns, cfg.Binary, cfg.ApparmorProfile, cfg.SELinux, parallelismSem, common.traceSocket, cfg.DefaultCgroupParent, cdiManager)
+		scheduler = fairshare.New(cfg.MaxParallelism)
+	}
+
+	workerOpts := runc.WorkerOptions{
+		Root:               common.config.Root,
+		SnapshotterFactory: snFactory,
+		Rootless:           cfg.Rootless,
+		ProcessMode:        processMode,
+		Labels:             cfg.Labels,
+		UserRemap:          userRemap,
+		NetworkOpt:         nc,
+		DNS:                dns,
+		Binary:             cfg.Binary,
+		Runtimes:           ociRuntimes(cfg.Runtimes),
+		DefaultRuntime:     cfg.DefaultRuntime,
+		ApparmorProfile:    cfg.ApparmorProfile,
+		Selinux:            cfg.SELinux,
+		Scheduler:          scheduler,
+		TraceSocket:        common.traceSocket,
+		CgroupParent:       cfg.DefaultCgroupParent,
+		CDIManager:         cdiManager,
+		Checkpoint:         cfg.Checkpoint,
+	}
+
+	opt, err := runc.NewWorkerOpt(workerOpts)
 	if err != nil {
 		return nil, err
 	}
 	opt.GCPolicy = getGCPolicy(cfg.GCConfig, common.config.Root)
 	opt.BuildkitVersion = getBuildkitVersion()
 	opt.RegistryHosts = hosts
+	opt.SecurityProfiles = secProfiles
 
 	if platformsStr := cfg.Platforms; len(platformsStr) != 0 {
 		platforms, err := parsePlatforms(platformsStr)
@@ -462,6 +514,21 @@ func snapshotterFactory(commonRoot string, cfg config.OCIConfig, sm *session.Man
 	return snFactory, nil
 }
 
+func ociRuntimes(cfg map[string]config.OCIRuntimeConfig) map[string]string {
+	if len(cfg) == 0 {
+		return nil
+	}
+	runtimes := make(map[string]string, len(cfg))
+	for name, rcfg := range cfg {
+		binary := rcfg.Binary
+		if binary == "" {
+			binary = name
+		}
+		runtimes[name] = binary
+	}
+	return runtimes
+}
+
 func validOCIBinary() bool {
 	_, err := exec.LookPath("runc")
 	_, err1 := exec.LookPath("buildkit-runc")
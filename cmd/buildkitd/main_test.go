@@ -23,6 +23,23 @@ func TestApplyMainFlagsProxyNetworkOverridesConfig(t *testing.T) {
 	require.False(t, cfg.ProxyNetwork)
 }
 
+func TestParseInsecureEntitlements(t *testing.T) {
+	cfg := config.Config{}
+	err := runApplyMainFlags(t, []string{"--allow-insecure-entitlement=security.runtime", "--allow-insecure-entitlement=network.host"}, &cfg)
+	require.NoError(t, err)
+
+	ents, err := parseInsecureEntitlements(cfg.Entitlements)
+	require.NoError(t, err)
+	require.Equal(t, []string{"security.runtime", "network.host"}, ents)
+
+	ents, err = parseInsecureEntitlements([]string{"network.custom"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"network.custom"}, ents)
+
+	_, err = parseInsecureEntitlements([]string{"security.unknown"})
+	require.ErrorContains(t, err, "invalid entitlement : security.unknown")
+}
+
 func runApplyMainFlags(t *testing.T, args []string, cfg *config.Config) error {
 	t.Helper()
 
@@ -32,6 +49,9 @@ func runApplyMainFlags(t *testing.T, args []string, cfg *config.Config) error {
 			&cli.BoolFlag{
 				Name: "proxy-network",
 			},
+			&cli.StringSliceFlag{
+				Name: "allow-insecure-entitlement",
+			},
 		},
 		Action: func(_ context.Context, cmd *cli.Command) error {
 			return applyMainFlags(cmd, cfg, nil)
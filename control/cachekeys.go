@@ -0,0 +1,164 @@
+package control
+
+import (
+	"context"
+	"strings"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/cachestore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (c *Controller) ListCacheKeys(ctx context.Context, req *controlapi.ListCacheKeysRequest) (*controlapi.ListCacheKeysResponse, error) {
+	var ids []string
+	if err := c.opt.CacheStore.Walk(func(id string) error {
+		ids = append(ids, id)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var infos []*solver.CacheKeyInfo
+	var summaries []string
+	for _, id := range ids {
+		info, err := solver.InspectCacheKey(c.opt.CacheStore, id)
+		if err != nil {
+			// the key may have been released after the walk
+			if errors.Is(err, solver.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		summary := cachestore.Summary(ctx, info)
+		if req.Filter != "" && !matchCacheKey(info, summary, req.Filter) {
+			continue
+		}
+		infos = append(infos, info)
+		summaries = append(summaries, summary)
+	}
+
+	usage, err := c.cacheResultUsage(ctx, infos...)
+	if err != nil {
+		return nil, err
+	}
+	resp := &controlapi.ListCacheKeysResponse{}
+	for i, info := range infos {
+		resp.Keys = append(resp.Keys, toCacheKeyRecord(info, summaries[i], usage))
+	}
+	return resp, nil
+}
+
+func (c *Controller) InspectCacheKey(ctx context.Context, req *controlapi.InspectCacheKeyRequest) (*controlapi.InspectCacheKeyResponse, error) {
+	info, err := solver.InspectCacheKey(c.opt.CacheStore, req.ID)
+	if err != nil {
+		if errors.Is(err, solver.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "cache key %s not found", req.ID)
+		}
+		return nil, err
+	}
+	usage, err := c.cacheResultUsage(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	return &controlapi.InspectCacheKeyResponse{
+		Key: toCacheKeyRecord(info, cachestore.Summary(ctx, info), usage),
+	}, nil
+}
+
+func (c *Controller) InvalidateCacheKey(ctx context.Context, req *controlapi.InvalidateCacheKeyRequest) (*controlapi.InvalidateCacheKeyResponse, error) {
+	results, err := solver.InvalidateCacheKey(c.opt.CacheStore, req.ID, req.Dependents)
+	if err != nil {
+		if errors.Is(err, solver.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "cache key %s not found", req.ID)
+		}
+		return nil, err
+	}
+	bklog.G(ctx).Infof("invalidated cache key %s: released %d results", req.ID, len(results))
+	return &controlapi.InvalidateCacheKeyResponse{Results: results}, nil
+}
+
+func matchCacheKey(info *solver.CacheKeyInfo, summary, filter string) bool {
+	if strings.Contains(info.ID, filter) || strings.Contains(summary, filter) {
+		return true
+	}
+	for _, res := range info.Results {
+		if strings.Contains(res.ID, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheResultUsage returns the usage of the worker records of the results
+// of the keys by result ID.
+func (c *Controller) cacheResultUsage(ctx context.Context, infos ...*solver.CacheKeyInfo) (map[string]*client.UsageInfo, error) {
+	// results are stored as <worker id>::<ref id>
+	filters := map[string][]string{}
+	for _, info := range infos {
+		for _, res := range info.Results {
+			workerID, refID, ok := strings.Cut(res.ID, "::")
+			if !ok || refID == "" {
+				continue
+			}
+			filters[workerID] = append(filters[workerID], "id=="+refID)
+		}
+	}
+	usage := map[string]*client.UsageInfo{}
+	for workerID, filter := range filters {
+		w, err := c.opt.WorkerController.Get(workerID)
+		if err != nil {
+			continue
+		}
+		du, err := w.DiskUsage(ctx, client.DiskUsageInfo{Filter: filter})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range du {
+			usage[workerID+"::"+u.ID] = u
+		}
+	}
+	return usage, nil
+}
+
+func toCacheKeyRecord(info *solver.CacheKeyInfo, summary string, usage map[string]*client.UsageInfo) *controlapi.CacheKeyRecord {
+	rec := &controlapi.CacheKeyRecord{
+		ID:      info.ID,
+		Summary: summary,
+	}
+	for _, res := range info.Results {
+		r := &controlapi.CacheKeyResult{
+			ID:        res.ID,
+			CreatedAt: timestamppb.New(res.CreatedAt),
+		}
+		if u, ok := usage[res.ID]; ok {
+			r.Size = u.Size
+			if u.LastUsedAt != nil {
+				r.LastUsedAt = timestamppb.New(*u.LastUsedAt)
+			}
+		}
+		rec.Results = append(rec.Results, r)
+	}
+	for _, l := range info.Links {
+		rec.Links = append(rec.Links, toCacheKeyLink(l))
+	}
+	for _, l := range info.Backlinks {
+		rec.Backlinks = append(rec.Backlinks, toCacheKeyLink(l))
+	}
+	return rec
+}
+
+func toCacheKeyLink(l solver.CacheKeyInfoLink) *controlapi.CacheKeyLink {
+	return &controlapi.CacheKeyLink{
+		ID:       l.ID,
+		Input:    int64(l.Link.Input),
+		Output:   int64(l.Link.Output),
+		Digest:   l.Link.Digest.String(),
+		Selector: l.Link.Selector.String(),
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	stderrors "errors"
 	"fmt"
+	"io"
 	"runtime/trace"
 	"strconv"
 	"sync"
@@ -33,7 +34,6 @@ import (
 	"github.com/moby/buildkit/session/grpchijack"
 	containerdsnapshot "github.com/moby/buildkit/snapshot/containerd"
 	"github.com/moby/buildkit/solver"
-	"github.com/moby/buildkit/solver/bboltcachestorage"
 	"github.com/moby/buildkit/solver/llbsolver"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
 	"github.com/moby/buildkit/solver/llbsolver/compat"
@@ -44,8 +44,10 @@ import (
 	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/util/db"
 	"github.com/moby/buildkit/util/entitlements"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/imageutil"
 	"github.com/moby/buildkit/util/leaseutil"
+	"github.com/moby/buildkit/util/network"
 	"github.com/moby/buildkit/util/throttle"
 	"github.com/moby/buildkit/util/tracing/forwarder"
 	"github.com/moby/buildkit/util/tracing/transform"
@@ -59,13 +61,22 @@ import (
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const traceShutdownTimeout = 5 * time.Second
 
+// CacheKeyStore is the storage of the cache keys of the local cache manager.
+// It is closed with the controller.
+type CacheKeyStore interface {
+	solver.CacheKeyStorage
+	io.Closer
+}
+
 type Opt struct {
 	SessionManager            *session.Manager
 	WorkerController          *worker.Controller
@@ -77,14 +88,20 @@ type Opt struct {
 	TraceCollector            sdktrace.SpanExporter
 	MeterProvider             metric.MeterProvider
 	HistoryDB                 db.DB
-	CacheStore                *bboltcachestorage.Store
+	CacheStore                CacheKeyStore
 	LeaseManager              *leaseutil.Manager
 	ContentStore              *containerdsnapshot.Store
 	HistoryConfig             *config.HistoryConfig
 	ProxyNetwork              bool
+	ProxyEgress               *network.EgressPolicy
 	GarbageCollect            func(context.Context) error
 	GracefulStop              <-chan struct{}
 	ProvenanceEnv             map[string]any
+
+	// TrustClientScheduling accepts the tenant and the high priority class
+	// that clients request for their builds. Otherwise the tenant is the
+	// common name of the TLS client certificate.
+	TrustClientScheduling bool
 }
 
 type Controller struct { // TODO: ControlService
@@ -126,6 +143,7 @@ func NewController(opt Opt) (*Controller, error) {
 		Entitlements:     opt.Entitlements,
 		HistoryQueue:     hq,
 		ProxyNetwork:     opt.ProxyNetwork,
+		ProxyEgress:      opt.ProxyEgress,
 		ProvenanceEnv:    opt.ProvenanceEnv,
 		MeterProvider:    opt.MeterProvider,
 	})
@@ -367,6 +385,63 @@ func (c *Controller) UpdateBuildHistory(ctx context.Context, req *controlapi.Upd
 	return &controlapi.UpdateBuildHistoryResponse{}, err
 }
 
+func (c *Controller) ExplainCacheMiss(ctx context.Context, req *controlapi.ExplainCacheMissRequest) (*controlapi.ExplainCacheMissResponse, error) {
+	dgst, err := digest.Parse(req.Vertex)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid vertex digest %q", req.Vertex)
+	}
+	exp, err := c.solver.ExplainCacheMiss(ctx, req.Ref, dgst)
+	if err != nil {
+		return nil, err
+	}
+	resp := &controlapi.ExplainCacheMissResponse{
+		Vertex:         exp.Vertex.String(),
+		Name:           exp.Name,
+		Cached:         exp.Cached,
+		PreviousRef:    exp.PreviousRef,
+		PreviousVertex: exp.PreviousVertex.String(),
+	}
+	for _, r := range exp.Reasons {
+		resp.Reasons = append(resp.Reasons, &controlapi.CacheMissReason{
+			Type:   string(r.Type),
+			Vertex: r.Vertex.String(),
+			Name:   r.Name,
+			Field:  r.Field,
+			Old:    r.Old,
+			New:    r.New,
+		})
+	}
+	return resp, nil
+}
+
+func (c *Controller) ResourceUsage(ctx context.Context, req *controlapi.ResourceUsageRequest) (*controlapi.ResourceUsageResponse, error) {
+	rec, err := c.solver.ResourceUsage(ctx, req.Ref)
+	if err != nil {
+		return nil, err
+	}
+	resp := &controlapi.ResourceUsageResponse{}
+	for _, v := range rec.Vertexes {
+		resp.Vertexes = append(resp.Vertexes, &controlapi.VertexResourceUsage{
+			Vertex:       v.Digest.String(),
+			Name:         v.Name,
+			Started:      timestamppb.New(v.Started),
+			Duration:     int64(v.Duration),
+			CPU:          int64(v.CPU),
+			PeakMemory:   v.PeakMemory,
+			OOMKills:     v.OOMKills,
+			PeakPIDs:     v.PeakPIDs,
+			IOReadBytes:  v.IOReadBytes,
+			IOWriteBytes: v.IOWriteBytes,
+			NetRxBytes:   v.NetRxBytes,
+			NetTxBytes:   v.NetTxBytes,
+			CPUStall:     int64(v.CPUStall),
+			MemoryStall:  int64(v.MemoryStall),
+			IOStall:      int64(v.IOStall),
+		})
+	}
+	return resp, nil
+}
+
 func translateLegacySolveRequest(req *controlapi.SolveRequest) {
 	// translates ExportRef and ExportAttrs to new Exports (v0.4.0)
 	if legacyExportRef := req.Cache.ExportRefDeprecated; legacyExportRef != "" {
@@ -425,6 +500,11 @@ func (c *Controller) Solve(ctx context.Context, req *controlapi.SolveRequest) (*
 		return nil, err
 	}
 
+	schedReq, err := c.fairshareRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
 	defer func() {
 		time.AfterFunc(time.Second, c.throttledGC)
 	}()
@@ -572,6 +652,21 @@ func (c *Controller) Solve(ctx context.Context, req *controlapi.SolveRequest) (*
 		procs = append(procs, proc.ProvenanceProcessor(slsaVersion, params, c.opt.ProvenanceEnv))
 	}
 
+	proxy := llbsolver.ProxyNetworkRequest{
+		Enabled: req.ProxyNetwork,
+		Record:  req.ProxyRecord,
+	}
+	if req.ProxyReplay {
+		proxy.Replay = make(map[string]digest.Digest, len(req.ProxyReplayMaterials))
+		for u, v := range req.ProxyReplayMaterials {
+			dgst, err := digest.Parse(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid digest for proxy replay material %s", u)
+			}
+			proxy.Replay[u] = dgst
+		}
+	}
+
 	resp, err := c.solver.Solve(ctx, req.Ref, req.Session, frontend.SolveRequest{
 		Frontend:       req.Frontend,
 		Definition:     req.Definition,
@@ -582,7 +677,7 @@ func (c *Controller) Solve(ctx context.Context, req *controlapi.SolveRequest) (*
 		Exporters:             expis,
 		CacheExporters:        cacheExporters,
 		EnableSessionExporter: req.EnableSessionExporter,
-	}, entitlementsFromPB(req.Entitlements), procs, req.Internal, req.SourcePolicy, req.SourcePolicySession, req.ProxyNetwork)
+	}, entitlementsFromPB(req.Entitlements), procs, req.Internal, req.SourcePolicy, req.SourcePolicySession, proxy, schedReq)
 	if err != nil {
 		return nil, err
 	}
@@ -849,6 +944,42 @@ func sendTimestampHeader(srv grpc.ServerStream) error {
 	return srv.SendHeader(metadata.Pairs(timestampKey, time.Now().Format(time.RFC3339Nano)))
 }
 
+// fairshareRequest returns the priority class and tenant of a build. Unless
+// the daemon trusts the clients, the tenant is derived from the connection
+// and builds can't raise their priority, as the tenant also isolates the
+// checkpoints and proxy recordings of builds.
+func (c *Controller) fairshareRequest(ctx context.Context, req *controlapi.SolveRequest) (fairshare.Request, error) {
+	priority, err := fairshare.ParsePriority(req.Priority)
+	if err != nil {
+		return fairshare.Request{}, err
+	}
+	if c.opt.TrustClientScheduling {
+		return fairshare.Request{Priority: priority, Tenant: req.Tenant}, nil
+	}
+	tenant := peerTenant(ctx)
+	if req.Tenant != "" && req.Tenant != tenant {
+		return fairshare.Request{}, status.Errorf(codes.PermissionDenied, "tenant %q is not allowed for this client, it can only be set if scheduling.trustClients is enabled", req.Tenant)
+	}
+	if priority > fairshare.PriorityNormal {
+		return fairshare.Request{}, status.Errorf(codes.PermissionDenied, "priority class %q is only allowed if scheduling.trustClients is enabled", priority)
+	}
+	return fairshare.Request{Priority: priority, Tenant: tenant}, nil
+}
+
+// peerTenant returns the common name of the verified TLS client certificate
+// of the connection, or an empty string for the default tenant.
+func peerTenant(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
 func entitlementsFromPB(elems []string) []entitlements.Entitlement {
 	clone := make([]entitlements.Entitlement, len(elems))
 	for i, e := range elems {
@@ -1,10 +1,19 @@
 package control
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"testing"
 
 	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 func TestDuplicateCacheOptions(t *testing.T) {
@@ -201,3 +210,44 @@ func TestParseCacheExportIgnoreError(t *testing.T) {
 		})
 	}
 }
+
+func TestFairshareRequest(t *testing.T) {
+	ctx := context.TODO()
+	tlsCtx := peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "team-a"}}}},
+			},
+		},
+	})
+
+	c := &Controller{}
+
+	req, err := c.fairshareRequest(ctx, &controlapi.SolveRequest{Priority: "low"})
+	require.NoError(t, err)
+	require.Equal(t, fairshare.Request{Priority: fairshare.PriorityLow}, req)
+
+	req, err = c.fairshareRequest(tlsCtx, &controlapi.SolveRequest{})
+	require.NoError(t, err)
+	require.Equal(t, fairshare.Request{Tenant: "team-a"}, req)
+
+	req, err = c.fairshareRequest(tlsCtx, &controlapi.SolveRequest{Tenant: "team-a"})
+	require.NoError(t, err)
+	require.Equal(t, fairshare.Request{Tenant: "team-a"}, req)
+
+	// clients can't choose the tenant or raise their priority
+	_, err = c.fairshareRequest(tlsCtx, &controlapi.SolveRequest{Tenant: "team-b"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = c.fairshareRequest(ctx, &controlapi.SolveRequest{Tenant: "team-a"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = c.fairshareRequest(tlsCtx, &controlapi.SolveRequest{Priority: "high"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = c.fairshareRequest(ctx, &controlapi.SolveRequest{Priority: "urgent"})
+	require.ErrorContains(t, err, "invalid priority class")
+
+	c = &Controller{opt: Opt{TrustClientScheduling: true}}
+	req, err = c.fairshareRequest(tlsCtx, &controlapi.SolveRequest{Tenant: "team-b", Priority: "high"})
+	require.NoError(t, err)
+	require.Equal(t, fairshare.Request{Priority: fairshare.PriorityHigh, Tenant: "team-b"}, req)
+}
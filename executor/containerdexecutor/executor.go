@@ -3,6 +3,7 @@ package containerdexecutor
 import (
 	"context"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"slices"
@@ -33,18 +34,21 @@ type containerdExecutor struct {
 	client           *ctd.Client
 	root             string
 	networkProviders map[pb.NetMode]network.Provider
-	proxyProvider    network.ProxyProvider
-	cgroupParent     string
-	dnsConfig        *oci.DNSConfig
-	running          map[string]*containerState
-	mu               sync.Mutex
-	apparmorProfile  string
-	selinux          bool
-	traceSocket      string
-	rootless         bool
-	hypervIsolation  bool
-	runtime          *RuntimeInfo
-	cdiManager       *cdidevices.Manager
+	// namedNetworkProviders are selected by Meta.NetworkName.
+	namedNetworkProviders map[string]network.Provider
+	proxyProvider         network.ProxyProvider
+	cgroupParent          string
+	dnsConfig             *oci.DNSConfig
+	running               map[string]*containerState
+	mu                    sync.Mutex
+	networks              executor.SharedNetworks
+	apparmorProfile       string
+	selinux               bool
+	traceSocket           string
+	rootless              bool
+	hypervIsolation       bool
+	runtime               *RuntimeInfo
+	cdiManager            *cdidevices.Manager
 }
 
 // OnCreateRuntimer provides an alternative to OCI hooks for applying network
@@ -71,15 +75,18 @@ type ExecutorOptions struct {
 	Root             string
 	CgroupParent     string
 	NetworkProviders map[pb.NetMode]network.Provider
-	ProxyProvider    network.ProxyProvider
-	DNSConfig        *oci.DNSConfig
-	ApparmorProfile  string
-	Selinux          bool
-	TraceSocket      string
-	Rootless         bool
-	HyperVIsolation  bool
-	Runtime          *RuntimeInfo
-	CDIManager       *cdidevices.Manager
+	// NamedNetworkProviders are the CNI networks that execs can select by
+	// name.
+	NamedNetworkProviders map[string]network.Provider
+	ProxyProvider         network.ProxyProvider
+	DNSConfig             *oci.DNSConfig
+	ApparmorProfile       string
+	Selinux               bool
+	TraceSocket           string
+	Rootless              bool
+	HyperVIsolation       bool
+	Runtime               *RuntimeInfo
+	CDIManager            *cdidevices.Manager
 }
 
 // New creates a new executor backed by connection to containerd API
@@ -89,20 +96,21 @@ func New(executorOpts ExecutorOptions) executor.Executor {
 	os.RemoveAll(filepath.Join(executorOpts.Root, "resolv.conf"))
 
 	return &containerdExecutor{
-		client:           executorOpts.Client,
-		root:             executorOpts.Root,
-		networkProviders: executorOpts.NetworkProviders,
-		proxyProvider:    executorOpts.ProxyProvider,
-		cgroupParent:     executorOpts.CgroupParent,
-		dnsConfig:        executorOpts.DNSConfig,
-		running:          make(map[string]*containerState),
-		apparmorProfile:  executorOpts.ApparmorProfile,
-		selinux:          executorOpts.Selinux,
-		traceSocket:      executorOpts.TraceSocket,
-		rootless:         executorOpts.Rootless,
-		hypervIsolation:  executorOpts.HyperVIsolation,
-		runtime:          executorOpts.Runtime,
-		cdiManager:       executorOpts.CDIManager,
+		client:                executorOpts.Client,
+		root:                  executorOpts.Root,
+		networkProviders:      executorOpts.NetworkProviders,
+		namedNetworkProviders: executorOpts.NamedNetworkProviders,
+		proxyProvider:         executorOpts.ProxyProvider,
+		cgroupParent:          executorOpts.CgroupParent,
+		dnsConfig:             executorOpts.DNSConfig,
+		running:               make(map[string]*containerState),
+		apparmorProfile:       executorOpts.ApparmorProfile,
+		selinux:               executorOpts.Selinux,
+		traceSocket:           executorOpts.TraceSocket,
+		rootless:              executorOpts.Rootless,
+		hypervIsolation:       executorOpts.HyperVIsolation,
+		runtime:               executorOpts.Runtime,
+		cdiManager:            executorOpts.CDIManager,
 	}
 }
 
@@ -123,6 +131,9 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 	if err := executor.ValidContainerID(id); err != nil {
 		return nil, err
 	}
+	if name := process.Meta.Runtime; name != "" {
+		return nil, errors.Errorf("runtime %q is not supported by the containerd worker", name)
+	}
 
 	startedOnce := sync.Once{}
 	done := make(chan error, 1)
@@ -136,6 +147,7 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 		w.mu.Lock()
 		delete(w.running, id)
 		w.mu.Unlock()
+		w.networks.Remove(id)
 		done <- err
 		close(done)
 		if started != nil {
@@ -152,20 +164,31 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 
 	proxyConfig := meta.Proxy
 	var provider network.Provider
-	if proxyConfig == nil {
+	if meta.NetworkFrom != "" {
+		if proxyConfig != nil {
+			return nil, errors.New("joining the network of another container is not supported with proxy network")
+		}
+	} else if proxyConfig == nil {
 		var ok bool
-		provider, ok = w.networkProviders[meta.NetMode]
-		if !ok {
-			return nil, errors.Errorf("unknown network mode %s", meta.NetMode)
+		if meta.NetworkName != "" {
+			provider, ok = w.namedNetworkProviders[meta.NetworkName]
+			if !ok {
+				return nil, errors.Errorf("unknown network %q", meta.NetworkName)
+			}
+		} else {
+			provider, ok = w.networkProviders[meta.NetMode]
+			if !ok {
+				return nil, errors.Errorf("unknown network mode %s", meta.NetMode)
+			}
 		}
+	} else if meta.NetworkName != "" {
+		return nil, errors.Errorf("network %q is not supported with proxy network", meta.NetworkName)
 	} else if w.proxyProvider == nil {
 		return nil, errors.New("proxy network provider is not available")
 	} else {
-		proxyConfig = &network.ProxyConfig{
-			Policy:     proxyConfig.Policy,
-			Capture:    proxyConfig.Capture,
-			EgressMode: meta.NetMode,
-		}
+		cfg := *proxyConfig
+		cfg.EgressMode = meta.NetMode
+		proxyConfig = &cfg
 	}
 
 	resolvConf, hostsFile, releasers, err := w.prepareExecutionEnv(ctx, root, mounts, meta, details, meta.NetMode)
@@ -182,7 +205,9 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 	}
 
 	var namespace network.Namespace
-	if proxyConfig != nil {
+	if meta.NetworkFrom != "" {
+		namespace, err = w.networks.Join(meta.NetworkFrom)
+	} else if proxyConfig != nil {
 		namespace, err = w.proxyProvider.NewProxy(ctx, proxyConfig)
 	} else {
 		namespace, err = provider.New(ctx, meta.Hostname, network.NamespaceOptions{})
@@ -190,7 +215,9 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 	if err != nil {
 		return nil, err
 	}
-	defer namespace.Close()
+	defer func() {
+		namespace.Close()
+	}()
 	if proxyNS, ok := namespace.(network.ProxyNamespace); ok {
 		meta.Env = append(meta.Env, proxyNS.ProxyEnv()...)
 		cleanProxyCA, err := executor.InjectProxyCA(details.rootfsPath, proxyNS.ProxyCACert())
@@ -254,6 +281,12 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 			return nil, err
 		}
 	}
+	if meta.NetworkFrom == "" {
+		pid := int(task.Pid())
+		namespace = w.networks.Add(id, namespace, func() (int, error) {
+			return pid, nil
+		})
+	}
 
 	trace.SpanFromContext(ctx).AddEvent("Container created")
 	err = w.runProcess(ctx, task, process.Resize, process.Signal, process.Meta.ValidExitCodes, func() {
@@ -267,6 +300,10 @@ func (w *containerdExecutor) Run(ctx context.Context, id string, root executor.M
 	return nil, err
 }
 
+func (w *containerdExecutor) DialContainer(ctx context.Context, id, networkName, address string) (net.Conn, error) {
+	return w.networks.Dial(ctx, id, networkName, address)
+}
+
 func (w *containerdExecutor) Exec(ctx context.Context, id string, process executor.ProcessInfo) (err error) {
 	meta := process.Meta
 
@@ -0,0 +1,178 @@
+// Package diskquota limits the size of writable mounts of build steps.
+//
+// Project quotas of the backing filesystem (XFS, or ext4 with the prjquota
+// feature) are used where they are supported. Otherwise the writable
+// directory becomes the lower layer of an overlay mount whose upper directory
+// is on a loop-backed filesystem. Its size is the limit minus the size of the
+// existing data, and only the changes of the step are copied back afterwards.
+package diskquota
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/moby/buildkit/executor"
+	"github.com/pkg/errors"
+)
+
+// Controller applies size limits to writable mounts. The zero value is not
+// usable, use NewController instead.
+type Controller struct {
+	root string
+
+	mu       sync.Mutex
+	backings map[uint64]*backingFS
+}
+
+// NewController returns a controller that keeps its state in root. Nothing
+// is created until a limit is applied for the first time, state left behind
+// by a previous daemon is removed.
+func NewController(root string) *Controller {
+	cleanupLoopQuotas(root)
+	return &Controller{
+		root:     root,
+		backings: map[uint64]*backingFS{},
+	}
+}
+
+// Limit returns a Mountable that limits the size of the writable mounts of m
+// to limit bytes. Shared mounts that can be used by other steps at the same
+// time are never copied to a loop-backed filesystem so they can only be
+// limited with project quotas.
+func (c *Controller) Limit(m executor.Mountable, limit int64, shared bool) *Mountable {
+	return &Mountable{
+		Mountable: m,
+		c:         c,
+		limit:     limit,
+		shared:    shared,
+	}
+}
+
+// Mountable limits the size of the writable mounts of the wrapped Mountable.
+type Mountable struct {
+	executor.Mountable
+	c      *Controller
+	limit  int64
+	shared bool
+
+	mu       sync.Mutex
+	exceeded bool
+}
+
+func (m *Mountable) Mount(ctx context.Context, readonly bool) (executor.MountableRef, error) {
+	ref, err := m.Mountable.Mount(ctx, readonly)
+	if err != nil || readonly {
+		return ref, err
+	}
+	return &mountableRef{MountableRef: ref, m: m}, nil
+}
+
+// Limit returns the size limit of the mount in bytes.
+func (m *Mountable) Limit() int64 {
+	return m.limit
+}
+
+// Exceeded returns true if the size limit had been reached when the mount was
+// released.
+func (m *Mountable) Exceeded() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.exceeded
+}
+
+type mountableRef struct {
+	executor.MountableRef
+	m *Mountable
+}
+
+func (r *mountableRef) Mount() ([]mount.Mount, func() error, error) {
+	mounts, release, err := r.MountableRef.Mount()
+	if err != nil {
+		return nil, nil, err
+	}
+	mounts, q, err := r.m.c.apply(mounts, r.m.limit, r.m.shared)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return mounts, func() error {
+		exceeded, err := q.exceeded()
+		if exceeded {
+			r.m.mu.Lock()
+			r.m.exceeded = true
+			r.m.mu.Unlock()
+		}
+		if err1 := q.release(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err1 := release(); err1 != nil && err == nil {
+			err = err1
+		}
+		return err
+	}, nil
+}
+
+// quota is a size limit applied to a writable directory.
+type quota interface {
+	// exceeded returns true if no more data can be written.
+	exceeded() (bool, error)
+	release() error
+}
+
+// writableDir returns the directory that receives the writes to mounts.
+func writableDir(mounts []mount.Mount) (string, error) {
+	if len(mounts) != 1 {
+		return "", errors.Errorf("disk quota is not supported for %d mounts", len(mounts))
+	}
+	m := mounts[0]
+	switch m.Type {
+	case "overlay":
+		for _, o := range m.Options {
+			if v, ok := strings.CutPrefix(o, "upperdir="); ok {
+				return v, nil
+			}
+		}
+		return "", errors.New("disk quota is not supported for read-only overlay mounts")
+	case "bind", "rbind":
+		for _, o := range m.Options {
+			if o == "ro" {
+				return "", errors.New("disk quota is not supported for read-only bind mounts")
+			}
+		}
+		return m.Source, nil
+	}
+	return "", errors.Errorf("disk quota is not supported for %s mounts", m.Type)
+}
+
+// layerWritableDir returns an overlay mount that uses dir, the writable
+// directory of mounts, as its lowest layer and writes to upper. Overlay
+// mounts keep their other layers.
+func layerWritableDir(mounts []mount.Mount, dir, upper, work string) []mount.Mount {
+	m := mounts[0]
+	if m.Type != "overlay" {
+		return []mount.Mount{{
+			Type:    "overlay",
+			Source:  "overlay",
+			Options: []string{"lowerdir=" + dir, "upperdir=" + upper, "workdir=" + work},
+		}}
+	}
+	m.Options = append([]string(nil), m.Options...)
+	for i, o := range m.Options {
+		if v, ok := strings.CutPrefix(o, "lowerdir="); ok {
+			m.Options[i] = "lowerdir=" + dir + ":" + v
+		} else if strings.HasPrefix(o, "upperdir=") {
+			m.Options[i] = "upperdir=" + upper
+		} else if strings.HasPrefix(o, "workdir=") {
+			m.Options[i] = "workdir=" + work
+		}
+	}
+	return []mount.Mount{m}
+}
+
+// exceededUsage returns true if usage is close enough to limit that writes
+// are expected to fail.
+func exceededUsage(usage, limit int64) bool {
+	return usage >= limit-min(limit/100, 1<<20)
+}
@@ -0,0 +1,539 @@
+package diskquota
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"unsafe"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/containerd/continuity/fs"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// minProjectID is the first project ID used for build steps. Lower IDs
+	// are left for project quotas that are managed outside of BuildKit.
+	minProjectID = 1 << 20
+
+	// ioctls and flags from linux/fs.h
+	fsIocFsGetXattr    = 0x801c581f
+	fsIocFsSetXattr    = 0x401c5820
+	fsXflagProjInherit = 0x200
+
+	// quotactl commands and flags from linux/dqblk_xfs.h
+	qXGetQuota     = 0x5803
+	qXSetQLim      = 0x5804
+	prjQuota       = 2
+	fsDquotVersion = 1
+	fsProjQuota    = 2
+	fsDqBSoft      = 1 << 2
+	fsDqBHard      = 1 << 3
+
+	basicBlockSize = 512
+)
+
+// fsxattr matches struct fsxattr in linux/fs.h.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// fsDiskQuota matches struct fs_disk_quota in linux/dqblk_xfs.h.
+type fsDiskQuota struct {
+	version      int8
+	flags        int8
+	fieldmask    uint16
+	id           uint32
+	blkHardlimit uint64
+	blkSoftlimit uint64
+	inoHardlimit uint64
+	inoSoftlimit uint64
+	bcount       uint64
+	icount       uint64
+	itimer       int32
+	btimer       int32
+	iwarns       uint16
+	bwarns       uint16
+	padding2     int32
+	rtbHardlimit uint64
+	rtbSoftlimit uint64
+	rtbcount     uint64
+	rtbtimer     int32
+	rtbwarns     uint16
+	padding3     int16
+	padding4     [8]byte
+}
+
+// backingFS is a filesystem that contains writable directories.
+type backingFS struct {
+	// device is a block device node for the filesystem that is passed to
+	// quotactl.
+	device string
+	// projectQuota is true if the filesystem enforces project quotas.
+	projectQuota bool
+}
+
+func (c *Controller) apply(mounts []mount.Mount, limit int64, shared bool) ([]mount.Mount, quota, error) {
+	dir, err := writableDir(mounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := c.backing(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.projectQuota {
+		q, err := c.projectQuota(b, dir, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mounts, q, nil
+	}
+	if shared {
+		return nil, nil, errors.Errorf("size limit of shared mount %s requires project quota support", dir)
+	}
+	q, err := c.loopQuota(mounts, dir, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return layerWritableDir(mounts, dir, q.upper, q.work), q, nil
+}
+
+// backing returns the filesystem of dir and detects if it supports project
+// quotas. Must be called with c.mu held.
+func (c *Controller) backing(dir string) (*backingFS, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dev := uint64(st.Dev)
+	if b, ok := c.backings[dev]; ok {
+		return b, nil
+	}
+	if err := os.MkdirAll(c.root, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b := &backingFS{
+		device: filepath.Join(c.root, "backingdev-"+strconv.FormatUint(dev, 10)),
+	}
+	_ = os.Remove(b.device)
+	if err := unix.Mknod(b.device, unix.S_IFBLK|0600, int(dev)); err == nil {
+		// A limit of 0 removes the limit so this only checks that quotas
+		// are enabled for the filesystem.
+		b.projectQuota = setProjectLimit(b.device, minProjectID, 0) == nil
+	}
+	c.backings[dev] = b
+	return b, nil
+}
+
+func (c *Controller) projectQuota(b *backingFS, dir string, limit int64) (quota, error) {
+	id, err := getProjectID(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Directories that already have a project ID keep it so that cache
+	// mounts are limited including the data written by earlier steps.
+	if id < minProjectID {
+		if id, err = c.nextProjectID(); err != nil {
+			return nil, err
+		}
+		if err := setProjectID(dir, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := setProjectLimit(b.device, id, uint64(limit)); err != nil {
+		return nil, err
+	}
+	return &projectQuota{device: b.device, id: id, limit: limit}, nil
+}
+
+// nextProjectID allocates a project ID that has not been used before. Must be
+// called with c.mu held.
+func (c *Controller) nextProjectID() (uint32, error) {
+	fn := filepath.Join(c.root, "projid")
+	id := uint32(minProjectID)
+	dt, err := os.ReadFile(fn)
+	if err == nil && len(dt) == 4 {
+		id = max(id, binary.LittleEndian.Uint32(dt))
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, errors.WithStack(err)
+	}
+	if id == ^uint32(0) {
+		return 0, errors.New("no project IDs left for disk quota")
+	}
+	dt = binary.LittleEndian.AppendUint32(nil, id+1)
+	if err := os.WriteFile(fn, dt, 0600); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return id, nil
+}
+
+type projectQuota struct {
+	device string
+	id     uint32
+	limit  int64
+}
+
+func (q *projectQuota) exceeded() (bool, error) {
+	d := fsDiskQuota{}
+	if err := quotactl(qXGetQuota, q.device, q.id, &d); err != nil {
+		return false, errors.Wrapf(err, "failed to get usage of project %d", q.id)
+	}
+	return exceededUsage(int64(d.bcount*basicBlockSize), q.limit), nil
+}
+
+func (q *projectQuota) release() error {
+	return setProjectLimit(q.device, q.id, 0)
+}
+
+func getProjectID(dir string) (uint32, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer f.Close()
+	var attr fsxattr
+	if err := ioctl(f, fsIocFsGetXattr, &attr); err != nil {
+		return 0, errors.Wrapf(err, "failed to get project ID of %s", dir)
+	}
+	return attr.projid, nil
+}
+
+func setProjectID(dir string, id uint32) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	var attr fsxattr
+	if err := ioctl(f, fsIocFsGetXattr, &attr); err != nil {
+		return errors.Wrapf(err, "failed to get project ID of %s", dir)
+	}
+	attr.projid = id
+	attr.xflags |= fsXflagProjInherit
+	if err := ioctl(f, fsIocFsSetXattr, &attr); err != nil {
+		return errors.Wrapf(err, "failed to set project ID of %s", dir)
+	}
+	return nil
+}
+
+func setProjectLimit(device string, id uint32, limit uint64) error {
+	blocks := (limit + basicBlockSize - 1) / basicBlockSize
+	d := fsDiskQuota{
+		version:      fsDquotVersion,
+		flags:        fsProjQuota,
+		fieldmask:    fsDqBHard | fsDqBSoft,
+		id:           id,
+		blkHardlimit: blocks,
+		blkSoftlimit: blocks,
+	}
+	if err := quotactl(qXSetQLim, device, id, &d); err != nil {
+		return errors.Wrapf(err, "failed to set limit for project %d", id)
+	}
+	return nil
+}
+
+func quotactl(cmd int, device string, id uint32, d *fsDiskQuota) error {
+	p, err := unix.BytePtrFromString(device)
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL, uintptr(cmd<<8|prjQuota), uintptr(unsafe.Pointer(p)), uintptr(id), uintptr(unsafe.Pointer(d)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func ioctl(f *os.File, req uintptr, attr *fsxattr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(attr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// loopQuota redirects writes to a loop-backed filesystem of the limited size.
+// The writable directory becomes the lowest layer of an overlay mount so that
+// only the changes of the step are written to the loop-backed filesystem and
+// copied back.
+type loopQuota struct {
+	// size is the size of the loop-backed filesystem, the limit minus the
+	// size of the existing data.
+	size  int64
+	dir   string
+	tmp   string
+	mnt   string
+	upper string
+	work  string
+	// overlay is true if dir is the upper directory of an overlay mount,
+	// that keeps the whiteouts and opaque directories of the changes.
+	overlay bool
+	// xattrPrefix is the prefix of the overlay xattrs.
+	xattrPrefix string
+}
+
+// cleanupLoopQuotas removes the loop-backed filesystems left behind by a
+// daemon that did not shut down cleanly. Their changes are lost as the step
+// that made them did not complete.
+func cleanupLoopQuotas(root string) {
+	dirs, err := filepath.Glob(filepath.Join(root, "loop-*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if err := mount.UnmountAll(filepath.Join(dir, "mnt"), 0); err != nil {
+			bklog.L.WithError(err).Warnf("failed to unmount stale disk quota filesystem %s", dir)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			bklog.L.WithError(err).Warnf("failed to remove stale disk quota filesystem %s", dir)
+		}
+	}
+}
+
+func (c *Controller) loopQuota(mounts []mount.Mount, dir string, limit int64) (_ *loopQuota, retErr error) {
+	mkfs, err := exec.LookPath("mkfs.ext4")
+	if err != nil {
+		return nil, errors.Wrap(err, "disk quota requires project quota support or mkfs.ext4 for a loop-backed filesystem")
+	}
+	// the existing data stays in dir but counts against the limit
+	usage, err := dirUsage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if exceededUsage(usage, limit) {
+		return nil, errors.Errorf("size of %s exceeds the disk quota of %d bytes", dir, limit)
+	}
+	if err := os.MkdirAll(c.root, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tmp, err := os.MkdirTemp(c.root, "loop-")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	q := &loopQuota{
+		size:        limit - usage,
+		dir:         dir,
+		tmp:         tmp,
+		mnt:         filepath.Join(tmp, "mnt"),
+		upper:       filepath.Join(tmp, "mnt", "upper"),
+		work:        filepath.Join(tmp, "mnt", "work"),
+		overlay:     mounts[0].Type == "overlay",
+		xattrPrefix: "trusted.overlay.",
+	}
+	if slices.Contains(mounts[0].Options, "userxattr") {
+		q.xattrPrefix = "user.overlay."
+	}
+	defer func() {
+		if retErr != nil {
+			mount.UnmountAll(q.mnt, 0)
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	img := filepath.Join(tmp, "disk.img")
+	f, err := os.Create(img)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	err = f.Truncate(q.size)
+	f.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if out, err := exec.Command(mkfs, "-q", "-F", "-m", "0", "-O", "^has_journal", img).CombinedOutput(); err != nil {
+		return nil, errors.Wrapf(err, "failed to create loop-backed filesystem: %s", out)
+	}
+	if err := os.Mkdir(q.mnt, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m := mount.Mount{Type: "ext4", Source: img, Options: []string{"loop"}}
+	if err := m.Mount(q.mnt); err != nil {
+		return nil, errors.Wrap(err, "failed to mount loop-backed filesystem")
+	}
+	if err := os.Mkdir(q.work, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// the root of the upper directory is the root of the mount
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.Mkdir(q.upper, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.Chmod(q.upper, os.FileMode(st.Mode&0o777)|unixModeBits(st.Mode)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.Lchown(q.upper, int(st.Uid), int(st.Gid)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return q, nil
+}
+
+func unixModeBits(mode uint32) os.FileMode {
+	var m os.FileMode
+	if mode&unix.S_ISUID != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&unix.S_ISGID != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&unix.S_ISVTX != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+func (q *loopQuota) exceeded() (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(q.mnt, &st); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if st.Ffree == 0 {
+		return true, nil
+	}
+	// The filesystem overhead is not available for data so check the free
+	// space instead of the usage. ext4 reserves blocks for the metadata of
+	// delayed allocations so writes fail before all blocks are used.
+	return int64(st.Bavail)*st.Bsize < min(q.size/10, 1<<20), nil
+}
+
+func (q *loopQuota) release() error {
+	err := q.merge()
+	if err1 := mount.UnmountAll(q.mnt, 0); err1 != nil && err == nil {
+		err = err1
+	}
+	if err1 := os.RemoveAll(q.tmp); err1 != nil && err == nil {
+		err = errors.WithStack(err1)
+	}
+	return err
+}
+
+// merge applies the changes in the upper directory of the loop-backed
+// filesystem to the original directory. Entries that were replaced or
+// removed are removed from the original directory first. If it is the upper
+// directory of an overlay mount it keeps the whiteouts and opaque
+// directories, otherwise they are applied to it.
+func (q *loopQuota) merge() error {
+	opaque := q.xattrPrefix + "opaque"
+	err := filepath.WalkDir(q.upper, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == q.upper {
+			return nil
+		}
+		rel, err := filepath.Rel(q.upper, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(q.dir, rel)
+		var tst unix.Stat_t
+		exists := unix.Lstat(target, &tst) == nil
+
+		if isWhiteout(d, p) {
+			if exists {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+			if !q.overlay {
+				// nothing to copy, the entry has been removed
+				return os.Remove(p)
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			if exists {
+				return os.RemoveAll(target)
+			}
+			return nil
+		}
+		isOpaque := getXattr(p, opaque) == "y"
+		if exists && (isOpaque || tst.Mode&unix.S_IFMT != unix.S_IFDIR) {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			// the directory replaced an entry that hid the lower layers
+			if q.overlay && !isOpaque {
+				return errors.WithStack(unix.Lsetxattr(p, opaque, []byte("y"), 0))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to merge loop-backed filesystem to %s", q.dir)
+	}
+	opts := []fs.CopyDirOpt{fs.WithXAttrExclude(q.xattrPrefix+"origin", q.xattrPrefix+"impure")}
+	if !q.overlay {
+		opts = append(opts, fs.WithXAttrExclude(opaque))
+	}
+	if err := fs.CopyDir(q.dir, q.upper, opts...); err != nil {
+		return errors.Wrapf(err, "failed to copy loop-backed filesystem to %s", q.dir)
+	}
+	return nil
+}
+
+// isWhiteout returns true if the entry is an overlay whiteout, a character
+// device with device number 0.
+func isWhiteout(d os.DirEntry, p string) bool {
+	if d.Type()&os.ModeCharDevice == 0 {
+		return false
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat(p, &st); err != nil {
+		return false
+	}
+	return st.Rdev == 0
+}
+
+func getXattr(p, attr string) string {
+	buf := make([]byte, 16)
+	n, err := unix.Lgetxattr(p, attr, buf)
+	if err != nil {
+		return ""
+	}
+	return string(buf[:n])
+}
+
+// dirUsage returns the disk usage of the files in dir.
+func dirUsage(dir string) (int64, error) {
+	var usage int64
+	inodes := map[uint64]struct{}{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		var st unix.Stat_t
+		if err := unix.Lstat(p, &st); err != nil {
+			return err
+		}
+		if st.Nlink > 1 {
+			if _, ok := inodes[st.Ino]; ok {
+				return nil
+			}
+			inodes[st.Ino] = struct{}{}
+		}
+		usage += st.Blocks * basicBlockSize
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get size of %s", dir)
+	}
+	return usage, nil
+}
@@ -0,0 +1,209 @@
+package diskquota
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestNextProjectID(t *testing.T) {
+	c := NewController(t.TempDir())
+
+	id, err := c.nextProjectID()
+	require.NoError(t, err)
+	require.Equal(t, uint32(minProjectID), id)
+
+	id, err = c.nextProjectID()
+	require.NoError(t, err)
+	require.Equal(t, uint32(minProjectID+1), id)
+
+	// IDs are never reused by a new controller for the same root
+	c = NewController(c.root)
+	id, err = c.nextProjectID()
+	require.NoError(t, err)
+	require.Equal(t, uint32(minProjectID+2), id)
+}
+
+func requireLoopQuota(t *testing.T) {
+	t.Helper()
+	if os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("requires mkfs.ext4")
+	}
+}
+
+// writeFile writes size bytes to p and returns the error of the write.
+func writeFile(p string, size int) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(make([]byte, size))
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func withMount(t *testing.T, mounts []mount.Mount, f func(dir string)) {
+	t.Helper()
+	target := t.TempDir()
+	require.NoError(t, mount.All(mounts, target))
+	defer func() {
+		require.NoError(t, mount.UnmountAll(target, 0))
+	}()
+	f(target)
+}
+
+func TestLoopQuotaBind(t *testing.T) {
+	requireLoopQuota(t)
+
+	c := NewController(t.TempDir())
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "keep/sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keep/sub/a"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "remove"), []byte("remove"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "replace/old"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "modify"), []byte("old"), 0o644))
+	// existing data counts against the limit
+	require.NoError(t, writeFile(filepath.Join(dir, "existing"), 4<<20))
+
+	const limit = 16 << 20
+	mounts := []mount.Mount{{Type: "bind", Source: dir, Options: []string{"rbind"}}}
+	q, err := c.loopQuota(mounts, dir, limit)
+	require.NoError(t, err)
+	require.Less(t, q.size, int64(limit-4<<20+1))
+
+	withMount(t, layerWritableDir(mounts, dir, q.upper, q.work), func(root string) {
+		fi, err := os.Stat(root)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+
+		require.NoError(t, os.Remove(filepath.Join(root, "remove")))
+		require.NoError(t, os.RemoveAll(filepath.Join(root, "replace")))
+		require.NoError(t, os.Mkdir(filepath.Join(root, "replace"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "replace/new"), []byte("new"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "modify"), []byte("new"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "keep/sub/b"), []byte("b"), 0o644))
+
+		exceeded, err := q.exceeded()
+		require.NoError(t, err)
+		require.False(t, exceeded)
+
+		err = writeFile(filepath.Join(root, "big"), limit)
+		require.ErrorIs(t, err, syscall.ENOSPC)
+		require.NoError(t, os.Remove(filepath.Join(root, "big")))
+		require.NoError(t, writeFile(filepath.Join(root, "big"), limit/2))
+
+		// the whole filesystem is used by the step
+		err = writeFile(filepath.Join(root, "fill"), limit)
+		require.ErrorIs(t, err, syscall.ENOSPC)
+		exceeded, err = q.exceeded()
+		require.NoError(t, err)
+		require.True(t, exceeded)
+		require.NoError(t, os.Remove(filepath.Join(root, "fill")))
+	})
+
+	require.NoError(t, q.release())
+	_, err = os.Stat(q.tmp)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	dt, err := os.ReadFile(filepath.Join(dir, "keep/sub/a"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(dt))
+	dt, err = os.ReadFile(filepath.Join(dir, "keep/sub/b"))
+	require.NoError(t, err)
+	require.Equal(t, "b", string(dt))
+	dt, err = os.ReadFile(filepath.Join(dir, "modify"))
+	require.NoError(t, err)
+	require.Equal(t, "new", string(dt))
+	_, err = os.Lstat(filepath.Join(dir, "remove"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Lstat(filepath.Join(dir, "replace/old"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	fi, err := os.Stat(filepath.Join(dir, "replace"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), fi.Mode().Perm())
+	fi, err = os.Stat(filepath.Join(dir, "big"))
+	require.NoError(t, err)
+	require.Equal(t, int64(limit/2), fi.Size())
+
+	// no overlay metadata is left in a plain directory
+	sz, err := unix.Lgetxattr(filepath.Join(dir, "replace"), "trusted.overlay.opaque", nil)
+	require.ErrorIs(t, err, unix.ENODATA, "size %d", sz)
+
+	// the limit is already used by the existing data
+	_, err = c.loopQuota(mounts, dir, 8<<20)
+	require.ErrorContains(t, err, "exceeds the disk quota")
+}
+
+func TestLoopQuotaOverlay(t *testing.T) {
+	requireLoopQuota(t)
+
+	c := NewController(t.TempDir())
+	lower := t.TempDir()
+	upper := t.TempDir()
+	work := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(lower, "lower"), []byte("lower"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(lower, "dir/old"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(upper, "upper"), []byte("upper"), 0o644))
+
+	mounts := []mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=" + lower, "upperdir=" + upper, "workdir=" + work},
+	}}
+	q, err := c.loopQuota(mounts, upper, 16<<20)
+	require.NoError(t, err)
+
+	withMount(t, layerWritableDir(mounts, upper, q.upper, q.work), func(root string) {
+		require.NoError(t, os.Remove(filepath.Join(root, "lower")))
+		require.NoError(t, os.Remove(filepath.Join(root, "upper")))
+		require.NoError(t, os.RemoveAll(filepath.Join(root, "dir")))
+		require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "new"), []byte("new"), 0o644))
+		require.ErrorIs(t, writeFile(filepath.Join(root, "big"), 16<<20), syscall.ENOSPC)
+		require.NoError(t, os.Remove(filepath.Join(root, "big")))
+	})
+	require.NoError(t, q.release())
+
+	// the changes are visible through the original mount
+	withMount(t, mounts, func(root string) {
+		entries, err := os.ReadDir(root)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		require.Equal(t, []string{"dir", "new"}, names)
+		entries, err = os.ReadDir(filepath.Join(root, "dir"))
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func TestCleanupLoopQuotas(t *testing.T) {
+	requireLoopQuota(t)
+
+	root := t.TempDir()
+	c := NewController(root)
+	dir := t.TempDir()
+	mounts := []mount.Mount{{Type: "bind", Source: dir, Options: []string{"rbind"}}}
+	q, err := c.loopQuota(mounts, dir, 16<<20)
+	require.NoError(t, err)
+
+	// a new controller for the same root removes the loop-backed filesystem
+	// that was never released
+	NewController(root)
+	_, err = os.Stat(q.tmp)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package diskquota
+
+import (
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/pkg/errors"
+)
+
+type backingFS struct{}
+
+func (c *Controller) apply(_ []mount.Mount, _ int64, _ bool) ([]mount.Mount, quota, error) {
+	return nil, nil, errors.New("disk quota is not supported on this platform")
+}
+
+func cleanupLoopQuotas(string) {}
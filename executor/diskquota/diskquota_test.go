@@ -0,0 +1,70 @@
+package diskquota
+
+import (
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritableDir(t *testing.T) {
+	dir, err := writableDir([]mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=/l1:/l2", "upperdir=/upper", "workdir=/work"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "/upper", dir)
+
+	dir, err = writableDir([]mount.Mount{{
+		Type:    "bind",
+		Source:  "/snapshot",
+		Options: []string{"rbind"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "/snapshot", dir)
+
+	_, err = writableDir([]mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=/l1:/l2"},
+	}})
+	require.ErrorContains(t, err, "read-only overlay")
+
+	_, err = writableDir([]mount.Mount{{
+		Type:    "bind",
+		Source:  "/snapshot",
+		Options: []string{"rbind", "ro"},
+	}})
+	require.ErrorContains(t, err, "read-only bind")
+
+	_, err = writableDir([]mount.Mount{{Type: "tmpfs", Source: "tmpfs"}})
+	require.ErrorContains(t, err, "not supported for tmpfs")
+}
+
+func TestLayerWritableDir(t *testing.T) {
+	in := []mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=/l1", "upperdir=/upper", "workdir=/work", "index=off"},
+	}}
+	out := layerWritableDir(in, "/upper", "/loop/upper", "/loop/work")
+	require.Equal(t, []string{"lowerdir=/upper:/l1", "upperdir=/loop/upper", "workdir=/loop/work", "index=off"}, out[0].Options)
+	require.Equal(t, "upperdir=/upper", in[0].Options[1], "input must not be modified")
+
+	out = layerWritableDir([]mount.Mount{{Type: "bind", Source: "/snapshot", Options: []string{"rbind"}}}, "/snapshot", "/loop/upper", "/loop/work")
+	require.Equal(t, []mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=/snapshot", "upperdir=/loop/upper", "workdir=/loop/work"},
+	}}, out)
+}
+
+func TestExceededUsage(t *testing.T) {
+	require.False(t, exceededUsage(0, 1<<30))
+	require.False(t, exceededUsage(1<<29, 1<<30))
+	require.True(t, exceededUsage(1<<30-1<<19, 1<<30))
+	require.True(t, exceededUsage(1<<30, 1<<30))
+	require.False(t, exceededUsage(9<<20, 10<<20))
+	require.True(t, exceededUsage(10<<20-1<<10, 10<<20))
+}
@@ -5,12 +5,15 @@ import (
 	"io"
 	"net"
 	"syscall"
+	"time"
 
 	"github.com/containerd/containerd/v2/core/mount"
 	resourcestypes "github.com/moby/buildkit/executor/resources/types"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/network"
 	"github.com/moby/sys/user"
+	"github.com/pkg/errors"
 )
 
 type Meta struct {
@@ -27,13 +30,47 @@ type Meta struct {
 	CgroupParent   string
 	LinuxResources *pb.LinuxResources
 	NetMode        pb.NetMode
-	SecurityMode   pb.SecurityMode
-	ValidExitCodes []int
-	Proxy          *network.ProxyConfig
+	// NetworkName selects a named CNI network of the worker instead of the
+	// provider of NetMode.
+	NetworkName string
+	// NetworkFrom is the id of a running container whose network namespace
+	// is joined instead of creating a new one.
+	NetworkFrom  string
+	SecurityMode pb.SecurityMode
+	// SecurityProfile replaces the default seccomp and AppArmor profiles
+	// in sandbox mode if it is set.
+	SecurityProfile *securityprofiles.Profile
+	ValidExitCodes  []int
+	Proxy           *network.ProxyConfig
 
 	RemoveMountStubsRecursive bool
+
+	// Runtime selects a named OCI runtime of the worker instead of the
+	// default runtime.
+	Runtime string
+
+	// Checkpoint enables checkpointing the process on executors that
+	// implement Checkpointer.
+	Checkpoint *CheckpointConfig
 }
 
+// CheckpointConfig configures checkpointing of a process with CRIU.
+type CheckpointConfig struct {
+	// Timeout checkpoints and stops the process after it has run for the
+	// duration. If it is zero, the process is only checkpointed when the
+	// context of Run is cancelled.
+	Timeout time.Duration
+	// ImagePath is the directory the checkpoint is written to.
+	ImagePath string
+	// RestorePath is the directory of a previous checkpoint. If it is set,
+	// the process is restored from it instead of being started.
+	RestorePath string
+}
+
+// ErrCheckpointed is returned by Run if the process was checkpointed and
+// stopped.
+var ErrCheckpointed = errors.New("process was checkpointed")
+
 type MountableRef interface {
 	Mount() ([]mount.Mount, func() error, error)
 	IdentityMapping() *user.IdentityMapping
@@ -73,6 +110,18 @@ type Executor interface {
 	Exec(ctx context.Context, id string, process ProcessInfo) error
 }
 
+// ContainerDialer is implemented by executors that can open connections in
+// the network namespace of a running container.
+type ContainerDialer interface {
+	DialContainer(ctx context.Context, id, networkName, address string) (net.Conn, error)
+}
+
+// Checkpointer is implemented by executors that can checkpoint and restore
+// processes with Meta.Checkpoint.
+type Checkpointer interface {
+	CheckpointSupported() bool
+}
+
 type HostIP struct {
 	Host string
 	IP   net.IP
@@ -0,0 +1,143 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	resourcestypes "github.com/moby/buildkit/executor/resources/types"
+	"github.com/moby/buildkit/util/network"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+)
+
+// SharedNetworks keeps the network namespaces of running containers so that
+// other containers can join them with Meta.NetworkFrom. A namespace is closed
+// after the last container using it has closed it. The zero value is ready to
+// use.
+type SharedNetworks struct {
+	mu sync.Mutex
+	m  map[string]*sharedNamespace
+}
+
+// Add registers ns as the network namespace of container id after its spec
+// has been generated. pid returns the pid of the container and is used to
+// join namespaces that are created by the runtime instead of the network
+// provider. The returned namespace replaces ns and must be closed instead of
+// it.
+func (s *SharedNetworks) Add(id string, ns network.Namespace, pid func() (int, error)) network.Namespace {
+	sn := &sharedNamespace{ns: ns, pid: pid}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]*sharedNamespace)
+	}
+	s.m[id] = sn
+	return sn.ref()
+}
+
+// Remove unregisters the network namespace of container id. Containers that
+// have already joined the namespace keep using it.
+func (s *SharedNetworks) Remove(id string) {
+	s.mu.Lock()
+	delete(s.m, id)
+	s.mu.Unlock()
+}
+
+// Join returns a reference to the network namespace of the running
+// container id.
+func (s *SharedNetworks) Join(id string) (network.Namespace, error) {
+	return s.join(id)
+}
+
+func (s *SharedNetworks) join(id string) (*namespaceRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sn, ok := s.m[id]
+	if !ok {
+		return nil, errors.Errorf("network of container %s not found", id)
+	}
+	return sn.ref(), nil
+}
+
+// Dial connects to address from inside the network namespace of the running
+// container id.
+func (s *SharedNetworks) Dial(ctx context.Context, id, networkName, address string) (net.Conn, error) {
+	ref, err := s.join(id)
+	if err != nil {
+		return nil, err
+	}
+	defer ref.Close()
+	dialer, ok := ref.ns.(network.Dialer)
+	if !ok {
+		return nil, errors.Errorf("network of container %s does not support connections from the host", id)
+	}
+	return dialer.DialContext(ctx, networkName, address)
+}
+
+type sharedNamespace struct {
+	ns   network.Namespace
+	pid  func() (int, error)
+	mu   sync.Mutex
+	refs int
+}
+
+func (sn *sharedNamespace) ref() *namespaceRef {
+	sn.mu.Lock()
+	sn.refs++
+	sn.mu.Unlock()
+	return &namespaceRef{sharedNamespace: sn}
+}
+
+func (sn *sharedNamespace) release() error {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+	sn.refs--
+	if sn.refs > 0 {
+		return nil
+	}
+	return sn.ns.Close()
+}
+
+type namespaceRef struct {
+	*sharedNamespace
+	once sync.Once
+}
+
+func (r *namespaceRef) Set(s *specs.Spec) error {
+	if err := r.ns.Set(s); err != nil {
+		return err
+	}
+	if s.Linux == nil {
+		return nil
+	}
+	// Providers like the none provider leave it to the runtime to create a
+	// new namespace. Join the one of the container that owns it instead.
+	for i, n := range s.Linux.Namespaces {
+		if n.Type != specs.NetworkNamespace || n.Path != "" {
+			continue
+		}
+		if r.pid == nil {
+			return errors.New("network namespace can't be shared")
+		}
+		pid, err := r.pid()
+		if err != nil {
+			return errors.Wrap(err, "failed to get pid of network namespace owner")
+		}
+		s.Linux.Namespaces[i].Path = fmt.Sprintf("/proc/%d/ns/net", pid)
+	}
+	return nil
+}
+
+func (r *namespaceRef) Sample() (*resourcestypes.NetworkSample, error) {
+	return r.ns.Sample()
+}
+
+func (r *namespaceRef) Close() error {
+	var err error
+	r.once.Do(func() {
+		err = r.release()
+	})
+	return err
+}
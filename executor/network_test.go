@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"testing"
+
+	resourcestypes "github.com/moby/buildkit/executor/resources/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+)
+
+type testNamespace struct {
+	closed int
+}
+
+func (ns *testNamespace) Set(*specs.Spec) error {
+	return nil
+}
+
+func (ns *testNamespace) Close() error {
+	ns.closed++
+	return nil
+}
+
+func (ns *testNamespace) Sample() (*resourcestypes.NetworkSample, error) {
+	return nil, nil
+}
+
+func TestSharedNetworksClosedByLastUser(t *testing.T) {
+	var networks SharedNetworks
+	ns := &testNamespace{}
+	owner := networks.Add("owner", ns, func() (int, error) {
+		return 42, nil
+	})
+
+	joined, err := networks.Join("owner")
+	require.NoError(t, err)
+
+	spec := &specs.Spec{Linux: &specs.Linux{
+		Namespaces: []specs.LinuxNamespace{{Type: specs.NetworkNamespace}},
+	}}
+	require.NoError(t, joined.Set(spec))
+	require.Equal(t, "/proc/42/ns/net", spec.Linux.Namespaces[0].Path)
+
+	require.NoError(t, owner.Close())
+	require.NoError(t, owner.Close())
+	require.Equal(t, 0, ns.closed)
+
+	networks.Remove("owner")
+	_, err = networks.Join("owner")
+	require.ErrorContains(t, err, "network of container owner not found")
+
+	require.NoError(t, joined.Close())
+	require.Equal(t, 1, ns.closed)
+}
@@ -0,0 +1,130 @@
+package oci
+
+import (
+	"context"
+	"sync"
+
+	"github.com/moby/sys/user"
+	"github.com/pkg/errors"
+)
+
+// IDMapRangeSize is the number of subordinate IDs of a container that runs
+// with an ID range of an IDMapPool.
+const IDMapRangeSize = 65536
+
+// IDMapPool hands out distinct ranges of subordinate IDs so that containers
+// that run at the same time never share a host UID or GID.
+type IDMapPool struct {
+	size int
+	uids []int64
+	gids []int64
+	free chan int
+
+	mu sync.Mutex
+	// shared are the ranges of the running containers that other containers
+	// can share with Acquire.
+	shared map[string]*sharedRange
+}
+
+type sharedRange struct {
+	index int
+	refs  int
+}
+
+// NewIDMapPool splits the subordinate ID ranges of m into ranges of size IDs.
+// The IDs that don't fill a whole range are not used.
+func NewIDMapPool(m user.IdentityMapping, size int) (*IDMapPool, error) {
+	if size <= 0 {
+		return nil, errors.Errorf("invalid ID range size %d", size)
+	}
+	uids := splitIDMaps(m.UIDMaps, int64(size))
+	gids := splitIDMaps(m.GIDMaps, int64(size))
+	n := min(len(uids), len(gids))
+	if n == 0 {
+		return nil, errors.Errorf("subordinate ID ranges need to have at least %d IDs", size)
+	}
+	p := &IDMapPool{
+		size:   size,
+		uids:   uids[:n],
+		gids:   gids[:n],
+		free:   make(chan int, n),
+		shared: make(map[string]*sharedRange),
+	}
+	for i := range n {
+		p.free <- i
+	}
+	return p, nil
+}
+
+func splitIDMaps(maps []user.IDMap, size int64) []int64 {
+	var starts []int64
+	for _, m := range maps {
+		for off := int64(0); off+size <= m.Count; off += size {
+			starts = append(starts, m.ParentID+off)
+		}
+	}
+	return starts
+}
+
+// Len returns the number of ranges of the pool.
+func (p *IDMapPool) Len() int {
+	return len(p.uids)
+}
+
+// ErrIDMapPoolExhausted is returned by Get and Acquire if all ranges of the
+// pool are in use.
+var ErrIDMapPoolExhausted = errors.New("all subordinate ID ranges are in use")
+
+// Get returns a range that isn't used by any other caller until release is
+// called. Get doesn't wait for a range to be released: containers that run at
+// the same time may depend on each other, so waiting could block forever. If
+// all ranges are in use, an error wrapping ErrIDMapPoolExhausted is returned.
+func (p *IDMapPool) Get(ctx context.Context) (_ *user.IdentityMapping, release func(), _ error) {
+	return p.Acquire(ctx, "", "")
+}
+
+// Acquire returns the range of container id. If from is the id of a running
+// container that acquired a range, the range is shared with it, so that the
+// containers of one build step, like service containers and the containers
+// that join their network, only use one range. Otherwise Acquire behaves like
+// Get. The range is returned to the pool after all containers sharing it have
+// called release.
+func (p *IDMapPool) Acquire(ctx context.Context, id, from string) (_ *user.IdentityMapping, release func(), _ error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sr, ok := p.shared[from]
+	if from == "" || !ok {
+		select {
+		case i := <-p.free:
+			sr = &sharedRange{index: i}
+		default:
+			return nil, nil, errors.Wrapf(ErrIDMapPoolExhausted, "no free range of %d subordinate IDs for the container, all %d ranges are used by other build containers", p.size, p.Len())
+		}
+	}
+	sr.refs++
+	if id != "" {
+		p.shared[id] = sr
+	}
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if id != "" && p.shared[id] == sr {
+				delete(p.shared, id)
+			}
+			sr.refs--
+			if sr.refs == 0 {
+				p.free <- sr.index
+			}
+		})
+	}
+	i := sr.index
+	return &user.IdentityMapping{
+		UIDMaps: []user.IDMap{{ID: 0, ParentID: p.uids[i], Count: int64(p.size)}},
+		GIDMaps: []user.IDMap{{ID: 0, ParentID: p.gids[i], Count: int64(p.size)}},
+	}, release, nil
+}
@@ -0,0 +1,117 @@
+package oci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/sys/user"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDMapPool(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewIDMapPool(user.IdentityMapping{
+		UIDMaps: []user.IDMap{
+			{ID: 0, ParentID: 100000, Count: 2 * IDMapRangeSize},
+			{ID: 2 * IDMapRangeSize, ParentID: 500000, Count: IDMapRangeSize + 10},
+		},
+		GIDMaps: []user.IDMap{
+			{ID: 0, ParentID: 200000, Count: 5 * IDMapRangeSize},
+		},
+	}, IDMapRangeSize)
+	require.NoError(t, err)
+	require.Equal(t, 3, p.Len())
+
+	ctx := context.TODO()
+	hostUIDs := map[int]struct{}{}
+	var releases []func()
+	for range p.Len() {
+		m, release, err := p.Get(ctx)
+		require.NoError(t, err)
+		releases = append(releases, release)
+
+		uid, gid := m.RootPair()
+		require.NotContains(t, hostUIDs, uid)
+		hostUIDs[uid] = struct{}{}
+
+		cuid, cgid, err := m.ToContainer(uid+IDMapRangeSize-1, gid+IDMapRangeSize-1)
+		require.NoError(t, err)
+		require.Equal(t, IDMapRangeSize-1, cuid)
+		require.Equal(t, IDMapRangeSize-1, cgid)
+		_, _, err = m.ToContainer(uid+IDMapRangeSize, gid)
+		require.Error(t, err)
+	}
+	require.Equal(t, map[int]struct{}{100000: {}, 100000 + IDMapRangeSize: {}, 500000: {}}, hostUIDs)
+
+	// all ranges are in use
+	_, _, err = p.Get(ctx)
+	require.ErrorIs(t, err, ErrIDMapPoolExhausted)
+
+	releases[1]()
+	releases[1]()
+	m, release, err := p.Get(ctx)
+	require.NoError(t, err)
+	defer release()
+	uid, _ := m.RootPair()
+	require.Equal(t, 100000+IDMapRangeSize, uid)
+
+	_, _, err = p.Get(ctx)
+	require.ErrorIs(t, err, ErrIDMapPoolExhausted)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = p.Get(cctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIDMapPoolAcquireShared(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewIDMapPool(user.IdentityMapping{
+		UIDMaps: []user.IDMap{{ID: 0, ParentID: 100000, Count: IDMapRangeSize}},
+		GIDMaps: []user.IDMap{{ID: 0, ParentID: 100000, Count: IDMapRangeSize}},
+	}, IDMapRangeSize)
+	require.NoError(t, err)
+	require.Equal(t, 1, p.Len())
+
+	ctx := context.TODO()
+	// a service container holds the only range
+	svc, releaseSvc, err := p.Acquire(ctx, "svc", "")
+	require.NoError(t, err)
+
+	// a container of another build step fails instead of waiting
+	_, _, err = p.Acquire(ctx, "other", "")
+	require.ErrorIs(t, err, ErrIDMapPoolExhausted)
+
+	// the container that joins the network of the service shares its range
+	m, releaseMain, err := p.Acquire(ctx, "main", "svc")
+	require.NoError(t, err)
+	require.Equal(t, svc, m)
+
+	// the range is kept until all containers sharing it have released it
+	releaseSvc()
+	releaseSvc()
+	_, _, err = p.Get(ctx)
+	require.ErrorIs(t, err, ErrIDMapPoolExhausted)
+
+	// a container of the service that exited doesn't share the range
+	_, _, err = p.Acquire(ctx, "late", "svc")
+	require.ErrorIs(t, err, ErrIDMapPoolExhausted)
+
+	releaseMain()
+	m, release, err := p.Get(ctx)
+	require.NoError(t, err)
+	defer release()
+	require.Equal(t, svc, m)
+}
+
+func TestIDMapPoolTooSmall(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewIDMapPool(user.IdentityMapping{
+		UIDMaps: []user.IDMap{{ID: 0, ParentID: 100000, Count: IDMapRangeSize - 1}},
+		GIDMaps: []user.IDMap{{ID: 0, ParentID: 100000, Count: IDMapRangeSize}},
+	}, IDMapRangeSize)
+	require.ErrorContains(t, err, "at least 65536 IDs")
+}
@@ -111,7 +111,7 @@ func GenerateSpec(ctx context.Context, meta executor.Meta, mounts []executor.Mou
 
 	opts = append(opts, generateMountOpts(resolvConf, hostsFile)...)
 
-	if securityOpts, err := generateSecurityOpts(meta.SecurityMode, apparmorProfile, selinuxB); err == nil {
+	if securityOpts, err := generateSecurityOpts(meta.SecurityMode, meta.SecurityProfile, apparmorProfile, selinuxB); err == nil {
 		opts = append(opts, securityOpts...)
 	} else {
 		return nil, nil, err
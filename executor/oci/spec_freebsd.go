@@ -5,6 +5,7 @@ import (
 	"github.com/containerd/containerd/v2/pkg/oci"
 	"github.com/containerd/continuity/fs"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/sys/user"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -20,10 +21,13 @@ func generateMountOpts(_, _ string) []oci.SpecOpts {
 }
 
 // generateSecurityOpts may affect mounts, so must be called after generateMountOpts
-func generateSecurityOpts(mode pb.SecurityMode, _ string, _ bool) ([]oci.SpecOpts, error) {
+func generateSecurityOpts(mode pb.SecurityMode, profile *securityprofiles.Profile, _ string, _ bool) ([]oci.SpecOpts, error) {
 	if err := pb.ValidateSecurityMode(mode); err != nil {
 		return nil, err
 	}
+	if profile != nil {
+		return nil, errors.New("no support for security profiles on FreeBSD")
+	}
 	if mode == pb.SecurityMode_INSECURE {
 		return nil, errors.New("no support for running in insecure mode on FreeBSD")
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"github.com/containerd/continuity/fs"
 	"github.com/moby/buildkit/snapshot"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/util/entitlements/security"
@@ -52,10 +54,13 @@ func generateMountOpts(resolvConf, hostsFile string) []oci.SpecOpts {
 }
 
 // generateSecurityOpts may affect mounts, so must be called after generateMountOpts
-func generateSecurityOpts(mode pb.SecurityMode, apparmorProfile string, selinuxB bool) (opts []oci.SpecOpts, _ error) {
+func generateSecurityOpts(mode pb.SecurityMode, profile *securityprofiles.Profile, apparmorProfile string, selinuxB bool) (opts []oci.SpecOpts, _ error) {
 	if err := pb.ValidateSecurityMode(mode); err != nil {
 		return nil, err
 	}
+	if profile != nil && mode == pb.SecurityMode_INSECURE {
+		return nil, errors.Errorf("security profile %q can't be used in insecure mode", profile.Name)
+	}
 	if selinuxB && !selinux.GetEnabled() {
 		return nil, errors.New("selinux is not available")
 	}
@@ -75,9 +80,18 @@ func generateSecurityOpts(mode pb.SecurityMode, apparmorProfile string, selinuxB
 		}, nil
 	}
 
-	if cdseccomp.IsEnabled() {
+	switch {
+	case profile != nil && profile.Seccomp != "":
+		if !cdseccomp.IsEnabled() {
+			return nil, errors.Errorf("seccomp is not supported on this host, but the security profile %q sets a seccomp profile", profile.Name)
+		}
+		opts = append(opts, withSeccompProfile(profile.Seccomp))
+	case cdseccomp.IsEnabled():
 		opts = append(opts, withDefaultProfile())
 	}
+	if profile != nil && profile.AppArmor != "" {
+		apparmorProfile = profile.AppArmor
+	}
 	if apparmorProfile != "" {
 		// If AppArmor is not supported but a profile was specified, return an error
 		if !apparmor.HostSupports() {
@@ -188,9 +202,88 @@ func generateLinuxResourceOpts(res *pb.LinuxResources) ([]oci.SpecOpts, error) {
 	if res.CpusetMems != "" {
 		opts = append(opts, oci.WithCPUsMems(res.CpusetMems))
 	}
+	if res.PidsLimit != 0 {
+		opts = append(opts, oci.WithPidsLimit(res.PidsLimit))
+	}
+	blockIO, err := generateBlockIO(res)
+	if err != nil {
+		return nil, err
+	}
+	if blockIO != nil {
+		opts = append(opts, oci.WithBlockIO(blockIO))
+	}
 	return opts, nil
 }
 
+func generateBlockIO(res *pb.LinuxResources) (*specs.LinuxBlockIO, error) {
+	if res.BlkioWeight == 0 && len(res.BlkioDeviceReadBps) == 0 && len(res.BlkioDeviceWriteBps) == 0 &&
+		len(res.BlkioDeviceReadIOps) == 0 && len(res.BlkioDeviceWriteIOps) == 0 {
+		return nil, nil
+	}
+	var blockIO specs.LinuxBlockIO
+	if res.BlkioWeight != 0 {
+		if res.BlkioWeight < 10 || res.BlkioWeight > 1000 {
+			return nil, errors.Errorf("invalid block IO weight %d: must be in range 10-1000", res.BlkioWeight)
+		}
+		w := uint16(res.BlkioWeight)
+		blockIO.Weight = &w
+	}
+	var err error
+	if blockIO.ThrottleReadBpsDevice, err = throttleDevices(res.BlkioDeviceReadBps); err != nil {
+		return nil, err
+	}
+	if blockIO.ThrottleWriteBpsDevice, err = throttleDevices(res.BlkioDeviceWriteBps); err != nil {
+		return nil, err
+	}
+	if blockIO.ThrottleReadIOPSDevice, err = throttleDevices(res.BlkioDeviceReadIOps); err != nil {
+		return nil, err
+	}
+	if blockIO.ThrottleWriteIOPSDevice, err = throttleDevices(res.BlkioDeviceWriteIOps); err != nil {
+		return nil, err
+	}
+	return &blockIO, nil
+}
+
+// throttleDevices resolves the host paths of block devices to their device
+// numbers. The paths come from the client, so only block devices in /dev are
+// allowed and all invalid paths fail with the same error to not reveal the
+// files of the host.
+func throttleDevices(devs []*pb.LinuxThrottleDevice) ([]specs.LinuxThrottleDevice, error) {
+	var out []specs.LinuxThrottleDevice
+	for _, d := range devs {
+		st, ok := throttleDeviceStat(d.Path)
+		if !ok {
+			return nil, errors.Errorf("invalid throttle device %s: must be a block device in /dev", d.Path)
+		}
+		var td specs.LinuxThrottleDevice
+		td.Major = int64(unix.Major(uint64(st.Rdev)))
+		td.Minor = int64(unix.Minor(uint64(st.Rdev)))
+		td.Rate = d.Rate
+		out = append(out, td)
+	}
+	return out, nil
+}
+
+func throttleDeviceStat(p string) (unix.Stat_t, bool) {
+	var st unix.Stat_t
+	if !isDevPath(p) {
+		return st, false
+	}
+	// e.g. /dev/disk/by-id links point to the device nodes
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil || !isDevPath(resolved) {
+		return st, false
+	}
+	if err := unix.Stat(resolved, &st); err != nil {
+		return st, false
+	}
+	return st, st.Mode&unix.S_IFMT == unix.S_IFBLK
+}
+
+func isDevPath(p string) bool {
+	return filepath.IsAbs(p) && filepath.Clean(p) == p && strings.HasPrefix(p, "/dev/")
+}
+
 // genereateCDIOptions creates the OCI runtime spec options for injecting CDI
 // devices.
 func generateCDIOpts(manager *cdidevices.Manager, devs []*pb.CDIDevice) ([]oci.SpecOpts, error) {
@@ -229,6 +322,14 @@ func withDefaultProfile() oci.SpecOpts {
 	}
 }
 
+func withSeccompProfile(body string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+		var err error
+		s.Linux.Seccomp, err = seccomp.LoadProfile(body, s)
+		return err
+	}
+}
+
 func withROBind(src, dest string) oci.SpecOpts {
 	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
 		s.Mounts = append(s.Mounts, specs.Mount{
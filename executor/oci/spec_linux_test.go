@@ -0,0 +1,35 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateBlockIO(t *testing.T) {
+	blockIO, err := generateBlockIO(&pb.LinuxResources{Memory: 1024})
+	require.NoError(t, err)
+	require.Nil(t, blockIO)
+
+	blockIO, err = generateBlockIO(&pb.LinuxResources{BlkioWeight: 500})
+	require.NoError(t, err)
+	require.NotNil(t, blockIO.Weight)
+	require.Equal(t, uint16(500), *blockIO.Weight)
+
+	_, err = generateBlockIO(&pb.LinuxResources{BlkioWeight: 5})
+	require.ErrorContains(t, err, "must be in range 10-1000")
+
+	// paths outside of /dev, missing files and files that aren't block
+	// devices can't be told apart
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink("/etc/passwd", link))
+	for _, p := range []string{"/dev/null", "/etc/passwd", "/etc/missing", "/dev/../etc/passwd", "dev/sda", link} {
+		_, err = generateBlockIO(&pb.LinuxResources{
+			BlkioDeviceReadBps: []*pb.LinuxThrottleDevice{{Path: p, Rate: 1024}},
+		})
+		require.EqualError(t, err, "invalid throttle device "+p+": must be a block device in /dev")
+	}
+}
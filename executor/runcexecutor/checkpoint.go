@@ -0,0 +1,125 @@
+//go:build linux
+
+package runcexecutor
+
+import (
+	"context"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	runc "github.com/containerd/go-runc"
+	"github.com/moby/buildkit/executor"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/pkg/errors"
+)
+
+func (w *runcExecutor) CheckpointSupported() bool {
+	return w.checkpoint
+}
+
+// checkpointWatcher checkpoints a running container with CRIU when the
+// context of Run is cancelled or the process exceeds the timeout of the
+// checkpoint config.
+type checkpointWatcher struct {
+	stopCh       chan struct{}
+	done         chan struct{}
+	checkpointed atomic.Bool
+}
+
+// watchCheckpoint returns the context the container must be run with. The
+// context is not cancelled with ctx so that the process can be checkpointed
+// before it is stopped. If the checkpoint fails, the context is cancelled and
+// the process is killed like it would have been without checkpointing.
+func (w *runcExecutor) watchCheckpoint(ctx context.Context, rt *runc.Runc, id string, cfg *executor.CheckpointConfig) (context.Context, *checkpointWatcher) {
+	runCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	cw := &checkpointWatcher{
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(cw.done)
+		var timeout <-chan time.Time
+		if cfg.Timeout > 0 {
+			timer := time.NewTimer(cfg.Timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		for {
+			select {
+			case <-cw.stopCh:
+				cancel(errors.WithStack(context.Canceled))
+				return
+			case <-ctx.Done():
+			case <-timeout:
+			}
+			err := rt.Checkpoint(context.WithoutCancel(ctx), id, &runc.CheckpointOpts{
+				ImagePath: cfg.ImagePath,
+				FileLocks: true,
+			})
+			if err == nil {
+				bklog.G(ctx).Debugf("checkpointed %s to %s", id, cfg.ImagePath)
+				cw.checkpointed.Store(true)
+				<-cw.stopCh
+				cancel(errors.WithStack(context.Canceled))
+				return
+			}
+			bklog.G(ctx).Warnf("failed to checkpoint %s: %v", id, err)
+			if ctx.Err() == nil {
+				// keep running until the build is cancelled
+				timeout = nil
+				continue
+			}
+			cancel(context.Cause(ctx))
+			if err := rt.Kill(context.WithoutCancel(ctx), id, int(syscall.SIGKILL), nil); err != nil {
+				bklog.G(ctx).Debugf("failed to kill %s after checkpoint error: %v", id, err)
+			}
+			<-cw.stopCh
+			return
+		}
+	}()
+	return runCtx, cw
+}
+
+// stop must be called after the container has exited. It reports whether
+// the process was stopped by a checkpoint.
+func (cw *checkpointWatcher) stop() bool {
+	close(cw.stopCh)
+	<-cw.done
+	return cw.checkpointed.Load()
+}
+
+// restore resumes the process of container id from the checkpoint at
+// imagePath with the config of bundle.
+func (w *runcExecutor) restore(ctx context.Context, rt *runc.Runc, id, bundle, imagePath string, process executor.ProcessInfo, started func()) error {
+	killer := newRunProcKiller(rt, id)
+	return w.callWithIO(ctx, process, started, killer, func(ctx context.Context, startedCh chan<- int, io runc.IO, pidfile string) error {
+		// runc restore doesn't report its pid, use the pid of the restored
+		// process once it is running instead.
+		pctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			for {
+				if state, err := rt.State(pctx, id); err == nil && state.Status == "running" {
+					startedCh <- state.Pid
+					return
+				}
+				select {
+				case <-pctx.Done():
+					return
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+		}()
+		_, err := rt.Restore(ctx, id, bundle, &runc.RestoreOpts{
+			CheckpointOpts: runc.CheckpointOpts{
+				ImagePath: imagePath,
+				FileLocks: true,
+			},
+			IO:      io,
+			NoPivot: w.noPivot,
+		})
+		return err
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -21,6 +22,7 @@ import (
 
 	"github.com/containerd/containerd/v2/core/mount"
 	containerdoci "github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/containerd/containerd/v2/plugins/snapshots/overlay/overlayutils"
 	"github.com/containerd/continuity/fs"
 	runc "github.com/containerd/go-runc"
 	"github.com/moby/buildkit/executor"
@@ -60,29 +62,51 @@ type Opt struct {
 	ResourceMonitor *resources.Monitor
 	CDIManager      *cdidevices.Manager
 	ProxyProvider   network.ProxyProvider
+	// NamedNetworkProviders are the CNI networks that execs can select by
+	// name.
+	NamedNetworkProviders map[string]network.Provider
+	// Checkpoint enables checkpointing processes with CRIU. It requires
+	// the criu binary and is not supported in rootless mode.
+	Checkpoint bool
+	// Runtimes maps the names of the OCI runtimes that execs can select to
+	// their binaries.
+	Runtimes map[string]string
+	// UserRemap runs each container in a user namespace with a distinct
+	// range of subordinate IDs of the pool. The mounts of the container are
+	// idmapped to the range. It can't be used together with
+	// IdentityMapping.
+	UserRemap *oci.IDMapPool
 }
 
 var defaultCommandCandidates = []string{"buildkit-runc", "runc"}
 
 type runcExecutor struct {
-	runc             *runc.Runc
+	runc *runc.Runc
+	// runtimes are selected by Meta.Runtime.
+	runtimes         map[string]*runc.Runc
 	root             string
 	cgroupParent     string
 	rootless         bool
 	networkProviders map[pb.NetMode]network.Provider
-	proxyProvider    network.ProxyProvider
-	processMode      oci.ProcessMode
-	idmap            *user.IdentityMapping
-	noPivot          bool
-	dns              *oci.DNSConfig
-	oomScoreAdj      *int
-	running          map[string]chan error
-	mu               sync.Mutex
-	apparmorProfile  string
-	selinux          bool
-	tracingSocket    string
-	resmon           *resources.Monitor
-	cdiManager       *cdidevices.Manager
+	// namedNetworkProviders are selected by Meta.NetworkName.
+	namedNetworkProviders map[string]network.Provider
+	proxyProvider         network.ProxyProvider
+	processMode           oci.ProcessMode
+	idmap                 *user.IdentityMapping
+	noPivot               bool
+	dns                   *oci.DNSConfig
+	oomScoreAdj           *int
+	running               map[string]chan error
+	containerRuntimes     map[string]*runc.Runc
+	mu                    sync.Mutex
+	networks              executor.SharedNetworks
+	apparmorProfile       string
+	selinux               bool
+	tracingSocket         string
+	resmon                *resources.Monitor
+	cdiManager            *cdidevices.Manager
+	checkpoint            bool
+	userRemap             *oci.IDMapPool
 }
 
 func New(opt Opt, networkProviders map[pb.NetMode]network.Provider) (executor.Executor, error) {
@@ -122,37 +146,92 @@ func New(opt Opt, networkProviders map[pb.NetMode]network.Provider) (executor.Ex
 	os.RemoveAll(filepath.Join(root, "hosts"))
 	os.RemoveAll(filepath.Join(root, "resolv.conf"))
 
+	runtime := newRuntime(cmd, filepath.Join(root, "runc-log.json"))
+
+	runtimes := make(map[string]*runc.Runc, len(opt.Runtimes))
+	for name, binary := range opt.Runtimes {
+		if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+			return nil, errors.Errorf("invalid runtime name %q", name)
+		}
+		if _, err := exec.LookPath(binary); err != nil {
+			return nil, errors.Wrapf(err, "failed to find binary of runtime %q", name)
+		}
+		runtimes[name] = newRuntime(binary, filepath.Join(root, "runtime-"+name+"-log.json"))
+	}
+
+	checkpoint := opt.Checkpoint
+	if checkpoint {
+		if opt.Rootless {
+			bklog.L.Warn("checkpointing is not supported in rootless mode")
+			checkpoint = false
+		} else if _, err := exec.LookPath("criu"); err != nil {
+			bklog.L.Warnf("checkpointing is disabled: %v", err)
+			checkpoint = false
+		}
+	}
+
+	if opt.UserRemap != nil {
+		if opt.Rootless {
+			return nil, errors.New("user namespace remapping is not supported in rootless mode")
+		}
+		if opt.IdentityMapping != nil {
+			return nil, errors.New("user namespace remapping can't be used with an identity mapping")
+		}
+		if ok, err := overlayutils.SupportsIDMappedMounts(); !ok {
+			return nil, errors.Errorf("user namespace remapping requires idmapped mounts: %v", err)
+		}
+	}
+
+	w := &runcExecutor{
+		runc:                  runtime,
+		runtimes:              runtimes,
+		root:                  root,
+		cgroupParent:          opt.DefaultCgroupParent,
+		rootless:              opt.Rootless,
+		networkProviders:      networkProviders,
+		namedNetworkProviders: opt.NamedNetworkProviders,
+		proxyProvider:         opt.ProxyProvider,
+		processMode:           opt.ProcessMode,
+		idmap:                 opt.IdentityMapping,
+		noPivot:               opt.NoPivot,
+		dns:                   opt.DNS,
+		oomScoreAdj:           opt.OOMScoreAdj,
+		running:               make(map[string]chan error),
+		containerRuntimes:     make(map[string]*runc.Runc),
+		apparmorProfile:       opt.ApparmorProfile,
+		selinux:               opt.SELinux,
+		tracingSocket:         opt.TracingSocket,
+		resmon:                opt.ResourceMonitor,
+		cdiManager:            opt.CDIManager,
+		checkpoint:            checkpoint,
+		userRemap:             opt.UserRemap,
+	}
+	return w, nil
+}
+
+func newRuntime(cmd, log string) *runc.Runc {
 	runtime := &runc.Runc{
 		Command:   cmd,
-		Log:       filepath.Join(root, "runc-log.json"),
+		Log:       log,
 		LogFormat: runc.JSON,
 		Setpgid:   true,
 		// we don't execute runc with --rootless=(true|false) explicitly,
 		// so as to support non-runc runtimes
 	}
-
 	updateRuncFieldsForHostOS(runtime)
+	return runtime
+}
 
-	w := &runcExecutor{
-		runc:             runtime,
-		root:             root,
-		cgroupParent:     opt.DefaultCgroupParent,
-		rootless:         opt.Rootless,
-		networkProviders: networkProviders,
-		proxyProvider:    opt.ProxyProvider,
-		processMode:      opt.ProcessMode,
-		idmap:            opt.IdentityMapping,
-		noPivot:          opt.NoPivot,
-		dns:              opt.DNS,
-		oomScoreAdj:      opt.OOMScoreAdj,
-		running:          make(map[string]chan error),
-		apparmorProfile:  opt.ApparmorProfile,
-		selinux:          opt.SELinux,
-		tracingSocket:    opt.TracingSocket,
-		resmon:           opt.ResourceMonitor,
-		cdiManager:       opt.CDIManager,
+// runtime returns the named runtime or the default runtime if name is empty.
+func (w *runcExecutor) runtime(name string) (*runc.Runc, error) {
+	if name == "" {
+		return w.runc, nil
 	}
-	return w, nil
+	rt, ok := w.runtimes[name]
+	if !ok {
+		return nil, errors.Errorf("unknown runtime %q", name)
+	}
+	return rt, nil
 }
 
 func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount, mounts []executor.Mount, process executor.ProcessInfo, started chan<- struct{}) (rec resourcestypes.Recorder, err error) {
@@ -162,16 +241,23 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 	if err := executor.ValidContainerID(id); err != nil {
 		return nil, err
 	}
+	rt, err := w.runtime(process.Meta.Runtime)
+	if err != nil {
+		return nil, err
+	}
 
 	startedOnce := sync.Once{}
 	done := make(chan error, 1)
 	w.mu.Lock()
 	w.running[id] = done
+	w.containerRuntimes[id] = rt
 	w.mu.Unlock()
 	defer func() {
 		w.mu.Lock()
 		delete(w.running, id)
+		delete(w.containerRuntimes, id)
 		w.mu.Unlock()
+		w.networks.Remove(id)
 		done <- err
 		close(done)
 		if started != nil {
@@ -188,23 +274,36 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 
 	proxyConfig := meta.Proxy
 	var provider network.Provider
-	if proxyConfig == nil {
+	if meta.NetworkFrom != "" {
+		if proxyConfig != nil {
+			return nil, errors.New("joining the network of another container is not supported with proxy network")
+		}
+	} else if proxyConfig == nil {
 		var ok bool
-		provider, ok = w.networkProviders[meta.NetMode]
-		if !ok {
-			return nil, errors.Errorf("unknown network mode %s", meta.NetMode)
+		if meta.NetworkName != "" {
+			provider, ok = w.namedNetworkProviders[meta.NetworkName]
+			if !ok {
+				return nil, errors.Errorf("unknown network %q", meta.NetworkName)
+			}
+		} else {
+			provider, ok = w.networkProviders[meta.NetMode]
+			if !ok {
+				return nil, errors.Errorf("unknown network mode %s", meta.NetMode)
+			}
 		}
+	} else if meta.NetworkName != "" {
+		return nil, errors.Errorf("network %q is not supported with proxy network", meta.NetworkName)
 	} else if w.proxyProvider == nil {
 		return nil, errors.New("proxy network provider is not available")
 	} else {
-		proxyConfig = &network.ProxyConfig{
-			Policy:     proxyConfig.Policy,
-			Capture:    proxyConfig.Capture,
-			EgressMode: meta.NetMode,
-		}
+		cfg := *proxyConfig
+		cfg.EgressMode = meta.NetMode
+		proxyConfig = &cfg
 	}
 	var namespace network.Namespace
-	if proxyConfig != nil {
+	if meta.NetworkFrom != "" {
+		namespace, err = w.networks.Join(meta.NetworkFrom)
+	} else if proxyConfig != nil {
 		namespace, err = w.proxyProvider.NewProxy(ctx, proxyConfig)
 	} else {
 		namespace, err = provider.New(ctx, meta.Hostname, network.NamespaceOptions{})
@@ -243,6 +342,25 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 		defer clean()
 	}
 
+	idmap := w.idmap
+	var idmapFD *os.File
+	if w.userRemap != nil {
+		// containers that join the network of another container belong to
+		// the same build step and share its range
+		m, release, err := w.userRemap.Acquire(ctx, id, meta.NetworkFrom)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		idmap = m
+		idmapFD, err = usernsFD(idmap)
+		if err != nil {
+			return nil, err
+		}
+		defer idmapFD.Close()
+		mounts = idmapMounts(mounts, idmap, int(idmapFD.Fd()))
+	}
+
 	mountable, err := root.Src.Mount(ctx, false)
 	if err != nil {
 		return nil, err
@@ -264,8 +382,8 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 	defer os.RemoveAll(bundle)
 
 	var rootUID, rootGID int
-	if w.idmap != nil {
-		rootUID, rootGID = w.idmap.RootPair()
+	if idmap != nil {
+		rootUID, rootGID = idmap.RootPair()
 	}
 
 	rootFSPath := filepath.Join(bundle, "rootfs")
@@ -311,7 +429,7 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 		}
 	}
 
-	spec, cleanup, err := oci.GenerateSpec(ctx, meta, mounts, id, resolvConf, hostsFile, namespace, w.cgroupParent, w.processMode, w.idmap, w.apparmorProfile, w.selinux, w.tracingSocket, w.cdiManager, opts...)
+	spec, cleanup, err := oci.GenerateSpec(ctx, meta, mounts, id, resolvConf, hostsFile, namespace, w.cgroupParent, w.processMode, idmap, w.apparmorProfile, w.selinux, w.tracingSocket, w.cdiManager, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -332,6 +450,20 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 		}
 	}
 
+	if idmapFD != nil {
+		// the changes to the rootfs above are made without idmapping so
+		// that they are stored with the IDs of the container
+		if len(rootMount) != 1 {
+			return nil, errors.Errorf("idmapped rootfs of %d mounts is not supported", len(rootMount))
+		}
+		if err := mount.Unmount(rootFSPath, 0); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := idmapMount(rootMount[0], rootFSPath, int(idmapFD.Fd())); err != nil {
+			return nil, errors.Wrap(err, "failed to idmap rootfs")
+		}
+	}
+
 	spec.Process.Terminal = meta.Tty
 	spec.Process.OOMScoreAdj = w.oomScoreAdj
 	if w.rootless {
@@ -346,6 +478,16 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 
 	bklog.G(ctx).Debugf("> creating %s %v", id, meta.Args)
 
+	if meta.NetworkFrom == "" {
+		namespace = w.networks.Add(id, namespace, func() (int, error) {
+			state, err := rt.State(context.WithoutCancel(ctx), id)
+			if err != nil {
+				return 0, err
+			}
+			return state.Pid, nil
+		})
+	}
+
 	cgroupPath := spec.Linux.CgroupsPath
 	if cgroupPath != "" {
 		rec, err = w.resmon.RecordNamespace(cgroupPath, resources.RecordOpt{
@@ -357,7 +499,7 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 	}
 
 	trace.SpanFromContext(ctx).AddEvent("Container created")
-	err = w.run(ctx, id, bundle, process, func() {
+	startedFn := func() {
 		startedOnce.Do(func() {
 			trace.SpanFromContext(ctx).AddEvent("Container started")
 			if started != nil {
@@ -367,10 +509,22 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 				rec.Start()
 			}
 		})
-	}, true)
+	}
+	var checkpointed bool
+	if cfg := meta.Checkpoint; cfg != nil && w.checkpoint {
+		runCtx, cw := w.watchCheckpoint(ctx, rt, id, cfg)
+		if cfg.RestorePath != "" {
+			err = w.restore(runCtx, rt, id, bundle, cfg.RestorePath, process, startedFn)
+		} else {
+			err = w.run(runCtx, rt, id, bundle, process, startedFn, true)
+		}
+		checkpointed = cw.stop()
+	} else {
+		err = w.run(ctx, rt, id, bundle, process, startedFn, true)
+	}
 
 	releaseContainer := func(ctx context.Context) error {
-		err := w.runc.Delete(ctx, id, &runc.DeleteOpts{})
+		err := rt.Delete(ctx, id, &runc.DeleteOpts{})
 		err1 := namespace.Close()
 		if err == nil {
 			err = err1
@@ -379,6 +533,14 @@ func (w *runcExecutor) Run(ctx context.Context, id string, root executor.Mount,
 	}
 	doReleaseNetwork = false
 
+	if checkpointed {
+		if rec != nil {
+			rec.Close()
+		}
+		releaseContainer(context.TODO())
+		return nil, errors.WithStack(executor.ErrCheckpointed)
+	}
+
 	err = exitError(ctx, cgroupPath, err, process.Meta.ValidExitCodes)
 	if err != nil {
 		if rec != nil {
@@ -435,20 +597,26 @@ func exitError(ctx context.Context, cgroupPath string, err error, validExitCodes
 	}
 }
 
+func (w *runcExecutor) DialContainer(ctx context.Context, id, networkName, address string) (net.Conn, error) {
+	return w.networks.Dial(ctx, id, networkName, address)
+}
+
 func (w *runcExecutor) Exec(ctx context.Context, id string, process executor.ProcessInfo) (err error) {
 	// first verify the container is running, if we get an error assume the container
 	// is in the process of being created and check again every 100ms or until
 	// context is canceled.
 	var state *runc.Container
+	var rt *runc.Runc
 	for {
 		w.mu.Lock()
 		done, ok := w.running[id]
+		rt = w.containerRuntimes[id]
 		w.mu.Unlock()
 		if !ok {
 			return errors.Errorf("container %s not found", id)
 		}
 
-		state, _ = w.runc.State(ctx, id)
+		state, _ = rt.State(ctx, id)
 		if state != nil && state.Status == "running" {
 			break
 		}
@@ -502,7 +670,7 @@ func (w *runcExecutor) Exec(ctx context.Context, id string, process executor.Pro
 		spec.Process.Env = process.Meta.Env
 	}
 
-	err = w.exec(ctx, id, spec.Process, process, nil)
+	err = w.exec(ctx, rt, id, spec.Process, process, nil)
 	return exitError(ctx, "", err, process.Meta.ValidExitCodes)
 }
 
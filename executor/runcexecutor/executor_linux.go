@@ -26,14 +26,14 @@ func updateRuncFieldsForHostOS(runtime *runc.Runc) {
 	runtime.PdeathSignal = syscall.SIGKILL // this can still leak the process
 }
 
-func (w *runcExecutor) run(ctx context.Context, id, bundle string, process executor.ProcessInfo, started func(), keep bool) error {
-	killer := newRunProcKiller(w.runc, id)
+func (w *runcExecutor) run(ctx context.Context, rt *runc.Runc, id, bundle string, process executor.ProcessInfo, started func(), keep bool) error {
+	killer := newRunProcKiller(rt, id)
 	return w.callWithIO(ctx, process, started, killer, func(ctx context.Context, started chan<- int, io runc.IO, pidfile string) error {
 		extraArgs := []string{}
 		if keep {
 			extraArgs = append(extraArgs, "--keep")
 		}
-		_, err := w.runc.Run(ctx, id, bundle, &runc.CreateOpts{
+		_, err := rt.Run(ctx, id, bundle, &runc.CreateOpts{
 			NoPivot:   w.noPivot,
 			Started:   started,
 			IO:        io,
@@ -43,15 +43,15 @@ func (w *runcExecutor) run(ctx context.Context, id, bundle string, process execu
 	})
 }
 
-func (w *runcExecutor) exec(ctx context.Context, id string, specsProcess *specs.Process, process executor.ProcessInfo, started func()) error {
-	killer, err := newExecProcKiller(w.runc, id)
+func (w *runcExecutor) exec(ctx context.Context, rt *runc.Runc, id string, specsProcess *specs.Process, process executor.ProcessInfo, started func()) error {
+	killer, err := newExecProcKiller(rt, id)
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize process killer")
 	}
 	defer killer.Cleanup()
 
 	return w.callWithIO(ctx, process, started, killer, func(ctx context.Context, started chan<- int, io runc.IO, pidfile string) error {
-		return w.runc.Exec(ctx, id, *specsProcess, &runc.ExecOpts{
+		return rt.Exec(ctx, id, *specsProcess, &runc.ExecOpts{
 			Started: started,
 			IO:      io,
 			PidFile: pidfile,
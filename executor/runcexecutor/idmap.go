@@ -0,0 +1,207 @@
+//go:build linux
+
+package runcexecutor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/moby/buildkit/executor"
+	"github.com/moby/sys/user"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// usernsFD returns a user namespace with the mappings of idmap that mounts
+// can be idmapped to.
+func usernsFD(idmap *user.IdentityMapping) (*os.File, error) {
+	return mount.GetUsernsFD(formatIDMaps(idmap.UIDMaps), formatIDMaps(idmap.GIDMaps))
+}
+
+func formatIDMaps(maps []user.IDMap) string {
+	s := make([]string, len(maps))
+	for i, m := range maps {
+		s[i] = fmt.Sprintf("%d:%d:%d", m.ID, m.ParentID, m.Count)
+	}
+	return strings.Join(s, ",")
+}
+
+// idmapMount mounts m at target with the ownership of its files mapped to
+// the user namespace of usernsFD. Files created by the container through the
+// mount are stored with the IDs they have inside the container so that the
+// snapshots and the layers exported from them don't depend on the ID range
+// of the container.
+func idmapMount(m mount.Mount, target string, usernsFD int) error {
+	switch m.Type {
+	case "bind", "rbind":
+		var attrs uint64
+		if m.ReadOnly() {
+			attrs = unix.MOUNT_ATTR_RDONLY
+		}
+		return errors.WithStack(mount.IDMapMountWithAttrs(m.Source, target, usernsFD, attrs, 0))
+	case "overlay":
+		return idmapOverlay(m, target, usernsFD)
+	default:
+		return errors.Errorf("idmapped %s mounts are not supported", m.Type)
+	}
+}
+
+// idmapOverlay mounts an overlay with all its layers, including the upper
+// directory, accessed through an idmapped mount of their common parent
+// directory. The overlay itself can't be idmapped.
+func idmapOverlay(m mount.Mount, target string, usernsFD int) error {
+	var dirs []string
+	for _, o := range m.Options {
+		k, v, _ := strings.Cut(o, "=")
+		switch k {
+		case "lowerdir":
+			dirs = append(dirs, strings.Split(v, ":")...)
+		case "upperdir", "workdir":
+			dirs = append(dirs, v)
+		}
+	}
+	if len(dirs) == 0 {
+		return errors.Errorf("overlay mount %s has no layers", m.Source)
+	}
+	common := dirs[0]
+	for _, d := range dirs[1:] {
+		for !isSubdir(d, common) {
+			common = filepath.Dir(common)
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "buildkit-idmap")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp)
+	if err := mount.IDMapMount(common, tmp, usernsFD); err != nil {
+		return errors.WithStack(err)
+	}
+	// the overlay keeps references to its layers after the idmapped mount
+	// is detached
+	defer mount.Unmount(tmp, unix.MNT_DETACH)
+
+	opts := make([]string, 0, len(m.Options))
+	for _, o := range m.Options {
+		k, v, ok := strings.Cut(o, "=")
+		switch k {
+		case "lowerdir", "upperdir", "workdir":
+			layers := strings.Split(v, ":")
+			for i, l := range layers {
+				rel, err := filepath.Rel(common, l)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				layers[i] = filepath.Join(tmp, rel)
+			}
+			o = k + "=" + strings.Join(layers, ":")
+		default:
+			if !ok {
+				o = k
+			}
+		}
+		opts = append(opts, o)
+	}
+	return mount.All([]mount.Mount{{Type: m.Type, Source: m.Source, Options: opts}}, target)
+}
+
+func isSubdir(p, dir string) bool {
+	return dir == "/" || p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// idmapMounts returns mounts that are idmapped to the user namespace of
+// usernsFD when they are mounted. tmpfs mounts are created by the runtime
+// inside the user namespace of the container and are not changed.
+func idmapMounts(mounts []executor.Mount, idmap *user.IdentityMapping, usernsFD int) []executor.Mount {
+	out := make([]executor.Mount, len(mounts))
+	for i, m := range mounts {
+		m.Src = &idmappedMountable{src: m.Src, idmap: idmap, usernsFD: usernsFD}
+		out[i] = m
+	}
+	return out
+}
+
+type idmappedMountable struct {
+	src      executor.Mountable
+	idmap    *user.IdentityMapping
+	usernsFD int
+}
+
+func (m *idmappedMountable) Mount(ctx context.Context, readonly bool) (executor.MountableRef, error) {
+	ref, err := m.src.Mount(ctx, readonly)
+	if err != nil {
+		return nil, err
+	}
+	return &idmappedMountableRef{ref: ref, idmap: m.idmap, usernsFD: m.usernsFD}, nil
+}
+
+type idmappedMountableRef struct {
+	ref      executor.MountableRef
+	idmap    *user.IdentityMapping
+	usernsFD int
+}
+
+func (r *idmappedMountableRef) Mount() (_ []mount.Mount, _ func() error, err error) {
+	mounts, release, err := r.ref.Mount()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.ContainsFunc(mounts, func(m mount.Mount) bool { return m.Type != "tmpfs" }) {
+		return mounts, release, nil
+	}
+	defer func() {
+		if err != nil && release != nil {
+			release()
+		}
+	}()
+	if len(mounts) != 1 {
+		return nil, nil, errors.Errorf("idmapped mounts of %d mounts are not supported", len(mounts))
+	}
+	m := mounts[0]
+
+	dir, err := os.MkdirTemp("", "buildkit-idmap")
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	target := dir
+	if m.Type == "bind" || m.Type == "rbind" {
+		if fi, err := os.Stat(m.Source); err == nil && !fi.IsDir() {
+			target = filepath.Join(dir, "file")
+			if err := os.WriteFile(target, nil, 0o644); err != nil {
+				os.RemoveAll(dir)
+				return nil, nil, errors.WithStack(err)
+			}
+		}
+	}
+	if err := idmapMount(m, target, r.usernsFD); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, errors.Wrapf(err, "failed to idmap mount %s", m.Source)
+	}
+
+	bind := mount.Mount{Type: "bind", Source: target, Options: []string{"rbind"}}
+	if m.Type == "bind" || m.Type == "rbind" {
+		bind.Options = m.Options
+	} else if m.ReadOnly() {
+		bind.Options = append(bind.Options, "ro")
+	}
+	return []mount.Mount{bind}, func() error {
+		err := mount.Unmount(target, unix.MNT_DETACH)
+		os.RemoveAll(dir)
+		if release != nil {
+			if err1 := release(); err == nil {
+				err = err1
+			}
+		}
+		return err
+	}, nil
+}
+
+func (r *idmappedMountableRef) IdentityMapping() *user.IdentityMapping {
+	return r.idmap
+}
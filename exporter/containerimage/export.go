@@ -88,6 +88,12 @@ func (e *imageExporter) Resolve(ctx context.Context, id int, opt map[string]stri
 		return nil, err
 	}
 
+	var sign *bool
+	imageSign := ImageSign{
+		TokenSecret: defaultSignTokenSecret,
+	}
+	var signImage bool
+
 	for k, v := range opt {
 		switch exptypes.ImageExporterOptKey(k) {
 		case exptypes.OptKeyPush:
@@ -172,6 +178,30 @@ func (e *imageExporter) Resolve(ctx context.Context, id int, opt map[string]stri
 				return nil, errors.Wrapf(err, "non-bool value specified for %s", k)
 			}
 			i.nameCanonical = b
+		case exptypes.OptKeySign:
+			b := true
+			if v != "" {
+				if b, err = strconv.ParseBool(v); err != nil {
+					return nil, errors.Wrapf(err, "non-bool value specified for %s", k)
+				}
+			}
+			sign = &b
+		case exptypes.OptKeySignKeySecret:
+			imageSign.KeySecret = v
+			signImage = true
+		case exptypes.OptKeySignFulcioURL:
+			if err := parseSignURL(&imageSign.FulcioURL, k, v); err != nil {
+				return nil, err
+			}
+			signImage = true
+		case exptypes.OptKeySignRekorURL:
+			if err := parseSignURL(&imageSign.RekorURL, k, v); err != nil {
+				return nil, err
+			}
+			signImage = true
+		case exptypes.OptKeySignTokenSecret:
+			imageSign.TokenSecret = v
+			signImage = true
 		default:
 			if i.meta == nil {
 				i.meta = make(map[string][]byte)
@@ -179,6 +209,29 @@ func (e *imageExporter) Resolve(ctx context.Context, id int, opt map[string]stri
 			i.meta[k] = []byte(v)
 		}
 	}
+
+	if i.unpack {
+		// the image is unpacked from the result refs, not from the squashed
+		// or rebalanced layers
+		if i.opts.Squash != nil {
+			return nil, errors.Errorf("exporter option %q conflicts with \"unpack\"", exptypes.OptKeySquash)
+		}
+		if i.opts.Rebalance != nil {
+			return nil, errors.Errorf("exporter options %q, %q and %q conflict with \"unpack\"", exptypes.OptKeyLayerSizeMin, exptypes.OptKeyLayerSizeMax, exptypes.OptKeyLayerHotPaths)
+		}
+	}
+	if sign != nil && !*sign && signImage {
+		return nil, errors.Errorf("exporter signing options conflict with \"%s=false\"", exptypes.OptKeySign)
+	}
+	if (sign != nil && *sign) || signImage {
+		if err := imageSign.validate(); err != nil {
+			return nil, err
+		}
+		if !i.push {
+			return nil, errors.Errorf("exporter option %q requires \"push=true\"", exptypes.OptKeySign)
+		}
+		i.sign = &imageSign
+	}
 	return i, nil
 }
 
@@ -197,6 +250,7 @@ type imageExporterInstance struct {
 	nameCanonical        bool
 	danglingPrefix       string
 	danglingEmptyOnly    bool
+	sign                 *ImageSign
 	meta                 map[string][]byte
 }
 
@@ -238,6 +292,9 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 	if err := opts.Validate(); err != nil {
 		return nil, nil, nil, err
 	}
+	if e.sign != nil && !opts.OCITypesEnabled() {
+		return nil, nil, nil, errors.Errorf("exporter option %q conflicts with \"oci-mediatypes=false\"", exptypes.OptKeySign)
+	}
 
 	ctx, done, err := leaseutil.WithLease(ctx, e.opt.LeaseManager, leaseutil.MakeTemporary)
 	if err != nil {
@@ -395,10 +452,22 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 		return resp, nil, descref, nil
 	}
 
+	// The signature is pushed together with the image so that the image is
+	// never available without it.
+	var referrers []ocispecs.Descriptor
+	if e.sign != nil {
+		sigDesc, err := e.signImage(ctx, buildInfo.SessionID, *desc)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		referrers = append(referrers, *sigDesc)
+		resp[exptypes.ExporterImageSignatureDigestKey] = sigDesc.Digest.String()
+	}
+
 	// Create finalize callback for pushing
 	finalize := func(ctx context.Context) error {
 		for _, targetName := range namesToPush {
-			err := e.pushImage(ctx, src, buildInfo.SessionID, targetName, desc.Digest)
+			err := e.pushImage(ctx, src, buildInfo.SessionID, targetName, desc.Digest, referrers)
 			if err != nil {
 				var statusErr remoteserrors.ErrUnexpectedStatus
 				if errors.As(err, &statusErr) {
@@ -413,7 +482,7 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 	return resp, finalize, descref, nil
 }
 
-func (e *imageExporterInstance) pushImage(ctx context.Context, src *exporter.Source, sessionID string, targetName string, dgst digest.Digest) error {
+func (e *imageExporterInstance) pushImage(ctx context.Context, src *exporter.Source, sessionID string, targetName string, dgst digest.Digest, referrers []ocispecs.Descriptor) error {
 	var refs []cache.ImmutableRef
 	if src.Ref != nil {
 		refs = append(refs, src.Ref)
@@ -438,7 +507,7 @@ func (e *imageExporterInstance) pushImage(ctx context.Context, src *exporter.Sou
 			addAnnotations(annotations, desc)
 		}
 	}
-	return push.Push(ctx, e.opt.SessionManager, sessionID, mprovider, e.opt.ImageWriter.ContentStore(), dgst, targetName, e.insecure, e.opt.RegistryHosts, e.pushByDigest, annotations)
+	return push.Push(ctx, e.opt.SessionManager, sessionID, mprovider, e.opt.ImageWriter.ContentStore(), dgst, targetName, e.insecure, e.opt.RegistryHosts, e.pushByDigest, annotations, referrers...)
 }
 
 func (e *imageExporterInstance) unpackImage(ctx context.Context, img images.Image, src *exporter.Source, s session.Group) (err0 error) {
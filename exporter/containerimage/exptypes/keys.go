@@ -70,8 +70,8 @@ var (
 	OptKeySourceDateEpoch ImageExporterOptKey = ImageExporterOptKey(commonexptypes.OptKeySourceDateEpoch)
 
 	// Compression type for newly created and cached layers.
-	// estargz should be used with OptKeyOCITypes set to true.
-	// Value: string <uncompressed|gzip|estargz|zstd>
+	// estargz and zstd:chunked should be used with OptKeyOCITypes set to true.
+	// Value: string <uncompressed|gzip|estargz|zstd|zstd:chunked>
 	OptKeyLayerCompression ImageExporterOptKey = "compression"
 
 	// Force compression on all (including existing) layers.
@@ -86,4 +86,60 @@ var (
 	// Rewrite timestamps in layers to match SOURCE_DATE_EPOCH
 	// Value: bool <true|false>
 	OptKeyRewriteTimestamp ImageExporterOptKey = "rewrite-timestamp"
+
+	// Squash the layers of the image into one layer. Combined with
+	// OptKeySquashFrom and OptKeyLayerBoundaries, only the layers between
+	// the boundaries are squashed.
+	// Value: bool <true|false>
+	OptKeySquash ImageExporterOptKey = "squash"
+
+	// Keep the layers below the given layer and squash the rest into one
+	// layer. "base" keeps the layers of the base image.
+	// Value: string <base|int>
+	OptKeySquashFrom ImageExporterOptKey = "squash-from"
+
+	// Indexes of the layers that start a new layer of the exported image.
+	// The layers between two boundaries are squashed into one layer.
+	// Value: comma-separated list of ints
+	OptKeyLayerBoundaries ImageExporterOptKey = "layer-boundaries"
+
+	// Merge adjacent layers that are smaller than the given compressed size.
+	// The layers of the base image are not merged.
+	// Value: size, e.g. "50MB"
+	OptKeyLayerSizeMin ImageExporterOptKey = "layer-size-min"
+
+	// Split layers that are larger than the given compressed size.
+	// The layers of the base image are not split.
+	// Value: size, e.g. "200MB"
+	OptKeyLayerSizeMax ImageExporterOptKey = "layer-size-max"
+
+	// Move the given paths of a layer to separate layers on top of it so
+	// that the rest of the layer can be reused when only these paths change.
+	// Value: comma-separated list of paths
+	OptKeyLayerHotPaths ImageExporterOptKey = "layer-hot-paths"
+
+	// Sign the pushed image manifest or index. Implied by the other sign
+	// options.
+	// Value: bool <true|false>
+	OptKeySign ImageExporterOptKey = "sign"
+
+	// ID of the session secret containing the PEM encoded private key that
+	// signs the image.
+	// Value: string
+	OptKeySignKeySecret ImageExporterOptKey = "sign-key-secret"
+
+	// URL of the Fulcio instance that issues the certificate for keyless
+	// signing.
+	// Value: string
+	OptKeySignFulcioURL ImageExporterOptKey = "sign-fulcio-url"
+
+	// URL of the Rekor instance that records the signature in its
+	// transparency log. Required for keyless signing.
+	// Value: string
+	OptKeySignRekorURL ImageExporterOptKey = "sign-rekor-url"
+
+	// ID of the session secret containing the OIDC identity token for
+	// keyless signing. Defaults to "SIGSTORE_ID_TOKEN".
+	// Value: string
+	OptKeySignTokenSecret ImageExporterOptKey = "sign-oidc-token-secret"
 )
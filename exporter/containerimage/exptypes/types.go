@@ -8,14 +8,16 @@ import (
 )
 
 const (
-	ExporterConfigDigestKey      = "config.digest"
-	ExporterImageNameKey         = "image.name"
-	ExporterImageDigestKey       = "containerimage.digest"
-	ExporterImageConfigKey       = "containerimage.config"
-	ExporterImageConfigDigestKey = "containerimage.config.digest"
-	ExporterImageDescriptorKey   = "containerimage.descriptor"
-	ExporterImageBaseConfigKey   = "containerimage.base.config"
-	ExporterPlatformsKey         = "refs.platforms"
+	ExporterConfigDigestKey         = "config.digest"
+	ExporterImageNameKey            = "image.name"
+	ExporterImageDigestKey          = "containerimage.digest"
+	ExporterImageConfigKey          = "containerimage.config"
+	ExporterImageConfigDigestKey    = "containerimage.config.digest"
+	ExporterImageDescriptorKey      = "containerimage.descriptor"
+	ExporterImageBaseConfigKey      = "containerimage.base.config"
+	ExporterImageStageLayersKey     = "containerimage.stage.layers"
+	ExporterImageSignatureDigestKey = "containerimage.signature.digest"
+	ExporterPlatformsKey            = "refs.platforms"
 )
 
 // KnownRefMetadataKeys are the subset of exporter keys that can be suffixed by
@@ -23,6 +25,7 @@ const (
 var KnownRefMetadataKeys = []string{
 	ExporterImageConfigKey,
 	ExporterImageBaseConfigKey,
+	ExporterImageStageLayersKey,
 }
 
 type Platforms struct {
@@ -18,6 +18,8 @@ type ImageCommitOpts struct {
 	OCIArtifact *bool
 	Annotations AnnotationsGroup
 	Epoch       *epoch.Epoch
+	Squash      *LayerSquash
+	Rebalance   *LayerRebalance
 
 	ForceInlineAttestations bool // force inline attestations to be attached
 	RewriteTimestamp        bool // rewrite timestamps in layers to match the epoch
@@ -41,6 +43,12 @@ func (c *ImageCommitOpts) Load(ctx context.Context, opt map[string]string) (map[
 		return nil, err
 	}
 
+	var squash *bool
+	var layerSquash LayerSquash
+	var squashLayers bool
+	var rebalance LayerRebalance
+	var rebalanceLayers bool
+
 	for k, v := range opt {
 		var err error
 		switch exptypes.ImageExporterOptKey(k) {
@@ -60,6 +68,25 @@ func (c *ImageCommitOpts) Load(ctx context.Context, opt map[string]string) (map[
 			err = parseBool(&c.RefCfg.PreferNonDistributable, k, v)
 		case exptypes.OptKeyRewriteTimestamp:
 			err = parseBool(&c.RewriteTimestamp, k, v)
+		case exptypes.OptKeySquash:
+			var b bool
+			err = parseBool(&b, k, v)
+			squash = &b
+		case exptypes.OptKeySquashFrom:
+			err = parseSquashFrom(&layerSquash, k, v)
+			squashLayers = true
+		case exptypes.OptKeyLayerBoundaries:
+			err = parseLayerBoundaries(&layerSquash, k, v)
+			squashLayers = true
+		case exptypes.OptKeyLayerSizeMin:
+			err = parseLayerSize(&rebalance.MinSize, k, v)
+			rebalanceLayers = true
+		case exptypes.OptKeyLayerSizeMax:
+			err = parseLayerSize(&rebalance.MaxSize, k, v)
+			rebalanceLayers = true
+		case exptypes.OptKeyLayerHotPaths:
+			parseHotPaths(&rebalance, v)
+			rebalanceLayers = true
 		default:
 			rest[k] = v
 		}
@@ -69,6 +96,19 @@ func (c *ImageCommitOpts) Load(ctx context.Context, opt map[string]string) (map[
 		}
 	}
 
+	if squash != nil && !*squash && squashLayers {
+		return nil, errors.Errorf("exporter options %q and %q conflict with \"squash=false\"", exptypes.OptKeySquashFrom, exptypes.OptKeyLayerBoundaries)
+	}
+	if (squash != nil && *squash) || squashLayers {
+		c.Squash = &layerSquash
+	}
+	if rebalanceLayers {
+		if err := rebalance.validate(); err != nil {
+			return nil, err
+		}
+		c.Rebalance = &rebalance
+	}
+
 	if err := c.Validate(); err != nil {
 		return nil, err
 	}
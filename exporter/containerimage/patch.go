@@ -11,7 +11,7 @@ import (
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-func patchImageLayers(ctx context.Context, remote *solver.Remote, history []ocispecs.History, ref cache.ImmutableRef, opts *ImageCommitOpts, _ session.Group) (*solver.Remote, []ocispecs.History, error) {
-	remote, history = normalizeLayersAndHistory(ctx, remote, history, ref, opts.OCITypesEnabled())
+func patchImageLayers(ctx context.Context, remote *solver.Remote, history []ocispecs.History, _ cache.ImmutableRef, refMeta []refMetadata, opts *ImageCommitOpts, _ session.Group) (*solver.Remote, []ocispecs.History, error) {
+	remote, history = normalizeLayersAndHistory(ctx, remote, history, refMeta, opts.OCITypesEnabled())
 	return remote, history, nil
 }
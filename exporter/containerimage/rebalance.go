@@ -0,0 +1,309 @@
+package containerimage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/docker/go-units"
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/compression"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/converter"
+	"github.com/moby/buildkit/util/progress"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// LayerRebalance defines how the layers of the image are merged and split
+// before they are exported. The layers of the base image are never changed.
+type LayerRebalance struct {
+	// MinSize is the compressed size below which adjacent layers are merged.
+	MinSize int64
+	// MaxSize is the approximate compressed size above which layers are split.
+	MaxSize int64
+	// HotPaths are the paths that are moved to separate layers on top of
+	// the other paths of a layer, so that the rest of the layer can be
+	// reused when only these paths change.
+	HotPaths []string
+}
+
+func parseLayerSize(dest *int64, key, value string) error {
+	n, err := units.RAMInBytes(value)
+	if err != nil || n < 0 {
+		return errors.Errorf("invalid layer size for %s: %q", key, value)
+	}
+	*dest = n
+	return nil
+}
+
+func parseHotPaths(r *LayerRebalance, value string) {
+	for p := range strings.SplitSeq(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		r.HotPaths = append(r.HotPaths, path.Clean("/"+p))
+	}
+}
+
+func (r *LayerRebalance) validate() error {
+	if r.MaxSize > 0 && r.MinSize > r.MaxSize {
+		return errors.Errorf("minimum layer size %s is larger than maximum layer size %s", units.BytesSize(float64(r.MinSize)), units.BytesSize(float64(r.MaxSize)))
+	}
+	return nil
+}
+
+// hot returns true if p is one of the hot paths or is below one of them.
+func (r *LayerRebalance) hot(p string) bool {
+	for _, hp := range r.HotPaths {
+		if p == hp || hp == "/" || strings.HasPrefix(p, hp+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeBoundaries returns the indexes of the layers that start a new layer
+// when the layers with the given sizes are merged. The first fixed layers are
+// never merged.
+func (r *LayerRebalance) mergeBoundaries(sizes []int64, fixed int) []int {
+	var b []int
+	var size int64
+	for i, s := range sizes {
+		if i > 0 && (i <= fixed || size >= r.MinSize || (r.MaxSize > 0 && size+s > r.MaxSize)) {
+			b = append(b, i)
+			size = 0
+		}
+		size += s
+	}
+	return b
+}
+
+// historyRewrite rewrites the history of the image for layers that were
+// changed by the exporter.
+type historyRewrite func([]ocispecs.History) []ocispecs.History
+
+// chainHistoryRewrites returns a rewrite that applies fns in order, or nil if
+// there is nothing to rewrite.
+func chainHistoryRewrites(fns ...historyRewrite) historyRewrite {
+	fns = slices.DeleteFunc(fns, func(fn historyRewrite) bool { return fn == nil })
+	if len(fns) == 0 {
+		return nil
+	}
+	return func(history []ocispecs.History) []ocispecs.History {
+		for _, fn := range fns {
+			history = fn(history)
+		}
+		return history
+	}
+}
+
+type splitLayers struct {
+	// parts is the number of layers each layer was split into
+	parts []int
+	meta  []refMetadata
+}
+
+// layerMeta returns the metadata of the layers after they were split, with
+// the metadata of a split layer repeated for each of its parts.
+func (s *splitLayers) layerMeta() []refMetadata {
+	out := make([]refMetadata, 0, len(s.meta))
+	for i, md := range s.meta {
+		parts := 1
+		if i < len(s.parts) {
+			parts = s.parts[i]
+		}
+		for range parts {
+			out = append(out, md)
+		}
+	}
+	return out
+}
+
+// history returns the history with the items of the split layers repeated
+// for each of their parts.
+func (s *splitLayers) history(history []ocispecs.History) []ocispecs.History {
+	n := len(s.parts)
+	var layers int
+	for _, h := range history {
+		if !h.EmptyLayer {
+			layers++
+		}
+	}
+	if layers < len(s.meta) {
+		history = append([]ocispecs.History{}, history...)
+		for _, md := range s.meta[layers:] {
+			history = append(history, ocispecs.History{
+				Created:   md.createdAt,
+				CreatedBy: md.description,
+				Comment:   "buildkit.exporter.image.v0",
+			})
+		}
+	}
+
+	out := make([]ocispecs.History, 0, len(history))
+	var layer int
+	for _, h := range history {
+		if h.EmptyLayer || layer >= n || s.parts[layer] <= 1 {
+			if !h.EmptyLayer {
+				layer++
+			}
+			out = append(out, h)
+			continue
+		}
+		parts := s.parts[layer]
+		for i := range parts {
+			p := h
+			p.Comment = strings.TrimSpace(fmt.Sprintf("%s (part %d of %d)", h.Comment, i+1, parts))
+			out = append(out, p)
+		}
+		layer++
+	}
+	return out
+}
+
+// baseLayers returns the number of bottom layers of remote that are the
+// layers of the base image.
+func baseLayers(remote *solver.Remote, baseImg *dockerspec.DockerOCIImage) int {
+	if baseImg == nil {
+		return 0
+	}
+	var n int
+	for i, desc := range remote.Descriptors {
+		if i >= len(baseImg.RootFS.DiffIDs) || digest.Digest(desc.Annotations[labels.LabelUncompressed]) != baseImg.RootFS.DiffIDs[i] {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// rebalanceLayers merges and splits the layers of ref as defined by
+// opts.Rebalance. It returns the merged ref if layers were merged, which needs
+// to be released, and the remote and history rewrite of the rebalanced layers.
+func (ic *ImageWriter) rebalanceLayers(ctx context.Context, opts *ImageCommitOpts, ref cache.ImmutableRef, remote *solver.Remote, baseImg *dockerspec.DockerOCIImage, sg session.Group) (cache.ImmutableRef, *solver.Remote, historyRewrite, []refMetadata, error) {
+	rb := opts.Rebalance
+	fixed := baseLayers(remote, baseImg)
+	n := len(remote.Descriptors)
+
+	var merged cache.ImmutableRef
+	var rewrites []historyRewrite
+	sizes := make([]int64, n)
+	for i, desc := range remote.Descriptors {
+		sizes[i] = desc.Size
+	}
+	if boundaries := rb.mergeBoundaries(sizes, fixed); len(boundaries)+1 < n {
+		done := progress.OneOff(ctx, "merging small layers")
+		r, err := cache.SquashLayers(ctx, ref, boundaries, nil)
+		if err := done(err); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		merged = r
+		remotes, err := ic.exportLayers(ctx, opts.RefCfg, sg, merged)
+		if err != nil {
+			merged.Release(context.WithoutCancel(ctx))
+			return nil, nil, nil, nil, err
+		}
+		remote = &remotes[0]
+		rewrites = append(rewrites, (&squashedLayers{
+			starts: cache.SquashGroups(n, boundaries),
+			meta:   getRefMetadata(ref, n),
+		}).history)
+		ref = merged
+		n = len(remote.Descriptors)
+	}
+
+	if rb.MaxSize == 0 && len(rb.HotPaths) == 0 {
+		return merged, remote, chainHistoryRewrites(rewrites...), nil, nil
+	}
+
+	var top func(string) bool
+	if len(rb.HotPaths) > 0 {
+		top = rb.hot
+	}
+	cs := contentutil.NewStoreWithProvider(ic.opt.ContentStore, remote.Provider)
+	split := make([][]ocispecs.Descriptor, n)
+	eg, egCtx := errgroup.WithContext(ctx)
+	done := progress.OneOff(ctx, "splitting large layers")
+	for i, desc := range remote.Descriptors {
+		if i < fixed {
+			continue
+		}
+		if !(rb.MaxSize > 0 && desc.Size > rb.MaxSize) && !(top != nil && desc.Size >= rb.MinSize) {
+			continue
+		}
+		comp, ok := splitCompression(desc, opts.RefCfg.Compression)
+		if !ok {
+			continue
+		}
+		eg.Go(func() error {
+			descs, err := converter.Split(egCtx, cs, desc, comp, converter.SplitOpt{
+				MaxSize: rb.MaxSize,
+				Top:     top,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to split layer %d", i+1)
+			}
+			split[i] = descs
+			return nil
+		})
+	}
+	if err := done(eg.Wait()); err != nil {
+		if merged != nil {
+			merged.Release(context.WithoutCancel(ctx))
+		}
+		return nil, nil, nil, nil, err
+	}
+
+	sl := &splitLayers{
+		parts: make([]int, n),
+		meta:  getRefMetadata(ref, n),
+	}
+	descs := make([]ocispecs.Descriptor, 0, n)
+	var changed bool
+	for i, desc := range remote.Descriptors {
+		if len(split[i]) == 0 {
+			sl.parts[i] = 1
+			descs = append(descs, desc)
+			continue
+		}
+		sl.parts[i] = len(split[i])
+		descs = append(descs, split[i]...)
+		changed = true
+	}
+	if changed {
+		remote = &solver.Remote{
+			Provider:    cs,
+			Descriptors: descs,
+		}
+		rewrites = append(rewrites, sl.history)
+		return merged, remote, chainHistoryRewrites(rewrites...), sl.layerMeta(), nil
+	}
+	return merged, remote, chainHistoryRewrites(rewrites...), nil, nil
+}
+
+// splitCompression returns the compression for the split parts of desc.
+func splitCompression(desc ocispecs.Descriptor, comp compression.Config) (compression.Config, bool) {
+	t, err := compression.FromMediaType(desc.MediaType)
+	if err != nil {
+		return comp, false
+	}
+	switch t {
+	case compression.Uncompressed, compression.Gzip, compression.Zstd:
+	default:
+		return comp, false
+	}
+	if !compression.IsMediaType(comp.Type, desc.MediaType) {
+		comp = compression.New(t)
+	}
+	return comp, true
+}
@@ -0,0 +1,166 @@
+package containerimage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/moby/buildkit/util/sigstoresign"
+	"github.com/moby/buildkit/util/tracing"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	defaultSignTokenSecret = "SIGSTORE_ID_TOKEN"
+
+	// bundleContentAnnotation describes the content of a Sigstore bundle
+	// stored in an OCI artifact.
+	bundleContentAnnotation = "dev.sigstore.bundle.content"
+)
+
+// ImageSign defines how the pushed manifest or index is signed. The signature
+// is pushed as a Sigstore bundle in an OCI artifact that refers to the signed
+// manifest or index, before the image is tagged.
+type ImageSign struct {
+	// KeySecret is the ID of the secret containing the private key.
+	KeySecret string
+	// FulcioURL is the URL of the Fulcio instance that issues the signing
+	// certificate if no key is used.
+	FulcioURL string
+	// RekorURL is the URL of the Rekor instance that records the signature.
+	RekorURL string
+	// TokenSecret is the ID of the secret containing the OIDC identity token
+	// that is exchanged for the signing certificate.
+	TokenSecret string
+}
+
+func parseSignURL(dest *string, key, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid URL for %s: %q", key, value)
+	}
+	*dest = value
+	return nil
+}
+
+func (s *ImageSign) validate() error {
+	if s.KeySecret != "" && s.FulcioURL != "" {
+		return errors.Errorf("exporter option %q conflicts with %q", exptypes.OptKeySignKeySecret, exptypes.OptKeySignFulcioURL)
+	}
+	if s.KeySecret == "" && s.FulcioURL == "" {
+		return errors.Errorf("exporter option %q requires %q or %q", exptypes.OptKeySign, exptypes.OptKeySignKeySecret, exptypes.OptKeySignFulcioURL)
+	}
+	if s.FulcioURL != "" && s.RekorURL == "" {
+		return errors.Errorf("exporter option %q requires %q", exptypes.OptKeySignFulcioURL, exptypes.OptKeySignRekorURL)
+	}
+	return nil
+}
+
+// signImage signs the manifest or index target and writes the signature to
+// the content store as an OCI artifact that refers to target.
+func (e *imageExporterInstance) signImage(ctx context.Context, sessionID string, target ocispecs.Descriptor) (*ocispecs.Descriptor, error) {
+	if target.Digest.Algorithm() != digest.SHA256 {
+		return nil, errors.Errorf("unsupported digest algorithm for signing: %s", target.Digest.Algorithm())
+	}
+	cs := e.opt.ImageWriter.ContentStore()
+	payload, err := content.ReadBlob(ctx, cs, target)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := sigstoresign.Opt{
+		FulcioURL: e.sign.FulcioURL,
+		RekorURL:  e.sign.RekorURL,
+		Client:    tracing.DefaultClient,
+	}
+	secretID := e.sign.KeySecret
+	if secretID == "" {
+		secretID = e.sign.TokenSecret
+	}
+	err = e.opt.SessionManager.Any(ctx, session.NewGroup(sessionID), func(ctx context.Context, _ string, caller session.Caller) error {
+		dt, err := secrets.GetSecret(ctx, caller, secretID)
+		if err != nil {
+			return err
+		}
+		if e.sign.KeySecret == "" {
+			opt.IdentityToken = strings.TrimSpace(string(dt))
+			return nil
+		}
+		opt.Key, err = sigstoresign.ParsePrivateKey(dt)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve signing secret %s", secretID)
+	}
+
+	done := progress.OneOff(ctx, "signing "+target.Digest.String())
+	b, err := sigstoresign.Sign(ctx, payload, opt)
+	if err != nil {
+		return nil, done(err)
+	}
+	bundleJSON, err := protojson.Marshal(b)
+	if err != nil {
+		return nil, done(errors.Wrap(err, "failed to marshal signature bundle"))
+	}
+	bundleDesc := ocispecs.Descriptor{
+		MediaType: sigstoresign.BundleMediaType,
+		Digest:    digest.FromBytes(bundleJSON),
+		Size:      int64(len(bundleJSON)),
+		Annotations: map[string]string{
+			bundleContentAnnotation: "message-signature",
+		},
+	}
+	if err := content.WriteBlob(ctx, cs, bundleDesc.Digest.String(), bytes.NewReader(bundleJSON), bundleDesc); err != nil {
+		return nil, done(errors.Wrapf(err, "error writing signature blob %s", bundleDesc.Digest))
+	}
+
+	configDesc := ocispecs.DescriptorEmptyJSON
+	if err := content.WriteBlob(ctx, cs, configDesc.Digest.String(), bytes.NewReader(configDesc.Data), configDesc); err != nil {
+		return nil, done(errors.Wrap(err, "error writing config blob"))
+	}
+
+	mfst := ocispecs.Manifest{
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		ArtifactType: sigstoresign.BundleMediaType,
+		Config:       configDesc,
+		Layers:       []ocispecs.Descriptor{bundleDesc},
+		Subject: &ocispecs.Descriptor{
+			MediaType: target.MediaType,
+			Digest:    target.Digest,
+			Size:      target.Size,
+		},
+	}
+	mfstJSON, err := json.MarshalIndent(mfst, "", "  ")
+	if err != nil {
+		return nil, done(errors.Wrap(err, "failed to marshal manifest"))
+	}
+	mfstDesc := ocispecs.Descriptor{
+		MediaType:    ocispecs.MediaTypeImageManifest,
+		ArtifactType: sigstoresign.BundleMediaType,
+		Digest:       digest.FromBytes(mfstJSON),
+		Size:         int64(len(mfstJSON)),
+	}
+	labels := map[string]string{
+		"containerd.io/gc.ref.content.0": configDesc.Digest.String(),
+		"containerd.io/gc.ref.content.1": bundleDesc.Digest.String(),
+	}
+	if err := content.WriteBlob(ctx, cs, mfstDesc.Digest.String(), bytes.NewReader(mfstJSON), mfstDesc, content.WithLabels(labels)); err != nil {
+		return nil, done(errors.Wrapf(err, "error writing manifest blob %s", mfstDesc.Digest))
+	}
+	done(nil)
+	return &mfstDesc, nil
+}
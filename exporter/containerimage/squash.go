@@ -0,0 +1,178 @@
+package containerimage
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/exporter"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/util/progress"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// LayerSquash defines how the layers of the result are squashed before they
+// are exported.
+type LayerSquash struct {
+	// Boundaries are the indexes of the layers that start a new layer of the
+	// exported image.
+	Boundaries []int
+	// From is the number of bottom layers that are exported unchanged.
+	From int
+	// FromBase exports the layers of the base image unchanged.
+	FromBase bool
+	// FromStage exports the layers of the build stage with the name
+	// unchanged. The number of layers of the stages is set by the frontend
+	// in the exporter metadata.
+	FromStage string
+}
+
+func (s *LayerSquash) boundaries(baseLayers int, stageLayers map[string]int) ([]int, error) {
+	from := s.From
+	switch {
+	case s.FromBase:
+		from = baseLayers
+	case s.FromStage != "":
+		n, ok := stageLayers[s.FromStage]
+		if !ok {
+			return nil, errors.Errorf("stage %q is not a stage the result is built from", s.FromStage)
+		}
+		from = n
+	}
+	b := slices.Clone(s.Boundaries)
+	for i := 1; i <= from; i++ {
+		b = append(b, i)
+	}
+	return b, nil
+}
+
+func parseSquashFrom(s *LayerSquash, key, value string) error {
+	if value == "base" {
+		s.FromBase = true
+		return nil
+	}
+	// "stage:" selects stages with names that are also valid values
+	if name, ok := strings.CutPrefix(value, "stage:"); ok {
+		if name == "" {
+			return errors.Errorf("invalid value for %s: %q, expected stage name", key, value)
+		}
+		s.FromStage = name
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		s.FromStage = value
+		return nil
+	}
+	if n < 0 {
+		return errors.Errorf("invalid value for %s: %q, expected \"base\", stage name or number of layers", key, value)
+	}
+	s.From = n
+	return nil
+}
+
+func parseStageLayers(inp *exporter.Source, p *exptypes.Platform) (map[string]int, error) {
+	dt := exptypes.ParseKey(inp.Metadata, exptypes.ExporterImageStageLayersKey, p)
+	if len(dt) == 0 {
+		return nil, nil
+	}
+	var m map[string]int
+	if err := json.Unmarshal(dt, &m); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal stage layers")
+	}
+	return m, nil
+}
+
+func parseLayerBoundaries(s *LayerSquash, key, value string) error {
+	for v := range strings.SplitSeq(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return errors.Errorf("invalid layer index for %s: %q", key, v)
+		}
+		s.Boundaries = append(s.Boundaries, n)
+	}
+	return nil
+}
+
+type squashedLayers struct {
+	// starts are the indexes of the layers of the result that start a layer
+	// of the squashed ref
+	starts []int
+	// meta of the layers of the result
+	meta []refMetadata
+}
+
+// squashLayers returns a ref with the layers of ref squashed as defined by
+// opts. The returned ref needs to be released.
+func squashLayers(ctx context.Context, ref cache.ImmutableRef, opts *ImageCommitOpts, baseImg *dockerspec.DockerOCIImage, stageLayers map[string]int) (cache.ImmutableRef, *squashedLayers, error) {
+	var baseLayers int
+	if baseImg != nil {
+		baseLayers = len(baseImg.RootFS.DiffIDs)
+	}
+	boundaries, err := opts.Squash.boundaries(baseLayers, stageLayers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layers := ref.LayerChain()
+	n := len(layers)
+	layers.Release(context.TODO())
+
+	done := progress.OneOff(ctx, "squashing layers")
+	squashed, err := cache.SquashLayers(ctx, ref, boundaries, nil)
+	if err := done(err); err != nil {
+		return nil, nil, err
+	}
+	return squashed, &squashedLayers{
+		starts: cache.SquashGroups(n, boundaries),
+		meta:   getRefMetadata(ref, n),
+	}, nil
+}
+
+// history returns the history of the squashed layers. The history items of
+// the layers that were squashed into a layer above them are kept as empty
+// layers so that the history of all the squashed layers is preserved.
+func (s *squashedLayers) history(history []ocispecs.History) []ocispecs.History {
+	n := len(s.meta)
+	out := make([]ocispecs.History, 0, len(history))
+	var layers int
+	for _, h := range history {
+		if !h.EmptyLayer {
+			if layers >= n {
+				h.EmptyLayer = true
+			} else {
+				layers++
+			}
+		}
+		out = append(out, h)
+	}
+	// add the history items that are missing before the layers are squashed
+	for _, md := range s.meta[layers:] {
+		out = append(out, ocispecs.History{
+			Created:   md.createdAt,
+			CreatedBy: md.description,
+			Comment:   "buildkit.exporter.image.v0",
+		})
+	}
+
+	var layer int
+	for i, h := range out {
+		if h.EmptyLayer {
+			continue
+		}
+		if layer+1 < n && !slices.Contains(s.starts, layer+1) {
+			out[i].EmptyLayer = true
+		}
+		layer++
+	}
+	return out
+}
@@ -72,7 +72,7 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 	if err := opts.Validate(); err != nil {
 		return nil, err
 	}
-	if compatibilityVersion == compat.CompatibilityVersion013 && opts.RefCfg.Compression.Type == compression.Zstd {
+	if compatibilityVersion == compat.CompatibilityVersion013 && (opts.RefCfg.Compression.Type == compression.Zstd || opts.RefCfg.Compression.Type == compression.ZstdChunked) {
 		feature := fmt.Sprintf("%s exporter compression=%s", exporterType, opts.RefCfg.Compression.Type.String())
 		return nil, solvererrdefs.NewUnsupportedCompatibilityFeatureError(compatibilityVersion, feature)
 	}
@@ -147,14 +147,24 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 			expEpoch = opts.Epoch.Value
 		}
 		config := exptypes.ParseKey(inp.Metadata, exptypes.ExporterImageConfigKey, p)
-		baseImgConfig := exptypes.ParseKey(inp.Metadata, exptypes.ExporterImageBaseConfigKey, p)
-		var baseImg *dockerspec.DockerOCIImage
-		if len(baseImgConfig) > 0 {
-			var baseImgX dockerspec.DockerOCIImage
-			if err := json.Unmarshal(baseImgConfig, &baseImgX); err != nil {
-				return nil, errors.Wrap(err, "failed to unmarshal base image config")
+		baseImg, err := parseBaseImage(inp, p)
+		if err != nil {
+			return nil, err
+		}
+
+		var squash *squashedLayers
+		if opts.Squash != nil && ref != nil {
+			stageLayers, err := parseStageLayers(inp, p)
+			if err != nil {
+				return nil, err
 			}
-			baseImg = &baseImgX
+			var squashed cache.ImmutableRef
+			squashed, squash, err = squashLayers(ctx, ref, opts, baseImg, stageLayers)
+			if err != nil {
+				return nil, err
+			}
+			defer squashed.Release(context.WithoutCancel(ctx))
+			ref = squashed
 		}
 
 		remotes, err := ic.exportLayers(ctx, opts.RefCfg, session.NewGroup(sessionID), ref)
@@ -162,6 +172,24 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 			return nil, err
 		}
 		remote := &remotes[0]
+		var rewriteHistory historyRewrite
+		if squash != nil {
+			rewriteHistory = squash.history
+		}
+		var layerMeta []refMetadata
+		if opts.Rebalance != nil && ref != nil {
+			merged, rebalanced, rewrite, meta, err := ic.rebalanceLayers(ctx, opts, ref, remote, baseImg, session.NewGroup(sessionID))
+			if err != nil {
+				return nil, err
+			}
+			if merged != nil {
+				defer merged.Release(context.WithoutCancel(ctx))
+				ref = merged
+			}
+			remote = rebalanced
+			rewriteHistory = chainHistoryRewrites(rewriteHistory, rewrite)
+			layerMeta = meta
+		}
 		if opts.RewriteTimestamp {
 			remote, err = ic.rewriteRemoteWithEpoch(ctx, opts, remote, baseImg, expEpoch)
 			if err != nil {
@@ -188,8 +216,11 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 				}
 			}
 		}
+		if inlineCacheEntry != nil && rewriteHistory != nil {
+			return nil, errors.New("inline cache can't be exported with squashed or rebalanced layers")
+		}
 
-		mfstDesc, configDesc, err := ic.commitDistributionManifest(ctx, opts, ref, config, remote, annotations, inlineCacheEntry, expEpoch, session.NewGroup(sessionID), baseImg)
+		mfstDesc, configDesc, err := ic.commitDistributionManifest(ctx, opts, ref, config, remote, annotations, inlineCacheEntry, expEpoch, session.NewGroup(sessionID), baseImg, rewriteHistory, layerMeta)
 		if err != nil {
 			return nil, err
 		}
@@ -210,11 +241,29 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 
 	refs := make([]cache.ImmutableRef, 0, len(inp.Refs))
 	remotesMap := make(map[string]int, len(inp.Refs))
+	squashes := make(map[string]*squashedLayers)
 	for _, p := range ps.Platforms {
 		r, ok := inp.FindRef(p.ID)
 		if !ok {
 			return nil, errors.Errorf("failed to find ref for ID %s", p.ID)
 		}
+		if opts.Squash != nil && r != nil {
+			baseImg, err := parseBaseImage(inp, &p)
+			if err != nil {
+				return nil, err
+			}
+			stageLayers, err := parseStageLayers(inp, &p)
+			if err != nil {
+				return nil, err
+			}
+			squashed, squash, err := squashLayers(ctx, r, opts, baseImg, stageLayers)
+			if err != nil {
+				return nil, err
+			}
+			defer squashed.Release(context.WithoutCancel(ctx))
+			r = squashed
+			squashes[p.ID] = squash
+		}
 		remotesMap[p.ID] = len(refs)
 		refs = append(refs, r)
 	}
@@ -249,19 +298,11 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 	var attestationManifests []ocispecs.Descriptor
 
 	for i, p := range ps.Platforms {
-		r, ok := inp.FindRef(p.ID)
-		if !ok {
-			return nil, errors.Errorf("failed to find ref for ID %s", p.ID)
-		}
+		r := refs[remotesMap[p.ID]]
 		config := exptypes.ParseKey(inp.Metadata, exptypes.ExporterImageConfigKey, &p)
-		baseImgConfig := exptypes.ParseKey(inp.Metadata, exptypes.ExporterImageBaseConfigKey, &p)
-		var baseImg *dockerspec.DockerOCIImage
-		if len(baseImgConfig) > 0 {
-			var baseImgX dockerspec.DockerOCIImage
-			if err := json.Unmarshal(baseImgConfig, &baseImgX); err != nil {
-				return nil, errors.Wrap(err, "failed to unmarshal base image config")
-			}
-			baseImg = &baseImgX
+		baseImg, err := parseBaseImage(inp, &p)
+		if err != nil {
+			return nil, err
 		}
 
 		var expEpoch *time.Time
@@ -280,6 +321,24 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 				Provider: ic.opt.ContentStore,
 			}
 		}
+		var rewriteHistory historyRewrite
+		if squash := squashes[p.ID]; squash != nil {
+			rewriteHistory = squash.history
+		}
+		var layerMeta []refMetadata
+		if opts.Rebalance != nil && r != nil {
+			merged, rebalanced, rewrite, meta, err := ic.rebalanceLayers(ctx, opts, r, remote, baseImg, session.NewGroup(sessionID))
+			if err != nil {
+				return nil, err
+			}
+			if merged != nil {
+				defer merged.Release(context.WithoutCancel(ctx))
+				r = merged
+			}
+			remote = rebalanced
+			rewriteHistory = chainHistoryRewrites(rewriteHistory, rewrite)
+			layerMeta = meta
+		}
 		if opts.RewriteTimestamp {
 			remote, err = ic.rewriteRemoteWithEpoch(ctx, opts, remote, baseImg, expEpoch)
 			if err != nil {
@@ -291,8 +350,11 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 		if inlineCacheResult != nil {
 			inlineCacheEntry, _ = inlineCacheResult.FindRef(p.ID)
 		}
+		if inlineCacheEntry != nil && rewriteHistory != nil {
+			return nil, errors.New("inline cache can't be exported with squashed or rebalanced layers")
+		}
 
-		desc, _, err := ic.commitDistributionManifest(ctx, opts, r, config, remote, opts.Annotations.Platform(&p.Platform), inlineCacheEntry, expEpoch, session.NewGroup(sessionID), baseImg)
+		desc, _, err := ic.commitDistributionManifest(ctx, opts, r, config, remote, opts.Annotations.Platform(&p.Platform), inlineCacheEntry, expEpoch, session.NewGroup(sessionID), baseImg, rewriteHistory, layerMeta)
 		if err != nil {
 			return nil, err
 		}
@@ -486,7 +548,7 @@ func (ic *ImageWriter) rewriteRemoteWithEpoch(ctx context.Context, opts *ImageCo
 	}, nil
 }
 
-func (ic *ImageWriter) commitDistributionManifest(ctx context.Context, opts *ImageCommitOpts, ref cache.ImmutableRef, config []byte, remote *solver.Remote, annotations *Annotations, inlineCache *exptypes.InlineCacheEntry, epoch *time.Time, sg session.Group, baseImg *dockerspec.DockerOCIImage) (*ocispecs.Descriptor, *ocispecs.Descriptor, error) {
+func (ic *ImageWriter) commitDistributionManifest(ctx context.Context, opts *ImageCommitOpts, ref cache.ImmutableRef, config []byte, remote *solver.Remote, annotations *Annotations, inlineCache *exptypes.InlineCacheEntry, epoch *time.Time, sg session.Group, baseImg *dockerspec.DockerOCIImage, rewriteHistory historyRewrite, layerMeta []refMetadata) (*ocispecs.Descriptor, *ocispecs.Descriptor, error) {
 	if len(config) == 0 {
 		var err error
 		config, err = defaultImageConfig()
@@ -499,8 +561,15 @@ func (ic *ImageWriter) commitDistributionManifest(ctx context.Context, opts *Ima
 	if err != nil {
 		return nil, nil, err
 	}
+	if rewriteHistory != nil {
+		history = rewriteHistory(history)
+	}
 
-	remote, history, err = patchImageLayers(ctx, remote, history, ref, opts, sg)
+	// layerMeta is set if the layers of remote are not the layers of ref
+	if layerMeta == nil {
+		layerMeta = getRefMetadata(ref, len(remote.Descriptors))
+	}
+	remote, history, err = patchImageLayers(ctx, remote, history, ref, layerMeta, opts, sg)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -735,6 +804,18 @@ func attestationsConfig(layers []ocispecs.Descriptor) ([]byte, error) {
 	return dt, errors.Wrap(err, "failed to create attestations image config")
 }
 
+func parseBaseImage(inp *exporter.Source, p *exptypes.Platform) (*dockerspec.DockerOCIImage, error) {
+	dt := exptypes.ParseKey(inp.Metadata, exptypes.ExporterImageBaseConfigKey, p)
+	if len(dt) == 0 {
+		return nil, nil
+	}
+	var img dockerspec.DockerOCIImage
+	if err := json.Unmarshal(dt, &img); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal base image config")
+	}
+	return &img, nil
+}
+
 func parseHistoryFromConfig(dt []byte) ([]ocispecs.History, error) {
 	var config struct {
 		History []ocispecs.History
@@ -840,9 +921,7 @@ func patchImageConfig(dt []byte, descs []ocispecs.Descriptor, history []ocispecs
 	return dt, errors.Wrap(err, "failed to marshal config after patch")
 }
 
-func normalizeLayersAndHistory(ctx context.Context, remote *solver.Remote, history []ocispecs.History, ref cache.ImmutableRef, oci bool) (*solver.Remote, []ocispecs.History) {
-	refMeta := getRefMetadata(ref, len(remote.Descriptors))
-
+func normalizeLayersAndHistory(ctx context.Context, remote *solver.Remote, history []ocispecs.History, refMeta []refMetadata, oci bool) (*solver.Remote, []ocispecs.History) {
 	var historyLayers int
 	for _, h := range history {
 		if !h.EmptyLayer {
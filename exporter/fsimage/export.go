@@ -0,0 +1,387 @@
+package fsimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/v2/pkg/archive"
+	"github.com/docker/go-units"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/exporter"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/exporter/local"
+	"github.com/moby/buildkit/exporter/util/epoch"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/filesync"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	keyFormat      = "format"
+	keyCompression = "compression"
+	keySize        = "size"
+)
+
+type Opt struct {
+	SessionManager *session.Manager
+	// Root is the directory where the images are created before they are
+	// sent to the client.
+	Root string
+}
+
+type fsImageExporter struct {
+	opt Opt
+}
+
+func New(opt Opt) (exporter.Exporter, error) {
+	return &fsImageExporter{opt: opt}, nil
+}
+
+func (e *fsImageExporter) Resolve(ctx context.Context, id int, opt map[string]string) (exporter.ExporterInstance, error) {
+	i := &fsImageExporterInstance{
+		fsImageExporter: e,
+		id:              id,
+		attrs:           opt,
+	}
+
+	var err error
+	i.epoch, opt, err = epoch.ParseExporterAttrs(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range opt {
+		switch k {
+		case keyFormat:
+			i.opts.Format, err = parseFormat(v)
+			if err != nil {
+				return nil, err
+			}
+		case keyCompression:
+			i.opts.Compression = v
+		case keySize:
+			i.opts.Size, err = units.RAMInBytes(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid value for %s: %s", keySize, v)
+			}
+		}
+	}
+	if i.opts.Format == "" {
+		i.opts.Format = FormatEROFS
+	}
+	if err := i.opts.validate(); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+type fsImageExporterInstance struct {
+	*fsImageExporter
+	id    int
+	attrs map[string]string
+
+	opts  imageOpts
+	epoch *epoch.Epoch
+}
+
+func (e *fsImageExporterInstance) ID() int {
+	return e.id
+}
+
+func (e *fsImageExporterInstance) Name() string {
+	return "exporting to " + string(e.opts.Format) + " image"
+}
+
+func (e *fsImageExporterInstance) Type() string {
+	return client.ExporterFSImage
+}
+
+func (e *fsImageExporterInstance) Attrs() map[string]string {
+	return e.attrs
+}
+
+func (e *fsImageExporterInstance) Config() *exporter.Config {
+	return exporter.NewConfig()
+}
+
+func (e *fsImageExporterInstance) Export(ctx context.Context, inp *exporter.Source, buildInfo exporter.ExportBuildInfo) (map[string]string, exporter.FinalizeFunc, exporter.DescriptorReference, error) {
+	p, err := exptypes.ParsePlatforms(inp.Metadata)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(p.Platforms) > 1 {
+		return nil, nil, nil, errors.Errorf("unable to export multiple platforms to %s image", e.opts.Format)
+	}
+
+	ref := inp.Ref
+	var platform *exptypes.Platform
+	if len(p.Platforms) == 1 {
+		platform = &p.Platforms[0]
+		r, ok := inp.FindRef(platform.ID)
+		if !ok {
+			return nil, nil, nil, errors.Errorf("failed to find ref for ID %s", platform.ID)
+		}
+		ref = r
+	}
+
+	opts := e.opts
+	if e.epoch != nil {
+		opts.Epoch = e.epoch.Value
+	} else {
+		var err error
+		opts.Epoch, err = epoch.ParseSource(inp, platform)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	now := time.Now().Truncate(time.Second)
+	outputFS, cleanup, err := local.CreateFS(ctx, buildInfo.SessionID, "", ref, nil, now, false, local.CreateFSOpts{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	var entries int64
+	var paths []string
+	outputFS, err = fsutil.NewFilterFS(outputFS, &fsutil.FilterOpt{
+		Map: func(p string, st *fstypes.Stat) fsutil.MapResult {
+			entries++
+			if opts.Format == FormatExt4 && opts.Epoch != nil {
+				paths = append(paths, p)
+			}
+			if opts.Epoch != nil && st.ModTime > opts.Epoch.UnixNano() {
+				st.ModTime = opts.Epoch.UnixNano()
+			}
+			return fsutil.MapResultKeep
+		},
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := os.MkdirAll(e.opt.Root, 0700); err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	dir, err := os.MkdirTemp(e.opt.Root, "export-")
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	img := filepath.Join(dir, "image")
+
+	report := progress.OneOff(ctx, "creating "+string(opts.Format)+" image")
+	if opts.Format == FormatExt4 {
+		err = writeExt4(ctx, outputFS, opts, dir, img, &entries, &paths)
+	} else {
+		err = runMkfs(ctx, mkfsArgs(opts, img), nil, func(w io.Writer) error {
+			return fsutil.WriteTar(ctx, outputFS, w)
+		})
+	}
+	if err := report(err); err != nil {
+		return nil, nil, nil, err
+	}
+
+	f, err := os.Open(img)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	timeoutCtx, cancel := context.WithCancelCause(ctx)
+	defer func() { cancel(errors.WithStack(context.Canceled)) }()
+	timeoutCtx, cancelTimeout := context.WithTimeoutCause(timeoutCtx, 5*time.Second, errors.WithStack(context.DeadlineExceeded))
+	defer cancelTimeout()
+
+	caller, err := e.opt.SessionManager.Get(timeoutCtx, buildInfo.SessionID, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	w, err := filesync.CopyFileWriter(ctx, nil, e.id, caller)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	report = progress.OneOff(ctx, "sending image")
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return nil, nil, nil, report(errors.WithStack(err))
+	}
+	return nil, nil, nil, report(w.Close())
+}
+
+// ext4ReadsTar reports if the installed mkfs.ext4 can read tar files.
+var ext4ReadsTar = sync.OnceValue(func() bool {
+	out, _ := exec.Command("mkfs.ext4", "-V").CombinedOutput()
+	return mke2fsReadsTar(string(out))
+})
+
+// writeExt4 writes the contents of fs to a tar file in dir first because the
+// size of the ext4 image needs to be known before it is created. Older
+// versions of mkfs.ext4 get the contents extracted to a directory instead.
+// paths are the files of fs that get their change times set to the epoch of
+// reproducible images.
+func writeExt4(ctx context.Context, fs fsutil.FS, opts imageOpts, dir, img string, entries *int64, paths *[]string) error {
+	var src string
+	var tarSize int64
+	if ext4ReadsTar() {
+		src = filepath.Join(dir, "rootfs.tar")
+		f, err := os.Create(src)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := fsutil.WriteTar(ctx, fs, f); err != nil {
+			f.Close()
+			return err
+		}
+		fi, err := f.Stat()
+		f.Close()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		tarSize = fi.Size()
+	} else {
+		src = filepath.Join(dir, "rootfs")
+		var err error
+		tarSize, err = extractTar(ctx, fs, src)
+		if err != nil {
+			return err
+		}
+	}
+
+	size := opts.Size
+	if size == 0 {
+		size = ext4Size(tarSize, *entries)
+	}
+	if err := runMkfs(ctx, mkfsExt4Args(opts, src, img, size, ext4Inodes(*entries)), mkfsExt4Env(opts), nil); err != nil {
+		return err
+	}
+	if opts.Epoch == nil {
+		return nil
+	}
+	script, err := debugfsCtimeScript(*paths, *opts.Epoch)
+	if err != nil {
+		return err
+	}
+	scriptFile := filepath.Join(dir, "debugfs")
+	if err := os.WriteFile(scriptFile, []byte(script), 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	return runDebugfs(ctx, scriptFile, img)
+}
+
+// extractTar extracts the tar stream of fs to dir and returns the size of
+// the stream.
+func extractTar(ctx context.Context, fs fsutil.FS, dir string) (int64, error) {
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	// the mode of the root directory of the image is copied from dir
+	if err := os.Chmod(dir, 0755); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	pr, pw := io.Pipe()
+	r := &countingReader{r: pr}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		err := fsutil.WriteTar(ctx, fs, pw)
+		pw.CloseWithError(err)
+		return err
+	})
+	eg.Go(func() error {
+		// whiteout files are regular files of the image
+		_, err := archive.Apply(ctx, dir, r, archive.WithConvertWhiteout(func(*tar.Header, string) (bool, error) {
+			return true, nil
+		}))
+		if err == nil {
+			_, err = io.Copy(io.Discard, r)
+		}
+		pr.CloseWithError(err)
+		return errors.WithStack(err)
+	})
+	if err := eg.Wait(); err != nil {
+		return 0, err
+	}
+	return r.n, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// runDebugfs runs the debugfs commands of script on the image img.
+func runDebugfs(ctx context.Context, script, img string) error {
+	if _, err := exec.LookPath("debugfs"); err != nil {
+		return errors.Wrap(err, "debugfs is required for exporting reproducible ext4 images")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "debugfs", "-w", "-f", script, img)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "debugfs failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	// debugfs prints its version and the errors of the commands to stderr
+	// without failing
+	for l := range strings.Lines(stderr.String()) {
+		if !strings.HasPrefix(l, "debugfs ") {
+			return errors.Errorf("debugfs failed: %s", strings.TrimSpace(l))
+		}
+	}
+	return nil
+}
+
+// runMkfs runs args with the tar stream written by writeTar on its stdin.
+func runMkfs(ctx context.Context, args []string, env []string, writeTar func(io.Writer) error) error {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return errors.Wrapf(err, "%s is required for exporting filesystem images", args[0])
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // args are built by mkfsArgs
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stdout = &stderr
+	cmd.Stderr = &stderr
+
+	var stdin io.WriteCloser
+	if writeTar != nil {
+		var err error
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	var tarErr error
+	if writeTar != nil {
+		// writes fail instead of blocking if the command exits early
+		tarErr = writeTar(stdin)
+		stdin.Close()
+	}
+	if err := cmd.Wait(); err != nil {
+		return errors.Wrapf(err, "%s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return tarErr
+}
@@ -0,0 +1,81 @@
+package fsimage
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+	"golang.org/x/sys/unix"
+)
+
+func TestWriteExt4Reproducible(t *testing.T) {
+	for _, bin := range []string{"mkfs.ext4", "debugfs"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("test requires %s", bin)
+		}
+	}
+	t.Parallel()
+
+	epoch := time.Unix(1700000000, 0)
+	opts := imageOpts{Format: FormatExt4, Size: 16 << 20, Epoch: &epoch}
+
+	write := func(files []string) []byte {
+		src := t.TempDir()
+		for _, f := range files {
+			p := filepath.Join(src, f)
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+			require.NoError(t, os.WriteFile(p, []byte(f), 0644))
+		}
+		require.NoError(t, os.Symlink("etc/foo", filepath.Join(src, "baz")))
+		err := unix.Lsetxattr(filepath.Join(src, "etc", "foo"), "user.foo", []byte("xattr-value"), 0)
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skip("test requires user xattrs")
+		}
+		require.NoError(t, err)
+
+		fs, err := fsutil.NewFS(src)
+		require.NoError(t, err)
+		var entries int64
+		var paths []string
+		fs, err = fsutil.NewFilterFS(fs, &fsutil.FilterOpt{
+			Map: func(p string, st *fstypes.Stat) fsutil.MapResult {
+				entries++
+				paths = append(paths, p)
+				st.ModTime = epoch.UnixNano()
+				return fsutil.MapResultKeep
+			},
+		})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		img := filepath.Join(dir, "image")
+		require.NoError(t, writeExt4(t.Context(), fs, opts, dir, img, &entries, &paths))
+		dt, err := os.ReadFile(img)
+		require.NoError(t, err)
+		return dt
+	}
+
+	// the files are created in a different order, at different times
+	img1 := write([]string{"etc/foo", "etc/bar", ".wh.qux"})
+	time.Sleep(1100 * time.Millisecond)
+	img2 := write([]string{".wh.qux", "etc/bar", "etc/foo"})
+	require.True(t, bytes.Equal(img1, img2), "ext4 images are not reproducible")
+
+	img := filepath.Join(t.TempDir(), "image")
+	require.NoError(t, os.WriteFile(img, img1, 0600))
+	out, err := exec.Command("debugfs", "-R", "ea_get /etc/foo user.foo", img).Output()
+	require.NoError(t, err)
+	require.Contains(t, string(out), "xattr-value")
+
+	// whiteout files are kept as regular files
+	out, err = exec.Command("debugfs", "-R", "cat /.wh.qux", img).Output()
+	require.NoError(t, err)
+	require.Equal(t, ".wh.qux", string(out))
+}
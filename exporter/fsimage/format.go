@@ -0,0 +1,169 @@
+package fsimage
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Format string
+
+const (
+	FormatEROFS    Format = "erofs"
+	FormatSquashFS Format = "squashfs"
+	FormatExt4     Format = "ext4"
+)
+
+func parseFormat(v string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(v))); f {
+	case "":
+		return FormatEROFS, nil
+	case FormatEROFS, FormatSquashFS, FormatExt4:
+		return f, nil
+	default:
+		return "", errors.Errorf("unsupported filesystem image format %q", v)
+	}
+}
+
+// nullUUID is used as the filesystem UUID of reproducible images
+const nullUUID = "00000000-0000-0000-0000-000000000000"
+
+// ext4HashSeed is the seed of the directory hashes of reproducible ext4
+// images. mke2fs replaces a null seed with a random one.
+const ext4HashSeed = "6b75696c-642d-6b69-742d-657874340000"
+
+// ext4 block size used to estimate the default image size
+const ext4BlockSize = 4096
+
+// ext4 images get at least this much space on top of the estimated size of
+// their contents for the journal and other metadata
+const ext4MinFree = 64 << 20
+
+type imageOpts struct {
+	Format      Format
+	Compression string
+	// Size of ext4 images in bytes, 0 to derive it from the contents
+	Size int64
+	// Epoch is the time used for the filesystem creation time. Timestamps of
+	// files newer than Epoch are clamped to it. nil means the current time
+	// and unchanged timestamps.
+	Epoch *time.Time
+}
+
+func (o imageOpts) validate() error {
+	if o.Format == FormatExt4 && o.Compression != "" {
+		return errors.Errorf("compression is not supported for %s images", o.Format)
+	}
+	if o.Format != FormatExt4 && o.Size != 0 {
+		return errors.Errorf("size is not supported for %s images", o.Format)
+	}
+	if o.Size < 0 {
+		return errors.Errorf("invalid image size %d", o.Size)
+	}
+	return nil
+}
+
+// mkfsArgs returns the command that writes a filesystem image of the tar
+// stream on its stdin to out.
+func mkfsArgs(o imageOpts, out string) []string {
+	switch o.Format {
+	case FormatSquashFS:
+		args := []string{"sqfstar", "-quiet"}
+		if o.Compression != "" {
+			args = append(args, "-comp", o.Compression)
+		}
+		if o.Epoch != nil {
+			ts := strconv.FormatInt(o.Epoch.Unix(), 10)
+			args = append(args, "-mkfs-time", ts, "-root-time", ts)
+		}
+		return append(args, out)
+	default:
+		args := []string{"mkfs.erofs", "--quiet", "--tar=f"}
+		if o.Compression != "" {
+			args = append(args, "-z"+o.Compression)
+		}
+		if o.Epoch != nil {
+			// --mkfs-time only applies the timestamp to the superblock and
+			// keeps the timestamps of the files
+			args = append(args, fmt.Sprintf("-T%d", o.Epoch.Unix()), "--mkfs-time", "-U", nullUUID)
+		}
+		return append(args, out)
+	}
+}
+
+// mkfsExt4Args returns the command that writes an ext4 image with the
+// contents of src to out. src is a tar file or, for mkfs.ext4 versions that
+// can't read tar files, a directory. Unlike mkfs.erofs and sqfstar,
+// mkfs.ext4 can't read the tar stream from stdin.
+func mkfsExt4Args(o imageOpts, src, out string, size int64, inodes int64) []string {
+	args := []string{"mkfs.ext4", "-q", "-F", "-t", "ext4", "-b", strconv.Itoa(ext4BlockSize), "-N", strconv.FormatInt(inodes, 10), "-d", src}
+	opts := []string{"root_owner=0:0"}
+	if o.Epoch != nil {
+		args = append(args, "-U", nullUUID)
+		opts = append(opts, "hash_seed="+ext4HashSeed)
+	}
+	args = append(args, "-E", strings.Join(opts, ","))
+	return append(args, out, strconv.FormatInt(size/1024, 10)+"k")
+}
+
+// mkfsExt4Env returns the environment of mkfs.ext4. e2fsprogs uses
+// E2FSPROGS_FAKE_TIME for all the timestamps it sets itself.
+func mkfsExt4Env(o imageOpts) []string {
+	if o.Epoch == nil {
+		return nil
+	}
+	return []string{"E2FSPROGS_FAKE_TIME=" + strconv.FormatInt(o.Epoch.Unix(), 10)}
+}
+
+var mke2fsVersionRe = regexp.MustCompile(`mke2fs (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// mke2fsReadsTar reports if the mke2fs version printed by mkfs.ext4 -V can
+// read tar files with -d. Tar support was added in e2fsprogs 1.47.1.
+func mke2fsReadsTar(version string) bool {
+	m := mke2fsVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return false
+	}
+	v := make([]int, 3)
+	for i, s := range m[1:] {
+		if s != "" {
+			v[i], _ = strconv.Atoi(s)
+		}
+	}
+	return slices.Compare(v, []int{1, 47, 1}) >= 0
+}
+
+// debugfsCtimeScript returns the debugfs commands that set the inode change
+// time of the root directory and of paths to ts. mke2fs keeps the change
+// times of the files it copies from a directory, and these are the times
+// the files were extracted.
+func debugfsCtimeScript(paths []string, ts time.Time) (string, error) {
+	var sb strings.Builder
+	v := strconv.FormatInt(ts.Unix(), 10)
+	for _, p := range append([]string{""}, paths...) {
+		if strings.ContainsAny(p, "\n\x00") {
+			return "", errors.Errorf("unsupported file name %q in ext4 image", p)
+		}
+		// debugfs unquotes "" to "
+		fmt.Fprintf(&sb, "sif \"/%s\" ctime @%s\n", strings.ReplaceAll(p, `"`, `""`), v)
+	}
+	return sb.String(), nil
+}
+
+// ext4Size estimates the size of an ext4 image for a tar file of tarSize
+// bytes with the given number of entries, rounded up to MiB.
+func ext4Size(tarSize, entries int64) int64 {
+	size := (tarSize+entries*ext4BlockSize)*11/10 + ext4MinFree
+	return (size + 1<<20 - 1) &^ (1<<20 - 1)
+}
+
+// ext4Inodes returns the number of inodes of an ext4 image for the given
+// number of entries.
+func ext4Inodes(entries int64) int64 {
+	return entries + entries/10 + 1024
+}
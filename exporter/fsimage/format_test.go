@@ -0,0 +1,133 @@
+package fsimage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for in, exp := range map[string]Format{
+		"":         FormatEROFS,
+		"erofs":    FormatEROFS,
+		"SquashFS": FormatSquashFS,
+		" ext4 ":   FormatExt4,
+	} {
+		f, err := parseFormat(in)
+		require.NoError(t, err)
+		require.Equal(t, exp, f)
+	}
+	_, err := parseFormat("vfat")
+	require.ErrorContains(t, err, "unsupported filesystem image format")
+}
+
+func TestImageOptsValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, imageOpts{Format: FormatEROFS, Compression: "lz4hc"}.validate())
+	require.NoError(t, imageOpts{Format: FormatExt4, Size: 1 << 30}.validate())
+	require.Error(t, imageOpts{Format: FormatExt4, Compression: "gzip"}.validate())
+	require.Error(t, imageOpts{Format: FormatSquashFS, Size: 1 << 30}.validate())
+	require.Error(t, imageOpts{Format: FormatExt4, Size: -1}.validate())
+}
+
+func TestMkfsArgs(t *testing.T) {
+	t.Parallel()
+
+	tm := time.Unix(1700000000, 0)
+
+	require.Equal(t, []string{"mkfs.erofs", "--quiet", "--tar=f", "out"},
+		mkfsArgs(imageOpts{Format: FormatEROFS}, "out"))
+	require.Equal(t, []string{"mkfs.erofs", "--quiet", "--tar=f", "-zlz4hc", "-T1700000000", "--mkfs-time", "-U", nullUUID, "out"},
+		mkfsArgs(imageOpts{Format: FormatEROFS, Compression: "lz4hc", Epoch: &tm}, "out"))
+
+	require.Equal(t, []string{"sqfstar", "-quiet", "out"},
+		mkfsArgs(imageOpts{Format: FormatSquashFS}, "out"))
+	require.Equal(t, []string{"sqfstar", "-quiet", "-comp", "zstd", "-mkfs-time", "1700000000", "-root-time", "1700000000", "out"},
+		mkfsArgs(imageOpts{Format: FormatSquashFS, Compression: "zstd", Epoch: &tm}, "out"))
+
+	require.Equal(t, []string{"mkfs.ext4", "-q", "-F", "-t", "ext4", "-b", "4096", "-N", "2000", "-d", "src.tar", "-E", "root_owner=0:0", "out", "1048576k"},
+		mkfsExt4Args(imageOpts{Format: FormatExt4}, "src.tar", "out", 1<<30, 2000))
+	require.Equal(t, []string{"mkfs.ext4", "-q", "-F", "-t", "ext4", "-b", "4096", "-N", "2000", "-d", "src.tar", "-U", nullUUID, "-E", "root_owner=0:0,hash_seed=" + ext4HashSeed, "out", "1048576k"},
+		mkfsExt4Args(imageOpts{Format: FormatExt4, Epoch: &tm}, "src.tar", "out", 1<<30, 2000))
+
+	require.Nil(t, mkfsExt4Env(imageOpts{Format: FormatExt4}))
+	require.Equal(t, []string{"E2FSPROGS_FAKE_TIME=1700000000"}, mkfsExt4Env(imageOpts{Format: FormatExt4, Epoch: &tm}))
+}
+
+func TestMke2fsReadsTar(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, mke2fsReadsTar("mke2fs 1.47.0 (5-Feb-2023)\n\tUsing EXT2FS Library version 1.47.0\n"))
+	require.False(t, mke2fsReadsTar("mke2fs 1.46.5 (30-Dec-2021)"))
+	require.True(t, mke2fsReadsTar("mke2fs 1.47.1 (20-May-2024)"))
+	require.True(t, mke2fsReadsTar("mke2fs 1.48 (1-Jan-2026)"))
+	require.False(t, mke2fsReadsTar("sh: mkfs.ext4: not found"))
+}
+
+func TestDebugfsCtimeScript(t *testing.T) {
+	t.Parallel()
+
+	script, err := debugfsCtimeScript([]string{"etc", "etc/a b", `x"y`}, time.Unix(1700000000, 5))
+	require.NoError(t, err)
+	require.Equal(t, `sif "/" ctime @1700000000
+sif "/etc" ctime @1700000000
+sif "/etc/a b" ctime @1700000000
+sif "/x""y" ctime @1700000000
+`, script)
+
+	_, err = debugfsCtimeScript([]string{"a\nb"}, time.Unix(1700000000, 0))
+	require.ErrorContains(t, err, "unsupported file name")
+}
+
+func TestExt4Size(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, int64(ext4MinFree), ext4Size(0, 0))
+	size := ext4Size(100<<20, 1000)
+	require.Greater(t, size, int64(100<<20+1000*ext4BlockSize+ext4MinFree))
+	require.Zero(t, size%(1<<20))
+	require.Greater(t, ext4Inodes(1000), int64(1000))
+}
+
+func TestRunMkfs(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("test requires sh")
+	}
+	t.Parallel()
+
+	ctx := t.Context()
+	out := filepath.Join(t.TempDir(), "out")
+	data := bytes.Repeat([]byte("a"), 1<<20)
+
+	err := runMkfs(ctx, []string{"sh", "-c", `cat > "$0"`, out}, nil, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+	dt, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, data, dt)
+
+	// the tar stream doesn't block if the command fails before reading it
+	err = runMkfs(ctx, []string{"sh", "-c", "echo no space left >&2; exit 3"}, nil, func(w io.Writer) error {
+		for range 64 {
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.ErrorContains(t, err, "sh failed: no space left")
+
+	err = runMkfs(ctx, []string{"buildkit-missing-mkfs"}, nil, nil)
+	require.ErrorContains(t, err, "buildkit-missing-mkfs is required")
+}
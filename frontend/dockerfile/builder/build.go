@@ -172,10 +172,11 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 		id := platforms.FormatAll(platforms.Normalize(p))
 		scanTargets.Store(id, dfRes)
 		return &dockerui.BuildResult{
-			Reference: ref,
-			Image:     dfRes.Image,
-			BaseImage: dfRes.BaseImage,
-			Epoch:     dfRes.Epoch,
+			Reference:   ref,
+			Image:       dfRes.Image,
+			BaseImage:   dfRes.BaseImage,
+			Epoch:       dfRes.Epoch,
+			StageLayers: dfRes.StageLayers,
 		}, nil
 	})
 	if err != nil {
@@ -77,6 +77,9 @@ type Result struct {
 	BaseImage *dockerspec.DockerOCIImage
 	SBOM      *SBOMTargets
 	Epoch     *time.Time
+	// StageLayers is the number of layers of the image of each named stage
+	// that the target is built from.
+	StageLayers map[string]int
 
 	IsIgnoreCache bool
 }
@@ -95,7 +98,8 @@ func Dockerfile2LLB(ctx context.Context, dt []byte, opt ConvertOpt) (*Result, er
 			Core:   ds.state,
 			Extras: map[string]llb.State{},
 		},
-		Epoch: ds.epoch,
+		Epoch:       ds.epoch,
+		StageLayers: stageLayers(ds),
 	}
 	if ds.scanContext {
 		res.SBOM.Extras["context"] = ds.opt.buildContext
@@ -838,6 +842,7 @@ func (dctx *dispatchContext) dispatchStages(ctx context.Context, allReachable ma
 			devices:             dctx.opt.Devices,
 			cgroupParent:        dctx.opt.CgroupParent,
 			linuxResources:      dctx.opt.LinuxResources,
+			runtime:             dctx.opt.Runtime,
 			llbCaps:             dctx.opt.LLBCaps,
 			sourceMap:           dctx.opt.SourceMap,
 			lint:                dctx.lint,
@@ -994,6 +999,7 @@ type dispatchOpt struct {
 	devices             []*pb.CDIDevice
 	cgroupParent        string
 	linuxResources      *pb.LinuxResources
+	runtime             string
 	llbCaps             *apicaps.CapSet
 	sourceMap           *llb.SourceMap
 	lint                *linter.Linter
@@ -1161,6 +1167,38 @@ func dispatch(d *dispatchState, cmd command, opt dispatchOpt) error {
 	return err
 }
 
+// stageLayers returns the number of layers of the images of the named stages
+// that ds is built from. The layers of a stage are the layers of the base
+// image and the layers added by the stages up to it.
+func stageLayers(ds *dispatchState) map[string]int {
+	var baseLayers, baseHistory int
+	if ds.baseImg != nil {
+		baseLayers = len(ds.baseImg.RootFS.DiffIDs)
+		baseHistory = nonEmptyHistory(ds.baseImg.History)
+	}
+	var m map[string]int
+	for d := ds.base; d != nil; d = d.base {
+		if d.stageName == "" {
+			continue
+		}
+		if m == nil {
+			m = map[string]int{}
+		}
+		m[d.stageName] = baseLayers + nonEmptyHistory(d.image.History) - baseHistory
+	}
+	return m
+}
+
+func nonEmptyHistory(history []ocispecs.History) int {
+	var n int
+	for _, h := range history {
+		if !h.EmptyLayer {
+			n++
+		}
+	}
+	return n
+}
+
 type dispatchState struct {
 	opt          dispatchOpt
 	state        llb.State
@@ -1489,7 +1527,7 @@ func dispatchRun(d *dispatchState, c *instructions.RunCommand, proxy *llb.ProxyE
 
 	if dopt.llbCaps != nil && dopt.llbCaps.Supports(pb.CapExecMetaLinuxResources) == nil {
 		if dopt.linuxResources != nil {
-			opt = append(opt, llb.WithLinuxResources(llb.LinuxResources{
+			res := llb.LinuxResources{
 				Memory:     dopt.linuxResources.Memory,
 				MemorySwap: dopt.linuxResources.MemorySwap,
 				CPUShares:  dopt.linuxResources.CpuShares,
@@ -1497,8 +1535,25 @@ func dispatchRun(d *dispatchState, c *instructions.RunCommand, proxy *llb.ProxyE
 				CPUQuota:   dopt.linuxResources.CpuQuota,
 				CpusetCpus: dopt.linuxResources.CpusetCpus,
 				CpusetMems: dopt.linuxResources.CpusetMems,
-			}))
+			}
+			if dopt.llbCaps.Supports(pb.CapExecMetaLinuxResourcesPidsBlkio) == nil {
+				res.PidsLimit = dopt.linuxResources.PidsLimit
+				res.BlkioWeight = uint16(dopt.linuxResources.BlkioWeight)
+			}
+			if dopt.llbCaps.Supports(pb.CapExecMetaLinuxResourcesDiskQuota) == nil {
+				res.DiskQuota = dopt.linuxResources.DiskQuota
+			}
+			opt = append(opt, llb.WithLinuxResources(res))
+		}
+	}
+
+	if dopt.runtime != "" {
+		if dopt.llbCaps != nil {
+			if err := dopt.llbCaps.Supports(pb.CapExecMetaRuntime); err != nil {
+				return errors.Wrap(err, "runtime selection is not supported")
+			}
 		}
+		opt = append(opt, llb.WithRuntime(dopt.runtime))
 	}
 
 	d.state = d.state.Run(opt...).Root()
@@ -117,6 +117,12 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 				mount.CacheID = path.Clean(mount.Target)
 			}
 			mountOpts = append(mountOpts, llb.AsPersistentCacheDir(opt.cacheIDNamespace+"/"+mount.CacheID, sharing))
+			if mount.SizeLimit > 0 {
+				if err := opt.llbCaps.Supports(pb.CapExecMountCacheSizeLimit); err != nil {
+					return nil, errors.Wrap(err, "cache mount size limit is not supported")
+				}
+				mountOpts = append(mountOpts, llb.CacheMountSizeLimit(mount.SizeLimit))
+			}
 		}
 		target := mount.Target
 		if !system.IsAbsolutePath(filepath.Clean(mount.Target)) {
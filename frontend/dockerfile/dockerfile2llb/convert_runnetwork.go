@@ -1,6 +1,8 @@
 package dockerfile2llb
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
 
 	"github.com/moby/buildkit/client/llb"
@@ -11,6 +13,10 @@ import (
 func dispatchRunNetwork(c *instructions.RunCommand) (llb.RunOption, error) {
 	network := instructions.GetNetwork(c)
 
+	if name, ok := strings.CutPrefix(network, instructions.NetworkCNIPrefix); ok {
+		return llb.WithNetworkName(name), nil
+	}
+
 	switch network {
 	case instructions.NetworkDefault:
 		return nil, nil
@@ -1,6 +1,8 @@
 package dockerfile2llb
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
 
 	"github.com/moby/buildkit/client/llb"
@@ -11,6 +13,10 @@ import (
 func dispatchRunSecurity(c *instructions.RunCommand) (llb.RunOption, error) {
 	security := instructions.GetSecurity(c)
 
+	if name, ok := strings.CutPrefix(security, instructions.SecurityProfilePrefix); ok {
+		return llb.WithSecurityProfile(name), nil
+	}
+
 	switch security {
 	case instructions.SecurityInsecure:
 		return llb.Security(pb.SecurityMode_INSECURE), nil
@@ -423,3 +423,21 @@ func TestSourceStateFromSourceOpWrappedCopy(t *testing.T) {
 	assert.Equal(t, sourceOp.Identifier, rewrittenSourceOp.Identifier)
 	assert.Equal(t, sourceOp.Attrs, rewrittenSourceOp.Attrs)
 }
+
+func TestStageLayers(t *testing.T) {
+	t.Parallel()
+	df := `FROM scratch AS build
+COPY f1 /
+ENV FOO=bar
+COPY f2 /
+FROM build AS test
+RUN ls
+FROM scratch AS other
+COPY f3 /
+FROM test
+COPY --from=other /f3 /
+`
+	res, err := Dockerfile2LLB(appcontext.Context(), []byte(df), ConvertOpt{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"build": 2, "test": 3}, res.StageLayers)
+}
@@ -1,6 +1,8 @@
 package instructions
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
@@ -10,6 +12,10 @@ const (
 	NetworkDefault NetworkMode = "default"
 	NetworkNone    NetworkMode = "none"
 	NetworkHost    NetworkMode = "host"
+
+	// NetworkCNIPrefix selects a named CNI network configured in the daemon,
+	// e.g. cni=isolated.
+	NetworkCNIPrefix = "cni="
 )
 
 var allowedNetwork = map[NetworkMode]struct{}{
@@ -19,6 +25,9 @@ var allowedNetwork = map[NetworkMode]struct{}{
 }
 
 func isValidNetwork(value string) bool {
+	if name, ok := strings.CutPrefix(value, NetworkCNIPrefix); ok {
+		return name != ""
+	}
 	_, ok := allowedNetwork[value]
 	return ok
 }
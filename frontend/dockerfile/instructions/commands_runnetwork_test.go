@@ -0,0 +1,26 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidNetwork(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "default", expected: true},
+		{input: "none", expected: true},
+		{input: "host", expected: true},
+		{input: "cni=isolated", expected: true},
+		{input: "cni=", expected: false},
+		{input: "isolated", expected: false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			require.Equal(t, tt.expected, isValidNetwork(tt.input))
+		})
+	}
+}
@@ -1,12 +1,18 @@
 package instructions
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
 const (
 	SecurityInsecure = "insecure"
 	SecuritySandbox  = "sandbox"
+
+	// SecurityProfilePrefix selects a security profile configured in the
+	// daemon, e.g. profile=perf.
+	SecurityProfilePrefix = "profile="
 )
 
 var allowedSecurity = map[string]struct{}{
@@ -15,6 +21,9 @@ var allowedSecurity = map[string]struct{}{
 }
 
 func isValidSecurity(value string) bool {
+	if name, ok := strings.CutPrefix(value, SecurityProfilePrefix); ok {
+		return name != ""
+	}
 	_, ok := allowedSecurity[value]
 	return ok
 }
@@ -0,0 +1,25 @@
+package instructions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidSecurity(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "sandbox", expected: true},
+		{input: "insecure", expected: true},
+		{input: "profile=perf", expected: true},
+		{input: "profile=", expected: false},
+		{input: "perf", expected: false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			require.Equal(t, tt.expected, isValidSecurity(tt.input))
+		})
+	}
+}
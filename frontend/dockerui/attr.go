@@ -163,8 +163,40 @@ func parseLinuxResources(opts map[string]string) (*pb.LinuxResources, error) {
 		res.CpusetMems = v
 	}
 
+	if v, ok := opts[keyPidsLimit]; ok && v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value: %s", keyPidsLimit, v)
+		}
+		if n <= 0 {
+			return nil, errors.Errorf("invalid %s value: %s: must be > 0", keyPidsLimit, v)
+		}
+		res.PidsLimit = n
+	}
+	if v, ok := opts[keyBlkioWeight]; ok && v != "" {
+		n, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value: %s", keyBlkioWeight, v)
+		}
+		if n < 10 || n > 1000 {
+			return nil, errors.Errorf("invalid %s value: %s: must be in range 10-1000", keyBlkioWeight, v)
+		}
+		res.BlkioWeight = uint32(n)
+	}
+	if v, ok := opts[keyDiskQuota]; ok && v != "" {
+		n, err := units.RAMInBytes(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value: %s", keyDiskQuota, v)
+		}
+		if n <= 0 {
+			return nil, errors.Errorf("invalid %s value: %s: must be > 0", keyDiskQuota, v)
+		}
+		res.DiskQuota = n
+	}
+
 	if res.Memory == 0 && res.MemorySwap == 0 && res.CpuShares == 0 &&
-		res.CpuPeriod == 0 && res.CpuQuota == 0 && res.CpusetCpus == "" && res.CpusetMems == "" {
+		res.CpuPeriod == 0 && res.CpuQuota == 0 && res.CpusetCpus == "" && res.CpusetMems == "" &&
+		res.PidsLimit == 0 && res.BlkioWeight == 0 && res.DiskQuota == 0 {
 		return nil, nil
 	}
 	return &res, nil
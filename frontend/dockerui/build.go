@@ -23,6 +23,9 @@ type BuildResult struct {
 	Image     *dockerspec.DockerOCIImage
 	BaseImage *dockerspec.DockerOCIImage
 	Epoch     *time.Time
+	// StageLayers is the number of layers of the stages the result is built
+	// from. It is used by the squash-from exporter option.
+	StageLayers map[string]int
 }
 
 type BuildFunc func(ctx context.Context, platform *ocispecs.Platform, idx int) (*BuildResult, error)
@@ -64,6 +67,14 @@ func (bc *Client) Build(ctx context.Context, fn BuildFunc) (*ResultBuilder, erro
 				}
 			}
 
+			var stageLayers []byte
+			if len(buildRes.StageLayers) > 0 {
+				stageLayers, err = json.Marshal(buildRes.StageLayers)
+				if err != nil {
+					return errors.Wrapf(err, "failed to marshal stage layers")
+				}
+			}
+
 			var p ocispecs.Platform
 			if tp != nil {
 				p = *tp
@@ -77,6 +88,9 @@ func (bc *Client) Build(ctx context.Context, fn BuildFunc) (*ResultBuilder, erro
 				if len(baseConfig) > 0 {
 					res.AddMeta(fmt.Sprintf("%s/%s", exptypes.ExporterImageBaseConfigKey, expPlat.ID), baseConfig)
 				}
+				if len(stageLayers) > 0 {
+					res.AddMeta(fmt.Sprintf("%s/%s", exptypes.ExporterImageStageLayersKey, expPlat.ID), stageLayers)
+				}
 				if buildRes.Epoch != nil {
 					res.AddMeta(fmt.Sprintf("%s/%s", commonexptypes.ExporterEpochKey, expPlat.ID), []byte(strconv.FormatInt(buildRes.Epoch.Unix(), 10)))
 				}
@@ -86,6 +100,9 @@ func (bc *Client) Build(ctx context.Context, fn BuildFunc) (*ResultBuilder, erro
 				if len(baseConfig) > 0 {
 					res.AddMeta(exptypes.ExporterImageBaseConfigKey, baseConfig)
 				}
+				if len(stageLayers) > 0 {
+					res.AddMeta(exptypes.ExporterImageStageLayersKey, stageLayers)
+				}
 				if buildRes.Epoch != nil {
 					res.AddMeta(commonexptypes.ExporterEpochKey, []byte(strconv.FormatInt(buildRes.Epoch.Unix(), 10)))
 				}
@@ -47,6 +47,10 @@ const (
 	keyCPUQuota         = "cpuquota"
 	keyCpusetCpus       = "cpusetcpus"
 	keyCpusetMems       = "cpusetmems"
+	keyPidsLimit        = "pids-limit"
+	keyBlkioWeight      = "blkio-weight"
+	keyDiskQuota        = "disk-quota"
+	keyRuntime          = "runtime"
 	keyCacheFrom        = "cache-from"    // for registry only. deprecated in favor of keyCacheImports
 	keyCacheImports     = "cache-imports" // JSON representation of []CacheOptionsEntry
 
@@ -73,6 +77,7 @@ type Config struct {
 	Ulimits          []*pb.Ulimit
 	LinuxResources   *pb.LinuxResources
 	Devices          []*pb.CDIDevice
+	Runtime          string
 	LinterConfig     *linter.Config
 
 	CacheImports           []client.CacheOptionsEntry
@@ -206,6 +211,7 @@ func (bc *Client) init() error {
 		return errors.Wrap(err, "failed to parse resource limits")
 	}
 	bc.LinuxResources = linuxRes
+	bc.Runtime = opts[keyRuntime]
 
 	defaultNetMode, err := parseNetMode(opts[keyForceNetwork])
 	if err != nil {
@@ -366,3 +366,45 @@ func testResult(v string) Result {
 		value: v,
 	}
 }
+
+func TestInvalidateCacheKey(t *testing.T) {
+	s := NewInMemoryCacheStorage()
+
+	now := time.Now()
+	require.NoError(t, s.AddResult("a", CacheResult{ID: "resA", CreatedAt: now}))
+	require.NoError(t, s.AddLink("a", CacheInfoLink{Digest: dgst("b")}, "b"))
+	require.NoError(t, s.AddResult("b", CacheResult{ID: "resB", CreatedAt: now}))
+	require.NoError(t, s.AddLink("b", CacheInfoLink{Digest: dgst("c")}, "c"))
+	require.NoError(t, s.AddResult("c", CacheResult{ID: "resC", CreatedAt: now}))
+	require.NoError(t, s.AddResult("x", CacheResult{ID: "resX", CreatedAt: now}))
+
+	info, err := InspectCacheKey(s, "b")
+	require.NoError(t, err)
+	require.Equal(t, "b", info.ID)
+	require.Len(t, info.Results, 1)
+	require.Equal(t, "resB", info.Results[0].ID)
+	require.Len(t, info.Links, 1)
+	require.Equal(t, "c", info.Links[0].ID)
+	require.Len(t, info.Backlinks, 1)
+	require.Equal(t, "a", info.Backlinks[0].ID)
+
+	_, err = InspectCacheKey(s, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	released, err := InvalidateCacheKey(s, "a", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"resA"}, released)
+	_, err = s.Load("a", "resA")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Load("b", "resB")
+	require.NoError(t, err)
+
+	released, err = InvalidateCacheKey(s, "a", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"resC", "resB"}, released)
+	require.False(t, s.Exists("c"))
+	require.False(t, s.Exists("b"))
+
+	_, err = s.Load("x", "resX")
+	require.NoError(t, err)
+}
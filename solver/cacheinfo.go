@@ -0,0 +1,117 @@
+package solver
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// VertexCacheInfo describes the inputs of the cache key of a vertex loaded by
+// a job. Comparing it with the same information from an earlier job explains
+// why a vertex did not match the cache.
+type VertexCacheInfo struct {
+	Digest      digest.Digest
+	Name        string
+	Sys         any
+	Inputs      []InputCacheInfo
+	IgnoreCache bool
+	// Executed is set if the vertex was evaluated instead of being loaded
+	// from the cache.
+	Executed bool
+}
+
+// InputCacheInfo describes an input of a vertex.
+type InputCacheInfo struct {
+	Vertex digest.Digest
+	Index  Index
+	// ContentChecksum is the digest of the content of the input if it was
+	// used for the cache key.
+	ContentChecksum digest.Digest
+	// Paths are the checksums of the individual paths ContentChecksum was
+	// computed from.
+	Paths []PathChecksum
+}
+
+// PathChecksum is the content checksum of a path in an input.
+type PathChecksum struct {
+	Path   string
+	Digest digest.Digest
+}
+
+type pathChecksumKey struct{}
+
+type pathChecksumRecorder struct {
+	mu    sync.Mutex
+	paths []PathChecksum
+}
+
+// RecordPathChecksum records the checksum of a path while a
+// ResultBasedCacheFunc computes the content checksum of an input.
+func RecordPathChecksum(ctx context.Context, path string, dgst digest.Digest) {
+	r, ok := ctx.Value(pathChecksumKey{}).(*pathChecksumRecorder)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	r.paths = append(r.paths, PathChecksum{Path: path, Digest: dgst})
+	r.mu.Unlock()
+}
+
+func withPathChecksumRecorder(ctx context.Context) (context.Context, func() []PathChecksum) {
+	r := &pathChecksumRecorder{}
+	return context.WithValue(ctx, pathChecksumKey{}, r), func() []PathChecksum {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		paths := slices.Clone(r.paths)
+		slices.SortFunc(paths, func(a, b PathChecksum) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+		return paths
+	}
+}
+
+// CacheInfo returns the cache key inputs of all vertexes loaded by the job.
+func (j *Job) CacheInfo() []VertexCacheInfo {
+	j.list.mu.RLock()
+	defer j.list.mu.RUnlock()
+
+	var out []VertexCacheInfo
+	for _, st := range j.list.actives {
+		if _, ok := st.jobs[j]; !ok {
+			continue
+		}
+		info := VertexCacheInfo{
+			Digest:      st.vtx.Digest(),
+			Name:        st.vtx.Name(),
+			Sys:         st.vtx.Sys(),
+			IgnoreCache: st.vtx.Options().IgnoreCache,
+		}
+		for _, inp := range st.vtx.Inputs() {
+			info.Inputs = append(info.Inputs, InputCacheInfo{
+				Vertex: inp.Vertex.Digest(),
+				Index:  inp.Index,
+			})
+		}
+
+		st.mu.Lock()
+		op := st.op
+		st.mu.Unlock()
+		if op != nil {
+			info.Executed = op.executed.Load()
+			op.slowMu.Lock()
+			for i := range info.Inputs {
+				info.Inputs[i].ContentChecksum = op.slowCacheRes[Index(i)]
+				info.Inputs[i].Paths = op.slowCachePaths[Index(i)]
+			}
+			op.slowMu.Unlock()
+		}
+		out = append(out, info)
+	}
+	slices.SortFunc(out, func(a, b VertexCacheInfo) int {
+		return strings.Compare(string(a.Digest), string(b.Digest))
+	})
+	return out
+}
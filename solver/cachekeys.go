@@ -0,0 +1,111 @@
+package solver
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CacheKeyInfo describes a key in a cache key storage.
+type CacheKeyInfo struct {
+	ID      string
+	Results []CacheResult
+	// Links are the keys that depend on this key
+	Links []CacheKeyInfoLink
+	// Backlinks are the keys this key depends on
+	Backlinks []CacheKeyInfoLink
+}
+
+// CacheKeyInfoLink is a link from or to another cache key.
+type CacheKeyInfoLink struct {
+	ID   string
+	Link CacheInfoLink
+}
+
+// cacheKeyStorageWithLinks is implemented by the storages that can list the
+// links of a key without knowing the link.
+type cacheKeyStorageWithLinks interface {
+	WalkLinksAll(id string, fn func(id string, link CacheInfoLink) error) error
+}
+
+// InspectCacheKey returns the results and the links of a cache key.
+func InspectCacheKey(store CacheKeyStorage, id string) (*CacheKeyInfo, error) {
+	if !store.Exists(id) {
+		return nil, errors.Wrapf(ErrNotFound, "cache key %s", id)
+	}
+	info := &CacheKeyInfo{ID: id}
+	if err := store.WalkResults(id, func(res CacheResult) error {
+		info.Results = append(info.Results, res)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := store.WalkBacklinks(id, func(id string, link CacheInfoLink) error {
+		info.Backlinks = append(info.Backlinks, CacheKeyInfoLink{ID: id, Link: link})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if swl, ok := store.(cacheKeyStorageWithLinks); ok {
+		if err := swl.WalkLinksAll(id, func(id string, link CacheInfoLink) error {
+			info.Links = append(info.Links, CacheKeyInfoLink{ID: id, Link: link})
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// InvalidateCacheKey releases the results of a cache key so that the next
+// build using the key runs the operation again. If dependents is set, the
+// results of all keys depending on the key are released as well. The
+// results are released for all keys that share them. It returns the IDs of
+// the released results.
+func InvalidateCacheKey(store CacheKeyStorage, id string, dependents bool) ([]string, error) {
+	if !store.Exists(id) {
+		return nil, errors.Wrapf(ErrNotFound, "cache key %s", id)
+	}
+	ids := []string{id}
+	if dependents {
+		swl, ok := store.(cacheKeyStorageWithLinks)
+		if !ok {
+			return nil, errors.New("cache key storage does not support walking dependent keys")
+		}
+		visited := map[string]struct{}{id: {}}
+		for i := 0; i < len(ids); i++ {
+			if err := swl.WalkLinksAll(ids[i], func(id string, _ CacheInfoLink) error {
+				if _, ok := visited[id]; !ok {
+					visited[id] = struct{}{}
+					ids = append(ids, id)
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var results []string
+	seen := map[string]struct{}{}
+	// dependents are released first so that their keys are removed before
+	// the keys they depend on
+	for i := len(ids) - 1; i >= 0; i-- {
+		var resIDs []string
+		if err := store.WalkResults(ids[i], func(res CacheResult) error {
+			resIDs = append(resIDs, res.ID)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		for _, resID := range resIDs {
+			if _, ok := seen[resID]; ok {
+				continue
+			}
+			seen[resID] = struct{}{}
+			if err := store.Release(resID); err != nil && !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			results = append(results, resID)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,54 @@
+package errdefs
+
+import (
+	"errors"
+
+	"github.com/containerd/typeurl/v2"
+	"github.com/moby/buildkit/util/grpcerrors"
+	"google.golang.org/grpc/codes"
+)
+
+func init() {
+	typeurl.Register((*Checkpoint)(nil), "github.com/moby/buildkit", "errdefs.Checkpoint+json")
+}
+
+// CheckpointedError is returned when the process of an exec was checkpointed
+// instead of completing. The build can be run again to resume it. The gRPC
+// status code of the error is Aborted.
+type CheckpointedError struct {
+	*Checkpoint
+	error
+}
+
+func (e *CheckpointedError) Unwrap() error {
+	return e.error
+}
+
+func (e *CheckpointedError) Code() codes.Code {
+	return codes.Aborted
+}
+
+func (e *CheckpointedError) ToProto() grpcerrors.TypedErrorProto {
+	return e.Checkpoint
+}
+
+func (v *Checkpoint) WrapError(err error) error {
+	return &CheckpointedError{error: err, Checkpoint: v}
+}
+
+func WithCheckpointed(err error, id string) error {
+	if err == nil {
+		return nil
+	}
+	return &CheckpointedError{
+		error:      err,
+		Checkpoint: &Checkpoint{Id: id},
+	}
+}
+
+// IsCheckpointed returns true if err was returned by a build that
+// checkpointed the process of an exec.
+func IsCheckpointed(err error) bool {
+	var ce *CheckpointedError
+	return errors.As(err, &ce)
+}
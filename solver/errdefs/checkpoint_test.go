@@ -0,0 +1,27 @@
+package errdefs
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/util/grpcerrors"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCheckpointedRoundTrip(t *testing.T) {
+	err := WithCheckpointed(errors.New("process \"make\" will be resumed by the next build"), "x1b8uvxmd2nrt6jtt4lwd1lmb")
+	err = errors.Wrap(WrapVertex(err, digest.FromString("exec")), "failed to solve")
+	require.Equal(t, codes.Aborted, grpcerrors.Code(err))
+
+	decoded := grpcerrors.FromGRPC(grpcerrors.ToGRPC(t.Context(), err))
+	require.Equal(t, codes.Aborted, grpcerrors.Code(decoded))
+	require.True(t, IsCheckpointed(decoded))
+	var ce *CheckpointedError
+	require.ErrorAs(t, decoded, &ce)
+	require.Equal(t, "x1b8uvxmd2nrt6jtt4lwd1lmb", ce.Id)
+	require.ErrorContains(t, decoded, "will be resumed by the next build")
+
+	require.False(t, IsCheckpointed(grpcerrors.FromGRPC(grpcerrors.ToGRPC(t.Context(), errors.New("failed")))))
+}
@@ -642,6 +642,53 @@ func (x *ProvenanceMaterialIncomplete) GetReason() string {
 	return ""
 }
 
+// Checkpoint is returned when the process of an exec was checkpointed and
+// stopped. The next build of the exec with the same inputs resumes it.
+type Checkpoint struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the cache record of the checkpoint.
+	Id            string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Checkpoint) Reset() {
+	*x = Checkpoint{}
+	mi := &file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Checkpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Checkpoint) ProtoMessage() {}
+
+func (x *Checkpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Checkpoint.ProtoReflect.Descriptor instead.
+func (*Checkpoint) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Checkpoint) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
 var File_github_com_moby_buildkit_solver_errdefs_errdefs_proto protoreflect.FileDescriptor
 
 const file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc = "" +
@@ -689,7 +736,10 @@ const file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc = "" +
 	"\x06method\x18\x03 \x01(\tR\x06method\x12\x10\n" +
 	"\x03uri\x18\x04 \x01(\tR\x03uri\x12\x1b\n" +
 	"\tfinal_uri\x18\x05 \x01(\tR\bfinalUri\x12\x16\n" +
-	"\x06reason\x18\x06 \x01(\tR\x06reasonB)Z'github.com/moby/buildkit/solver/errdefsb\x06proto3"
+	"\x06reason\x18\x06 \x01(\tR\x06reason\"\x1c\n" +
+	"\n" +
+	"Checkpoint\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02idB)Z'github.com/moby/buildkit/solver/errdefsb\x06proto3"
 
 var (
 	file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescOnce sync.Once
@@ -703,7 +753,7 @@ func file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescGZIP() []
 	return file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescData
 }
 
-var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_goTypes = []any{
 	(*Vertex)(nil),                        // 0: errdefs.Vertex
 	(*Source)(nil),                        // 1: errdefs.Source
@@ -716,18 +766,19 @@ var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_goTypes = []any{
 	(*ContentCache)(nil),                  // 8: errdefs.ContentCache
 	(*ProvenanceMaterialsIncomplete)(nil), // 9: errdefs.ProvenanceMaterialsIncomplete
 	(*ProvenanceMaterialIncomplete)(nil),  // 10: errdefs.ProvenanceMaterialIncomplete
-	nil,                                   // 11: errdefs.Solve.DescriptionEntry
-	(*pb.SourceInfo)(nil),                 // 12: pb.SourceInfo
-	(*pb.Range)(nil),                      // 13: pb.Range
-	(*pb.Op)(nil),                         // 14: pb.Op
+	(*Checkpoint)(nil),                    // 11: errdefs.Checkpoint
+	nil,                                   // 12: errdefs.Solve.DescriptionEntry
+	(*pb.SourceInfo)(nil),                 // 13: pb.SourceInfo
+	(*pb.Range)(nil),                      // 14: pb.Range
+	(*pb.Op)(nil),                         // 15: pb.Op
 }
 var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_depIdxs = []int32{
-	12, // 0: errdefs.Source.info:type_name -> pb.SourceInfo
-	13, // 1: errdefs.Source.ranges:type_name -> pb.Range
-	14, // 2: errdefs.Solve.op:type_name -> pb.Op
+	13, // 0: errdefs.Source.info:type_name -> pb.SourceInfo
+	14, // 1: errdefs.Source.ranges:type_name -> pb.Range
+	15, // 2: errdefs.Solve.op:type_name -> pb.Op
 	7,  // 3: errdefs.Solve.file:type_name -> errdefs.FileAction
 	8,  // 4: errdefs.Solve.cache:type_name -> errdefs.ContentCache
-	11, // 5: errdefs.Solve.description:type_name -> errdefs.Solve.DescriptionEntry
+	12, // 5: errdefs.Solve.description:type_name -> errdefs.Solve.DescriptionEntry
 	10, // 6: errdefs.ProvenanceMaterialsIncomplete.incomplete:type_name -> errdefs.ProvenanceMaterialIncomplete
 	7,  // [7:7] is the sub-list for method output_type
 	7,  // [7:7] is the sub-list for method input_type
@@ -751,7 +802,7 @@ func file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc), len(file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
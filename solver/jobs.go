@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/moby/buildkit/client"
@@ -14,6 +17,7 @@ import (
 	"github.com/moby/buildkit/solver/llbsolver/compat"
 	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/util/bkmaps"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/flightcontrol"
 	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/util/progress/controller"
@@ -120,6 +124,26 @@ func (s *state) CompatibilityVersion() (int, error) {
 	return version, nil
 }
 
+// fairshareRequest returns the scheduling request of the job with the highest
+// priority that uses the vertex.
+func (s *state) fairshareRequest() fairshare.Request {
+	s.mu.RLock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.RUnlock()
+
+	var req fairshare.Request
+	for i, j := range jobs {
+		r := j.fairshareRequest()
+		if i == 0 || r.Priority > req.Priority {
+			req = r
+		}
+	}
+	return req
+}
+
 func (s *state) Lock(key any) (values []any, release func(any) error, err error) {
 	var rcs []ResolverCache
 	s.mu.RLock()
@@ -851,6 +875,35 @@ func (j *Job) walkProvenance(ctx context.Context, e Edge, f func(ProvenanceProvi
 	return nil
 }
 
+// ExecutedOp is an op that was executed for a vertex.
+type ExecutedOp struct {
+	Vertex Vertex
+	Op     Op
+}
+
+// ExecutedOps returns the ops of the vertexes that were executed for the job
+// instead of being loaded from the cache.
+func (j *Job) ExecutedOps() []ExecutedOp {
+	j.list.mu.RLock()
+	defer j.list.mu.RUnlock()
+
+	var out []ExecutedOp
+	for _, st := range j.list.actives {
+		if _, ok := st.jobs[j]; !ok {
+			continue
+		}
+		st.mu.Lock()
+		if st.op != nil && st.op.op != nil && st.op.executed.Load() {
+			out = append(out, ExecutedOp{Vertex: st.vtx, Op: st.op.op})
+		}
+		st.mu.Unlock()
+	}
+	slices.SortFunc(out, func(a, b ExecutedOp) int {
+		return strings.Compare(string(a.Vertex.Digest()), string(b.Vertex.Digest()))
+	})
+	return out
+}
+
 func (j *Job) CloseProgress() {
 	j.progressCloser(errors.WithStack(context.Canceled))
 	j.pw.Close()
@@ -929,6 +982,17 @@ func (j *Job) CompatibilityVersion() (int, error) {
 	return version, nil
 }
 
+func (j *Job) fairshareRequest() fairshare.Request {
+	var req fairshare.Request
+	_ = j.EachValue(context.TODO(), fairshare.JobValueKey, func(v any) error {
+		if r, ok := v.(fairshare.Request); ok {
+			req = r
+		}
+		return nil
+	})
+	return req
+}
+
 func (j *Job) InContext(ctx context.Context, f func(context.Context, JobContext) error) error {
 	return f(progress.WithProgress(ctx, j.pw), j)
 }
@@ -976,10 +1040,11 @@ type activeOp interface {
 
 func newSharedOp(resolver ResolveOpFunc, st *state) *sharedOp {
 	so := &sharedOp{
-		resolver:     resolver,
-		st:           st,
-		slowCacheRes: map[Index]digest.Digest{},
-		slowCacheErr: map[Index]error{},
+		resolver:       resolver,
+		st:             st,
+		slowCacheRes:   map[Index]digest.Digest{},
+		slowCacheErr:   map[Index]error{},
+		slowCachePaths: map[Index][]PathChecksum{},
 	}
 	return so
 }
@@ -1004,18 +1069,20 @@ type sharedOp struct {
 	execRes  *execRes
 	execDone bool
 	execErr  error
+	executed atomic.Bool
 
 	cacheRes  []*CacheMap
 	cacheDone bool
 	cacheErr  error
 
-	slowMu       sync.Mutex
-	slowCacheRes map[Index]digest.Digest
-	slowCacheErr map[Index]error
+	slowMu         sync.Mutex
+	slowCacheRes   map[Index]digest.Digest
+	slowCacheErr   map[Index]error
+	slowCachePaths map[Index][]PathChecksum
 }
 
 func (s *sharedOp) IgnoreCache() bool {
-	return s.st.vtx.Options().IgnoreCache
+	return s.st.vtx.Options().IgnoreCache || s.reproducibleCheck() != nil
 }
 
 func (s *sharedOp) Cache() CacheManager {
@@ -1094,12 +1161,15 @@ func (s *sharedOp) CalcSlowCache(ctx context.Context, index Index, p PreprocessF
 		}
 
 		var key digest.Digest
+		var paths func() []PathChecksum
 		if f != nil {
 			ctx = progress.WithProgress(ctx, s.st.mpw)
 			if s.st.mspan.Span != nil {
 				ctx = trace.ContextWithSpan(ctx, s.st.mspan)
 			}
-			key, err = f(withAncestorCacheOpts(ctx, s.st), res, s.st)
+			var fctx context.Context
+			fctx, paths = withPathChecksumRecorder(withAncestorCacheOpts(ctx, s.st))
+			key, err = f(fctx, res, s.st)
 		}
 		if err != nil {
 			select {
@@ -1117,6 +1187,9 @@ func (s *sharedOp) CalcSlowCache(ctx context.Context, index Index, p PreprocessF
 		if complete {
 			if err == nil {
 				s.slowCacheRes[index] = key
+				if paths != nil {
+					s.slowCachePaths[index] = paths()
+				}
 			}
 			s.slowCacheErr[index] = err
 		}
@@ -1165,7 +1238,14 @@ func (s *sharedOp) CacheMap(ctx context.Context, index int) (resp *cacheMapResp,
 				notifyCompleted(retErr, false)
 			}()
 		}
-		res, done, err := op.CacheMap(ctx, s.st, len(s.cacheRes))
+		var (
+			res  *CacheMap
+			done bool
+		)
+		err := runWithPolicy(ctx, s.st.vtx.Options(), func(ctx context.Context) (err error) {
+			res, done, err = op.CacheMap(ctx, s.st, len(s.cacheRes))
+			return err
+		})
 		complete := true
 		if err != nil {
 			select {
@@ -1224,18 +1304,18 @@ func (s *sharedOp) Exec(ctx context.Context, inputs []Result) (outputs []Result,
 			}
 			return s.execRes, nil
 		}
-		release, err := op.Acquire(ctx)
-		if err != nil {
-			return nil, errors.Wrap(err, "acquire op resources")
-		}
-		defer release()
-
 		ctx = progress.WithProgress(ctx, s.st.mpw)
 		if s.st.mspan.Span != nil {
 			ctx = trace.ContextWithSpan(ctx, s.st.mspan)
 		}
 		ctx = withAncestorCacheOpts(ctx, s.st)
 
+		release, err := op.Acquire(fairshare.WithRequest(ctx, s.st.fairshareRequest()))
+		if err != nil {
+			return nil, errors.Wrap(err, "acquire op resources")
+		}
+		defer release()
+
 		// no cache hit. start evaluating the node
 		span, ctx := tracing.StartSpan(ctx, s.st.vtx.Name(), trace.WithAttributes(attribute.String("vertex", s.st.vtx.Digest().String())))
 		s.st.execSpan = span
@@ -1245,7 +1325,15 @@ func (s *sharedOp) Exec(ctx context.Context, inputs []Result) (outputs []Result,
 			notifyCompleted(retErr, false)
 		}()
 
-		res, err := op.Exec(ctx, s.st, inputs)
+		s.executed.Store(true)
+		var res []Result
+		err = runWithPolicy(ctx, s.st.vtx.Options(), func(ctx context.Context) (err error) {
+			res, err = op.Exec(ctx, s.st, inputs)
+			return err
+		})
+		if err == nil {
+			s.checkReproducible(ctx, op, inputs, res)
+		}
 		complete := true
 		if err != nil {
 			select {
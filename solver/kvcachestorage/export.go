@@ -0,0 +1,131 @@
+package kvcachestorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/moby/buildkit/util/kv"
+	"github.com/pkg/errors"
+)
+
+var snapshotMagic = []byte("buildkit-cache-index-v1\n")
+
+// importBatchSize is the number of records written in a single transaction
+// when importing a snapshot.
+const importBatchSize = 10000
+
+// maxRecordSize guards against allocating huge buffers for corrupted
+// snapshots.
+const maxRecordSize = 64 * 1024 * 1024
+
+// Export writes a consistent snapshot of the index to w. The snapshot can be
+// loaded with Import to seed the index of another daemon.
+func (s *Store) Export(ctx context.Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(snapshotMagic); err != nil {
+		return errors.WithStack(err)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	if err := s.kv.View(func(tx kv.Tx) error {
+		return tx.Scan(nil, func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return context.Cause(ctx)
+			}
+			for _, dt := range [][]byte{k, v} {
+				n := binary.PutUvarint(buf, uint64(len(dt)))
+				if _, err := bw.Write(buf[:n]); err != nil {
+					return errors.WithStack(err)
+				}
+				if _, err := bw.Write(dt); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	return errors.WithStack(bw.Flush())
+}
+
+// Import loads a snapshot written by Export into the index. Existing keys are
+// kept. Results that reference records missing from the local cache are
+// released by the cache manager when they are loaded.
+func (s *Store) Import(ctx context.Context, r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, snapshotMagic) {
+		return errors.New("invalid cache index snapshot")
+	}
+
+	type record struct {
+		k, v []byte
+	}
+	var batch []record
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.kv.Update(func(tx kv.Tx) error {
+			for _, rec := range batch {
+				if err := tx.Put(rec.k, rec.v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+		k, err := readRecord(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		v, err := readRecord(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return errors.Wrap(err, "invalid cache index snapshot")
+		}
+		batch = append(batch, record{k: k, v: v})
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+func readRecord(br *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(br)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "invalid cache index snapshot")
+	}
+	if l > maxRecordSize {
+		return nil, errors.Errorf("invalid cache index snapshot: record size %d exceeds limit", l)
+	}
+	dt := make([]byte, l)
+	if _, err := io.ReadFull(br, dt); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, errors.Wrap(err, "invalid cache index snapshot")
+	}
+	return dt, nil
+}
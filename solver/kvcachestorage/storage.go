@@ -0,0 +1,455 @@
+// Package kvcachestorage implements solver.CacheKeyStorage on top of a
+// generic ordered key-value store. Unlike bboltcachestorage, the storage
+// engine is pluggable and the index can be compacted while it is in use and
+// exported to seed the cache key index of another daemon.
+package kvcachestorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/kv"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// The index is stored in a flat key space. Keys are a prefix byte followed by
+// the parts of the key separated by sep.
+const (
+	// keyPrefix marks that a cache key exists: keyPrefix id
+	keyPrefix = 'k'
+	// linkPrefix is a link to a child key: linkPrefix id link@target
+	linkPrefix = 'l'
+	// resultKeyPrefix marks a cache key that had results: resultKeyPrefix id
+	resultKeyPrefix = 'R'
+	// resultPrefix is a result of a key: resultPrefix id resultID
+	resultPrefix = 'r'
+	// byResultPrefix indexes keys by result: byResultPrefix resultID id
+	byResultPrefix = 'b'
+	// backlinkPrefix indexes parents of a key: backlinkPrefix target id
+	backlinkPrefix = 'p'
+
+	sep = 0
+)
+
+func key(prefix byte, parts ...string) []byte {
+	k := []byte{prefix}
+	for i, p := range parts {
+		if i > 0 {
+			k = append(k, sep)
+		}
+		k = append(k, p...)
+	}
+	return k
+}
+
+// scanPrefix returns the prefix of all keys with the given parts.
+func scanPrefix(prefix byte, parts ...string) []byte {
+	return append(key(prefix, parts...), sep)
+}
+
+type Store struct {
+	kv kv.Store
+}
+
+var _ solver.CacheKeyStorage = &Store{}
+
+// NewStore returns a cache key storage that keeps its index in s. The store
+// is closed when the cache key storage is closed.
+func NewStore(s kv.Store) *Store {
+	return &Store{kv: s}
+}
+
+func (s *Store) Close() error {
+	return s.kv.Close()
+}
+
+// Size returns the number of bytes the index uses on disk.
+func (s *Store) Size() (int64, error) {
+	return s.kv.Size()
+}
+
+// Compact reclaims the space of released cache keys.
+func (s *Store) Compact(ctx context.Context) error {
+	return s.kv.Compact(ctx)
+}
+
+func has(tx kv.Tx, k []byte) bool {
+	v, err := tx.Get(k)
+	return err == nil && v != nil
+}
+
+func isEmpty(tx kv.Tx, prefix []byte) (bool, error) {
+	empty := true
+	err := tx.Scan(prefix, func(_, _ []byte) error {
+		empty = false
+		return errStop
+	})
+	if err == errStop {
+		err = nil
+	}
+	return empty, err
+}
+
+var errStop = errors.New("stop")
+
+// scanSuffixes returns the part of the keys after prefix.
+func scanSuffixes(tx kv.Tx, prefix []byte) ([]string, error) {
+	var out []string
+	err := tx.Scan(prefix, func(k, _ []byte) error {
+		out = append(out, string(k[len(prefix):]))
+		return nil
+	})
+	return out, err
+}
+
+func (s *Store) Exists(id string) bool {
+	exists := false
+	if err := s.kv.View(func(tx kv.Tx) error {
+		exists = has(tx, key(keyPrefix, id))
+		return nil
+	}); err != nil {
+		return false
+	}
+	return exists
+}
+
+func (s *Store) Walk(fn func(id string) error) error {
+	var ids []string
+	if err := s.kv.View(func(tx kv.Tx) error {
+		var err error
+		ids, err = scanSuffixes(tx, []byte{keyPrefix})
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) WalkResults(id string, fn func(solver.CacheResult) error) error {
+	var list []solver.CacheResult
+	if err := s.kv.View(func(tx kv.Tx) error {
+		return tx.Scan(scanPrefix(resultPrefix, id), func(_, v []byte) error {
+			var res solver.CacheResult
+			if err := json.Unmarshal(v, &res); err != nil {
+				return err
+			}
+			list = append(list, res)
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	for _, res := range list {
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) Load(id string, resultID string) (solver.CacheResult, error) {
+	var res solver.CacheResult
+	if err := s.kv.View(func(tx kv.Tx) error {
+		v, err := tx.Get(key(resultPrefix, id, resultID))
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return errors.WithStack(solver.ErrNotFound)
+		}
+		return json.Unmarshal(v, &res)
+	}); err != nil {
+		return solver.CacheResult{}, err
+	}
+	return res, nil
+}
+
+func (s *Store) AddResult(id string, res solver.CacheResult) error {
+	dt, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return s.kv.Update(func(tx kv.Tx) error {
+		if err := tx.Put(key(keyPrefix, id), nil); err != nil {
+			return err
+		}
+		if err := tx.Put(key(resultKeyPrefix, id), nil); err != nil {
+			return err
+		}
+		if err := tx.Put(key(resultPrefix, id, res.ID), dt); err != nil {
+			return err
+		}
+		return tx.Put(key(byResultPrefix, res.ID, id), nil)
+	})
+}
+
+func (s *Store) WalkIDsByResult(resultID string, fn func(string) error) error {
+	var ids []string
+	if err := s.kv.View(func(tx kv.Tx) error {
+		var err error
+		ids, err = scanSuffixes(tx, scanPrefix(byResultPrefix, resultID))
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) Release(resultID string) error {
+	return s.kv.Update(func(tx kv.Tx) error {
+		ids, err := scanSuffixes(tx, scanPrefix(byResultPrefix, resultID))
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return errors.WithStack(solver.ErrNotFound)
+		}
+		for _, id := range ids {
+			if err := s.releaseHelper(tx, id, resultID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) releaseHelper(tx kv.Tx, id, resultID string) error {
+	if !has(tx, key(resultKeyPrefix, id)) {
+		return nil
+	}
+	if err := tx.Delete(key(resultPrefix, id, resultID)); err != nil {
+		return err
+	}
+	if err := tx.Delete(key(byResultPrefix, resultID, id)); err != nil {
+		return err
+	}
+	return s.emptyBranchWithParents(tx, id)
+}
+
+// emptyBranchWithParents removes a key without results and links together
+// with the links to it from its parents. Parents that become empty are
+// removed as well.
+func (s *Store) emptyBranchWithParents(tx kv.Tx, id string) error {
+	if !has(tx, key(resultKeyPrefix, id)) {
+		return nil
+	}
+	for _, prefix := range [][]byte{scanPrefix(resultPrefix, id), scanPrefix(linkPrefix, id)} {
+		empty, err := isEmpty(tx, prefix)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return nil
+		}
+	}
+
+	parents, err := scanSuffixes(tx, scanPrefix(backlinkPrefix, id))
+	if err != nil {
+		return err
+	}
+	for _, parent := range parents {
+		if has(tx, key(keyPrefix, parent)) {
+			links, err := scanSuffixes(tx, scanPrefix(linkPrefix, parent))
+			if err != nil {
+				return err
+			}
+			for _, l := range links {
+				_, target, err := parseLinkKey(l)
+				if err != nil {
+					return err
+				}
+				if target == id {
+					if err := tx.Delete(append(scanPrefix(linkPrefix, parent), l...)); err != nil {
+						return err
+					}
+				}
+			}
+			emptyLinks, err := isEmpty(tx, scanPrefix(linkPrefix, parent))
+			if err != nil {
+				return err
+			}
+			if emptyLinks {
+				emptyResults, err := isEmpty(tx, scanPrefix(resultPrefix, parent))
+				if err != nil {
+					return err
+				}
+				if emptyResults {
+					if err := tx.Delete(key(keyPrefix, parent)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if err := s.emptyBranchWithParents(tx, parent); err != nil {
+			return err
+		}
+		if err := tx.Delete(key(backlinkPrefix, id, parent)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Delete(key(keyPrefix, id)); err != nil {
+		return err
+	}
+	return tx.Delete(key(resultKeyPrefix, id))
+}
+
+func linkKey(link solver.CacheInfoLink, target string) (string, error) {
+	dt, err := json.Marshal(link)
+	if err != nil {
+		return "", err
+	}
+	return string(dt) + "@" + target, nil
+}
+
+func parseLinkKey(k string) (solver.CacheInfoLink, string, error) {
+	var link solver.CacheInfoLink
+	parts := strings.Split(k, "@")
+	if len(parts) != 2 {
+		return link, "", errors.Errorf("invalid key %s", k)
+	}
+	if err := json.Unmarshal([]byte(parts[0]), &link); err != nil {
+		return link, "", err
+	}
+	return link, parts[1], nil
+}
+
+func (s *Store) AddLink(id string, link solver.CacheInfoLink, target string) error {
+	lk, err := linkKey(link, target)
+	if err != nil {
+		return err
+	}
+	return s.kv.Update(func(tx kv.Tx) error {
+		if err := tx.Put(key(keyPrefix, id), nil); err != nil {
+			return err
+		}
+		if err := tx.Put(key(linkPrefix, id, lk), nil); err != nil {
+			return err
+		}
+		return tx.Put(key(backlinkPrefix, target, id), nil)
+	})
+}
+
+func (s *Store) WalkLinksAll(id string, fn func(id string, link solver.CacheInfoLink) error) error {
+	type linkEntry struct {
+		id   string
+		link solver.CacheInfoLink
+	}
+	var links []linkEntry
+	if err := s.kv.View(func(tx kv.Tx) error {
+		keys, err := scanSuffixes(tx, scanPrefix(linkPrefix, id))
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			link, target, err := parseLinkKey(k)
+			if err != nil {
+				return err
+			}
+			// make digest relative to output as not all backends store output separately
+			link.Digest = digest.FromBytes(fmt.Appendf(nil, "%s@%d", link.Digest, link.Output))
+			links = append(links, linkEntry{id: target, link: link})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if err := fn(l.id, l.link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) WalkLinks(id string, link solver.CacheInfoLink, fn func(id string) error) error {
+	lk, err := linkKey(link, "")
+	if err != nil {
+		return err
+	}
+	var links []string
+	if err := s.kv.View(func(tx kv.Tx) error {
+		var err error
+		links, err = scanSuffixes(tx, key(linkPrefix, id, lk))
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) HasLink(id string, link solver.CacheInfoLink, target string) bool {
+	lk, err := linkKey(link, target)
+	if err != nil {
+		return false
+	}
+	var v bool
+	if err := s.kv.View(func(tx kv.Tx) error {
+		v = has(tx, key(linkPrefix, id, lk))
+		return nil
+	}); err != nil {
+		return false
+	}
+	return v
+}
+
+func (s *Store) WalkBacklinks(id string, fn func(id string, link solver.CacheInfoLink) error) error {
+	var outIDs []string
+	var outLinks []solver.CacheInfoLink
+
+	if err := s.kv.View(func(tx kv.Tx) error {
+		parents, err := scanSuffixes(tx, scanPrefix(backlinkPrefix, id))
+		if err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			if !has(tx, key(keyPrefix, parent)) {
+				continue
+			}
+			if err := tx.Scan(scanPrefix(linkPrefix, parent), func(k, _ []byte) error {
+				k = bytes.TrimPrefix(k, scanPrefix(linkPrefix, parent))
+				l, target, err := parseLinkKey(string(k))
+				if err != nil || target != id {
+					return nil
+				}
+				l.Digest = digest.FromBytes(fmt.Appendf(nil, "%s@%d", l.Digest, l.Output))
+				l.Output = 0
+				outIDs = append(outIDs, parent)
+				outLinks = append(outLinks, l)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for i := range outIDs {
+		if err := fn(outIDs[i], outLinks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
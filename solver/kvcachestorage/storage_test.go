@@ -0,0 +1,107 @@
+package kvcachestorage
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/testutil"
+	"github.com/moby/buildkit/util/kv/boltkv"
+	"github.com/moby/buildkit/util/kv/lsm"
+	"github.com/stretchr/testify/require"
+)
+
+func newBoltStore(t *testing.T) *Store {
+	kvs, err := boltkv.Open(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	st := NewStore(kvs)
+	t.Cleanup(func() {
+		require.NoError(t, st.Close())
+	})
+	return st
+}
+
+func newLSMStore(t *testing.T) *Store {
+	// small memtable so that the tests exercise the table files
+	kvs, err := lsm.Open(t.TempDir(), lsm.Opt{MemtableSize: 256})
+	require.NoError(t, err)
+	st := NewStore(kvs)
+	t.Cleanup(func() {
+		require.NoError(t, st.Close())
+	})
+	return st
+}
+
+func TestKVCacheStorage(t *testing.T) {
+	for name, newStore := range map[string]func(*testing.T) *Store{
+		"bolt": newBoltStore,
+		"lsm":  newLSMStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			testutil.RunCacheStorageTests(t, func() solver.CacheKeyStorage {
+				return newStore(t)
+			})
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	for name, newStore := range map[string]func(*testing.T) *Store{
+		"bolt": newBoltStore,
+		"lsm":  newLSMStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			st := newStore(t)
+			for i := range 100 {
+				id := string(rune('a'+i%26)) + string(rune('a'+i/26))
+				require.NoError(t, st.AddResult(id, solver.CacheResult{ID: "res" + id}))
+				if i%2 == 0 {
+					require.NoError(t, st.Release("res"+id))
+				}
+			}
+			require.NoError(t, st.Compact(context.TODO()))
+
+			var ids []string
+			require.NoError(t, st.Walk(func(id string) error {
+				ids = append(ids, id)
+				return nil
+			}))
+			require.Len(t, ids, 50)
+
+			size, err := st.Size()
+			require.NoError(t, err)
+			require.Positive(t, size)
+		})
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src := newBoltStore(t)
+	require.NoError(t, src.AddResult("foo", solver.CacheResult{ID: "foo0"}))
+	require.NoError(t, src.AddResult("bar", solver.CacheResult{ID: "bar0"}))
+	require.NoError(t, src.AddLink("foo", solver.CacheInfoLink{Digest: "dgst"}, "bar"))
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Export(context.TODO(), &buf))
+
+	dst := newLSMStore(t)
+	require.NoError(t, dst.Import(context.TODO(), bytes.NewReader(buf.Bytes())))
+
+	res, err := dst.Load("foo", "foo0")
+	require.NoError(t, err)
+	require.Equal(t, "foo0", res.ID)
+	require.True(t, dst.HasLink("foo", solver.CacheInfoLink{Digest: "dgst"}, "bar"))
+
+	var exported bytes.Buffer
+	require.NoError(t, dst.Export(context.TODO(), &exported))
+	require.Equal(t, buf.Bytes(), exported.Bytes())
+
+	err = dst.Import(context.TODO(), bytes.NewReader([]byte("invalid")))
+	require.Error(t, err)
+
+	// truncated snapshot
+	err = dst.Import(context.TODO(), bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	require.Error(t, err)
+}
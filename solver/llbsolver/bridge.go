@@ -26,6 +26,7 @@ import (
 	"github.com/moby/buildkit/util/network"
 	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/worker"
+	"github.com/moby/buildkit/worker/label"
 	digest "github.com/opencontainers/go-digest"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
@@ -42,6 +43,7 @@ type llbBridge struct {
 	sm                        *session.Manager
 	provenanceStore           *provenanceStore
 	proxyNetwork              bool
+	proxyEgress               *network.EgressPolicy
 
 	executorOnce sync.Once
 	executorErr  error
@@ -142,7 +144,7 @@ func (b *llbBridge) loadResult(ctx context.Context, def *pb.Definition, cacheImp
 	}
 	dpc := &detectPrunedCacheID{}
 
-	edge, err := loadWithProxyNetwork(ctx, def, b.policy(polEngine), b.proxyNetwork, dpc.Load, ValidateEntitlements(ent, w.CDIManager()), WithCacheSources(cms), NormalizeRuntimePlatforms(), WithValidateCaps(), WithLinuxResourcesMetadata())
+	edge, err := loadWithProxyNetwork(ctx, def, b.policy(polEngine), b.proxyNetwork, dpc.Load, ValidateEntitlements(ent, w.CDIManager(), w.SecurityProfiles(), w.Labels()[label.OCIDefaultRuntime]), WithCacheSources(cms), NormalizeRuntimePlatforms(), WithValidateCaps(), WithLinuxResourcesMetadata())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load LLB")
 	}
@@ -174,7 +176,7 @@ func (b *llbBridge) validateEntitlements(p *executor.ProcessInfo) error {
 	if err != nil {
 		return err
 	}
-	if b.proxyNetwork {
+	if b.ProxyNetwork() {
 		switch p.Meta.NetMode {
 		case pb.NetMode_UNSET, pb.NetMode_HOST:
 			if p.Meta.Proxy == nil {
@@ -204,6 +206,11 @@ func (b *llbBridge) Run(ctx context.Context, id string, rootfs executor.Mount, m
 	if policy != nil && process.Meta.Proxy != nil {
 		process.Meta.Proxy.Policy = policy
 	}
+	if process.Meta.Proxy != nil {
+		if err := b.ConfigureProxy(process.Meta.Proxy); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := b.loadExecutor(); err != nil {
 		return nil, err
@@ -222,6 +229,11 @@ func (b *llbBridge) Exec(ctx context.Context, id string, process executor.Proces
 	if policy != nil && process.Meta.Proxy != nil {
 		process.Meta.Proxy.Policy = policy
 	}
+	if process.Meta.Proxy != nil {
+		if err := b.ConfigureProxy(process.Meta.Proxy); err != nil {
+			return err
+		}
+	}
 
 	if err := b.loadExecutor(); err != nil {
 		return err
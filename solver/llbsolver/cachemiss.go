@@ -0,0 +1,116 @@
+package llbsolver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/llbsolver/cachemiss"
+	"github.com/moby/buildkit/util/bklog"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+func (s *Solver) recordCacheInfo(ctx context.Context, j *solver.Job) (*controlapi.Descriptor, func(), error) {
+	dt, err := json.Marshal(cachemiss.NewRecord(j.CacheInfo()))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	w, err := s.history.OpenBlobWriter(ctx, cachemiss.MediaType)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if w != nil {
+			w.Discard()
+		}
+	}()
+	if _, err := w.Write(dt); err != nil {
+		return nil, nil, err
+	}
+	desc, release, err := w.Commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	w = nil
+	return &controlapi.Descriptor{
+		Digest:    string(desc.Digest),
+		Size:      desc.Size,
+		MediaType: desc.MediaType,
+	}, release, nil
+}
+
+func (s *Solver) readCacheInfo(ctx context.Context, desc *controlapi.Descriptor) (*cachemiss.Record, error) {
+	dt, err := s.history.ReadBlob(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	var rec cachemiss.Record
+	if err := json.Unmarshal(dt, &rec); err != nil {
+		return nil, errors.Wrap(err, "failed to parse cache info")
+	}
+	return &rec, nil
+}
+
+// ExplainCacheMiss explains why a vertex of a build did not match the cache
+// by comparing its cache key inputs with the builds that were started before
+// it and are still in the build history.
+func (s *Solver) ExplainCacheMiss(ctx context.Context, ref string, dgst digest.Digest) (*cachemiss.Explanation, error) {
+	var (
+		cur  *controlapi.BuildHistoryRecord
+		prev []*controlapi.BuildHistoryRecord
+	)
+	if err := s.history.Listen(ctx, &controlapi.BuildHistoryRequest{EarlyExit: true}, func(e *controlapi.BuildHistoryEvent) error {
+		switch {
+		case e.Record == nil:
+		case e.Record.Ref == ref:
+			cur = e.Record
+		case e.Record.CacheInfo != nil:
+			prev = append(prev, e.Record)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if cur == nil {
+		return nil, errors.Wrapf(os.ErrNotExist, "build record %s not found", ref)
+	}
+	if cur.CacheInfo == nil {
+		return nil, errors.Errorf("build %s has no cache information, it may still be running or was created by an older version", ref)
+	}
+	rec, err := s.readCacheInfo(ctx, cur.CacheInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	prev = slices.DeleteFunc(prev, func(r *controlapi.BuildHistoryRecord) bool {
+		return !r.CreatedAt.AsTime().Before(cur.CreatedAt.AsTime())
+	})
+	// most recent builds first
+	slices.SortFunc(prev, func(a, b *controlapi.BuildHistoryRecord) int {
+		return b.CreatedAt.AsTime().Compare(a.CreatedAt.AsTime())
+	})
+
+	builds := make([]cachemiss.Build, 0, len(prev))
+	for _, r := range prev {
+		pr, err := s.readCacheInfo(ctx, r.CacheInfo)
+		if err != nil {
+			bklog.G(ctx).Debugf("skipping cache info of build %s: %v", r.Ref, err)
+			continue
+		}
+		builds = append(builds, cachemiss.Build{
+			Ref:       r.Ref,
+			CreatedAt: r.CreatedAt.AsTime(),
+			Record:    pr,
+		})
+	}
+
+	return cachemiss.Explain(cachemiss.Build{
+		Ref:       cur.Ref,
+		CreatedAt: cur.CreatedAt.AsTime(),
+		Record:    rec,
+	}, dgst, builds)
+}
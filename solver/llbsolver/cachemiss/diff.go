@@ -0,0 +1,193 @@
+package cachemiss
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldChange is a field that differs between two messages.
+type fieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// diffMessages returns the fields that differ between two messages of the
+// same type. Fields are named by their path from the root message, e.g.
+// exec.meta.args.
+func diffMessages(a, b protoreflect.Message) []fieldChange {
+	var changes []fieldChange
+	diffMessage("", a, b, &changes)
+	return changes
+}
+
+func diffMessage(prefix string, a, b protoreflect.Message, changes *[]fieldChange) {
+	fields := a.Descriptor().Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		if !a.Has(fd) && !b.Has(fd) {
+			continue
+		}
+		name := joinField(prefix, string(fd.Name()))
+		switch {
+		case fd.IsList():
+			diffList(name, fd, a.Get(fd).List(), b.Get(fd).List(), changes)
+		case fd.IsMap():
+			diffMap(name, fd, a.Get(fd).Map(), b.Get(fd).Map(), changes)
+		case fd.Message() != nil:
+			if a.Has(fd) != b.Has(fd) && fd.ContainingOneof() != nil {
+				*changes = append(*changes, fieldChange{
+					Field: name,
+					Old:   presence(a.Has(fd)),
+					New:   presence(b.Has(fd)),
+				})
+				continue
+			}
+			diffMessage(name, a.Get(fd).Message(), b.Get(fd).Message(), changes)
+		default:
+			av, bv := a.Get(fd), b.Get(fd)
+			if !av.Equal(bv) {
+				*changes = append(*changes, fieldChange{
+					Field: name,
+					Old:   formatValue(fd, av),
+					New:   formatValue(fd, bv),
+				})
+			}
+		}
+	}
+}
+
+func diffList(name string, fd protoreflect.FieldDescriptor, a, b protoreflect.List, changes *[]fieldChange) {
+	if fd.Message() == nil {
+		if listEqual(a, b) {
+			return
+		}
+		// report removed and added items so that a single changed env
+		// variable or argument doesn't print the whole list
+		old, added := listValues(fd, a), listValues(fd, b)
+		removed := slices.DeleteFunc(slices.Clone(old), func(v string) bool {
+			return slices.Contains(added, v)
+		})
+		added = slices.DeleteFunc(added, func(v string) bool {
+			return slices.Contains(old, v)
+		})
+		if len(removed) == 0 && len(added) == 0 {
+			// only the order changed
+			removed, added = old, listValues(fd, b)
+		}
+		*changes = append(*changes, fieldChange{
+			Field: name,
+			Old:   strings.Join(removed, " "),
+			New:   strings.Join(added, " "),
+		})
+		return
+	}
+	n := min(a.Len(), b.Len())
+	for i := range n {
+		diffMessage(fmt.Sprintf("%s[%d]", name, i), a.Get(i).Message(), b.Get(i).Message(), changes)
+	}
+	if a.Len() != b.Len() {
+		*changes = append(*changes, fieldChange{
+			Field: name,
+			Old:   fmt.Sprintf("%d items", a.Len()),
+			New:   fmt.Sprintf("%d items", b.Len()),
+		})
+	}
+}
+
+func diffMap(name string, fd protoreflect.FieldDescriptor, a, b protoreflect.Map, changes *[]fieldChange) {
+	keys := map[string]protoreflect.MapKey{}
+	collect := func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys[k.String()] = k
+		return true
+	}
+	a.Range(collect)
+	b.Range(collect)
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	slices.Sort(sorted)
+
+	vd := fd.MapValue()
+	for _, ks := range sorted {
+		k := keys[ks]
+		field := fmt.Sprintf("%s[%s]", name, ks)
+		if vd.Message() != nil {
+			if a.Has(k) && b.Has(k) {
+				diffMessage(field, a.Get(k).Message(), b.Get(k).Message(), changes)
+			} else {
+				*changes = append(*changes, fieldChange{
+					Field: field,
+					Old:   presence(a.Has(k)),
+					New:   presence(b.Has(k)),
+				})
+			}
+			continue
+		}
+		var old, cur string
+		if a.Has(k) {
+			old = formatValue(vd, a.Get(k))
+		}
+		if b.Has(k) {
+			cur = formatValue(vd, b.Get(k))
+		}
+		if a.Has(k) != b.Has(k) || old != cur {
+			*changes = append(*changes, fieldChange{Field: field, Old: old, New: cur})
+		}
+	}
+}
+
+func listEqual(a, b protoreflect.List) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := range a.Len() {
+		if !a.Get(i).Equal(b.Get(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+func listValues(fd protoreflect.FieldDescriptor, l protoreflect.List) []string {
+	out := make([]string, l.Len())
+	for i := range l.Len() {
+		out[i] = formatValue(fd, l.Get(i))
+	}
+	return out
+}
+
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return fmt.Sprint(v.Enum())
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%q", v.Bytes())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func presence(ok bool) string {
+	if ok {
+		return "set"
+	}
+	return "unset"
+}
+
+func joinField(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,303 @@
+package cachemiss
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// Build is the Record of a build in the build history.
+type Build struct {
+	Ref       string
+	CreatedAt time.Time
+	Record    *Record
+}
+
+type ReasonType string
+
+const (
+	// ReasonOp is a changed field of the LLB operation.
+	ReasonOp ReasonType = "op"
+	// ReasonInput is an input that was replaced by a different vertex.
+	ReasonInput ReasonType = "input"
+	// ReasonContent is a changed content checksum of a path of an input.
+	ReasonContent ReasonType = "content"
+	// ReasonIgnoreCache is a vertex that does not use the cache.
+	ReasonIgnoreCache ReasonType = "ignore-cache"
+	// ReasonNew is a vertex without any similar vertex in previous builds.
+	ReasonNew ReasonType = "new"
+	// ReasonUnchanged means that no changed cache key input was found, so
+	// the cache record of the previous build was probably released.
+	ReasonUnchanged ReasonType = "unchanged"
+)
+
+// Reason is a difference between a vertex and its closest match in a
+// previous build.
+type Reason struct {
+	Type ReasonType
+	// Vertex is the vertex the difference was found in. It is the explained
+	// vertex or one of its ancestors.
+	Vertex digest.Digest
+	Name   string
+	// Field is the changed op field, the input index or the path of a
+	// content checksum.
+	Field string
+	Old   string
+	New   string
+}
+
+type Explanation struct {
+	Vertex digest.Digest
+	Name   string
+	// Cached is set if the vertex was not executed, so there is no cache
+	// miss to explain.
+	Cached bool
+	// PreviousRef and PreviousVertex are the closest match of the vertex
+	// in the previous builds.
+	PreviousRef    string
+	PreviousVertex digest.Digest
+	Reasons        []Reason
+}
+
+// Explain compares a vertex of the current build with the closest vertex in
+// the previous builds and returns the differences in the inputs of their
+// cache keys. Previous builds are searched in the order they are passed, so
+// the most recent build should be first.
+func Explain(cur Build, dgst digest.Digest, previous []Build) (*Explanation, error) {
+	curGraph := newGraph(cur.Record)
+	v, ok := curGraph.vertexes[dgst]
+	if !ok {
+		return nil, errors.Errorf("vertex %s not found in build %s", dgst, cur.Ref)
+	}
+	exp := &Explanation{
+		Vertex: v.Digest,
+		Name:   v.Name,
+		Cached: !v.Executed,
+	}
+	if exp.Cached {
+		return exp, nil
+	}
+
+	var (
+		best      *Vertex
+		bestGraph *graph
+		bestScore int
+	)
+	for _, b := range previous {
+		if b.Record == nil {
+			continue
+		}
+		g := newGraph(b.Record)
+		for _, u := range g.vertexes {
+			score, ok := similarity(curGraph, v, g, u)
+			if !ok {
+				continue
+			}
+			if best == nil || score < bestScore {
+				best, bestGraph, bestScore = u, g, score
+				exp.PreviousRef = b.Ref
+			}
+		}
+		if best != nil && bestScore == 0 {
+			break
+		}
+	}
+	if best == nil {
+		exp.Reasons = append(exp.Reasons, Reason{
+			Type:   ReasonNew,
+			Vertex: v.Digest,
+			Name:   v.Name,
+		})
+		return exp, nil
+	}
+	exp.PreviousVertex = best.Digest
+
+	d := &differ{cur: curGraph, prev: bestGraph, visited: map[digest.Digest]struct{}{}}
+	d.diff(v, best)
+	exp.Reasons = d.reasons
+	if len(exp.Reasons) == 0 {
+		exp.Reasons = append(exp.Reasons, Reason{
+			Type:   ReasonUnchanged,
+			Vertex: v.Digest,
+			Name:   v.Name,
+		})
+	}
+	return exp, nil
+}
+
+// similarity returns the number of differences between v and u that would
+// be reported. The vertexes can only be compared if they are the same kind
+// of operation.
+func similarity(g1 *graph, v *Vertex, g2 *graph, u *Vertex) (int, bool) {
+	if opType(v.Op) != opType(u.Op) {
+		return 0, false
+	}
+	if g1.key(v) == g2.key(u) {
+		return 0, true
+	}
+	score := 1
+	if v.Op != nil && u.Op != nil {
+		score += len(diffMessages(u.Op.ProtoReflect(), v.Op.ProtoReflect()))
+	}
+	if len(v.Inputs) != len(u.Inputs) {
+		score++
+	}
+	for i := range min(len(v.Inputs), len(u.Inputs)) {
+		if g1.inputKey(v.Inputs[i]) != g2.inputKey(u.Inputs[i]) {
+			score++
+		}
+	}
+	if v.Name != u.Name {
+		score++
+	}
+	return score, true
+}
+
+type differ struct {
+	cur     *graph
+	prev    *graph
+	visited map[digest.Digest]struct{}
+	reasons []Reason
+}
+
+func (d *differ) add(v *Vertex, typ ReasonType, field, old, cur string) {
+	d.reasons = append(d.reasons, Reason{
+		Type:   typ,
+		Vertex: v.Digest,
+		Name:   v.Name,
+		Field:  field,
+		Old:    old,
+		New:    cur,
+	})
+}
+
+func (d *differ) diff(v, u *Vertex) {
+	if _, ok := d.visited[v.Digest]; ok {
+		return
+	}
+	d.visited[v.Digest] = struct{}{}
+
+	if v.IgnoreCache {
+		d.add(v, ReasonIgnoreCache, "", "", "")
+	}
+	if v.Op != nil && u.Op != nil {
+		for _, c := range diffMessages(u.Op.ProtoReflect(), v.Op.ProtoReflect()) {
+			d.add(v, ReasonOp, c.Field, c.Old, c.New)
+		}
+	}
+	if len(v.Inputs) != len(u.Inputs) {
+		d.add(v, ReasonOp, "inputs", fmt.Sprintf("%d inputs", len(u.Inputs)), fmt.Sprintf("%d inputs", len(v.Inputs)))
+	}
+	for i := range min(len(v.Inputs), len(u.Inputs)) {
+		vin, uin := v.Inputs[i], u.Inputs[i]
+		vv, uv := d.cur.vertexes[vin.Vertex], d.prev.vertexes[uin.Vertex]
+		if vv == nil || uv == nil {
+			continue
+		}
+		if d.cur.inputKey(vin) != d.prev.inputKey(uin) {
+			d.add(v, ReasonInput, fmt.Sprintf("input %d", i), uv.Name, vv.Name)
+		}
+		d.diffContent(v, i, uin, vin)
+		// an unchanged input can still have a different result if the
+		// cache key of one of its ancestors changed
+		if vv.Executed && opType(vv.Op) == opType(uv.Op) {
+			d.diff(vv, uv)
+		}
+	}
+}
+
+func (d *differ) diffContent(v *Vertex, i int, old, cur Input) {
+	if old.ContentChecksum == cur.ContentChecksum || old.ContentChecksum == "" || cur.ContentChecksum == "" {
+		return
+	}
+	oldPaths := map[string]digest.Digest{}
+	for _, p := range old.Paths {
+		oldPaths[p.Path] = p.Digest
+	}
+	found := false
+	for _, p := range cur.Paths {
+		o, ok := oldPaths[p.Path]
+		delete(oldPaths, p.Path)
+		if ok && o == p.Digest {
+			continue
+		}
+		d.add(v, ReasonContent, p.Path, o.String(), p.Digest.String())
+		found = true
+	}
+	for p, o := range oldPaths {
+		d.add(v, ReasonContent, p, o.String(), "")
+		found = true
+	}
+	if !found {
+		// paths are not recorded for all content checksums
+		d.add(v, ReasonContent, fmt.Sprintf("input %d", i), old.ContentChecksum.String(), cur.ContentChecksum.String())
+	}
+}
+
+// graph indexes the vertexes of a Record and computes keys that identify
+// vertexes with the same operation and inputs across builds.
+type graph struct {
+	vertexes map[digest.Digest]*Vertex
+	keys     map[digest.Digest]digest.Digest
+}
+
+func newGraph(rec *Record) *graph {
+	g := &graph{
+		vertexes: map[digest.Digest]*Vertex{},
+		keys:     map[digest.Digest]digest.Digest{},
+	}
+	if rec == nil {
+		return g
+	}
+	for i := range rec.Vertexes {
+		v := &rec.Vertexes[i]
+		g.vertexes[v.Digest] = v
+	}
+	return g
+}
+
+// key returns a digest of the operation of a vertex and the keys of its
+// inputs. Unlike the vertex digest, it doesn't depend on the local source
+// attributes that are different for every build.
+func (g *graph) key(v *Vertex) digest.Digest {
+	if k, ok := g.keys[v.Digest]; ok {
+		return k
+	}
+	// guard against cycles in invalid records
+	g.keys[v.Digest] = v.Digest
+
+	var sb strings.Builder
+	if v.Op != nil {
+		dt, err := proto.MarshalOptions{Deterministic: true}.Marshal(v.Op)
+		if err == nil {
+			sb.WriteString(digest.FromBytes(dt).String())
+		}
+	}
+	for _, inp := range v.Inputs {
+		sb.WriteString(",")
+		sb.WriteString(g.inputKey(inp).String())
+	}
+	k := digest.FromString(sb.String())
+	g.keys[v.Digest] = k
+	return k
+}
+
+func (g *graph) inputKey(inp Input) digest.Digest {
+	v, ok := g.vertexes[inp.Vertex]
+	if !ok {
+		return inp.Vertex
+	}
+	return digest.FromString(fmt.Sprintf("%s:%d", g.key(v), inp.Index))
+}
+
+func opType(op *pb.Op) string {
+	if op == nil || op.Op == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", op.Op)
+}
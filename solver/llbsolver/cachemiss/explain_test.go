@@ -0,0 +1,140 @@
+package cachemiss
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord(session string, args []string, srcChecksum digest.Digest) *Record {
+	src := &pb.Op{
+		Op: &pb.Op_Source{Source: &pb.SourceOp{
+			Identifier: "local://context",
+			Attrs: map[string]string{
+				pb.AttrLocalSessionID: session,
+				pb.AttrLocalUniqueID:  session,
+			},
+		}},
+	}
+	srcDgst := digest.FromString("src" + session)
+	exec := &pb.Op{
+		Inputs: []*pb.Input{{Digest: string(srcDgst)}},
+		Op: &pb.Op_Exec{Exec: &pb.ExecOp{
+			Meta: &pb.Meta{
+				Args: args,
+				Env:  []string{"PATH=/bin", "FOO=bar"},
+				Cwd:  "/",
+			},
+			Mounts: []*pb.Mount{{Input: 0, Dest: "/", Selector: "/src"}},
+		}},
+	}
+	execDgst := digest.FromString("exec" + session + args[len(args)-1])
+	return NewRecord([]solver.VertexCacheInfo{
+		{
+			Digest:   srcDgst,
+			Name:     "load build context",
+			Sys:      src,
+			Executed: true,
+		},
+		{
+			Digest: execDgst,
+			Name:   "RUN make",
+			Sys:    exec,
+			Inputs: []solver.InputCacheInfo{{
+				Vertex:          srcDgst,
+				ContentChecksum: digest.FromString(string(srcChecksum)),
+				Paths: []solver.PathChecksum{
+					{Path: "/src", Digest: srcChecksum},
+				},
+			}},
+			Executed: true,
+		},
+	})
+}
+
+func findVertex(t *testing.T, rec *Record, name string) *Vertex {
+	for i, v := range rec.Vertexes {
+		if v.Name == name {
+			return &rec.Vertexes[i]
+		}
+	}
+	t.Fatalf("vertex %s not found", name)
+	return nil
+}
+
+func TestNewRecordStripsSourceAttrs(t *testing.T) {
+	rec := testRecord("s1", []string{"make"}, digest.FromString("a"))
+	src := findVertex(t, rec, "load build context")
+	require.Empty(t, src.Op.GetSource().Attrs)
+	require.Nil(t, findVertex(t, rec, "RUN make").Op.Inputs)
+}
+
+func TestExplainOpChange(t *testing.T) {
+	prev := testRecord("s1", []string{"make", "all"}, digest.FromString("a"))
+	cur := testRecord("s2", []string{"make", "test"}, digest.FromString("a"))
+	v := findVertex(t, cur, "RUN make")
+
+	exp, err := Explain(Build{Ref: "cur", Record: cur}, v.Digest, []Build{{Ref: "prev", Record: prev}})
+	require.NoError(t, err)
+	require.False(t, exp.Cached)
+	require.Equal(t, "prev", exp.PreviousRef)
+	require.Equal(t, findVertex(t, prev, "RUN make").Digest, exp.PreviousVertex)
+	require.Equal(t, []Reason{{
+		Type:   ReasonOp,
+		Vertex: v.Digest,
+		Name:   "RUN make",
+		Field:  "exec.meta.args",
+		Old:    "all",
+		New:    "test",
+	}}, exp.Reasons)
+}
+
+func TestExplainContentChange(t *testing.T) {
+	prev := testRecord("s1", []string{"make"}, digest.FromString("a"))
+	cur := testRecord("s2", []string{"make"}, digest.FromString("b"))
+	v := findVertex(t, cur, "RUN make")
+
+	exp, err := Explain(Build{Ref: "cur", Record: cur}, v.Digest, []Build{{Ref: "prev", Record: prev}})
+	require.NoError(t, err)
+	require.Equal(t, []Reason{{
+		Type:   ReasonContent,
+		Vertex: v.Digest,
+		Name:   "RUN make",
+		Field:  "/src",
+		Old:    digest.FromString("a").String(),
+		New:    digest.FromString("b").String(),
+	}}, exp.Reasons)
+}
+
+func TestExplainUnchanged(t *testing.T) {
+	prev := testRecord("s1", []string{"make"}, digest.FromString("a"))
+	cur := testRecord("s2", []string{"make"}, digest.FromString("a"))
+	v := findVertex(t, cur, "RUN make")
+
+	exp, err := Explain(Build{Ref: "cur", Record: cur}, v.Digest, []Build{{Ref: "prev", Record: prev}})
+	require.NoError(t, err)
+	require.Len(t, exp.Reasons, 1)
+	require.Equal(t, ReasonUnchanged, exp.Reasons[0].Type)
+}
+
+func TestExplainNewAndCached(t *testing.T) {
+	cur := testRecord("s2", []string{"make"}, digest.FromString("a"))
+	v := findVertex(t, cur, "RUN make")
+
+	exp, err := Explain(Build{Ref: "cur", Record: cur}, v.Digest, nil)
+	require.NoError(t, err)
+	require.Len(t, exp.Reasons, 1)
+	require.Equal(t, ReasonNew, exp.Reasons[0].Type)
+
+	v.Executed = false
+	exp, err = Explain(Build{Ref: "cur", Record: cur}, v.Digest, nil)
+	require.NoError(t, err)
+	require.True(t, exp.Cached)
+	require.Empty(t, exp.Reasons)
+
+	_, err = Explain(Build{Ref: "cur", Record: cur}, digest.FromString("missing"), nil)
+	require.Error(t, err)
+}
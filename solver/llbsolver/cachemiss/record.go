@@ -0,0 +1,87 @@
+// Package cachemiss records the inputs of the cache keys of a build and
+// explains why a vertex did not match the cache by comparing them with the
+// records of previous builds.
+package cachemiss
+
+import (
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// MediaType is the media type of a Record stored in the build history.
+const MediaType = "application/vnd.buildkit.cacheinfo.v0+json"
+
+// Record contains the cache key inputs of all vertexes of a build.
+type Record struct {
+	Vertexes []Vertex `json:"vertexes,omitempty"`
+}
+
+type Vertex struct {
+	Digest digest.Digest `json:"digest"`
+	Name   string        `json:"name,omitempty"`
+	// Op is the LLB operation without inputs and build specific source
+	// attributes.
+	Op          *pb.Op  `json:"op,omitempty"`
+	Inputs      []Input `json:"inputs,omitempty"`
+	IgnoreCache bool    `json:"ignoreCache,omitempty"`
+	Executed    bool    `json:"executed,omitempty"`
+}
+
+type Input struct {
+	Vertex          digest.Digest `json:"vertex"`
+	Index           int           `json:"index,omitempty"`
+	ContentChecksum digest.Digest `json:"contentChecksum,omitempty"`
+	Paths           []Path        `json:"paths,omitempty"`
+}
+
+type Path struct {
+	Path   string        `json:"path"`
+	Digest digest.Digest `json:"digest"`
+}
+
+// ignoredSourceAttrs change on every build without changing the cache key.
+var ignoredSourceAttrs = []string{
+	pb.AttrLocalSessionID,
+	pb.AttrLocalUniqueID,
+}
+
+// NewRecord converts the cache info of a job to a Record.
+func NewRecord(infos []solver.VertexCacheInfo) *Record {
+	rec := &Record{}
+	for _, info := range infos {
+		v := Vertex{
+			Digest:      info.Digest,
+			Name:        info.Name,
+			IgnoreCache: info.IgnoreCache,
+			Executed:    info.Executed,
+		}
+		if op, ok := info.Sys.(*pb.Op); ok {
+			v.Op = normalizeOp(op)
+		}
+		for _, inp := range info.Inputs {
+			in := Input{
+				Vertex:          inp.Vertex,
+				Index:           int(inp.Index),
+				ContentChecksum: inp.ContentChecksum,
+			}
+			for _, p := range inp.Paths {
+				in.Paths = append(in.Paths, Path{Path: p.Path, Digest: p.Digest})
+			}
+			v.Inputs = append(v.Inputs, in)
+		}
+		rec.Vertexes = append(rec.Vertexes, v)
+	}
+	return rec
+}
+
+func normalizeOp(op *pb.Op) *pb.Op {
+	op = op.CloneVT()
+	op.Inputs = nil
+	if src := op.GetSource(); src != nil {
+		for _, k := range ignoredSourceAttrs {
+			delete(src.Attrs, k)
+		}
+	}
+	return op
+}
@@ -0,0 +1,42 @@
+package llbsolver
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/util/fairshare"
+	digest "github.com/opencontainers/go-digest"
+)
+
+func (b *provenanceBridge) CheckpointScope() (string, error) {
+	return b.llbBridge.CheckpointScope()
+}
+
+// CheckpointScope returns the scope of the checkpoints of the execs run by
+// the builds of the bridge. The scope is derived from the tenants and the
+// entitlements of the builds so that a checkpoint taken by one build is not
+// resumed by a build of another tenant or with other entitlements.
+func (b *llbBridge) CheckpointScope() (string, error) {
+	if b.builder == nil {
+		return "", nil
+	}
+	var tenants []string
+	_ = b.builder.EachValue(context.TODO(), fairshare.JobValueKey, func(v any) error {
+		if v, ok := v.(fairshare.Request); ok && !slices.Contains(tenants, v.Tenant) {
+			tenants = append(tenants, v.Tenant)
+		}
+		return nil
+	})
+	slices.Sort(tenants)
+	ent, err := loadEntitlements(b.builder)
+	if err != nil {
+		return "", err
+	}
+	ents := make([]string, 0, len(ent))
+	for e := range ent {
+		ents = append(ents, string(e))
+	}
+	slices.Sort(ents)
+	return digest.FromString(strings.Join(tenants, ",") + ":" + strings.Join(ents, ",")).String(), nil
+}
@@ -24,6 +24,15 @@ func supportedEntitlements(ents []string) []entitlements.Entitlement {
 		if e == string(entitlements.EntitlementDevice) {
 			out = append(out, entitlements.EntitlementDevice)
 		}
+		if e == string(entitlements.EntitlementSecurityProfile) {
+			out = append(out, entitlements.EntitlementSecurityProfile)
+		}
+		if e == string(entitlements.EntitlementNetworkCustom) {
+			out = append(out, entitlements.EntitlementNetworkCustom)
+		}
+		if e == string(entitlements.EntitlementSecurityRuntime) {
+			out = append(out, entitlements.EntitlementSecurityRuntime)
+		}
 	}
 	return out
 }
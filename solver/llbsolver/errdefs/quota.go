@@ -0,0 +1,34 @@
+package errdefs
+
+import (
+	"fmt"
+)
+
+// DiskQuotaError will be returned when a process failed after it used up the
+// size limit of a mount.
+type DiskQuotaError struct {
+	error
+	// Mount is the destination of the mount in the container.
+	Mount string
+	// Limit is the size limit of the mount in bytes.
+	Limit int64
+}
+
+func (e *DiskQuotaError) Error() string {
+	return fmt.Sprintf("disk quota of %d bytes exceeded for mount %s: %v", e.Limit, e.Mount, e.error)
+}
+
+func (e *DiskQuotaError) Unwrap() error {
+	return e.error
+}
+
+func WithDiskQuotaError(err error, mount string, limit int64) error {
+	if err == nil {
+		return nil
+	}
+	return &DiskQuotaError{
+		error: err,
+		Mount: mount,
+		Limit: limit,
+	}
+}
@@ -0,0 +1,114 @@
+package llbsolver
+
+import (
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	gatewaypb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrorClassNetwork matches errors from failed network connections,
+	// e.g. while fetching git or http sources.
+	ErrorClassNetwork = "network"
+	// ErrorClassTimeout matches attempts that exceeded the timeout of the
+	// vertex.
+	ErrorClassTimeout = "timeout"
+)
+
+// networkErrorMessages are printed by git and other tools that only report
+// network failures in their output.
+var networkErrorMessages = []string{
+	"could not resolve host",
+	"connection refused",
+	"connection reset by peer",
+	"connection timed out",
+	"network is unreachable",
+	"no route to host",
+	"tls handshake timeout",
+	"i/o timeout",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"unexpected disconnect while reading sideband packet",
+	"rpc failed",
+}
+
+var httpServerErrorRe = regexp.MustCompile(`invalid response status 5\d\d`)
+
+func setExecutionPolicy(opt *solver.VertexOptions, ep *pb.ExecutionPolicy) error {
+	if ep.Timeout < 0 {
+		return errors.Errorf("invalid negative timeout %d", ep.Timeout)
+	}
+	opt.Timeout = time.Duration(ep.Timeout)
+	rp := ep.Retry
+	if rp == nil || rp.MaxAttempts <= 1 {
+		return nil
+	}
+	if rp.Backoff < 0 || rp.MaxBackoff < 0 {
+		return errors.Errorf("invalid negative retry backoff")
+	}
+	for _, c := range rp.ErrorClasses {
+		switch c {
+		case ErrorClassNetwork, ErrorClassTimeout:
+		default:
+			return errors.Errorf("invalid retry error class %q", c)
+		}
+	}
+	opt.Retry = &solver.RetryPolicy{
+		MaxAttempts: int(rp.MaxAttempts),
+		Backoff:     time.Duration(rp.Backoff),
+		MaxBackoff:  time.Duration(rp.MaxBackoff),
+	}
+	if len(rp.ExitCodes) > 0 || len(rp.ErrorClasses) > 0 {
+		exitCodes := slices.Clone(rp.ExitCodes)
+		classes := slices.Clone(rp.ErrorClasses)
+		opt.Retry.Retryable = func(err error) bool {
+			return retryableError(err, exitCodes, classes)
+		}
+	}
+	return nil
+}
+
+func retryableError(err error, exitCodes []int32, classes []string) bool {
+	// a timed out attempt keeps the error of the killed process, which is
+	// only retried for the timeout class
+	if solver.IsTimeout(err) {
+		return slices.Contains(classes, ErrorClassTimeout)
+	}
+	var exitErr *gatewaypb.ExitError
+	if errors.As(err, &exitErr) && slices.Contains(exitCodes, int32(exitErr.ExitCode)) {
+		return true
+	}
+	return slices.Contains(classes, ErrorClassNetwork) && isNetworkError(err)
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	for _, errno := range []error{syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.ETIMEDOUT, syscall.EHOSTUNREACH, syscall.ENETUNREACH, syscall.EPIPE} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range networkErrorMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	// http source reports server errors only with their status code
+	return httpServerErrorRe.MatchString(msg)
+}
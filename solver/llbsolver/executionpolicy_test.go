@@ -0,0 +1,68 @@
+package llbsolver
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	gatewaypb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionPolicyRetryable(t *testing.T) {
+	var opt solver.VertexOptions
+	err := setExecutionPolicy(&opt, &pb.ExecutionPolicy{
+		Timeout: int64(time.Minute),
+		Retry: &pb.RetryPolicy{
+			MaxAttempts:  3,
+			Backoff:      int64(time.Second),
+			ExitCodes:    []int32{100},
+			ErrorClasses: []string{ErrorClassNetwork},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, opt.Timeout)
+	require.NotNil(t, opt.Retry)
+	require.Equal(t, 3, opt.Retry.MaxAttempts)
+	require.Equal(t, time.Second, opt.Retry.Backoff)
+
+	retryable := opt.Retry.Retryable
+	require.True(t, retryable(errors.Wrap(&gatewaypb.ExitError{ExitCode: 100}, "process failed")))
+	require.False(t, retryable(&gatewaypb.ExitError{ExitCode: 1}))
+	require.True(t, retryable(errors.Wrap(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, "failed to fetch")))
+	require.True(t, retryable(errors.New("fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com")))
+	require.True(t, retryable(errors.New("invalid response status 503")))
+	require.False(t, retryable(errors.New("invalid response status 404")))
+	require.False(t, retryable(errors.WithStack(&solver.TimeoutError{Timeout: time.Second})))
+	require.False(t, retryable(errors.New("no such file or directory")))
+
+	// the error of a timed out attempt is kept, but the attempt is only
+	// retried for the timeout class
+	timeoutErr := errors.WithStack(&solver.TimeoutError{
+		Timeout: time.Second,
+		Err:     errors.Wrap(&gatewaypb.ExitError{ExitCode: 100, Err: syscall.ETIMEDOUT}, "process failed"),
+	})
+	var exitErr *gatewaypb.ExitError
+	require.ErrorAs(t, timeoutErr, &exitErr)
+	require.ErrorIs(t, timeoutErr, syscall.ETIMEDOUT)
+	require.False(t, retryable(timeoutErr))
+	require.True(t, retryableError(timeoutErr, nil, []string{ErrorClassTimeout}))
+	require.False(t, retryableError(timeoutErr, []int32{100}, []string{ErrorClassNetwork}))
+}
+
+func TestExecutionPolicyInvalid(t *testing.T) {
+	var opt solver.VertexOptions
+	err := setExecutionPolicy(&opt, &pb.ExecutionPolicy{
+		Retry: &pb.RetryPolicy{MaxAttempts: 2, ErrorClasses: []string{"unknown"}},
+	})
+	require.ErrorContains(t, err, "invalid retry error class")
+
+	// a single attempt doesn't need a retry policy
+	opt = solver.VertexOptions{}
+	require.NoError(t, setExecutionPolicy(&opt, &pb.ExecutionPolicy{Retry: &pb.RetryPolicy{MaxAttempts: 1}}))
+	require.Nil(t, opt.Retry)
+}
@@ -0,0 +1,83 @@
+//go:build !windows
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/sys/user"
+	"github.com/stretchr/testify/require"
+	copy "github.com/tonistiigi/fsutil/copy"
+)
+
+func TestFileOpChown(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root")
+	}
+	t.Parallel()
+
+	idmap := &user.IdentityMapping{
+		UIDMaps: []user.IDMap{{ID: 0, ParentID: 100000, Count: 65536}},
+		GIDMaps: []user.IDMap{{ID: 0, ParentID: 200000, Count: 65536}},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		idmap *user.IdentityMapping
+		// root and user are the owners of the files created by the
+		// default user and by 1000:1001
+		root, user [2]uint32
+	}{
+		{
+			// snapshots of a worker with per-exec user namespace remapping
+			// are stored with the IDs of the containers
+			name: "unmapped",
+			root: [2]uint32{0, 0},
+			user: [2]uint32{1000, 1001},
+		},
+		{
+			name:  "mapped",
+			idmap: idmap,
+			root:  [2]uint32{100000, 200000},
+			user:  [2]uint32{101000, 201001},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			u := &copy.User{UID: 1000, GID: 1001}
+
+			src := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(src, "src"), []byte("data"), 0o644))
+			// the source is stored with the same mapping
+			require.NoError(t, os.Lchown(filepath.Join(src, "src"), int(tc.root[0]), int(tc.root[1])))
+			d := t.TempDir()
+
+			require.NoError(t, mkdir(d, &pb.FileActionMkDir{Path: "/a/b", Mode: 0o755, MakeParents: true, Timestamp: -1}, nil, tc.idmap))
+			require.NoError(t, mkdir(d, &pb.FileActionMkDir{Path: "/a/c", Mode: 0o755, Timestamp: -1}, u, tc.idmap))
+			require.NoError(t, mkfile(d, &pb.FileActionMkFile{Path: "/file", Mode: 0o644, Timestamp: -1}, u, tc.idmap))
+			require.NoError(t, symlink(d, &pb.FileActionSymlink{Oldpath: "file", Newpath: "/link", Timestamp: -1}, u, tc.idmap))
+			require.NoError(t, docopy(context.TODO(), src, d, &pb.FileActionCopy{Src: "/src", Dest: "/copy", Mode: -1, Timestamp: -1}, u, tc.idmap))
+			require.NoError(t, docopy(context.TODO(), src, d, &pb.FileActionCopy{Src: "/src", Dest: "/copy-root", Mode: -1, Timestamp: -1}, nil, tc.idmap))
+
+			for p, owner := range map[string][2]uint32{
+				"a":         tc.root,
+				"a/b":       tc.root,
+				"a/c":       tc.user,
+				"file":      tc.user,
+				"link":      tc.user,
+				"copy":      tc.user,
+				"copy-root": tc.root,
+			} {
+				fi, err := os.Lstat(filepath.Join(d, p))
+				require.NoError(t, err)
+				st := fi.Sys().(*syscall.Stat_t)
+				require.Equal(t, owner, [2]uint32{st.Uid, st.Gid}, p)
+			}
+		})
+	}
+}
@@ -204,6 +204,28 @@ func (s *Solver) recordBuildHistory(ctx context.Context, id string, req frontend
 		eg.Go(func() error {
 			return j.Status(ctx2, ch)
 		})
+		eg.Go(func() error {
+			desc, release, err := s.recordCacheInfo(ctx2, j)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			releasers = append(releasers, release)
+			rec.CacheInfo = desc
+			mu.Unlock()
+			return nil
+		})
+		eg.Go(func() error {
+			desc, release, err := s.recordResourceUsage(ctx2, j)
+			if err != nil || desc == nil {
+				return err
+			}
+			mu.Lock()
+			releasers = append(releasers, release)
+			rec.ResourceUsage = desc
+			mu.Unlock()
+			return nil
+		})
 
 		setDeprecated := true
 		for i, descref := range descrefs {
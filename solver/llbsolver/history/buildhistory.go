@@ -451,6 +451,12 @@ func (h *Queue) update(ctx context.Context, rec *controlapi.BuildHistoryRecord)
 		if err := h.addResource(ctx, l, rec.ExternalError, false); err != nil {
 			return err
 		}
+		if err := h.addResource(ctx, l, rec.CacheInfo, false); err != nil {
+			return err
+		}
+		if err := h.addResource(ctx, l, rec.ResourceUsage, false); err != nil {
+			return err
+		}
 		if rec.Result != nil {
 			if err := h.addResource(ctx, l, rec.Result.ResultDeprecated, true); err != nil {
 				return err
@@ -592,6 +598,15 @@ func (h *Queue) OpenBlobWriter(ctx context.Context, mt string) (_ *Writer, err e
 	}, nil
 }
 
+// ReadBlob reads a blob referenced by a build record.
+func (h *Queue) ReadBlob(ctx context.Context, desc *controlapi.Descriptor) ([]byte, error) {
+	return content.ReadBlob(ctx, h.hContentStore, ocispecs.Descriptor{
+		Digest:    digest.Digest(desc.Digest),
+		Size:      desc.Size,
+		MediaType: desc.MediaType,
+	})
+}
+
 type Writer struct {
 	mt string
 	w  content.Writer
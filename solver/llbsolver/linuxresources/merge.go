@@ -37,13 +37,20 @@ func mergeRelaxed(a, b *pb.LinuxResources) *pb.LinuxResources {
 	}
 	period, quota := relaxedCPUBandwidth(a.CpuPeriod, a.CpuQuota, b.CpuPeriod, b.CpuQuota)
 	return &pb.LinuxResources{
-		Memory:     relaxedMemory(a.Memory, b.Memory),
-		MemorySwap: relaxedMemorySwap(a.MemorySwap, b.MemorySwap),
-		CpuShares:  relaxedCPUShares(a.CpuShares, b.CpuShares),
-		CpuPeriod:  period,
-		CpuQuota:   quota,
-		CpusetCpus: relaxedCpuset(a.CpusetCpus, b.CpusetCpus),
-		CpusetMems: relaxedCpuset(a.CpusetMems, b.CpusetMems),
+		Memory:               relaxedMemory(a.Memory, b.Memory),
+		MemorySwap:           relaxedMemorySwap(a.MemorySwap, b.MemorySwap),
+		CpuShares:            relaxedCPUShares(a.CpuShares, b.CpuShares),
+		CpuPeriod:            period,
+		CpuQuota:             quota,
+		CpusetCpus:           relaxedCpuset(a.CpusetCpus, b.CpusetCpus),
+		CpusetMems:           relaxedCpuset(a.CpusetMems, b.CpusetMems),
+		PidsLimit:            relaxedMemory(a.PidsLimit, b.PidsLimit),
+		BlkioWeight:          uint32(relaxedCPUShares(uint64(a.BlkioWeight), uint64(b.BlkioWeight))),
+		BlkioDeviceReadBps:   relaxedThrottle(a.BlkioDeviceReadBps, b.BlkioDeviceReadBps),
+		BlkioDeviceWriteBps:  relaxedThrottle(a.BlkioDeviceWriteBps, b.BlkioDeviceWriteBps),
+		BlkioDeviceReadIOps:  relaxedThrottle(a.BlkioDeviceReadIOps, b.BlkioDeviceReadIOps),
+		BlkioDeviceWriteIOps: relaxedThrottle(a.BlkioDeviceWriteIOps, b.BlkioDeviceWriteIOps),
+		DiskQuota:            relaxedMemory(a.DiskQuota, b.DiskQuota),
 	}
 }
 
@@ -124,6 +131,21 @@ func relaxedCPUBandwidth(periodA uint64, quotaA int64, periodB uint64, quotaB in
 	return periodA, quotaA
 }
 
+// A device is only throttled if both sides throttle it, with the higher rate.
+func relaxedThrottle(a, b []*pb.LinuxThrottleDevice) []*pb.LinuxThrottleDevice {
+	rates := make(map[string]uint64, len(b))
+	for _, d := range b {
+		rates[d.Path] = d.Rate
+	}
+	var out []*pb.LinuxThrottleDevice
+	for _, d := range a {
+		if rate, ok := rates[d.Path]; ok {
+			out = append(out, &pb.LinuxThrottleDevice{Path: d.Path, Rate: max(d.Rate, rate)})
+		}
+	}
+	return out
+}
+
 // Empty = unset (most relaxed); otherwise the union of both sides, re-emitted
 // in canonical form. On parse error, falls back to the side that parsed cleanly.
 func relaxedCpuset(a, b string) string {
@@ -206,6 +206,35 @@ func TestMergeRelaxed(t *testing.T) {
 		require.Equal(t, "0-1", result.CpusetMems) // union of "0" and "0,1"
 	})
 
+	t.Run("pids and disk quota follow memory semantics", func(t *testing.T) {
+		a := &pb.LinuxResources{PidsLimit: 100, DiskQuota: 1 << 30}
+		b := &pb.LinuxResources{PidsLimit: 200}
+		result := mergeRelaxed(a, b)
+		require.Equal(t, int64(200), result.PidsLimit)
+		require.Equal(t, int64(0), result.DiskQuota) // unset on b means unlimited
+	})
+
+	t.Run("device throttled only if both sides throttle it", func(t *testing.T) {
+		a := &pb.LinuxResources{
+			BlkioWeight: 100,
+			BlkioDeviceReadBps: []*pb.LinuxThrottleDevice{
+				{Path: "/dev/sda", Rate: 1000},
+				{Path: "/dev/sdb", Rate: 1000},
+			},
+		}
+		b := &pb.LinuxResources{
+			BlkioWeight: 500,
+			BlkioDeviceReadBps: []*pb.LinuxThrottleDevice{
+				{Path: "/dev/sda", Rate: 2000},
+			},
+		}
+		result := mergeRelaxed(a, b)
+		require.Equal(t, uint32(500), result.BlkioWeight)
+		require.Len(t, result.BlkioDeviceReadBps, 1)
+		require.Equal(t, "/dev/sda", result.BlkioDeviceReadBps[0].Path)
+		require.Equal(t, uint64(2000), result.BlkioDeviceReadBps[0].Rate)
+	})
+
 	t.Run("no pointer aliasing when one side is nil", func(t *testing.T) {
 		b := &pb.LinuxResources{Memory: 64 * 1024 * 1024}
 		result := mergeRelaxed(nil, b)
@@ -2,16 +2,53 @@ package llbsolver
 
 import (
 	"context"
+	"maps"
+	"slices"
 
 	"github.com/moby/buildkit/solver"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/sourcepolicy"
 	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/network"
+	"github.com/moby/buildkit/util/network/proxystore"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 )
 
-const keyProxyNetwork = "llb.proxy-network"
+const (
+	keyProxyNetwork = "llb.proxy-network"
+	keyProxyRecord  = "llb.proxy-record"
+	keyProxyReplay  = "llb.proxy-replay"
+)
+
+// ProxyNetworkRequest configures the proxy network of a build.
+type ProxyNetworkRequest struct {
+	Enabled bool
+	// Record stores the response bodies of successful GET requests in the
+	// content store of the worker.
+	Record bool
+	// Replay serves requests only from recorded responses if it is not nil.
+	// It maps the URLs that can be requested to the digests of the recorded
+	// responses.
+	Replay map[string]digest.Digest
+}
+
+// proxyNetworkForSolve returns true if the solve request uses the proxy
+// network. Egress rules are only enforced by the proxy, so a source policy
+// with egress rules enables it.
+func proxyNetworkForSolve(proxy ProxyNetworkRequest, srcPol *spb.Policy) bool {
+	if proxy.Enabled || proxy.Record || proxy.Replay != nil {
+		return true
+	}
+	return srcPol != nil && len(srcPol.Egress) > 0
+}
+
+// defaultProxyNetwork returns true if all builds use the proxy network. The
+// daemon egress policy enables it as it is enforced by the proxy.
+func (s *Solver) defaultProxyNetwork() bool {
+	return s.proxyNetwork || (s.proxyEgress != nil && len(s.proxyEgress.Rules) > 0)
+}
 
 func proxyNetworkForOp(op *pb.Op, proxyNetwork bool) (bool, error) {
 	exec := op.GetExec()
@@ -20,6 +57,12 @@ func proxyNetworkForOp(op *pb.Op, proxyNetwork bool) (bool, error) {
 	}
 	switch exec.Network {
 	case pb.NetMode_UNSET, pb.NetMode_HOST:
+		if proxyNetwork && exec.NetworkName != "" {
+			return false, errors.Errorf("network %q is not allowed when proxy network is enabled", exec.NetworkName)
+		}
+		if proxyNetwork && len(exec.Services) > 0 {
+			return false, errors.New("services are not allowed when proxy network is enabled")
+		}
 		return proxyNetwork, nil
 	case pb.NetMode_NONE:
 		return false, nil
@@ -35,6 +78,10 @@ func (b *provenanceBridge) ProxyPolicy() (network.ProxyPolicy, error) {
 	return b.llbBridge.ProxyPolicy()
 }
 
+func (b *provenanceBridge) ConfigureProxy(cfg *network.ProxyConfig) error {
+	return b.llbBridge.ConfigureProxy(cfg)
+}
+
 func (b *provenanceBridge) ProxyNetwork() bool {
 	return b.llbBridge.ProxyNetwork()
 }
@@ -75,3 +122,109 @@ func (b *llbBridge) ProxyPolicy() (network.ProxyPolicy, error) {
 	}
 	return b.policy(sourcepolicy.NewEngine(policies)), nil
 }
+
+// ProxyEgress returns the egress policies of the daemon and of the source
+// policies of all builds that share the step. Requests must be allowed by
+// every one of them.
+func (b *llbBridge) ProxyEgress() ([]*network.EgressPolicy, error) {
+	var policies []*network.EgressPolicy
+	if b.proxyEgress != nil && len(b.proxyEgress.Rules) > 0 {
+		policies = append(policies, b.proxyEgress)
+	}
+	if b.builder == nil {
+		return policies, nil
+	}
+	err := b.builder.EachValue(context.TODO(), keySourcePolicy, func(v any) error {
+		x, ok := v.(*spb.Policy)
+		if !ok {
+			return errors.Errorf("invalid source policy %T", v)
+		}
+		p, err := egressPolicy(x.Egress)
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			policies = append(policies, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func egressPolicy(rules []*spb.EgressRule) (*network.EgressPolicy, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	p := &network.EgressPolicy{}
+	for _, r := range rules {
+		if r == nil {
+			return nil, errors.Errorf("invalid nil egress rule")
+		}
+		rule := network.EgressRule{
+			Hosts:        slices.Clone(r.Hosts),
+			PathPrefixes: slices.Clone(r.PathPrefixes),
+			Methods:      slices.Clone(r.Methods),
+		}
+		for _, port := range r.Ports {
+			rule.Ports = append(rule.Ports, int(port))
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ConfigureProxy sets the egress policies and the record and replay options
+// of the builds that share the exec.
+func (b *llbBridge) ConfigureProxy(cfg *network.ProxyConfig) error {
+	egress, err := b.ProxyEgress()
+	if err != nil {
+		return err
+	}
+	cfg.Egress = egress
+	if b.builder == nil {
+		return nil
+	}
+	var record bool
+	_ = b.builder.EachValue(context.TODO(), keyProxyRecord, func(v any) error {
+		if v, ok := v.(bool); ok {
+			record = record || v
+		}
+		return nil
+	})
+	var replay map[string]digest.Digest
+	_ = b.builder.EachValue(context.TODO(), keyProxyReplay, func(v any) error {
+		if v, ok := v.(map[string]digest.Digest); ok {
+			if replay == nil {
+				replay = map[string]digest.Digest{}
+			}
+			maps.Copy(replay, v)
+		}
+		return nil
+	})
+	if !record && replay == nil {
+		return nil
+	}
+	w, err := b.resolveWorker()
+	if err != nil {
+		return err
+	}
+	// recorded responses are owned by the tenants of the builds so that a
+	// build can only replay the responses recorded for its own tenant
+	var owners []string
+	_ = b.builder.EachValue(context.TODO(), fairshare.JobValueKey, func(v any) error {
+		if v, ok := v.(fairshare.Request); ok && !slices.Contains(owners, v.Tenant) {
+			owners = append(owners, v.Tenant)
+		}
+		return nil
+	})
+	cfg.Record = record
+	cfg.Replay = replay
+	cfg.Store = proxystore.New(w.ContentStore(), w.LeaseManager(), owners)
+	return nil
+}
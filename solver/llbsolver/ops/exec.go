@@ -8,24 +8,33 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/containerd/platforms"
 	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/executor"
+	"github.com/moby/buildkit/executor/diskquota"
 	resourcestypes "github.com/moby/buildkit/executor/resources/types"
 	"github.com/moby/buildkit/frontend/gateway/container"
+	"github.com/moby/buildkit/identity"
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/session/secrets"
 	"github.com/moby/buildkit/solver"
+	serrdefs "github.com/moby/buildkit/solver/errdefs"
 	"github.com/moby/buildkit/solver/llbsolver/errdefs"
 	"github.com/moby/buildkit/solver/llbsolver/mounts"
 	"github.com/moby/buildkit/solver/llbsolver/ops/opsutils"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/cachedigest"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/network"
+	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/util/progress/logs"
 	utilsystem "github.com/moby/buildkit/util/system"
 	"github.com/moby/buildkit/worker"
@@ -33,7 +42,6 @@ import (
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sync/semaphore"
 )
 
 const execCacheType = "buildkit.exec.v0"
@@ -47,34 +55,40 @@ type ExecOp struct {
 	w              worker.Worker
 	platform       *pb.Platform
 	numInputs      int
-	parallelism    *semaphore.Weighted
+	parallelism    *fairshare.Scheduler
 	rec            resourcestypes.Recorder
 	digest         digest.Digest
+	vertexName     string
 	linuxResources *pb.LinuxResources
 	proxyNetwork   bool
 	proxyCap       *network.ProxyCapture
+	// checkpointScope returns the scope of the builds running the exec
+	// that checkpoints are restricted to.
+	checkpointScope func() (string, error)
 }
 
 var _ solver.Op = &ExecOp{}
 
-func NewExecOp(v solver.Vertex, op *pb.Op_Exec, platform *pb.Platform, cm cache.Manager, parallelism *semaphore.Weighted, sm *session.Manager, exec executor.Executor, w worker.Worker, linuxResources *pb.LinuxResources, proxyNetwork bool) (*ExecOp, error) {
+func NewExecOp(v solver.Vertex, op *pb.Op_Exec, platform *pb.Platform, cm cache.Manager, parallelism *fairshare.Scheduler, sm *session.Manager, exec executor.Executor, w worker.Worker, linuxResources *pb.LinuxResources, proxyNetwork bool, checkpointScope func() (string, error)) (*ExecOp, error) {
 	if err := opsutils.Validate(&pb.Op{Op: op}); err != nil {
 		return nil, err
 	}
 	name := fmt.Sprintf("exec %s", strings.Join(op.Exec.Meta.Args, " "))
 	return &ExecOp{
-		op:             op.Exec,
-		mm:             mounts.NewMountManager(name, cm, sm),
-		cm:             cm,
-		sm:             sm,
-		exec:           exec,
-		numInputs:      len(v.Inputs()),
-		w:              w,
-		platform:       platform,
-		parallelism:    parallelism,
-		digest:         v.Digest(),
-		linuxResources: linuxResources,
-		proxyNetwork:   proxyNetwork,
+		op:              op.Exec,
+		mm:              mounts.NewMountManager(name, cm, sm),
+		cm:              cm,
+		sm:              sm,
+		exec:            exec,
+		numInputs:       len(v.Inputs()),
+		w:               w,
+		platform:        platform,
+		parallelism:     parallelism,
+		digest:          v.Digest(),
+		vertexName:      v.Name(),
+		linuxResources:  linuxResources,
+		proxyNetwork:    proxyNetwork,
+		checkpointScope: checkpointScope,
 	}, nil
 }
 
@@ -130,6 +144,7 @@ func (e *ExecOp) CacheMap(ctx context.Context, jobCtx solver.JobContext, index i
 		}
 	}
 	op.Meta.ProxyEnv = nil
+	op.Checkpoint = nil
 
 	var p ocispecs.Platform
 	if e.platform != nil {
@@ -386,7 +401,22 @@ func (e *ExecOp) Exec(ctx context.Context, jobCtx solver.JobContext, inputs []so
 		platformOS = e.platform.OS
 	}
 	g := jobCtx.Session()
-	p, err := container.PrepareMounts(ctx, e.mm, e.cm, g, e.op.Meta.Cwd, e.op.Mounts, refs, func(m *pb.Mount, ref cache.ImmutableRef) (cache.MutableRef, error) {
+
+	mounts := e.op.Mounts
+	if solver.IsReproducibleCheck(ctx) {
+		mounts = privateCacheMounts(mounts)
+	}
+	var checkpoint *execCheckpoint
+	if e.checkpointSupported(ctx) {
+		checkpoint, err = e.prepareCheckpoint(ctx, g, refs)
+		if err != nil {
+			return nil, err
+		}
+		defer checkpoint.release(context.TODO())
+		mounts, refs = checkpoint.restoreInputs(mounts, refs, e.w)
+	}
+
+	p, err := container.PrepareMounts(ctx, e.mm, e.cm, g, e.op.Meta.Cwd, mounts, refs, func(m *pb.Mount, ref cache.ImmutableRef) (cache.MutableRef, error) {
 		desc := fmt.Sprintf("mount %s from exec %s", m.Dest, strings.Join(e.op.Meta.Args, " "))
 		return e.cm.New(ctx, ref, g, cache.WithDescription(desc))
 	}, platformOS)
@@ -475,8 +505,23 @@ func (e *ExecOp) Exec(ctx context.Context, jobCtx solver.JobContext, inputs []so
 		CgroupParent:              e.op.Meta.CgroupParent,
 		LinuxResources:            e.linuxResources,
 		NetMode:                   e.op.Network,
+		NetworkName:               e.op.NetworkName,
 		SecurityMode:              e.op.Security,
 		RemoveMountStubsRecursive: e.op.Meta.RemoveMountStubsRecursive,
+		Runtime:                   e.op.Runtime,
+	}
+	if checkpoint != nil {
+		meta.Checkpoint = checkpoint.config(e.op.Checkpoint)
+	}
+	if name := e.op.SecurityProfile; name != "" {
+		var secProfiles *securityprofiles.Manager
+		if e.w != nil {
+			secProfiles = e.w.SecurityProfiles()
+		}
+		meta.SecurityProfile, err = secProfiles.Get(name)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if e.proxyNetwork {
 		meta.Proxy = &network.ProxyConfig{}
@@ -521,19 +566,54 @@ func (e *ExecOp) Exec(ctx context.Context, jobCtx solver.JobContext, inputs []so
 		meta.Proxy.Capture = e.proxyCap
 	}
 
+	limited, err := e.limitMounts(&p, platformOS)
+	if err != nil {
+		return nil, err
+	}
+
+	var services *serviceGroup
+	if len(e.op.Services) > 0 {
+		meta.ExtraHosts = append(meta.ExtraHosts, serviceHosts(e.op.Services)...)
+		services, err = e.startServices(ctx, g, refs, meta)
+		if err != nil {
+			return nil, err
+		}
+		defer services.stop()
+		meta.NetworkFrom = services.networkFrom
+	}
+
 	rec, execErr := e.exec.Run(ctx, "", p.Root, p.Mounts, executor.ProcessInfo{
 		Meta:   meta,
 		Stdin:  nil,
 		Stdout: stdout,
 		Stderr: stderr,
 	}, nil)
+	if services != nil {
+		services.stop()
+	}
 	if e.proxyCap != nil {
 		logProxyRequests(stderr, e.proxyCap.Requests())
+		e.warnProxyDenied(ctx, e.proxyCap.Denied())
+	}
+	if execErr != nil {
+		for _, lm := range limited {
+			if lm.Exceeded() {
+				execErr = errdefs.WithDiskQuotaError(execErr, lm.dest, lm.Limit())
+				break
+			}
+		}
 	}
 
+	commitCtx := ctx
+	checkpointed := errors.Is(execErr, executor.ErrCheckpointed)
+	if checkpointed {
+		// the build may have been cancelled while the process was
+		// checkpointed
+		commitCtx = context.WithoutCancel(ctx)
+	}
 	for i, out := range p.OutputRefs {
 		if mutable, ok := out.Ref.(cache.MutableRef); ok {
-			ref, err := mutable.Commit(ctx)
+			ref, err := mutable.Commit(commitCtx)
 			if err != nil {
 				// Release the outputs already committed in earlier
 				// iterations; an internal commit failure is not a
@@ -552,9 +632,85 @@ func (e *ExecOp) Exec(ctx context.Context, jobCtx solver.JobContext, inputs []so
 		p.OutputRefs[i].Ref = nil
 	}
 	e.rec = rec
+	if checkpointed {
+		id, err := checkpoint.store(commitCtx, p.OutputRefs, results)
+		if err != nil {
+			return results, errors.Wrapf(err, "failed to store checkpoint of process %q", strings.Join(e.op.Meta.Args, " "))
+		}
+		return results, serrdefs.WithCheckpointed(errors.Wrapf(execErr, "process %q will be resumed by the next build", strings.Join(e.op.Meta.Args, " ")), id)
+	}
 	return results, errors.Wrapf(execErr, "process %q did not complete successfully", strings.Join(e.op.Meta.Args, " "))
 }
 
+// privateCacheMounts returns the mounts with the writable cache mounts
+// without outputs replaced by new mounts of their base, so that a second
+// execution of the exec doesn't see the changes of the first one.
+func privateCacheMounts(mounts []*pb.Mount) []*pb.Mount {
+	out := make([]*pb.Mount, len(mounts))
+	for i, m := range mounts {
+		if m.MountType == pb.MountType_CACHE && !m.Readonly && m.Output == int64(pb.SkipOutput) {
+			m = &pb.Mount{
+				Input:     m.Input,
+				Selector:  m.Selector,
+				Dest:      m.Dest,
+				Output:    m.Output,
+				MountType: pb.MountType_BIND,
+			}
+		}
+		out[i] = m
+	}
+	return out
+}
+
+type limitedMount struct {
+	*diskquota.Mountable
+	dest string
+}
+
+// limitMounts applies the disk quota to the writable root mount and the size
+// limits of cache mounts.
+func (e *ExecOp) limitMounts(p *container.PreparedMounts, platformOS string) ([]limitedMount, error) {
+	var dq *diskquota.Controller
+	if e.w != nil {
+		dq = e.w.DiskQuota()
+	}
+	var limited []limitedMount
+	limit := func(m *executor.Mount, dest string, size int64, shared bool) error {
+		if dq == nil {
+			return errors.Errorf("size limit for mount %s is not supported by the worker", dest)
+		}
+		lm := dq.Limit(m.Src, size, shared)
+		m.Src = lm
+		limited = append(limited, limitedMount{Mountable: lm, dest: dest})
+		return nil
+	}
+
+	if size := e.linuxResources.GetDiskQuota(); size > 0 && !p.ReadonlyRootFS {
+		if err := limit(&p.Root, pb.RootMount, size, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range e.op.Mounts {
+		size := m.CacheOpt.GetSizeLimit()
+		if m.MountType != pb.MountType_CACHE || m.Readonly || size <= 0 {
+			continue
+		}
+		dest := m.Dest
+		if !utilsystem.IsAbs(filepath.Clean(dest), platformOS) {
+			dest = filepath.Join("/", e.op.Meta.Cwd, dest)
+		}
+		for i := range p.Mounts {
+			if p.Mounts[i].Dest != dest {
+				continue
+			}
+			if err := limit(&p.Mounts[i], dest, size, m.CacheOpt.Sharing == pb.CacheSharingOpt_SHARED); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return limited, nil
+}
+
 func logProxyRequests(w io.Writer, requests []network.ProxyRequest) {
 	if len(requests) == 0 {
 		return
@@ -569,6 +725,33 @@ func logProxyRequests(w io.Writer, requests []network.ProxyRequest) {
 	}
 }
 
+// warnProxyDenied reports the requests that were rejected by the egress or
+// source policy as build warnings of the step.
+func (e *ExecOp) warnProxyDenied(ctx context.Context, denied []network.ProxyDenied) {
+	if len(denied) == 0 {
+		return
+	}
+	pw, ok, _ := progress.NewFromContext(ctx)
+	if !ok {
+		return
+	}
+	defer pw.Close()
+	seen := map[string]struct{}{}
+	for _, d := range denied {
+		k := d.Method + " " + d.URL
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		pw.Write(identity.NewID(), client.VertexWarning{
+			Vertex: e.digest,
+			Level:  1,
+			Short:  []byte(fmt.Sprintf("proxy network request %s denied in step %q", k, e.vertexName)),
+			Detail: [][]byte{[]byte(d.Reason)},
+		})
+	}
+}
+
 func proxyEnvList(p *pb.ProxyEnv) []string {
 	out := []string{}
 	if v := p.HttpProxy; v != "" {
@@ -593,13 +776,15 @@ func (e *ExecOp) Acquire(ctx context.Context) (solver.ReleaseFunc, error) {
 	if e.parallelism == nil {
 		return func() {}, nil
 	}
-	err := e.parallelism.Acquire(ctx, 1)
+	release, err := e.parallelism.Acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return func() {
-		e.parallelism.Release(1)
-	}, nil
+	return release, nil
+}
+
+func (e *ExecOp) CompareResults(ctx context.Context, g session.Group, a, b solver.Result, epoch *time.Time) ([]string, error) {
+	return opsutils.CompareResults(ctx, g, a, b, epoch)
 }
 
 func (e *ExecOp) loadSecretEnv(ctx context.Context, g session.Group) ([]string, error) {
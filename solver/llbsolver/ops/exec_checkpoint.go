@@ -0,0 +1,345 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/executor"
+	"github.com/moby/buildkit/frontend/gateway/container"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/snapshot"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/worker"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+const (
+	keyExecCheckpoint       = "exec.checkpoint"
+	keyExecCheckpointImage  = "exec.checkpoint.image"
+	keyExecCheckpointMounts = "exec.checkpoint.mounts"
+	execCheckpointIndex     = "exec.checkpoint:"
+)
+
+// execCheckpoint is the checkpoint an exec is resumed from and the one its
+// process is checkpointed to. Checkpoints are stored as merge records of the
+// CRIU image and the output mounts of the exec at the time of the checkpoint,
+// so that the snapshots of the output mounts are kept as long as the
+// checkpoint. The records are indexed by the digest of the exec, the IDs of
+// its inputs and the scope of the builds that run it.
+type execCheckpoint struct {
+	key  string
+	desc string
+	cm   cache.Manager
+
+	image     cache.MutableRef
+	imagePath string
+	unmount   func() error
+
+	// record is the checkpoint the exec is resumed from. It holds restore
+	// and restoreMounts.
+	record         cache.ImmutableRef
+	restore        cache.ImmutableRef
+	restorePath    string
+	restoreUnmount func() error
+	// restoreMounts replace the inputs of the output mounts of the exec when
+	// it is resumed.
+	restoreMounts map[int]cache.ImmutableRef
+}
+
+// checkpointSupported reports whether the exec can be checkpointed by the
+// executor. Writable mounts without outputs and writable cache mounts can't
+// be checkpointed as their changes aren't part of the checkpoint. Execs with secrets can't be checkpointed as the
+// memory of the process stored in the checkpoint may contain them.
+func (e *ExecOp) checkpointSupported(ctx context.Context) bool {
+	if e.op.Checkpoint == nil {
+		return false
+	}
+	if c, ok := e.exec.(executor.Checkpointer); !ok || !c.CheckpointSupported() {
+		bklog.G(ctx).Warnf("checkpointing is not supported by the worker, running %q without checkpoint", strings.Join(e.op.Meta.Args, " "))
+		return false
+	}
+	if len(e.op.Secretenv) > 0 {
+		bklog.G(ctx).Warnf("checkpointing is not supported for execs with secret environment variables, running %q without checkpoint", strings.Join(e.op.Meta.Args, " "))
+		return false
+	}
+	for _, m := range e.op.Mounts {
+		switch m.MountType {
+		case pb.MountType_SECRET, pb.MountType_SSH:
+			bklog.G(ctx).Warnf("checkpointing is not supported for execs with secret or SSH mounts, running %q without checkpoint", strings.Join(e.op.Meta.Args, " "))
+			return false
+		}
+		if m.MountType == pb.MountType_BIND && m.Output == int64(pb.SkipOutput) && !m.Readonly {
+			bklog.G(ctx).Warnf("checkpointing is not supported for writable mount %s without output", m.Dest)
+			return false
+		}
+		if m.MountType == pb.MountType_CACHE && !m.Readonly {
+			bklog.G(ctx).Warnf("checkpointing is not supported for writable cache mount %s", m.Dest)
+			return false
+		}
+	}
+	return true
+}
+
+// checkpointKey returns the key of the checkpoints of the exec with refs as
+// inputs. The key is scoped to the builds running the exec and the shared
+// keys of their client sessions, so that a checkpoint is only resumed by
+// builds with the same tenant, entitlements and client.
+func (e *ExecOp) checkpointKey(ctx context.Context, g session.Group, refs []*worker.WorkerRef) (string, error) {
+	ids := make([]string, len(refs))
+	for i, r := range refs {
+		if r != nil && r.ImmutableRef != nil {
+			ids[i] = r.ImmutableRef.ID()
+		}
+	}
+	var scope string
+	if e.checkpointScope != nil {
+		var err error
+		scope, err = e.checkpointScope()
+		if err != nil {
+			return "", err
+		}
+	}
+	var sharedKeys []string
+	if e.sm != nil && g != nil {
+		it := g.SessionIterator()
+		for id := it.NextSession(); id != ""; id = it.NextSession() {
+			c, err := e.sm.Get(ctx, id, true)
+			if err != nil || c == nil {
+				continue
+			}
+			if k := c.SharedKey(); !slices.Contains(sharedKeys, k) {
+				sharedKeys = append(sharedKeys, k)
+			}
+		}
+	}
+	slices.Sort(sharedKeys)
+	return digest.FromString(strings.Join([]string{
+		e.digest.String(),
+		strings.Join(ids, ","),
+		scope,
+		strings.Join(sharedKeys, ","),
+	}, ":")).Encoded(), nil
+}
+
+// prepareCheckpoint loads the latest checkpoint of the exec with refs as
+// inputs and creates the record the next checkpoint is written to. A
+// checkpoint is consumed when it is loaded so that a process is never
+// resumed twice.
+func (e *ExecOp) prepareCheckpoint(ctx context.Context, g session.Group, refs []*worker.WorkerRef) (_ *execCheckpoint, err error) {
+	key, err := e.checkpointKey(ctx, g, refs)
+	if err != nil {
+		return nil, err
+	}
+	cp := &execCheckpoint{
+		key:  key,
+		desc: fmt.Sprintf("checkpoint of exec %s", strings.Join(e.op.Meta.Args, " ")),
+		cm:   e.cm,
+	}
+	defer func() {
+		if err != nil {
+			cp.release(context.TODO())
+		}
+	}()
+
+	mds, err := e.cm.Search(ctx, execCheckpointIndex+cp.key, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, md := range mds {
+		if err := md.ClearValueAndIndex(keyExecCheckpoint, execCheckpointIndex); err != nil {
+			return nil, err
+		}
+		// consumed checkpoints are no longer listed and are removed by the
+		// next prune
+		if err := md.SetRecordType(client.UsageRecordTypeInternal); err != nil {
+			return nil, err
+		}
+		if cp.record != nil {
+			continue
+		}
+		if err := e.loadCheckpoint(ctx, g, cp, md); err != nil {
+			bklog.G(ctx).Warnf("failed to load checkpoint %s, running %q from the start: %v", md.ID(), strings.Join(e.op.Meta.Args, " "), err)
+			cp.releaseRestore(context.TODO())
+		}
+	}
+
+	// the image is listed through the merge record of the checkpoint
+	cp.image, err = e.cm.New(ctx, nil, g, cache.WithDescription(cp.desc), cache.WithRecordType(client.UsageRecordTypeInternal))
+	if err != nil {
+		return nil, err
+	}
+	cp.imagePath, cp.unmount, err = mountLocal(ctx, cp.image, false, g)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (e *ExecOp) loadCheckpoint(ctx context.Context, g session.Group, cp *execCheckpoint, md cache.RefMetadata) (err error) {
+	cp.record, err = e.cm.Get(ctx, md.ID(), nil)
+	if err != nil {
+		return err
+	}
+	imageID := md.GetString(keyExecCheckpointImage)
+	if imageID == "" {
+		return errors.Errorf("checkpoint %s has no image", md.ID())
+	}
+	cp.restore, err = e.cm.Get(ctx, imageID, nil)
+	if err != nil {
+		return err
+	}
+	cp.restoreMounts = make(map[int]cache.ImmutableRef)
+	for _, v := range strings.Split(md.GetString(keyExecCheckpointMounts), ",") {
+		idx, id, ok := strings.Cut(v, "=")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(idx)
+		if err != nil || i < 0 || i >= len(e.op.Mounts) {
+			return errors.Errorf("invalid checkpoint mount %q", v)
+		}
+		ref, err := e.cm.Get(ctx, id, nil)
+		if err != nil {
+			return err
+		}
+		cp.restoreMounts[i] = ref
+	}
+	cp.restorePath, cp.restoreUnmount, err = mountLocal(ctx, cp.restore, true, g)
+	return err
+}
+
+// restoreInputs returns the mounts and inputs of the exec with the output
+// mounts replaced by their state at the time of the checkpoint.
+func (cp *execCheckpoint) restoreInputs(mounts []*pb.Mount, refs []*worker.WorkerRef, w worker.Worker) ([]*pb.Mount, []*worker.WorkerRef) {
+	if cp.restore == nil {
+		return mounts, refs
+	}
+	mounts = append([]*pb.Mount(nil), mounts...)
+	refs = append([]*worker.WorkerRef(nil), refs...)
+	for i, ref := range cp.restoreMounts {
+		m := mounts[i].CloneVT()
+		m.Input = int64(len(refs))
+		mounts[i] = m
+		refs = append(refs, &worker.WorkerRef{ImmutableRef: ref, Worker: w})
+	}
+	return mounts, refs
+}
+
+// config returns the checkpoint config of the executor.
+func (cp *execCheckpoint) config(op *pb.CheckpointOpt) *executor.CheckpointConfig {
+	return &executor.CheckpointConfig{
+		Timeout:     time.Duration(op.Timeout) * time.Second,
+		ImagePath:   cp.imagePath,
+		RestorePath: cp.restorePath,
+	}
+}
+
+// store commits the checkpoint image and merges it with the output mounts of
+// the exec into the checkpoint record, so that the next run of the exec with
+// the same inputs can resume from it. It returns the ID of the record.
+func (cp *execCheckpoint) store(ctx context.Context, outputs []container.MountRef, results []solver.Result) (string, error) {
+	if err := cp.unmount(); err != nil {
+		return "", err
+	}
+	cp.unmount = nil
+	image, err := cp.image.Commit(ctx)
+	if err != nil {
+		return "", err
+	}
+	cp.image = nil
+	defer image.Release(context.TODO())
+	if err := image.Finalize(ctx); err != nil {
+		return "", err
+	}
+
+	parents := []cache.ImmutableRef{image}
+	mounts := make([]string, 0, len(results))
+	for i, res := range results {
+		ref, ok := res.Sys().(*worker.WorkerRef)
+		if !ok || ref.ImmutableRef == nil {
+			continue
+		}
+		if err := ref.ImmutableRef.Finalize(ctx); err != nil {
+			return "", err
+		}
+		parents = append(parents, ref.ImmutableRef)
+		mounts = append(mounts, fmt.Sprintf("%d=%s", outputs[i].MountIndex, ref.ImmutableRef.ID()))
+	}
+	// the merge record is never mounted, it only keeps the image and the
+	// output mounts from being pruned before the checkpoint
+	record, err := cp.cm.Merge(ctx, parents, nil)
+	if err != nil {
+		return "", err
+	}
+	defer record.Release(context.TODO())
+	if err := record.SetDescription(cp.desc); err != nil {
+		return "", err
+	}
+	if err := record.SetRecordType(client.UsageRecordTypeCheckpoint); err != nil {
+		return "", err
+	}
+	if err := record.SetString(keyExecCheckpointImage, image.ID(), ""); err != nil {
+		return "", err
+	}
+	if err := record.SetString(keyExecCheckpointMounts, strings.Join(mounts, ","), ""); err != nil {
+		return "", err
+	}
+	if err := record.SetString(keyExecCheckpoint, cp.key, execCheckpointIndex+cp.key); err != nil {
+		return "", err
+	}
+	return record.ID(), nil
+}
+
+func (cp *execCheckpoint) releaseRestore(ctx context.Context) {
+	if cp.restoreUnmount != nil {
+		cp.restoreUnmount()
+		cp.restoreUnmount = nil
+	}
+	for _, ref := range cp.restoreMounts {
+		ref.Release(ctx)
+	}
+	cp.restoreMounts = nil
+	if cp.restore != nil {
+		cp.restore.Release(ctx)
+		cp.restore = nil
+	}
+	if cp.record != nil {
+		cp.record.Release(ctx)
+		cp.record = nil
+	}
+	cp.restorePath = ""
+}
+
+func (cp *execCheckpoint) release(ctx context.Context) {
+	cp.releaseRestore(ctx)
+	if cp.unmount != nil {
+		cp.unmount()
+		cp.unmount = nil
+	}
+	if cp.image != nil {
+		cp.image.Release(ctx)
+		cp.image = nil
+	}
+}
+
+func mountLocal(ctx context.Context, ref cache.Ref, readonly bool, g session.Group) (string, func() error, error) {
+	mounts, err := ref.Mount(ctx, readonly, g)
+	if err != nil {
+		return "", nil, err
+	}
+	lm := snapshot.LocalMounter(mounts)
+	dir, err := lm.Mount()
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, lm.Unmount, nil
+}
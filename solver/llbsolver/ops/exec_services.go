@@ -0,0 +1,222 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/executor"
+	"github.com/moby/buildkit/frontend/gateway/container"
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/progress/logs"
+	utilsystem "github.com/moby/buildkit/util/system"
+	"github.com/moby/buildkit/worker"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultServiceReadyTimeout = time.Minute
+	serviceReadyInterval       = 500 * time.Millisecond
+)
+
+// serviceGroup is the set of running service containers of an exec.
+type serviceGroup struct {
+	cancel   context.CancelCauseFunc
+	services []*runningService
+	roots    []cache.MutableRef
+	// networkFrom is the id of the container that owns the network namespace
+	// shared by the services and the process.
+	networkFrom string
+	stopOnce    sync.Once
+}
+
+type runningService struct {
+	done           chan struct{}
+	err            error
+	stdout, stderr io.WriteCloser
+}
+
+// serviceHosts returns the host entries that resolve the names of the
+// services to the loopback address of the shared network namespace.
+func serviceHosts(services []*pb.ServiceContainer) []executor.HostIP {
+	hosts := make([]executor.HostIP, 0, len(services))
+	for _, svc := range services {
+		hosts = append(hosts, executor.HostIP{Host: svc.Name, IP: net.IPv4(127, 0, 0, 1)})
+	}
+	return hosts
+}
+
+// startServices starts the service containers of the exec one by one and
+// waits for each of them to become ready. The services join the network
+// namespace of the first one.
+func (e *ExecOp) startServices(ctx context.Context, g session.Group, refs []*worker.WorkerRef, base executor.Meta) (_ *serviceGroup, err error) {
+	sctx, cancel := context.WithCancelCause(ctx)
+	sg := &serviceGroup{cancel: cancel}
+	defer func() {
+		if err != nil {
+			sg.stop()
+		}
+	}()
+	for _, svc := range e.op.Services {
+		if err := e.startService(ctx, sctx, sg, g, refs, svc, base); err != nil {
+			return nil, err
+		}
+	}
+	return sg, nil
+}
+
+func (e *ExecOp) startService(ctx, sctx context.Context, sg *serviceGroup, g session.Group, refs []*worker.WorkerRef, svc *pb.ServiceContainer, base executor.Meta) error {
+	if int(svc.Input) >= len(refs) {
+		return errors.Errorf("invalid input %d for service %q", svc.Input, svc.Name)
+	}
+	var ref cache.ImmutableRef
+	if r := refs[svc.Input]; r != nil {
+		ref = r.ImmutableRef
+	}
+	desc := fmt.Sprintf("service %s of exec %s", svc.Name, strings.Join(e.op.Meta.Args, " "))
+	root, err := e.cm.New(ctx, ref, g, cache.WithDescription(desc))
+	if err != nil {
+		return err
+	}
+	sg.roots = append(sg.roots, root)
+
+	meta := executor.Meta{
+		Args:                      svc.Meta.Args,
+		Env:                       svc.Meta.Env,
+		Cwd:                       svc.Meta.Cwd,
+		User:                      svc.Meta.User,
+		Hostname:                  svc.Name,
+		ExtraHosts:                base.ExtraHosts,
+		CgroupParent:              base.CgroupParent,
+		NetMode:                   base.NetMode,
+		NetworkName:               base.NetworkName,
+		NetworkFrom:               sg.networkFrom,
+		Runtime:                   base.Runtime,
+		RemoveMountStubsRecursive: true,
+	}
+	if meta.Cwd == "" {
+		meta.Cwd = "/"
+	}
+	var platformOS string
+	if e.platform != nil {
+		platformOS = e.platform.OS
+	}
+	// don't set PATH for Windows. #5445
+	if platformOS != "windows" {
+		meta.Env = addDefaultEnvvar(meta.Env, "PATH", utilsystem.DefaultPathEnv(platformOS))
+	}
+
+	id := identity.NewID()
+	rs := &runningService{done: make(chan struct{})}
+	rs.stdout, rs.stderr, _ = logs.NewLogStreams(ctx, os.Getenv("BUILDKIT_DEBUG_EXEC_OUTPUT") == "1")
+	sg.services = append(sg.services, rs)
+
+	started := make(chan struct{})
+	go func() {
+		defer close(rs.done)
+		_, rs.err = e.exec.Run(sctx, id, container.MountWithSession(root, g), nil, executor.ProcessInfo{
+			Meta:   meta,
+			Stdout: rs.stdout,
+			Stderr: rs.stderr,
+		}, started)
+	}()
+	select {
+	case <-started:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+	select {
+	case <-rs.done:
+		return serviceExitedError(svc.Name, rs.err)
+	default:
+	}
+	if sg.networkFrom == "" {
+		sg.networkFrom = id
+	}
+	return e.waitServiceReady(ctx, id, sg.networkFrom, svc, rs)
+}
+
+// waitServiceReady runs the readiness checks of the service until they
+// succeed. TCP checks dial into the network namespace owned by the container
+// networkFrom.
+func (e *ExecOp) waitServiceReady(ctx context.Context, id, networkFrom string, svc *pb.ServiceContainer, rs *runningService) error {
+	rc := svc.Readiness
+	if rc == nil || rc.TcpPort == 0 && len(rc.Exec) == 0 {
+		return nil
+	}
+	timeout := defaultServiceReadyTimeout
+	if rc.Timeout > 0 {
+		timeout = time.Duration(rc.Timeout) * time.Second
+	}
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := e.checkServiceReady(tctx, id, networkFrom, rc)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-rs.done:
+			return serviceExitedError(svc.Name, rs.err)
+		case <-tctx.Done():
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return errors.Wrapf(err, "service %q did not become ready within %s", svc.Name, timeout)
+		case <-time.After(serviceReadyInterval):
+		}
+	}
+}
+
+func serviceExitedError(name string, err error) error {
+	if err != nil {
+		return errors.Wrapf(err, "service %q exited before it became ready", name)
+	}
+	return errors.Errorf("service %q exited before it became ready", name)
+}
+
+func (e *ExecOp) checkServiceReady(ctx context.Context, id, networkFrom string, rc *pb.ReadinessCheck) error {
+	if rc.TcpPort != 0 {
+		dialer, ok := e.exec.(executor.ContainerDialer)
+		if !ok {
+			return errors.New("tcp readiness checks are not supported by the worker")
+		}
+		conn, err := dialer.DialContainer(ctx, networkFrom, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(rc.TcpPort))))
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	}
+	if len(rc.Exec) > 0 {
+		if err := e.exec.Exec(ctx, id, executor.ProcessInfo{
+			Meta: executor.Meta{Args: rc.Exec},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop kills the service containers and releases their root filesystems.
+func (sg *serviceGroup) stop() {
+	sg.stopOnce.Do(func() {
+		sg.cancel(errors.WithStack(context.Canceled))
+		for _, rs := range sg.services {
+			<-rs.done
+			rs.stdout.Close()
+			rs.stderr.Close()
+		}
+		for _, root := range sg.roots {
+			root.Release(context.TODO())
+		}
+	})
+}
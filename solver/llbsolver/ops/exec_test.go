@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/moby/buildkit/executor"
 	"github.com/moby/buildkit/identity"
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/solver"
@@ -132,6 +133,12 @@ func TestExecOpCacheMap(t *testing.T) {
 			op2:    newExecOp(withNewMount("/foo", withCache(&pb.CacheOpt{ID: "someOtherID", Sharing: 1}))),
 			xMatch: true,
 		},
+		{
+			name:   "checkpoint option should not change the cache key",
+			op1:    newExecOp(withNewMount("/foo")),
+			op2:    newExecOp(withNewMount("/foo"), withCheckpoint(60)),
+			xMatch: true,
+		},
 	}
 
 	ctx := context.Background()
@@ -265,6 +272,65 @@ func TestExecOpContentCache(t *testing.T) {
 	}
 }
 
+type checkpointExecutor struct {
+	executor.Executor
+}
+
+func (checkpointExecutor) CheckpointSupported() bool {
+	return true
+}
+
+func TestExecOpCheckpointSupported(t *testing.T) {
+	ctx := context.Background()
+	withExecutor := func(op *ExecOp) {
+		op.exec = checkpointExecutor{}
+	}
+
+	op := newExecOp(withExecutor, withCheckpoint(60), withNewMount("/", withReadonly()))
+	require.True(t, op.checkpointSupported(ctx))
+
+	op = newExecOp(withCheckpoint(60), withNewMount("/", withReadonly()))
+	require.False(t, op.checkpointSupported(ctx), "executor without checkpoint support")
+
+	op = newExecOp(withExecutor, withCheckpoint(60), withNewMount("/", withReadonly()))
+	op.op.Secretenv = []*pb.SecretEnv{{ID: "token", Name: "TOKEN"}}
+	require.False(t, op.checkpointSupported(ctx), "secret env")
+
+	for _, mt := range []pb.MountType{pb.MountType_SECRET, pb.MountType_SSH} {
+		op = newExecOp(withExecutor, withCheckpoint(60), withNewMount("/", withReadonly()))
+		op.op.Mounts = append(op.op.Mounts, &pb.Mount{Dest: "/run/secret", MountType: mt, Input: int64(pb.Empty), Output: int64(pb.SkipOutput)})
+		require.False(t, op.checkpointSupported(ctx), mt.String())
+	}
+
+	op = newExecOp(withExecutor, withCheckpoint(60), withNewMount("/", withReadonly()))
+	op.op.Mounts = append(op.op.Mounts, &pb.Mount{Dest: "/cache", MountType: pb.MountType_CACHE, Input: int64(pb.Empty), Output: int64(pb.SkipOutput), CacheOpt: &pb.CacheOpt{ID: "cache"}})
+	require.False(t, op.checkpointSupported(ctx), "writable cache mount")
+
+	op.op.Mounts[1].Readonly = true
+	require.True(t, op.checkpointSupported(ctx), "read-only cache mount")
+}
+
+func TestExecOpCheckpointKeyScope(t *testing.T) {
+	ctx := context.Background()
+	withScope := func(scope string) func(*ExecOp) {
+		return func(op *ExecOp) {
+			op.checkpointScope = func() (string, error) {
+				return scope, nil
+			}
+		}
+	}
+
+	k1, err := newExecOp(withScope("a")).checkpointKey(ctx, nil, nil)
+	require.NoError(t, err)
+	k2, err := newExecOp(withScope("a")).checkpointKey(ctx, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, k1, k2)
+
+	k3, err := newExecOp(withScope("b")).checkpointKey(ctx, nil, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, k1, k3)
+}
+
 func newExecOp(opts ...func(*ExecOp)) *ExecOp {
 	op := &ExecOp{op: &pb.ExecOp{Meta: &pb.Meta{}}}
 	for _, opt := range opts {
@@ -273,6 +339,12 @@ func newExecOp(opts ...func(*ExecOp)) *ExecOp {
 	return op
 }
 
+func withCheckpoint(timeout int64) func(*ExecOp) {
+	return func(op *ExecOp) {
+		op.op.Checkpoint = &pb.CheckpointOpt{Timeout: timeout}
+	}
+}
+
 func withEmptyMounts(op *ExecOp) {
 	op.op.Mounts = []*pb.Mount{}
 }
@@ -342,3 +414,20 @@ func (j *jobCtx) ResolverCache() solver.ResolverCache {
 func (j *jobCtx) CompatibilityVersion() (int, error) {
 	return 0, nil
 }
+
+func TestPrivateCacheMounts(t *testing.T) {
+	in := []*pb.Mount{
+		{Dest: "/", Input: 0, Output: 0},
+		{Dest: "/cache", Input: 1, Selector: "/sub", Output: int64(pb.SkipOutput), MountType: pb.MountType_CACHE, CacheOpt: &pb.CacheOpt{ID: "cache"}},
+		{Dest: "/ro", Input: int64(pb.Empty), Output: int64(pb.SkipOutput), Readonly: true, MountType: pb.MountType_CACHE, CacheOpt: &pb.CacheOpt{ID: "ro"}},
+	}
+	out := privateCacheMounts(in)
+	require.Same(t, in[0], out[0])
+	require.Same(t, in[2], out[2])
+	require.Equal(t, pb.MountType_BIND, out[1].MountType)
+	require.Equal(t, int64(1), out[1].Input)
+	require.Equal(t, "/sub", out[1].Selector)
+	require.Equal(t, int64(pb.SkipOutput), out[1].Output)
+	require.False(t, out[1].Readonly)
+	require.Equal(t, pb.MountType_CACHE, in[1].MountType, "input must not be modified")
+}
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/moby/buildkit/cache"
 	"github.com/moby/buildkit/session"
@@ -20,12 +21,12 @@ import (
 	"github.com/moby/buildkit/solver/llbsolver/ops/opsutils"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/cachedigest"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/flightcontrol"
 	"github.com/moby/buildkit/worker"
 	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
-	"golang.org/x/sync/semaphore"
 )
 
 const fileCacheType = "buildkit.file.v0"
@@ -35,10 +36,10 @@ type fileOp struct {
 	w           worker.Worker
 	refManager  *file.RefManager
 	numInputs   int
-	parallelism *semaphore.Weighted
+	parallelism *fairshare.Scheduler
 }
 
-func NewFileOp(v solver.Vertex, op *pb.Op_File, cm cache.Manager, parallelism *semaphore.Weighted, w worker.Worker) (solver.Op, error) {
+func NewFileOp(v solver.Vertex, op *pb.Op_File, cm cache.Manager, parallelism *fairshare.Scheduler, w worker.Worker) (solver.Op, error) {
 	if err := opsutils.Validate(&pb.Op{Op: op}); err != nil {
 		return nil, err
 	}
@@ -202,17 +203,19 @@ func (f *fileOp) Exec(ctx context.Context, jobCtx solver.JobContext, inputs []so
 	return outResults, nil
 }
 
+func (f *fileOp) CompareResults(ctx context.Context, g session.Group, a, b solver.Result, epoch *time.Time) ([]string, error) {
+	return opsutils.CompareResults(ctx, g, a, b, epoch)
+}
+
 func (f *fileOp) Acquire(ctx context.Context) (solver.ReleaseFunc, error) {
 	if f.parallelism == nil {
 		return func() {}, nil
 	}
-	err := f.parallelism.Acquire(ctx, 1)
+	release, err := f.parallelism.Acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return func() {
-		f.parallelism.Release(1)
-	}, nil
+	return release, nil
 }
 
 func addSelector(m map[int][]opsutils.Selector, idx int, sel string, wildcard, followLinks bool, includePatterns, excludePatterns, requiredPaths []string) {
@@ -45,8 +45,9 @@ func NewContentHashFunc(selectors []Selector) solver.ResultBasedCacheFunc {
 
 		for i, sel := range selectors {
 			eg.Go(func() error {
+				p := path.Join("/", sel.Path)
 				dgst, err := contenthash.Checksum(
-					ctx, ref.ImmutableRef, path.Join("/", sel.Path),
+					ctx, ref.ImmutableRef, p,
 					contenthash.ChecksumOpts{
 						Wildcard:        sel.Wildcard,
 						FollowLinks:     sel.FollowLinks,
@@ -60,6 +61,7 @@ func NewContentHashFunc(selectors []Selector) solver.ResultBasedCacheFunc {
 					return errors.Wrapf(err, "failed to calculate checksum of ref %s", ref.ID())
 				}
 				dgsts[i] = []byte(dgst)
+				solver.RecordPathChecksum(ctx, p, dgst)
 				return nil
 			})
 		}
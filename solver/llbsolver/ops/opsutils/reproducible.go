@@ -0,0 +1,279 @@
+package opsutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/cache/contenthash"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/snapshot"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/worker"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// maxDiffs is the number of differences after which the comparison stops.
+const maxDiffs = 20
+
+// CompareResults compares the filesystems of two results of an op and
+// returns the first paths that differ. Modification times are clamped to
+// epoch before they are compared if it is set.
+func CompareResults(ctx context.Context, g session.Group, a, b solver.Result, epoch *time.Time) ([]string, error) {
+	refA, err := immutableRef(a)
+	if err != nil {
+		return nil, err
+	}
+	refB, err := immutableRef(b)
+	if err != nil {
+		return nil, err
+	}
+	if refA == nil || refB == nil {
+		if refA != refB {
+			return []string{"output is empty in only one execution"}, nil
+		}
+		return nil, nil
+	}
+
+	// contents and ownership are equal if the checksums match, so only the
+	// modification times need to be compared then
+	dgstA, err := contenthash.Checksum(ctx, refA, "/", contenthash.ChecksumOpts{}, g)
+	if err != nil {
+		return nil, err
+	}
+	dgstB, err := contenthash.Checksum(ctx, refB, "/", contenthash.ChecksumOpts{}, g)
+	if err != nil {
+		return nil, err
+	}
+
+	dirA, releaseA, err := mountReadonly(ctx, refA, g)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseA()
+	dirB, releaseB, err := mountReadonly(ctx, refB, g)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseB()
+
+	return CompareDirs(ctx, dirA, dirB, CompareOpt{
+		SkipContent: dgstA == dgstB,
+		Epoch:       epoch,
+	})
+}
+
+func immutableRef(res solver.Result) (cache.ImmutableRef, error) {
+	ref, ok := res.Sys().(*worker.WorkerRef)
+	if !ok {
+		return nil, errors.Errorf("invalid reference: %T", res.Sys())
+	}
+	return ref.ImmutableRef, nil
+}
+
+func mountReadonly(ctx context.Context, ref cache.ImmutableRef, g session.Group) (string, func() error, error) {
+	m, err := ref.Mount(ctx, true, g)
+	if err != nil {
+		return "", nil, err
+	}
+	lm := snapshot.LocalMounter(m)
+	dir, err := lm.Mount()
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, lm.Unmount, nil
+}
+
+type CompareOpt struct {
+	// SkipContent only compares the metadata of the files
+	SkipContent bool
+	// Epoch clamps the modification times before they are compared
+	Epoch *time.Time
+}
+
+// CompareDirs compares two directory trees and returns the first paths that
+// differ in type, mode, ownership, modification time or content.
+func CompareDirs(ctx context.Context, a, b string, opt CompareOpt) ([]string, error) {
+	var diffs []string
+	errLimit := errors.New("limit reached")
+	add := func(format string, args ...any) error {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+		if len(diffs) >= maxDiffs {
+			return errLimit
+		}
+		return nil
+	}
+
+	err := filepath.WalkDir(a, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+		rel, err := filepath.Rel(a, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := "/" + filepath.ToSlash(rel)
+		stA, err := fsutil.Stat(p)
+		if err != nil {
+			return err
+		}
+		stB, err := fsutil.Stat(filepath.Join(b, rel))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				if err := add("%s: only in first execution", name); err != nil {
+					return err
+				}
+				if os.FileMode(stA.Mode).IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return err
+		}
+		if msg := compareStat(stA, stB, opt.Epoch); msg != "" {
+			if err := add("%s: %s", name, msg); err != nil {
+				return err
+			}
+		}
+		if !opt.SkipContent && os.FileMode(stA.Mode).IsRegular() && os.FileMode(stB.Mode).IsRegular() {
+			same, err := sameContent(p, filepath.Join(b, rel))
+			if err != nil {
+				return err
+			}
+			if !same {
+				if err := add("%s: content differs", name); err != nil {
+					return err
+				}
+			}
+		}
+		if os.FileMode(stA.Mode).IsDir() != os.FileMode(stB.Mode).IsDir() && os.FileMode(stA.Mode).IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err == nil {
+		err = filepath.WalkDir(b, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return context.Cause(ctx)
+			}
+			rel, err := filepath.Rel(b, p)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				return nil
+			}
+			if _, err := os.Lstat(filepath.Join(a, rel)); err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				if err := add("%s: only in second execution", "/"+filepath.ToSlash(rel)); err != nil {
+					return err
+				}
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil && !errors.Is(err, errLimit) {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+func compareStat(a, b *fstypes.Stat, epoch *time.Time) string {
+	modeA, modeB := os.FileMode(a.Mode), os.FileMode(b.Mode)
+	switch {
+	case modeA.Type() != modeB.Type():
+		return fmt.Sprintf("type differs (%s != %s)", typeString(modeA), typeString(modeB))
+	case modeA != modeB:
+		return fmt.Sprintf("mode differs (%s != %s)", modeA, modeB)
+	case a.Uid != b.Uid || a.Gid != b.Gid:
+		return fmt.Sprintf("ownership differs (%d:%d != %d:%d)", a.Uid, a.Gid, b.Uid, b.Gid)
+	case a.Linkname != b.Linkname:
+		return fmt.Sprintf("link target differs (%s != %s)", a.Linkname, b.Linkname)
+	case a.Devmajor != b.Devmajor || a.Devminor != b.Devminor:
+		return fmt.Sprintf("device differs (%d:%d != %d:%d)", a.Devmajor, a.Devminor, b.Devmajor, b.Devminor)
+	case modeA.IsRegular() && a.Size != b.Size:
+		return fmt.Sprintf("size differs (%d != %d)", a.Size, b.Size)
+	}
+	mtimeA, mtimeB := clampTime(a.ModTime, epoch), clampTime(b.ModTime, epoch)
+	if !mtimeA.Equal(mtimeB) {
+		return fmt.Sprintf("mtime differs (%s != %s)", mtimeA.UTC().Format(time.RFC3339Nano), mtimeB.UTC().Format(time.RFC3339Nano))
+	}
+	return ""
+}
+
+func clampTime(ns int64, epoch *time.Time) time.Time {
+	t := time.Unix(0, ns)
+	if epoch != nil && t.After(*epoch) {
+		return *epoch
+	}
+	return t
+}
+
+func typeString(m os.FileMode) string {
+	switch {
+	case m.IsDir():
+		return "directory"
+	case m.IsRegular():
+		return "file"
+	case m&os.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return m.Type().String()
+	}
+}
+
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 32*1024)
+	bufB := make([]byte, 32*1024)
+	for {
+		nA, errA := io.ReadFull(fa, bufA)
+		nB, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		eofA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		eofB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if eofA || eofB {
+			return eofA == eofB, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package opsutils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareDirs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	a := t.TempDir()
+	b := t.TempDir()
+
+	epoch := time.Unix(1000, 0)
+	before := time.Unix(500, 0)
+
+	for _, dir := range []string{a, b} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "same"), []byte("foo"), 0644))
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(a, "content"), []byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(b, "content"), []byte("bar"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(a, "mode"), []byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(b, "mode"), []byte("foo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(a, "sub", "onlya"), []byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(b, "onlyb"), []byte("foo"), 0644))
+
+	// all files and directories are newer than the epoch except for "old"
+	for _, dir := range []string{a, b} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "old"), []byte("foo"), 0644))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(a, "old"), before, before))
+	require.NoError(t, os.Chtimes(filepath.Join(b, "old"), before.Add(time.Second), before.Add(time.Second)))
+
+	diffs, err := CompareDirs(ctx, a, b, CompareOpt{Epoch: &epoch})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"/content: content differs",
+		"/mode: mode differs (-rw-r--r-- != -rwxr-xr-x)",
+		"/old: mtime differs (1970-01-01T00:08:20Z != 1970-01-01T00:08:21Z)",
+		"/sub/onlya: only in first execution",
+		"/onlyb: only in second execution",
+	}, diffs)
+
+	diffs, err = CompareDirs(ctx, a, b, CompareOpt{Epoch: &epoch, SkipContent: true})
+	require.NoError(t, err)
+	require.NotContains(t, diffs, "/content: content differs")
+
+	diffs, err = CompareDirs(ctx, a, a, CompareOpt{})
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+}
@@ -40,6 +40,32 @@ func Validate(op *pb.Op) error {
 		if !isRoot {
 			return errors.Errorf("invalid exec op with no rootfs")
 		}
+		if op.Exec.NetworkName != "" && op.Exec.Network != pb.NetMode_UNSET {
+			return errors.Errorf("invalid exec op with network %q and network mode %s", op.Exec.NetworkName, op.Exec.Network)
+		}
+		// services would bind on the host loopback and clash with the ports
+		// of the host
+		if len(op.Exec.Services) > 0 && op.Exec.Network == pb.NetMode_HOST {
+			return errors.Errorf("invalid exec op with services and network mode %s", op.Exec.Network)
+		}
+		names := make(map[string]struct{}, len(op.Exec.Services))
+		for _, svc := range op.Exec.Services {
+			if err := validateService(svc, inputCount); err != nil {
+				return err
+			}
+			if _, ok := names[svc.Name]; ok {
+				return errors.Errorf("invalid exec op with duplicate service %q", svc.Name)
+			}
+			names[svc.Name] = struct{}{}
+		}
+		if cp := op.Exec.Checkpoint; cp != nil {
+			if cp.Timeout < 0 {
+				return errors.Errorf("invalid exec op with checkpoint timeout %d", cp.Timeout)
+			}
+			if len(op.Exec.Services) > 0 {
+				return errors.Errorf("invalid exec op with checkpoint and services")
+			}
+		}
 	case *pb.Op_File:
 		if op.File == nil {
 			return errors.Errorf("invalid nil file op")
@@ -77,3 +103,27 @@ func Validate(op *pb.Op) error {
 	}
 	return nil
 }
+
+func validateService(svc *pb.ServiceContainer, inputCount int) error {
+	if svc == nil {
+		return errors.Errorf("invalid nil service")
+	}
+	if svc.Name == "" {
+		return errors.Errorf("invalid service with no name")
+	}
+	if svc.Input < 0 || inputCount > 0 && svc.Input >= int64(inputCount) {
+		return errors.Errorf("invalid service %q input index %d", svc.Name, svc.Input)
+	}
+	if svc.Meta == nil || len(svc.Meta.Args) == 0 {
+		return errors.Errorf("invalid service %q with no args", svc.Name)
+	}
+	if rc := svc.Readiness; rc != nil {
+		if rc.TcpPort < 0 || rc.TcpPort > 65535 {
+			return errors.Errorf("invalid service %q readiness port %d", svc.Name, rc.TcpPort)
+		}
+		if rc.Timeout < 0 {
+			return errors.Errorf("invalid service %q readiness timeout %d", svc.Name, rc.Timeout)
+		}
+	}
+	return nil
+}
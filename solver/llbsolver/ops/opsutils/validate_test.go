@@ -0,0 +1,33 @@
+package opsutils
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExecServices(t *testing.T) {
+	t.Parallel()
+
+	op := func(network pb.NetMode) *pb.Op {
+		return &pb.Op{
+			Inputs: []*pb.Input{{Digest: "sha256:0000000000000000000000000000000000000000000000000000000000000000"}},
+			Op: &pb.Op_Exec{
+				Exec: &pb.ExecOp{
+					Meta:    &pb.Meta{Args: []string{"true"}},
+					Mounts:  []*pb.Mount{{Input: 0, Dest: pb.RootMount}},
+					Network: network,
+					Services: []*pb.ServiceContainer{{
+						Name: "db",
+						Meta: &pb.Meta{Args: []string{"db"}},
+					}},
+				},
+			},
+		}
+	}
+
+	require.NoError(t, Validate(op(pb.NetMode_UNSET)))
+	require.NoError(t, Validate(op(pb.NetMode_NONE)))
+	require.ErrorContains(t, Validate(op(pb.NetMode_HOST)), "services and network mode HOST")
+}
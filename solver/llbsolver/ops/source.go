@@ -11,9 +11,9 @@ import (
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/source"
 	"github.com/moby/buildkit/util/cachedigest"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/worker"
 	digest "github.com/opencontainers/go-digest"
-	"golang.org/x/sync/semaphore"
 )
 
 const sourceCacheType = "buildkit.source.v0"
@@ -27,14 +27,14 @@ type SourceOp struct {
 	sessM       *session.Manager
 	w           worker.Worker
 	vtx         solver.Vertex
-	parallelism *semaphore.Weighted
+	parallelism *fairshare.Scheduler
 	pin         string
 	id          source.Identifier
 }
 
 var _ solver.Op = &SourceOp{}
 
-func NewSourceOp(vtx solver.Vertex, op *pb.Op_Source, platform *pb.Platform, sm *source.Manager, parallelism *semaphore.Weighted, sessM *session.Manager, w worker.Worker) (*SourceOp, error) {
+func NewSourceOp(vtx solver.Vertex, op *pb.Op_Source, platform *pb.Platform, sm *source.Manager, parallelism *fairshare.Scheduler, sessM *session.Manager, w worker.Worker) (*SourceOp, error) {
 	if err := opsutils.Validate(&pb.Op{Op: op}); err != nil {
 		return nil, err
 	}
@@ -120,11 +120,9 @@ func (s *SourceOp) Acquire(ctx context.Context) (solver.ReleaseFunc, error) {
 	if s.parallelism == nil {
 		return func() {}, nil
 	}
-	err := s.parallelism.Acquire(ctx, 1)
+	release, err := s.parallelism.Acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return func() {
-		s.parallelism.Release(1)
-	}, nil
+	return release, nil
 }
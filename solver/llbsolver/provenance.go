@@ -343,6 +343,9 @@ func captureProvenance(ctx context.Context, res solver.CachedResultWithProvenanc
 			if pr.Network != pb.NetMode_NONE {
 				c.NetworkAccess = true
 			}
+			if pr.Runtime != "" {
+				c.AddRuntime(pr.Runtime)
+			}
 			if op.ProxyNetwork() {
 				c.ProxyNetwork = true
 				proxyCap := op.ProxyCapture()
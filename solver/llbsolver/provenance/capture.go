@@ -23,6 +23,9 @@ type Capture struct {
 	IncompleteMaterials bool
 	ProxyIncomplete     []provenancetypes.ProxyCaptureIncomplete
 	Samples             map[digest.Digest]*resourcestypes.Samples
+	// Runtimes are the names of the OCI runtimes selected by the steps of
+	// the build.
+	Runtimes []string
 }
 
 func (c *Capture) Clone() *Capture {
@@ -34,6 +37,7 @@ func (c *Capture) Clone() *Capture {
 		ProxyNetwork:        c.ProxyNetwork,
 		IncompleteMaterials: c.IncompleteMaterials,
 		ProxyIncomplete:     slices.Clone(c.ProxyIncomplete),
+		Runtimes:            slices.Clone(c.Runtimes),
 	}
 	if req := c.Request.Clone(); req != nil {
 		out.Request = *req
@@ -89,6 +93,9 @@ func (c *Capture) Merge(c2 *Capture) error {
 		c.IncompleteMaterials = true
 	}
 	c.ProxyIncomplete = append(c.ProxyIncomplete, c2.ProxyIncomplete...)
+	for _, r := range c2.Runtimes {
+		c.AddRuntime(r)
+	}
 	return nil
 }
 
@@ -126,6 +133,7 @@ func (c *Capture) Sort() {
 		}
 		return cmp.Compare(a.Reason, b.Reason)
 	})
+	slices.Sort(c.Runtimes)
 }
 
 // OptimizeImageSources filters out image sources by digest reference if same digest
@@ -261,6 +269,12 @@ func (c *Capture) AddSSH(s provenancetypes.SSH) {
 	c.Request.SSH = append(c.Request.SSH, &s)
 }
 
+func (c *Capture) AddRuntime(name string) {
+	if !slices.Contains(c.Runtimes, name) {
+		c.Runtimes = append(c.Runtimes, name)
+	}
+}
+
 func (c *Capture) AddSamples(dgst digest.Digest, samples *resourcestypes.Samples) {
 	if c.Samples == nil {
 		c.Samples = map[digest.Digest]*resourcestypes.Samples{}
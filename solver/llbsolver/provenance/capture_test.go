@@ -170,6 +170,9 @@ func TestCaptureMerge(t *testing.T) {
 	}
 	c2.AddImage(provenancetypes.ImageSource{Ref: "busybox"})
 	c2.AddGit(provenancetypes.GitSource{URL: "https://example.com", Commit: "abc"})
+	c1.AddRuntime("runsc")
+	c2.AddRuntime("runsc")
+	c2.AddRuntime("crun")
 
 	err := c1.Merge(c2)
 	require.NoError(t, err)
@@ -179,6 +182,7 @@ func TestCaptureMerge(t *testing.T) {
 	require.Len(t, c1.Request.Secrets, 1)
 	require.True(t, c1.NetworkAccess)
 	require.True(t, c1.IncompleteMaterials)
+	require.Equal(t, []string{"runsc", "crun"}, c1.Runtimes)
 }
 
 func TestCaptureMergeNil(t *testing.T) {
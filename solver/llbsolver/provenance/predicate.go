@@ -289,6 +289,9 @@ func NewPredicate(c *Capture) (*provenancetypes.ProvenancePredicateSLSA1, error)
 	if len(vcs) > 0 {
 		pr.RunDetails.Metadata.BuildKitMetadata.VCS = vcs
 	}
+	if len(c.Runtimes) > 0 {
+		pr.RunDetails.Metadata.BuildKitMetadata.Runtimes = slices.Clone(c.Runtimes)
+	}
 	if c.ProxyNetwork {
 		pr.RunDetails.Metadata.BuildKitMetadata.Network = &provenancetypes.NetworkMetadata{
 			Mode: "proxy",
@@ -350,6 +350,9 @@ type BuildKitMetadata struct {
 	Layers   map[string][][]ocispecs.Descriptor `json:"layers,omitempty"`
 	SysUsage []*resourcestypes.SysSample        `json:"sysUsage,omitempty"`
 	Network  *NetworkMetadata                   `json:"network,omitempty"`
+	// Runtimes are the names of the OCI runtimes that were selected by
+	// build steps instead of the default runtime of the worker.
+	Runtimes []string `json:"runtimes,omitempty"`
 }
 
 type NetworkMetadata struct {
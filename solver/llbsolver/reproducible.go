@@ -0,0 +1,30 @@
+package llbsolver
+
+import (
+	"github.com/moby/buildkit/exporter/exptypes"
+	"github.com/moby/buildkit/exporter/util/epoch"
+	"github.com/moby/buildkit/solver"
+)
+
+// keyReproducibleCheck is the frontend attribute that enables the
+// reproducibility check for the build.
+const keyReproducibleCheck = "reproducible-check"
+
+func parseReproducibleCheck(opt map[string]string) (*solver.ReproducibleCheck, error) {
+	check, err := solver.ParseReproducibleCheck(opt[keyReproducibleCheck])
+	if err != nil || check == nil {
+		return nil, err
+	}
+	// modification times are clamped by the exporters when SOURCE_DATE_EPOCH
+	// is set, so later times are not a difference
+	if v, ok := epoch.ParseBuildArgs(opt); ok {
+		tm, _, err := epoch.ParseExporterAttrs(map[string]string{string(exptypes.OptKeySourceDateEpoch): v})
+		if err != nil {
+			return nil, err
+		}
+		if tm != nil {
+			check.Epoch = tm.Value
+		}
+	}
+	return check, nil
+}
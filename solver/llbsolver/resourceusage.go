@@ -0,0 +1,98 @@
+package llbsolver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	resourcestypes "github.com/moby/buildkit/executor/resources/types"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/llbsolver/resourceusage"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/pkg/errors"
+)
+
+type resourceSampler interface {
+	Samples() (*resourcestypes.Samples, error)
+}
+
+// recordResourceUsage stores the resource usage of the steps executed by the
+// job in the build history. It returns a nil descriptor if no step was
+// sampled.
+func (s *Solver) recordResourceUsage(ctx context.Context, j *solver.Job) (*controlapi.Descriptor, func(), error) {
+	rec := &resourceusage.Record{}
+	for _, eo := range j.ExecutedOps() {
+		rs, ok := eo.Op.(resourceSampler)
+		if !ok {
+			continue
+		}
+		samples, err := rs.Samples()
+		if err != nil {
+			bklog.G(ctx).Debugf("skipping resource usage of %s: %v", eo.Vertex.Digest(), err)
+			continue
+		}
+		if v, ok := resourceusage.Summarize(eo.Vertex.Digest(), eo.Vertex.Name(), samples); ok {
+			rec.Vertexes = append(rec.Vertexes, v)
+		}
+	}
+	if len(rec.Vertexes) == 0 {
+		return nil, nil, nil
+	}
+
+	dt, err := json.Marshal(rec)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	w, err := s.history.OpenBlobWriter(ctx, resourceusage.MediaType)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if w != nil {
+			w.Discard()
+		}
+	}()
+	if _, err := w.Write(dt); err != nil {
+		return nil, nil, err
+	}
+	desc, release, err := w.Commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	w = nil
+	return &controlapi.Descriptor{
+		Digest:    string(desc.Digest),
+		Size:      desc.Size,
+		MediaType: desc.MediaType,
+	}, release, nil
+}
+
+// ResourceUsage returns the resource usage of the steps of a build in the
+// build history.
+func (s *Solver) ResourceUsage(ctx context.Context, ref string) (*resourceusage.Record, error) {
+	var rec *controlapi.BuildHistoryRecord
+	if err := s.history.Listen(ctx, &controlapi.BuildHistoryRequest{EarlyExit: true, Ref: ref}, func(e *controlapi.BuildHistoryEvent) error {
+		if e.Record != nil && e.Record.Ref == ref {
+			rec = e.Record
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, errors.Wrapf(os.ErrNotExist, "build record %s not found", ref)
+	}
+	if rec.ResourceUsage == nil {
+		return &resourceusage.Record{}, nil
+	}
+	dt, err := s.history.ReadBlob(ctx, rec.ResourceUsage)
+	if err != nil {
+		return nil, err
+	}
+	var out resourceusage.Record
+	if err := json.Unmarshal(dt, &out); err != nil {
+		return nil, errors.Wrap(err, "failed to parse resource usage")
+	}
+	return &out, nil
+}
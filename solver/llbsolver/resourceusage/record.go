@@ -0,0 +1,136 @@
+// Package resourceusage summarizes the resources used by the containers of
+// the steps of a build so that they can be stored in the build history.
+package resourceusage
+
+import (
+	"slices"
+	"time"
+
+	resourcestypes "github.com/moby/buildkit/executor/resources/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// MediaType is the media type of a Record stored in the build history.
+const MediaType = "application/vnd.buildkit.resourceusage.v0+json"
+
+// Record contains the resource usage of the executed steps of a build.
+type Record struct {
+	Vertexes []Vertex `json:"vertexes,omitempty"`
+}
+
+// Vertex is the resource usage of the container of a step. Counters are
+// accumulated over the lifetime of the container and taken from its last
+// sample.
+type Vertex struct {
+	Digest   digest.Digest `json:"digest"`
+	Name     string        `json:"name,omitempty"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration,omitempty"`
+
+	CPU          time.Duration `json:"cpu,omitempty"`
+	PeakMemory   uint64        `json:"peakMemory,omitempty"`
+	OOMKills     uint64        `json:"oomKills,omitempty"`
+	PeakPIDs     uint64        `json:"peakPIDs,omitempty"`
+	IOReadBytes  uint64        `json:"ioReadBytes,omitempty"`
+	IOWriteBytes uint64        `json:"ioWriteBytes,omitempty"`
+	NetRxBytes   int64         `json:"netRxBytes,omitempty"`
+	NetTxBytes   int64         `json:"netTxBytes,omitempty"`
+
+	// Stall times are the time that some processes of the container were
+	// waiting for the resource, read from the pressure stall information.
+	CPUStall    time.Duration `json:"cpuStall,omitempty"`
+	MemoryStall time.Duration `json:"memoryStall,omitempty"`
+	IOStall     time.Duration `json:"ioStall,omitempty"`
+}
+
+// Summarize computes the resource usage of a vertex from the samples of its
+// container. It returns false if there are no samples.
+func Summarize(dgst digest.Digest, name string, samples *resourcestypes.Samples) (Vertex, bool) {
+	v := Vertex{Digest: dgst, Name: name}
+	if samples == nil || len(samples.Samples) == 0 {
+		return v, false
+	}
+	first, last := samples.Samples[0], samples.Samples[len(samples.Samples)-1]
+	v.Started = first.Timestamp_
+	v.Duration = last.Timestamp_.Sub(first.Timestamp_)
+
+	for _, s := range samples.Samples {
+		if s.MemoryStat != nil {
+			m := s.MemoryStat
+			v.PeakMemory = max(v.PeakMemory, value(m.Peak), value(m.Anon))
+			v.OOMKills = max(v.OOMKills, m.OomKillEvents)
+		}
+		if s.PIDsStat != nil {
+			v.PeakPIDs = max(v.PeakPIDs, value(s.PIDsStat.Current))
+		}
+	}
+	if s := last.CPUStat; s != nil {
+		v.CPU = time.Duration(value(s.UsageNanos))
+		v.CPUStall = stallTotal(s.Pressure)
+	}
+	if s := last.MemoryStat; s != nil {
+		v.MemoryStall = stallTotal(s.Pressure)
+	}
+	if s := last.IOStat; s != nil {
+		v.IOReadBytes = value(s.ReadBytes)
+		v.IOWriteBytes = value(s.WriteBytes)
+		v.IOStall = stallTotal(s.Pressure)
+	}
+	if s := last.NetStat; s != nil {
+		v.NetRxBytes = s.RxBytes
+		v.NetTxBytes = s.TxBytes
+	}
+	return v, true
+}
+
+// SortKeys are the keys accepted by Sort.
+var SortKeys = []string{"memory", "cpu", "duration", "io", "net", "pids", "stall"}
+
+// Sort sorts the vertexes by the given key in descending order. Unknown keys
+// sort by peak memory.
+func Sort(vertexes []Vertex, key string) {
+	get := func(v Vertex) int64 {
+		switch key {
+		case "cpu":
+			return int64(v.CPU)
+		case "duration":
+			return int64(v.Duration)
+		case "io":
+			return int64(v.IOReadBytes + v.IOWriteBytes)
+		case "net":
+			return v.NetRxBytes + v.NetTxBytes
+		case "pids":
+			return int64(v.PeakPIDs)
+		case "stall":
+			return int64(v.CPUStall + v.MemoryStall + v.IOStall)
+		default:
+			return int64(v.PeakMemory)
+		}
+	}
+	slices.SortStableFunc(vertexes, func(a, b Vertex) int {
+		va, vb := get(a), get(b)
+		switch {
+		case va > vb:
+			return -1
+		case va < vb:
+			return 1
+		}
+		return 0
+	})
+}
+
+// stallTotal returns the total stall time of the "some" line of a pressure
+// file. The kernel reports it in microseconds.
+func stallTotal(p *resourcestypes.Pressure) time.Duration {
+	if p == nil || p.Some == nil || p.Some.Total == nil {
+		return 0
+	}
+	return time.Duration(*p.Some.Total) * time.Microsecond
+}
+
+func value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,99 @@
+package resourceusage
+
+import (
+	"testing"
+	"time"
+
+	resourcestypes "github.com/moby/buildkit/executor/resources/types"
+	"github.com/stretchr/testify/require"
+)
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := &resourcestypes.Samples{
+		Samples: []*resourcestypes.Sample{
+			{
+				Timestamp_: start,
+				CPUStat:    &resourcestypes.CPUStat{UsageNanos: uint64Ptr(1000)},
+				MemoryStat: &resourcestypes.MemoryStat{Anon: uint64Ptr(100)},
+				PIDsStat:   &resourcestypes.PIDsStat{Current: uint64Ptr(1)},
+			},
+			{
+				Timestamp_: start.Add(2 * time.Second),
+				MemoryStat: &resourcestypes.MemoryStat{Anon: uint64Ptr(500), Peak: uint64Ptr(800), OomKillEvents: 1},
+				PIDsStat:   &resourcestypes.PIDsStat{Current: uint64Ptr(7)},
+			},
+			{
+				Timestamp_: start.Add(3 * time.Second),
+				CPUStat: &resourcestypes.CPUStat{
+					UsageNanos: uint64Ptr(uint64(1500 * time.Millisecond)),
+					Pressure: &resourcestypes.Pressure{
+						Some: &resourcestypes.PressureValues{Total: uint64Ptr(2000)},
+					},
+				},
+				MemoryStat: &resourcestypes.MemoryStat{
+					Anon:          uint64Ptr(200),
+					OomKillEvents: 1,
+					Pressure: &resourcestypes.Pressure{
+						Some: &resourcestypes.PressureValues{Total: uint64Ptr(3000)},
+					},
+				},
+				IOStat:   &resourcestypes.IOStat{ReadBytes: uint64Ptr(10), WriteBytes: uint64Ptr(20)},
+				PIDsStat: &resourcestypes.PIDsStat{Current: uint64Ptr(2)},
+				NetStat:  &resourcestypes.NetworkSample{RxBytes: 30, TxBytes: 40},
+			},
+		},
+	}
+
+	v, ok := Summarize("sha256:abc", "RUN make", samples)
+	require.True(t, ok)
+	require.Equal(t, Vertex{
+		Digest:       "sha256:abc",
+		Name:         "RUN make",
+		Started:      start,
+		Duration:     3 * time.Second,
+		CPU:          1500 * time.Millisecond,
+		PeakMemory:   800,
+		OOMKills:     1,
+		PeakPIDs:     7,
+		IOReadBytes:  10,
+		IOWriteBytes: 20,
+		NetRxBytes:   30,
+		NetTxBytes:   40,
+		CPUStall:     2 * time.Millisecond,
+		MemoryStall:  3 * time.Millisecond,
+	}, v)
+
+	_, ok = Summarize("sha256:abc", "RUN make", &resourcestypes.Samples{})
+	require.False(t, ok)
+	_, ok = Summarize("sha256:abc", "RUN make", nil)
+	require.False(t, ok)
+}
+
+func TestSort(t *testing.T) {
+	vertexes := []Vertex{
+		{Name: "a", PeakMemory: 10, CPU: 3 * time.Second},
+		{Name: "b", PeakMemory: 30, CPU: time.Second},
+		{Name: "c", PeakMemory: 20, CPU: 2 * time.Second},
+	}
+	names := func() []string {
+		var out []string
+		for _, v := range vertexes {
+			out = append(out, v.Name)
+		}
+		return out
+	}
+
+	Sort(vertexes, "memory")
+	require.Equal(t, []string{"b", "c", "a"}, names())
+
+	Sort(vertexes, "cpu")
+	require.Equal(t, []string{"a", "c", "b"}, names())
+
+	Sort(vertexes, "")
+	require.Equal(t, []string{"b", "c", "a"}, names())
+}
@@ -0,0 +1,85 @@
+package securityprofiles
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/moby/profiles/seccomp"
+	"github.com/pkg/errors"
+)
+
+// Profile is a named seccomp and AppArmor profile that build steps can
+// select instead of the default profile.
+type Profile struct {
+	Name string
+	// Seccomp is the seccomp profile in JSON format. The default seccomp
+	// profile is used if it is empty.
+	Seccomp string
+	// AppArmor is the name of an AppArmor profile loaded on the host. The
+	// AppArmor profile of the worker is used if it is empty.
+	AppArmor string
+	// Restrictive marks profiles that only tighten the default profile.
+	// Selecting them does not require the security.profile entitlement.
+	Restrictive bool
+}
+
+// Validate checks that the seccomp profile can be parsed.
+func (p *Profile) Validate() error {
+	if p.Name == "" {
+		return errors.New("security profile name must be set")
+	}
+	if p.Seccomp == "" {
+		return nil
+	}
+	var sp seccomp.Seccomp
+	if err := json.Unmarshal([]byte(p.Seccomp), &sp); err != nil {
+		return errors.Wrapf(err, "invalid seccomp profile for security profile %q", p.Name)
+	}
+	return nil
+}
+
+// Manager holds the security profiles allowed by the daemon configuration.
+type Manager struct {
+	profiles map[string]Profile
+}
+
+func NewManager(profiles []Profile) (*Manager, error) {
+	m := &Manager{profiles: make(map[string]Profile, len(profiles))}
+	for _, p := range profiles {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := m.profiles[p.Name]; ok {
+			return nil, errors.Errorf("duplicate security profile %q", p.Name)
+		}
+		m.profiles[p.Name] = p
+	}
+	return m, nil
+}
+
+// Get returns the profile with the given name. An error is returned if the
+// profile is not configured.
+func (m *Manager) Get(name string) (*Profile, error) {
+	if m != nil {
+		if p, ok := m.profiles[name]; ok {
+			return &p, nil
+		}
+	}
+	return nil, errors.Errorf("security profile %q is not configured", name)
+}
+
+// List returns the configured profiles sorted by name.
+func (m *Manager) List() []Profile {
+	if m == nil {
+		return nil
+	}
+	out := make([]Profile, 0, len(m.profiles))
+	for _, p := range m.profiles {
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b Profile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
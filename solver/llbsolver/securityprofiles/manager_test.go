@@ -0,0 +1,43 @@
+package securityprofiles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	m, err := NewManager([]Profile{
+		{Name: "perf", Seccomp: `{"defaultAction":"SCMP_ACT_ERRNO","syscalls":[{"names":["perf_event_open"],"action":"SCMP_ACT_ALLOW"}]}`},
+		{Name: "confined", AppArmor: "buildkit-confined", Restrictive: true},
+	})
+	require.NoError(t, err)
+
+	p, err := m.Get("perf")
+	require.NoError(t, err)
+	require.False(t, p.Restrictive)
+	require.Contains(t, p.Seccomp, "perf_event_open")
+
+	_, err = m.Get("unknown")
+	require.ErrorContains(t, err, `security profile "unknown" is not configured`)
+
+	profiles := m.List()
+	require.Len(t, profiles, 2)
+	require.Equal(t, "confined", profiles[0].Name)
+	require.Equal(t, "perf", profiles[1].Name)
+
+	var nilManager *Manager
+	_, err = nilManager.Get("perf")
+	require.Error(t, err)
+}
+
+func TestManagerInvalid(t *testing.T) {
+	_, err := NewManager([]Profile{{Name: "bad", Seccomp: "{"}})
+	require.ErrorContains(t, err, `invalid seccomp profile for security profile "bad"`)
+
+	_, err = NewManager([]Profile{{Name: "a"}, {Name: "a"}})
+	require.ErrorContains(t, err, "duplicate security profile")
+
+	_, err = NewManager([]Profile{{}})
+	require.Error(t, err)
+}
@@ -25,7 +25,9 @@ import (
 	"github.com/moby/buildkit/solver/result"
 	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/util/entitlements"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/leaseutil"
+	"github.com/moby/buildkit/util/network"
 	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/worker"
 	digest "github.com/opencontainers/go-digest"
@@ -61,8 +63,11 @@ type Opt struct {
 	HistoryQueue     *history.Queue
 	ResourceMonitor  *resources.Monitor
 	ProxyNetwork     bool
-	ProvenanceEnv    map[string]any
-	MeterProvider    metric.MeterProvider
+	// ProxyEgress restricts the requests of all builds that use the proxy
+	// network.
+	ProxyEgress   *network.EgressPolicy
+	ProvenanceEnv map[string]any
+	MeterProvider metric.MeterProvider
 }
 
 type Solver struct {
@@ -78,6 +83,7 @@ type Solver struct {
 	history                   *history.Queue
 	sysSampler                *resources.Sampler[*resourcestypes.SysSample]
 	proxyNetwork              bool
+	proxyEgress               *network.EgressPolicy
 	provenanceEnv             map[string]any
 	provenanceStore           *provenanceStore
 	metrics                   *buildMetrics
@@ -116,6 +122,7 @@ func New(opt Opt) (*Solver, error) {
 		entitlements:              opt.Entitlements,
 		history:                   opt.HistoryQueue,
 		proxyNetwork:              opt.ProxyNetwork,
+		proxyEgress:               opt.ProxyEgress,
 		provenanceEnv:             opt.ProvenanceEnv,
 		provenanceStore:           newProvenanceStore(),
 		metrics:                   bm,
@@ -150,15 +157,16 @@ func (s *Solver) resolver() solver.ResolveOpFunc {
 		}
 		br := s.bridge(b)
 		return w.ResolveOp(v, br, s.sm, worker.ProxyOpt{
-			Network: br.ProxyNetwork(),
-			Policy:  br.ProxyPolicy,
+			Network:   br.ProxyNetwork(),
+			Policy:    br.ProxyPolicy,
+			Configure: br.ConfigureProxy,
 		})
 	}
 }
 
 func (s *Solver) bridge(b solver.Builder, opts ...bridgeOpt) *provenanceBridge {
 	cfg := bridgeConfig{
-		proxyNetwork: s.proxyNetwork,
+		proxyNetwork: s.defaultProxyNetwork(),
 	}
 	for _, opt := range opts {
 		opt(&cfg)
@@ -173,6 +181,7 @@ func (s *Solver) bridge(b solver.Builder, opts ...bridgeOpt) *provenanceBridge {
 		sm:                        s.sm,
 		provenanceStore:           s.provenanceStore,
 		proxyNetwork:              cfg.proxyNetwork,
+		proxyEgress:               s.proxyEgress,
 	}}
 }
 
@@ -192,7 +201,7 @@ func (s *Solver) Bridge(b solver.Builder) frontend.FrontendLLBBridge {
 	return s.bridge(b)
 }
 
-func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req frontend.SolveRequest, compatibilityVersion int, exp ExporterRequest, ent []entitlements.Entitlement, post []Processor, internal bool, srcPol *spb.Policy, policySession string, proxyNetwork bool) (_ *client.SolveResponse, err error) {
+func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req frontend.SolveRequest, compatibilityVersion int, exp ExporterRequest, ent []entitlements.Entitlement, post []Processor, internal bool, srcPol *spb.Policy, policySession string, proxy ProxyNetworkRequest, fs fairshare.Request) (_ *client.SolveResponse, err error) {
 	hasNamedDockerfileContext := false
 	for k := range req.FrontendOpt {
 		if k == "context:dockerfile.v0" || strings.HasPrefix(k, "context:dockerfile.v0::") {
@@ -245,9 +254,16 @@ func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req fro
 		return nil, err
 	}
 	j.SetValue(keyEntitlements, set)
+	proxyNetwork := proxyNetworkForSolve(proxy, srcPol)
 	if proxyNetwork {
 		j.SetValue(keyProxyNetwork, true)
 	}
+	if proxy.Record {
+		j.SetValue(keyProxyRecord, true)
+	}
+	if proxy.Replay != nil {
+		j.SetValue(keyProxyReplay, proxy.Replay)
+	}
 
 	if srcPol != nil {
 		if err := validateSourcePolicy(srcPol); err != nil {
@@ -262,10 +278,18 @@ func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req fro
 		compatibilityVersion = compat.CompatibilityVersionCurrent
 	}
 	j.SetValue(compat.JobValueKey, compatibilityVersion)
+	j.SetValue(fairshare.JobValueKey, fs)
+	check, err := parseReproducibleCheck(req.FrontendOpt)
+	if err != nil {
+		return nil, err
+	}
+	if check != nil {
+		j.SetValue(solver.ReproducibleCheckJobValueKey, check)
+	}
 
 	j.SessionID = sessionID
 
-	br := s.bridge(j, withBridgeProxyNetwork(proxyNetwork || s.proxyNetwork))
+	br := s.bridge(j, withBridgeProxyNetwork(proxyNetwork || s.defaultProxyNetwork()))
 	defer br.releaseProvenanceRefs()
 	rootReq := req.Clone()
 	br.rootReq = &rootReq
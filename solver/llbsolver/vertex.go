@@ -11,6 +11,7 @@ import (
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
 	"github.com/moby/buildkit/solver/llbsolver/linuxresources"
 	"github.com/moby/buildkit/solver/llbsolver/ops/opsutils"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/apicaps"
 	"github.com/moby/buildkit/util/entitlements"
@@ -126,13 +127,26 @@ func NormalizeRuntimePlatforms() LoadOpt {
 	}
 }
 
-func ValidateEntitlements(ent entitlements.Set, cdiManager *cdidevices.Manager) LoadOpt {
+// ValidateEntitlements checks that the execs of the definition only use the
+// entitlements of ent. defaultRuntime is the name of the OCI runtime the worker
+// runs execs with if they don't select one, selecting it doesn't need an
+// entitlement.
+func ValidateEntitlements(ent entitlements.Set, cdiManager *cdidevices.Manager, secProfiles *securityprofiles.Manager, defaultRuntime string) LoadOpt {
 	return func(op *pb.Op, _ *pb.OpMetadata, opt *solver.VertexOptions) error {
 		switch op := op.Op.(type) {
 		case *pb.Op_Exec:
 			v := entitlements.Values{
 				NetworkHost:      op.Exec.Network == pb.NetMode_HOST,
 				SecurityInsecure: op.Exec.Security == pb.SecurityMode_INSECURE,
+				NetworkCustom:    op.Exec.NetworkName != "",
+				SecurityRuntime:  op.Exec.Runtime != "" && op.Exec.Runtime != defaultRuntime,
+			}
+			if name := op.Exec.SecurityProfile; name != "" {
+				p, err := secProfiles.Get(name)
+				if err != nil {
+					return err
+				}
+				v.SecurityProfile = !p.Restrictive
 			}
 			if err := ent.Check(v); err != nil {
 				return err
@@ -265,7 +279,7 @@ func loadWithProxyNetwork(ctx context.Context, def *pb.Definition, polEngine Sou
 	})
 }
 
-func vertexOptions(opMeta *pb.OpMetadata) solver.VertexOptions {
+func vertexOptions(opMeta *pb.OpMetadata) (solver.VertexOptions, error) {
 	opt := solver.VertexOptions{}
 	if opMeta != nil {
 		opt.IgnoreCache = opMeta.IgnoreCache
@@ -274,12 +288,20 @@ func vertexOptions(opMeta *pb.OpMetadata) solver.VertexOptions {
 			opt.ExportCache = &opMeta.ExportCache.Value
 		}
 		opt.ProgressGroup = opMeta.ProgressGroup
+		if opMeta.ExecutionPolicy != nil {
+			if err := setExecutionPolicy(&opt, opMeta.ExecutionPolicy); err != nil {
+				return opt, err
+			}
+		}
 	}
-	return opt
+	return opt, nil
 }
 
 func newVertex(dgst digest.Digest, op *op, load func(digest.Digest) (solver.Vertex, error), opts ...LoadOpt) (*vertex, error) {
-	opt := vertexOptions(op.Metadata)
+	opt, err := vertexOptions(op.Metadata)
+	if err != nil {
+		return nil, err
+	}
 	for _, fn := range opts {
 		if err := fn(op.Op, op.Metadata, &opt); err != nil {
 			return nil, err
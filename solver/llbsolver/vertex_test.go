@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/util/entitlements"
+	"github.com/moby/buildkit/util/network"
 	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -181,13 +184,83 @@ func TestWithProxyNetworkHostEgressRequiresEntitlement(t *testing.T) {
 		exec.Network = pb.NetMode_HOST
 	})
 
-	_, err := loadWithProxyNetwork(t.Context(), def, nil, true, ValidateEntitlements(entitlements.Set{}, nil))
+	_, err := loadWithProxyNetwork(t.Context(), def, nil, true, ValidateEntitlements(entitlements.Set{}, nil, nil, ""))
 	require.Error(t, err)
 	require.ErrorContains(t, err, "network.host is not allowed")
 
 	_, err = loadWithProxyNetwork(t.Context(), def, nil, true, ValidateEntitlements(entitlements.Set{
 		entitlements.EntitlementNetworkHost: nil,
-	}, nil))
+	}, nil, nil, ""))
+	require.NoError(t, err)
+}
+
+func TestSecurityProfileRequiresEntitlement(t *testing.T) {
+	secProfiles, err := securityprofiles.NewManager([]securityprofiles.Profile{
+		{Name: "perf", Seccomp: `{"defaultAction":"SCMP_ACT_ALLOW"}`},
+		{Name: "confined", AppArmor: "buildkit-confined", Restrictive: true},
+	})
+	require.NoError(t, err)
+
+	withProfile := func(name string) *pb.Definition {
+		return proxyNetworkTestDefinition(t, func(exec *pb.ExecOp) {
+			exec.SecurityProfile = name
+		})
+	}
+
+	_, err = Load(t.Context(), withProfile("perf"), nil, ValidateEntitlements(entitlements.Set{}, nil, secProfiles, ""))
+	require.ErrorContains(t, err, "security.profile is not allowed")
+
+	_, err = Load(t.Context(), withProfile("perf"), nil, ValidateEntitlements(entitlements.Set{
+		entitlements.EntitlementSecurityProfile: nil,
+	}, nil, secProfiles, ""))
+	require.NoError(t, err)
+
+	_, err = Load(t.Context(), withProfile("confined"), nil, ValidateEntitlements(entitlements.Set{}, nil, secProfiles, ""))
+	require.NoError(t, err)
+
+	_, err = Load(t.Context(), withProfile("unknown"), nil, ValidateEntitlements(entitlements.Set{
+		entitlements.EntitlementSecurityProfile: nil,
+	}, nil, secProfiles, ""))
+	require.ErrorContains(t, err, `security profile "unknown" is not configured`)
+}
+
+func TestNetworkNameRequiresEntitlement(t *testing.T) {
+	def := proxyNetworkTestDefinition(t, func(exec *pb.ExecOp) {
+		exec.NetworkName = "isolated"
+	})
+
+	_, err := Load(t.Context(), def, nil, ValidateEntitlements(entitlements.Set{}, nil, nil, ""))
+	require.ErrorContains(t, err, "network.custom is not allowed")
+
+	_, err = Load(t.Context(), def, nil, ValidateEntitlements(entitlements.Set{
+		entitlements.EntitlementNetworkCustom: nil,
+	}, nil, nil, ""))
+	require.NoError(t, err)
+
+	_, err = loadWithProxyNetwork(t.Context(), def, nil, true, ValidateEntitlements(entitlements.Set{
+		entitlements.EntitlementNetworkCustom: nil,
+	}, nil, nil, ""))
+	require.ErrorContains(t, err, `network "isolated" is not allowed when proxy network is enabled`)
+}
+
+func TestRuntimeRequiresEntitlement(t *testing.T) {
+	def := proxyNetworkTestDefinition(t, func(exec *pb.ExecOp) {
+		exec.Runtime = "runc"
+	})
+
+	_, err := Load(t.Context(), def, nil, ValidateEntitlements(entitlements.Set{}, nil, nil, ""))
+	require.ErrorContains(t, err, "security.runtime is not allowed")
+
+	_, err = Load(t.Context(), def, nil, ValidateEntitlements(entitlements.Set{}, nil, nil, "runsc"))
+	require.ErrorContains(t, err, "security.runtime is not allowed")
+
+	_, err = Load(t.Context(), def, nil, ValidateEntitlements(entitlements.Set{
+		entitlements.EntitlementSecurityRuntime: nil,
+	}, nil, nil, "runsc"))
+	require.NoError(t, err)
+
+	// the default runtime can be selected without the entitlement
+	_, err = Load(t.Context(), def, nil, ValidateEntitlements(entitlements.Set{}, nil, nil, "runc"))
 	require.NoError(t, err)
 }
 
@@ -199,6 +272,21 @@ func TestBridgeUsesDefaultProxyNetwork(t *testing.T) {
 	require.True(t, br.proxyNetwork)
 }
 
+func TestEgressPolicyEnablesProxyNetwork(t *testing.T) {
+	s := &Solver{proxyEgress: &network.EgressPolicy{Rules: []network.EgressRule{{Hosts: []string{"example.com"}}}}}
+	require.True(t, s.bridge(nil).proxyNetwork)
+
+	s = &Solver{proxyEgress: &network.EgressPolicy{}}
+	require.False(t, s.bridge(nil).proxyNetwork)
+
+	require.False(t, proxyNetworkForSolve(ProxyNetworkRequest{}, nil))
+	require.False(t, proxyNetworkForSolve(ProxyNetworkRequest{}, &spb.Policy{}))
+	require.True(t, proxyNetworkForSolve(ProxyNetworkRequest{}, &spb.Policy{
+		Egress: []*spb.EgressRule{{Hosts: []string{"example.com"}}},
+	}))
+	require.True(t, proxyNetworkForSolve(ProxyNetworkRequest{Record: true}, nil))
+}
+
 func proxyNetworkTestDefinition(t *testing.T, opts ...func(*pb.ExecOp)) *pb.Definition {
 	t.Helper()
 	source := &pb.Op{
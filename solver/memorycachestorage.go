@@ -230,6 +230,36 @@ func (s *inMemoryStore) WalkLinks(id string, link CacheInfoLink, fn func(id stri
 	return nil
 }
 
+func (s *inMemoryStore) WalkLinksAll(id string, fn func(id string, link CacheInfoLink) error) error {
+	s.mu.RLock()
+	k, ok := s.byID[id]
+	if !ok {
+		s.mu.RUnlock()
+		return nil
+	}
+	var outIDs []string
+	var outLinks []CacheInfoLink
+	for l, m := range k.links {
+		for target := range m {
+			outIDs = append(outIDs, target)
+			outLinks = append(outLinks, CacheInfoLink{
+				Digest:   rootKey(l.Digest, l.Output),
+				Input:    l.Input,
+				Output:   l.Output,
+				Selector: l.Selector,
+			})
+		}
+	}
+	s.mu.RUnlock()
+
+	for i := range outIDs {
+		if err := fn(outIDs[i], outLinks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *inMemoryStore) HasLink(id string, link CacheInfoLink, target string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -61,15 +61,23 @@ const (
 	CapExecMetaProxy                     apicaps.CapID = "exec.meta.proxyenv"
 	CapExecMetaSecurity                  apicaps.CapID = "exec.meta.security"
 	CapExecMetaSecurityDeviceWhitelistV1 apicaps.CapID = "exec.meta.security.devices.v1"
+	CapExecMetaSecurityProfile           apicaps.CapID = "exec.meta.security.profile"
+	CapExecMetaNetworkName               apicaps.CapID = "exec.meta.network.name"
+	CapExecMetaServices                  apicaps.CapID = "exec.meta.services"
+	CapExecMetaCheckpoint                apicaps.CapID = "exec.meta.checkpoint"
+	CapExecMetaRuntime                   apicaps.CapID = "exec.meta.runtime"
 	CapExecMetaSetsDefaultPath           apicaps.CapID = "exec.meta.setsdefaultpath"
 	CapExecMetaUlimit                    apicaps.CapID = "exec.meta.ulimit"
 	CapExecMetaCDI                       apicaps.CapID = "exec.meta.cdi"
 	CapExecMetaRemoveMountStubsRecursive apicaps.CapID = "exec.meta.removemountstubs.recursive"
 	CapExecMetaLinuxResources            apicaps.CapID = "exec.meta.linux.resources"
+	CapExecMetaLinuxResourcesPidsBlkio   apicaps.CapID = "exec.meta.linux.resources.pidsblkio"
+	CapExecMetaLinuxResourcesDiskQuota   apicaps.CapID = "exec.meta.linux.resources.diskquota"
 	CapExecMountBind                     apicaps.CapID = "exec.mount.bind"
 	CapExecMountBindReadWriteNoOutput    apicaps.CapID = "exec.mount.bind.readwrite-nooutput"
 	CapExecMountCache                    apicaps.CapID = "exec.mount.cache"
 	CapExecMountCacheSharing             apicaps.CapID = "exec.mount.cache.sharing"
+	CapExecMountCacheSizeLimit           apicaps.CapID = "exec.mount.cache.sizelimit"
 	CapExecMountSelector                 apicaps.CapID = "exec.mount.selector"
 	CapExecMountTmpfs                    apicaps.CapID = "exec.mount.tmpfs"
 	CapExecMountTmpfsSize                apicaps.CapID = "exec.mount.tmpfs.size"
@@ -96,9 +104,13 @@ const (
 	CapMetaDescription apicaps.CapID = "meta.description"
 	CapMetaExportCache apicaps.CapID = "meta.exportcache"
 
+	CapMetaExecutionPolicy apicaps.CapID = "meta.executionpolicy"
+
 	CapRemoteCacheGHA    apicaps.CapID = "cache.gha"
 	CapRemoteCacheS3     apicaps.CapID = "cache.s3"
 	CapRemoteCacheAzBlob apicaps.CapID = "cache.azblob"
+	CapRemoteCacheHTTP   apicaps.CapID = "cache.http"
+	CapRemoteCacheGCS    apicaps.CapID = "cache.gcs"
 
 	CapMergeOp       apicaps.CapID = "mergeop"
 	CapDiffOp        apicaps.CapID = "diffop"
@@ -394,6 +406,36 @@ func init() {
 		Status:  apicaps.CapStatusExperimental,
 	})
 
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaSecurityProfile,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaNetworkName,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaServices,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaCheckpoint,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaRuntime,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
 	Caps.Init(apicaps.Cap{
 		ID:      CapExecMetaUlimit,
 		Enabled: true,
@@ -412,6 +454,18 @@ func init() {
 		Status:  apicaps.CapStatusExperimental,
 	})
 
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaLinuxResourcesPidsBlkio,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMetaLinuxResourcesDiskQuota,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
 	Caps.Init(apicaps.Cap{
 		ID:      CapExecMountBind,
 		Enabled: true,
@@ -436,6 +490,12 @@ func init() {
 		Status:  apicaps.CapStatusExperimental,
 	})
 
+	Caps.Init(apicaps.Cap{
+		ID:      CapExecMountCacheSizeLimit,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
 	Caps.Init(apicaps.Cap{
 		ID:      CapExecMountSelector,
 		Enabled: true,
@@ -566,6 +626,12 @@ func init() {
 		Status:  apicaps.CapStatusExperimental,
 	})
 
+	Caps.Init(apicaps.Cap{
+		ID:      CapMetaExecutionPolicy,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
 	Caps.Init(apicaps.Cap{
 		ID:      CapRemoteCacheGHA,
 		Enabled: true,
@@ -584,6 +650,18 @@ func init() {
 		Status:  apicaps.CapStatusExperimental,
 	})
 
+	Caps.Init(apicaps.Cap{
+		ID:      CapRemoteCacheHTTP,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
+	Caps.Init(apicaps.Cap{
+		ID:      CapRemoteCacheGCS,
+		Enabled: true,
+		Status:  apicaps.CapStatusExperimental,
+	})
+
 	Caps.Init(apicaps.Cap{
 		ID:      CapMergeOp,
 		Enabled: true,
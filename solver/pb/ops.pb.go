@@ -601,13 +601,30 @@ func (x *Input) GetIndex() int64 {
 
 // ExecOp executes a command in a container.
 type ExecOp struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Meta          *Meta                  `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
-	Mounts        []*Mount               `protobuf:"bytes,2,rep,name=mounts,proto3" json:"mounts,omitempty"`
-	Network       NetMode                `protobuf:"varint,3,opt,name=network,proto3,enum=pb.NetMode" json:"network,omitempty"`
-	Security      SecurityMode           `protobuf:"varint,4,opt,name=security,proto3,enum=pb.SecurityMode" json:"security,omitempty"`
-	Secretenv     []*SecretEnv           `protobuf:"bytes,5,rep,name=secretenv,proto3" json:"secretenv,omitempty"`
-	CdiDevices    []*CDIDevice           `protobuf:"bytes,6,rep,name=cdiDevices,proto3" json:"cdiDevices,omitempty"`
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Meta       *Meta                  `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
+	Mounts     []*Mount               `protobuf:"bytes,2,rep,name=mounts,proto3" json:"mounts,omitempty"`
+	Network    NetMode                `protobuf:"varint,3,opt,name=network,proto3,enum=pb.NetMode" json:"network,omitempty"`
+	Security   SecurityMode           `protobuf:"varint,4,opt,name=security,proto3,enum=pb.SecurityMode" json:"security,omitempty"`
+	Secretenv  []*SecretEnv           `protobuf:"bytes,5,rep,name=secretenv,proto3" json:"secretenv,omitempty"`
+	CdiDevices []*CDIDevice           `protobuf:"bytes,6,rep,name=cdiDevices,proto3" json:"cdiDevices,omitempty"`
+	// securityProfile is the name of a seccomp and AppArmor profile
+	// configured in the daemon that is applied instead of the default one.
+	SecurityProfile string `protobuf:"bytes,7,opt,name=securityProfile,proto3" json:"securityProfile,omitempty"`
+	// networkName is the name of a CNI network configured in the daemon that
+	// the exec is attached to instead of the default network. It can only be
+	// set with the sandbox network mode.
+	NetworkName string `protobuf:"bytes,8,opt,name=networkName,proto3" json:"networkName,omitempty"`
+	// services are started before the process and share its network
+	// namespace. They are stopped when the process exits.
+	Services []*ServiceContainer `protobuf:"bytes,9,rep,name=services,proto3" json:"services,omitempty"`
+	// checkpoint enables checkpointing the process with CRIU when the build is
+	// cancelled or the process exceeds a timeout. The next run of the exec with
+	// the same inputs resumes the process from the checkpoint.
+	Checkpoint *CheckpointOpt `protobuf:"bytes,10,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+	// runtime is the name of an OCI runtime configured in the daemon that
+	// runs the process instead of the default runtime of the worker.
+	Runtime       string `protobuf:"bytes,11,opt,name=runtime,proto3" json:"runtime,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -684,6 +701,226 @@ func (x *ExecOp) GetCdiDevices() []*CDIDevice {
 	return nil
 }
 
+func (x *ExecOp) GetSecurityProfile() string {
+	if x != nil {
+		return x.SecurityProfile
+	}
+	return ""
+}
+
+func (x *ExecOp) GetNetworkName() string {
+	if x != nil {
+		return x.NetworkName
+	}
+	return ""
+}
+
+func (x *ExecOp) GetServices() []*ServiceContainer {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *ExecOp) GetCheckpoint() *CheckpointOpt {
+	if x != nil {
+		return x.Checkpoint
+	}
+	return nil
+}
+
+func (x *ExecOp) GetRuntime() string {
+	if x != nil {
+		return x.Runtime
+	}
+	return ""
+}
+
+// CheckpointOpt configures checkpointing of the process of an ExecOp.
+type CheckpointOpt struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// timeout in seconds after which the process is checkpointed and stopped.
+	// If it is 0, the process is only checkpointed when the build is cancelled.
+	Timeout       int64 `protobuf:"varint,1,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckpointOpt) Reset() {
+	*x = CheckpointOpt{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckpointOpt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckpointOpt) ProtoMessage() {}
+
+func (x *CheckpointOpt) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckpointOpt.ProtoReflect.Descriptor instead.
+func (*CheckpointOpt) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CheckpointOpt) GetTimeout() int64 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+// ServiceContainer is a long-running container that runs alongside the
+// process of an ExecOp, e.g. a database used by tests.
+type ServiceContainer struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name is the host name that the process can use to reach the service.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// input is the index of the input with the root filesystem of the service.
+	Input         int64           `protobuf:"varint,2,opt,name=input,proto3" json:"input,omitempty"`
+	Meta          *Meta           `protobuf:"bytes,3,opt,name=meta,proto3" json:"meta,omitempty"`
+	Readiness     *ReadinessCheck `protobuf:"bytes,4,opt,name=readiness,proto3" json:"readiness,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceContainer) Reset() {
+	*x = ServiceContainer{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceContainer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceContainer) ProtoMessage() {}
+
+func (x *ServiceContainer) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceContainer.ProtoReflect.Descriptor instead.
+func (*ServiceContainer) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ServiceContainer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ServiceContainer) GetInput() int64 {
+	if x != nil {
+		return x.Input
+	}
+	return 0
+}
+
+func (x *ServiceContainer) GetMeta() *Meta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *ServiceContainer) GetReadiness() *ReadinessCheck {
+	if x != nil {
+		return x.Readiness
+	}
+	return nil
+}
+
+// ReadinessCheck delays the start of the process until a service is ready.
+// All set checks must succeed.
+type ReadinessCheck struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// tcpPort is a port that the service must accept connections on.
+	TcpPort int32 `protobuf:"varint,1,opt,name=tcpPort,proto3" json:"tcpPort,omitempty"`
+	// exec is a command that is run in the service container and must exit
+	// with code 0.
+	Exec []string `protobuf:"bytes,2,rep,name=exec,proto3" json:"exec,omitempty"`
+	// timeout is the maximum time to wait for the service in seconds.
+	Timeout       int64 `protobuf:"varint,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadinessCheck) Reset() {
+	*x = ReadinessCheck{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadinessCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadinessCheck) ProtoMessage() {}
+
+func (x *ReadinessCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadinessCheck.ProtoReflect.Descriptor instead.
+func (*ReadinessCheck) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ReadinessCheck) GetTcpPort() int32 {
+	if x != nil {
+		return x.TcpPort
+	}
+	return 0
+}
+
+func (x *ReadinessCheck) GetExec() []string {
+	if x != nil {
+		return x.Exec
+	}
+	return nil
+}
+
+func (x *ReadinessCheck) GetTimeout() int64 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
 // Meta is a set of arguments for ExecOp.
 // Meta is unrelated to LLB metadata.
 // FIXME: rename (ExecContext? ExecArgs?)
@@ -706,7 +943,7 @@ type Meta struct {
 
 func (x *Meta) Reset() {
 	*x = Meta{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[4]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -718,7 +955,7 @@ func (x *Meta) String() string {
 func (*Meta) ProtoMessage() {}
 
 func (x *Meta) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[4]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -731,7 +968,7 @@ func (x *Meta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Meta.ProtoReflect.Descriptor instead.
 func (*Meta) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{4}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Meta) GetArgs() []string {
@@ -821,7 +1058,7 @@ type HostIP struct {
 
 func (x *HostIP) Reset() {
 	*x = HostIP{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[5]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -833,7 +1070,7 @@ func (x *HostIP) String() string {
 func (*HostIP) ProtoMessage() {}
 
 func (x *HostIP) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[5]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -846,7 +1083,7 @@ func (x *HostIP) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HostIP.ProtoReflect.Descriptor instead.
 func (*HostIP) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{5}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *HostIP) GetHost() string {
@@ -874,7 +1111,7 @@ type Ulimit struct {
 
 func (x *Ulimit) Reset() {
 	*x = Ulimit{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[6]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -886,7 +1123,7 @@ func (x *Ulimit) String() string {
 func (*Ulimit) ProtoMessage() {}
 
 func (x *Ulimit) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[6]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -899,7 +1136,7 @@ func (x *Ulimit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Ulimit.ProtoReflect.Descriptor instead.
 func (*Ulimit) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{6}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Ulimit) GetName() string {
@@ -935,7 +1172,7 @@ type SecretEnv struct {
 
 func (x *SecretEnv) Reset() {
 	*x = SecretEnv{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[7]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -947,7 +1184,7 @@ func (x *SecretEnv) String() string {
 func (*SecretEnv) ProtoMessage() {}
 
 func (x *SecretEnv) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[7]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -960,7 +1197,7 @@ func (x *SecretEnv) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SecretEnv.ProtoReflect.Descriptor instead.
 func (*SecretEnv) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{7}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *SecretEnv) GetID() string {
@@ -998,7 +1235,7 @@ type CDIDevice struct {
 
 func (x *CDIDevice) Reset() {
 	*x = CDIDevice{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[8]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1010,7 +1247,7 @@ func (x *CDIDevice) String() string {
 func (*CDIDevice) ProtoMessage() {}
 
 func (x *CDIDevice) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[8]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1023,7 +1260,7 @@ func (x *CDIDevice) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CDIDevice.ProtoReflect.Descriptor instead.
 func (*CDIDevice) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{8}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *CDIDevice) GetName() string {
@@ -1061,7 +1298,7 @@ type Mount struct {
 
 func (x *Mount) Reset() {
 	*x = Mount{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[9]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1073,7 +1310,7 @@ func (x *Mount) String() string {
 func (*Mount) ProtoMessage() {}
 
 func (x *Mount) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[9]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1086,7 +1323,7 @@ func (x *Mount) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Mount.ProtoReflect.Descriptor instead.
 func (*Mount) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{9}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *Mount) GetInput() int64 {
@@ -1184,7 +1421,7 @@ type TmpfsOpt struct {
 
 func (x *TmpfsOpt) Reset() {
 	*x = TmpfsOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[10]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1196,7 +1433,7 @@ func (x *TmpfsOpt) String() string {
 func (*TmpfsOpt) ProtoMessage() {}
 
 func (x *TmpfsOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[10]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1209,7 +1446,7 @@ func (x *TmpfsOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TmpfsOpt.ProtoReflect.Descriptor instead.
 func (*TmpfsOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{10}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *TmpfsOpt) GetSize() int64 {
@@ -1225,14 +1462,16 @@ type CacheOpt struct {
 	// ID is an optional namespace for the mount
 	ID string `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
 	// Sharing is the sharing mode for the mount
-	Sharing       CacheSharingOpt `protobuf:"varint,2,opt,name=sharing,proto3,enum=pb.CacheSharingOpt" json:"sharing,omitempty"`
+	Sharing CacheSharingOpt `protobuf:"varint,2,opt,name=sharing,proto3,enum=pb.CacheSharingOpt" json:"sharing,omitempty"`
+	// SizeLimit is the maximum size of the mount in bytes
+	SizeLimit     int64 `protobuf:"varint,3,opt,name=sizeLimit,proto3" json:"sizeLimit,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CacheOpt) Reset() {
 	*x = CacheOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[11]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1244,7 +1483,7 @@ func (x *CacheOpt) String() string {
 func (*CacheOpt) ProtoMessage() {}
 
 func (x *CacheOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[11]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1257,7 +1496,7 @@ func (x *CacheOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CacheOpt.ProtoReflect.Descriptor instead.
 func (*CacheOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{11}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *CacheOpt) GetID() string {
@@ -1274,6 +1513,13 @@ func (x *CacheOpt) GetSharing() CacheSharingOpt {
 	return CacheSharingOpt_SHARED
 }
 
+func (x *CacheOpt) GetSizeLimit() int64 {
+	if x != nil {
+		return x.SizeLimit
+	}
+	return 0
+}
+
 // SecretOpt defines options describing secret mounts
 type SecretOpt struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -1294,7 +1540,7 @@ type SecretOpt struct {
 
 func (x *SecretOpt) Reset() {
 	*x = SecretOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[12]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1306,7 +1552,7 @@ func (x *SecretOpt) String() string {
 func (*SecretOpt) ProtoMessage() {}
 
 func (x *SecretOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[12]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1319,7 +1565,7 @@ func (x *SecretOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SecretOpt.ProtoReflect.Descriptor instead.
 func (*SecretOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{12}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *SecretOpt) GetID() string {
@@ -1377,7 +1623,7 @@ type SSHOpt struct {
 
 func (x *SSHOpt) Reset() {
 	*x = SSHOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[13]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1389,7 +1635,7 @@ func (x *SSHOpt) String() string {
 func (*SSHOpt) ProtoMessage() {}
 
 func (x *SSHOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[13]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1402,7 +1648,7 @@ func (x *SSHOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SSHOpt.ProtoReflect.Descriptor instead.
 func (*SSHOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{13}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *SSHOpt) GetID() string {
@@ -1454,7 +1700,7 @@ type SourceOp struct {
 
 func (x *SourceOp) Reset() {
 	*x = SourceOp{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[14]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1466,7 +1712,7 @@ func (x *SourceOp) String() string {
 func (*SourceOp) ProtoMessage() {}
 
 func (x *SourceOp) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[14]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1479,7 +1725,7 @@ func (x *SourceOp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SourceOp.ProtoReflect.Descriptor instead.
 func (*SourceOp) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{14}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *SourceOp) GetIdentifier() string {
@@ -1510,7 +1756,7 @@ type BuildOp struct {
 
 func (x *BuildOp) Reset() {
 	*x = BuildOp{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[15]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1522,7 +1768,7 @@ func (x *BuildOp) String() string {
 func (*BuildOp) ProtoMessage() {}
 
 func (x *BuildOp) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[15]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1535,7 +1781,7 @@ func (x *BuildOp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BuildOp.ProtoReflect.Descriptor instead.
 func (*BuildOp) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{15}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *BuildOp) GetBuilder() int64 {
@@ -1576,7 +1822,7 @@ type BuildInput struct {
 
 func (x *BuildInput) Reset() {
 	*x = BuildInput{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[16]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1588,7 +1834,7 @@ func (x *BuildInput) String() string {
 func (*BuildInput) ProtoMessage() {}
 
 func (x *BuildInput) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[16]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1601,7 +1847,7 @@ func (x *BuildInput) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BuildInput.ProtoReflect.Descriptor instead.
 func (*BuildInput) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{16}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *BuildInput) GetInput() int64 {
@@ -1620,17 +1866,18 @@ type OpMetadata struct {
 	Description map[string]string `protobuf:"bytes,2,rep,name=description,proto3" json:"description,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	// index 3 reserved for WorkerConstraint in previous versions
 	// WorkerConstraint worker_constraint = 3;
-	ExportCache    *ExportCache    `protobuf:"bytes,4,opt,name=export_cache,json=exportCache,proto3" json:"export_cache,omitempty"`
-	Caps           map[string]bool `protobuf:"bytes,5,rep,name=caps,proto3" json:"caps,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
-	ProgressGroup  *ProgressGroup  `protobuf:"bytes,6,opt,name=progress_group,json=progressGroup,proto3" json:"progress_group,omitempty"`
-	LinuxResources *LinuxResources `protobuf:"bytes,7,opt,name=linux_resources,json=linuxResources,proto3" json:"linux_resources,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	ExportCache     *ExportCache     `protobuf:"bytes,4,opt,name=export_cache,json=exportCache,proto3" json:"export_cache,omitempty"`
+	Caps            map[string]bool  `protobuf:"bytes,5,rep,name=caps,proto3" json:"caps,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	ProgressGroup   *ProgressGroup   `protobuf:"bytes,6,opt,name=progress_group,json=progressGroup,proto3" json:"progress_group,omitempty"`
+	LinuxResources  *LinuxResources  `protobuf:"bytes,7,opt,name=linux_resources,json=linuxResources,proto3" json:"linux_resources,omitempty"`
+	ExecutionPolicy *ExecutionPolicy `protobuf:"bytes,8,opt,name=execution_policy,json=executionPolicy,proto3" json:"execution_policy,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *OpMetadata) Reset() {
 	*x = OpMetadata{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[17]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1642,7 +1889,7 @@ func (x *OpMetadata) String() string {
 func (*OpMetadata) ProtoMessage() {}
 
 func (x *OpMetadata) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[17]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1655,7 +1902,7 @@ func (x *OpMetadata) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OpMetadata.ProtoReflect.Descriptor instead.
 func (*OpMetadata) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{17}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *OpMetadata) GetIgnoreCache() bool {
@@ -1700,6 +1947,13 @@ func (x *OpMetadata) GetLinuxResources() *LinuxResources {
 	return nil
 }
 
+func (x *OpMetadata) GetExecutionPolicy() *ExecutionPolicy {
+	if x != nil {
+		return x.ExecutionPolicy
+	}
+	return nil
+}
+
 // Source is a source mapping description for a file
 type Source struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -1711,7 +1965,7 @@ type Source struct {
 
 func (x *Source) Reset() {
 	*x = Source{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[18]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1723,7 +1977,7 @@ func (x *Source) String() string {
 func (*Source) ProtoMessage() {}
 
 func (x *Source) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[18]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1736,7 +1990,7 @@ func (x *Source) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Source.ProtoReflect.Descriptor instead.
 func (*Source) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{18}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *Source) GetLocations() map[string]*Locations {
@@ -1763,7 +2017,7 @@ type Locations struct {
 
 func (x *Locations) Reset() {
 	*x = Locations{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[19]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1775,7 +2029,7 @@ func (x *Locations) String() string {
 func (*Locations) ProtoMessage() {}
 
 func (x *Locations) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[19]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1788,7 +2042,7 @@ func (x *Locations) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Locations.ProtoReflect.Descriptor instead.
 func (*Locations) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{19}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *Locations) GetLocations() []*Location {
@@ -1811,7 +2065,7 @@ type SourceInfo struct {
 
 func (x *SourceInfo) Reset() {
 	*x = SourceInfo{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[20]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1823,7 +2077,7 @@ func (x *SourceInfo) String() string {
 func (*SourceInfo) ProtoMessage() {}
 
 func (x *SourceInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[20]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1836,7 +2090,7 @@ func (x *SourceInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SourceInfo.ProtoReflect.Descriptor instead.
 func (*SourceInfo) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{20}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *SourceInfo) GetFilename() string {
@@ -1878,7 +2132,7 @@ type Location struct {
 
 func (x *Location) Reset() {
 	*x = Location{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[21]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1890,7 +2144,7 @@ func (x *Location) String() string {
 func (*Location) ProtoMessage() {}
 
 func (x *Location) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[21]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1903,7 +2157,7 @@ func (x *Location) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Location.ProtoReflect.Descriptor instead.
 func (*Location) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{21}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *Location) GetSourceIndex() int32 {
@@ -1931,7 +2185,7 @@ type Range struct {
 
 func (x *Range) Reset() {
 	*x = Range{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[22]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1943,7 +2197,7 @@ func (x *Range) String() string {
 func (*Range) ProtoMessage() {}
 
 func (x *Range) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[22]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1956,98 +2210,355 @@ func (x *Range) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Range.ProtoReflect.Descriptor instead.
 func (*Range) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{22}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *Range) GetStart() *Position {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Range) GetEnd() *Position {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+// Position is single location in a source file
+type Position struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Line          int32                  `protobuf:"varint,1,opt,name=line,proto3" json:"line,omitempty"`
+	Character     int32                  `protobuf:"varint,2,opt,name=character,proto3" json:"character,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Position) Reset() {
+	*x = Position{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Position) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Position) ProtoMessage() {}
+
+func (x *Position) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Position.ProtoReflect.Descriptor instead.
+func (*Position) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *Position) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Position) GetCharacter() int32 {
+	if x != nil {
+		return x.Character
+	}
+	return 0
+}
+
+type ExportCache struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         bool                   `protobuf:"varint,1,opt,name=Value,proto3" json:"Value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportCache) Reset() {
+	*x = ExportCache{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportCache) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportCache) ProtoMessage() {}
+
+func (x *ExportCache) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportCache.ProtoReflect.Descriptor instead.
+func (*ExportCache) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ExportCache) GetValue() bool {
+	if x != nil {
+		return x.Value
+	}
+	return false
+}
+
+type ProgressGroup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Weak          bool                   `protobuf:"varint,3,opt,name=weak,proto3" json:"weak,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProgressGroup) Reset() {
+	*x = ProgressGroup{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProgressGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProgressGroup) ProtoMessage() {}
+
+func (x *ProgressGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProgressGroup.ProtoReflect.Descriptor instead.
+func (*ProgressGroup) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ProgressGroup) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ProgressGroup) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProgressGroup) GetWeak() bool {
+	if x != nil {
+		return x.Weak
+	}
+	return false
+}
+
+// LinuxResources specifies cgroup resource limits for containers.
+// Used in OpMetadata to set per-step resource constraints without
+// affecting the cache key.
+type LinuxResources struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Memory               int64                  `protobuf:"varint,1,opt,name=memory,proto3" json:"memory,omitempty"`           // memory limit in bytes
+	MemorySwap           int64                  `protobuf:"varint,2,opt,name=memorySwap,proto3" json:"memorySwap,omitempty"`   // memory+swap limit in bytes
+	CpuShares            uint64                 `protobuf:"varint,3,opt,name=cpuShares,proto3" json:"cpuShares,omitempty"`     // relative CPU weight
+	CpuPeriod            uint64                 `protobuf:"varint,4,opt,name=cpuPeriod,proto3" json:"cpuPeriod,omitempty"`     // CFS period in microseconds
+	CpuQuota             int64                  `protobuf:"varint,5,opt,name=cpuQuota,proto3" json:"cpuQuota,omitempty"`       // CFS quota in microseconds
+	CpusetCpus           string                 `protobuf:"bytes,6,opt,name=cpusetCpus,proto3" json:"cpusetCpus,omitempty"`    // CPU affinity (e.g., "0-3")
+	CpusetMems           string                 `protobuf:"bytes,7,opt,name=cpusetMems,proto3" json:"cpusetMems,omitempty"`    // memory node affinity (e.g., "0,1")
+	PidsLimit            int64                  `protobuf:"varint,8,opt,name=pidsLimit,proto3" json:"pidsLimit,omitempty"`     // maximum number of processes
+	BlkioWeight          uint32                 `protobuf:"varint,9,opt,name=blkioWeight,proto3" json:"blkioWeight,omitempty"` // relative block IO weight (10-1000)
+	BlkioDeviceReadBps   []*LinuxThrottleDevice `protobuf:"bytes,10,rep,name=blkioDeviceReadBps,proto3" json:"blkioDeviceReadBps,omitempty"`
+	BlkioDeviceWriteBps  []*LinuxThrottleDevice `protobuf:"bytes,11,rep,name=blkioDeviceWriteBps,proto3" json:"blkioDeviceWriteBps,omitempty"`
+	BlkioDeviceReadIOps  []*LinuxThrottleDevice `protobuf:"bytes,12,rep,name=blkioDeviceReadIOps,proto3" json:"blkioDeviceReadIOps,omitempty"`
+	BlkioDeviceWriteIOps []*LinuxThrottleDevice `protobuf:"bytes,13,rep,name=blkioDeviceWriteIOps,proto3" json:"blkioDeviceWriteIOps,omitempty"`
+	DiskQuota            int64                  `protobuf:"varint,14,opt,name=diskQuota,proto3" json:"diskQuota,omitempty"` // size limit of the writable root layer in bytes
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *LinuxResources) Reset() {
+	*x = LinuxResources{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinuxResources) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinuxResources) ProtoMessage() {}
+
+func (x *LinuxResources) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinuxResources.ProtoReflect.Descriptor instead.
+func (*LinuxResources) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *LinuxResources) GetMemory() int64 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *LinuxResources) GetMemorySwap() int64 {
+	if x != nil {
+		return x.MemorySwap
+	}
+	return 0
+}
+
+func (x *LinuxResources) GetCpuShares() uint64 {
+	if x != nil {
+		return x.CpuShares
+	}
+	return 0
+}
+
+func (x *LinuxResources) GetCpuPeriod() uint64 {
+	if x != nil {
+		return x.CpuPeriod
+	}
+	return 0
+}
+
+func (x *LinuxResources) GetCpuQuota() int64 {
+	if x != nil {
+		return x.CpuQuota
+	}
+	return 0
+}
+
+func (x *LinuxResources) GetCpusetCpus() string {
+	if x != nil {
+		return x.CpusetCpus
+	}
+	return ""
+}
+
+func (x *LinuxResources) GetCpusetMems() string {
+	if x != nil {
+		return x.CpusetMems
+	}
+	return ""
+}
+
+func (x *LinuxResources) GetPidsLimit() int64 {
+	if x != nil {
+		return x.PidsLimit
+	}
+	return 0
 }
 
-func (x *Range) GetStart() *Position {
+func (x *LinuxResources) GetBlkioWeight() uint32 {
 	if x != nil {
-		return x.Start
+		return x.BlkioWeight
 	}
-	return nil
+	return 0
 }
 
-func (x *Range) GetEnd() *Position {
+func (x *LinuxResources) GetBlkioDeviceReadBps() []*LinuxThrottleDevice {
 	if x != nil {
-		return x.End
+		return x.BlkioDeviceReadBps
 	}
 	return nil
 }
 
-// Position is single location in a source file
-type Position struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Line          int32                  `protobuf:"varint,1,opt,name=line,proto3" json:"line,omitempty"`
-	Character     int32                  `protobuf:"varint,2,opt,name=character,proto3" json:"character,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *Position) Reset() {
-	*x = Position{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[23]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *Position) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*Position) ProtoMessage() {}
-
-func (x *Position) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[23]
+func (x *LinuxResources) GetBlkioDeviceWriteBps() []*LinuxThrottleDevice {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.BlkioDeviceWriteBps
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use Position.ProtoReflect.Descriptor instead.
-func (*Position) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{23}
+func (x *LinuxResources) GetBlkioDeviceReadIOps() []*LinuxThrottleDevice {
+	if x != nil {
+		return x.BlkioDeviceReadIOps
+	}
+	return nil
 }
 
-func (x *Position) GetLine() int32 {
+func (x *LinuxResources) GetBlkioDeviceWriteIOps() []*LinuxThrottleDevice {
 	if x != nil {
-		return x.Line
+		return x.BlkioDeviceWriteIOps
 	}
-	return 0
+	return nil
 }
 
-func (x *Position) GetCharacter() int32 {
+func (x *LinuxResources) GetDiskQuota() int64 {
 	if x != nil {
-		return x.Character
+		return x.DiskQuota
 	}
 	return 0
 }
 
-type ExportCache struct {
+// LinuxThrottleDevice limits the IO rate of a block device.
+type LinuxThrottleDevice struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Value         bool                   `protobuf:"varint,1,opt,name=Value,proto3" json:"Value,omitempty"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`  // path of the block device on the host
+	Rate          uint64                 `protobuf:"varint,2,opt,name=rate,proto3" json:"rate,omitempty"` // bytes or IO operations per second
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExportCache) Reset() {
-	*x = ExportCache{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[24]
+func (x *LinuxThrottleDevice) Reset() {
+	*x = LinuxThrottleDevice{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExportCache) String() string {
+func (x *LinuxThrottleDevice) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExportCache) ProtoMessage() {}
+func (*LinuxThrottleDevice) ProtoMessage() {}
 
-func (x *ExportCache) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[24]
+func (x *LinuxThrottleDevice) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2058,42 +2569,51 @@ func (x *ExportCache) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExportCache.ProtoReflect.Descriptor instead.
-func (*ExportCache) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{24}
+// Deprecated: Use LinuxThrottleDevice.ProtoReflect.Descriptor instead.
+func (*LinuxThrottleDevice) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *ExportCache) GetValue() bool {
+func (x *LinuxThrottleDevice) GetPath() string {
 	if x != nil {
-		return x.Value
+		return x.Path
 	}
-	return false
+	return ""
 }
 
-type ProgressGroup struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Weak          bool                   `protobuf:"varint,3,opt,name=weak,proto3" json:"weak,omitempty"`
+func (x *LinuxThrottleDevice) GetRate() uint64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+// ExecutionPolicy controls how the solver runs an Op. It is not part of the
+// cache key.
+type ExecutionPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// timeout in nanoseconds for a single attempt to run the Op
+	Timeout       int64        `protobuf:"varint,1,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	Retry         *RetryPolicy `protobuf:"bytes,2,opt,name=retry,proto3" json:"retry,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ProgressGroup) Reset() {
-	*x = ProgressGroup{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[25]
+func (x *ExecutionPolicy) Reset() {
+	*x = ExecutionPolicy{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ProgressGroup) String() string {
+func (x *ExecutionPolicy) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ProgressGroup) ProtoMessage() {}
+func (*ExecutionPolicy) ProtoMessage() {}
 
-func (x *ProgressGroup) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[25]
+func (x *ExecutionPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2104,63 +2624,58 @@ func (x *ProgressGroup) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ProgressGroup.ProtoReflect.Descriptor instead.
-func (*ProgressGroup) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{25}
-}
-
-func (x *ProgressGroup) GetId() string {
-	if x != nil {
-		return x.Id
-	}
-	return ""
+// Deprecated: Use ExecutionPolicy.ProtoReflect.Descriptor instead.
+func (*ExecutionPolicy) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *ProgressGroup) GetName() string {
+func (x *ExecutionPolicy) GetTimeout() int64 {
 	if x != nil {
-		return x.Name
+		return x.Timeout
 	}
-	return ""
+	return 0
 }
 
-func (x *ProgressGroup) GetWeak() bool {
+func (x *ExecutionPolicy) GetRetry() *RetryPolicy {
 	if x != nil {
-		return x.Weak
+		return x.Retry
 	}
-	return false
+	return nil
 }
 
-// LinuxResources specifies cgroup resource limits for containers.
-// Used in OpMetadata to set per-step resource constraints without
-// affecting the cache key.
-type LinuxResources struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Memory        int64                  `protobuf:"varint,1,opt,name=memory,proto3" json:"memory,omitempty"`         // memory limit in bytes
-	MemorySwap    int64                  `protobuf:"varint,2,opt,name=memorySwap,proto3" json:"memorySwap,omitempty"` // memory+swap limit in bytes
-	CpuShares     uint64                 `protobuf:"varint,3,opt,name=cpuShares,proto3" json:"cpuShares,omitempty"`   // relative CPU weight
-	CpuPeriod     uint64                 `protobuf:"varint,4,opt,name=cpuPeriod,proto3" json:"cpuPeriod,omitempty"`   // CFS period in microseconds
-	CpuQuota      int64                  `protobuf:"varint,5,opt,name=cpuQuota,proto3" json:"cpuQuota,omitempty"`     // CFS quota in microseconds
-	CpusetCpus    string                 `protobuf:"bytes,6,opt,name=cpusetCpus,proto3" json:"cpusetCpus,omitempty"`  // CPU affinity (e.g., "0-3")
-	CpusetMems    string                 `protobuf:"bytes,7,opt,name=cpusetMems,proto3" json:"cpusetMems,omitempty"`  // memory node affinity (e.g., "0,1")
+// RetryPolicy retries an Op that failed. If no exit codes or error classes
+// are set, all errors are retried.
+type RetryPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// max_attempts is the total number of attempts including the first one
+	MaxAttempts int64 `protobuf:"varint,1,opt,name=max_attempts,json=maxAttempts,proto3" json:"max_attempts,omitempty"`
+	// backoff in nanoseconds before the first retry, doubled on every retry
+	Backoff int64 `protobuf:"varint,2,opt,name=backoff,proto3" json:"backoff,omitempty"`
+	// max_backoff in nanoseconds limits the backoff between retries
+	MaxBackoff int64 `protobuf:"varint,3,opt,name=max_backoff,json=maxBackoff,proto3" json:"max_backoff,omitempty"`
+	// exit_codes of an exec process that are retried
+	ExitCodes []int32 `protobuf:"varint,4,rep,packed,name=exit_codes,json=exitCodes,proto3" json:"exit_codes,omitempty"`
+	// error_classes that are retried: "network" or "timeout"
+	ErrorClasses  []string `protobuf:"bytes,5,rep,name=error_classes,json=errorClasses,proto3" json:"error_classes,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LinuxResources) Reset() {
-	*x = LinuxResources{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[26]
+func (x *RetryPolicy) Reset() {
+	*x = RetryPolicy{}
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LinuxResources) String() string {
+func (x *RetryPolicy) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LinuxResources) ProtoMessage() {}
+func (*RetryPolicy) ProtoMessage() {}
 
-func (x *LinuxResources) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[26]
+func (x *RetryPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2171,58 +2686,44 @@ func (x *LinuxResources) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LinuxResources.ProtoReflect.Descriptor instead.
-func (*LinuxResources) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{26}
-}
-
-func (x *LinuxResources) GetMemory() int64 {
-	if x != nil {
-		return x.Memory
-	}
-	return 0
-}
-
-func (x *LinuxResources) GetMemorySwap() int64 {
-	if x != nil {
-		return x.MemorySwap
-	}
-	return 0
+// Deprecated: Use RetryPolicy.ProtoReflect.Descriptor instead.
+func (*RetryPolicy) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *LinuxResources) GetCpuShares() uint64 {
+func (x *RetryPolicy) GetMaxAttempts() int64 {
 	if x != nil {
-		return x.CpuShares
+		return x.MaxAttempts
 	}
 	return 0
 }
 
-func (x *LinuxResources) GetCpuPeriod() uint64 {
+func (x *RetryPolicy) GetBackoff() int64 {
 	if x != nil {
-		return x.CpuPeriod
+		return x.Backoff
 	}
 	return 0
 }
 
-func (x *LinuxResources) GetCpuQuota() int64 {
+func (x *RetryPolicy) GetMaxBackoff() int64 {
 	if x != nil {
-		return x.CpuQuota
+		return x.MaxBackoff
 	}
 	return 0
 }
 
-func (x *LinuxResources) GetCpusetCpus() string {
+func (x *RetryPolicy) GetExitCodes() []int32 {
 	if x != nil {
-		return x.CpusetCpus
+		return x.ExitCodes
 	}
-	return ""
+	return nil
 }
 
-func (x *LinuxResources) GetCpusetMems() string {
+func (x *RetryPolicy) GetErrorClasses() []string {
 	if x != nil {
-		return x.CpusetMems
+		return x.ErrorClasses
 	}
-	return ""
+	return nil
 }
 
 type ProxyEnv struct {
@@ -2238,7 +2739,7 @@ type ProxyEnv struct {
 
 func (x *ProxyEnv) Reset() {
 	*x = ProxyEnv{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[27]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2250,7 +2751,7 @@ func (x *ProxyEnv) String() string {
 func (*ProxyEnv) ProtoMessage() {}
 
 func (x *ProxyEnv) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[27]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2263,7 +2764,7 @@ func (x *ProxyEnv) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProxyEnv.ProtoReflect.Descriptor instead.
 func (*ProxyEnv) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{27}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *ProxyEnv) GetHttpProxy() string {
@@ -2311,7 +2812,7 @@ type WorkerConstraints struct {
 
 func (x *WorkerConstraints) Reset() {
 	*x = WorkerConstraints{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[28]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2323,7 +2824,7 @@ func (x *WorkerConstraints) String() string {
 func (*WorkerConstraints) ProtoMessage() {}
 
 func (x *WorkerConstraints) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[28]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2336,7 +2837,7 @@ func (x *WorkerConstraints) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WorkerConstraints.ProtoReflect.Descriptor instead.
 func (*WorkerConstraints) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{28}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *WorkerConstraints) GetFilter() []string {
@@ -2362,7 +2863,7 @@ type Definition struct {
 
 func (x *Definition) Reset() {
 	*x = Definition{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[29]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2374,7 +2875,7 @@ func (x *Definition) String() string {
 func (*Definition) ProtoMessage() {}
 
 func (x *Definition) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[29]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2387,7 +2888,7 @@ func (x *Definition) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Definition.ProtoReflect.Descriptor instead.
 func (*Definition) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{29}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *Definition) GetDef() [][]byte {
@@ -2420,7 +2921,7 @@ type FileOp struct {
 
 func (x *FileOp) Reset() {
 	*x = FileOp{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[30]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2432,7 +2933,7 @@ func (x *FileOp) String() string {
 func (*FileOp) ProtoMessage() {}
 
 func (x *FileOp) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[30]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2445,7 +2946,7 @@ func (x *FileOp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileOp.ProtoReflect.Descriptor instead.
 func (*FileOp) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{30}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *FileOp) GetActions() []*FileAction {
@@ -2475,7 +2976,7 @@ type FileAction struct {
 
 func (x *FileAction) Reset() {
 	*x = FileAction{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[31]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2487,7 +2988,7 @@ func (x *FileAction) String() string {
 func (*FileAction) ProtoMessage() {}
 
 func (x *FileAction) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[31]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2500,7 +3001,7 @@ func (x *FileAction) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileAction.ProtoReflect.Descriptor instead.
 func (*FileAction) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{31}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *FileAction) GetInput() int64 {
@@ -2656,7 +3157,7 @@ type FileActionCopy struct {
 
 func (x *FileActionCopy) Reset() {
 	*x = FileActionCopy{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[32]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2668,7 +3169,7 @@ func (x *FileActionCopy) String() string {
 func (*FileActionCopy) ProtoMessage() {}
 
 func (x *FileActionCopy) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[32]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2681,7 +3182,7 @@ func (x *FileActionCopy) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileActionCopy.ProtoReflect.Descriptor instead.
 func (*FileActionCopy) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{32}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *FileActionCopy) GetSrc() string {
@@ -2814,7 +3315,7 @@ type FileActionMkFile struct {
 
 func (x *FileActionMkFile) Reset() {
 	*x = FileActionMkFile{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[33]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2826,7 +3327,7 @@ func (x *FileActionMkFile) String() string {
 func (*FileActionMkFile) ProtoMessage() {}
 
 func (x *FileActionMkFile) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[33]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2839,7 +3340,7 @@ func (x *FileActionMkFile) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileActionMkFile.ProtoReflect.Descriptor instead.
 func (*FileActionMkFile) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{33}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{39}
 }
 
 func (x *FileActionMkFile) GetPath() string {
@@ -2893,7 +3394,7 @@ type FileActionSymlink struct {
 
 func (x *FileActionSymlink) Reset() {
 	*x = FileActionSymlink{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[34]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2905,7 +3406,7 @@ func (x *FileActionSymlink) String() string {
 func (*FileActionSymlink) ProtoMessage() {}
 
 func (x *FileActionSymlink) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[34]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2918,7 +3419,7 @@ func (x *FileActionSymlink) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileActionSymlink.ProtoReflect.Descriptor instead.
 func (*FileActionSymlink) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{34}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{40}
 }
 
 func (x *FileActionSymlink) GetOldpath() string {
@@ -2967,7 +3468,7 @@ type FileActionMkDir struct {
 
 func (x *FileActionMkDir) Reset() {
 	*x = FileActionMkDir{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[35]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2979,7 +3480,7 @@ func (x *FileActionMkDir) String() string {
 func (*FileActionMkDir) ProtoMessage() {}
 
 func (x *FileActionMkDir) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[35]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2992,7 +3493,7 @@ func (x *FileActionMkDir) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileActionMkDir.ProtoReflect.Descriptor instead.
 func (*FileActionMkDir) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{35}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{41}
 }
 
 func (x *FileActionMkDir) GetPath() string {
@@ -3044,7 +3545,7 @@ type FileActionRm struct {
 
 func (x *FileActionRm) Reset() {
 	*x = FileActionRm{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[36]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[42]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3056,7 +3557,7 @@ func (x *FileActionRm) String() string {
 func (*FileActionRm) ProtoMessage() {}
 
 func (x *FileActionRm) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[36]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[42]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3069,7 +3570,7 @@ func (x *FileActionRm) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileActionRm.ProtoReflect.Descriptor instead.
 func (*FileActionRm) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{36}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{42}
 }
 
 func (x *FileActionRm) GetPath() string {
@@ -3103,7 +3604,7 @@ type ChownOpt struct {
 
 func (x *ChownOpt) Reset() {
 	*x = ChownOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[37]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[43]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3115,7 +3616,7 @@ func (x *ChownOpt) String() string {
 func (*ChownOpt) ProtoMessage() {}
 
 func (x *ChownOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[37]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[43]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3128,7 +3629,7 @@ func (x *ChownOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChownOpt.ProtoReflect.Descriptor instead.
 func (*ChownOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{37}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{43}
 }
 
 func (x *ChownOpt) GetUser() *UserOpt {
@@ -3160,7 +3661,7 @@ type UserOpt struct {
 
 func (x *UserOpt) Reset() {
 	*x = UserOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[38]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[44]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3172,7 +3673,7 @@ func (x *UserOpt) String() string {
 func (*UserOpt) ProtoMessage() {}
 
 func (x *UserOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[38]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[44]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3185,7 +3686,7 @@ func (x *UserOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserOpt.ProtoReflect.Descriptor instead.
 func (*UserOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{38}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{44}
 }
 
 func (x *UserOpt) GetUser() isUserOpt_User {
@@ -3239,7 +3740,7 @@ type NamedUserOpt struct {
 
 func (x *NamedUserOpt) Reset() {
 	*x = NamedUserOpt{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[39]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[45]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3251,7 +3752,7 @@ func (x *NamedUserOpt) String() string {
 func (*NamedUserOpt) ProtoMessage() {}
 
 func (x *NamedUserOpt) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[39]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[45]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3264,7 +3765,7 @@ func (x *NamedUserOpt) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NamedUserOpt.ProtoReflect.Descriptor instead.
 func (*NamedUserOpt) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{39}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{45}
 }
 
 func (x *NamedUserOpt) GetName() string {
@@ -3290,7 +3791,7 @@ type MergeInput struct {
 
 func (x *MergeInput) Reset() {
 	*x = MergeInput{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[40]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[46]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3302,7 +3803,7 @@ func (x *MergeInput) String() string {
 func (*MergeInput) ProtoMessage() {}
 
 func (x *MergeInput) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[40]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[46]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3315,7 +3816,7 @@ func (x *MergeInput) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeInput.ProtoReflect.Descriptor instead.
 func (*MergeInput) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{40}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{46}
 }
 
 func (x *MergeInput) GetInput() int64 {
@@ -3334,7 +3835,7 @@ type MergeOp struct {
 
 func (x *MergeOp) Reset() {
 	*x = MergeOp{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[41]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[47]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3346,7 +3847,7 @@ func (x *MergeOp) String() string {
 func (*MergeOp) ProtoMessage() {}
 
 func (x *MergeOp) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[41]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[47]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3359,7 +3860,7 @@ func (x *MergeOp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeOp.ProtoReflect.Descriptor instead.
 func (*MergeOp) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{41}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{47}
 }
 
 func (x *MergeOp) GetInputs() []*MergeInput {
@@ -3378,7 +3879,7 @@ type LowerDiffInput struct {
 
 func (x *LowerDiffInput) Reset() {
 	*x = LowerDiffInput{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[42]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[48]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3390,7 +3891,7 @@ func (x *LowerDiffInput) String() string {
 func (*LowerDiffInput) ProtoMessage() {}
 
 func (x *LowerDiffInput) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[42]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[48]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3403,7 +3904,7 @@ func (x *LowerDiffInput) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LowerDiffInput.ProtoReflect.Descriptor instead.
 func (*LowerDiffInput) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{42}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{48}
 }
 
 func (x *LowerDiffInput) GetInput() int64 {
@@ -3422,7 +3923,7 @@ type UpperDiffInput struct {
 
 func (x *UpperDiffInput) Reset() {
 	*x = UpperDiffInput{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[43]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[49]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3434,7 +3935,7 @@ func (x *UpperDiffInput) String() string {
 func (*UpperDiffInput) ProtoMessage() {}
 
 func (x *UpperDiffInput) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[43]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[49]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3447,7 +3948,7 @@ func (x *UpperDiffInput) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpperDiffInput.ProtoReflect.Descriptor instead.
 func (*UpperDiffInput) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{43}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{49}
 }
 
 func (x *UpperDiffInput) GetInput() int64 {
@@ -3467,7 +3968,7 @@ type DiffOp struct {
 
 func (x *DiffOp) Reset() {
 	*x = DiffOp{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[44]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[50]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3479,7 +3980,7 @@ func (x *DiffOp) String() string {
 func (*DiffOp) ProtoMessage() {}
 
 func (x *DiffOp) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[44]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[50]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3492,7 +3993,7 @@ func (x *DiffOp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DiffOp.ProtoReflect.Descriptor instead.
 func (*DiffOp) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{44}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{50}
 }
 
 func (x *DiffOp) GetLower() *LowerDiffInput {
@@ -3519,7 +4020,7 @@ type PassthroughOp struct {
 
 func (x *PassthroughOp) Reset() {
 	*x = PassthroughOp{}
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[45]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[51]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3531,7 +4032,7 @@ func (x *PassthroughOp) String() string {
 func (*PassthroughOp) ProtoMessage() {}
 
 func (x *PassthroughOp) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[45]
+	mi := &file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[51]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3544,7 +4045,7 @@ func (x *PassthroughOp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PassthroughOp.ProtoReflect.Descriptor instead.
 func (*PassthroughOp) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{45}
+	return file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP(), []int{51}
 }
 
 func (x *PassthroughOp) GetId() string {
@@ -3592,7 +4093,7 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"OSFeatures\"5\n" +
 	"\x05Input\x12\x16\n" +
 	"\x06digest\x18\x01 \x01(\tR\x06digest\x12\x14\n" +
-	"\x05index\x18\x02 \x01(\x03R\x05index\"\xfa\x01\n" +
+	"\x05index\x18\x02 \x01(\x03R\x05index\"\xc5\x03\n" +
 	"\x06ExecOp\x12\x1c\n" +
 	"\x04meta\x18\x01 \x01(\v2\b.pb.MetaR\x04meta\x12!\n" +
 	"\x06mounts\x18\x02 \x03(\v2\t.pb.MountR\x06mounts\x12%\n" +
@@ -3601,7 +4102,26 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"\tsecretenv\x18\x05 \x03(\v2\r.pb.SecretEnvR\tsecretenv\x12-\n" +
 	"\n" +
 	"cdiDevices\x18\x06 \x03(\v2\r.pb.CDIDeviceR\n" +
-	"cdiDevices\"\xf3\x02\n" +
+	"cdiDevices\x12(\n" +
+	"\x0fsecurityProfile\x18\a \x01(\tR\x0fsecurityProfile\x12 \n" +
+	"\vnetworkName\x18\b \x01(\tR\vnetworkName\x120\n" +
+	"\bservices\x18\t \x03(\v2\x14.pb.ServiceContainerR\bservices\x121\n" +
+	"\n" +
+	"checkpoint\x18\n" +
+	" \x01(\v2\x11.pb.CheckpointOptR\n" +
+	"checkpoint\x12\x18\n" +
+	"\aruntime\x18\v \x01(\tR\aruntime\")\n" +
+	"\rCheckpointOpt\x12\x18\n" +
+	"\atimeout\x18\x01 \x01(\x03R\atimeout\"\x8c\x01\n" +
+	"\x10ServiceContainer\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05input\x18\x02 \x01(\x03R\x05input\x12\x1c\n" +
+	"\x04meta\x18\x03 \x01(\v2\b.pb.MetaR\x04meta\x120\n" +
+	"\treadiness\x18\x04 \x01(\v2\x12.pb.ReadinessCheckR\treadiness\"X\n" +
+	"\x0eReadinessCheck\x12\x18\n" +
+	"\atcpPort\x18\x01 \x01(\x05R\atcpPort\x12\x12\n" +
+	"\x04exec\x18\x02 \x03(\tR\x04exec\x12\x18\n" +
+	"\atimeout\x18\x03 \x01(\x03R\atimeout\"\xf3\x02\n" +
 	"\x04Meta\x12\x12\n" +
 	"\x04args\x18\x01 \x03(\tR\x04args\x12\x10\n" +
 	"\x03env\x18\x02 \x03(\tR\x03env\x12\x10\n" +
@@ -3648,10 +4168,11 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"\bresultID\x18\x17 \x01(\tR\bresultID\x129\n" +
 	"\fcontentCache\x18\x18 \x01(\x0e2\x15.pb.MountContentCacheR\fcontentCache\"\x1e\n" +
 	"\bTmpfsOpt\x12\x12\n" +
-	"\x04size\x18\x01 \x01(\x03R\x04size\"I\n" +
+	"\x04size\x18\x01 \x01(\x03R\x04size\"g\n" +
 	"\bCacheOpt\x12\x0e\n" +
 	"\x02ID\x18\x01 \x01(\tR\x02ID\x12-\n" +
-	"\asharing\x18\x02 \x01(\x0e2\x13.pb.CacheSharingOptR\asharing\"o\n" +
+	"\asharing\x18\x02 \x01(\x0e2\x13.pb.CacheSharingOptR\asharing\x12\x1c\n" +
+	"\tsizeLimit\x18\x03 \x01(\x03R\tsizeLimit\"o\n" +
 	"\tSecretOpt\x12\x0e\n" +
 	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x10\n" +
 	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x10\n" +
@@ -3687,7 +4208,7 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\"\n" +
 	"\n" +
 	"BuildInput\x12\x14\n" +
-	"\x05input\x18\x01 \x01(\x03R\x05input\"\xc4\x03\n" +
+	"\x05input\x18\x01 \x01(\x03R\x05input\"\x84\x04\n" +
 	"\n" +
 	"OpMetadata\x12!\n" +
 	"\fignore_cache\x18\x01 \x01(\bR\vignoreCache\x12A\n" +
@@ -3695,7 +4216,8 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"\fexport_cache\x18\x04 \x01(\v2\x0f.pb.ExportCacheR\vexportCache\x12,\n" +
 	"\x04caps\x18\x05 \x03(\v2\x18.pb.OpMetadata.CapsEntryR\x04caps\x128\n" +
 	"\x0eprogress_group\x18\x06 \x01(\v2\x11.pb.ProgressGroupR\rprogressGroup\x12;\n" +
-	"\x0flinux_resources\x18\a \x01(\v2\x12.pb.LinuxResourcesR\x0elinuxResources\x1a>\n" +
+	"\x0flinux_resources\x18\a \x01(\v2\x12.pb.LinuxResourcesR\x0elinuxResources\x12>\n" +
+	"\x10execution_policy\x18\b \x01(\v2\x13.pb.ExecutionPolicyR\x0fexecutionPolicy\x1a>\n" +
 	"\x10DescriptionEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a7\n" +
@@ -3732,7 +4254,7 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"\rProgressGroup\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
-	"\x04weak\x18\x03 \x01(\bR\x04weak\"\xe0\x01\n" +
+	"\x04weak\x18\x03 \x01(\bR\x04weak\"\xea\x04\n" +
 	"\x0eLinuxResources\x12\x16\n" +
 	"\x06memory\x18\x01 \x01(\x03R\x06memory\x12\x1e\n" +
 	"\n" +
@@ -3746,7 +4268,29 @@ const file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc = "" +
 	"cpusetCpus\x12\x1e\n" +
 	"\n" +
 	"cpusetMems\x18\a \x01(\tR\n" +
-	"cpusetMems\"\x9f\x01\n" +
+	"cpusetMems\x12\x1c\n" +
+	"\tpidsLimit\x18\b \x01(\x03R\tpidsLimit\x12 \n" +
+	"\vblkioWeight\x18\t \x01(\rR\vblkioWeight\x12G\n" +
+	"\x12blkioDeviceReadBps\x18\n" +
+	" \x03(\v2\x17.pb.LinuxThrottleDeviceR\x12blkioDeviceReadBps\x12I\n" +
+	"\x13blkioDeviceWriteBps\x18\v \x03(\v2\x17.pb.LinuxThrottleDeviceR\x13blkioDeviceWriteBps\x12I\n" +
+	"\x13blkioDeviceReadIOps\x18\f \x03(\v2\x17.pb.LinuxThrottleDeviceR\x13blkioDeviceReadIOps\x12K\n" +
+	"\x14blkioDeviceWriteIOps\x18\r \x03(\v2\x17.pb.LinuxThrottleDeviceR\x14blkioDeviceWriteIOps\x12\x1c\n" +
+	"\tdiskQuota\x18\x0e \x01(\x03R\tdiskQuota\"=\n" +
+	"\x13LinuxThrottleDevice\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04rate\x18\x02 \x01(\x04R\x04rate\"R\n" +
+	"\x0fExecutionPolicy\x12\x18\n" +
+	"\atimeout\x18\x01 \x01(\x03R\atimeout\x12%\n" +
+	"\x05retry\x18\x02 \x01(\v2\x0f.pb.RetryPolicyR\x05retry\"\xaf\x01\n" +
+	"\vRetryPolicy\x12!\n" +
+	"\fmax_attempts\x18\x01 \x01(\x03R\vmaxAttempts\x12\x18\n" +
+	"\abackoff\x18\x02 \x01(\x03R\abackoff\x12\x1f\n" +
+	"\vmax_backoff\x18\x03 \x01(\x03R\n" +
+	"maxBackoff\x12\x1d\n" +
+	"\n" +
+	"exit_codes\x18\x04 \x03(\x05R\texitCodes\x12#\n" +
+	"\rerror_classes\x18\x05 \x03(\tR\ferrorClasses\"\x9f\x01\n" +
 	"\bProxyEnv\x12\x1d\n" +
 	"\n" +
 	"http_proxy\x18\x01 \x01(\tR\thttpProxy\x12\x1f\n" +
@@ -3881,136 +4425,152 @@ func file_github_com_moby_buildkit_solver_pb_ops_proto_rawDescGZIP() []byte {
 }
 
 var file_github_com_moby_buildkit_solver_pb_ops_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
-var file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes = make([]protoimpl.MessageInfo, 53)
+var file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes = make([]protoimpl.MessageInfo, 59)
 var file_github_com_moby_buildkit_solver_pb_ops_proto_goTypes = []any{
-	(NetMode)(0),              // 0: pb.NetMode
-	(SecurityMode)(0),         // 1: pb.SecurityMode
-	(MountType)(0),            // 2: pb.MountType
-	(MountContentCache)(0),    // 3: pb.MountContentCache
-	(CacheSharingOpt)(0),      // 4: pb.CacheSharingOpt
-	(*Op)(nil),                // 5: pb.Op
-	(*Platform)(nil),          // 6: pb.Platform
-	(*Input)(nil),             // 7: pb.Input
-	(*ExecOp)(nil),            // 8: pb.ExecOp
-	(*Meta)(nil),              // 9: pb.Meta
-	(*HostIP)(nil),            // 10: pb.HostIP
-	(*Ulimit)(nil),            // 11: pb.Ulimit
-	(*SecretEnv)(nil),         // 12: pb.SecretEnv
-	(*CDIDevice)(nil),         // 13: pb.CDIDevice
-	(*Mount)(nil),             // 14: pb.Mount
-	(*TmpfsOpt)(nil),          // 15: pb.TmpfsOpt
-	(*CacheOpt)(nil),          // 16: pb.CacheOpt
-	(*SecretOpt)(nil),         // 17: pb.SecretOpt
-	(*SSHOpt)(nil),            // 18: pb.SSHOpt
-	(*SourceOp)(nil),          // 19: pb.SourceOp
-	(*BuildOp)(nil),           // 20: pb.BuildOp
-	(*BuildInput)(nil),        // 21: pb.BuildInput
-	(*OpMetadata)(nil),        // 22: pb.OpMetadata
-	(*Source)(nil),            // 23: pb.Source
-	(*Locations)(nil),         // 24: pb.Locations
-	(*SourceInfo)(nil),        // 25: pb.SourceInfo
-	(*Location)(nil),          // 26: pb.Location
-	(*Range)(nil),             // 27: pb.Range
-	(*Position)(nil),          // 28: pb.Position
-	(*ExportCache)(nil),       // 29: pb.ExportCache
-	(*ProgressGroup)(nil),     // 30: pb.ProgressGroup
-	(*LinuxResources)(nil),    // 31: pb.LinuxResources
-	(*ProxyEnv)(nil),          // 32: pb.ProxyEnv
-	(*WorkerConstraints)(nil), // 33: pb.WorkerConstraints
-	(*Definition)(nil),        // 34: pb.Definition
-	(*FileOp)(nil),            // 35: pb.FileOp
-	(*FileAction)(nil),        // 36: pb.FileAction
-	(*FileActionCopy)(nil),    // 37: pb.FileActionCopy
-	(*FileActionMkFile)(nil),  // 38: pb.FileActionMkFile
-	(*FileActionSymlink)(nil), // 39: pb.FileActionSymlink
-	(*FileActionMkDir)(nil),   // 40: pb.FileActionMkDir
-	(*FileActionRm)(nil),      // 41: pb.FileActionRm
-	(*ChownOpt)(nil),          // 42: pb.ChownOpt
-	(*UserOpt)(nil),           // 43: pb.UserOpt
-	(*NamedUserOpt)(nil),      // 44: pb.NamedUserOpt
-	(*MergeInput)(nil),        // 45: pb.MergeInput
-	(*MergeOp)(nil),           // 46: pb.MergeOp
-	(*LowerDiffInput)(nil),    // 47: pb.LowerDiffInput
-	(*UpperDiffInput)(nil),    // 48: pb.UpperDiffInput
-	(*DiffOp)(nil),            // 49: pb.DiffOp
-	(*PassthroughOp)(nil),     // 50: pb.PassthroughOp
-	nil,                       // 51: pb.SourceOp.AttrsEntry
-	nil,                       // 52: pb.BuildOp.InputsEntry
-	nil,                       // 53: pb.BuildOp.AttrsEntry
-	nil,                       // 54: pb.OpMetadata.DescriptionEntry
-	nil,                       // 55: pb.OpMetadata.CapsEntry
-	nil,                       // 56: pb.Source.LocationsEntry
-	nil,                       // 57: pb.Definition.MetadataEntry
+	(NetMode)(0),                // 0: pb.NetMode
+	(SecurityMode)(0),           // 1: pb.SecurityMode
+	(MountType)(0),              // 2: pb.MountType
+	(MountContentCache)(0),      // 3: pb.MountContentCache
+	(CacheSharingOpt)(0),        // 4: pb.CacheSharingOpt
+	(*Op)(nil),                  // 5: pb.Op
+	(*Platform)(nil),            // 6: pb.Platform
+	(*Input)(nil),               // 7: pb.Input
+	(*ExecOp)(nil),              // 8: pb.ExecOp
+	(*CheckpointOpt)(nil),       // 9: pb.CheckpointOpt
+	(*ServiceContainer)(nil),    // 10: pb.ServiceContainer
+	(*ReadinessCheck)(nil),      // 11: pb.ReadinessCheck
+	(*Meta)(nil),                // 12: pb.Meta
+	(*HostIP)(nil),              // 13: pb.HostIP
+	(*Ulimit)(nil),              // 14: pb.Ulimit
+	(*SecretEnv)(nil),           // 15: pb.SecretEnv
+	(*CDIDevice)(nil),           // 16: pb.CDIDevice
+	(*Mount)(nil),               // 17: pb.Mount
+	(*TmpfsOpt)(nil),            // 18: pb.TmpfsOpt
+	(*CacheOpt)(nil),            // 19: pb.CacheOpt
+	(*SecretOpt)(nil),           // 20: pb.SecretOpt
+	(*SSHOpt)(nil),              // 21: pb.SSHOpt
+	(*SourceOp)(nil),            // 22: pb.SourceOp
+	(*BuildOp)(nil),             // 23: pb.BuildOp
+	(*BuildInput)(nil),          // 24: pb.BuildInput
+	(*OpMetadata)(nil),          // 25: pb.OpMetadata
+	(*Source)(nil),              // 26: pb.Source
+	(*Locations)(nil),           // 27: pb.Locations
+	(*SourceInfo)(nil),          // 28: pb.SourceInfo
+	(*Location)(nil),            // 29: pb.Location
+	(*Range)(nil),               // 30: pb.Range
+	(*Position)(nil),            // 31: pb.Position
+	(*ExportCache)(nil),         // 32: pb.ExportCache
+	(*ProgressGroup)(nil),       // 33: pb.ProgressGroup
+	(*LinuxResources)(nil),      // 34: pb.LinuxResources
+	(*LinuxThrottleDevice)(nil), // 35: pb.LinuxThrottleDevice
+	(*ExecutionPolicy)(nil),     // 36: pb.ExecutionPolicy
+	(*RetryPolicy)(nil),         // 37: pb.RetryPolicy
+	(*ProxyEnv)(nil),            // 38: pb.ProxyEnv
+	(*WorkerConstraints)(nil),   // 39: pb.WorkerConstraints
+	(*Definition)(nil),          // 40: pb.Definition
+	(*FileOp)(nil),              // 41: pb.FileOp
+	(*FileAction)(nil),          // 42: pb.FileAction
+	(*FileActionCopy)(nil),      // 43: pb.FileActionCopy
+	(*FileActionMkFile)(nil),    // 44: pb.FileActionMkFile
+	(*FileActionSymlink)(nil),   // 45: pb.FileActionSymlink
+	(*FileActionMkDir)(nil),     // 46: pb.FileActionMkDir
+	(*FileActionRm)(nil),        // 47: pb.FileActionRm
+	(*ChownOpt)(nil),            // 48: pb.ChownOpt
+	(*UserOpt)(nil),             // 49: pb.UserOpt
+	(*NamedUserOpt)(nil),        // 50: pb.NamedUserOpt
+	(*MergeInput)(nil),          // 51: pb.MergeInput
+	(*MergeOp)(nil),             // 52: pb.MergeOp
+	(*LowerDiffInput)(nil),      // 53: pb.LowerDiffInput
+	(*UpperDiffInput)(nil),      // 54: pb.UpperDiffInput
+	(*DiffOp)(nil),              // 55: pb.DiffOp
+	(*PassthroughOp)(nil),       // 56: pb.PassthroughOp
+	nil,                         // 57: pb.SourceOp.AttrsEntry
+	nil,                         // 58: pb.BuildOp.InputsEntry
+	nil,                         // 59: pb.BuildOp.AttrsEntry
+	nil,                         // 60: pb.OpMetadata.DescriptionEntry
+	nil,                         // 61: pb.OpMetadata.CapsEntry
+	nil,                         // 62: pb.Source.LocationsEntry
+	nil,                         // 63: pb.Definition.MetadataEntry
 }
 var file_github_com_moby_buildkit_solver_pb_ops_proto_depIdxs = []int32{
 	7,  // 0: pb.Op.inputs:type_name -> pb.Input
 	8,  // 1: pb.Op.exec:type_name -> pb.ExecOp
-	19, // 2: pb.Op.source:type_name -> pb.SourceOp
-	35, // 3: pb.Op.file:type_name -> pb.FileOp
-	20, // 4: pb.Op.build:type_name -> pb.BuildOp
-	46, // 5: pb.Op.merge:type_name -> pb.MergeOp
-	49, // 6: pb.Op.diff:type_name -> pb.DiffOp
-	50, // 7: pb.Op.passthrough:type_name -> pb.PassthroughOp
+	22, // 2: pb.Op.source:type_name -> pb.SourceOp
+	41, // 3: pb.Op.file:type_name -> pb.FileOp
+	23, // 4: pb.Op.build:type_name -> pb.BuildOp
+	52, // 5: pb.Op.merge:type_name -> pb.MergeOp
+	55, // 6: pb.Op.diff:type_name -> pb.DiffOp
+	56, // 7: pb.Op.passthrough:type_name -> pb.PassthroughOp
 	6,  // 8: pb.Op.platform:type_name -> pb.Platform
-	33, // 9: pb.Op.constraints:type_name -> pb.WorkerConstraints
-	9,  // 10: pb.ExecOp.meta:type_name -> pb.Meta
-	14, // 11: pb.ExecOp.mounts:type_name -> pb.Mount
+	39, // 9: pb.Op.constraints:type_name -> pb.WorkerConstraints
+	12, // 10: pb.ExecOp.meta:type_name -> pb.Meta
+	17, // 11: pb.ExecOp.mounts:type_name -> pb.Mount
 	0,  // 12: pb.ExecOp.network:type_name -> pb.NetMode
 	1,  // 13: pb.ExecOp.security:type_name -> pb.SecurityMode
-	12, // 14: pb.ExecOp.secretenv:type_name -> pb.SecretEnv
-	13, // 15: pb.ExecOp.cdiDevices:type_name -> pb.CDIDevice
-	32, // 16: pb.Meta.proxy_env:type_name -> pb.ProxyEnv
-	10, // 17: pb.Meta.extraHosts:type_name -> pb.HostIP
-	11, // 18: pb.Meta.ulimit:type_name -> pb.Ulimit
-	2,  // 19: pb.Mount.mountType:type_name -> pb.MountType
-	15, // 20: pb.Mount.TmpfsOpt:type_name -> pb.TmpfsOpt
-	16, // 21: pb.Mount.cacheOpt:type_name -> pb.CacheOpt
-	17, // 22: pb.Mount.secretOpt:type_name -> pb.SecretOpt
-	18, // 23: pb.Mount.SSHOpt:type_name -> pb.SSHOpt
-	3,  // 24: pb.Mount.contentCache:type_name -> pb.MountContentCache
-	4,  // 25: pb.CacheOpt.sharing:type_name -> pb.CacheSharingOpt
-	51, // 26: pb.SourceOp.attrs:type_name -> pb.SourceOp.AttrsEntry
-	52, // 27: pb.BuildOp.inputs:type_name -> pb.BuildOp.InputsEntry
-	34, // 28: pb.BuildOp.def:type_name -> pb.Definition
-	53, // 29: pb.BuildOp.attrs:type_name -> pb.BuildOp.AttrsEntry
-	54, // 30: pb.OpMetadata.description:type_name -> pb.OpMetadata.DescriptionEntry
-	29, // 31: pb.OpMetadata.export_cache:type_name -> pb.ExportCache
-	55, // 32: pb.OpMetadata.caps:type_name -> pb.OpMetadata.CapsEntry
-	30, // 33: pb.OpMetadata.progress_group:type_name -> pb.ProgressGroup
-	31, // 34: pb.OpMetadata.linux_resources:type_name -> pb.LinuxResources
-	56, // 35: pb.Source.locations:type_name -> pb.Source.LocationsEntry
-	25, // 36: pb.Source.infos:type_name -> pb.SourceInfo
-	26, // 37: pb.Locations.locations:type_name -> pb.Location
-	34, // 38: pb.SourceInfo.definition:type_name -> pb.Definition
-	27, // 39: pb.Location.ranges:type_name -> pb.Range
-	28, // 40: pb.Range.start:type_name -> pb.Position
-	28, // 41: pb.Range.end:type_name -> pb.Position
-	57, // 42: pb.Definition.metadata:type_name -> pb.Definition.MetadataEntry
-	23, // 43: pb.Definition.Source:type_name -> pb.Source
-	36, // 44: pb.FileOp.actions:type_name -> pb.FileAction
-	37, // 45: pb.FileAction.copy:type_name -> pb.FileActionCopy
-	38, // 46: pb.FileAction.mkfile:type_name -> pb.FileActionMkFile
-	40, // 47: pb.FileAction.mkdir:type_name -> pb.FileActionMkDir
-	41, // 48: pb.FileAction.rm:type_name -> pb.FileActionRm
-	39, // 49: pb.FileAction.symlink:type_name -> pb.FileActionSymlink
-	42, // 50: pb.FileActionCopy.owner:type_name -> pb.ChownOpt
-	42, // 51: pb.FileActionMkFile.owner:type_name -> pb.ChownOpt
-	42, // 52: pb.FileActionSymlink.owner:type_name -> pb.ChownOpt
-	42, // 53: pb.FileActionMkDir.owner:type_name -> pb.ChownOpt
-	43, // 54: pb.ChownOpt.user:type_name -> pb.UserOpt
-	43, // 55: pb.ChownOpt.group:type_name -> pb.UserOpt
-	44, // 56: pb.UserOpt.byName:type_name -> pb.NamedUserOpt
-	45, // 57: pb.MergeOp.inputs:type_name -> pb.MergeInput
-	47, // 58: pb.DiffOp.lower:type_name -> pb.LowerDiffInput
-	48, // 59: pb.DiffOp.upper:type_name -> pb.UpperDiffInput
-	21, // 60: pb.BuildOp.InputsEntry.value:type_name -> pb.BuildInput
-	24, // 61: pb.Source.LocationsEntry.value:type_name -> pb.Locations
-	22, // 62: pb.Definition.MetadataEntry.value:type_name -> pb.OpMetadata
-	63, // [63:63] is the sub-list for method output_type
-	63, // [63:63] is the sub-list for method input_type
-	63, // [63:63] is the sub-list for extension type_name
-	63, // [63:63] is the sub-list for extension extendee
-	0,  // [0:63] is the sub-list for field type_name
+	15, // 14: pb.ExecOp.secretenv:type_name -> pb.SecretEnv
+	16, // 15: pb.ExecOp.cdiDevices:type_name -> pb.CDIDevice
+	10, // 16: pb.ExecOp.services:type_name -> pb.ServiceContainer
+	9,  // 17: pb.ExecOp.checkpoint:type_name -> pb.CheckpointOpt
+	12, // 18: pb.ServiceContainer.meta:type_name -> pb.Meta
+	11, // 19: pb.ServiceContainer.readiness:type_name -> pb.ReadinessCheck
+	38, // 20: pb.Meta.proxy_env:type_name -> pb.ProxyEnv
+	13, // 21: pb.Meta.extraHosts:type_name -> pb.HostIP
+	14, // 22: pb.Meta.ulimit:type_name -> pb.Ulimit
+	2,  // 23: pb.Mount.mountType:type_name -> pb.MountType
+	18, // 24: pb.Mount.TmpfsOpt:type_name -> pb.TmpfsOpt
+	19, // 25: pb.Mount.cacheOpt:type_name -> pb.CacheOpt
+	20, // 26: pb.Mount.secretOpt:type_name -> pb.SecretOpt
+	21, // 27: pb.Mount.SSHOpt:type_name -> pb.SSHOpt
+	3,  // 28: pb.Mount.contentCache:type_name -> pb.MountContentCache
+	4,  // 29: pb.CacheOpt.sharing:type_name -> pb.CacheSharingOpt
+	57, // 30: pb.SourceOp.attrs:type_name -> pb.SourceOp.AttrsEntry
+	58, // 31: pb.BuildOp.inputs:type_name -> pb.BuildOp.InputsEntry
+	40, // 32: pb.BuildOp.def:type_name -> pb.Definition
+	59, // 33: pb.BuildOp.attrs:type_name -> pb.BuildOp.AttrsEntry
+	60, // 34: pb.OpMetadata.description:type_name -> pb.OpMetadata.DescriptionEntry
+	32, // 35: pb.OpMetadata.export_cache:type_name -> pb.ExportCache
+	61, // 36: pb.OpMetadata.caps:type_name -> pb.OpMetadata.CapsEntry
+	33, // 37: pb.OpMetadata.progress_group:type_name -> pb.ProgressGroup
+	34, // 38: pb.OpMetadata.linux_resources:type_name -> pb.LinuxResources
+	36, // 39: pb.OpMetadata.execution_policy:type_name -> pb.ExecutionPolicy
+	62, // 40: pb.Source.locations:type_name -> pb.Source.LocationsEntry
+	28, // 41: pb.Source.infos:type_name -> pb.SourceInfo
+	29, // 42: pb.Locations.locations:type_name -> pb.Location
+	40, // 43: pb.SourceInfo.definition:type_name -> pb.Definition
+	30, // 44: pb.Location.ranges:type_name -> pb.Range
+	31, // 45: pb.Range.start:type_name -> pb.Position
+	31, // 46: pb.Range.end:type_name -> pb.Position
+	35, // 47: pb.LinuxResources.blkioDeviceReadBps:type_name -> pb.LinuxThrottleDevice
+	35, // 48: pb.LinuxResources.blkioDeviceWriteBps:type_name -> pb.LinuxThrottleDevice
+	35, // 49: pb.LinuxResources.blkioDeviceReadIOps:type_name -> pb.LinuxThrottleDevice
+	35, // 50: pb.LinuxResources.blkioDeviceWriteIOps:type_name -> pb.LinuxThrottleDevice
+	37, // 51: pb.ExecutionPolicy.retry:type_name -> pb.RetryPolicy
+	63, // 52: pb.Definition.metadata:type_name -> pb.Definition.MetadataEntry
+	26, // 53: pb.Definition.Source:type_name -> pb.Source
+	42, // 54: pb.FileOp.actions:type_name -> pb.FileAction
+	43, // 55: pb.FileAction.copy:type_name -> pb.FileActionCopy
+	44, // 56: pb.FileAction.mkfile:type_name -> pb.FileActionMkFile
+	46, // 57: pb.FileAction.mkdir:type_name -> pb.FileActionMkDir
+	47, // 58: pb.FileAction.rm:type_name -> pb.FileActionRm
+	45, // 59: pb.FileAction.symlink:type_name -> pb.FileActionSymlink
+	48, // 60: pb.FileActionCopy.owner:type_name -> pb.ChownOpt
+	48, // 61: pb.FileActionMkFile.owner:type_name -> pb.ChownOpt
+	48, // 62: pb.FileActionSymlink.owner:type_name -> pb.ChownOpt
+	48, // 63: pb.FileActionMkDir.owner:type_name -> pb.ChownOpt
+	49, // 64: pb.ChownOpt.user:type_name -> pb.UserOpt
+	49, // 65: pb.ChownOpt.group:type_name -> pb.UserOpt
+	50, // 66: pb.UserOpt.byName:type_name -> pb.NamedUserOpt
+	51, // 67: pb.MergeOp.inputs:type_name -> pb.MergeInput
+	53, // 68: pb.DiffOp.lower:type_name -> pb.LowerDiffInput
+	54, // 69: pb.DiffOp.upper:type_name -> pb.UpperDiffInput
+	24, // 70: pb.BuildOp.InputsEntry.value:type_name -> pb.BuildInput
+	27, // 71: pb.Source.LocationsEntry.value:type_name -> pb.Locations
+	25, // 72: pb.Definition.MetadataEntry.value:type_name -> pb.OpMetadata
+	73, // [73:73] is the sub-list for method output_type
+	73, // [73:73] is the sub-list for method input_type
+	73, // [73:73] is the sub-list for extension type_name
+	73, // [73:73] is the sub-list for extension extendee
+	0,  // [0:73] is the sub-list for field type_name
 }
 
 func init() { file_github_com_moby_buildkit_solver_pb_ops_proto_init() }
@@ -4027,14 +4587,14 @@ func file_github_com_moby_buildkit_solver_pb_ops_proto_init() {
 		(*Op_Diff)(nil),
 		(*Op_Passthrough)(nil),
 	}
-	file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[31].OneofWrappers = []any{
+	file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[37].OneofWrappers = []any{
 		(*FileAction_Copy)(nil),
 		(*FileAction_Mkfile)(nil),
 		(*FileAction_Mkdir)(nil),
 		(*FileAction_Rm)(nil),
 		(*FileAction_Symlink)(nil),
 	}
-	file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[38].OneofWrappers = []any{
+	file_github_com_moby_buildkit_solver_pb_ops_proto_msgTypes[44].OneofWrappers = []any{
 		(*UserOpt_ByName)(nil),
 		(*UserOpt_ByID)(nil),
 	}
@@ -4044,7 +4604,7 @@ func file_github_com_moby_buildkit_solver_pb_ops_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc), len(file_github_com_moby_buildkit_solver_pb_ops_proto_rawDesc)),
 			NumEnums:      5,
-			NumMessages:   53,
+			NumMessages:   59,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
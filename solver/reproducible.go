@@ -0,0 +1,170 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/pkg/errors"
+)
+
+// ReproducibleCheckJobValueKey is the key of the job value that enables the
+// reproducibility check for a build. The value is a *ReproducibleCheck.
+const ReproducibleCheckJobValueKey = "solver.reproduciblecheck"
+
+// ReproducibleOp is implemented by the ops that can be checked for
+// reproducibility.
+type ReproducibleOp interface {
+	// CompareResults returns the differences between the results of two
+	// executions of the op. Modification times are clamped to epoch if it
+	// is set.
+	CompareResults(ctx context.Context, g session.Group, a, b Result, epoch *time.Time) ([]string, error)
+}
+
+// ReproducibleCheck runs the checked vertices without cache, executes them a
+// second time and reports the differences between the results as warnings.
+type ReproducibleCheck struct {
+	// Filter matches the names of the checked vertices. All vertices are
+	// checked if it is nil.
+	Filter *regexp.Regexp
+	// Epoch is the SOURCE_DATE_EPOCH of the build.
+	Epoch *time.Time
+}
+
+// ParseReproducibleCheck parses the value of the reproducible-check frontend
+// attribute. The value is either a boolean or a regular expression matching
+// the names of the vertices to check.
+func ParseReproducibleCheck(v string) (*ReproducibleCheck, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if b, err := strconv.ParseBool(v); err == nil {
+		if !b {
+			return nil, nil
+		}
+		return &ReproducibleCheck{}, nil
+	}
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reproducible-check filter %q", v)
+	}
+	return &ReproducibleCheck{Filter: re}, nil
+}
+
+func (c *ReproducibleCheck) match(v Vertex) bool {
+	return c.Filter == nil || c.Filter.MatchString(v.Name())
+}
+
+// reproducibleCheck returns the check of the first job that enables it for
+// the vertex.
+func (s *state) reproducibleCheck() *ReproducibleCheck {
+	s.mu.RLock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.RUnlock()
+
+	var check *ReproducibleCheck
+	for _, j := range jobs {
+		_ = j.EachValue(context.TODO(), ReproducibleCheckJobValueKey, func(v any) error {
+			if c, ok := v.(*ReproducibleCheck); ok && c != nil && check == nil && c.match(s.vtx) {
+				check = c
+			}
+			return nil
+		})
+	}
+	return check
+}
+
+// reproducibleCheck returns the check of the vertex if the op of the vertex
+// can be checked. Checked vertices ignore the cache so that they are always
+// executed.
+func (s *sharedOp) reproducibleCheck() *ReproducibleCheck {
+	op, err := s.getOp()
+	if err != nil {
+		return nil
+	}
+	if _, ok := op.(ReproducibleOp); !ok {
+		return nil
+	}
+	return s.st.reproducibleCheck()
+}
+
+type reproducibleCheckKey struct{}
+
+// IsReproducibleCheck returns true if ctx is the context of the second
+// execution of an op by the reproducibility check. Ops should not share
+// state, like cache mounts, with the first execution.
+func IsReproducibleCheck(ctx context.Context) bool {
+	v, _ := ctx.Value(reproducibleCheckKey{}).(bool)
+	return v
+}
+
+// maxReproducibleWarnings limits the warnings reported for a vertex.
+const maxReproducibleWarnings = 10
+
+// checkReproducible executes the op a second time if the reproducibility
+// check is enabled for the vertex and reports the differences between the
+// results of the two executions as warnings of the vertex.
+func (s *sharedOp) checkReproducible(ctx context.Context, op Op, inputs []Result, res []Result) {
+	rop, ok := op.(ReproducibleOp)
+	if !ok {
+		return
+	}
+	check := s.st.reproducibleCheck()
+	if check == nil {
+		return
+	}
+
+	var warnings []string
+	res2, err := op.Exec(context.WithValue(ctx, reproducibleCheckKey{}, true), s.st, inputs)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("second execution failed: %v", err))
+	}
+	for i := range res2 {
+		if err == nil && i < len(res) {
+			diffs, cerr := rop.CompareResults(ctx, s.st.Session(), res[i], res2[i], check.Epoch)
+			if cerr != nil {
+				warnings = append(warnings, fmt.Sprintf("failed to compare output %d: %v", i, cerr))
+			}
+			for _, d := range diffs {
+				if len(res) > 1 {
+					d = fmt.Sprintf("output %d: %s", i, d)
+				}
+				warnings = append(warnings, d)
+			}
+		}
+		if res2[i] != nil {
+			if rerr := res2[i].Release(context.WithoutCancel(ctx)); rerr != nil {
+				bklog.G(ctx).Warnf("failed to release reproducibility check result: %v", rerr)
+			}
+		}
+	}
+	if len(warnings) == 0 {
+		return
+	}
+
+	pw, _, _ := progress.NewFromContext(ctx)
+	defer pw.Close()
+	for i, w := range warnings {
+		if i == maxReproducibleWarnings {
+			w = fmt.Sprintf("%d more differences", len(warnings)-i)
+		}
+		pw.Write(identity.NewID(), client.VertexWarning{
+			Vertex: s.st.clientVertex.Digest,
+			Level:  1,
+			Short:  []byte("not reproducible: " + w),
+		})
+		if i == maxReproducibleWarnings {
+			break
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package solver
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/session"
+	"github.com/stretchr/testify/require"
+)
+
+// reproducibleVertex is a vertex whose op can be checked for
+// reproducibility.
+type reproducibleVertex struct {
+	*vertex
+	checks atomic.Int64
+}
+
+func (v *reproducibleVertex) Sys() any {
+	return v
+}
+
+func (v *reproducibleVertex) Exec(ctx context.Context, job JobContext, inputs []Result) ([]Result, error) {
+	if IsReproducibleCheck(ctx) {
+		v.checks.Add(1)
+	}
+	return v.vertex.Exec(ctx, job, inputs)
+}
+
+func (v *reproducibleVertex) CompareResults(context.Context, session.Group, Result, Result, *time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func TestReproducibleCheckIgnoresCache(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	s := NewSolver(SolverOpt{
+		ResolveOpFunc: testOpResolver,
+	})
+	defer s.Close()
+
+	build := func(check *ReproducibleCheck) (*reproducibleVertex, int64) {
+		j, err := s.NewJob(identity.NewID())
+		require.NoError(t, err)
+		defer j.Discard()
+		if check != nil {
+			j.SetValue(ReproducibleCheckJobValueKey, check)
+		}
+		v := &reproducibleVertex{vertex: vtx(vtxOpt{
+			name:         "v0",
+			cacheKeySeed: "seed0",
+			value:        "result0",
+		})}
+		v.setupCallCounters()
+		res, err := j.Build(ctx, Edge{Vertex: v})
+		require.NoError(t, err)
+		require.Equal(t, "result0", unwrap(res))
+		return v, *v.execCallCount
+	}
+
+	_, execs := build(nil)
+	require.Equal(t, int64(1), execs)
+
+	// the cached result is not used and the op is executed twice
+	v, execs := build(&ReproducibleCheck{})
+	require.Equal(t, int64(2), execs)
+	require.Equal(t, int64(1), v.checks.Load())
+
+	// vertices that don't match the filter use the cache
+	check, err := ParseReproducibleCheck("^other$")
+	require.NoError(t, err)
+	_, execs = build(check)
+	require.Equal(t, int64(0), execs)
+}
@@ -0,0 +1,105 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/progress/logs"
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy defines how a vertex that failed is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It is doubled on every
+	// following retry.
+	Backoff time.Duration
+	// MaxBackoff limits the delay between retries. Zero means no limit.
+	MaxBackoff time.Duration
+	// Retryable reports if an error should be retried. All errors are
+	// retried if it is nil.
+	Retryable func(error) bool
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return true
+	}
+	return p.Retryable(err)
+}
+
+// TimeoutError is returned when a single attempt to run a vertex exceeded the
+// timeout set in its VertexOptions.
+type TimeoutError struct {
+	Timeout time.Duration
+	// Err is the error returned by the attempt after the timeout was reached.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("vertex did not complete within timeout of %s", e.Timeout)
+	if e.Err != nil {
+		return e.Err.Error() + ": " + msg
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// IsTimeout returns true if the error was caused by a vertex timeout.
+func IsTimeout(err error) bool {
+	var te *TimeoutError
+	return errors.As(err, &te)
+}
+
+// runWithPolicy calls f until it succeeds or the retry policy of the vertex
+// gives up. Every attempt is limited by the timeout of the vertex. Errors
+// caused by the cancellation of ctx are never retried.
+func runWithPolicy(ctx context.Context, opts VertexOptions, f func(context.Context) error) error {
+	attempts := 1
+	var backoff time.Duration
+	if opts.Retry != nil {
+		attempts = max(opts.Retry.MaxAttempts, 1)
+		backoff = opts.Retry.Backoff
+	}
+	for attempt := 1; ; attempt++ {
+		err := runWithTimeout(ctx, opts.Timeout, f)
+		if err == nil || attempt >= attempts || ctx.Err() != nil || !opts.Retry.retryable(err) {
+			return err
+		}
+		bklog.G(ctx).WithError(err).Debugf("retrying vertex, attempt %d of %d", attempt+1, attempts)
+		logs.LoggerFromContext(ctx)(fmt.Appendf(nil, "error: %v\nretrying in %v (attempt %d of %d)\n", err, backoff, attempt+1, attempts))
+		if backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if opts.Retry.MaxBackoff > 0 && backoff > opts.Retry.MaxBackoff {
+				backoff = opts.Retry.MaxBackoff
+			}
+		}
+	}
+}
+
+func runWithTimeout(ctx context.Context, timeout time.Duration, f func(context.Context) error) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+	cause := errors.WithStack(&TimeoutError{Timeout: timeout})
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, cause)
+	defer cancel()
+	err := f(ctx)
+	if err != nil && errors.Is(context.Cause(ctx), cause) && !errors.Is(err, cause) {
+		// the error returned by f is usually a cancellation error that
+		// doesn't tell that the timeout was reached
+		return errors.WithStack(&TimeoutError{Timeout: timeout, Err: err})
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package solver
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithTimeoutKeepsError(t *testing.T) {
+	t.Parallel()
+
+	// the error of the attempt is kept together with the timeout
+	err := runWithTimeout(t.Context(), 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.Wrap(syscall.ECONNRESET, "process killed")
+	})
+	require.True(t, IsTimeout(err))
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+	require.ErrorContains(t, err, "process killed: connection reset by peer: vertex did not complete within timeout of 10ms")
+
+	// the cause of the context isn't wrapped again
+	err = runWithTimeout(t.Context(), 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return context.Cause(ctx)
+	})
+	require.True(t, IsTimeout(err))
+	require.EqualError(t, err, "vertex did not complete within timeout of 10ms")
+
+	// errors before the timeout are returned unchanged
+	errFailed := errors.New("failed")
+	err = runWithTimeout(t.Context(), time.Minute, func(context.Context) error {
+		return errFailed
+	})
+	require.Equal(t, errFailed, err)
+	require.False(t, IsTimeout(err))
+}
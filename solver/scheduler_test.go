@@ -207,6 +207,55 @@ func TestSingleLevelActiveGraph(t *testing.T) {
 	require.Equal(t, int64(1), *g4.Vertex.(*vertex).execCallCount)
 }
 
+func TestJobExecutedOps(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	s := NewSolver(SolverOpt{
+		ResolveOpFunc: testOpResolver,
+	})
+	defer s.Close()
+
+	newEdge := func() Edge {
+		return Edge{
+			Vertex: vtx(vtxOpt{
+				name:         "v0",
+				cacheKeySeed: "seed0",
+				value:        "result0",
+				inputs: []Edge{
+					{Vertex: vtx(vtxOpt{
+						name:         "v1",
+						cacheKeySeed: "seed1",
+						value:        "result1",
+					})},
+				},
+			}),
+		}
+	}
+
+	j0, err := s.NewJob("job0")
+	require.NoError(t, err)
+	g0 := newEdge()
+	res, err := j0.Build(ctx, g0)
+	require.NoError(t, err)
+	require.Equal(t, "result0", unwrap(res))
+
+	ops := j0.ExecutedOps()
+	require.Len(t, ops, 2)
+	names := []string{ops[0].Vertex.Name(), ops[1].Vertex.Name()}
+	require.ElementsMatch(t, []string{"v0", "v1"}, names)
+	require.NoError(t, j0.Discard())
+
+	// the second build is loaded from the cache
+	j1, err := s.NewJob("job1")
+	require.NoError(t, err)
+	defer j1.Discard()
+	res, err = j1.Build(ctx, newEdge())
+	require.NoError(t, err)
+	require.Equal(t, "result0", unwrap(res))
+	require.Empty(t, j1.ExecutedOps())
+}
+
 func TestSingleLevelCache(t *testing.T) {
 	t.Parallel()
 	ctx := t.Context()
@@ -554,6 +603,141 @@ func TestSingleCancelExec(t *testing.T) {
 	j1 = nil
 }
 
+func TestSingleExecRetry(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	s := NewSolver(SolverOpt{
+		ResolveOpFunc: testOpResolver,
+	})
+	defer s.Close()
+
+	j0, err := s.NewJob("job0")
+	require.NoError(t, err)
+
+	defer func() {
+		if j0 != nil {
+			j0.Discard()
+		}
+	}()
+
+	errFlaky := errors.New("flaky")
+	var attempts, cacheAttempts int64
+	g0 := Edge{
+		Vertex: vtx(vtxOpt{
+			name:  "v0",
+			value: "result0",
+			cachePreFunc: func(context.Context) error {
+				if atomic.AddInt64(&cacheAttempts, 1) < 2 {
+					return errFlaky
+				}
+				return nil
+			},
+			execPreFunc: func(context.Context) error {
+				if atomic.AddInt64(&attempts, 1) < 3 {
+					return errFlaky
+				}
+				return nil
+			},
+			retry: &RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     time.Millisecond,
+				Retryable: func(err error) bool {
+					return errors.Is(err, errFlaky)
+				},
+			},
+		}),
+	}
+	g0.Vertex.(*vertex).setupCallCounters()
+
+	res, err := j0.Build(ctx, g0)
+	require.NoError(t, err)
+	require.Equal(t, "result0", unwrap(res))
+
+	require.Equal(t, int64(2), cacheAttempts)
+	require.Equal(t, int64(3), attempts)
+	require.Equal(t, int64(1), *g0.Vertex.(*vertex).execCallCount)
+
+	require.NoError(t, j0.Discard())
+	j0 = nil
+
+	// errors that are not retryable fail on the first attempt
+	j1, err := s.NewJob("job1")
+	require.NoError(t, err)
+
+	defer func() {
+		if j1 != nil {
+			j1.Discard()
+		}
+	}()
+
+	attempts = 0
+	g1 := Edge{
+		Vertex: vtx(vtxOpt{
+			name: "v1",
+			execPreFunc: func(context.Context) error {
+				atomic.AddInt64(&attempts, 1)
+				return errors.New("permanent")
+			},
+			retry: &RetryPolicy{
+				MaxAttempts: 3,
+				Retryable: func(err error) bool {
+					return errors.Is(err, errFlaky)
+				},
+			},
+		}),
+	}
+
+	_, err = j1.Build(ctx, g1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "permanent")
+	require.Equal(t, int64(1), attempts)
+
+	require.NoError(t, j1.Discard())
+	j1 = nil
+}
+
+func TestSingleExecTimeout(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	s := NewSolver(SolverOpt{
+		ResolveOpFunc: testOpResolver,
+	})
+	defer s.Close()
+
+	j0, err := s.NewJob("job0")
+	require.NoError(t, err)
+
+	defer func() {
+		if j0 != nil {
+			j0.Discard()
+		}
+	}()
+
+	g0 := Edge{
+		Vertex: vtx(vtxOpt{
+			name:      "v0",
+			execDelay: time.Minute,
+			timeout:   50 * time.Millisecond,
+			retry: &RetryPolicy{
+				MaxAttempts: 2,
+				Retryable:   IsTimeout,
+			},
+		}),
+	}
+	g0.Vertex.(*vertex).setupCallCounters()
+
+	_, err = j0.Build(ctx, g0)
+	require.Error(t, err)
+	require.True(t, IsTimeout(err))
+	require.False(t, errors.Is(err, context.Canceled))
+	require.Equal(t, int64(2), *g0.Vertex.(*vertex).execCallCount)
+
+	require.NoError(t, j0.Discard())
+	j0 = nil
+}
+
 func TestSingleCancelParallel(t *testing.T) {
 	t.Parallel()
 	ctx := t.Context()
@@ -3725,6 +3909,8 @@ type vtxOpt struct {
 	selectors        map[int]digest.Digest
 	cacheSource      CacheManager
 	ignoreCache      bool
+	timeout          time.Duration
+	retry            *RetryPolicy
 }
 
 func vtx(opt vtxOpt) *vertex {
@@ -3766,6 +3952,8 @@ func (v *vertex) Options() VertexOptions {
 	return VertexOptions{
 		CacheSources: cache,
 		IgnoreCache:  v.opt.ignoreCache,
+		Timeout:      v.opt.timeout,
+		Retry:        v.opt.retry,
 	}
 }
 
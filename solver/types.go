@@ -57,6 +57,10 @@ type VertexOptions struct {
 	// WorkerConstraint
 	ProgressGroup *pb.ProgressGroup
 	Metadata      VertexMetadata
+	// Timeout limits the duration of a single attempt to compute the cache
+	// map or to execute the vertex. Zero means no timeout.
+	Timeout time.Duration
+	Retry   *RetryPolicy
 }
 
 // VertexMetadata is opaque per-vertex metadata that gets merged when the same
@@ -416,9 +416,13 @@ func (x *AttrConstraint) GetCondition() AttrMatch {
 
 // Policy is the list of rules the policy engine will perform
 type Policy struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"` // Currently 1
-	Rules         []*Rule                `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"` // Currently 1
+	Rules   []*Rule                `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	// Egress restricts the network requests that build steps can make through
+	// the proxy network. If set, a request is only allowed if it matches at
+	// least one of the rules.
+	Egress        []*EgressRule `protobuf:"bytes,3,rep,name=egress,proto3" json:"egress,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -467,6 +471,84 @@ func (x *Policy) GetRules() []*Rule {
 	return nil
 }
 
+func (x *Policy) GetEgress() []*EgressRule {
+	if x != nil {
+		return x.Egress
+	}
+	return nil
+}
+
+// EgressRule matches network requests made through the proxy network.
+// Empty fields match any value.
+type EgressRule struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Hosts are host names that may contain wildcards, e.g. "*.example.com"
+	Hosts         []string `protobuf:"bytes,1,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	Ports         []uint32 `protobuf:"varint,2,rep,packed,name=ports,proto3" json:"ports,omitempty"`
+	PathPrefixes  []string `protobuf:"bytes,3,rep,name=path_prefixes,json=pathPrefixes,proto3" json:"path_prefixes,omitempty"`
+	Methods       []string `protobuf:"bytes,4,rep,name=methods,proto3" json:"methods,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EgressRule) Reset() {
+	*x = EgressRule{}
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EgressRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EgressRule) ProtoMessage() {}
+
+func (x *EgressRule) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EgressRule.ProtoReflect.Descriptor instead.
+func (*EgressRule) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EgressRule) GetHosts() []string {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+func (x *EgressRule) GetPorts() []uint32 {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+func (x *EgressRule) GetPathPrefixes() []string {
+	if x != nil {
+		return x.PathPrefixes
+	}
+	return nil
+}
+
+func (x *EgressRule) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
 var File_github_com_moby_buildkit_sourcepolicy_pb_policy_proto protoreflect.FileDescriptor
 
 const file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc = "" +
@@ -495,10 +577,17 @@ const file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc = "" +
 	"\x0eAttrConstraint\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value\x12F\n" +
-	"\tcondition\x18\x03 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.AttrMatchR\tcondition\"]\n" +
+	"\tcondition\x18\x03 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.AttrMatchR\tcondition\"\xa0\x01\n" +
 	"\x06Policy\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x03R\aversion\x129\n" +
-	"\x05rules\x18\x02 \x03(\v2#.moby.buildkit.v1.sourcepolicy.RuleR\x05rules*0\n" +
+	"\x05rules\x18\x02 \x03(\v2#.moby.buildkit.v1.sourcepolicy.RuleR\x05rules\x12A\n" +
+	"\x06egress\x18\x03 \x03(\v2).moby.buildkit.v1.sourcepolicy.EgressRuleR\x06egress\"w\n" +
+	"\n" +
+	"EgressRule\x12\x14\n" +
+	"\x05hosts\x18\x01 \x03(\tR\x05hosts\x12\x14\n" +
+	"\x05ports\x18\x02 \x03(\rR\x05ports\x12#\n" +
+	"\rpath_prefixes\x18\x03 \x03(\tR\fpathPrefixes\x12\x18\n" +
+	"\amethods\x18\x04 \x03(\tR\amethods*0\n" +
 	"\fPolicyAction\x12\t\n" +
 	"\x05ALLOW\x10\x00\x12\b\n" +
 	"\x04DENY\x10\x01\x12\v\n" +
@@ -525,7 +614,7 @@ func file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP() []
 }
 
 var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_goTypes = []any{
 	(PolicyAction)(0),      // 0: moby.buildkit.v1.sourcepolicy.PolicyAction
 	(AttrMatch)(0),         // 1: moby.buildkit.v1.sourcepolicy.AttrMatch
@@ -535,22 +624,24 @@ var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_goTypes = []any{
 	(*Selector)(nil),       // 5: moby.buildkit.v1.sourcepolicy.Selector
 	(*AttrConstraint)(nil), // 6: moby.buildkit.v1.sourcepolicy.AttrConstraint
 	(*Policy)(nil),         // 7: moby.buildkit.v1.sourcepolicy.Policy
-	nil,                    // 8: moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
+	(*EgressRule)(nil),     // 8: moby.buildkit.v1.sourcepolicy.EgressRule
+	nil,                    // 9: moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
 }
 var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_depIdxs = []int32{
 	0, // 0: moby.buildkit.v1.sourcepolicy.Rule.action:type_name -> moby.buildkit.v1.sourcepolicy.PolicyAction
 	5, // 1: moby.buildkit.v1.sourcepolicy.Rule.selector:type_name -> moby.buildkit.v1.sourcepolicy.Selector
 	4, // 2: moby.buildkit.v1.sourcepolicy.Rule.updates:type_name -> moby.buildkit.v1.sourcepolicy.Update
-	8, // 3: moby.buildkit.v1.sourcepolicy.Update.attrs:type_name -> moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
+	9, // 3: moby.buildkit.v1.sourcepolicy.Update.attrs:type_name -> moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
 	2, // 4: moby.buildkit.v1.sourcepolicy.Selector.match_type:type_name -> moby.buildkit.v1.sourcepolicy.MatchType
 	6, // 5: moby.buildkit.v1.sourcepolicy.Selector.constraints:type_name -> moby.buildkit.v1.sourcepolicy.AttrConstraint
 	1, // 6: moby.buildkit.v1.sourcepolicy.AttrConstraint.condition:type_name -> moby.buildkit.v1.sourcepolicy.AttrMatch
 	3, // 7: moby.buildkit.v1.sourcepolicy.Policy.rules:type_name -> moby.buildkit.v1.sourcepolicy.Rule
-	8, // [8:8] is the sub-list for method output_type
-	8, // [8:8] is the sub-list for method input_type
-	8, // [8:8] is the sub-list for extension type_name
-	8, // [8:8] is the sub-list for extension extendee
-	0, // [0:8] is the sub-list for field type_name
+	8, // 8: moby.buildkit.v1.sourcepolicy.Policy.egress:type_name -> moby.buildkit.v1.sourcepolicy.EgressRule
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_init() }
@@ -564,7 +655,7 @@ func file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc), len(file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc)),
 			NumEnums:      3,
-			NumMessages:   6,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,75 @@
+package cachestore
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/cachedigest"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// maxSummaryDepth limits how many digests are resolved to find the operation
+// of a key
+const maxSummaryDepth = 4
+
+var outputDigestRegexp = regexp.MustCompile(`^(sha256:[a-f0-9]{64})@\d+$`)
+
+// Summary returns a short description of the operation a cache key was
+// created for. The description is looked up from the cache debug database,
+// so it is empty unless the daemon saves cache debug information.
+func Summary(ctx context.Context, info *solver.CacheKeyInfo) string {
+	dgst := digest.Digest(info.ID)
+	if strings.HasPrefix(info.ID, "random:") {
+		dgst = digest.Digest("sha256:" + strings.TrimPrefix(info.ID, "random:"))
+	}
+	if dgst.Validate() != nil {
+		// keys that depend on other keys are named after the operation
+		// of the links to them
+		if len(info.Backlinks) == 0 {
+			return ""
+		}
+		dgst = info.Backlinks[0].Link.Digest
+	}
+	return summarizeDigest(ctx, cachedigest.GetDefaultDB(), dgst, 0)
+}
+
+func summarizeDigest(ctx context.Context, db *cachedigest.DB, dgst digest.Digest, depth int) string {
+	if db == nil || depth > maxSummaryDepth {
+		return ""
+	}
+	typ, frames, err := db.Get(ctx, dgst.String())
+	if err != nil {
+		return ""
+	}
+	var dt []byte
+	for _, f := range frames {
+		if f.ID == cachedigest.FrameIDData {
+			dt = append(dt, f.Data...)
+		}
+	}
+	switch typ {
+	case cachedigest.TypeString:
+		if m := outputDigestRegexp.FindSubmatch(dt); m != nil {
+			return summarizeDigest(ctx, db, digest.Digest(m[1]), depth+1)
+		}
+		return string(dt)
+	case cachedigest.TypeJSON:
+		var op struct {
+			Type string
+			Exec *pb.ExecOp
+		}
+		if err := json.Unmarshal(dt, &op); err != nil || op.Type == "" {
+			return string(dt)
+		}
+		if op.Exec != nil && op.Exec.Meta != nil {
+			return op.Type + ": " + strings.Join(op.Exec.Meta.Args, " ")
+		}
+		return op.Type
+	default:
+		return ""
+	}
+}
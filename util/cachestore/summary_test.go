@@ -0,0 +1,55 @@
+package cachestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/cachedigest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	db, err := cachedigest.NewDB(filepath.Join(t.TempDir(), "cache-debug.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := t.Context()
+
+	src, err := db.FromBytes([]byte("source:https://example.com/foo.tar.gz"), cachedigest.TypeString)
+	require.NoError(t, err)
+	srcKey, err := db.FromBytes(fmt.Appendf(nil, "%s@0", src), cachedigest.TypeString)
+	require.NoError(t, err)
+
+	dt, err := json.Marshal(struct {
+		Type string
+		Exec *pb.ExecOp
+	}{
+		Type: "exec",
+		Exec: &pb.ExecOp{Meta: &pb.Meta{Args: []string{"sh", "-c", "make"}}},
+	})
+	require.NoError(t, err)
+	exec, err := db.FromBytes(dt, cachedigest.TypeJSON)
+	require.NoError(t, err)
+	execLink, err := db.FromBytes(fmt.Appendf(nil, "%s@0", exec), cachedigest.TypeString)
+	require.NoError(t, err)
+	db.Wait()
+
+	require.Equal(t, "", Summary(ctx, &solver.CacheKeyInfo{ID: srcKey.String()}))
+
+	old := cachedigest.GetDefaultDB()
+	cachedigest.SetDefaultDB(db)
+	t.Cleanup(func() { cachedigest.SetDefaultDB(old) })
+
+	require.Equal(t, "source:https://example.com/foo.tar.gz", Summary(ctx, &solver.CacheKeyInfo{ID: srcKey.String()}))
+	require.Equal(t, "exec: sh -c make", Summary(ctx, &solver.CacheKeyInfo{
+		ID: "abcdef",
+		Backlinks: []solver.CacheKeyInfoLink{
+			{ID: srcKey.String(), Link: solver.CacheInfoLink{Digest: execLink}},
+		},
+	}))
+	require.Equal(t, "", Summary(ctx, &solver.CacheKeyInfo{ID: "abcdef"}))
+}
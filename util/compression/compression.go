@@ -37,6 +37,7 @@ type (
 	gzipType         struct{}
 	estargzType      struct{}
 	zstdType         struct{}
+	zstdChunkedType  struct{}
 )
 
 var (
@@ -51,6 +52,9 @@ var (
 
 	// Zstd is used for Zstandard data.
 	Zstd = zstdType{}
+
+	// ZstdChunked is used for zstd:chunked data.
+	ZstdChunked = zstdChunkedType{}
 )
 
 type Config struct {
@@ -87,6 +91,8 @@ func parse(t string) (Type, error) {
 		return EStargz, nil
 	case Zstd.String():
 		return Zstd, nil
+	case ZstdChunked.String():
+		return ZstdChunked, nil
 	default:
 		return nil, errors.Errorf("unsupported compression type %s", t)
 	}
@@ -0,0 +1,417 @@
+package compression
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/klauspost/compress/zstd"
+	"github.com/moby/buildkit/util/iohelper"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/vbatts/tar-split/tar/asm"
+	"github.com/vbatts/tar-split/tar/storage"
+)
+
+// Annotations of zstd:chunked layers as defined by containers/storage.
+const (
+	ZstdChunkedManifestChecksumAnnotation = "io.github.containers.zstd-chunked.manifest-checksum"
+	ZstdChunkedManifestPositionAnnotation = "io.github.containers.zstd-chunked.manifest-position"
+	ZstdChunkedTarSplitPositionAnnotation = "io.github.containers.zstd-chunked.tarsplit-position"
+)
+
+var ZstdChunkedAnnotations = []string{ZstdChunkedManifestChecksumAnnotation, ZstdChunkedManifestPositionAnnotation, ZstdChunkedTarSplitPositionAnnotation}
+
+const (
+	// zstdChunkedManifestTypeCRFS is the type of the TOC in the footer and
+	// the manifest position annotation.
+	zstdChunkedManifestTypeCRFS = 1
+	zstdChunkedFooterSize       = 64
+	// zstdSkippableFrameHeaderSize is the size of the magic number and the
+	// frame size of a zstd skippable frame.
+	zstdSkippableFrameHeaderSize = 8
+)
+
+var (
+	zstdSkippableFrameMagic = []byte{0x50, 0x2a, 0x4d, 0x18}
+	zstdChunkedFrameMagic   = []byte{0x47, 0x4e, 0x55, 0x6c, 0x49, 0x6e, 0x55, 0x78}
+)
+
+var zstdChunkedTypes = map[byte]string{
+	tar.TypeReg:     "reg",
+	tar.TypeLink:    "hardlink",
+	tar.TypeChar:    "char",
+	tar.TypeBlock:   "block",
+	tar.TypeDir:     "dir",
+	tar.TypeFifo:    "fifo",
+	tar.TypeSymlink: "symlink",
+}
+
+// zstdChunkedTOC is the table of contents of a zstd:chunked layer.
+type zstdChunkedTOC struct {
+	Version        int                   `json:"version"`
+	Entries        []zstdChunkedTOCEntry `json:"entries"`
+	TarSplitDigest digest.Digest         `json:"tarSplitDigest,omitempty"`
+}
+
+type zstdChunkedTOCEntry struct {
+	Type       string            `json:"type"`
+	Name       string            `json:"name"`
+	Linkname   string            `json:"linkName,omitempty"`
+	Mode       int64             `json:"mode,omitempty"`
+	Size       int64             `json:"size,omitempty"`
+	UID        int               `json:"uid,omitempty"`
+	GID        int               `json:"gid,omitempty"`
+	ModTime    *time.Time        `json:"modtime,omitempty"`
+	AccessTime *time.Time        `json:"accesstime,omitempty"`
+	ChangeTime *time.Time        `json:"changetime,omitempty"`
+	Devmajor   int64             `json:"devMajor,omitempty"`
+	Devminor   int64             `json:"devMinor,omitempty"`
+	Xattrs     map[string]string `json:"xattrs,omitempty"`
+	Digest     string            `json:"digest,omitempty"`
+	Offset     int64             `json:"offset,omitempty"`
+	EndOffset  int64             `json:"endOffset,omitempty"`
+}
+
+func (c zstdChunkedType) Compress(ctx context.Context, comp Config) (compressorFunc Compressor, finalize Finalizer) {
+	var cInfo *zstdChunkedInfo
+	var writeErr error
+	var mu sync.Mutex
+	return func(dest io.Writer, requiredMediaType string) (io.WriteCloser, error) {
+			ct, err := FromMediaType(requiredMediaType)
+			if err != nil {
+				return nil, err
+			}
+			if ct != Zstd {
+				return nil, errors.Errorf("unsupported media type for zstd:chunked compressor %q", requiredMediaType)
+			}
+			level := zstd.SpeedDefault
+			if comp.Level != nil {
+				level = toZstdEncoderLevel(*comp.Level)
+			}
+			done := make(chan struct{})
+			pr, pw := io.Pipe()
+			go func() {
+				defer close(done)
+				info, err := writeZstdChunked(dest, pr, level)
+				mu.Lock()
+				cInfo, writeErr = info, err
+				mu.Unlock()
+				pr.CloseWithError(err)
+			}()
+			return &iohelper.WriteCloser{WriteCloser: pw, CloseFunc: func() error {
+				<-done // wait until the write completes
+				mu.Lock()
+				defer mu.Unlock()
+				return writeErr
+			}}, nil
+		}, func(ctx context.Context, cs content.Store) (map[string]string, error) {
+			mu.Lock()
+			cInfo, writeErr := cInfo, writeErr
+			mu.Unlock()
+			if cInfo == nil {
+				if writeErr != nil {
+					return nil, errors.Wrapf(writeErr, "cannot finalize due to write error")
+				}
+				return nil, errors.Errorf("cannot finalize (reason unknown)")
+			}
+
+			info, err := cs.Info(ctx, cInfo.compressedDigest)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get info from content store")
+			}
+			if info.Labels == nil {
+				info.Labels = make(map[string]string)
+			}
+			info.Labels[labels.LabelUncompressed] = cInfo.uncompressedDigest.String()
+			if _, err := cs.Update(ctx, info, "labels."+labels.LabelUncompressed); err != nil {
+				return nil, err
+			}
+
+			a := maps.Clone(cInfo.annotations)
+			a[labels.LabelUncompressed] = cInfo.uncompressedDigest.String()
+			return a, nil
+		}
+}
+
+func (c zstdChunkedType) Decompress(ctx context.Context, cs content.Store, desc ocispecs.Descriptor) (io.ReadCloser, error) {
+	// the TOC and tar-split data are in skippable frames that are ignored
+	// by the zstd decoder
+	return decompress(ctx, cs, desc)
+}
+
+func (c zstdChunkedType) NeedsConversion(ctx context.Context, cs content.Store, desc ocispecs.Descriptor) (bool, error) {
+	if !images.IsLayerType(desc.MediaType) {
+		return false, nil
+	}
+	ct, err := FromMediaType(desc.MediaType)
+	if err != nil {
+		return false, err
+	}
+	if ct != Zstd {
+		return true, nil
+	}
+	chunked, err := c.Is(ctx, cs, desc)
+	if err != nil {
+		return false, err
+	}
+	return !chunked, nil
+}
+
+func (c zstdChunkedType) NeedsComputeDiffBySelf(comp Config) bool {
+	return true
+}
+
+func (c zstdChunkedType) OnlySupportOCITypes() bool {
+	return true
+}
+
+func (c zstdChunkedType) MediaType() string {
+	return ocispecs.MediaTypeImageLayerZstd
+}
+
+func (c zstdChunkedType) String() string {
+	return "zstd:chunked"
+}
+
+// Is returns true when desc is a zstd:chunked layer. Layers whose content
+// isn't available locally are detected by their annotations.
+func (c zstdChunkedType) Is(ctx context.Context, cs content.Store, desc ocispecs.Descriptor) (bool, error) {
+	if _, ok := desc.Annotations[ZstdChunkedManifestChecksumAnnotation]; ok {
+		return true, nil
+	}
+	ra, err := cs.ReaderAt(ctx, ocispecs.Descriptor{Digest: desc.Digest})
+	if err != nil {
+		return false, nil
+	}
+	defer ra.Close()
+	if ra.Size() < zstdSkippableFrameHeaderSize+zstdChunkedFooterSize {
+		return false, nil
+	}
+	magic := make([]byte, len(zstdChunkedFrameMagic))
+	if _, err := ra.ReadAt(magic, ra.Size()-int64(len(magic))); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(magic, zstdChunkedFrameMagic), nil
+}
+
+type zstdChunkedInfo struct {
+	blobInfo
+	annotations map[string]string
+}
+
+// writeZstdChunked writes the tar stream r to dest as a zstd:chunked layer.
+// The content of each regular file is compressed in separate zstd frames so
+// that it can be fetched individually using the offsets of the TOC. The TOC,
+// the tar-split data to recreate the original tar stream and the footer are
+// appended in zstd skippable frames.
+func writeZstdChunked(dest io.Writer, r io.Reader, level zstd.EncoderLevel) (_ *zstdChunkedInfo, retErr error) {
+	compressed := digest.Canonical.Digester()
+	cw := &countingWriter{w: io.MultiWriter(dest, compressed.Hash())}
+	zw, err := zstd.NewWriter(cw, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	defer zw.Close()
+	// restart ends the current zstd frame so that the next frame starts at
+	// the returned offset
+	restart := func() (int64, error) {
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		zw.Reset(cw)
+		return cw.n, nil
+	}
+
+	var tarSplit bytes.Buffer
+	tarSplitSize := &countingWriter{w: io.Discard}
+	tsw, err := zstd.NewWriter(&tarSplit, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	defer tsw.Close()
+	its, itsDone, err := asm.NewInputTarStreamWithDone(r, storage.NewJSONPacker(io.MultiWriter(tsw, tarSplitSize)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			// stop the tar-split goroutine that would otherwise block on
+			// writing the rest of the stream
+			its.Close()
+			<-itsDone
+		}
+	}()
+
+	uncompressed := digest.Canonical.Digester()
+	uncompressedSize := &countingWriter{w: uncompressed.Hash()}
+	src := io.TeeReader(its, io.MultiWriter(zw, uncompressedSize))
+	tr := tar.NewReader(src)
+	var entries []zstdChunkedTOCEntry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tar stream")
+		}
+		e, err := newZstdChunkedTOCEntry(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && hdr.Size > 0 {
+			if e.Offset, err = restart(); err != nil {
+				return nil, err
+			}
+			dgst := digest.Canonical.Digester()
+			if _, err := io.Copy(dgst.Hash(), tr); err != nil {
+				return nil, errors.Wrapf(err, "failed to read %s", hdr.Name)
+			}
+			if e.EndOffset, err = restart(); err != nil {
+				return nil, err
+			}
+			e.Digest = dgst.Digest().String()
+		}
+		entries = append(entries, e)
+	}
+	// the tar footer and padding are part of the last frame
+	if _, err := io.Copy(io.Discard, src); err != nil {
+		return nil, err
+	}
+	// the tar-split metadata is complete after the goroutine has finished
+	if err := <-itsDone; err != nil {
+		return nil, errors.Wrap(err, "failed to read tar-split metadata")
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := tsw.Close(); err != nil {
+		return nil, err
+	}
+
+	tocJSON, err := json.Marshal(zstdChunkedTOC{
+		Version:        1,
+		Entries:        entries,
+		TarSplitDigest: digest.FromBytes(tarSplit.Bytes()),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal zstd:chunked TOC")
+	}
+	var toc bytes.Buffer
+	tocW, err := zstd.NewWriter(&toc, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tocW.Write(tocJSON); err != nil {
+		tocW.Close()
+		return nil, err
+	}
+	if err := tocW.Close(); err != nil {
+		return nil, err
+	}
+
+	tocOffset := cw.n + zstdSkippableFrameHeaderSize
+	if err := writeZstdSkippableFrame(cw, toc.Bytes()); err != nil {
+		return nil, err
+	}
+	tarSplitOffset := cw.n + zstdSkippableFrameHeaderSize
+	if err := writeZstdSkippableFrame(cw, tarSplit.Bytes()); err != nil {
+		return nil, err
+	}
+
+	footer := make([]byte, zstdChunkedFooterSize)
+	binary.LittleEndian.PutUint64(footer[8*0:], uint64(tocOffset))
+	binary.LittleEndian.PutUint64(footer[8*1:], uint64(toc.Len()))
+	binary.LittleEndian.PutUint64(footer[8*2:], uint64(len(tocJSON)))
+	binary.LittleEndian.PutUint64(footer[8*3:], zstdChunkedManifestTypeCRFS)
+	binary.LittleEndian.PutUint64(footer[8*4:], uint64(tarSplitOffset))
+	binary.LittleEndian.PutUint64(footer[8*5:], uint64(tarSplit.Len()))
+	binary.LittleEndian.PutUint64(footer[8*6:], uint64(tarSplitSize.n))
+	copy(footer[8*7:], zstdChunkedFrameMagic)
+	if err := writeZstdSkippableFrame(cw, footer); err != nil {
+		return nil, err
+	}
+
+	return &zstdChunkedInfo{
+		blobInfo: blobInfo{
+			compressedDigest:   compressed.Digest(),
+			uncompressedDigest: uncompressed.Digest(),
+			uncompressedSize:   uncompressedSize.n,
+		},
+		annotations: map[string]string{
+			ZstdChunkedManifestChecksumAnnotation: digest.FromBytes(toc.Bytes()).String(),
+			ZstdChunkedManifestPositionAnnotation: fmt.Sprintf("%d:%d:%d:%d", tocOffset, toc.Len(), len(tocJSON), zstdChunkedManifestTypeCRFS),
+			ZstdChunkedTarSplitPositionAnnotation: fmt.Sprintf("%d:%d:%d", tarSplitOffset, tarSplit.Len(), tarSplitSize.n),
+		},
+	}, nil
+}
+
+func newZstdChunkedTOCEntry(hdr *tar.Header) (zstdChunkedTOCEntry, error) {
+	typ, ok := zstdChunkedTypes[hdr.Typeflag]
+	if !ok {
+		return zstdChunkedTOCEntry{}, errors.Errorf("unsupported type %q of %s for zstd:chunked", hdr.Typeflag, hdr.Name)
+	}
+	e := zstdChunkedTOCEntry{
+		Type:     typ,
+		Name:     hdr.Name,
+		Linkname: hdr.Linkname,
+		Mode:     hdr.Mode,
+		Size:     hdr.Size,
+		UID:      hdr.Uid,
+		GID:      hdr.Gid,
+		ModTime:  &hdr.ModTime,
+		Devmajor: hdr.Devmajor,
+		Devminor: hdr.Devminor,
+	}
+	if !hdr.AccessTime.IsZero() {
+		e.AccessTime = &hdr.AccessTime
+	}
+	if !hdr.ChangeTime.IsZero() {
+		e.ChangeTime = &hdr.ChangeTime
+	}
+	for k, v := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(k, "SCHILY.xattr."); ok {
+			if e.Xattrs == nil {
+				e.Xattrs = make(map[string]string)
+			}
+			e.Xattrs[name] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	return e, nil
+}
+
+func writeZstdSkippableFrame(w io.Writer, data []byte) error {
+	hdr := make([]byte, zstdSkippableFrameHeaderSize)
+	copy(hdr, zstdSkippableFrameMagic)
+	binary.LittleEndian.PutUint32(hdr[len(zstdSkippableFrameMagic):], uint32(len(data)))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,129 @@
+package compression
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+	"github.com/vbatts/tar-split/tar/asm"
+	"github.com/vbatts/tar-split/tar/storage"
+)
+
+func TestZstdChunked(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"foo":     "foo content",
+		"dir/bar": strings.Repeat("bar", 10000),
+		"empty":   "",
+	}
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0o755, ModTime: time.Unix(1000, 0)}))
+	for _, name := range []string{"foo", "dir/bar", "empty"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeReg,
+			Name:       name,
+			Size:       int64(len(files[name])),
+			Mode:       0o644,
+			ModTime:    time.Unix(1000, 0),
+			PAXRecords: map[string]string{"SCHILY.xattr.user.foo": "bar"},
+		}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "foo", ModTime: time.Unix(1000, 0)}))
+	require.NoError(t, tw.Close())
+	tarData := buf.Bytes()
+
+	out := bytes.NewBuffer(nil)
+	info, err := writeZstdChunked(out, bytes.NewReader(tarData), zstd.SpeedDefault)
+	require.NoError(t, err)
+	blob := out.Bytes()
+	require.Equal(t, digest.FromBytes(blob), info.compressedDigest)
+	require.Equal(t, digest.FromBytes(tarData), info.uncompressedDigest)
+	require.Equal(t, int64(len(tarData)), info.uncompressedSize)
+
+	// the blob is a valid zstd stream of the original tar
+	zr, err := zstd.NewReader(bytes.NewReader(blob))
+	require.NoError(t, err)
+	defer zr.Close()
+	dt, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, tarData, dt)
+
+	position := func(key string) []int64 {
+		var pos []int64
+		for v := range strings.SplitSeq(info.annotations[key], ":") {
+			n, err := strconv.ParseInt(v, 10, 64)
+			require.NoError(t, err)
+			pos = append(pos, n)
+		}
+		return pos
+	}
+	decode := func(offset, length int64) []byte {
+		dt, err := zr.DecodeAll(blob[offset:offset+length], nil)
+		require.NoError(t, err)
+		return dt
+	}
+
+	// the footer points to the TOC
+	footer := blob[len(blob)-zstdChunkedFooterSize:]
+	require.Equal(t, zstdChunkedFrameMagic, footer[8*7:])
+	tocPos := position(ZstdChunkedManifestPositionAnnotation)
+	require.Equal(t, []int64{int64(binary.LittleEndian.Uint64(footer)), int64(binary.LittleEndian.Uint64(footer[8:])), int64(binary.LittleEndian.Uint64(footer[16:])), zstdChunkedManifestTypeCRFS}, tocPos)
+	require.Equal(t, digest.FromBytes(blob[tocPos[0]:tocPos[0]+tocPos[1]]).String(), info.annotations[ZstdChunkedManifestChecksumAnnotation])
+
+	var toc zstdChunkedTOC
+	tocJSON := decode(tocPos[0], tocPos[1])
+	require.Len(t, tocJSON, int(tocPos[2]))
+	require.NoError(t, json.Unmarshal(tocJSON, &toc))
+	require.Equal(t, 1, toc.Version)
+	require.Len(t, toc.Entries, 5)
+
+	// the content of each file can be read from its offsets
+	var names []string
+	for _, e := range toc.Entries {
+		names = append(names, e.Name)
+		if e.Type != "reg" {
+			continue
+		}
+		require.Equal(t, map[string]string{"user.foo": "YmFy"}, e.Xattrs)
+		if e.Size == 0 {
+			require.Zero(t, e.Offset)
+			continue
+		}
+		dt := decode(e.Offset, e.EndOffset-e.Offset)
+		require.Equal(t, files[e.Name], string(dt))
+		require.Equal(t, digest.FromBytes(dt).String(), e.Digest)
+	}
+	require.Equal(t, []string{"dir/", "foo", "dir/bar", "empty", "link"}, names)
+	require.Equal(t, "foo", toc.Entries[4].Linkname)
+
+	// the tar-split data recreates the original tar
+	tsPos := position(ZstdChunkedTarSplitPositionAnnotation)
+	tarSplit := blob[tsPos[0] : tsPos[0]+tsPos[1]]
+	require.Equal(t, toc.TarSplitDigest, digest.FromBytes(tarSplit))
+	tsData := decode(tsPos[0], tsPos[1])
+	require.Len(t, tsData, int(tsPos[2]))
+	rc := asm.NewOutputTarStream(testFileGetter(files), storage.NewJSONUnpacker(bytes.NewReader(tsData)))
+	defer rc.Close()
+	dt, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, tarData, dt)
+}
+
+type testFileGetter map[string]string
+
+func (g testFileGetter) Get(name string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(g[name])), nil
+}
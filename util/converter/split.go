@@ -0,0 +1,262 @@
+package converter
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/pkg/labels"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/util/compression"
+	"github.com/moby/go-archive"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// SplitOpt defines how Split splits a layer.
+type SplitOpt struct {
+	// MaxSize is the approximate size of the compressed layers that the layer
+	// is split into. 0 means no limit.
+	MaxSize int64
+	// Top returns true for the paths that are moved to layers above the
+	// layers with the other paths of the layer.
+	Top func(p string) bool
+}
+
+// Split splits the layer desc into layers that are applied in order. Entries
+// are never reordered except for moving the entries of Top paths above the
+// others, so whiteouts are still applied before the entries they precede.
+// Split returns nil if the layer doesn't need to be split.
+func Split(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, comp compression.Config, opt SplitOpt) ([]ocispecs.Descriptor, error) {
+	from, err := compression.FromMediaType(desc.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first pass assigns the entries to layers
+	var entries []splitEntry
+	var total int64
+	if err := walkLayer(ctx, cs, desc, from, func(hdr *tar.Header, size int64, _ io.Reader) error {
+		e := splitEntry{size: size}
+		if opt.Top != nil {
+			e.top = opt.Top(entryPath(hdr.Name))
+			if !e.top && hdr.Typeflag == tar.TypeLink {
+				// hard links need to be created after their targets
+				e.top = opt.Top(entryPath(hdr.Linkname))
+			}
+		}
+		entries = append(entries, e)
+		total += size
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	limit := total
+	if opt.MaxSize > 0 && desc.Size > 0 {
+		// estimate the uncompressed size of the split layers from the
+		// compression ratio of the layer
+		limit = max(opt.MaxSize*total/desc.Size, 1)
+	}
+	numLayers := assignLayers(entries, limit)
+	if numLayers <= 1 {
+		return nil, nil
+	}
+
+	writers := make([]*splitWriter, numLayers)
+	defer func() {
+		for _, w := range writers {
+			if w != nil {
+				w.cw.Close()
+			}
+		}
+	}()
+	for i := range writers {
+		w, err := newSplitWriter(ctx, cs, desc, comp, i)
+		if err != nil {
+			return nil, err
+		}
+		writers[i] = w
+	}
+
+	var i int
+	if err := walkLayer(ctx, cs, desc, from, func(hdr *tar.Header, _ int64, r io.Reader) error {
+		if i >= len(entries) {
+			return errors.Errorf("layer %s changed while it was split", desc.Digest)
+		}
+		w := writers[entries[i].layer]
+		i++
+		if err := w.tw.WriteHeader(hdr); err != nil {
+			return errors.WithStack(err)
+		}
+		//nolint:gosec // G110: the layer was created by the worker
+		_, err := io.Copy(w.tw, r)
+		return errors.WithStack(err)
+	}); err != nil {
+		return nil, err
+	}
+
+	descs := make([]ocispecs.Descriptor, numLayers)
+	for i, w := range writers {
+		d, err := w.commit(ctx, cs, desc, comp)
+		if err != nil {
+			return nil, err
+		}
+		descs[i] = *d
+	}
+	return descs, nil
+}
+
+type splitEntry struct {
+	size  int64
+	top   bool
+	layer int
+}
+
+// assignLayers assigns the entries to layers of about limit bytes. The entries
+// that aren't top entries are assigned to the bottom layers. It returns the
+// number of layers.
+func assignLayers(entries []splitEntry, limit int64) int {
+	var layer int
+	for _, top := range []bool{false, true} {
+		var size int64
+		var used bool
+		for i, e := range entries {
+			if e.top != top {
+				continue
+			}
+			if used && size+e.size > limit {
+				layer++
+				size = 0
+			}
+			entries[i].layer = layer
+			size += e.size
+			used = true
+		}
+		if used {
+			layer++
+		}
+	}
+	return layer
+}
+
+// entryPath returns the path that a tar entry of a layer changes.
+func entryPath(name string) string {
+	p := path.Clean("/" + name)
+	dir, base := path.Split(p)
+	switch {
+	case base == archive.WhiteoutOpaqueDir:
+		return path.Clean(dir)
+	case strings.HasPrefix(base, archive.WhiteoutPrefix):
+		return path.Join(dir, strings.TrimPrefix(base, archive.WhiteoutPrefix))
+	}
+	return p
+}
+
+// walkLayer calls fn for each entry of the layer with an estimate of the
+// number of bytes the entry takes in the tar stream.
+func walkLayer(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, from compression.Type, fn func(hdr *tar.Header, size int64, r io.Reader) error) error {
+	rc, err := from.Decompress(ctx, cs, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read layer %s", desc.Digest)
+		}
+		// header block and content padded to the block size
+		size := 512 + (hdr.Size+511)&^511
+		if err := fn(hdr, size, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type splitWriter struct {
+	cw       content.Writer
+	bufW     *bufio.Writer
+	zw       io.WriteCloser
+	tw       *tar.Writer
+	diffID   digest.Digester
+	finalize compression.Finalizer
+}
+
+func newSplitWriter(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, comp compression.Config, i int) (*splitWriter, error) {
+	ref := fmt.Sprintf("split-%s-%d-%s", desc.Digest, i, identity.NewID())
+	cw, err := cs.Writer(ctx, content.WithRef(ref))
+	if err != nil {
+		return nil, err
+	}
+	if err := cw.Truncate(0); err != nil {
+		cw.Close()
+		return nil, err
+	}
+	w := &splitWriter{
+		cw:     cw,
+		bufW:   bufio.NewWriterSize(cw, 128*1024),
+		diffID: digest.Canonical.Digester(),
+	}
+	var compress compression.Compressor
+	compress, w.finalize = comp.Type.Compress(ctx, comp)
+	w.zw, err = compress(w.bufW, comp.Type.MediaType())
+	if err != nil {
+		cw.Close()
+		return nil, err
+	}
+	w.tw = tar.NewWriter(io.MultiWriter(w.zw, w.diffID.Hash()))
+	return w, nil
+}
+
+func (w *splitWriter) commit(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, comp compression.Config) (*ocispecs.Descriptor, error) {
+	if err := w.tw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := w.zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := w.bufW.Flush(); err != nil {
+		return nil, errors.Wrap(err, "failed to flush split layer")
+	}
+	diffID := w.diffID.Digest()
+	labelz := map[string]string{labels.LabelUncompressed: diffID.String()}
+	if err := w.cw.Commit(ctx, 0, "", content.WithLabels(labelz)); err != nil && !cerrdefs.IsAlreadyExists(err) {
+		return nil, err
+	}
+	dgst := w.cw.Digest()
+	if err := w.cw.Close(); err != nil {
+		return nil, err
+	}
+	info, err := cs.Info(ctx, dgst)
+	if err != nil {
+		return nil, err
+	}
+
+	newDesc := ocispecs.Descriptor{
+		MediaType:   comp.Type.MediaType(),
+		Digest:      info.Digest,
+		Size:        info.Size,
+		Annotations: map[string]string{labels.LabelUncompressed: diffID.String()},
+	}
+	if w.finalize != nil {
+		a, err := w.finalize(ctx, cs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed finalize compression")
+		}
+		maps.Copy(newDesc.Annotations, a)
+	}
+	return &newDesc, nil
+}
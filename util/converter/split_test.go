@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/containerd/containerd/v2/plugins/content/local"
+	"github.com/moby/buildkit/util/compression"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+type testEntry struct {
+	name string
+	data string
+	link string
+}
+
+func writeTestLayer(t *testing.T, cs content.Store, entries []testEntry) ocispecs.Descriptor {
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     e.name,
+			Size:     int64(len(e.data)),
+			Mode:     0o644,
+		}
+		if strings.HasSuffix(e.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0o755
+		}
+		if e.link != "" {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = e.link
+			hdr.Size = 0
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := tw.Write([]byte(e.data))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(buf.Bytes()),
+		Size:      int64(buf.Len()),
+	}
+	require.NoError(t, content.WriteBlob(t.Context(), cs, "test-layer", bytes.NewReader(buf.Bytes()), desc))
+	return desc
+}
+
+func readTestLayer(t *testing.T, cs content.Store, desc ocispecs.Descriptor) []testEntry {
+	ra, err := cs.ReaderAt(t.Context(), desc)
+	require.NoError(t, err)
+	defer ra.Close()
+
+	var entries []testEntry
+	tr := tar.NewReader(content.NewReader(ra))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		dt, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries = append(entries, testEntry{name: hdr.Name, data: string(dt), link: hdr.Linkname})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	cs, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	data := strings.Repeat("a", 4096)
+	entries := []testEntry{
+		{name: "bin/"},
+		{name: "bin/a", data: data},
+		{name: "bin/b", data: data},
+		{name: "var/cache/.wh.old"},
+		{name: "var/cache/new", data: data},
+		{name: "bin/c", data: data},
+		{name: "var/cache/link", link: "bin/a"},
+		{name: "bin/d", link: "var/cache/new"},
+	}
+	desc := writeTestLayer(t, cs, entries)
+	comp := compression.New(compression.Uncompressed)
+
+	descs, err := Split(t.Context(), cs, desc, comp, SplitOpt{})
+	require.NoError(t, err)
+	require.Nil(t, descs)
+
+	top := func(p string) bool {
+		return p == "/var/cache" || strings.HasPrefix(p, "/var/cache/")
+	}
+	descs, err = Split(t.Context(), cs, desc, comp, SplitOpt{Top: top})
+	require.NoError(t, err)
+	require.Len(t, descs, 2)
+	require.Equal(t, []testEntry{entries[0], entries[1], entries[2], entries[5]}, readTestLayer(t, cs, descs[0]))
+	require.Equal(t, []testEntry{entries[3], entries[4], entries[6], entries[7]}, readTestLayer(t, cs, descs[1]))
+
+	// two of the files fit into half of the layer
+	descs, err = Split(t.Context(), cs, desc, comp, SplitOpt{MaxSize: desc.Size / 2, Top: top})
+	require.NoError(t, err)
+	require.Len(t, descs, 3)
+
+	var all []testEntry
+	for _, d := range descs {
+		require.Equal(t, ocispecs.MediaTypeImageLayer, d.MediaType)
+		require.Equal(t, d.Digest.String(), d.Annotations[labels.LabelUncompressed])
+		all = append(all, readTestLayer(t, cs, d)...)
+	}
+	require.ElementsMatch(t, entries, all)
+}
@@ -17,12 +17,18 @@ const (
 	EntitlementSecurityInsecure Entitlement = "security.insecure"
 	EntitlementNetworkHost      Entitlement = "network.host"
 	EntitlementDevice           Entitlement = "device"
+	EntitlementSecurityProfile  Entitlement = "security.profile"
+	EntitlementNetworkCustom    Entitlement = "network.custom"
+	EntitlementSecurityRuntime  Entitlement = "security.runtime"
 )
 
 var all = map[Entitlement]struct{}{
 	EntitlementSecurityInsecure: {},
 	EntitlementNetworkHost:      {},
 	EntitlementDevice:           {},
+	EntitlementSecurityProfile:  {},
+	EntitlementNetworkCustom:    {},
+	EntitlementSecurityRuntime:  {},
 }
 
 type EntitlementsConfig interface {
@@ -165,11 +171,37 @@ func (s Set) Check(v Values) error {
 			return errors.Errorf("%s is not allowed", EntitlementSecurityInsecure)
 		}
 	}
+
+	if v.SecurityProfile {
+		if !s.Allowed(EntitlementSecurityProfile) {
+			return errors.Errorf("%s is not allowed", EntitlementSecurityProfile)
+		}
+	}
+
+	if v.NetworkCustom {
+		if !s.Allowed(EntitlementNetworkCustom) {
+			return errors.Errorf("%s is not allowed", EntitlementNetworkCustom)
+		}
+	}
+
+	if v.SecurityRuntime {
+		if !s.Allowed(EntitlementSecurityRuntime) {
+			return errors.Errorf("%s is not allowed", EntitlementSecurityRuntime)
+		}
+	}
 	return nil
 }
 
 type Values struct {
 	NetworkHost      bool
 	SecurityInsecure bool
-	Devices          map[string]struct{}
+	// SecurityProfile is set if the exec uses a security profile that
+	// loosens the default profile.
+	SecurityProfile bool
+	// NetworkCustom is set if the exec is attached to a named CNI network.
+	NetworkCustom bool
+	// SecurityRuntime is set if the exec selects an OCI runtime other than
+	// the default runtime of the worker.
+	SecurityRuntime bool
+	Devices         map[string]struct{}
 }
@@ -0,0 +1,287 @@
+// Package fairshare limits the number of concurrently running operations of a
+// worker and shares the available slots between builds.
+//
+// Queued operations of a higher priority class always get the next free slot
+// before queued operations of a lower class. Within a priority class, the
+// next slot goes to the tenant with the fewest running operations, so that a
+// single tenant with a large build can not starve the others. Operations of
+// the same tenant are started in the order they were queued.
+package fairshare
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
+)
+
+// JobValueKey is the key used to store the Request of a build on a solver
+// job via Job.SetValue/EachValue.
+const JobValueKey = "fairshare.request"
+
+// Priority is the priority class of a build.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// ParsePriority parses the name of a priority class. An empty string is the
+// normal priority.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return 0, errors.Errorf("invalid priority class %q, expected one of low, normal or high", s)
+	}
+}
+
+// Request identifies the priority class and tenant of a build.
+type Request struct {
+	Priority Priority
+	Tenant   string
+}
+
+type contextKeyT string
+
+var contextKey = contextKeyT("buildkit/util/fairshare")
+
+// WithRequest returns a context that acquires slots for the request.
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, contextKey, req)
+}
+
+// RequestFromContext returns the request set with WithRequest. Without a
+// request, the normal priority and the default tenant are used.
+func RequestFromContext(ctx context.Context) Request {
+	req, _ := ctx.Value(contextKey).(Request)
+	return req
+}
+
+// Scheduler is a counting semaphore that hands out free slots according to
+// the priority class and tenant of the waiting requests.
+type Scheduler struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	tenants map[string]int
+	queue   []*waiter
+	seq     uint64
+	sem     *semaphore.Weighted
+}
+
+type waiter struct {
+	req     Request
+	seq     uint64
+	ready   chan struct{}
+	updated chan struct{}
+}
+
+// New returns a scheduler that allows size concurrently running operations.
+func New(size int) *Scheduler {
+	return &Scheduler{
+		size:    size,
+		tenants: map[string]int{},
+	}
+}
+
+// FromSemaphore returns a scheduler that hands out the slots of sem in the
+// order they are requested, without priority classes or fair sharing between
+// tenants.
+func FromSemaphore(sem *semaphore.Weighted) *Scheduler {
+	return &Scheduler{sem: sem}
+}
+
+// Acquire waits for a free slot for the request in ctx. While the operation
+// is queued, its position in the queue is reported to the progress writer of
+// ctx. The returned function must be called to release the slot.
+func (s *Scheduler) Acquire(ctx context.Context) (func(), error) {
+	if s.sem != nil {
+		if err := s.sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		var once sync.Once
+		return func() {
+			once.Do(func() { s.sem.Release(1) })
+		}, nil
+	}
+
+	req := RequestFromContext(ctx)
+
+	s.mu.Lock()
+	if s.running < s.size && len(s.queue) == 0 {
+		s.start(req)
+		s.mu.Unlock()
+		return s.releaseFunc(req), nil
+	}
+	s.seq++
+	w := &waiter{
+		req:     req,
+		seq:     s.seq,
+		ready:   make(chan struct{}),
+		updated: make(chan struct{}, 1),
+	}
+	s.queue = append(s.queue, w)
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	pw, _, _ := progress.NewFromContext(ctx)
+	defer pw.Close()
+	id := "queue-" + identity.NewID()
+	now := time.Now()
+	st := progress.Status{Started: &now}
+
+	for {
+		select {
+		case <-w.ready:
+			now := time.Now()
+			st.Completed = &now
+			pw.Write(id, st)
+			return s.releaseFunc(req), nil
+		case <-w.updated:
+			pos, n := s.position(w)
+			if pos == 0 {
+				continue
+			}
+			st.Action = fmt.Sprintf("waiting for worker slot: position %d of %d in queue", pos, n)
+			pw.Write(id, st)
+		case <-ctx.Done():
+			s.mu.Lock()
+			select {
+			case <-w.ready:
+				// the slot was handed out concurrently
+				s.mu.Unlock()
+				s.releaseFunc(req)()
+			default:
+				s.removeLocked(w)
+				s.notifyLocked()
+				s.mu.Unlock()
+			}
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) releaseFunc(req Request) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running--
+			if s.tenants[req.Tenant]--; s.tenants[req.Tenant] <= 0 {
+				delete(s.tenants, req.Tenant)
+			}
+			s.dispatchLocked()
+		})
+	}
+}
+
+func (s *Scheduler) start(req Request) {
+	s.running++
+	s.tenants[req.Tenant]++
+}
+
+func (s *Scheduler) dispatchLocked() {
+	changed := false
+	for s.running < s.size && len(s.queue) > 0 {
+		w := s.queue[next(s.queue, s.tenants)]
+		s.removeLocked(w)
+		s.start(w.req)
+		close(w.ready)
+		changed = true
+	}
+	if changed {
+		s.notifyLocked()
+	}
+}
+
+func (s *Scheduler) removeLocked(w *waiter) {
+	for i, q := range s.queue {
+		if q == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyLocked wakes up the waiters to report their new queue position.
+func (s *Scheduler) notifyLocked() {
+	for _, w := range s.queue {
+		select {
+		case w.updated <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// position returns the 1-based position of w in the order the queued
+// operations would be started and the length of the queue. The position is
+// 0 if w is no longer queued.
+func (s *Scheduler) position(w *waiter) (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := append([]*waiter(nil), s.queue...)
+	tenants := make(map[string]int, len(s.tenants))
+	for t, n := range s.tenants {
+		tenants[t] = n
+	}
+	n := len(queue)
+	for pos := 1; len(queue) > 0; pos++ {
+		i := next(queue, tenants)
+		if queue[i] == w {
+			return pos, n
+		}
+		tenants[queue[i].req.Tenant]++
+		queue = append(queue[:i], queue[i+1:]...)
+	}
+	return 0, n
+}
+
+// next returns the index of the waiter that gets the next free slot: the
+// oldest waiter of the tenant with the fewest running operations in the
+// highest priority class.
+func next(queue []*waiter, tenants map[string]int) int {
+	best := 0
+	for i := 1; i < len(queue); i++ {
+		w, b := queue[i], queue[best]
+		switch {
+		case w.req.Priority != b.req.Priority:
+			if w.req.Priority > b.req.Priority {
+				best = i
+			}
+		case tenants[w.req.Tenant] != tenants[b.req.Tenant]:
+			if tenants[w.req.Tenant] < tenants[b.req.Tenant] {
+				best = i
+			}
+		case w.seq < b.seq:
+			best = i
+		}
+	}
+	return best
+}
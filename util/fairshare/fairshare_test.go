@@ -0,0 +1,149 @@
+package fairshare
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+// enqueue starts acquiring a slot for req in a goroutine and waits until the
+// request is queued.
+func enqueue(t *testing.T, ctx context.Context, s *Scheduler, req Request, started chan<- Request) <-chan error {
+	t.Helper()
+	s.mu.Lock()
+	n := len(s.queue)
+	s.mu.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		release, err := s.Acquire(WithRequest(ctx, req))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		started <- req
+		release()
+		errCh <- nil
+	}()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queue) == n+1
+	}, time.Second, time.Millisecond)
+	return errCh
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	ctx := t.Context()
+	s := New(1)
+
+	release, err := s.Acquire(ctx)
+	require.NoError(t, err)
+
+	started := make(chan Request, 3)
+	low := Request{Priority: PriorityLow, Tenant: "ci"}
+	normal := Request{Priority: PriorityNormal, Tenant: "ci"}
+	high := Request{Priority: PriorityHigh, Tenant: "dev"}
+	enqueue(t, ctx, s, low, started)
+	enqueue(t, ctx, s, normal, started)
+	w := s.queue[1]
+	enqueue(t, ctx, s, high, started)
+
+	pos, n := s.position(w)
+	require.Equal(t, 2, pos)
+	require.Equal(t, 3, n)
+
+	release()
+	require.Equal(t, high, <-started)
+	require.Equal(t, normal, <-started)
+	require.Equal(t, low, <-started)
+}
+
+func TestSchedulerTenants(t *testing.T) {
+	ctx := t.Context()
+	s := New(2)
+
+	// tenant a is using both slots
+	release1, err := s.Acquire(WithRequest(ctx, Request{Tenant: "a"}))
+	require.NoError(t, err)
+	release2, err := s.Acquire(WithRequest(ctx, Request{Tenant: "a"}))
+	require.NoError(t, err)
+
+	started := make(chan Request, 3)
+	a := Request{Tenant: "a"}
+	b := Request{Tenant: "b"}
+	enqueue(t, ctx, s, a, started)
+	enqueue(t, ctx, s, a, started)
+	enqueue(t, ctx, s, b, started)
+
+	// tenant b gets the next slot although it was queued last
+	release1()
+	require.Equal(t, b, <-started)
+	release2()
+	require.Equal(t, a, <-started)
+	require.Equal(t, a, <-started)
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	ctx := t.Context()
+	s := New(1)
+
+	release, err := s.Acquire(ctx)
+	require.NoError(t, err)
+
+	cctx, cancel := context.WithCancelCause(ctx)
+	started := make(chan Request, 1)
+	errCh := enqueue(t, cctx, s, Request{}, started)
+	cancel(context.Canceled)
+	require.ErrorIs(t, <-errCh, context.Canceled)
+
+	s.mu.Lock()
+	require.Empty(t, s.queue)
+	s.mu.Unlock()
+
+	release()
+	release, err = s.Acquire(ctx)
+	require.NoError(t, err)
+	release()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Equal(t, 0, s.running)
+	require.Empty(t, s.tenants)
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		parsed, err := ParsePriority(p.String())
+		require.NoError(t, err)
+		require.Equal(t, p, parsed)
+	}
+	p, err := ParsePriority("")
+	require.NoError(t, err)
+	require.Equal(t, PriorityNormal, p)
+
+	_, err = ParsePriority("urgent")
+	require.Error(t, err)
+}
+
+func TestSchedulerFromSemaphore(t *testing.T) {
+	sem := semaphore.NewWeighted(1)
+	s := FromSemaphore(sem)
+
+	release, err := s.Acquire(t.Context())
+	require.NoError(t, err)
+	require.False(t, sem.TryAcquire(1))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release()
+	require.True(t, sem.TryAcquire(1))
+	require.False(t, sem.TryAcquire(1))
+}
@@ -0,0 +1,182 @@
+// Package boltkv implements kv.Store on top of a bbolt database.
+package boltkv
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/moby/buildkit/util/db/boltutil"
+	"github.com/moby/buildkit/util/kv"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketName = []byte("kv")
+
+// compactTxMaxSize is the maximum size of a transaction when copying the
+// database during compaction.
+const compactTxMaxSize = 64 * 1024 * 1024
+
+type Store struct {
+	// mu is held for writing while the database file is replaced by Compact
+	mu   sync.RWMutex
+	path string
+	db   *bolt.DB
+}
+
+var _ kv.Store = &Store{}
+
+// Open opens or creates the bbolt database at path.
+func Open(path string) (*Store, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, db: db}, nil
+}
+
+func open(path string) (*bolt.DB, error) {
+	bdb, err := boltutil.SafeOpen(path, 0600, &bolt.Options{
+		NoSync:       true,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	db, ok := bdb.(*bolt.DB)
+	if !ok {
+		bdb.Close()
+		return nil, errors.Errorf("unexpected database type %T", bdb)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *Store) View(fn func(kv.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTx{b: tx.Bucket(bucketName)})
+	})
+}
+
+func (s *Store) Update(fn func(kv.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{b: tx.Bucket(bucketName), writable: true})
+	})
+}
+
+func (s *Store) Size() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var size int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Compact copies the live keys to a new database file and replaces the
+// current file with it. Transactions are blocked while the file is replaced.
+func (s *Store) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return context.Cause(ctx)
+	}
+
+	tmp := filepath.Join(filepath.Dir(s.path), "."+filepath.Base(s.path)+".compact")
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0600, &bolt.Options{
+		NoSync:       true,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := bolt.Compact(dst, s.db, compactTxMaxSize); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return errors.Wrap(err, "failed to compact database")
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := s.db.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		// reopen the old database so the store stays usable
+		if db, err2 := open(s.path); err2 == nil {
+			s.db = db
+		}
+		return errors.WithStack(err)
+	}
+	db, err := open(s.path)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
+
+type boltTx struct {
+	b        *bolt.Bucket
+	writable bool
+}
+
+func (tx *boltTx) Get(key []byte) ([]byte, error) {
+	return tx.b.Get(key), nil
+}
+
+func (tx *boltTx) Put(key, value []byte) error {
+	if !tx.writable {
+		return errors.New("put in read-only transaction")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return tx.b.Put(key, value)
+}
+
+func (tx *boltTx) Delete(key []byte) error {
+	if !tx.writable {
+		return errors.New("delete in read-only transaction")
+	}
+	return tx.b.Delete(key)
+}
+
+func (tx *boltTx) Scan(prefix []byte, fn func(key, value []byte) error) error {
+	c := tx.b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
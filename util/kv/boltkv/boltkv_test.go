@@ -0,0 +1,57 @@
+package boltkv
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/util/kv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "kv.db")
+	s, err := Open(p)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Update(func(tx kv.Tx) error {
+		for i := range 1000 {
+			if err := tx.Put([]byte(fmt.Sprintf("key%04d", i)), make([]byte, 1024)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, s.Update(func(tx kv.Tx) error {
+		for i := 1; i < 1000; i++ {
+			if err := tx.Delete([]byte(fmt.Sprintf("key%04d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	before, err := s.Size()
+	require.NoError(t, err)
+	require.NoError(t, s.Compact(context.TODO()))
+	after, err := s.Size()
+	require.NoError(t, err)
+	require.Less(t, after, before)
+
+	err = s.View(func(tx kv.Tx) error {
+		return tx.Put([]byte("foo"), nil)
+	})
+	require.Error(t, err)
+
+	require.NoError(t, s.Close())
+
+	s, err = Open(p)
+	require.NoError(t, err)
+	require.NoError(t, s.View(func(tx kv.Tx) error {
+		v, err := tx.Get([]byte("key0000"))
+		require.Len(t, v, 1024)
+		return err
+	}))
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,41 @@
+// Package kv defines a minimal ordered key-value store interface so that
+// indexes like the solver cache key storage can be backed by different
+// storage engines.
+package kv
+
+import (
+	"context"
+	"io"
+)
+
+// Store is an ordered key-value store with serializable transactions.
+type Store interface {
+	io.Closer
+
+	// View runs fn in a read-only transaction.
+	View(fn func(Tx) error) error
+	// Update runs fn in a read-write transaction. The changes are applied
+	// atomically if fn returns nil and discarded otherwise.
+	Update(fn func(Tx) error) error
+
+	// Size returns the number of bytes the store uses on disk.
+	Size() (int64, error)
+	// Compact reclaims the space of deleted and overwritten keys. The store
+	// can be used concurrently while it is compacted.
+	Compact(ctx context.Context) error
+}
+
+// Tx is a transaction of a Store. Keys and values passed to and returned by a
+// transaction are only valid until the transaction ends.
+type Tx interface {
+	// Get returns the value of key or nil if the key does not exist. Keys
+	// that exist have a non-nil value.
+	Get(key []byte) ([]byte, error)
+	// Put sets the value of key. A Tx returned by View returns an error.
+	Put(key, value []byte) error
+	// Delete removes key. Deleting a key that doesn't exist is not an error.
+	Delete(key []byte) error
+	// Scan calls fn for all keys with prefix in ascending order. Modifying
+	// the transaction from fn is not allowed.
+	Scan(prefix []byte, fn func(key, value []byte) error) error
+}
@@ -0,0 +1,489 @@
+// Package lsm implements kv.Store as a small log-structured merge tree in
+// pure Go.
+//
+// Writes are appended to a write-ahead log and kept in a sorted in-memory
+// table. When the in-memory table grows beyond a threshold it is flushed to
+// an immutable sorted table file. Reads look up the in-memory table and then
+// the table files from the newest to the oldest. Compact merges all table
+// files into one and drops deleted and overwritten keys.
+package lsm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/kv"
+	"github.com/pkg/errors"
+)
+
+const (
+	walName     = "wal.log"
+	tableSuffix = ".sst"
+	// compactName records the tables replaced by a compaction until they
+	// are removed, so that an interrupted compaction can be completed
+	compactName = "compact.pending"
+
+	// DefaultMemtableSize is the size of the in-memory table that triggers
+	// a flush to a table file.
+	DefaultMemtableSize = 4 * 1024 * 1024
+)
+
+type Opt struct {
+	// MemtableSize is the size of the in-memory table that triggers a
+	// flush to a table file. Defaults to DefaultMemtableSize.
+	MemtableSize int
+}
+
+type Store struct {
+	dir string
+	opt Opt
+
+	// mu protects the memtable, the WAL and the list of tables. Update
+	// holds it for writing for the whole transaction.
+	mu      sync.RWMutex
+	mem     memtable
+	wal     *wal
+	tables  []*table // oldest first
+	nextSeq uint64
+	closed  bool
+
+	// compactMu allows only one compaction at a time
+	compactMu sync.Mutex
+}
+
+var _ kv.Store = &Store{}
+
+// Open opens or creates a store in dir.
+func Open(dir string, opt Opt) (*Store, error) {
+	if opt.MemtableSize <= 0 {
+		opt.MemtableSize = DefaultMemtableSize
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s := &Store{dir: dir, opt: opt, nextSeq: 1}
+
+	if err := recoverCompaction(dir); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasPrefix(name, ".") {
+			// leftover temporary file of an interrupted flush or compaction
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, tableSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, tableSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		t, err := openTable(filepath.Join(dir, name), seq)
+		if err != nil {
+			s.closeTables()
+			return nil, err
+		}
+		s.tables = append(s.tables, t)
+		s.nextSeq = max(s.nextSeq, seq+1)
+	}
+	slices.SortFunc(s.tables, func(a, b *table) int {
+		return compareSeq(a.seq, b.seq)
+	})
+
+	w, err := openWAL(filepath.Join(dir, walName), s.mem.set)
+	if err != nil {
+		s.closeTables()
+		return nil, err
+	}
+	// the WAL may have been created
+	if err := syncDir(dir); err != nil {
+		w.close()
+		s.closeTables()
+		return nil, err
+	}
+	s.wal = w
+	return s, nil
+}
+
+func compareSeq(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s *Store) tablePath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", seq, tableSuffix))
+}
+
+func (s *Store) View(fn func(kv.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("store is closed")
+	}
+	return fn(&tx{s: s})
+}
+
+func (s *Store) Update(fn func(kv.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("store is closed")
+	}
+	t := &tx{s: s, writable: true, pending: &memtable{}}
+	if err := fn(t); err != nil {
+		return err
+	}
+	if len(t.pending.entries) == 0 {
+		return nil
+	}
+	if err := s.wal.append(t.pending.entries); err != nil {
+		return err
+	}
+	for _, e := range t.pending.entries {
+		s.mem.set(e)
+	}
+	if s.mem.size >= s.opt.MemtableSize {
+		// the batch is committed to the WAL, a failed flush is retried by
+		// the next update
+		if err := s.flushLocked(); err != nil {
+			bklog.L.WithError(err).Warnf("failed to flush memtable of %s", s.dir)
+		}
+	}
+	return nil
+}
+
+// flushLocked writes the memtable to a new table and resets the WAL.
+func (s *Store) flushLocked() error {
+	if len(s.mem.entries) == 0 {
+		return nil
+	}
+	seq := s.nextSeq
+	p := s.tablePath(seq)
+	tmp := filepath.Join(s.dir, "."+filepath.Base(p))
+	if err := writeTable(tmp, s.mem.entries); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	// the table must be in place before the WAL is truncated
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	t, err := openTable(p, seq)
+	if err != nil {
+		return err
+	}
+	s.nextSeq++
+	s.tables = append(s.tables, t)
+	s.mem.reset()
+	return s.wal.reset()
+}
+
+func (s *Store) get(key string) (entry, bool, error) {
+	if e, ok := s.mem.get(key); ok {
+		return e, true, nil
+	}
+	for i := len(s.tables) - 1; i >= 0; i-- {
+		e, ok, err := s.tables[i].get(key)
+		if err != nil || ok {
+			return e, ok, err
+		}
+	}
+	return entry{}, false, nil
+}
+
+// scan returns the live entries with prefix. The layers are searched from
+// the newest to the oldest and the first entry of a key wins.
+func (s *Store) scan(prefix string, pending *memtable) ([]entry, error) {
+	seen := map[string]struct{}{}
+	var out []entry
+	add := func(e entry) error {
+		if _, ok := seen[e.key]; ok {
+			return nil
+		}
+		seen[e.key] = struct{}{}
+		if !e.deleted {
+			out = append(out, e)
+		}
+		return nil
+	}
+	if pending != nil {
+		if err := pending.scan(prefix, add); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.mem.scan(prefix, add); err != nil {
+		return nil, err
+	}
+	for i := len(s.tables) - 1; i >= 0; i-- {
+		if err := s.tables[i].scan(prefix, add); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(out, func(a, b entry) int {
+		return strings.Compare(a.key, b.key)
+	})
+	return out, nil
+}
+
+func (s *Store) Size() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	size, err := s.wal.size()
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range s.tables {
+		size += t.size
+	}
+	return size, nil
+}
+
+// Compact flushes the memtable and merges all tables into one. Reads and
+// writes can continue while the tables are merged. Tables flushed during
+// the compaction are not merged.
+func (s *Store) Compact(ctx context.Context) error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("store is closed")
+	}
+	if err := s.flushLocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	tables := slices.Clone(s.tables)
+	s.mu.Unlock()
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	// the oldest table is merged, so tombstones can be dropped
+	var merged []entry
+	seen := map[string]struct{}{}
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+		if err := tables[i].scan("", func(e entry) error {
+			if _, ok := seen[e.key]; ok {
+				return nil
+			}
+			seen[e.key] = struct{}{}
+			if !e.deleted {
+				merged = append(merged, e)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	slices.SortFunc(merged, func(a, b entry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	// the merged table replaces the newest merged table, so it stays older
+	// than the tables flushed during the compaction
+	last := tables[len(tables)-1]
+	tmp := filepath.Join(s.dir, "."+filepath.Base(last.path)+".compact")
+	if err := writeTable(tmp, merged); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		os.Remove(tmp)
+		return errors.New("store is closed")
+	}
+	// the merged table doesn't contain the tombstones anymore, so the
+	// replaced tables must not be loaded again once it is in place
+	pending := []string{filepath.Base(tmp)}
+	for _, t := range tables[:len(tables)-1] {
+		pending = append(pending, filepath.Base(t.path))
+	}
+	if err := writeFileSync(filepath.Join(s.dir, compactName), []byte(strings.Join(pending, "\n"))); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, last.path); err != nil {
+		os.Remove(filepath.Join(s.dir, compactName))
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	t, err := openTable(last.path, last.seq)
+	if err != nil {
+		return err
+	}
+	for _, old := range tables {
+		old.close()
+		if old != last {
+			os.Remove(old.path)
+		}
+	}
+	s.tables = append([]*table{t}, s.tables[len(tables):]...)
+	return errors.WithStack(os.Remove(filepath.Join(s.dir, compactName)))
+}
+
+// recoverCompaction completes or rolls back a compaction that was
+// interrupted. If the merged table was not moved in place yet, the
+// compaction is discarded. Otherwise the replaced tables are removed.
+func recoverCompaction(dir string) error {
+	dt, err := os.ReadFile(filepath.Join(dir, compactName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	names := strings.Split(string(dt), "\n")
+	for _, name := range names {
+		if name != filepath.Base(name) {
+			return errors.Errorf("invalid file %q in %s", name, compactName)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, names[0])); err == nil {
+		os.Remove(filepath.Join(dir, names[0]))
+	} else {
+		for _, name := range names[1:] {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	return errors.WithStack(os.Remove(filepath.Join(dir, compactName)))
+}
+
+func writeFileSync(p string, dt []byte) error {
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.Write(dt); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+// syncDir makes the creation, removal and renaming of the files in dir
+// durable.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	return errors.WithStack(f.Sync())
+}
+
+// Close flushes the memtable and closes the store.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.flushLocked()
+	if err2 := s.wal.close(); err == nil {
+		err = errors.WithStack(err2)
+	}
+	s.closeTables()
+	return err
+}
+
+func (s *Store) closeTables() {
+	for _, t := range s.tables {
+		t.close()
+	}
+	s.tables = nil
+}
+
+type tx struct {
+	s        *Store
+	writable bool
+	pending  *memtable
+}
+
+func (t *tx) Get(key []byte) ([]byte, error) {
+	k := string(key)
+	if t.pending != nil {
+		if e, ok := t.pending.get(k); ok {
+			if e.deleted {
+				return nil, nil
+			}
+			return e.value, nil
+		}
+	}
+	e, ok, err := t.s.get(k)
+	if err != nil || !ok || e.deleted {
+		return nil, err
+	}
+	return e.value, nil
+}
+
+func (t *tx) Put(key, value []byte) error {
+	if !t.writable {
+		return errors.New("put in read-only transaction")
+	}
+	v := make([]byte, len(value))
+	copy(v, value)
+	t.pending.set(entry{key: string(key), value: v})
+	return nil
+}
+
+func (t *tx) Delete(key []byte) error {
+	if !t.writable {
+		return errors.New("delete in read-only transaction")
+	}
+	t.pending.set(entry{key: string(key), deleted: true})
+	return nil
+}
+
+func (t *tx) Scan(prefix []byte, fn func(key, value []byte) error) error {
+	entries, err := t.s.scan(string(prefix), t.pending)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := fn([]byte(e.key), e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
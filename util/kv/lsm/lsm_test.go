@@ -0,0 +1,165 @@
+package lsm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/util/kv"
+	"github.com/stretchr/testify/require"
+)
+
+func put(t *testing.T, s *Store, k, v string) {
+	require.NoError(t, s.Update(func(tx kv.Tx) error {
+		return tx.Put([]byte(k), []byte(v))
+	}))
+}
+
+func get(t *testing.T, s *Store, k string) []byte {
+	var v []byte
+	require.NoError(t, s.View(func(tx kv.Tx) error {
+		dt, err := tx.Get([]byte(k))
+		v = dt
+		return err
+	}))
+	return v
+}
+
+func keys(t *testing.T, s *Store, prefix string) []string {
+	var out []string
+	require.NoError(t, s.View(func(tx kv.Tx) error {
+		return tx.Scan([]byte(prefix), func(k, _ []byte) error {
+			out = append(out, string(k))
+			return nil
+		})
+	}))
+	return out
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Opt{MemtableSize: 64})
+	require.NoError(t, err)
+
+	for i := range 20 {
+		put(t, s, fmt.Sprintf("a/%02d", i), fmt.Sprintf("v%d", i))
+	}
+	put(t, s, "b", "")
+	require.NoError(t, s.Update(func(tx kv.Tx) error {
+		for i := 0; i < 20; i += 2 {
+			if err := tx.Delete([]byte(fmt.Sprintf("a/%02d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	err = s.Update(func(tx kv.Tx) error {
+		if err := tx.Put([]byte("a/00"), []byte("discarded")); err != nil {
+			return err
+		}
+		return os.ErrInvalid
+	})
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	check := func(s *Store) {
+		require.Nil(t, get(t, s, "a/00"))
+		require.Equal(t, []byte("v1"), get(t, s, "a/01"))
+		require.NotNil(t, get(t, s, "b"))
+		require.Len(t, keys(t, s, "a/"), 10)
+		require.Equal(t, []string{"a/01", "a/03"}, keys(t, s, "a/0")[:2])
+	}
+	check(s)
+
+	require.Greater(t, len(s.tables), 1)
+	require.NoError(t, s.Compact(context.TODO()))
+	require.Len(t, s.tables, 1)
+	check(s)
+
+	require.NoError(t, s.Close())
+
+	s, err = Open(dir, Opt{MemtableSize: 64})
+	require.NoError(t, err)
+	check(s)
+	require.NoError(t, s.Close())
+
+	err = s.View(func(tx kv.Tx) error { return nil })
+	require.Error(t, err)
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Opt{})
+	require.NoError(t, err)
+	put(t, s, "foo", "bar")
+	put(t, s, "foo2", "bar2")
+
+	// simulate a crash with a torn write at the end of the log
+	f, err := os.OpenFile(filepath.Join(dir, walName), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x20, 0x01, 0x02})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s2, err := Open(dir, Opt{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), get(t, s2, "foo"))
+	require.Equal(t, []byte("bar2"), get(t, s2, "foo2"))
+	put(t, s2, "foo3", "bar3")
+	require.NoError(t, s2.Close())
+
+	s3, err := Open(dir, Opt{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "foo2", "foo3"}, keys(t, s3, ""))
+	require.NoError(t, s3.Close())
+	require.NoError(t, s.wal.close())
+}
+
+func TestRecoverCompaction(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Opt{MemtableSize: 1})
+	require.NoError(t, err)
+	put(t, s, "foo", "bar")
+	require.NoError(t, s.Update(func(tx kv.Tx) error {
+		return tx.Delete([]byte("foo"))
+	}))
+	require.Len(t, s.tables, 2)
+	require.NoError(t, s.Close())
+
+	// interrupted after the merged table without the tombstone was moved in
+	// place but before the replaced tables were removed
+	require.NoError(t, writeTable(filepath.Join(dir, fmt.Sprintf("%016d%s", 2, tableSuffix)), nil))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, compactName), []byte(".0000000000000002.sst.compact\n"+fmt.Sprintf("%016d%s", 1, tableSuffix)), 0600))
+
+	s, err = Open(dir, Opt{})
+	require.NoError(t, err)
+	require.Nil(t, get(t, s, "foo"))
+	require.Len(t, s.tables, 1)
+	require.NoError(t, s.Close())
+}
+
+func TestUpdateFlushError(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Opt{MemtableSize: 1})
+	require.NoError(t, err)
+
+	// a directory in place of the temporary table fails the flush
+	tmp := filepath.Join(dir, "."+fmt.Sprintf("%016d%s", 1, tableSuffix))
+	require.NoError(t, os.Mkdir(tmp, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "file"), nil, 0600))
+	put(t, s, "foo", "bar")
+	require.Empty(t, s.tables)
+	require.Equal(t, []byte("bar"), get(t, s, "foo"))
+
+	// the next update flushes both batches
+	require.NoError(t, os.RemoveAll(tmp))
+	put(t, s, "foo2", "bar2")
+	require.Len(t, s.tables, 1)
+	require.NoError(t, s.Close())
+
+	s, err = Open(dir, Opt{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "foo2"}, keys(t, s, ""))
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,59 @@
+package lsm
+
+import (
+	"sort"
+	"strings"
+)
+
+// memtable holds the recent writes in memory until they are flushed to a
+// table.
+type memtable struct {
+	entries []entry
+	size    int
+}
+
+func (m *memtable) find(key string) (int, bool) {
+	i := sort.Search(len(m.entries), func(i int) bool {
+		return m.entries[i].key >= key
+	})
+	return i, i < len(m.entries) && m.entries[i].key == key
+}
+
+func (m *memtable) get(key string) (entry, bool) {
+	i, ok := m.find(key)
+	if !ok {
+		return entry{}, false
+	}
+	return m.entries[i], true
+}
+
+func (m *memtable) set(e entry) {
+	i, ok := m.find(e.key)
+	if ok {
+		m.size += len(e.value) - len(m.entries[i].value)
+		m.entries[i] = e
+		return
+	}
+	m.size += len(e.key) + len(e.value)
+	m.entries = append(m.entries, entry{})
+	copy(m.entries[i+1:], m.entries[i:])
+	m.entries[i] = e
+}
+
+func (m *memtable) scan(prefix string, fn func(entry) error) error {
+	i, _ := m.find(prefix)
+	for ; i < len(m.entries); i++ {
+		if !strings.HasPrefix(m.entries[i].key, prefix) {
+			break
+		}
+		if err := fn(m.entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memtable) reset() {
+	m.entries = nil
+	m.size = 0
+}
@@ -0,0 +1,206 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var tableMagic = []byte("buildkit-lsm-table-v1\n")
+
+const (
+	kindPut    byte = 1
+	kindDelete byte = 2
+)
+
+// entry is a key with its value or a tombstone for a deleted key.
+type entry struct {
+	key     string
+	value   []byte
+	deleted bool
+}
+
+// table is an immutable sorted file of entries. The keys are kept in memory
+// and values are read from the file when needed.
+type table struct {
+	seq     uint64
+	path    string
+	f       *os.File
+	size    int64
+	keys    []string
+	offsets []int64 // offset of the value, -1 for tombstones
+	lens    []int
+}
+
+// writeTable writes the sorted entries to path.
+func writeTable(path string, entries []entry) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(f, crc))
+	if _, err := w.Write(tableMagic); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, e := range entries {
+		writeEntry(w, buf, e)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := binary.Write(f, binary.LittleEndian, crc.Sum32()); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+func writeEntry(w *bufio.Writer, buf []byte, e entry) {
+	n := binary.PutUvarint(buf, uint64(len(e.key)))
+	w.Write(buf[:n])
+	w.WriteString(e.key)
+	if e.deleted {
+		w.WriteByte(kindDelete)
+		return
+	}
+	w.WriteByte(kindPut)
+	n = binary.PutUvarint(buf, uint64(len(e.value)))
+	w.Write(buf[:n])
+	w.Write(e.value)
+}
+
+// openTable opens a table and loads its keys.
+func openTable(path string, seq uint64) (*table, error) {
+	dt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(dt) < len(tableMagic)+4 || !bytes.Equal(dt[:len(tableMagic)], tableMagic) {
+		return nil, errors.Errorf("invalid table %s", path)
+	}
+	body := dt[:len(dt)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(dt[len(dt)-4:]) {
+		return nil, errors.Errorf("checksum mismatch in table %s", path)
+	}
+	t := &table{seq: seq, path: path, size: int64(len(dt))}
+	off := len(tableMagic)
+	for off < len(body) {
+		e, valueOff, n, err := decodeEntry(body[off:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid table %s", path)
+		}
+		if len(t.keys) > 0 && t.keys[len(t.keys)-1] >= e.key {
+			return nil, errors.Errorf("unsorted keys in table %s", path)
+		}
+		t.keys = append(t.keys, e.key)
+		if e.deleted {
+			t.offsets = append(t.offsets, -1)
+			t.lens = append(t.lens, 0)
+		} else {
+			t.offsets = append(t.offsets, int64(off+valueOff))
+			t.lens = append(t.lens, len(e.value))
+		}
+		off += n
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t.f = f
+	return t, nil
+}
+
+// decodeEntry decodes an entry from dt and returns it with the offset of
+// its value and the encoded length.
+func decodeEntry(dt []byte) (entry, int, int, error) {
+	var e entry
+	kl, n := binary.Uvarint(dt)
+	if n <= 0 || uint64(len(dt)-n) < kl+1 {
+		return e, 0, 0, io.ErrUnexpectedEOF
+	}
+	off := n
+	e.key = string(dt[off : off+int(kl)])
+	off += int(kl)
+	kind := dt[off]
+	off++
+	switch kind {
+	case kindDelete:
+		e.deleted = true
+		return e, 0, off, nil
+	case kindPut:
+	default:
+		return e, 0, 0, errors.Errorf("invalid entry kind %d", kind)
+	}
+	vl, n := binary.Uvarint(dt[off:])
+	if n <= 0 || uint64(len(dt)-off-n) < vl {
+		return e, 0, 0, io.ErrUnexpectedEOF
+	}
+	off += n
+	e.value = dt[off : off+int(vl)]
+	return e, off, off + int(vl), nil
+}
+
+func (t *table) find(key string) int {
+	i := sort.SearchStrings(t.keys, key)
+	if i < len(t.keys) && t.keys[i] == key {
+		return i
+	}
+	return -1
+}
+
+func (t *table) entry(i int) (entry, error) {
+	e := entry{key: t.keys[i]}
+	if t.offsets[i] < 0 {
+		e.deleted = true
+		return e, nil
+	}
+	e.value = make([]byte, t.lens[i])
+	if _, err := t.f.ReadAt(e.value, t.offsets[i]); err != nil {
+		return e, errors.WithStack(err)
+	}
+	return e, nil
+}
+
+func (t *table) get(key string) (entry, bool, error) {
+	i := t.find(key)
+	if i < 0 {
+		return entry{}, false, nil
+	}
+	e, err := t.entry(i)
+	return e, true, err
+}
+
+// scan calls fn for the entries with prefix in ascending order.
+func (t *table) scan(prefix string, fn func(entry) error) error {
+	for i := sort.SearchStrings(t.keys, prefix); i < len(t.keys); i++ {
+		if len(t.keys[i]) < len(prefix) || t.keys[i][:len(prefix)] != prefix {
+			break
+		}
+		e, err := t.entry(i)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *table) close() error {
+	return t.f.Close()
+}
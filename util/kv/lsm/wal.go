@@ -0,0 +1,153 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/pkg/errors"
+)
+
+// wal is the write-ahead log of the batches that have not been flushed to a
+// table yet. Every record is a length prefixed batch followed by its
+// checksum.
+type wal struct {
+	f *os.File
+}
+
+func openWAL(path string, apply func(entry)) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	valid, err := replayWAL(f, apply)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// a torn write at the end of the log is discarded
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, errors.WithStack(err)
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, errors.WithStack(err)
+	}
+	return &wal{f: f}, nil
+}
+
+// replayWAL applies the complete records of the log and returns the length
+// of the valid part of the log.
+func replayWAL(f *os.File, apply func(entry)) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	r := bufio.NewReader(f)
+	var valid int64
+	for {
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return valid, nil
+		}
+		if l+4 > uint64(fi.Size()-valid) {
+			bklog.L.Warnf("discarding incomplete record at offset %d of %s", valid, f.Name())
+			return valid, nil
+		}
+		rec := make([]byte, l+4)
+		if _, err := io.ReadFull(r, rec); err != nil {
+			bklog.L.Warnf("discarding incomplete record at offset %d of %s", valid, f.Name())
+			return valid, nil
+		}
+		batch := rec[:l]
+		if crc32.ChecksumIEEE(batch) != binary.LittleEndian.Uint32(rec[l:]) {
+			bklog.L.Warnf("discarding corrupted record at offset %d of %s", valid, f.Name())
+			return valid, nil
+		}
+		var entries []entry
+		for off := 0; off < len(batch); {
+			e, _, n, err := decodeEntry(batch[off:])
+			if err != nil {
+				return 0, errors.Wrapf(err, "invalid record in %s", f.Name())
+			}
+			e.value = bytes.Clone(e.value)
+			entries = append(entries, e)
+			off += n
+		}
+		for _, e := range entries {
+			apply(e)
+		}
+		valid += int64(uvarintLen(l)) + int64(len(rec))
+	}
+}
+
+func (w *wal) append(entries []entry) error {
+	var body bytes.Buffer
+	bw := bufio.NewWriter(&body)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, e := range entries {
+		writeEntry(bw, buf, e)
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	batch := body.Bytes()
+	rec := binary.AppendUvarint(nil, uint64(len(batch)))
+	rec = append(rec, batch...)
+	rec = binary.LittleEndian.AppendUint32(rec, crc32.ChecksumIEEE(batch))
+	off, err := w.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := w.f.Write(rec); err != nil {
+		return w.discard(off, err)
+	}
+	// the batch is only committed once it is on disk
+	if err := w.f.Sync(); err != nil {
+		return w.discard(off, err)
+	}
+	return nil
+}
+
+// discard removes a record that failed to be written from the end of the
+// log so that the following records are not lost on replay.
+func (w *wal) discard(off int64, err error) error {
+	if err := w.f.Truncate(off); err != nil {
+		bklog.L.WithError(err).Warnf("failed to truncate %s", w.f.Name())
+	}
+	if _, err := w.f.Seek(off, io.SeekStart); err != nil {
+		bklog.L.WithError(err).Warnf("failed to seek %s", w.f.Name())
+	}
+	return errors.WithStack(err)
+}
+
+func (w *wal) reset() error {
+	if err := w.f.Truncate(0); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(w.f.Sync())
+}
+
+func (w *wal) size() (int64, error) {
+	fi, err := w.f.Stat()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return fi.Size(), nil
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
+
+func uvarintLen(v uint64) int {
+	return len(binary.AppendUvarint(nil, v))
+}
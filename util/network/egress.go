@@ -0,0 +1,147 @@
+package network
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EgressRule matches requests made through the proxy network. Empty fields
+// match any value.
+type EgressRule struct {
+	// Hosts are host names that may contain wildcards, e.g. "*.example.com".
+	Hosts []string
+	Ports []int
+	// PathPrefixes match the path of the request URL. A prefix only matches
+	// at path segment boundaries unless it ends with a slash.
+	PathPrefixes []string
+	Methods      []string
+}
+
+// EgressPolicy is an allow-list of requests. A nil or empty policy allows
+// all requests.
+type EgressPolicy struct {
+	Rules []EgressRule
+}
+
+// Validate returns an error if one of the rules can never match.
+func (p *EgressPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, r := range p.Rules {
+		for _, h := range r.Hosts {
+			if _, err := path.Match(strings.ToLower(h), ""); err != nil {
+				return errors.Wrapf(err, "invalid egress host pattern %q", h)
+			}
+		}
+		for _, port := range r.Ports {
+			if port <= 0 || port > 65535 {
+				return errors.Errorf("invalid egress port %d", port)
+			}
+		}
+		for _, p := range r.PathPrefixes {
+			if !strings.HasPrefix(p, "/") {
+				return errors.Errorf("invalid egress path prefix %q: must start with /", p)
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed returns true if the request matches one of the rules of the
+// policy.
+func (p *EgressPolicy) Allowed(method string, u *url.URL) bool {
+	if p == nil || len(p.Rules) == 0 {
+		return true
+	}
+	host, port := egressHostPort(u)
+	reqPath, ok := egressPath(u)
+	for _, r := range p.Rules {
+		if len(r.PathPrefixes) > 0 && !ok {
+			continue
+		}
+		if r.matches(method, host, port, reqPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r EgressRule) matches(method, host string, port int, p string) bool {
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	}) {
+		return false
+	}
+	if len(r.Ports) > 0 && !slices.Contains(r.Ports, port) {
+		return false
+	}
+	if len(r.Hosts) > 0 && !slices.ContainsFunc(r.Hosts, func(h string) bool {
+		return matchEgressHost(h, host)
+	}) {
+		return false
+	}
+	if len(r.PathPrefixes) > 0 && !slices.ContainsFunc(r.PathPrefixes, func(prefix string) bool {
+		return matchEgressPath(prefix, p)
+	}) {
+		return false
+	}
+	return true
+}
+
+func matchEgressHost(pattern, host string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	ok, err := path.Match(pattern, host)
+	return err == nil && ok
+}
+
+func matchEgressPath(prefix, p string) bool {
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	return strings.HasSuffix(prefix, "/") || len(p) == len(prefix) || p[len(prefix)] == '/'
+}
+
+// egressPath returns the decoded and cleaned path of u. A path that can't be
+// decoded or that still contains dot segments after cleaning is not valid,
+// as the server could resolve it outside of an allowed prefix.
+func egressPath(u *url.URL) (string, bool) {
+	p, err := url.PathUnescape(u.EscapedPath())
+	if err != nil {
+		return "", false
+	}
+	if p == "" {
+		return "/", true
+	}
+	cleaned := path.Clean(p)
+	if slices.ContainsFunc(strings.Split(cleaned, "/"), func(s string) bool {
+		return s == "." || s == ".."
+	}) {
+		return "", false
+	}
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned, true
+}
+
+func egressHostPort(u *url.URL) (string, int) {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if p := u.Port(); p != "" {
+		if port, err := strconv.Atoi(p); err == nil {
+			return host, port
+		}
+	}
+	switch u.Scheme {
+	case "http":
+		return host, 80
+	case "https":
+		return host, 443
+	}
+	return host, 0
+}
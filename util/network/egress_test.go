@@ -0,0 +1,67 @@
+package network
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEgressPolicyAllowed(t *testing.T) {
+	p := &EgressPolicy{
+		Rules: []EgressRule{
+			{
+				Hosts:        []string{"npm.example.com"},
+				Ports:        []int{443},
+				PathPrefixes: []string{"/repository/npm"},
+				Methods:      []string{"GET", "HEAD"},
+			},
+			{
+				Hosts: []string{"*.mirror.example.org"},
+			},
+		},
+	}
+	require.NoError(t, p.Validate())
+
+	for _, tc := range []struct {
+		method  string
+		url     string
+		allowed bool
+	}{
+		{"GET", "https://npm.example.com/repository/npm/left-pad", true},
+		{"head", "https://NPM.example.com./repository/npm", true},
+		{"GET", "https://npm.example.com:443/repository/npm/", true},
+		{"POST", "https://npm.example.com/repository/npm/left-pad", false},
+		{"GET", "http://npm.example.com/repository/npm/left-pad", false},
+		{"GET", "https://npm.example.com:8443/repository/npm/left-pad", false},
+		{"GET", "https://npm.example.com/repository/npm2/left-pad", false},
+		{"GET", "https://npm.example.com/", false},
+		{"GET", "https://registry.npmjs.org/left-pad", false},
+		{"POST", "http://a.mirror.example.org:8080/any", true},
+		{"GET", "http://a.b.mirror.example.org/any", true},
+		{"GET", "http://mirror.example.org/any", false},
+		{"GET", "https://npm.example.com/repository/npm/../secret", false},
+		{"GET", "https://npm.example.com/repository/npm/%2e%2e/secret", false},
+		{"GET", "https://npm.example.com/repository/npm/%2E%2E%2Fsecret", false},
+		{"GET", "https://npm.example.com/repository/npm/./left-pad", true},
+		{"GET", "https://npm.example.com/repository/npm/a/../left-pad", true},
+		{"GET", "https://npm.example.com/repository/%6epm/left-pad", true},
+		{"GET", "https://npm.example.com/repository/npm/a//b", true},
+		{"GET", "http://a.mirror.example.org/../any", true},
+	} {
+		u, err := url.Parse(tc.url)
+		require.NoError(t, err)
+		require.Equal(t, tc.allowed, p.Allowed(tc.method, u), "%s %s", tc.method, tc.url)
+	}
+
+	u, err := url.Parse("https://example.com/")
+	require.NoError(t, err)
+	require.True(t, (*EgressPolicy)(nil).Allowed("GET", u))
+	require.True(t, (&EgressPolicy{}).Allowed("GET", u))
+}
+
+func TestEgressPolicyValidate(t *testing.T) {
+	require.ErrorContains(t, (&EgressPolicy{Rules: []EgressRule{{Hosts: []string{"[a"}}}}).Validate(), "invalid egress host pattern")
+	require.ErrorContains(t, (&EgressPolicy{Rules: []EgressRule{{Ports: []int{70000}}}}).Validate(), "invalid egress port")
+	require.ErrorContains(t, (&EgressPolicy{Rules: []EgressRule{{PathPrefixes: []string{"npm"}}}}).Validate(), "must start with /")
+}
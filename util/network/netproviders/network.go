@@ -2,6 +2,7 @@ package netproviders
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/moby/buildkit/solver/pb"
@@ -14,6 +15,10 @@ import (
 type Opt struct {
 	CNI  cniprovider.Opt
 	Mode string
+	// Networks are additional CNI networks that exec steps can select by
+	// name. Only ConfigPath and PoolSize are used, the other fields are
+	// inherited from CNI.
+	Networks map[string]cniprovider.Opt
 }
 
 // Providers returns the network provider set.
@@ -91,3 +96,34 @@ func Providers(opt Opt) (providers map[pb.NetMode]network.Provider, proxyProvide
 
 	return providers, proxyProvider, resolvedMode, nil
 }
+
+// NamedProviders returns the providers of the named CNI networks in opt.Networks.
+func NamedProviders(opt Opt) (map[string]network.Provider, error) {
+	if len(opt.Networks) == 0 {
+		return nil, nil
+	}
+	providers := make(map[string]network.Provider, len(opt.Networks))
+	for name, n := range opt.Networks {
+		if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+			return nil, closeProviders(providers, errors.Errorf("invalid CNI network name %q", name))
+		}
+		p, err := cniprovider.New(cniprovider.Opt{
+			Root:       filepath.Join(opt.CNI.Root, "cni-networks", name),
+			ConfigPath: n.ConfigPath,
+			BinaryDir:  opt.CNI.BinaryDir,
+			PoolSize:   n.PoolSize,
+		})
+		if err != nil {
+			return nil, closeProviders(providers, errors.Wrapf(err, "failed to create CNI network %q", name))
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+func closeProviders(providers map[string]network.Provider, err error) error {
+	for _, p := range providers {
+		p.Close()
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package network
 import (
 	"context"
 	"io"
+	"net/http"
 	"slices"
 	"sync"
 
@@ -16,9 +17,46 @@ type ProxyPolicy interface {
 }
 
 type ProxyConfig struct {
-	Policy     ProxyPolicy
+	Policy ProxyPolicy
+	// Egress policies that all need to allow a request before it is
+	// forwarded.
+	Egress     []*EgressPolicy
 	Capture    *ProxyCapture
 	EgressMode pb.NetMode
+	// Store keeps the recorded response bodies.
+	Store ProxyMaterialStore
+	// Record stores the response bodies of successful GET requests in Store.
+	Record bool
+	// Replay serves requests only from Store without network access if it
+	// is not nil. It maps the URLs that can be requested to the digests of
+	// their recorded responses.
+	Replay map[string]digest.Digest
+}
+
+// ProxyMaterialStore keeps the response bodies of proxied requests so that
+// they can be replayed later.
+type ProxyMaterialStore interface {
+	// Writer returns a writer for a response body.
+	Writer(ctx context.Context) (ProxyMaterialWriter, error)
+	// Open returns the recorded response with the digest, its body and the
+	// size of the body. Only responses that were recorded for the owners of
+	// the store can be opened.
+	Open(ctx context.Context, dgst digest.Digest) (*ProxyResponse, io.ReadCloser, int64, error)
+}
+
+// ProxyResponse is the status and the headers of a recorded response.
+type ProxyResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+}
+
+// ProxyMaterialWriter writes a response body to a ProxyMaterialStore. Close
+// discards the body if it has not been committed.
+type ProxyMaterialWriter interface {
+	io.WriteCloser
+	// Commit stores the written body of the response for url together with
+	// its status and headers. dgst must match the written data.
+	Commit(ctx context.Context, url string, resp *ProxyResponse, dgst digest.Digest) error
 }
 
 type ProxyProvider interface {
@@ -52,11 +90,19 @@ type ProxyIncomplete struct {
 	Reason string
 }
 
+// ProxyDenied is a request that was rejected by the egress or source policy.
+type ProxyDenied struct {
+	Method string
+	URL    string
+	Reason string
+}
+
 type ProxyCapture struct {
 	mu         sync.Mutex
 	requests   []ProxyRequest
 	materials  []ProxyMaterial
 	incomplete []ProxyIncomplete
+	denied     []ProxyDenied
 }
 
 func NewProxyCapture() *ProxyCapture {
@@ -90,6 +136,15 @@ func (c *ProxyCapture) AddIncomplete(in ProxyIncomplete) {
 	c.incomplete = append(c.incomplete, in)
 }
 
+func (c *ProxyCapture) AddDenied(d ProxyDenied) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.denied = append(c.denied, d)
+}
+
 func (c *ProxyCapture) Materials() []ProxyMaterial {
 	if c == nil {
 		return nil
@@ -152,3 +207,12 @@ func (c *ProxyCapture) Incomplete() []ProxyIncomplete {
 	copy(out, c.incomplete)
 	return out
 }
+
+func (c *ProxyCapture) Denied() []ProxyDenied {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.denied)
+}
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -406,7 +407,11 @@ func (n *proxyNS) startProxy(ctx context.Context, proxy *network.ProxyConfig) er
 	handler := &proxyHandler{
 		provider:  n.provider,
 		policy:    proxy.Policy,
+		egress:    proxy.Egress,
 		capture:   proxy.Capture,
+		store:     proxy.Store,
+		record:    proxy.Record,
+		replay:    proxy.Replay,
 		transport: transport,
 	}
 	n.server = &http.Server{
@@ -502,7 +507,11 @@ func (n *proxyNS) deleteVeth() error {
 type proxyHandler struct {
 	provider  *provider
 	policy    network.ProxyPolicy
+	egress    []*network.EgressPolicy
 	capture   *network.ProxyCapture
+	store     network.ProxyMaterialStore
+	record    bool
+	replay    map[string]digest.Digest
 	transport *http.Transport
 }
 
@@ -516,7 +525,7 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.URL.Host = r.Host
 	}
 	if target, err := h.check(r.Context(), r.Method, r.URL.String()); err != nil {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
 		return
 	} else if target != nil {
 		r.URL = target
@@ -533,7 +542,7 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.recordRequest(r, resp.StatusCode, finalURL(r, resp))
 	copyHeader(w.Header(), resp.Header)
 	w.WriteHeader(resp.StatusCode)
-	tracker := newProxyBodyTracker(resp.Body)
+	tracker := h.newBodyTracker(r, resp)
 	_, copyErr := io.Copy(w, tracker)
 	h.recordResponse(r, resp, tracker, copyErr)
 }
@@ -576,7 +585,8 @@ func (h *proxyHandler) handleConnect(w http.ResponseWriter, r *http.Request) {
 		req.RequestURI = ""
 		if target, err := h.check(req.Context(), req.Method, req.URL.String()); err != nil {
 			_ = req.Body.Close()
-			_, _ = io.WriteString(tlsConn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 10\r\nConnection: close\r\n\r\nForbidden\n")
+			msg := "Forbidden: " + err.Error() + "\n"
+			_, _ = fmt.Fprintf(tlsConn, "HTTP/1.1 403 Forbidden\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(msg), msg)
 			return
 		} else if target != nil {
 			req.URL = target
@@ -592,7 +602,7 @@ func (h *proxyHandler) handleConnect(w http.ResponseWriter, r *http.Request) {
 		}
 		h.recordRequest(req, resp.StatusCode, finalURL(req, resp))
 		prepareMITMResponse(req, resp)
-		tracker := newProxyBodyTracker(resp.Body)
+		tracker := h.newBodyTracker(req, resp)
 		resp.Body = tracker
 		if err := resp.Write(tlsConn); err != nil {
 			h.recordResponse(req, resp, tracker, err)
@@ -628,6 +638,27 @@ type proxyBodyTracker struct {
 	body    io.ReadCloser
 	hash    hash.Hash
 	readErr error
+	// recorder receives a copy of the body in record mode.
+	recorder network.ProxyMaterialWriter
+}
+
+// newBodyTracker returns a tracker for the response body that also records
+// the body if the response can be replayed later.
+func (h *proxyHandler) newBodyTracker(req *http.Request, resp *http.Response) *proxyBodyTracker {
+	t := newProxyBodyTracker(resp.Body)
+	if !h.record || h.replay != nil || h.store == nil {
+		return t
+	}
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return t
+	}
+	w, err := h.store.Writer(context.WithoutCancel(req.Context()))
+	if err != nil {
+		h.recordIncomplete(req, "record_failed")
+		return t
+	}
+	t.recorder = w
+	return t
 }
 
 func newProxyBodyTracker(body io.ReadCloser) *proxyBodyTracker {
@@ -641,6 +672,12 @@ func (t *proxyBodyTracker) Read(p []byte) (int, error) {
 	n, err := t.body.Read(p)
 	if n > 0 {
 		_, _ = t.hash.Write(p[:n])
+		if t.recorder != nil {
+			if _, err := t.recorder.Write(p[:n]); err != nil {
+				_ = t.recorder.Close()
+				t.recorder = nil
+			}
+		}
 	}
 	if err != nil && !errors.Is(err, io.EOF) && t.readErr == nil {
 		t.readErr = err
@@ -657,10 +694,22 @@ func (t *proxyBodyTracker) Digest() digest.Digest {
 }
 
 func (h *proxyHandler) recordResponse(req *http.Request, resp *http.Response, tracker *proxyBodyTracker, copyErr error) {
+	reason := proxyIncompleteReason(req, resp, tracker, copyErr)
+	if w := tracker.recorder; w != nil {
+		defer w.Close()
+		if reason == "" {
+			recorded := &network.ProxyResponse{
+				StatusCode: resp.StatusCode,
+				Header:     recordedHeader(resp.Header),
+			}
+			if err := w.Commit(context.WithoutCancel(req.Context()), captureURL(req.URL.String()), recorded, tracker.Digest()); err != nil {
+				reason = "record_failed"
+			}
+		}
+	}
 	if h.capture == nil {
 		return
 	}
-	reason := proxyIncompleteReason(req, resp, tracker, copyErr)
 	if reason != "" {
 		h.recordIncomplete(req, reason)
 		return
@@ -755,6 +804,9 @@ func captureURL(s string) string {
 }
 
 func (h *proxyHandler) roundTrip(r *http.Request) (*http.Response, error) {
+	if h.replay != nil {
+		return h.replayResponse(r)
+	}
 	stripProxyHeaders(r.Header)
 	r.Header.Del("Accept-Encoding")
 	r.RequestURI = ""
@@ -762,6 +814,105 @@ func (h *proxyHandler) roundTrip(r *http.Request) (*http.Response, error) {
 }
 
 func (h *proxyHandler) check(ctx context.Context, method, rawURL string) (*neturl.URL, error) {
+	target, err := h.checkPolicy(ctx, method, rawURL)
+	if err == nil {
+		err = h.checkEgress(method, rawURL, target)
+	}
+	if err == nil {
+		err = h.checkReplay(method, rawURL, target)
+	}
+	if err != nil {
+		if h.capture != nil {
+			h.capture.AddDenied(network.ProxyDenied{
+				Method: method,
+				URL:    captureURL(rawURL),
+				Reason: err.Error(),
+			})
+		}
+		return nil, err
+	}
+	return target, nil
+}
+
+// checkReplay verifies that a recorded response is available for the request
+// in replay mode.
+func (h *proxyHandler) checkReplay(method, rawURL string, target *neturl.URL) error {
+	if h.replay == nil {
+		return nil
+	}
+	u := rawURL
+	if target != nil {
+		u = target.String()
+	}
+	if method != http.MethodGet && method != http.MethodHead {
+		return errors.Errorf("%s %s is not allowed in offline replay mode", method, redactURL(u))
+	}
+	if _, ok := h.replay[captureURL(u)]; !ok {
+		return errors.Errorf("%s %s has no recorded response for offline replay", method, redactURL(u))
+	}
+	if h.store == nil {
+		return errors.New("offline replay requires a store for recorded responses")
+	}
+	return nil
+}
+
+// replayResponse returns the recorded response for the request.
+func (h *proxyHandler) replayResponse(r *http.Request) (*http.Response, error) {
+	dgst := h.replay[captureURL(r.URL.String())]
+	recorded, body, size, err := h.store.Open(r.Context(), dgst)
+	if err != nil {
+		return nil, err
+	}
+	statusCode := http.StatusOK
+	header := http.Header{}
+	if recorded != nil {
+		if recorded.StatusCode != 0 {
+			statusCode = recorded.StatusCode
+		}
+		copyHeader(header, recorded.Header)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	if r.Method == http.MethodHead {
+		body.Close()
+		body = http.NoBody
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: size,
+		Request:       r,
+	}, nil
+}
+
+// checkEgress verifies that the request, after source policy conversions,
+// is allowed by all egress policies.
+func (h *proxyHandler) checkEgress(method, rawURL string, target *neturl.URL) error {
+	if len(h.egress) == 0 {
+		return nil
+	}
+	u := target
+	if u == nil {
+		var err error
+		if u, err = neturl.Parse(rawURL); err != nil {
+			return errors.Wrapf(err, "error parsing proxy request URL %q", redactURL(rawURL))
+		}
+	}
+	for _, p := range h.egress {
+		if !p.Allowed(method, u) {
+			return errors.Errorf("%s %s is not allowed by egress policy", method, redactURL(u.String()))
+		}
+	}
+	return nil
+}
+
+func (h *proxyHandler) checkPolicy(ctx context.Context, method, rawURL string) (*neturl.URL, error) {
 	if h.policy == nil {
 		return nil, nil
 	}
@@ -1011,6 +1162,17 @@ func stripProxyHeaders(h http.Header) {
 	}
 }
 
+// recordedHeader returns the headers of a response that are replayed with the
+// recorded body. Hop-by-hop headers, cookies and the length, which is set from
+// the recorded body, are dropped.
+func recordedHeader(h http.Header) http.Header {
+	h = h.Clone()
+	stripProxyHeaders(h)
+	h.Del("Content-Length")
+	h.Del("Set-Cookie")
+	return h
+}
+
 func copyHeader(dst, src http.Header) {
 	for k, vv := range src {
 		for _, v := range vv {
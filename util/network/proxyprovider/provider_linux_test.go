@@ -3,14 +3,17 @@
 package proxyprovider
 
 import (
+	"bytes"
 	"compress/gzip"
 	"container/list"
 	"context"
 	"crypto/x509"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +21,8 @@ import (
 	"github.com/moby/buildkit/sourcepolicy"
 	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/util/network"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -372,6 +377,123 @@ func TestProxyHandlerRejectsConvertedAttrs(t *testing.T) {
 	require.Contains(t, err.Error(), "proxy conversion only supports URL updates")
 }
 
+func TestProxyHandlerDeniesEgress(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("upstream should not receive denied request")
+	}))
+	t.Cleanup(upstream.Close)
+
+	capture := network.NewProxyCapture()
+	handler := newTestProxyHandler(t, capture)
+	handler.egress = []*network.EgressPolicy{{
+		Rules: []network.EgressRule{{Hosts: []string{"mirror.example.com"}}},
+	}}
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, upstream.URL+"/file", nil)
+
+	handler.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusForbidden, resp.Code)
+	require.Contains(t, resp.Body.String(), "not allowed by egress policy")
+	require.Empty(t, capture.Requests())
+	denied := capture.Denied()
+	require.Len(t, denied, 1)
+	require.Equal(t, http.MethodGet, denied[0].Method)
+	require.Equal(t, upstream.URL+"/file", denied[0].URL)
+	require.Contains(t, denied[0].Reason, "not allowed by egress policy")
+}
+
+func TestProxyHandlerChecksEgressAfterConvert(t *testing.T) {
+	handler := newTestProxyHandler(t, nil)
+	handler.policy = proxyPolicyFunc(func(_ context.Context, op *pb.Op) (bool, error) {
+		op.GetSource().Identifier = "https://mirror.example.com/file"
+		return true, nil
+	})
+	handler.egress = []*network.EgressPolicy{{
+		Rules: []network.EgressRule{{Hosts: []string{"mirror.example.com"}}},
+	}}
+
+	target, err := handler.check(t.Context(), http.MethodGet, "https://example.com/file")
+	require.NoError(t, err)
+	require.Equal(t, "https://mirror.example.com/file", target.String())
+
+	handler.egress = append(handler.egress, &network.EgressPolicy{
+		Rules: []network.EgressRule{{Hosts: []string{"example.com"}}},
+	})
+	_, err = handler.check(t.Context(), http.MethodGet, "https://example.com/file")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "https://mirror.example.com/file is not allowed by egress policy")
+}
+
+func TestProxyHandlerRecordsAndReplaysResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusNonAuthoritativeInfo)
+		_, _ = w.Write([]byte("proxy material"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	store := &testMaterialStore{blobs: map[digest.Digest][]byte{}}
+	capture := network.NewProxyCapture()
+	handler := newTestProxyHandler(t, capture)
+	handler.store = store
+	handler.record = true
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, upstream.URL+"/file", nil))
+	require.Equal(t, http.StatusNonAuthoritativeInfo, resp.Code)
+
+	materials := capture.Materials()
+	require.Len(t, materials, 1)
+	require.Equal(t, []byte("proxy material"), store.blobs[materials[0].Digest])
+	require.Equal(t, upstream.URL+"/file", store.urls[materials[0].Digest])
+	upstream.Close()
+
+	capture = network.NewProxyCapture()
+	handler = newTestProxyHandler(t, capture)
+	handler.store = store
+	handler.replay = map[string]digest.Digest{
+		upstream.URL + "/file": materials[0].Digest,
+	}
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, upstream.URL+"/file", nil))
+	require.Equal(t, http.StatusNonAuthoritativeInfo, resp.Code)
+	require.Equal(t, "proxy material", resp.Body.String())
+	require.Equal(t, "application/x-tar", resp.Header().Get("Content-Type"))
+	require.Equal(t, `"v1"`, resp.Header().Get("ETag"))
+	require.Equal(t, "14", resp.Header().Get("Content-Length"))
+	require.Empty(t, resp.Header().Get("Set-Cookie"))
+	require.Equal(t, materials, capture.Materials())
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, upstream.URL+"/other", nil))
+	require.Equal(t, http.StatusForbidden, resp.Code)
+	denied := capture.Denied()
+	require.Len(t, denied, 1)
+	require.Contains(t, denied[0].Reason, "no recorded response for offline replay")
+
+	_, err := handler.check(t.Context(), http.MethodPost, upstream.URL+"/file")
+	require.ErrorContains(t, err, "not allowed in offline replay mode")
+}
+
+func TestProxyHandlerDoesNotRecordIncompleteResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte("partial"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	store := &testMaterialStore{blobs: map[digest.Digest][]byte{}}
+	handler := newTestProxyHandler(t, network.NewProxyCapture())
+	handler.store = store
+	handler.record = true
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, upstream.URL+"/file", nil))
+	require.Equal(t, http.StatusPartialContent, resp.Code)
+	require.Empty(t, store.blobs)
+}
+
 func TestCertForHostUsesCachedValidCertificate(t *testing.T) {
 	p := newTestCertProvider(t)
 
@@ -401,6 +523,54 @@ func TestCertForHostRefreshesExpiredCertificate(t *testing.T) {
 	require.Len(t, p.certs, 1)
 }
 
+type testMaterialStore struct {
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	urls      map[digest.Digest]string
+	responses map[digest.Digest]*network.ProxyResponse
+}
+
+func (s *testMaterialStore) Writer(context.Context) (network.ProxyMaterialWriter, error) {
+	return &testMaterialWriter{s: s}, nil
+}
+
+func (s *testMaterialStore) Open(_ context.Context, dgst digest.Digest) (*network.ProxyResponse, io.ReadCloser, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dt, ok := s.blobs[dgst]
+	if !ok {
+		return nil, nil, 0, errors.Errorf("%s not found", dgst)
+	}
+	return s.responses[dgst], io.NopCloser(bytes.NewReader(dt)), int64(len(dt)), nil
+}
+
+type testMaterialWriter struct {
+	bytes.Buffer
+	s *testMaterialStore
+}
+
+func (w *testMaterialWriter) Commit(_ context.Context, url string, resp *network.ProxyResponse, dgst digest.Digest) error {
+	if digest.FromBytes(w.Bytes()) != dgst {
+		return errors.New("digest mismatch")
+	}
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.blobs[dgst] = w.Bytes()
+	if w.s.urls == nil {
+		w.s.urls = map[digest.Digest]string{}
+	}
+	w.s.urls[dgst] = url
+	if w.s.responses == nil {
+		w.s.responses = map[digest.Digest]*network.ProxyResponse{}
+	}
+	w.s.responses[dgst] = resp
+	return nil
+}
+
+func (w *testMaterialWriter) Close() error {
+	return nil
+}
+
 type proxyPolicyFunc func(context.Context, *pb.Op) (bool, error)
 
 func (f proxyPolicyFunc) Evaluate(ctx context.Context, op *pb.Op) (bool, error) {
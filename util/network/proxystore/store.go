@@ -0,0 +1,270 @@
+// Package proxystore keeps the response bodies recorded by the proxy network
+// in the content store of a worker.
+package proxystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/leases"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/util/leaseutil"
+	"github.com/moby/buildkit/util/network"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// LeasePrefix is the prefix of the leases that keep recorded response
+	// bodies from being garbage collected.
+	LeasePrefix = "buildkit-proxy-material-"
+
+	labelURL      = "buildkit/proxy-material.url"
+	labelOwner    = "buildkit/proxy-material.owner"
+	labelResponse = "buildkit/proxy-material.response"
+
+	mediaTypeResponse = "application/vnd.buildkit.proxy-material.response.v0+json"
+)
+
+// New returns a store that writes response bodies to cs. Every recorded body
+// is kept by a lease for each of the owners, usually the tenants of the
+// builds that share the exec. Open only returns bodies that were recorded for
+// all of the owners, so a build can't replay the content of another tenant.
+func New(cs content.Store, lm leases.Manager, owners []string) network.ProxyMaterialStore {
+	if len(owners) == 0 {
+		owners = []string{""}
+	}
+	return &store{cs: cs, lm: lm, owners: owners}
+}
+
+type store struct {
+	cs     content.Store
+	lm     leases.Manager
+	owners []string
+}
+
+// leaseID returns the ID of the lease that keeps the body with the digest
+// for owner.
+func leaseID(owner string, dgst digest.Digest) string {
+	return LeasePrefix + digest.FromString(owner).Encoded()[:16] + "-" + dgst.Encoded()
+}
+
+func (s *store) Writer(ctx context.Context) (network.ProxyMaterialWriter, error) {
+	ctx, done, err := leaseutil.WithLease(ctx, s.lm, leaseutil.MakeTemporary)
+	if err != nil {
+		return nil, err
+	}
+	w, err := content.OpenWriter(ctx, s.cs, content.WithRef("proxy-material-"+identity.NewID()))
+	if err != nil {
+		done(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return &writer{Writer: w, s: s, ctx: ctx, done: done}, nil
+}
+
+func (s *store) Open(ctx context.Context, dgst digest.Digest) (*network.ProxyResponse, io.ReadCloser, int64, error) {
+	var resp *network.ProxyResponse
+	for _, owner := range s.owners {
+		l, ok, err := s.lease(ctx, owner, dgst)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if !ok {
+			// don't reveal whether the content exists for other owners
+			return nil, nil, 0, errors.Errorf("recorded response %s is not available", dgst)
+		}
+		if resp == nil {
+			if resp, err = s.readResponse(ctx, l); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+	}
+	ra, err := s.cs.ReaderAt(ctx, ocispecs.Descriptor{Digest: dgst})
+	if err != nil {
+		return nil, nil, 0, errors.Wrapf(err, "recorded response %s is not available", dgst)
+	}
+	return resp, &readCloser{Reader: content.NewReader(ra), Closer: ra}, ra.Size(), nil
+}
+
+// lease returns the lease of owner that keeps the body with the digest.
+func (s *store) lease(ctx context.Context, owner string, dgst digest.Digest) (leases.Lease, bool, error) {
+	ls, err := s.lm.List(ctx, `id=="`+leaseID(owner, dgst)+`"`)
+	if err != nil {
+		return leases.Lease{}, false, err
+	}
+	if len(ls) == 0 || ls[0].Labels[labelOwner] != owner {
+		return leases.Lease{}, false, nil
+	}
+	resources, err := s.lm.ListResources(ctx, ls[0])
+	if err != nil {
+		return leases.Lease{}, false, err
+	}
+	for _, r := range resources {
+		if r.Type == "content" && r.ID == dgst.String() {
+			return ls[0], true, nil
+		}
+	}
+	return leases.Lease{}, false, nil
+}
+
+// readResponse reads the status and headers recorded with the lease. Nil is
+// returned for bodies recorded without them.
+func (s *store) readResponse(ctx context.Context, l leases.Lease) (*network.ProxyResponse, error) {
+	v, ok := l.Labels[labelResponse]
+	if !ok {
+		return nil, nil
+	}
+	dgst, err := digest.Parse(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid recorded response in lease %s", l.ID)
+	}
+	dt, err := content.ReadBlob(ctx, s.cs, ocispecs.Descriptor{Digest: dgst})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read recorded response %s", dgst)
+	}
+	var resp network.ProxyResponse
+	if err := json.Unmarshal(dt, &resp); err != nil {
+		return nil, errors.Wrapf(err, "invalid recorded response %s", dgst)
+	}
+	return &resp, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type writer struct {
+	content.Writer
+	s *store
+	// ctx holds the temporary lease of the written data.
+	ctx  context.Context
+	done func(context.Context) error
+}
+
+func (w *writer) Commit(ctx context.Context, url string, resp *network.ProxyResponse, dgst digest.Digest) error {
+	if err := w.Writer.Commit(w.ctx, 0, dgst); err != nil && !cerrdefs.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "failed to record response of %s", url)
+	}
+	dt, err := json.Marshal(resp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	respDesc := ocispecs.Descriptor{
+		MediaType: mediaTypeResponse,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(len(dt)),
+	}
+	if err := content.WriteBlob(w.ctx, w.s.cs, "proxy-material-response-"+respDesc.Digest.String(), bytes.NewReader(dt), respDesc); err != nil {
+		return errors.Wrapf(err, "failed to record response of %s", url)
+	}
+
+	for _, owner := range w.s.owners {
+		id := leaseID(owner, dgst)
+		// a new recording replaces the response recorded earlier
+		if err := w.s.lm.Delete(ctx, leases.Lease{ID: id}); err != nil && !cerrdefs.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to replace lease for recorded response of %s", url)
+		}
+		l, err := w.s.lm.Create(ctx, leases.WithID(id), leases.WithLabels(map[string]string{
+			labelURL:      url,
+			labelOwner:    owner,
+			labelResponse: respDesc.Digest.String(),
+		}))
+		if err != nil {
+			return errors.Wrapf(err, "failed to create lease for recorded response of %s", url)
+		}
+		for _, r := range []leases.Resource{
+			{ID: dgst.String(), Type: "content"},
+			{ID: respDesc.Digest.String(), Type: "content"},
+		} {
+			if err := w.s.lm.AddResource(ctx, l, r); err != nil {
+				return errors.Wrapf(err, "failed to add recorded response of %s to lease", url)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *writer) Close() error {
+	err := w.Writer.Close()
+	if err1 := w.done(context.WithoutCancel(w.ctx)); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Prune deletes the leases of recorded responses so that their content can
+// be garbage collected. Recorded responses are only deleted by prune options
+// that apply to all records: with a keep duration, the leases created before
+// it are deleted, and without one, all leases are deleted by options with
+// All set and no space limits. Options with filters don't apply because
+// recorded responses are not cache records.
+func Prune(ctx context.Context, cs content.Store, lm leases.Manager, ch chan client.UsageInfo, opts ...client.PruneInfo) error {
+	var cutoff time.Time
+	for _, opt := range opts {
+		if len(opt.Filter) > 0 {
+			continue
+		}
+		var t time.Time
+		switch {
+		case opt.KeepDuration > 0:
+			t = time.Now().Add(-opt.KeepDuration)
+		case opt.All && opt.ReservedSpace == 0 && opt.MaxUsedSpace == 0 && opt.MinFreeSpace == 0:
+			t = time.Now()
+		default:
+			continue
+		}
+		if t.After(cutoff) {
+			cutoff = t
+		}
+	}
+	if cutoff.IsZero() {
+		return nil
+	}
+
+	ls, err := lm.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		if !strings.HasPrefix(l.ID, LeasePrefix) || !l.CreatedAt.Before(cutoff) {
+			continue
+		}
+		var size int64
+		if ch != nil {
+			resources, err := lm.ListResources(ctx, l)
+			if err != nil {
+				return err
+			}
+			for _, r := range resources {
+				dgst, err := digest.Parse(r.ID)
+				if err != nil || r.Type != "content" {
+					continue
+				}
+				if info, err := cs.Info(ctx, dgst); err == nil {
+					size += info.Size
+				}
+			}
+		}
+		if err := lm.Delete(ctx, l); err != nil && !cerrdefs.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete lease %s", l.ID)
+		}
+		if ch != nil {
+			ch <- client.UsageInfo{
+				ID:          l.ID,
+				Size:        size,
+				CreatedAt:   l.CreatedAt,
+				Description: "proxy material " + l.Labels[labelURL],
+			}
+		}
+	}
+	return nil
+}
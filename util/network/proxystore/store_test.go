@@ -0,0 +1,109 @@
+package proxystore
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/leases"
+	ctdmetadata "github.com/containerd/containerd/v2/core/metadata"
+	"github.com/containerd/containerd/v2/core/snapshots"
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/containerd/v2/plugins/content/local"
+	"github.com/moby/buildkit/client"
+	containerdsnapshot "github.com/moby/buildkit/snapshot/containerd"
+	"github.com/moby/buildkit/util/leaseutil"
+	"github.com/moby/buildkit/util/network"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestStore(t *testing.T, owners ...string) (network.ProxyMaterialStore, *containerdsnapshot.Store, leases.Manager) {
+	tmpdir := t.TempDir()
+	store, err := local.NewStore(tmpdir)
+	require.NoError(t, err)
+	db, err := bolt.Open(filepath.Join(tmpdir, "containerdmeta.db"), 0644, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+	mdb := ctdmetadata.NewDB(db, store, map[string]snapshots.Snapshotter{})
+	cs := containerdsnapshot.NewContentStore(mdb.ContentStore(), "buildkit")
+	lm := leaseutil.WithNamespace(ctdmetadata.NewLeaseManager(mdb), "buildkit")
+	return New(cs, lm, owners), cs, lm
+}
+
+func record(t *testing.T, s network.ProxyMaterialStore, url string, dt []byte, resp *network.ProxyResponse) digest.Digest {
+	ctx := namespaces.WithNamespace(t.Context(), "buildkit")
+	w, err := s.Writer(ctx)
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.Write(dt)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(dt)
+	require.NoError(t, w.Commit(ctx, url, resp, dgst))
+	return dgst
+}
+
+func TestStoreReplaysOwnResponses(t *testing.T) {
+	ctx := namespaces.WithNamespace(t.Context(), "buildkit")
+	s, cs, lm := newTestStore(t, "tenant-a")
+
+	dgst := record(t, s, "https://example.com/file", []byte("material"), &network.ProxyResponse{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+	})
+
+	resp, rc, size, err := s.Open(ctx, dgst)
+	require.NoError(t, err)
+	defer rc.Close()
+	dt, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "material", string(dt))
+	require.Equal(t, int64(len(dt)), size)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+
+	// another tenant can't replay the response, even though the content exists
+	other := New(cs, lm, []string{"tenant-b"})
+	_, _, _, err = other.Open(ctx, dgst)
+	require.ErrorContains(t, err, "is not available")
+
+	// content that was not recorded by the proxy can't be replayed
+	_, _, _, err = s.Open(ctx, digest.FromString("other"))
+	require.ErrorContains(t, err, "is not available")
+
+	// a shared exec needs the response to be recorded for all owners
+	shared := New(cs, lm, []string{"tenant-a", "tenant-b"})
+	_, _, _, err = shared.Open(ctx, dgst)
+	require.ErrorContains(t, err, "is not available")
+}
+
+func TestPrune(t *testing.T) {
+	ctx := namespaces.WithNamespace(t.Context(), "buildkit")
+	s, cs, lm := newTestStore(t)
+	dgst := record(t, s, "https://example.com/file", []byte("material"), &network.ProxyResponse{StatusCode: http.StatusOK})
+
+	// size based and filtered prunes keep recorded responses
+	require.NoError(t, Prune(ctx, cs, lm, nil, client.PruneInfo{All: true, MaxUsedSpace: 1}, client.PruneInfo{All: true, Filter: []string{"type==regular"}}))
+	require.NoError(t, Prune(ctx, cs, lm, nil, client.PruneInfo{KeepDuration: time.Hour}))
+	_, rc, _, err := s.Open(ctx, dgst)
+	require.NoError(t, err)
+	rc.Close()
+
+	ch := make(chan client.UsageInfo, 10)
+	require.NoError(t, Prune(ctx, cs, lm, ch, client.PruneInfo{All: true}))
+	close(ch)
+	var pruned []client.UsageInfo
+	for info := range ch {
+		pruned = append(pruned, info)
+	}
+	require.Len(t, pruned, 1)
+	require.Equal(t, "proxy material https://example.com/file", pruned[0].Description)
+	require.Positive(t, pruned[0].Size)
+
+	_, _, _, err = s.Open(ctx, dgst)
+	require.ErrorContains(t, err, "is not available")
+}
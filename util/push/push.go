@@ -1,6 +1,7 @@
 package push
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -26,7 +27,9 @@ import (
 	resolverconfig "github.com/moby/buildkit/util/resolver/config"
 	"github.com/moby/buildkit/util/resolver/limited"
 	"github.com/moby/buildkit/util/resolver/retryhandler"
+	"github.com/moby/buildkit/util/sigstoresign"
 	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 )
@@ -46,7 +49,9 @@ func Pusher(ctx context.Context, resolver remotes.Resolver, ref string) (remotes
 	return &pusher{Pusher: p}, nil
 }
 
-func Push(ctx context.Context, sm *session.Manager, sid string, provider content.Provider, manager content.Manager, dgst digest.Digest, ref string, insecure bool, hosts docker.RegistryHosts, byDigest bool, annotations map[digest.Digest]map[string]string) error {
+// Push pushes the manifest or index dgst and its content to ref. The
+// referrers, e.g. signatures of dgst, are pushed before dgst is tagged.
+func Push(ctx context.Context, sm *session.Manager, sid string, provider content.Provider, manager content.Manager, dgst digest.Digest, ref string, insecure bool, hosts docker.RegistryHosts, byDigest bool, annotations map[digest.Digest]map[string]string, referrers ...ocispecs.Descriptor) error {
 	ctx = contentutil.RegisterContentPayloadTypes(ctx)
 	desc := ocispecs.Descriptor{
 		Digest: dgst,
@@ -134,12 +139,24 @@ func Push(ctx context.Context, sm *session.Manager, sid string, provider content
 		Size:      ra.Size(),
 		MediaType: mtype,
 	})
+	if err == nil && len(referrers) > 0 {
+		// the manifests of the referrers are added to the stack after the
+		// root manifest, so they are pushed before it
+		err = images.Dispatch(ctx, skipNonDistributableBlobs(images.Handlers(handlers...)), nil, referrers...)
+	}
 	if err := layersDone(err); err != nil {
 		return err
 	}
 
 	mfstDone := progress.OneOff(ctx, fmt.Sprintf("pushing manifest for %s", ref))
 	for _, desc := range slices.Backward(manifestStack) {
+		if desc.Digest == dgst && len(referrers) > 0 {
+			// the referrers manifests have been pushed, record them for
+			// registries without the referrers API before dgst is tagged
+			if err := updateReferrersTag(ctx, resolver, parsed, dgst, referrers); err != nil {
+				return mfstDone(err)
+			}
+		}
 		if _, err := pushHandler(ctx, desc); err != nil {
 			return mfstDone(err)
 		}
@@ -147,6 +164,82 @@ func Push(ctx context.Context, sm *session.Manager, sid string, provider content
 	return mfstDone(nil)
 }
 
+// updateReferrersTag adds the referrers of subject to the index tagged with the
+// referrers tag schema ("sha256-<hex>") in the repository of ref. A registry
+// that supports the OCI referrers API already lists the pushed referrers and
+// the tag is left alone. Concurrent updates of the same tag can race, as with
+// any other client following the fallback procedure of the distribution spec.
+func updateReferrersTag(ctx context.Context, resolver remotes.Resolver, ref reference.Named, subject digest.Digest, referrers []ocispecs.Descriptor) error {
+	tagged, err := reference.WithTag(reference.TrimNamed(ref), strings.Replace(subject.String(), ":", "-", 1))
+	if err != nil {
+		return errors.Wrapf(err, "invalid referrers tag for %s", subject)
+	}
+
+	f, err := resolver.Fetcher(ctx, tagged.String())
+	if err != nil {
+		return err
+	}
+	rf, ok := f.(remotes.ReferrersFetcher)
+	if !ok {
+		return nil
+	}
+	// the referrers API is tried first, the referrers tag is the fallback
+	existing, err := rf.FetchReferrers(ctx, subject)
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to fetch referrers of %s", subject)
+	}
+
+	manifests := existing
+	for _, desc := range referrers {
+		if slices.ContainsFunc(existing, func(d ocispecs.Descriptor) bool { return d.Digest == desc.Digest }) {
+			continue
+		}
+		manifests = append(manifests, ocispecs.Descriptor{
+			MediaType:    desc.MediaType,
+			ArtifactType: desc.ArtifactType,
+			Digest:       desc.Digest,
+			Size:         desc.Size,
+			Annotations:  desc.Annotations,
+		})
+	}
+	if len(manifests) == len(existing) {
+		return nil
+	}
+
+	dt, err := json.Marshal(ocispecs.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: ocispecs.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+	if err != nil {
+		return err
+	}
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(len(dt)),
+	}
+
+	p, err := Pusher(ctx, resolver, tagged.String())
+	if err != nil {
+		return err
+	}
+	w, err := p.Push(ctx, desc)
+	if err != nil {
+		if cerrdefs.IsAlreadyExists(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to push referrers tag %s", tagged)
+	}
+	defer w.Close()
+	if err := content.Copy(ctx, w, bytes.NewReader(dt), desc.Size, desc.Digest); err != nil {
+		return errors.Wrapf(err, "failed to push referrers tag %s", tagged)
+	}
+	return nil
+}
+
 // TODO: the containerd function for this is filtering too much, that needs to be fixed.
 // For now we just carry this.
 func skipNonDistributableBlobs(f images.HandlerFunc) images.HandlerFunc {
@@ -252,6 +345,7 @@ func childrenHandler(provider content.Provider) images.HandlerFunc {
 		case images.MediaTypeDockerSchema2Layer, images.MediaTypeDockerSchema2LayerGzip,
 			images.MediaTypeDockerSchema2Config, ocispecs.MediaTypeImageConfig,
 			ocispecs.MediaTypeImageLayer, ocispecs.MediaTypeImageLayerGzip,
+			ocispecs.MediaTypeEmptyJSON, sigstoresign.BundleMediaType,
 			intoto.PayloadType:
 			// childless data types.
 			return nil, nil
@@ -0,0 +1,126 @@
+package push
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// referrersRegistry serves the manifest endpoints needed by the referrers tag
+// schema and optionally the referrers API.
+type referrersRegistry struct {
+	mu        sync.Mutex
+	api       []ocispecs.Descriptor
+	manifests map[string][]byte
+	puts      int
+}
+
+func (r *referrersRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case req.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasPrefix(req.URL.Path, "/v2/test/referrers/"):
+		if r.api == nil {
+			http.NotFound(w, req)
+			return
+		}
+		dt, _ := json.Marshal(ocispecs.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispecs.MediaTypeImageIndex,
+			Manifests: r.api,
+		})
+		w.Header().Set("Content-Type", ocispecs.MediaTypeImageIndex)
+		w.Write(dt)
+	case strings.HasPrefix(req.URL.Path, "/v2/test/manifests/"):
+		tag := strings.TrimPrefix(req.URL.Path, "/v2/test/manifests/")
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+			dt, ok := r.manifests[tag]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			w.Header().Set("Content-Type", ocispecs.MediaTypeImageIndex)
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(dt).String())
+			if req.Method == http.MethodGet {
+				w.Write(dt)
+			}
+		case http.MethodPut:
+			dt, _ := io.ReadAll(req.Body)
+			r.manifests[tag] = dt
+			r.puts++
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(dt).String())
+			w.WriteHeader(http.StatusCreated)
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestUpdateReferrersTag(t *testing.T) {
+	subject := digest.FromString("image")
+	tag := strings.Replace(subject.String(), ":", "-", 1)
+	sig := ocispecs.Descriptor{
+		MediaType:    ocispecs.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.dev.sigstore.bundle.v0.3+json",
+		Digest:       digest.FromString("signature"),
+		Size:         100,
+	}
+	other := ocispecs.Descriptor{
+		MediaType:    ocispecs.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example+json",
+		Digest:       digest.FromString("other"),
+		Size:         200,
+	}
+
+	reg := &referrersRegistry{manifests: map[string][]byte{}}
+	srv := httptest.NewServer(reg)
+	t.Cleanup(srv.Close)
+
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Hosts: docker.ConfigureDefaultRegistries(docker.WithPlainHTTP(docker.MatchAllHosts)),
+	})
+	ref, err := reference.ParseNormalizedNamed(strings.TrimPrefix(srv.URL, "http://") + "/test:latest")
+	require.NoError(t, err)
+
+	tagged := func() []ocispecs.Descriptor {
+		t.Helper()
+		var idx ocispecs.Index
+		require.NoError(t, json.Unmarshal(reg.manifests[tag], &idx))
+		require.Equal(t, ocispecs.MediaTypeImageIndex, idx.MediaType)
+		return idx.Manifests
+	}
+
+	// no referrers API, the tag is created
+	require.NoError(t, updateReferrersTag(t.Context(), resolver, ref, subject, []ocispecs.Descriptor{sig}))
+	require.Equal(t, []ocispecs.Descriptor{sig}, tagged())
+
+	// existing entries are kept and referrers are not added twice
+	require.NoError(t, updateReferrersTag(t.Context(), resolver, ref, subject, []ocispecs.Descriptor{other, sig}))
+	require.Equal(t, []ocispecs.Descriptor{sig, other}, tagged())
+	require.Equal(t, 2, reg.puts)
+
+	require.NoError(t, updateReferrersTag(t.Context(), resolver, ref, subject, []ocispecs.Descriptor{sig}))
+	require.Equal(t, 2, reg.puts)
+
+	// the referrers API lists the referrers, the tag is left alone
+	delete(reg.manifests, tag)
+	reg.api = []ocispecs.Descriptor{sig}
+	require.NoError(t, updateReferrersTag(t.Context(), resolver, ref, subject, []ocispecs.Descriptor{sig}))
+	require.NotContains(t, reg.manifests, tag)
+	require.Equal(t, 2, reg.puts)
+}
@@ -0,0 +1,145 @@
+package sigstoresign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+type fulcioRequest struct {
+	Credentials struct {
+		OIDCIdentityToken string `json:"oidcIdentityToken"`
+	} `json:"credentials"`
+	PublicKeyRequest struct {
+		PublicKey struct {
+			Algorithm string `json:"algorithm"`
+			Content   string `json:"content"`
+		} `json:"publicKey"`
+		ProofOfPossession []byte `json:"proofOfPossession"`
+	} `json:"publicKeyRequest"`
+}
+
+type fulcioResponse struct {
+	SignedCertificateEmbeddedSct *fulcioChain `json:"signedCertificateEmbeddedSct,omitempty"`
+	SignedCertificateDetachedSct *fulcioChain `json:"signedCertificateDetachedSct,omitempty"`
+}
+
+type fulcioChain struct {
+	Chain struct {
+		Certificates []string `json:"certificates"`
+	} `json:"chain"`
+}
+
+// requestCertificate exchanges the identity token for a certificate of the
+// public key of key that is issued by the Fulcio instance at url.
+func requestCertificate(ctx context.Context, client *http.Client, url, token string, key crypto.Signer) (*x509.Certificate, error) {
+	if token == "" {
+		return nil, errors.New("keyless signing requires an identity token")
+	}
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	// Fulcio requires a signature of the token subject as proof that the
+	// requester has the private key.
+	sum := sha256.Sum256([]byte(subject))
+	proof, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign token subject")
+	}
+
+	var fr fulcioRequest
+	fr.Credentials.OIDCIdentityToken = token
+	fr.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	fr.PublicKeyRequest.PublicKey.Content = string(pub)
+	fr.PublicKeyRequest.ProofOfPossession = proof
+	dt, err := json.Marshal(fr)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp fulcioResponse
+	if err := postJSON(ctx, client, strings.TrimSuffix(url, "/")+"/api/v2/signingCert", dt, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to request signing certificate")
+	}
+	chain := resp.SignedCertificateEmbeddedSct
+	if chain == nil {
+		chain = resp.SignedCertificateDetachedSct
+	}
+	if chain == nil || len(chain.Chain.Certificates) == 0 {
+		return nil, errors.New("no certificate in Fulcio response")
+	}
+	certs, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(chain.Chain.Certificates[0]))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse signing certificate")
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate in Fulcio response")
+	}
+	if err := cryptoutils.EqualKeys(certs[0].PublicKey, key.Public()); err != nil {
+		return nil, errors.Wrap(err, "signing certificate does not match the signing key")
+	}
+	return certs[0], nil
+}
+
+// tokenSubject returns the identity of the OIDC token that is certified by
+// Fulcio: the email address if the token has one, or the subject otherwise.
+// The token is not verified, Fulcio verifies it.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("identity token is not a JWT")
+	}
+	dt, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decode identity token")
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(dt, &claims); err != nil {
+		return "", errors.Wrap(err, "failed to decode identity token")
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", errors.New("identity token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		dt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("unexpected status from %s: %s: %s", url, resp.Status, strings.TrimSpace(string(dt)))
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(v), "failed to decode response from %s", url)
+}
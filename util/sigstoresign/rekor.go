@@ -0,0 +1,119 @@
+package sigstoresign
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/rekor/pkg/generated/models"
+)
+
+// uploadEntry records the signature of the SHA-256 digest sum as a
+// hashedrekord entry in the transparency log of the Rekor instance at url.
+func uploadEntry(ctx context.Context, client *http.Client, url string, sum, sig, pubPEM []byte) (*protorekor.TransparencyLogEntry, error) {
+	apiVersion := "0.0.1"
+	algorithm := models.HashedrekordV001SchemaDataHashAlgorithmSha256
+	value := hex.EncodeToString(sum)
+	entry := &models.Hashedrekord{
+		APIVersion: &apiVersion,
+		Spec: &models.HashedrekordV001Schema{
+			Data: &models.HashedrekordV001SchemaData{
+				Hash: &models.HashedrekordV001SchemaDataHash{
+					Algorithm: &algorithm,
+					Value:     &value,
+				},
+			},
+			Signature: &models.HashedrekordV001SchemaSignature{
+				Content: sig,
+				PublicKey: &models.HashedrekordV001SchemaSignaturePublicKey{
+					Content: pubPEM,
+				},
+			},
+		},
+	}
+	dt, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp models.LogEntry
+	if err := postJSON(ctx, client, strings.TrimSuffix(url, "/")+"/api/v1/log/entries", dt, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to upload transparency log entry")
+	}
+	for _, e := range resp {
+		return transparencyLogEntry(e)
+	}
+	return nil, errors.New("no entry in Rekor response")
+}
+
+// transparencyLogEntry converts a Rekor log entry to its representation in
+// a Sigstore bundle.
+func transparencyLogEntry(e models.LogEntryAnon) (*protorekor.TransparencyLogEntry, error) {
+	if e.LogIndex == nil || e.LogID == nil || e.IntegratedTime == nil {
+		return nil, errors.New("incomplete transparency log entry")
+	}
+	if e.Verification == nil || e.Verification.InclusionProof == nil {
+		return nil, errors.New("transparency log entry has no inclusion proof")
+	}
+	body, ok := e.Body.(string)
+	if !ok {
+		return nil, errors.New("invalid transparency log entry body")
+	}
+	canonicalized, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid transparency log entry body")
+	}
+	logID, err := hex.DecodeString(*e.LogID)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid transparency log ID")
+	}
+
+	p := e.Verification.InclusionProof
+	if p.LogIndex == nil || p.RootHash == nil || p.TreeSize == nil || p.Checkpoint == nil {
+		return nil, errors.New("incomplete inclusion proof")
+	}
+	rootHash, err := hex.DecodeString(*p.RootHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid inclusion proof root hash")
+	}
+	hashes := make([][]byte, len(p.Hashes))
+	for i, h := range p.Hashes {
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			return nil, errors.Wrap(err, "invalid inclusion proof hash")
+		}
+	}
+
+	entry := &protorekor.TransparencyLogEntry{
+		LogIndex: *e.LogIndex,
+		LogId: &protocommon.LogId{
+			KeyId: logID,
+		},
+		KindVersion: &protorekor.KindVersion{
+			Kind:    "hashedrekord",
+			Version: "0.0.1",
+		},
+		IntegratedTime: *e.IntegratedTime,
+		InclusionProof: &protorekor.InclusionProof{
+			LogIndex: *p.LogIndex,
+			RootHash: rootHash,
+			TreeSize: *p.TreeSize,
+			Hashes:   hashes,
+			Checkpoint: &protorekor.Checkpoint{
+				Envelope: *p.Checkpoint,
+			},
+		},
+		CanonicalizedBody: canonicalized,
+	}
+	if len(e.Verification.SignedEntryTimestamp) > 0 {
+		entry.InclusionPromise = &protorekor.InclusionPromise{
+			SignedEntryTimestamp: e.Verification.SignedEntryTimestamp,
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,135 @@
+package sigstoresign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/pkg/errors"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// BundleMediaType is the media type of the Sigstore bundles created by Sign.
+const BundleMediaType = "application/vnd.dev.sigstore.bundle.v0.3+json"
+
+// Opt defines how a payload is signed.
+type Opt struct {
+	// Key is the private key that signs the payload. If nil, the payload is
+	// signed with an ephemeral key that is certified by Fulcio.
+	Key crypto.Signer
+	// FulcioURL is the URL of the Fulcio instance that issues the signing
+	// certificate when no Key is set.
+	FulcioURL string
+	// IdentityToken is the OIDC identity token exchanged for the signing
+	// certificate.
+	IdentityToken string
+	// RekorURL is the URL of the Rekor instance that records the signature
+	// in its transparency log. Required when no Key is set.
+	RekorURL string
+	// Client is the HTTP client used for requests to Fulcio and Rekor.
+	Client *http.Client
+}
+
+// ParsePrivateKey parses a PEM encoded ECDSA or RSA private key.
+func ParsePrivateKey(dt []byte) (crypto.Signer, error) {
+	key, err := cryptoutils.UnmarshalPEMToPrivateKey(dt, cryptoutils.SkipPassword)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse private key")
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// Sign signs the SHA-256 digest of payload and returns the signature and its
+// verification material as a Sigstore bundle.
+func Sign(ctx context.Context, payload []byte, opt Opt) (*protobundle.Bundle, error) {
+	client := opt.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	key := opt.Key
+	vm := &protobundle.VerificationMaterial{}
+	if key != nil {
+		der, err := cryptoutils.MarshalPublicKeyToDER(key.Public())
+		if err != nil {
+			return nil, err
+		}
+		hint := sha256.Sum256(der)
+		vm.Content = &protobundle.VerificationMaterial_PublicKey{
+			PublicKey: &protocommon.PublicKeyIdentifier{
+				Hint: base64.StdEncoding.EncodeToString(hint[:]),
+			},
+		}
+	} else {
+		if opt.FulcioURL == "" {
+			return nil, errors.New("signing requires a private key or a Fulcio URL")
+		}
+		if opt.RekorURL == "" {
+			return nil, errors.New("keyless signing requires a Rekor URL")
+		}
+		ephemeral, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate signing key")
+		}
+		key = ephemeral
+		cert, err := requestCertificate(ctx, client, opt.FulcioURL, opt.IdentityToken, key)
+		if err != nil {
+			return nil, err
+		}
+		vm.Content = &protobundle.VerificationMaterial_Certificate{
+			Certificate: &protocommon.X509Certificate{
+				RawBytes: cert.Raw,
+			},
+		}
+	}
+
+	sum := sha256.Sum256(payload)
+	sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign payload")
+	}
+
+	if opt.RekorURL != "" {
+		var pubPEM []byte
+		if cert := vm.GetCertificate(); cert != nil {
+			pubPEM = cryptoutils.PEMEncode(cryptoutils.CertificatePEMType, cert.RawBytes)
+		} else if pubPEM, err = cryptoutils.MarshalPublicKeyToPEM(key.Public()); err != nil {
+			return nil, err
+		}
+		entry, err := uploadEntry(ctx, client, opt.RekorURL, sum[:], sig, pubPEM)
+		if err != nil {
+			return nil, err
+		}
+		vm.TlogEntries = []*protorekor.TransparencyLogEntry{entry}
+	}
+
+	return &protobundle.Bundle{
+		MediaType:            BundleMediaType,
+		VerificationMaterial: vm,
+		Content: &protobundle.Bundle_MessageSignature{
+			MessageSignature: &protocommon.MessageSignature{
+				MessageDigest: &protocommon.HashOutput{
+					Algorithm: protocommon.HashAlgorithm_SHA2_256,
+					Digest:    sum[:],
+				},
+				Signature: sig,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,187 @@
+package sigstoresign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignKey(t *testing.T) {
+	t.Parallel()
+
+	privPEM, _, err := cryptoutils.GeneratePEMEncodedECDSAKeyPair(elliptic.P256(), cryptoutils.SkipPassword)
+	require.NoError(t, err)
+	key, err := ParsePrivateKey(privPEM)
+	require.NoError(t, err)
+
+	payload := []byte(`{"schemaVersion":2}`)
+	pb, err := Sign(t.Context(), payload, Opt{Key: key})
+	require.NoError(t, err)
+
+	b, err := bundle.NewBundle(pb)
+	require.NoError(t, err)
+	require.Equal(t, BundleMediaType, b.MediaType)
+
+	der, err := cryptoutils.MarshalPublicKeyToDER(key.Public())
+	require.NoError(t, err)
+	hint := sha256.Sum256(der)
+	require.Equal(t, base64.StdEncoding.EncodeToString(hint[:]), b.VerificationMaterial.GetPublicKey().GetHint())
+	require.Empty(t, b.VerificationMaterial.TlogEntries)
+
+	sum := sha256.Sum256(payload)
+	ms := b.GetMessageSignature()
+	require.Equal(t, sum[:], ms.MessageDigest.Digest)
+	require.True(t, ecdsa.VerifyASN1(key.Public().(*ecdsa.PublicKey), sum[:], ms.Signature))
+
+	_, err = Sign(t.Context(), payload, Opt{})
+	require.ErrorContains(t, err, "requires a private key or a Fulcio URL")
+}
+
+func TestSignKeyless(t *testing.T) {
+	t.Parallel()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234","email":"user@example.com"}`)) + ".sig"
+
+	var logged models.Hashedrekord
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v2/signingCert", func(w http.ResponseWriter, r *http.Request) {
+		var req fulcioRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Credentials.OIDCIdentityToken != token {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		pub, err := cryptoutils.UnmarshalPEMToPublicKey([]byte(req.PublicKeyRequest.PublicKey.Content))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte("user@example.com"))
+		if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), sum[:], req.PublicKeyRequest.ProofOfPossession) {
+			http.Error(w, "invalid proof of possession", http.StatusBadRequest)
+			return
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber:   big.NewInt(2),
+			NotBefore:      time.Now().Add(-time.Minute),
+			NotAfter:       time.Now().Add(10 * time.Minute),
+			KeyUsage:       x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			EmailAddresses: []string{"user@example.com"},
+		}, ca, pub, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var resp fulcioResponse
+		resp.SignedCertificateEmbeddedSct = &fulcioChain{}
+		resp.SignedCertificateEmbeddedSct.Chain.Certificates = []string{
+			string(cryptoutils.PEMEncode(cryptoutils.CertificatePEMType, der)),
+			string(cryptoutils.PEMEncode(cryptoutils.CertificatePEMType, caDER)),
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("POST /api/v1/log/entries", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&logged); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := json.Marshal(logged)
+		logID := hex.EncodeToString([]byte("test-log"))
+		rootHash := hex.EncodeToString(make([]byte, 32))
+		checkpoint := "test-log\n1\nroot\n"
+		var index, size, integrated int64 = 0, 1, time.Now().Unix()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.LogEntry{
+			"uuid": models.LogEntryAnon{
+				Body:           base64.StdEncoding.EncodeToString(body),
+				IntegratedTime: &integrated,
+				LogID:          &logID,
+				LogIndex:       &index,
+				Verification: &models.LogEntryAnonVerification{
+					InclusionProof: &models.InclusionProof{
+						Checkpoint: &checkpoint,
+						Hashes:     []string{},
+						LogIndex:   &index,
+						RootHash:   &rootHash,
+						TreeSize:   &size,
+					},
+					SignedEntryTimestamp: []byte("set"),
+				},
+			},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	payload := []byte(`{"schemaVersion":2}`)
+	_, err = Sign(t.Context(), payload, Opt{FulcioURL: srv.URL, IdentityToken: token})
+	require.ErrorContains(t, err, "requires a Rekor URL")
+
+	pb, err := Sign(t.Context(), payload, Opt{
+		FulcioURL:     srv.URL,
+		RekorURL:      srv.URL + "/",
+		IdentityToken: token,
+	})
+	require.NoError(t, err)
+	b, err := bundle.NewBundle(pb)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(b.VerificationMaterial.GetCertificate().GetRawBytes())
+	require.NoError(t, err)
+	require.Equal(t, []string{"user@example.com"}, cert.EmailAddresses)
+
+	sum := sha256.Sum256(payload)
+	ms := b.GetMessageSignature()
+	require.True(t, ecdsa.VerifyASN1(cert.PublicKey.(*ecdsa.PublicKey), sum[:], ms.Signature))
+
+	require.Len(t, b.VerificationMaterial.TlogEntries, 1)
+	entry := b.VerificationMaterial.TlogEntries[0]
+	require.Equal(t, []byte("test-log"), entry.LogId.KeyId)
+	require.Equal(t, "hashedrekord", entry.KindVersion.Kind)
+	require.Equal(t, []byte("set"), entry.InclusionPromise.SignedEntryTimestamp)
+	require.Equal(t, "test-log\n1\nroot\n", entry.InclusionProof.Checkpoint.Envelope)
+
+	spec, err := json.Marshal(logged.Spec)
+	require.NoError(t, err)
+	var logSpec models.HashedrekordV001Schema
+	require.NoError(t, json.Unmarshal(spec, &logSpec))
+	require.Equal(t, hex.EncodeToString(sum[:]), *logSpec.Data.Hash.Value)
+	require.Equal(t, []byte(ms.Signature), []byte(logSpec.Signature.Content))
+	require.Equal(t, cryptoutils.PEMEncode(cryptoutils.CertificatePEMType, cert.Raw), []byte(logSpec.Signature.PublicKey.Content))
+
+	_, err = Sign(t.Context(), payload, Opt{FulcioURL: srv.URL, RekorURL: srv.URL, IdentityToken: "invalid"})
+	require.ErrorContains(t, err, "not a JWT")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	stderrors "errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"slices"
@@ -21,10 +22,12 @@ import (
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb/sourceresolver"
 	"github.com/moby/buildkit/executor"
+	"github.com/moby/buildkit/executor/diskquota"
 	"github.com/moby/buildkit/executor/resources"
 	resourcestypes "github.com/moby/buildkit/executor/resources/types"
 	"github.com/moby/buildkit/exporter"
 	imageexporter "github.com/moby/buildkit/exporter/containerimage"
+	fsimageexporter "github.com/moby/buildkit/exporter/fsimage"
 	localexporter "github.com/moby/buildkit/exporter/local"
 	ociexporter "github.com/moby/buildkit/exporter/oci"
 	tarexporter "github.com/moby/buildkit/exporter/tar"
@@ -39,6 +42,7 @@ import (
 	"github.com/moby/buildkit/solver/llbsolver/linuxresources"
 	"github.com/moby/buildkit/solver/llbsolver/mounts"
 	"github.com/moby/buildkit/solver/llbsolver/ops"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/source"
 	"github.com/moby/buildkit/source/containerblob"
@@ -48,8 +52,10 @@ import (
 	"github.com/moby/buildkit/source/local"
 	"github.com/moby/buildkit/util/archutil"
 	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/leaseutil"
 	"github.com/moby/buildkit/util/network"
+	"github.com/moby/buildkit/util/network/proxystore"
 	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/util/progress/controller"
 	"github.com/moby/buildkit/worker"
@@ -76,21 +82,30 @@ type WorkerOpt struct {
 	BuildkitVersion  client.BuildkitVersion
 	NetworkProviders map[pb.NetMode]network.Provider
 	ProxyProvider    network.ProxyProvider
-	Executor         executor.Executor
-	Snapshotter      snapshot.Snapshotter
-	ContentStore     *containerdsnapshot.Store
-	Applier          diff.Applier
-	Differ           diff.Comparer
-	ImageStore       images.Store // optional
-	RegistryHosts    docker.RegistryHosts
-	IdentityMapping  *user.IdentityMapping
-	LeaseManager     *leaseutil.Manager
-	GarbageCollect   func(context.Context) (gc.Stats, error)
-	ParallelismSem   *semaphore.Weighted
+	// NamedNetworkProviders are the CNI networks that exec steps can select
+	// by name.
+	NamedNetworkProviders map[string]network.Provider
+	Executor              executor.Executor
+	Snapshotter           snapshot.Snapshotter
+	ContentStore          *containerdsnapshot.Store
+	Applier               diff.Applier
+	Differ                diff.Comparer
+	ImageStore            images.Store // optional
+	RegistryHosts         docker.RegistryHosts
+	IdentityMapping       *user.IdentityMapping
+	LeaseManager          *leaseutil.Manager
+	GarbageCollect        func(context.Context) (gc.Stats, error)
+	ParallelismSem        *semaphore.Weighted
+	// Scheduler limits the parallelism like ParallelismSem, sharing the
+	// slots between builds by priority class and tenant. It takes
+	// precedence over ParallelismSem.
+	Scheduler        *fairshare.Scheduler
 	MetadataStore    *metadata.Store
 	MountPoolRoot    string
 	ResourceMonitor  *resources.Monitor
 	CDIManager       *cdidevices.Manager
+	SecurityProfiles *securityprofiles.Manager
+	DiskQuota        *diskquota.Controller
 }
 
 // Worker is a local worker instance with dedicated snapshotter, cache, and so on.
@@ -109,6 +124,10 @@ type Worker struct {
 
 // NewWorker instantiates a local worker
 func NewWorker(ctx context.Context, opt WorkerOpt) (*Worker, error) {
+	if opt.Scheduler == nil && opt.ParallelismSem != nil {
+		opt.Scheduler = fairshare.FromSemaphore(opt.ParallelismSem)
+	}
+
 	imageRefChecker := imagerefchecker.New(imagerefchecker.Opt{
 		ImageStore:   opt.ImageStore,
 		ContentStore: opt.ContentStore,
@@ -130,6 +149,13 @@ func NewWorker(ctx context.Context, opt WorkerOpt) (*Worker, error) {
 		return nil, err
 	}
 
+	if opt.Root != "" {
+		// remove the images of fsimage exports interrupted by a restart
+		if err := os.RemoveAll(filepath.Join(opt.Root, "fsimage")); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	sm, err := source.NewManager()
 	if err != nil {
 		return nil, err
@@ -261,6 +287,11 @@ func (w *Worker) Close() error {
 			errs = append(errs, err)
 		}
 	}
+	for _, provider := range w.NamedNetworkProviders {
+		if err := provider.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if w.ResourceMonitor != nil {
 		if err := w.ResourceMonitor.Close(); err != nil {
 			errs = append(errs, err)
@@ -281,6 +312,14 @@ func (w *Worker) CDIManager() *cdidevices.Manager {
 	return w.WorkerOpt.CDIManager
 }
 
+func (w *Worker) SecurityProfiles() *securityprofiles.Manager {
+	return w.WorkerOpt.SecurityProfiles
+}
+
+func (w *Worker) DiskQuota() *diskquota.Controller {
+	return w.WorkerOpt.DiskQuota
+}
+
 func (w *Worker) ID() string {
 	return w.WorkerOpt.ID
 }
@@ -370,43 +409,59 @@ func (w *Worker) CacheManager() cache.Manager {
 type proxyPolicyExecutor struct {
 	executor.Executor
 	getProxyPolicy func() (network.ProxyPolicy, error)
+	configureProxy func(*network.ProxyConfig) error
 }
 
 func (e *proxyPolicyExecutor) Run(ctx context.Context, id string, rootfs executor.Mount, mounts []executor.Mount, process executor.ProcessInfo, started chan<- struct{}) (resourcestypes.Recorder, error) {
 	if process.Meta.Proxy != nil {
-		policy, err := e.proxyPolicy()
-		if err != nil {
+		if err := e.setProxyPolicy(process.Meta.Proxy); err != nil {
 			return nil, err
 		}
-		process.Meta.Proxy.Policy = policy
 	}
 	return e.Executor.Run(ctx, id, rootfs, mounts, process, started)
 }
 
 func (e *proxyPolicyExecutor) Exec(ctx context.Context, id string, process executor.ProcessInfo) error {
 	if process.Meta.Proxy != nil {
-		policy, err := e.proxyPolicy()
-		if err != nil {
+		if err := e.setProxyPolicy(process.Meta.Proxy); err != nil {
 			return err
 		}
-		process.Meta.Proxy.Policy = policy
 	}
 	return e.Executor.Exec(ctx, id, process)
 }
 
-func (e *proxyPolicyExecutor) proxyPolicy() (network.ProxyPolicy, error) {
-	policy, err := e.getProxyPolicy()
-	if err != nil {
-		return nil, err
+func (e *proxyPolicyExecutor) DialContainer(ctx context.Context, id, networkName, address string) (net.Conn, error) {
+	d, ok := e.Executor.(executor.ContainerDialer)
+	if !ok {
+		return nil, errors.New("executor does not support connections to containers")
 	}
-	return policy, nil
+	return d.DialContainer(ctx, id, networkName, address)
+}
+
+func (e *proxyPolicyExecutor) CheckpointSupported() bool {
+	c, ok := e.Executor.(executor.Checkpointer)
+	return ok && c.CheckpointSupported()
+}
+
+func (e *proxyPolicyExecutor) setProxyPolicy(cfg *network.ProxyConfig) error {
+	if e.getProxyPolicy != nil {
+		policy, err := e.getProxyPolicy()
+		if err != nil {
+			return err
+		}
+		cfg.Policy = policy
+	}
+	if e.configureProxy != nil {
+		return e.configureProxy(cfg)
+	}
+	return nil
 }
 
 func (w *Worker) ResolveOp(v solver.Vertex, s frontend.FrontendLLBBridge, sm *session.Manager, proxyOpt worker.ProxyOpt) (solver.Op, error) {
 	if baseOp, ok := v.Sys().(*pb.Op); ok {
 		switch op := baseOp.Op.(type) {
 		case *pb.Op_Source:
-			return ops.NewSourceOp(v, op, baseOp.Platform, w.SourceManager, w.ParallelismSem, sm, w)
+			return ops.NewSourceOp(v, op, baseOp.Platform, w.SourceManager, w.Scheduler, sm, w)
 		case *pb.Op_Exec:
 			var linuxResources *pb.LinuxResources
 			if m, ok := v.Options().Metadata.(*linuxresources.Metadata); ok && m != nil {
@@ -415,13 +470,17 @@ func (w *Worker) ResolveOp(v solver.Vertex, s frontend.FrontendLLBBridge, sm *se
 			exec := w.WorkerOpt.Executor
 			proxyNetwork := proxyOpt.Network && op.Exec.Network != pb.NetMode_NONE
 			if proxyNetwork {
-				if proxyOpt.Policy != nil {
-					exec = &proxyPolicyExecutor{Executor: exec, getProxyPolicy: proxyOpt.Policy}
+				if proxyOpt.Policy != nil || proxyOpt.Configure != nil {
+					exec = &proxyPolicyExecutor{Executor: exec, getProxyPolicy: proxyOpt.Policy, configureProxy: proxyOpt.Configure}
 				}
 			}
-			return ops.NewExecOp(v, op, baseOp.Platform, w.CacheMgr, w.ParallelismSem, sm, exec, w, linuxResources, proxyNetwork)
+			var checkpointScope func() (string, error)
+			if cs, ok := s.(interface{ CheckpointScope() (string, error) }); ok {
+				checkpointScope = cs.CheckpointScope
+			}
+			return ops.NewExecOp(v, op, baseOp.Platform, w.CacheMgr, w.Scheduler, sm, exec, w, linuxResources, proxyNetwork, checkpointScope)
 		case *pb.Op_File:
-			return ops.NewFileOp(v, op, w.CacheMgr, w.ParallelismSem, w)
+			return ops.NewFileOp(v, op, w.CacheMgr, w.Scheduler, w)
 		case *pb.Op_Build:
 			return ops.NewBuildOp(v, op, s, w)
 		case *pb.Op_Merge:
@@ -593,6 +652,9 @@ func (w *Worker) DiskUsage(ctx context.Context, opt client.DiskUsageInfo) ([]*cl
 }
 
 func (w *Worker) Prune(ctx context.Context, ch chan client.UsageInfo, opt ...client.PruneInfo) error {
+	if err := proxystore.Prune(ctx, w.ContentStore(), w.LeaseManager(), ch, opt...); err != nil {
+		return err
+	}
 	return w.CacheMgr.Prune(ctx, ch, opt...)
 }
 
@@ -614,6 +676,11 @@ func (w *Worker) Exporter(name string, sm *session.Manager) (exporter.Exporter,
 		return tarexporter.New(tarexporter.Opt{
 			SessionManager: sm,
 		})
+	case client.ExporterFSImage:
+		return fsimageexporter.New(fsimageexporter.Opt{
+			SessionManager: sm,
+			Root:           filepath.Join(w.Root, "fsimage"),
+		})
 	case client.ExporterOCI:
 		return ociexporter.New(ociexporter.Opt{
 			SessionManager: sm,
@@ -14,9 +14,11 @@ import (
 	"github.com/containerd/platforms"
 	"github.com/moby/buildkit/cache/metadata"
 	"github.com/moby/buildkit/executor/containerdexecutor"
+	"github.com/moby/buildkit/executor/diskquota"
 	"github.com/moby/buildkit/executor/oci"
 	containerdsnapshot "github.com/moby/buildkit/snapshot/containerd"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/leaseutil"
 	"github.com/moby/buildkit/util/network/netproviders"
 	"github.com/moby/buildkit/util/winlayers"
@@ -42,6 +44,7 @@ type WorkerOptions struct {
 	ApparmorProfile string
 	Selinux         bool
 	ParallelismSem  *semaphore.Weighted
+	Scheduler       *fairshare.Scheduler
 	TraceSocket     string
 	Runtime         *RuntimeInfo
 	CDIManager      *cdidevices.Manager
@@ -83,6 +86,10 @@ func newContainerd(client *ctd.Client, workerOpts WorkerOptions) (base.WorkerOpt
 	if err != nil {
 		return base.WorkerOpt{}, err
 	}
+	namedNP, err := netproviders.NamedProviders(workerOpts.NetworkOpt)
+	if err != nil {
+		return base.WorkerOpt{}, err
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -140,39 +147,43 @@ func newContainerd(client *ctd.Client, workerOpts WorkerOptions) (base.WorkerOpt
 	}
 
 	executorOpts := containerdexecutor.ExecutorOptions{
-		Client:           client,
-		Root:             root,
-		CgroupParent:     workerOpts.CgroupParent,
-		ApparmorProfile:  workerOpts.ApparmorProfile,
-		DNSConfig:        workerOpts.DNS,
-		Selinux:          workerOpts.Selinux,
-		TraceSocket:      workerOpts.TraceSocket,
-		Rootless:         workerOpts.Rootless,
-		Runtime:          workerOpts.Runtime,
-		CDIManager:       workerOpts.CDIManager,
-		NetworkProviders: np,
-		ProxyProvider:    proxyProvider,
+		Client:                client,
+		Root:                  root,
+		CgroupParent:          workerOpts.CgroupParent,
+		ApparmorProfile:       workerOpts.ApparmorProfile,
+		DNSConfig:             workerOpts.DNS,
+		Selinux:               workerOpts.Selinux,
+		TraceSocket:           workerOpts.TraceSocket,
+		Rootless:              workerOpts.Rootless,
+		Runtime:               workerOpts.Runtime,
+		CDIManager:            workerOpts.CDIManager,
+		NetworkProviders:      np,
+		ProxyProvider:         proxyProvider,
+		NamedNetworkProviders: namedNP,
 	}
 
 	opt := base.WorkerOpt{
-		ID:               id,
-		Root:             root,
-		Labels:           xlabels,
-		MetadataStore:    md,
-		NetworkProviders: np,
-		ProxyProvider:    proxyProvider,
-		Executor:         containerdexecutor.New(executorOpts),
-		Snapshotter:      containerdsnapshot.NewSnapshotter(workerOpts.SnapshotterName, client.SnapshotService(workerOpts.SnapshotterName), workerOpts.Namespace, nil),
-		ContentStore:     cs,
-		Applier:          winlayers.NewFileSystemApplierWithWindows(cs, df),
-		Differ:           winlayers.NewWalkingDiffWithWindows(cs, df),
-		ImageStore:       client.ImageService(),
-		Platforms:        platformSpecs,
-		LeaseManager:     lm,
-		GarbageCollect:   gc,
-		ParallelismSem:   workerOpts.ParallelismSem,
-		MountPoolRoot:    filepath.Join(root, "cachemounts"),
-		CDIManager:       workerOpts.CDIManager,
+		ID:                    id,
+		Root:                  root,
+		Labels:                xlabels,
+		MetadataStore:         md,
+		NetworkProviders:      np,
+		ProxyProvider:         proxyProvider,
+		NamedNetworkProviders: namedNP,
+		Executor:              containerdexecutor.New(executorOpts),
+		Snapshotter:           containerdsnapshot.NewSnapshotter(workerOpts.SnapshotterName, client.SnapshotService(workerOpts.SnapshotterName), workerOpts.Namespace, nil),
+		ContentStore:          cs,
+		Applier:               winlayers.NewFileSystemApplierWithWindows(cs, df),
+		Differ:                winlayers.NewWalkingDiffWithWindows(cs, df),
+		ImageStore:            client.ImageService(),
+		Platforms:             platformSpecs,
+		LeaseManager:          lm,
+		GarbageCollect:        gc,
+		ParallelismSem:        workerOpts.ParallelismSem,
+		Scheduler:             workerOpts.Scheduler,
+		MountPoolRoot:         filepath.Join(root, "cachemounts"),
+		CDIManager:            workerOpts.CDIManager,
+		DiskQuota:             diskquota.NewController(filepath.Join(root, "diskquota")),
 	}
 	return opt, nil
 }
@@ -11,6 +11,9 @@ const (
 	ApparmorProfile     = prefix + "apparmor.profile"
 	SELinuxEnabled      = prefix + "selinux.enabled"      // "true" or "false"
 	OCIProcessMode      = prefix + "oci.process-mode"     // OCI worker: process mode ("sandbox", "no-sandbox")
+	OCIRuntimes         = prefix + "oci.runtimes"         // OCI worker: comma-separated names of the runtimes that execs can select
+	OCIDefaultRuntime   = prefix + "oci.runtimes.default" // OCI worker: name of the runtime of execs that don't select one
+	OCIUserRemap        = prefix + "oci.userremap"        // OCI worker: "true" if execs run with distinct subordinate ID ranges
 	ContainerdUUID      = prefix + "containerd.uuid"      // containerd worker: containerd UUID
 	ContainerdNamespace = prefix + "containerd.namespace" // containerd worker: containerd namespace
 )
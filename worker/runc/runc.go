@@ -7,7 +7,9 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/containerd/containerd/v2/core/diff/apply"
 	ctdmetadata "github.com/containerd/containerd/v2/core/metadata"
@@ -16,20 +18,21 @@ import (
 	"github.com/containerd/containerd/v2/plugins/diff/walking"
 	"github.com/containerd/platforms"
 	"github.com/moby/buildkit/cache/metadata"
+	"github.com/moby/buildkit/executor/diskquota"
 	"github.com/moby/buildkit/executor/oci"
 	"github.com/moby/buildkit/executor/resources"
 	"github.com/moby/buildkit/executor/runcexecutor"
 	containerdsnapshot "github.com/moby/buildkit/snapshot/containerd"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/util/fairshare"
 	"github.com/moby/buildkit/util/leaseutil"
 	"github.com/moby/buildkit/util/network/netproviders"
 	"github.com/moby/buildkit/util/winlayers"
 	"github.com/moby/buildkit/worker/base"
 	wlabel "github.com/moby/buildkit/worker/label"
-	"github.com/moby/sys/user"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
 	bolt "go.etcd.io/bbolt"
-	"golang.org/x/sync/semaphore"
 )
 
 // SnapshotterFactory instantiates a snapshotter
@@ -38,20 +41,65 @@ type SnapshotterFactory struct {
 	New  func(root string) (ctdsnapshot.Snapshotter, error)
 }
 
+type WorkerOptions struct {
+	Root               string
+	SnapshotterFactory SnapshotterFactory
+	Rootless           bool
+	ProcessMode        oci.ProcessMode
+	Labels             map[string]string
+	// UserRemap runs build steps in user namespaces with distinct
+	// subordinate ID ranges if it is set.
+	UserRemap  *oci.IDMapPool
+	NetworkOpt netproviders.Opt
+	DNS        *oci.DNSConfig
+	// Binary is the OCI runtime binary, defaults to buildkit-runc or runc.
+	Binary string
+	// Runtimes are the binaries of the OCI runtimes that build steps can
+	// select by name.
+	Runtimes map[string]string
+	// DefaultRuntime is the name of the runtime of Runtimes that replaces
+	// Binary.
+	DefaultRuntime  string
+	ApparmorProfile string
+	Selinux         bool
+	Scheduler       *fairshare.Scheduler
+	TraceSocket     string
+	CgroupParent    string
+	CDIManager      *cdidevices.Manager
+	// Checkpoint enables checkpointing long-running build steps.
+	Checkpoint bool
+}
+
 // NewWorkerOpt creates a WorkerOpt.
-func NewWorkerOpt(root string, snFactory SnapshotterFactory, rootless bool, processMode oci.ProcessMode, labels map[string]string, idmap *user.IdentityMapping, nopt netproviders.Opt, dns *oci.DNSConfig, binary, apparmorProfile string, selinux bool, parallelismSem *semaphore.Weighted, traceSocket, defaultCgroupParent string, cdiManager *cdidevices.Manager) (base.WorkerOpt, error) {
+func NewWorkerOpt(workerOpts WorkerOptions) (base.WorkerOpt, error) {
 	var opt base.WorkerOpt
+	snFactory := workerOpts.SnapshotterFactory
 	name := "runc-" + snFactory.Name
-	root = filepath.Join(root, name)
+	root := filepath.Join(workerOpts.Root, name)
 	if err := os.MkdirAll(root, 0700); err != nil {
 		return opt, err
 	}
 
-	np, proxyProvider, npResolvedMode, err := netproviders.Providers(nopt)
+	np, proxyProvider, npResolvedMode, err := netproviders.Providers(workerOpts.NetworkOpt)
+	if err != nil {
+		return opt, err
+	}
+	namedNP, err := netproviders.NamedProviders(workerOpts.NetworkOpt)
 	if err != nil {
 		return opt, err
 	}
 
+	// the default runtime replaces the binary for execs that don't select a
+	// runtime
+	binary := workerOpts.Binary
+	if workerOpts.DefaultRuntime != "" {
+		b, ok := workerOpts.Runtimes[workerOpts.DefaultRuntime]
+		if !ok {
+			return opt, errors.Errorf("default runtime %q is not configured", workerOpts.DefaultRuntime)
+		}
+		binary = b
+	}
+
 	// Check if user has specified OCI worker binary; if they have, append it to cmds
 	var cmds []string
 	if binary != "" {
@@ -70,17 +118,20 @@ func NewWorkerOpt(root string, snFactory SnapshotterFactory, rootless bool, proc
 		// Otherwise, a nil array will be sent and the default OCI worker binary will be used
 		CommandCandidates: cmds,
 		// without root privileges
-		Rootless:            rootless,
-		ProcessMode:         processMode,
-		IdentityMapping:     idmap,
-		DNS:                 dns,
-		ApparmorProfile:     apparmorProfile,
-		SELinux:             selinux,
-		TracingSocket:       traceSocket,
-		DefaultCgroupParent: defaultCgroupParent,
-		ResourceMonitor:     rm,
-		CDIManager:          cdiManager,
-		ProxyProvider:       proxyProvider,
+		Rootless:              workerOpts.Rootless,
+		ProcessMode:           workerOpts.ProcessMode,
+		UserRemap:             workerOpts.UserRemap,
+		DNS:                   workerOpts.DNS,
+		ApparmorProfile:       workerOpts.ApparmorProfile,
+		SELinux:               workerOpts.Selinux,
+		TracingSocket:         workerOpts.TraceSocket,
+		DefaultCgroupParent:   workerOpts.CgroupParent,
+		ResourceMonitor:       rm,
+		CDIManager:            workerOpts.CDIManager,
+		ProxyProvider:         proxyProvider,
+		NamedNetworkProviders: namedNP,
+		Checkpoint:            workerOpts.Checkpoint,
+		Runtimes:              workerOpts.Runtimes,
 	}, np)
 	if err != nil {
 		return opt, err
@@ -124,14 +175,23 @@ func NewWorkerOpt(root string, snFactory SnapshotterFactory, rootless bool, proc
 		wlabel.Snapshotter:    snFactory.Name,
 		wlabel.Hostname:       hostname,
 		wlabel.Network:        npResolvedMode,
-		wlabel.OCIProcessMode: processMode.String(),
-		wlabel.SELinuxEnabled: strconv.FormatBool(selinux),
+		wlabel.OCIProcessMode: workerOpts.ProcessMode.String(),
+		wlabel.SELinuxEnabled: strconv.FormatBool(workerOpts.Selinux),
+	}
+	if workerOpts.ApparmorProfile != "" {
+		xlabels[wlabel.ApparmorProfile] = workerOpts.ApparmorProfile
+	}
+	if workerOpts.UserRemap != nil {
+		xlabels[wlabel.OCIUserRemap] = "true"
+	}
+	if len(workerOpts.Runtimes) > 0 {
+		xlabels[wlabel.OCIRuntimes] = strings.Join(slices.Sorted(maps.Keys(workerOpts.Runtimes)), ",")
 	}
-	if apparmorProfile != "" {
-		xlabels[wlabel.ApparmorProfile] = apparmorProfile
+	if workerOpts.DefaultRuntime != "" {
+		xlabels[wlabel.OCIDefaultRuntime] = workerOpts.DefaultRuntime
 	}
 
-	maps.Copy(xlabels, labels)
+	maps.Copy(xlabels, workerOpts.Labels)
 
 	md, err := metadata.NewStore(filepath.Join(root, "metadata_v2.db"))
 	if err != nil {
@@ -139,26 +199,27 @@ func NewWorkerOpt(root string, snFactory SnapshotterFactory, rootless bool, proc
 	}
 
 	opt = base.WorkerOpt{
-		ID:               id,
-		Root:             root,
-		Labels:           xlabels,
-		MetadataStore:    md,
-		NetworkProviders: np,
-		ProxyProvider:    proxyProvider,
-		Executor:         exe,
-		Snapshotter:      containerdsnapshot.NewSnapshotter(snFactory.Name, mdb.Snapshotter(snFactory.Name), "buildkit", idmap),
-		ContentStore:     c,
-		Applier:          winlayers.NewFileSystemApplierWithWindows(c, apply.NewFileSystemApplier(c)),
-		Differ:           winlayers.NewWalkingDiffWithWindows(c, walking.NewWalkingDiff(c)),
-		ImageStore:       nil, // explicitly
-		Platforms:        []ocispecs.Platform{platforms.Normalize(platforms.DefaultSpec())},
-		IdentityMapping:  idmap,
-		LeaseManager:     leaseutil.WithNamespace(ctdmetadata.NewLeaseManager(mdb), "buildkit"),
-		GarbageCollect:   mdb.GarbageCollect,
-		ParallelismSem:   parallelismSem,
-		MountPoolRoot:    filepath.Join(root, "cachemounts"),
-		ResourceMonitor:  rm,
-		CDIManager:       cdiManager,
+		ID:                    id,
+		Root:                  root,
+		Labels:                xlabels,
+		MetadataStore:         md,
+		NetworkProviders:      np,
+		ProxyProvider:         proxyProvider,
+		NamedNetworkProviders: namedNP,
+		Executor:              exe,
+		Snapshotter:           containerdsnapshot.NewSnapshotter(snFactory.Name, mdb.Snapshotter(snFactory.Name), "buildkit", nil),
+		ContentStore:          c,
+		Applier:               winlayers.NewFileSystemApplierWithWindows(c, apply.NewFileSystemApplier(c)),
+		Differ:                winlayers.NewWalkingDiffWithWindows(c, walking.NewWalkingDiff(c)),
+		ImageStore:            nil, // explicitly
+		Platforms:             []ocispecs.Platform{platforms.Normalize(platforms.DefaultSpec())},
+		LeaseManager:          leaseutil.WithNamespace(ctdmetadata.NewLeaseManager(mdb), "buildkit"),
+		GarbageCollect:        mdb.GarbageCollect,
+		Scheduler:             workerOpts.Scheduler,
+		MountPoolRoot:         filepath.Join(root, "cachemounts"),
+		ResourceMonitor:       rm,
+		CDIManager:            workerOpts.CDIManager,
+		DiskQuota:             diskquota.NewController(filepath.Join(root, "diskquota")),
 	}
 	return opt, nil
 }
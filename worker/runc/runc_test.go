@@ -9,6 +9,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -24,6 +26,8 @@ import (
 	"github.com/moby/buildkit/util/network/netproviders"
 	"github.com/moby/buildkit/worker/base"
 	"github.com/moby/buildkit/worker/tests"
+	"github.com/moby/sys/user"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -41,8 +45,12 @@ func newWorkerOpt(t *testing.T, processMode oci.ProcessMode) base.WorkerOpt {
 			return overlay.NewSnapshotter(root)
 		},
 	}
-	rootless := false
-	workerOpt, err := NewWorkerOpt(tmpdir, snFactory, rootless, processMode, nil, nil, netproviders.Opt{Mode: "host"}, nil, "", "", false, nil, "", "", nil)
+	workerOpt, err := NewWorkerOpt(WorkerOptions{
+		Root:               tmpdir,
+		SnapshotterFactory: snFactory,
+		ProcessMode:        processMode,
+		NetworkOpt:         netproviders.Opt{Mode: "host"},
+	})
 	require.NoError(t, err)
 
 	return workerOpt
@@ -244,6 +252,120 @@ func TestRuncWorkerCancel(t *testing.T) {
 	tests.TestWorkerCancel(t, w)
 }
 
+func TestRuncWorkerUserRemap(t *testing.T) {
+	t.Parallel()
+	checkRequirement(t)
+
+	// a single range, so that a second build step can't get one
+	userRemap, err := oci.NewIDMapPool(user.IdentityMapping{
+		UIDMaps: []user.IDMap{{ID: 0, ParentID: 300000, Count: oci.IDMapRangeSize}},
+		GIDMaps: []user.IDMap{{ID: 0, ParentID: 300000, Count: oci.IDMapRangeSize}},
+	}, oci.IDMapRangeSize)
+	require.NoError(t, err)
+
+	snFactory := SnapshotterFactory{
+		Name: "overlayfs",
+		New: func(root string) (ctdsnapshot.Snapshotter, error) {
+			return overlay.NewSnapshotter(root)
+		},
+	}
+	workerOpt, err := NewWorkerOpt(WorkerOptions{
+		Root:               t.TempDir(),
+		SnapshotterFactory: snFactory,
+		ProcessMode:        oci.ProcessSandbox,
+		UserRemap:          userRemap,
+		NetworkOpt:         netproviders.Opt{Mode: "host"},
+	})
+	if err != nil && strings.Contains(err.Error(), "requires idmapped mounts") {
+		t.Skip(err.Error())
+	}
+	require.NoError(t, err)
+	w, err := base.NewWorker(context.TODO(), workerOpt)
+	require.NoError(t, err)
+
+	ctx := tests.NewCtx("buildkit-test")
+	sm, err := session.NewManager()
+	require.NoError(t, err)
+	snap := tests.NewBusyboxSourceSnapshot(ctx, t, w, sm)
+	root, err := w.CacheMgr.New(ctx, snap, nil)
+	require.NoError(t, err)
+	out, err := w.CacheMgr.New(ctx, nil, nil)
+	require.NoError(t, err)
+
+	// files are created through the idmapped overlay of the rootfs and the
+	// idmapped bind mount of /out
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	meta := executor.Meta{
+		Args: []string{"/bin/sh", "-c", "id -u && touch /root-file /out/root-file && touch /user-file /out/user-file && chown 1000:1001 /user-file /out/user-file"},
+		Cwd:  "/",
+	}
+	mounts := []executor.Mount{{Src: &mountable{m: out}, Dest: "/out"}}
+	_, err = w.WorkerOpt.Executor.Run(ctx, "", execMount(root, false), mounts, executor.ProcessInfo{Meta: meta, Stdout: &iohelper.NopWriteCloser{Writer: stdout}, Stderr: &iohelper.NopWriteCloser{Writer: stderr}}, nil)
+	require.NoError(t, err, "stdout=%q, stderr=%q", stdout.String(), stderr.String())
+	require.Equal(t, "0\n", stdout.String())
+
+	// the files are stored with the IDs they have inside the container
+	for _, ref := range []cache.MutableRef{root, out} {
+		rf, err := ref.Commit(ctx)
+		require.NoError(t, err)
+		mounts, err := rf.Mount(ctx, true, nil)
+		require.NoError(t, err)
+		lm := snapshot.LocalMounter(mounts)
+		target, err := lm.Mount()
+		require.NoError(t, err)
+		for name, ids := range map[string][2]uint32{
+			"root-file": {0, 0},
+			"user-file": {1000, 1001},
+		} {
+			var st syscall.Stat_t
+			require.NoError(t, syscall.Lstat(filepath.Join(target, name), &st))
+			require.Equal(t, ids, [2]uint32{st.Uid, st.Gid}, name)
+		}
+		require.NoError(t, lm.Unmount())
+		require.NoError(t, rf.Release(ctx))
+	}
+
+	// a running container holds the only range
+	svcRoot, err := w.CacheMgr.New(ctx, snap, nil)
+	require.NoError(t, err)
+	defer svcRoot.Release(context.WithoutCancel(ctx))
+	svcCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	started := make(chan struct{})
+	svcDone := make(chan error, 1)
+	go func() {
+		_, err := w.WorkerOpt.Executor.Run(svcCtx, "svc", execMount(svcRoot, false), nil, executor.ProcessInfo{Meta: executor.Meta{Args: []string{"/bin/sleep", "60"}, Cwd: "/"}}, started)
+		svcDone <- err
+	}()
+	select {
+	case <-started:
+	case err := <-svcDone:
+		t.Fatalf("service container exited: %v", err)
+	}
+
+	// another build step fails instead of waiting for the range
+	stepRoot, err := w.CacheMgr.New(ctx, snap, nil)
+	require.NoError(t, err)
+	defer stepRoot.Release(context.WithoutCancel(ctx))
+	meta = executor.Meta{Args: []string{"/bin/true"}, Cwd: "/"}
+	_, err = w.WorkerOpt.Executor.Run(ctx, "", execMount(stepRoot, false), nil, executor.ProcessInfo{Meta: meta}, nil)
+	require.ErrorIs(t, err, oci.ErrIDMapPoolExhausted)
+
+	// a container that joins the network of the running container shares
+	// its range
+	meta.NetworkFrom = "svc"
+	_, err = w.WorkerOpt.Executor.Run(ctx, "", execMount(stepRoot, false), nil, executor.ProcessInfo{Meta: meta}, nil)
+	require.NoError(t, err)
+
+	// the range is released when the container exits
+	cancel(errors.New("stop service"))
+	<-svcDone
+	meta.NetworkFrom = ""
+	_, err = w.WorkerOpt.Executor.Run(ctx, "", execMount(stepRoot, false), nil, executor.ProcessInfo{Meta: meta}, nil)
+	require.NoError(t, err)
+}
+
 func execMount(m cache.Mountable, readonly bool) executor.Mount {
 	return executor.Mount{Src: &mountable{m: m}, Readonly: readonly}
 }
@@ -8,12 +8,14 @@ import (
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb/sourceresolver"
 	"github.com/moby/buildkit/executor"
+	"github.com/moby/buildkit/executor/diskquota"
 	"github.com/moby/buildkit/exporter"
 	"github.com/moby/buildkit/frontend"
 	"github.com/moby/buildkit/session"
 	containerdsnapshot "github.com/moby/buildkit/snapshot/containerd"
 	"github.com/moby/buildkit/solver"
 	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/solver/llbsolver/securityprofiles"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/leaseutil"
 	"github.com/moby/buildkit/util/network"
@@ -23,6 +25,9 @@ import (
 type ProxyOpt struct {
 	Network bool
 	Policy  func() (network.ProxyPolicy, error)
+	// Configure sets the build specific options of the proxy network of an
+	// exec.
+	Configure func(*network.ProxyConfig) error
 }
 
 type Worker interface {
@@ -49,6 +54,8 @@ type Worker interface {
 	LeaseManager() *leaseutil.Manager
 	GarbageCollect(context.Context) error
 	CDIManager() *cdidevices.Manager
+	SecurityProfiles() *securityprofiles.Manager
+	DiskQuota() *diskquota.Controller
 }
 
 type Infos interface {